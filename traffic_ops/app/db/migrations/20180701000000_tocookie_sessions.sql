@@ -0,0 +1,38 @@
+/*
+
+    Licensed under the Apache License, Version 2.0 (the "License");
+    you may not use this file except in compliance with the License.
+    You may obtain a copy of the License at
+
+        http://www.apache.org/licenses/LICENSE-2.0
+
+    Unless required by applicable law or agreed to in writing, software
+    distributed under the License is distributed on an "AS IS" BASIS,
+    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+    See the License for the specific language governing permissions and
+    limitations under the License.
+*/
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+
+CREATE TABLE IF NOT EXISTS tocookie_session (
+    id text PRIMARY KEY,
+    claims text NOT NULL,
+    expires timestamp with time zone NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS tocookie_session_expires_idx ON tocookie_session USING btree (expires);
+
+CREATE TABLE IF NOT EXISTS tocookie_revocation (
+    session_id text PRIMARY KEY,
+    until timestamp with time zone NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS tocookie_revocation_until_idx ON tocookie_revocation USING btree (until);
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+
+DROP TABLE IF EXISTS tocookie_revocation;
+DROP TABLE IF EXISTS tocookie_session;
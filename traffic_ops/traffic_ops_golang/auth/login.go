@@ -76,9 +76,10 @@ func LoginHandler(db *sqlx.DB, cfg config.Config) http.HandlerFunc {
 			}
 			if authenticated {
 				expiry := time.Now().Add(time.Hour * 6)
-				cookie := tocookie.New(form.Username, expiry, cfg.Secrets[0])
-				httpCookie := http.Cookie{Name: "mojolicious", Value: cookie, Path: "/", Expires: expiry, HttpOnly: true}
-				http.SetCookie(w, &httpCookie)
+				if cfg.CookieIdleTimeout > 0 {
+					expiry = time.Now().Add(time.Duration(cfg.CookieIdleTimeout) * time.Second)
+				}
+				http.SetCookie(w, tocookie.NewHTTPCookie(form.Username, expiry, cfg.Secrets[0]))
 				resp = struct {
 					tc.Alerts
 				}{tc.CreateAlerts(tc.SuccessLevel, "Successfully logged in.")}
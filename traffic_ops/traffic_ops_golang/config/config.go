@@ -29,6 +29,7 @@ import (
 
 	"github.com/apache/trafficcontrol/lib/go-log"
 	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/riaksvc"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/tocookie"
 	"github.com/basho/riak-go-client"
 )
 
@@ -77,6 +78,10 @@ type ConfigTrafficOpsGolang struct {
 	BackendMaxConnections  map[string]int `json:"backend_max_connections"`
 	ProfilingEnabled       bool           `json:"profiling_enabled"`
 	ProfilingLocation      string         `json:"profiling_location"`
+	CookieRefreshWindow    int            `json:"cookie_refresh_window"`
+	CookieMaxLifetime      int            `json:"cookie_max_lifetime"`
+	CookieIdleTimeout      int            `json:"cookie_idle_timeout"`
+	CookieLeeway           int            `json:"cookie_leeway"`
 }
 
 // ConfigDatabase reflects the structure of the database.conf file
@@ -192,11 +197,22 @@ func LoadConfig(cdnConfPath string, dbConfPath string, riakConfPath string, appV
 			}
 		} else {
 			cfg.LDAPEnabled = false
-			return cfg, []error{}, AllowStartup // no ldap.conf, disable and allow startup
+			return cfg, weakSecretErrs(cfg), AllowStartup // no ldap.conf, disable and allow startup
 		}
 	}
 
-	return cfg, []error{}, AllowStartup
+	return cfg, weakSecretErrs(cfg), AllowStartup
+}
+
+// weakSecretErrs returns an error for each of the secrets which tocookie.ValidateSecret rejects. They are logged as warnings rather than blocking startup, so installations still using the secret of the example cdn.conf keep running until it is replaced.
+func weakSecretErrs(cfg Config) []error {
+	errs := []error{}
+	for i, secret := range cfg.Secrets {
+		if err := tocookie.ValidateSecret(secret); err != nil {
+			errs = append(errs, fmt.Errorf("cdn.conf secrets[%d] is weak, so anyone who guesses it can forge sessions: %w", i, err))
+		}
+	}
+	return errs
 }
 
 // GetCertPath - extracts path to cert .cert file
@@ -21,6 +21,7 @@ package config
 
 import (
 	"crypto/tls"
+	"errors"
 	"flag"
 	"fmt"
 	"io/ioutil"
@@ -29,6 +30,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/tocookie"
 	"github.com/basho/riak-go-client"
 )
 
@@ -132,7 +134,7 @@ const (
 		"user_register_path" : "user"
 	},
 	"secrets" : [
-		"mONKEYDOmONKEYSEE."
+		"q3Zk8vR2nX0bLw5tYp7hJm4sGd1fCa9e"
 	],
 	"geniso" : {
 		"iso_root_path" : "/opt/traffic_ops/app/public"
@@ -244,6 +246,20 @@ func TestLoadConfig(t *testing.T) {
 		t.Error("expected blockStartup to be false but it was ", blockStartup)
 	}
 
+	// cdn.conf of the example secret
+	weakCfg, err := tempFileWith([]byte(strings.Replace(goodConfig, "q3Zk8vR2nX0bLw5tYp7hJm4sGd1fCa9e", "mONKEYDOmONKEYSEE.", 1)))
+	if err != nil {
+		t.Errorf("cannot create temp file: %v", err)
+	}
+	defer os.Remove(weakCfg) // clean up
+	_, errs, blockStartup = LoadConfig(weakCfg, goodDbCfg, goodRiakCfg, version)
+	if len(errs) != 1 || !errors.Is(errs[0], tocookie.ErrWeakSecret) {
+		t.Error("Weak secret -- expected one weak secret error, got: ", errs)
+	}
+	if blockStartup != false {
+		t.Error("Weak secret -- expected blockStartup to be false but it was ", blockStartup)
+	}
+
 	expectedRiak := riak.AuthOptions{User: "riakuser", Password: "password", TlsConfig: &tls.Config{InsecureSkipVerify: true}}
 
 	if cfg.RiakAuthOptions.User != expectedRiak.User || cfg.RiakAuthOptions.Password != expectedRiak.Password || !reflect.DeepEqual(cfg.RiakAuthOptions.TlsConfig, expectedRiak.TlsConfig) {
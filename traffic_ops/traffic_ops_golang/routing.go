@@ -33,6 +33,7 @@ import (
 	"github.com/apache/trafficcontrol/lib/go-log"
 	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/api"
 	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/config"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/tocookie"
 
 	"github.com/jmoiron/sqlx"
 )
@@ -218,7 +219,19 @@ func RegisterRoutes(d ServerData) error {
 		return fmt.Errorf("Error preparing db priv level query: %s", err)
 	}
 
-	authBase := AuthBase{secret: d.Config.Secrets[0], getCurrentUserInfoStmt: userInfoStmt, override: nil} //we know d.Config.Secrets is a slice of at least one or start up would fail.
+	authBase := AuthBase{
+		secret:                 d.Config.Secrets[0], //we know d.Config.Secrets is a slice of at least one or start up would fail.
+		keys:                   tocookie.NewKeySetFromSecrets(d.Config.Secrets...),
+		getCurrentUserInfoStmt: userInfoStmt,
+		override:               nil,
+		refreshWindow:          time.Duration(d.Config.CookieRefreshWindow) * time.Second,
+		maxLifetime:            time.Duration(d.Config.CookieMaxLifetime) * time.Second,
+		idleTimeout:            time.Duration(d.Config.CookieIdleTimeout) * time.Second,
+		leeway:                 time.Duration(d.Config.CookieLeeway) * time.Second,
+	}
+	if err := authBase.sessionConfig().Validate(); err != nil {
+		log.Warnf("cookie lifetime configuration: %s", err)
+	}
 	routes := CreateRouteMap(routeSlice, rawRoutes, authBase)
 	compiledRoutes := CompileRoutes(routes)
 	getReqID := nextReqIDGetter()
@@ -37,7 +37,7 @@ type key int
 const AuthWasCalled key = iota
 
 func TestCreateRouteMap(t *testing.T) {
-	authBase := AuthBase{"secret", nil, func(handlerFunc http.HandlerFunc) http.HandlerFunc {
+	authBase := AuthBase{secret: "secret", override: func(handlerFunc http.HandlerFunc) http.HandlerFunc {
 		return func(w http.ResponseWriter, r *http.Request) {
 			ctx := context.WithValue(r.Context(), AuthWasCalled, "true")
 			handlerFunc(w, r.WithContext(ctx))
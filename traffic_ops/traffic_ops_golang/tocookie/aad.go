@@ -0,0 +1,54 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"encoding/binary"
+	"hash"
+	"io"
+)
+
+// WithAssociatedData binds cookies to data which isn't stored in them, such as the host or API version they are for, so a cookie minted for one context is rejected in another. Given to New, the data is signed along with the payload; given to Parse, the signature only verifies if the same data is given.
+//
+// Both sides must agree on the data exactly, byte for byte. A cookie minted with associated data is rejected by Parse without it or with different data, and vice versa, with ErrBadSignature. Empty data is the same as none.
+func WithAssociatedData(aad []byte) Option {
+	aad = append([]byte(nil), aad...)
+	return func(o *options) { o.aad = aad }
+}
+
+// newMAC returns an HMAC of the configured hash with the key, or its signing subkey given WithKeySeparation. The associated data, if any, has already been written to it, preceded by its length as 8 big-endian bytes, so it can't be confused with the signed text, which never starts with a zero byte.
+func (o *options) newMAC(key []byte) hash.Hash {
+	mac := hmac.New(o.hash.New, o.macKey(key))
+	o.writeAAD(mac)
+	return mac
+}
+
+// withAAD returns the message preceded by the associated data, as written by newMAC, for MACs computed elsewhere; see WithMACer.
+func (o *options) withAAD(message []byte) []byte {
+	buf := bytes.Buffer{}
+	o.writeAAD(&buf)
+	buf.Write(message)
+	return buf.Bytes()
+}
+
+// writeAAD writes the associated data, if any, preceded by its length, to a new or reset HMAC, or the input of a remote one.
+func (o *options) writeAAD(mac io.Writer) {
+	if len(o.aad) > 0 {
+		aadLen := [8]byte{}
+		binary.BigEndian.PutUint64(aadLen[:], uint64(len(o.aad)))
+		mac.Write(aadLen[:])
+		mac.Write(o.aad)
+	}
+}
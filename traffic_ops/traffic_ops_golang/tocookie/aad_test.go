@@ -0,0 +1,59 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWithAssociatedData(t *testing.T) {
+	secret := "secret"
+	expiration := time.Now().Add(time.Minute)
+	hostA := WithAssociatedData([]byte("host-a.example.net"))
+	cookie := New("alice", expiration, secret, hostA)
+
+	if c, err := Parse(secret, cookie, hostA); err != nil || c.AuthData != "alice" {
+		t.Errorf("Parse with the same associated data expected alice and nil error, actual: %+v %v", c, err)
+	}
+	if c, err := Parse(secret, New("alice", expiration, secret, WithAssociatedData(nil))); err != nil || c.AuthData != "alice" {
+		t.Errorf("Parse of cookie with empty associated data expected alice and nil error, actual: %+v %v", c, err)
+	}
+	if cookie == New("alice", expiration, secret) {
+		t.Errorf("New with associated data expected a different signature than without")
+	}
+
+	tests := map[string]struct {
+		cookie string
+		opts   []Option
+	}{
+		"other host":    {cookie, []Option{WithAssociatedData([]byte("host-b.example.net"))}},
+		"none on parse": {cookie, nil},
+		"none on mint":  {New("alice", expiration, secret), []Option{hostA}},
+		"prefix":        {cookie, []Option{WithAssociatedData([]byte("host-a"))}},
+		"version 2":     {New("alice", expiration, secret, hostA, WithCompression(CodecGzip)), nil},
+	}
+	for name, test := range tests {
+		if _, err := Parse(secret, test.cookie, test.opts...); !errors.Is(err, ErrBadSignature) {
+			t.Errorf("%v: Parse expected ErrBadSignature, actual: %v", name, err)
+		}
+	}
+
+	aad := []byte("host-a.example.net")
+	opt := WithAssociatedData(aad)
+	aad[0] = 'X'
+	if _, err := Parse(secret, cookie, opt); err != nil {
+		t.Errorf("WithAssociatedData expected to copy its data, actual: %v", err)
+	}
+}
@@ -0,0 +1,164 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ActionTokenPrefix is the prefix of action tokens, so they can be told apart from cookies and API tokens. The version is bumped if the format changes.
+const ActionTokenPrefix = "toact_v1_"
+
+// DefaultActionTokenDuration is how long action tokens last, if NewActionToken isn't given an expiration: long enough to follow a link from an email, and short enough that a link left in a mailbox is soon useless.
+const DefaultActionTokenDuration = time.Hour
+
+// actionTokenInfo is the HKDF info of the key action tokens are signed with, so a secret shared with cookies and API tokens signs them with a different key, and none can be passed off as another.
+const actionTokenInfo = "tocookie action token v1"
+
+// actionTokenMaxSize is the maximum length of an action token, which is checked before it is decoded.
+const actionTokenMaxSize = 2048
+
+// ActionToken is the claims of a token minted by NewActionToken, for links which authorize a single action of a subject, such as resetting a user's password or confirming their email address, before they can log in. Unlike cookies, action tokens are short-lived, and valid exactly once.
+type ActionToken struct {
+	// ID identifies the token, and is consumed from the NonceStore of ParseActionToken, so the token is only accepted once.
+	ID string `json:"id"`
+	// Action names what the token authorizes, e.g. "password-reset".
+	Action string `json:"act"`
+	// Subject is what the action is of, e.g. the user whose password is reset.
+	Subject string `json:"sub"`
+	// IssuedAt is when the token was minted, in seconds since the Unix epoch.
+	IssuedAt int64 `json:"iat"`
+	// ExpiresUnix is when the token expires, in seconds since the Unix epoch.
+	ExpiresUnix int64 `json:"exp"`
+}
+
+// Expires returns the expiration time of the token.
+func (t *ActionToken) Expires() time.Time {
+	return time.Unix(t.ExpiresUnix, 0)
+}
+
+// IsActionToken returns whether the string has the prefix of an action token. It doesn't verify the token.
+func IsActionToken(s string) bool {
+	return strings.HasPrefix(s, ActionTokenPrefix)
+}
+
+// NewActionToken mints a single-use token authorizing the action of the subject, expiring at the given time, or DefaultActionTokenDuration from now if it is the zero time, signed with a key derived from the given secret, and returns it along with its claims. Options such as WithClock apply as they do to New; claim options are ignored.
+//
+// The token is ActionTokenPrefix, followed by its claims as JSON, and an HMAC-SHA256 tag, both base64url-encoded and separated by a period, as API tokens are, so it is safe in URLs and needn't be escaped.
+func NewActionToken(action, subject string, expiration time.Time, key string, opts ...Option) (string, *ActionToken, error) {
+	if action == "" || subject == "" {
+		return "", nil, errors.New("action token needs an action and a subject")
+	}
+	o := newOptions(opts)
+	id, err := NewJTI()
+	if err != nil {
+		return "", nil, fmt.Errorf("generating action token id: %w", err)
+	}
+	now := o.now()
+	if expiration.IsZero() {
+		expiration = now.Add(DefaultActionTokenDuration)
+	}
+	t := &ActionToken{ID: id, Action: action, Subject: subject, IssuedAt: now.Unix(), ExpiresUnix: expiration.Unix()}
+	claims, err := json.Marshal(t)
+	if err != nil {
+		return "", nil, fmt.Errorf("marshalling action token: %w", err)
+	}
+	signed := ActionTokenPrefix + base64.RawURLEncoding.EncodeToString(claims)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(actionTokenTag(signed, key)), t, nil
+}
+
+// ParseActionToken verifies an action token minted by NewActionToken with the given secret, for the given action, and consumes its ID from the store, so it is only ever accepted once, and returns its claims. Tokens without ActionTokenPrefix are rejected with ErrMalformed, forged tokens with ErrBadSignature, tokens of other actions with ErrActionMismatch, expired ones with ErrExpired, allowing for WithLeeway, and tokens which were already consumed with ErrReplayed. The store is only consulted once the token is otherwise valid, so forged, mismatched, or expired tokens can't consume IDs. The store must remember IDs for at least as long as tokens last.
+func ParseActionToken(store NonceStore, action, key, token string, opts ...Option) (*ActionToken, error) {
+	o := newOptions(opts)
+	start := o.startTimer()
+	t, err := parseActionToken(action, key, token, o)
+	if err == nil {
+		if err = consumeNonce(store, t.ID); err != nil {
+			t = nil
+		}
+	}
+	o.observe(start, nil, err)
+	return t, err
+}
+
+// VerifyActionToken verifies an action token as ParseActionToken does, without consuming it, e.g. to show the form of a password reset link, which the form's submission then consumes. It doesn't reject tokens which have already been consumed.
+func VerifyActionToken(action, key, token string, opts ...Option) (*ActionToken, error) {
+	t, err := parseActionToken(action, key, token, newOptions(opts))
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func parseActionToken(action, key, token string, o *options) (*ActionToken, error) {
+	if !IsActionToken(token) {
+		return nil, fmt.Errorf("%w: not an action token", ErrMalformed)
+	}
+	if len(token) > actionTokenMaxSize {
+		return nil, fmt.Errorf("%w: action token longer than %d bytes", ErrMalformed, actionTokenMaxSize)
+	}
+	dot := strings.LastIndexByte(token, '.')
+	if dot == -1 {
+		return nil, fmt.Errorf("%w: action token has no signature", ErrMalformed)
+	}
+	signed := token[:dot]
+	tag, err := base64.RawURLEncoding.DecodeString(token[dot+1:])
+	if err != nil {
+		return nil, fmt.Errorf("%w: decoding action token signature: %w", ErrMalformed, err)
+	}
+	if !hmac.Equal(tag, actionTokenTag(signed, key)) {
+		return nil, ErrBadSignature
+	}
+	claims, err := base64.RawURLEncoding.DecodeString(signed[len(ActionTokenPrefix):])
+	if err != nil {
+		return nil, fmt.Errorf("%w: decoding action token: %w", ErrMalformed, err)
+	}
+	t := &ActionToken{}
+	if err := json.Unmarshal(claims, t); err != nil {
+		return nil, fmt.Errorf("%w: unmarshalling action token: %w", ErrMalformed, err)
+	}
+	if t.ID == "" || t.Action == "" || t.Subject == "" || t.ExpiresUnix == 0 {
+		return nil, fmt.Errorf("%w: action token has no id, action, subject, or expiration", ErrMalformed)
+	}
+	if t.Action != action {
+		return nil, fmt.Errorf("%w: token for '%s', not '%s'", ErrActionMismatch, t.Action, action)
+	}
+	if now := o.now(); now.Add(-o.leeway).Unix() > t.ExpiresUnix {
+		return nil, &ExpiredError{Expired: t.Expires(), Now: now}
+	}
+	return t, nil
+}
+
+// NewActionToken mints an action token with the active secret, like the package-level NewActionToken.
+func (m *Manager) NewActionToken(action, subject string, expiration time.Time, opts ...Option) (string, *ActionToken, error) {
+	return NewActionToken(action, subject, expiration, m.Secret(), m.options(opts)...)
+}
+
+// ParseActionToken verifies and consumes an action token with the active secret, like the package-level ParseActionToken.
+func (m *Manager) ParseActionToken(store NonceStore, action, token string, opts ...Option) (*ActionToken, error) {
+	return ParseActionToken(store, action, m.Secret(), token, m.options(opts)...)
+}
+
+// actionTokenTag returns the HMAC-SHA256 tag of the signed part of an action token, with a key derived from the secret by HKDF-SHA256.
+func actionTokenTag(signed, secret string) []byte {
+	mac := hmac.New(sha256.New, deriveKey([]byte(secret), nil, actionTokenInfo))
+	mac.Write([]byte(signed))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,85 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestActionToken(t *testing.T) {
+	secret := "secret"
+	store := NewMemoryNonceStore(DefaultActionTokenDuration)
+	token, claims, err := NewActionToken("password-reset", "alice", time.Time{}, secret)
+	if err != nil || !IsActionToken(token) || IsAPIToken(token) {
+		t.Fatalf("NewActionToken expected action token, actual: '%v' %v", token, err)
+	}
+	if left := time.Until(claims.Expires()); left <= DefaultActionTokenDuration-time.Minute || left > DefaultActionTokenDuration {
+		t.Errorf("NewActionToken without expiration expected DefaultActionTokenDuration, actual: %v left", left)
+	}
+
+	if v, err := VerifyActionToken("password-reset", secret, token); err != nil || v.Subject != "alice" {
+		t.Errorf("VerifyActionToken expected token of alice, actual: %+v %v", v, err)
+	}
+	if _, err := ParseActionToken(store, "confirm-email", secret, token); !errors.Is(err, ErrActionMismatch) {
+		t.Errorf("ParseActionToken of another action expected ErrActionMismatch, actual: %v", err)
+	}
+	parsed, err := ParseActionToken(store, "password-reset", secret, token)
+	if err != nil || parsed.ID != claims.ID || parsed.Subject != "alice" || parsed.Action != "password-reset" {
+		t.Errorf("ParseActionToken expected claims %+v, actual: %+v %v", claims, parsed, err)
+	}
+	if parsed, err := ParseActionToken(store, "password-reset", secret, token); !errors.Is(err, ErrReplayed) || parsed != nil {
+		t.Errorf("ParseActionToken of consumed token expected ErrReplayed, actual: %+v %v", parsed, err)
+	}
+	if _, err := VerifyActionToken("password-reset", secret, token); err != nil {
+		t.Errorf("VerifyActionToken of consumed token expected nil error, actual: %v", err)
+	}
+}
+
+func TestParseActionTokenInvalid(t *testing.T) {
+	secret := "secret"
+	store := NewMemoryNonceStore(DefaultActionTokenDuration)
+	valid, _, _ := NewActionToken("password-reset", "alice", time.Now().Add(time.Hour), secret)
+	expired, _, _ := NewActionToken("password-reset", "alice", time.Now().Add(-time.Minute), secret)
+	forged, _, _ := NewActionToken("password-reset", "alice", time.Now().Add(time.Hour), "wrong")
+	apiToken, _, _ := NewAPIToken("alice", time.Now().Add(time.Hour), secret)
+
+	tests := map[string]struct {
+		token    string
+		expected error
+	}{
+		"expired":   {expired, ErrExpired},
+		"forged":    {forged, ErrBadSignature},
+		"api token": {apiToken, ErrMalformed},
+		"cookie":    {New("alice", time.Now().Add(time.Hour), secret), ErrMalformed},
+		"unsigned":  {strings.SplitN(valid, ".", 2)[0], ErrMalformed},
+		"bad tag":   {valid + "!", ErrMalformed},
+		"too long":  {ActionTokenPrefix + strings.Repeat("a", actionTokenMaxSize), ErrMalformed},
+	}
+	for name, test := range tests {
+		if _, err := ParseActionToken(store, "password-reset", secret, test.token); !errors.Is(err, test.expected) {
+			t.Errorf("%v: ParseActionToken expected %v, actual: %v", name, test.expected, err)
+		}
+	}
+	if len(store.consumed) != 0 {
+		t.Errorf("ParseActionToken of invalid tokens expected nothing consumed, actual: %v", store.consumed)
+	}
+	if _, err := ParseActionToken(store, "password-reset", secret, expired, WithLeeway(time.Hour)); err != nil {
+		t.Errorf("ParseActionToken within the leeway expected nil error, actual: %v", err)
+	}
+	if _, _, err := NewActionToken("", "alice", time.Time{}, secret); err == nil {
+		t.Errorf("NewActionToken without action expected error, actual nil")
+	}
+}
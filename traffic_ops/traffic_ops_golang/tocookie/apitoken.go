@@ -0,0 +1,182 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// APITokenPrefix is the prefix of API tokens, so they can be told apart from cookies, and found by secret scanners when they leak into logs or repositories. The version is bumped if the format changes.
+const APITokenPrefix = "toapi_v1_"
+
+// apiTokenInfo is the HKDF info of the key API tokens are signed with, so a secret shared with cookies signs them with a different key, and neither can be passed off as the other.
+const apiTokenInfo = "tocookie api token v1"
+
+// apiTokenMaxSize is the maximum length of an API token, which is checked before it is decoded.
+const apiTokenMaxSize = 4096
+
+// apiTokenRevocationLifetime is how long API tokens without an expiration are revoked for, which is forever in practice. RevocationStore takes a time, and stores such as Redis can't expire entries arbitrarily far in the future.
+const apiTokenRevocationLifetime = 100 * 365 * 24 * time.Hour
+
+// APIToken is the claims of a token minted by NewAPIToken, for automation clients such as ORT, Ansible, and monitoring scripts, which would otherwise have to script the login form. Unlike cookies, API tokens are long-lived, aren't refreshed, and only grant their Capabilities.
+type APIToken struct {
+	// ID identifies the token, so it can be listed, and revoked with RevokeAPIToken. It is random hex, like a SessionID.
+	ID string `json:"id"`
+	// Username is the user the token acts as.
+	Username string `json:"sub"`
+	// Capabilities are what the token may be used for; see HasCapability. A token with none grants nothing.
+	Capabilities []string `json:"caps,omitempty"`
+	// IssuedAt is when the token was minted, in seconds since the Unix epoch.
+	IssuedAt int64 `json:"iat"`
+	// ExpiresUnix is when the token expires, in seconds since the Unix epoch. It is zero for tokens which don't expire, which can only be revoked.
+	ExpiresUnix int64 `json:"exp,omitempty"`
+}
+
+// Expires returns the expiration time of the token. It is the zero time for tokens which don't expire.
+func (t *APIToken) Expires() time.Time {
+	if t.ExpiresUnix == 0 {
+		return time.Time{}
+	}
+	return time.Unix(t.ExpiresUnix, 0)
+}
+
+// HasCapability returns whether the token has the given capability.
+func (t *APIToken) HasCapability(capability string) bool {
+	for _, name := range t.Capabilities {
+		if name == capability {
+			return true
+		}
+	}
+	return false
+}
+
+// IsAPIToken returns whether the string has the prefix of an API token, e.g. to tell an Authorization header which carries one from one which carries a cookie. It doesn't verify the token.
+func IsAPIToken(s string) bool {
+	return strings.HasPrefix(s, APITokenPrefix)
+}
+
+// NewAPIToken mints an API token for the user, expiring at the given time, or never if it is the zero time, signed with a key derived from the given secret, and returns it along with its claims, whose ID should be recorded so it can be revoked. The token is scoped to the capabilities given WithCapabilities; other options, such as WithClock, apply as they do to New, except claim options, which are ignored.
+//
+// The token is APITokenPrefix, followed by its claims as JSON, and an HMAC-SHA256 tag, both base64url-encoded and separated by a period. Tokens are credentials as powerful as passwords, so they should only be shown to the user once, and stored hashed, if at all.
+func NewAPIToken(user string, expiration time.Time, key string, opts ...Option) (string, *APIToken, error) {
+	o := newOptions(opts)
+	id, err := NewJTI()
+	if err != nil {
+		return "", nil, fmt.Errorf("generating api token id: %w", err)
+	}
+	t := &APIToken{
+		ID:           id,
+		Username:     user,
+		Capabilities: o.capabilities,
+		IssuedAt:     o.now().Unix(),
+	}
+	if !expiration.IsZero() {
+		t.ExpiresUnix = expiration.Unix()
+	}
+	claims, err := json.Marshal(t)
+	if err != nil {
+		return "", nil, fmt.Errorf("marshalling api token: %w", err)
+	}
+	signed := APITokenPrefix + base64.RawURLEncoding.EncodeToString(claims)
+	tag := apiTokenTag(signed, key)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(tag), t, nil
+}
+
+// ParseAPIToken verifies an API token minted by NewAPIToken with the given secret, and returns its claims. Tokens without APITokenPrefix, including cookies, are rejected with ErrMalformed, and forged tokens with ErrBadSignature. Tokens which have expired are rejected with ErrExpired, allowing for WithLeeway, and tokens revoked in the store given WithRevocationStore with ErrRevoked. The caller must still check the token's capabilities.
+func ParseAPIToken(key, token string, opts ...Option) (*APIToken, error) {
+	o := newOptions(opts)
+	start := o.startTimer()
+	t, err := parseAPIToken(key, token, o)
+	o.observe(start, nil, err)
+	return t, err
+}
+
+func parseAPIToken(key, token string, o *options) (*APIToken, error) {
+	if !IsAPIToken(token) {
+		return nil, fmt.Errorf("%w: not an api token", ErrMalformed)
+	}
+	if len(token) > apiTokenMaxSize {
+		return nil, fmt.Errorf("%w: api token longer than %d bytes", ErrMalformed, apiTokenMaxSize)
+	}
+	dot := strings.LastIndexByte(token, '.')
+	if dot == -1 {
+		return nil, fmt.Errorf("%w: api token has no signature", ErrMalformed)
+	}
+	signed := token[:dot]
+	tag, err := base64.RawURLEncoding.DecodeString(token[dot+1:])
+	if err != nil {
+		return nil, fmt.Errorf("%w: decoding api token signature: %w", ErrMalformed, err)
+	}
+	if !hmac.Equal(tag, apiTokenTag(signed, key)) {
+		return nil, ErrBadSignature
+	}
+	claims, err := base64.RawURLEncoding.DecodeString(signed[len(APITokenPrefix):])
+	if err != nil {
+		return nil, fmt.Errorf("%w: decoding api token: %w", ErrMalformed, err)
+	}
+	t := &APIToken{}
+	if err := json.Unmarshal(claims, t); err != nil {
+		return nil, fmt.Errorf("%w: unmarshalling api token: %w", ErrMalformed, err)
+	}
+	if t.ID == "" || t.Username == "" {
+		return nil, fmt.Errorf("%w: api token has no id or username", ErrMalformed)
+	}
+	if now := o.now(); t.ExpiresUnix != 0 && now.Add(-o.leeway).Unix() > t.ExpiresUnix {
+		return t, &ExpiredError{Expired: t.Expires(), Now: now}
+	}
+	if o.revocations != nil {
+		if err := checkRevoked(o.revocations, t.ID); err != nil {
+			return t, err
+		}
+	}
+	return t, nil
+}
+
+// RevokeAPIToken revokes the token in the store, so ParseAPIToken given WithRevocationStore of the store rejects it. It is revoked until it expires, or for good if it doesn't. Only the token's ID and expiration are used, so a token may be revoked by the claims NewAPIToken returned, without the token itself.
+func RevokeAPIToken(store RevocationStore, t *APIToken) error {
+	if t.ID == "" {
+		return errors.New("api token has no id")
+	}
+	until := t.Expires()
+	if until.IsZero() {
+		until = time.Now().Add(apiTokenRevocationLifetime)
+	}
+	if err := store.Revoke(t.ID, until); err != nil {
+		return fmt.Errorf("revoking api token: %w", err)
+	}
+	return nil
+}
+
+// NewAPIToken mints an API token with the active secret, like the package-level NewAPIToken.
+func (m *Manager) NewAPIToken(user string, expiration time.Time, opts ...Option) (string, *APIToken, error) {
+	return NewAPIToken(user, expiration, m.Secret(), m.options(opts)...)
+}
+
+// ParseAPIToken verifies an API token with the active secret, like the package-level ParseAPIToken.
+func (m *Manager) ParseAPIToken(token string, opts ...Option) (*APIToken, error) {
+	return ParseAPIToken(m.Secret(), token, m.options(opts)...)
+}
+
+// apiTokenTag returns the HMAC-SHA256 tag of the signed part of an API token, with a key derived from the secret by HKDF-SHA256.
+func apiTokenTag(signed, secret string) []byte {
+	mac := hmac.New(sha256.New, deriveKey([]byte(secret), nil, apiTokenInfo))
+	mac.Write([]byte(signed))
+	return mac.Sum(nil)
+}
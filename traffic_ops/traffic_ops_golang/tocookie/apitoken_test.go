@@ -0,0 +1,91 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAPIToken(t *testing.T) {
+	secret := "secret"
+	token, claims, err := NewAPIToken("ort", time.Time{}, secret, WithCapabilities("servers-read"))
+	if err != nil {
+		t.Fatalf("NewAPIToken expected nil error, actual: %v", err)
+	}
+	if !IsAPIToken(token) || !strings.HasPrefix(token, APITokenPrefix) {
+		t.Errorf("NewAPIToken expected prefix %v, actual: %v", APITokenPrefix, token)
+	}
+	parsed, err := ParseAPIToken(secret, token)
+	if err != nil {
+		t.Fatalf("ParseAPIToken expected nil error, actual: %v", err)
+	}
+	if parsed.ID != claims.ID || parsed.Username != "ort" || !parsed.HasCapability("servers-read") || parsed.HasCapability("servers-write") || !parsed.Expires().IsZero() {
+		t.Errorf("ParseAPIToken expected claims %+v, actual: %+v", claims, parsed)
+	}
+
+	expired, _, _ := NewAPIToken("ort", time.Now().Add(-time.Hour), secret)
+	cookie := New("ort", time.Now().Add(time.Hour), secret)
+	dot := strings.LastIndexByte(token, '.')
+	tests := map[string]struct {
+		token string
+		key   string
+		opts  []Option
+		err   error
+	}{
+		"wrong secret":  {token, "wrong", nil, ErrBadSignature},
+		"tampered":      {token[:dot-2] + "AA" + token[dot:], secret, nil, ErrBadSignature},
+		"cookie":        {cookie, secret, nil, ErrMalformed},
+		"no signature":  {token[:dot], secret, nil, ErrMalformed},
+		"bad signature": {token[:dot] + ".!", secret, nil, ErrMalformed},
+		"too long":      {token + strings.Repeat("A", apiTokenMaxSize), secret, nil, ErrMalformed},
+		"expired":       {expired, secret, nil, ErrExpired},
+		"leeway":        {expired, secret, []Option{WithLeeway(2 * time.Hour)}, nil},
+	}
+	for name, test := range tests {
+		if _, err := ParseAPIToken(test.key, test.token, test.opts...); !errors.Is(err, test.err) || test.err == nil && err != nil {
+			t.Errorf("%v: ParseAPIToken expected %v, actual: %v", name, test.err, err)
+		}
+	}
+
+	if _, err := Parse(secret, token); err == nil {
+		t.Errorf("Parse of api token expected error, actual nil")
+	}
+}
+
+func TestRevokeAPIToken(t *testing.T) {
+	secret := "secret"
+	store := NewMemoryRevocationStore()
+	forever, foreverClaims, _ := NewAPIToken("ort", time.Time{}, secret)
+	expiring, expiringClaims, _ := NewAPIToken("ort", time.Now().Add(time.Hour), secret)
+	other, _, _ := NewAPIToken("ort", time.Time{}, secret)
+
+	for _, claims := range []*APIToken{foreverClaims, expiringClaims} {
+		if err := RevokeAPIToken(store, claims); err != nil {
+			t.Errorf("RevokeAPIToken expected nil error, actual: %v", err)
+		}
+	}
+	for _, token := range []string{forever, expiring} {
+		if _, err := ParseAPIToken(secret, token, WithRevocationStore(store)); !errors.Is(err, ErrRevoked) {
+			t.Errorf("ParseAPIToken of revoked token expected ErrRevoked, actual: %v", err)
+		}
+	}
+	if _, err := ParseAPIToken(secret, other, WithRevocationStore(store)); err != nil {
+		t.Errorf("ParseAPIToken of unrevoked token expected nil error, actual: %v", err)
+	}
+	if err := RevokeAPIToken(store, &APIToken{}); err == nil {
+		t.Errorf("RevokeAPIToken without id expected error, actual nil")
+	}
+}
@@ -0,0 +1,179 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// The signature algorithms of asymmetrically signed cookies, as named in their version 2 headers. The names are those of JWS (RFC 7518 and RFC 8037).
+const (
+	AlgEdDSA = "EdDSA"
+	AlgRS256 = "RS256"
+)
+
+// WithSigner makes New sign cookies with the private key instead of an HMAC of the secret, so services which verify cookies with ParseWithPublicKey can't forge them. The key must be an ed25519.PrivateKey or *rsa.PrivateKey, which sign with EdDSA and RSASSA-PKCS1-v1_5 SHA-256 respectively; see LoadPrivateKeyFile. The secret given to New is ignored.
+//
+// Signed cookies are minted in Version2, whose header names the algorithm. Parse rejects them, and they can't be combined with WithEncryption or WithPerUserKeys, which need a shared secret: New returns an empty string if the key is of another type, or given either option. Perl Traffic Ops can't read them.
+func WithSigner(key crypto.Signer) Option {
+	return func(o *options) { o.signer = key }
+}
+
+// NewWithPrivateKey mints a cookie like New, signed with the private key; see WithSigner.
+func NewWithPrivateKey(user string, expiration time.Time, key crypto.Signer, opts ...Option) string {
+	return New(user, expiration, "", append(append(make([]Option, 0, len(opts)+1), opts...), WithSigner(key))...)
+}
+
+// ParseWithPublicKey parses a cookie like Parse, verified with the public key of the private key it was minted with by WithSigner, for read-only services which must not be able to mint cookies. The key must be an ed25519.PublicKey or *rsa.PublicKey; see LoadPublicKeyFile. Cookies signed with an HMAC, or with another algorithm than the key's, are rejected with ErrBadSignature.
+func ParseWithPublicKey(key crypto.PublicKey, cookie string, opts ...Option) (*Cookie, error) {
+	o := newOptions(opts)
+	o.publicKey = key
+	return o.parseWith(context.Background(), func() (*Cookie, error) { return parse("", cookie, o) })
+}
+
+// signerAlg returns the algorithm of the private key, or an empty string if it isn't supported.
+func signerAlg(key crypto.Signer) string {
+	switch key.(type) {
+	case ed25519.PrivateKey:
+		return AlgEdDSA
+	case *rsa.PrivateKey:
+		return AlgRS256
+	}
+	return ""
+}
+
+// publicKeyAlg returns the algorithm of the public key, or an empty string if it isn't supported.
+func publicKeyAlg(key crypto.PublicKey) string {
+	switch key.(type) {
+	case ed25519.PublicKey:
+		return AlgEdDSA
+	case *rsa.PublicKey:
+		return AlgRS256
+	}
+	return ""
+}
+
+// signAsymmetric signs the message with the configured private key.
+func (o *options) signAsymmetric(message []byte) ([]byte, error) {
+	switch signerAlg(o.signer) {
+	case AlgEdDSA:
+		return o.signer.Sign(nil, message, crypto.Hash(0))
+	case AlgRS256:
+		digest := sha256.Sum256(message)
+		return o.signer.Sign(nil, digest[:], crypto.SHA256)
+	}
+	return nil, fmt.Errorf("unsupported private key type %T", o.signer)
+}
+
+// verifyAsymmetric checks the signature of the cookie against the configured public key.
+func (s signedCookie) verifyAsymmetric(key crypto.PublicKey) error {
+	if alg := publicKeyAlg(key); alg == "" || s.header.Alg != alg {
+		return fmt.Errorf("%w: cookie algorithm '%s' doesn't match public key type %T", ErrBadSignature, s.header.Alg, key)
+	}
+	return verifyPublicKey(key, s.signed, s.sig)
+}
+
+// verifyPublicKey checks the signature of the message against the public key, whose algorithm the caller has checked.
+func verifyPublicKey(key crypto.PublicKey, message string, sig []byte) error {
+	switch key := key.(type) {
+	case ed25519.PublicKey:
+		if len(key) == ed25519.PublicKeySize && ed25519.Verify(key, []byte(message), sig) {
+			return nil
+		}
+	case *rsa.PublicKey:
+		digest := sha256.Sum256([]byte(message))
+		if rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig) == nil {
+			return nil
+		}
+	}
+	return ErrBadSignature
+}
+
+// ParsePrivateKeyPEM parses the first PEM block of the data as an Ed25519 or RSA private key for WithSigner: a PKCS #8 "PRIVATE KEY", or a PKCS #1 "RSA PRIVATE KEY".
+func ParsePrivateKeyPEM(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	var key interface{}
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block type '%s'", block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing private key: %w", err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok || signerAlg(signer) == "" {
+		return nil, fmt.Errorf("unsupported private key type %T", key)
+	}
+	return signer, nil
+}
+
+// ParsePublicKeyPEM parses the first PEM block of the data as an Ed25519 or RSA public key for ParseWithPublicKey: a PKIX "PUBLIC KEY", or a PKCS #1 "RSA PUBLIC KEY".
+func ParsePublicKeyPEM(data []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	var key interface{}
+	var err error
+	switch block.Type {
+	case "PUBLIC KEY":
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block type '%s'", block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing public key: %w", err)
+	}
+	if publicKeyAlg(key) == "" {
+		return nil, fmt.Errorf("unsupported public key type %T", key)
+	}
+	return key, nil
+}
+
+// LoadPrivateKeyFile reads a PEM private key from the file at path; see ParsePrivateKeyPEM.
+func LoadPrivateKeyFile(path string) (crypto.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading private key file: %w", err)
+	}
+	return ParsePrivateKeyPEM(data)
+}
+
+// LoadPublicKeyFile reads a PEM public key from the file at path; see ParsePublicKeyPEM.
+func LoadPublicKeyFile(path string) (crypto.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading public key file: %w", err)
+	}
+	return ParsePublicKeyPEM(data)
+}
@@ -0,0 +1,153 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWithSigner(t *testing.T) {
+	edPub, edPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generating Ed25519 key: %v", err)
+	}
+	rsaPriv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generating RSA key: %v", err)
+	}
+	otherPub, _, _ := ed25519.GenerateKey(rand.Reader)
+	expiration := time.Now().Add(time.Minute)
+
+	tests := map[string]struct {
+		priv crypto.Signer
+		pub  crypto.PublicKey
+		alg  string
+	}{
+		AlgEdDSA: {edPriv, edPub, AlgEdDSA},
+		AlgRS256: {rsaPriv, &rsaPriv.PublicKey, AlgRS256},
+	}
+	for name, test := range tests {
+		cookie := NewWithPrivateKey("alice", expiration, test.priv, WithAudience("monitor"))
+		if info, err := Dump(cookie); err != nil || info.Algorithm != test.alg {
+			t.Fatalf("%v: NewWithPrivateKey expected cookie signed with %v, actual: '%v' %v", name, test.alg, info.Algorithm, err)
+		}
+		c, err := ParseWithPublicKey(test.pub, cookie, WithAudience("monitor"))
+		if err != nil || c.AuthData != "alice" {
+			t.Errorf("%v: ParseWithPublicKey expected alice, actual: %+v %v", name, c, err)
+		}
+		if _, err := ParseWithPublicKey(otherPub, cookie); !errors.Is(err, ErrBadSignature) {
+			t.Errorf("%v: ParseWithPublicKey with other key expected ErrBadSignature, actual: %v", name, err)
+		}
+		if _, err := Parse("", cookie); !errors.Is(err, ErrBadSignature) {
+			t.Errorf("%v: Parse of asymmetrically signed cookie expected ErrBadSignature, actual: %v", name, err)
+		}
+		tampered := strings.Replace(cookie, "--", "--00", 1)
+		if _, err := ParseWithPublicKey(test.pub, tampered); !errors.Is(err, ErrBadSignature) {
+			t.Errorf("%v: ParseWithPublicKey of tampered cookie expected ErrBadSignature, actual: %v", name, err)
+		}
+	}
+
+	if _, err := ParseWithPublicKey(edPub, New("alice", expiration, "secret")); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("ParseWithPublicKey of HMAC cookie expected ErrBadSignature, actual: %v", err)
+	}
+	if _, err := ParseWithPublicKey(edPub, NewWithPrivateKey("alice", expiration, rsaPriv)); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("ParseWithPublicKey with key of other algorithm expected ErrBadSignature, actual: %v", err)
+	}
+	for name, opt := range map[string]Option{"encryption": WithEncryption(), "per-user keys": WithPerUserKeys()} {
+		if cookie := NewWithPrivateKey("alice", expiration, edPriv, opt); cookie != "" {
+			t.Errorf("NewWithPrivateKey with %v expected empty cookie, actual: '%v'", name, cookie)
+		}
+	}
+}
+
+func TestLoadKeyFiles(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generating Ed25519 key: %v", err)
+	}
+	privDER, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatalf("marshalling private key: %v", err)
+	}
+	pubDER, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		t.Fatalf("marshalling public key: %v", err)
+	}
+	dir := t.TempDir()
+	privPath, pubPath := filepath.Join(dir, "cookie.key"), filepath.Join(dir, "cookie.pub")
+	os.WriteFile(privPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privDER}), 0600)
+	os.WriteFile(pubPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER}), 0644)
+
+	loadedPriv, err := LoadPrivateKeyFile(privPath)
+	if err != nil {
+		t.Fatalf("LoadPrivateKeyFile expected nil error, actual: %v", err)
+	}
+	loadedPub, err := LoadPublicKeyFile(pubPath)
+	if err != nil {
+		t.Fatalf("LoadPublicKeyFile expected nil error, actual: %v", err)
+	}
+	if _, err := ParseWithPublicKey(loadedPub, NewWithPrivateKey("alice", time.Now().Add(time.Minute), loadedPriv)); err != nil {
+		t.Errorf("ParseWithPublicKey with loaded keys expected nil error, actual: %v", err)
+	}
+
+	rsaPriv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generating RSA key: %v", err)
+	}
+	if key, err := ParsePrivateKeyPEM(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaPriv)})); err != nil || signerAlg(key) != AlgRS256 {
+		t.Errorf("ParsePrivateKeyPEM of PKCS #1 key expected RSA key, actual: %T %v", key, err)
+	}
+	if key, err := ParsePublicKeyPEM(pem.EncodeToMemory(&pem.Block{Type: "RSA PUBLIC KEY", Bytes: x509.MarshalPKCS1PublicKey(&rsaPriv.PublicKey)})); err != nil || publicKeyAlg(key) != AlgRS256 {
+		t.Errorf("ParsePublicKeyPEM of PKCS #1 key expected RSA key, actual: %T %v", key, err)
+	}
+
+	invalid := map[string][]byte{
+		"not PEM":      []byte("secret"),
+		"certificate":  pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: pubDER}),
+		"corrupt":      pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: []byte("corrupt")}),
+		"public block": pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER}),
+	}
+	for name, data := range invalid {
+		if _, err := ParsePrivateKeyPEM(data); err == nil {
+			t.Errorf("%v: ParsePrivateKeyPEM expected error, actual nil", name)
+		}
+	}
+	if _, err := LoadPublicKeyFile(filepath.Join(dir, "missing")); err == nil {
+		t.Errorf("LoadPublicKeyFile of missing file expected error, actual nil")
+	}
+}
+
+func TestParseWithPublicKeyHooks(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generating Ed25519 key: %v", err)
+	}
+	errDenied := errors.New("denied")
+	deny := WithHooks(func(ctx context.Context, c *Cookie) error { return errDenied })
+	cookie := NewWithPrivateKey("alice", time.Now().Add(time.Minute), priv)
+	if _, err := ParseWithPublicKey(pub, cookie, deny); !errors.Is(err, ErrHookRejected) || !errors.Is(err, errDenied) {
+		t.Errorf("ParseWithPublicKey with a rejecting hook expected ErrHookRejected, actual: %v", err)
+	}
+}
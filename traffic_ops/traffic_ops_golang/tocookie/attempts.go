@@ -0,0 +1,25 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+// WithFailedAttempts sets the failed-attempt counter of a cookie minted by New.
+func WithFailedAttempts(n int) Option {
+	return func(o *options) { o.failedAttempts = n }
+}
+
+// IncrementFailedAttempts returns the cookie re-signed with its failed-attempt counter incremented, and all other claims, including the expiration, unchanged. The given cookie is not modified.
+func IncrementFailedAttempts(c *Cookie, key string, opts ...Option) string {
+	incremented := c.Clone()
+	incremented.FailedAttempts++
+	return encodeCookie(incremented, key, newOptions(opts))
+}
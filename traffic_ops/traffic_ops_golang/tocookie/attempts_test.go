@@ -0,0 +1,48 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIncrementFailedAttempts(t *testing.T) {
+	secret := "secret"
+	c, err := Parse(secret, New("alice", time.Now().Add(time.Minute), secret, WithFailedAttempts(1)))
+	if err != nil {
+		t.Fatalf("Parse expected nil error, actual: %v", err)
+	}
+
+	incremented, err := Parse(secret, IncrementFailedAttempts(c, secret))
+	if err != nil {
+		t.Fatalf("Parse incremented expected nil error, actual: %v", err)
+	}
+	if incremented.FailedAttempts != 2 {
+		t.Errorf("IncrementFailedAttempts expected 2, actual: %v", incremented.FailedAttempts)
+	}
+	if incremented.ExpiresUnix != c.ExpiresUnix {
+		t.Errorf("IncrementFailedAttempts expected expiry unchanged %v, actual: %v", c.ExpiresUnix, incremented.ExpiresUnix)
+	}
+	if c.FailedAttempts != 1 {
+		t.Errorf("IncrementFailedAttempts expected original unmodified, actual: %v", c.FailedAttempts)
+	}
+
+	refreshed, err := Parse(secret, Refresh(incremented, secret))
+	if err != nil {
+		t.Fatalf("Parse refreshed expected nil error, actual: %v", err)
+	}
+	if refreshed.FailedAttempts != 2 {
+		t.Errorf("Refresh expected failed attempts preserved 2, actual: %v", refreshed.FailedAttempts)
+	}
+}
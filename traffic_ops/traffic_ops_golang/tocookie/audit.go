@@ -0,0 +1,117 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// AuditEvent is the kind of event of an AuditRecord.
+type AuditEvent string
+
+const (
+	// AuditIssued is a new session's cookie minted by New, NewImpersonation, NewServerSession, or the functions built on them.
+	AuditIssued AuditEvent = "issued"
+	// AuditRefreshed is a cookie re-issued by Refresh or RefreshServerSession, including through Middleware.
+	AuditRefreshed AuditEvent = "refreshed"
+	// AuditRejected is a cookie rejected by Parse or the other functions which verify cookies. The user and session are only known if the cookie was authentic, e.g. expired.
+	AuditRejected AuditEvent = "rejected"
+	// AuditRevoked is a session revoked by RevokeSession.
+	AuditRevoked AuditEvent = "revoked"
+)
+
+// AuditRecord is an auth event reported to the AuditSink of WithAuditSink.
+type AuditRecord struct {
+	Time  time.Time  `json:"time"`
+	Event AuditEvent `json:"event"`
+	// User is the AuthData of the cookie, and Actor the user acting on their behalf, if it is an impersonation.
+	User  string `json:"user,omitempty"`
+	Actor string `json:"actor,omitempty"`
+	// SessionID is the SessionID of the cookie.
+	SessionID string `json:"sid,omitempty"`
+	// ClientIP is the address of the client of the request, given AuditRequest, or by Middleware.
+	ClientIP string `json:"client_ip,omitempty"`
+	// Reason is the FailureReason of a rejected cookie, or the reason given RevokeSession.
+	Reason string `json:"reason,omitempty"`
+}
+
+// AuditSink receives an audit trail of auth events, given WithAuditSink. Sinks are called synchronously, so they must be fast, and safe for concurrent use, and they report their own failures, as the operations they audit can't. Sinks writing JSON lines and to syslog are in tocookie/auditlog.
+type AuditSink interface {
+	Audit(record AuditRecord)
+}
+
+// WithAuditSink makes New, Refresh, Parse, RevokeSession, and the functions built on them report the cookies they issue, refresh, reject, and revoke to the sink. Cookies which are accepted aren't audited, as every request would be. By default, nothing is audited.
+func WithAuditSink(sink AuditSink) Option {
+	return func(o *options) { o.auditSink = sink }
+}
+
+// AuditRequest returns an option recording the client of the request in the AuditRecords of the cookie operations it is given to, e.g. minting the cookies of login requests. The client's address is that of the ClientIP of the WithClientBinding of the options, if it has one, e.g. from a trusted proxy's X-Forwarded-For header, and otherwise the host of the request's RemoteAddr. Middleware records its requests' clients itself.
+func AuditRequest(r *http.Request, opts ...Option) Option {
+	var clientIP func(r *http.Request) string
+	if o := newOptions(opts); o.binding != nil {
+		clientIP = o.binding.ClientIP
+	}
+	ip := requestIP(r, clientIP)
+	return func(o *options) { o.auditClientIP = ip }
+}
+
+// RevokeSession revokes the session of the cookie in the store until the given time, as RevocationStore.Revoke does, reporting it to the AuditSink of the options with the reason, e.g. "logout". Cookies without a SessionID can't be revoked, and are ignored.
+func RevokeSession(store RevocationStore, c *Cookie, until time.Time, reason string, opts ...Option) error {
+	if c.SessionID == "" {
+		return nil
+	}
+	if err := store.Revoke(c.SessionID, until); err != nil {
+		return fmt.Errorf("revoking session: %w", err)
+	}
+	o := newOptions(opts)
+	if o.auditSink != nil {
+		record := o.auditRecord(AuditRevoked, c)
+		record.Reason = reason
+		o.auditSink.Audit(record)
+	}
+	return nil
+}
+
+// issued reports a cookie minted with the claims to the metrics and the audit sink.
+func (o *options) issued(c *Cookie) {
+	if o.metrics != nil {
+		o.metrics.Issued()
+	}
+	if o.auditSink != nil {
+		o.auditSink.Audit(o.auditRecord(AuditIssued, c))
+	}
+}
+
+// refreshed reports a cookie re-issued with the claims to the metrics and the audit sink.
+func (o *options) refreshed(c *Cookie) {
+	if o.metrics != nil {
+		o.metrics.Refreshed()
+	}
+	if o.auditSink != nil {
+		o.auditSink.Audit(o.auditRecord(AuditRefreshed, c))
+	}
+}
+
+// auditRecord returns the record of the event of the cookie, which may be nil.
+func (o *options) auditRecord(event AuditEvent, c *Cookie) AuditRecord {
+	record := AuditRecord{Time: o.now(), Event: event, ClientIP: o.auditClientIP}
+	if c != nil {
+		record.User, record.SessionID = c.AuthData, c.SessionID
+		if c.Actor != nil {
+			record.Actor = c.Actor.Subject
+		}
+	}
+	return record
+}
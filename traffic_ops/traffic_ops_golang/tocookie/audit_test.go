@@ -0,0 +1,160 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingSink is an AuditSink which keeps its records.
+type recordingSink struct {
+	mu      sync.Mutex
+	records []AuditRecord
+}
+
+func (s *recordingSink) Audit(record AuditRecord) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records = append(s.records, record)
+}
+
+// take returns the records, and forgets them.
+func (s *recordingSink) take() []AuditRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	records := s.records
+	s.records = nil
+	return records
+}
+
+func TestWithAuditSink(t *testing.T) {
+	secret := "secret"
+	now := time.Date(2018, 7, 1, 12, 0, 0, 0, time.UTC)
+	sink := &recordingSink{}
+	opts := []Option{WithAuditSink(sink), WithClock(func() time.Time { return now })}
+
+	cookie := New("alice", now.Add(time.Hour), secret, opts...)
+	c, err := Parse(secret, cookie, opts...)
+	if err != nil {
+		t.Fatalf("Parse expected nil error, actual: %v", err)
+	}
+	if records := sink.take(); len(records) != 1 || records[0] != (AuditRecord{Time: now, Event: AuditIssued, User: "alice", SessionID: c.SessionID}) {
+		t.Errorf("New and Parse expected only issued record, actual: %+v", records)
+	}
+	Refresh(c, secret, opts...)
+	if records := sink.take(); len(records) != 1 || records[0].Event != AuditRefreshed || records[0].SessionID != c.SessionID {
+		t.Errorf("Refresh expected refreshed record, actual: %+v", records)
+	}
+
+	tests := map[string]struct {
+		cookie string
+		user   string
+		reason string
+	}{
+		"forged":    {New("alice", now.Add(time.Hour), "wrong"), "", "bad_signature"},
+		"malformed": {"not a cookie", "", "malformed"},
+		"expired":   {New("alice", now.Add(-time.Hour), secret), "alice", "expired"},
+	}
+	for name, test := range tests {
+		sink.take()
+		Parse(secret, test.cookie, opts...)
+		records := sink.take()
+		if len(records) != 1 || records[0].Event != AuditRejected || records[0].User != test.user || records[0].Reason != test.reason {
+			t.Errorf("%v: Parse expected rejected record of user '%v' for %v, actual: %+v", name, test.user, test.reason, records)
+		}
+	}
+
+	store := NewMemoryRevocationStore()
+	if err := RevokeSession(store, c, time.Now().Add(time.Hour), "logout", opts...); err != nil {
+		t.Fatalf("RevokeSession expected nil error, actual: %v", err)
+	}
+	if records := sink.take(); len(records) != 1 || records[0] != (AuditRecord{Time: now, Event: AuditRevoked, User: "alice", SessionID: c.SessionID, Reason: "logout"}) {
+		t.Errorf("RevokeSession expected revoked record, actual: %+v", records)
+	}
+	if revoked, _ := store.IsRevoked(c.SessionID); !revoked {
+		t.Errorf("RevokeSession expected session revoked, actual: not revoked")
+	}
+	if _, err := Parse(secret, cookie, append(opts, WithRevocationStore(store))...); !errors.Is(err, ErrRevoked) {
+		t.Errorf("Parse of revoked session expected ErrRevoked, actual: %v", err)
+	}
+	if records := sink.take(); len(records) != 1 || records[0].Reason != "revoked" || records[0].User != "" {
+		t.Errorf("Parse of revoked session expected rejected record without claims, actual: %+v", records)
+	}
+	if err := RevokeSession(store, &Cookie{AuthData: "bob"}, now.Add(time.Hour), "logout", opts...); err != nil || len(sink.take()) != 0 {
+		t.Errorf("RevokeSession without SessionID expected no revocation, actual: %v", err)
+	}
+
+	impersonation := NewImpersonation(c, "bob", now.Add(time.Minute), secret, opts...)
+	if records := sink.take(); impersonation == "" || len(records) != 1 || records[0].User != "bob" || records[0].Actor != "alice" {
+		t.Errorf("NewImpersonation expected issued record of bob acted by alice, actual: %+v", records)
+	}
+}
+
+func TestAuditRequest(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/login", nil)
+	r.RemoteAddr = "192.0.2.1:1234"
+	r.Header.Set("X-Forwarded-For", "198.51.100.7")
+	binding := WithClientBinding(ClientBinding{IP: true, ClientIP: func(r *http.Request) string { return r.Header.Get("X-Forwarded-For") }})
+	tests := map[string]struct {
+		opts     []Option
+		expected string
+	}{
+		"remote addr":    {nil, "192.0.2.1"},
+		"client binding": {[]Option{binding}, "198.51.100.7"},
+	}
+	for name, test := range tests {
+		sink := &recordingSink{}
+		New("alice", time.Now().Add(time.Hour), "secret", WithAuditSink(sink), AuditRequest(r, test.opts...))
+		if records := sink.take(); len(records) != 1 || records[0].ClientIP != test.expected {
+			t.Errorf("%v: AuditRequest expected client IP %v, actual: %+v", name, test.expected, records)
+		}
+	}
+}
+
+func TestMiddlewareAudit(t *testing.T) {
+	secret := "secret"
+	sink := &recordingSink{}
+	handler := Middleware(secret, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), WithAuditSink(sink), WithRefreshWindow(DefaultRefreshWindow))
+	tests := map[string]struct {
+		cookie   string
+		expected []AuditEvent
+	}{
+		"valid":   {New("alice", time.Now().Add(time.Hour), secret), nil},
+		"refresh": {New("alice", time.Now().Add(time.Minute), secret), []AuditEvent{AuditRefreshed}},
+		"forged":  {New("alice", time.Now().Add(time.Hour), "wrong"), []AuditEvent{AuditRejected}},
+		"none":    {"", nil},
+	}
+	for name, test := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = "192.0.2.1:1234"
+		if test.cookie != "" {
+			r.AddCookie(&http.Cookie{Name: Name, Value: test.cookie})
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+		records := sink.take()
+		if len(records) != len(test.expected) {
+			t.Errorf("%v: Middleware expected records %v, actual: %+v", name, test.expected, records)
+			continue
+		}
+		for i, record := range records {
+			if record.Event != test.expected[i] || record.ClientIP != "192.0.2.1" {
+				t.Errorf("%v: Middleware expected %v record of 192.0.2.1, actual: %+v", name, test.expected[i], record)
+			}
+		}
+	}
+}
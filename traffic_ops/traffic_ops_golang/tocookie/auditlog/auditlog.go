@@ -0,0 +1,89 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package auditlog provides tocookie.AuditSinks which keep the audit trail of tocookie auth events, as JSON lines in a file, or in syslog, where security teams collect the audit trail of Perl Traffic Ops:
+//
+//	sink, err := auditlog.OpenFile("/var/log/traffic_ops/auth.log")
+//	handlers := login.New(authenticator, secret, tocookie.WithAuditSink(sink))
+//
+// Each record is a JSON object of the fields of tocookie.AuditRecord, e.g.
+//
+//	{"time":"2018-07-01T12:00:00Z","event":"rejected","user":"alice","sid":"3f2a...","client_ip":"192.0.2.1","reason":"revoked"}
+package auditlog
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/tocookie"
+)
+
+// FileMode is the mode OpenFile creates audit logs with, as they reveal who logs in from where.
+const FileMode = 0600
+
+// Writer is a tocookie.AuditSink which writes each record as a line of JSON. It is safe for concurrent use, and lines are never interleaved.
+type Writer struct {
+	// Logger, if not nil, receives the errors of writing records, which the audited operations can't return.
+	Logger tocookie.Logger
+
+	mu  sync.Mutex
+	w   io.Writer
+	buf []byte
+}
+
+// NewWriter returns a Writer writing to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+// OpenFile returns a Writer appending to the file at the path, which is created with FileMode if it doesn't exist. Close closes the file.
+func OpenFile(path string) (*Writer, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, FileMode)
+	if err != nil {
+		return nil, fmt.Errorf("opening audit log: %w", err)
+	}
+	return NewWriter(f), nil
+}
+
+// Audit implements tocookie.AuditSink. Each line is written with a single write, so lines appended to a file by several processes aren't interleaved either.
+func (w *Writer) Audit(record tocookie.AuditRecord) {
+	line, err := json.Marshal(record)
+	if err != nil {
+		w.warnf("encoding audit record: %v", err)
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buf = append(append(w.buf[:0], line...), '\n')
+	if _, err := w.w.Write(w.buf); err != nil {
+		w.warnf("writing audit record: %v", err)
+	}
+}
+
+// Close closes the underlying writer, if it is an io.Closer.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if c, ok := w.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+func (w *Writer) warnf(format string, v ...interface{}) {
+	if w.Logger != nil {
+		w.Logger.Warnf(format, v...)
+	}
+}
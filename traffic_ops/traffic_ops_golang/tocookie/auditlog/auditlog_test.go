@@ -0,0 +1,112 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auditlog
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/tocookie"
+)
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) { return 0, errors.New("disk full") }
+
+type recordingLogger struct {
+	warnings []string
+}
+
+func (l *recordingLogger) Infof(format string, v ...interface{}) {}
+func (l *recordingLogger) Warnf(format string, v ...interface{}) {
+	l.warnings = append(l.warnings, fmt.Sprintf(format, v...))
+}
+
+func TestWriter(t *testing.T) {
+	buf := &bytes.Buffer{}
+	sink := NewWriter(buf)
+	now := time.Date(2018, 7, 1, 12, 0, 0, 0, time.UTC)
+	tocookie.New("alice", now.Add(time.Hour), "secret", tocookie.WithAuditSink(sink), tocookie.WithClock(func() time.Time { return now }))
+	record := map[string]interface{}{}
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("Audit expected a JSON line, actual: '%s' %v", buf.String(), err)
+	}
+	if record["event"] != "issued" || record["user"] != "alice" || record["time"] != "2018-07-01T12:00:00Z" || !strings.HasSuffix(buf.String(), "}\n") {
+		t.Errorf("Audit expected issued record of alice, actual: '%s'", buf.String())
+	}
+	if _, ok := record["client_ip"]; ok {
+		t.Errorf("Audit expected no client_ip without AuditRequest, actual: '%s'", buf.String())
+	}
+
+	logger := &recordingLogger{}
+	failing := NewWriter(failingWriter{})
+	failing.Logger = logger
+	failing.Audit(tocookie.AuditRecord{Event: tocookie.AuditIssued})
+	if len(logger.warnings) != 1 || !strings.Contains(logger.warnings[0], "disk full") {
+		t.Errorf("Audit of failing writer expected a warning, actual: %v", logger.warnings)
+	}
+}
+
+func TestOpenFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "auth.log")
+	for i := 0; i < 2; i++ {
+		sink, err := OpenFile(path)
+		if err != nil {
+			t.Fatalf("OpenFile expected nil error, actual: %v", err)
+		}
+		wg := sync.WaitGroup{}
+		for j := 0; j < 50; j++ {
+			wg.Add(1)
+			go func(j int) {
+				defer wg.Done()
+				sink.Audit(tocookie.AuditRecord{Event: tocookie.AuditRejected, User: fmt.Sprintf("user%d", j), Reason: strings.Repeat("x", 1000)})
+			}(j)
+		}
+		wg.Wait()
+		if err := sink.Close(); err != nil {
+			t.Errorf("Close expected nil error, actual: %v", err)
+		}
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("opening audit log: %v", err)
+	}
+	defer f.Close()
+	if info, err := f.Stat(); err != nil || info.Mode().Perm() != FileMode {
+		t.Errorf("OpenFile expected mode %v, actual: %v %v", os.FileMode(FileMode), info.Mode().Perm(), err)
+	}
+	lines := 0
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		record := tocookie.AuditRecord{}
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil || record.Event != tocookie.AuditRejected {
+			t.Errorf("OpenFile expected lines of rejected records, actual: '%s' %v", scanner.Text(), err)
+		}
+		lines++
+	}
+	if lines != 100 {
+		t.Errorf("OpenFile expected records appended, 100 lines, actual: %v", lines)
+	}
+	if _, err := OpenFile(filepath.Join(t.TempDir(), "missing", "auth.log")); err == nil {
+		t.Errorf("OpenFile in missing directory expected error, actual nil")
+	}
+}
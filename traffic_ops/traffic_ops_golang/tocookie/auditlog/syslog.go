@@ -0,0 +1,71 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auditlog
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/syslog"
+
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/tocookie"
+)
+
+// DefaultTag is the syslog tag of NewSyslog, if none is given.
+const DefaultTag = "traffic_ops"
+
+// Syslog is a tocookie.AuditSink which sends each record to syslog as a JSON message, of the LOG_AUTH facility. Rejected cookies are of LOG_WARNING severity, and other events of LOG_INFO. It is safe for concurrent use.
+type Syslog struct {
+	// Logger, if not nil, receives the errors of sending records, which the audited operations can't return.
+	Logger tocookie.Logger
+
+	w *syslog.Writer
+}
+
+// NewSyslog returns a Syslog sending to the syslog server at the address of the network, e.g. "udp" and "loghost:514", or to the local syslog server if the network is empty. The tag is DefaultTag if it is empty.
+func NewSyslog(network, addr, tag string) (*Syslog, error) {
+	if tag == "" {
+		tag = DefaultTag
+	}
+	w, err := syslog.Dial(network, addr, syslog.LOG_AUTH|syslog.LOG_INFO, tag)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to syslog: %w", err)
+	}
+	return &Syslog{w: w}, nil
+}
+
+// Audit implements tocookie.AuditSink.
+func (s *Syslog) Audit(record tocookie.AuditRecord) {
+	msg, err := json.Marshal(record)
+	if err != nil {
+		s.warnf("encoding audit record: %v", err)
+		return
+	}
+	send := s.w.Info
+	if record.Event == tocookie.AuditRejected {
+		send = s.w.Warning
+	}
+	if err := send(string(msg)); err != nil {
+		s.warnf("sending audit record to syslog: %v", err)
+	}
+}
+
+// Close closes the connection to syslog.
+func (s *Syslog) Close() error {
+	return s.w.Close()
+}
+
+func (s *Syslog) warnf(format string, v ...interface{}) {
+	if s.Logger != nil {
+		s.Logger.Warnf(format, v...)
+	}
+}
@@ -0,0 +1,54 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auditlog
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/tocookie"
+)
+
+func TestSyslog(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listening: %v", err)
+	}
+	defer conn.Close()
+	sink, err := NewSyslog("udp", conn.LocalAddr().String(), "")
+	if err != nil {
+		t.Fatalf("NewSyslog expected nil error, actual: %v", err)
+	}
+	defer sink.Close()
+
+	tests := map[tocookie.AuditEvent]string{
+		// LOG_AUTH is facility 4, so the priorities of LOG_INFO and LOG_WARNING are 4*8+6 and 4*8+4.
+		tocookie.AuditIssued:   "<38>",
+		tocookie.AuditRejected: "<36>",
+	}
+	buf := make([]byte, 4096)
+	for event, priority := range tests {
+		sink.Audit(tocookie.AuditRecord{Event: event, User: "alice", ClientIP: "192.0.2.1"})
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("reading syslog message: %v", err)
+		}
+		msg := string(buf[:n])
+		if !strings.HasPrefix(msg, priority) || !strings.Contains(msg, DefaultTag) || !strings.Contains(msg, `"event":"`+string(event)+`"`) || !strings.Contains(msg, `"client_ip":"192.0.2.1"`) {
+			t.Errorf("Audit of %v expected message of priority %v, actual: '%v'", event, priority, msg)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// The authentication methods of WithAuthMethods, recorded in the "amr" claim of the cookie. They are the values of RFC 8176 where it has one.
+const (
+	// AuthMethodPassword is a password checked by the service, e.g. against the Traffic Ops database.
+	AuthMethodPassword = "pwd"
+	// AuthMethodLDAP is a password checked by an LDAP bind.
+	AuthMethodLDAP = "ldap"
+	// AuthMethodOIDC is an ID token of an OpenID Connect provider.
+	AuthMethodOIDC = "oidc"
+	// AuthMethodMTLS is a client certificate of mutual TLS.
+	AuthMethodMTLS = "mtls"
+	// AuthMethodTOTP is a time-based one-time password, as RFC 8176's "otp".
+	AuthMethodTOTP = "otp"
+)
+
+// AuthLevel is the assurance level of an authentication, recorded in the "acr" claim of the cookie as its decimal string, as OpenID Connect's acr values "0", "1", etc. Higher levels are stronger; see RequireAuthLevel.
+type AuthLevel int
+
+const (
+	// AuthLevelNone is the level of cookies minted without WithAuthMethods or WithAuthLevel, including those minted before the claims were introduced, and by Perl Traffic Ops.
+	AuthLevelNone AuthLevel = iota
+	// AuthLevelSingleFactor is a single factor, such as a password, an LDAP bind, an OpenID Connect login, or a client certificate.
+	AuthLevelSingleFactor
+	// AuthLevelMultiFactor is factors of at least two kinds, something the user knows, such as a password, and something they have, such as a TOTP device or a client certificate.
+	AuthLevelMultiFactor
+)
+
+// authFactors are the kinds of factor of the authentication methods, for AuthLevelOf. Methods not in it, such as AuthMethodOIDC, are single factors of their own kind, as the factors behind them are unknown.
+var authFactors = map[string]string{
+	AuthMethodPassword: "knowledge",
+	AuthMethodLDAP:     "knowledge",
+	AuthMethodMTLS:     "possession",
+	AuthMethodTOTP:     "possession",
+}
+
+// AuthLevelOf returns the level of an authentication by the methods: AuthLevelMultiFactor for methods of at least two kinds of factor, AuthLevelSingleFactor for methods of one, and AuthLevelNone for none.
+func AuthLevelOf(methods ...string) AuthLevel {
+	factors := map[string]struct{}{}
+	for _, method := range methods {
+		factor, ok := authFactors[method]
+		if !ok {
+			factor = method
+		}
+		factors[factor] = struct{}{}
+	}
+	switch {
+	case len(factors) >= 2:
+		return AuthLevelMultiFactor
+	case len(factors) == 1:
+		return AuthLevelSingleFactor
+	}
+	return AuthLevelNone
+}
+
+// WithAuthMethods sets the methods the user authenticated with, such as AuthMethodPassword, of a cookie minted by New, and its AuthLevel, which is AuthLevelOf the methods unless WithAuthLevel is given, e.g. of an OpenID Connect provider which reports the level itself.
+func WithAuthMethods(methods ...string) Option {
+	copied := append([]string(nil), methods...)
+	return func(o *options) { o.authMethods = copied }
+}
+
+// WithAuthLevel sets the AuthLevel of a cookie minted by New, overriding that of WithAuthMethods.
+func WithAuthLevel(level AuthLevel) Option {
+	return func(o *options) { o.authLevel = level }
+}
+
+// setAuthClaims sets the authentication claims of a new session of the options.
+func (o *options) setAuthClaims(c *Cookie) {
+	c.AuthMethods = o.authMethods
+	level := o.authLevel
+	if level == AuthLevelNone {
+		level = AuthLevelOf(o.authMethods...)
+	}
+	c.setAuthLevel(level)
+}
+
+func (c *Cookie) setAuthLevel(level AuthLevel) {
+	c.ACR = ""
+	if level != AuthLevelNone {
+		c.ACR = strconv.Itoa(int(level))
+	}
+}
+
+// AuthLevel returns the level of the cookie's ACR, or AuthLevelNone if it has none, or one which isn't a level.
+func (c *Cookie) AuthLevel() AuthLevel {
+	level, err := strconv.Atoi(c.ACR)
+	if err != nil || level < 0 {
+		return AuthLevelNone
+	}
+	return AuthLevel(level)
+}
+
+// HasAuthMethod returns whether the user authenticated with the method, at login or by StepUp.
+func (c *Cookie) HasAuthMethod(method string) bool {
+	for _, m := range c.AuthMethods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// AuthLevelAge returns how long ago the cookie's AuthLevel was reached, relative to now: since StepUpTime, or the authentication of AuthAge if the session hasn't stepped up.
+func (c *Cookie) AuthLevelAge(now time.Time) time.Duration {
+	if c.StepUpTime != 0 {
+		return now.Sub(time.Unix(c.StepUpTime, 0))
+	}
+	return c.AuthAge(now)
+}
+
+// MeetsAuthLevel returns whether the cookie's AuthLevel is at least the level, and, if maxAge isn't zero, was reached no longer than maxAge before now.
+func (c *Cookie) MeetsAuthLevel(level AuthLevel, maxAge time.Duration, now time.Time) bool {
+	if c.AuthLevel() < level {
+		return false
+	}
+	return maxAge <= 0 || c.AuthLevelAge(now) <= maxAge
+}
+
+// StepUp returns the cookie refreshed, as Refresh does, after the user authenticated again by the method, e.g. entered a TOTP code or reentered their password for a sensitive operation: the method is added to its AuthMethods, its AuthLevel is raised to that of all its methods, if that is higher, and its StepUpTime is set to now, so RequireAuthLevel measures from the step-up. The cookie must have been verified, e.g. by Parse, and the method checked by the caller.
+func StepUp(c *Cookie, key, method string, opts ...Option) string {
+	o := newOptions(opts)
+	stepped := c.Clone()
+	if !stepped.HasAuthMethod(method) {
+		stepped.AuthMethods = append(stepped.AuthMethods, method)
+	}
+	if level := AuthLevelOf(stepped.AuthMethods...); level > stepped.AuthLevel() {
+		stepped.setAuthLevel(level)
+	}
+	stepped.StepUpTime = o.now().Unix()
+	cookie, _ := refresh(stepped, key, o)
+	return cookie
+}
+
+// StepUp refreshes the cookie after a step-up with the active secret, like the package-level StepUp.
+func (m *Manager) StepUp(c *Cookie, method string, opts ...Option) string {
+	return StepUp(c, m.Secret(), method, m.options(opts)...)
+}
+
+// RequireAuthLevel returns a handler which only passes requests authenticated by Middleware, whose cookie MeetsAuthLevel of the level and maxAge, to next, e.g. to demand a recent multi-factor authentication for user or key management, rather than that of a session which logged in with a password hours ago. Requests without an authenticated cookie are answered with 401 Unauthorized, as by RequireCapability, and those whose authentication is too weak or too old with 401 Unauthorized and the insufficient_user_authentication challenge of RFC 9470, naming the level as acr_values and the age as max_age, so clients know to step up; see StepUp.
+func RequireAuthLevel(next http.Handler, level AuthLevel, maxAge time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, ok := FromContext(r.Context())
+		if !ok {
+			SetChallenge(w, DefaultRealm, nil)
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		if !c.MeetsAuthLevel(level, maxAge, time.Now()) {
+			w.Header().Set("WWW-Authenticate", StepUpChallenge(DefaultRealm, level, maxAge))
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// StepUpChallenge returns the WWW-Authenticate challenge of RFC 9470 for a request whose authentication is weaker than the level, or older than maxAge, if it isn't zero.
+func StepUpChallenge(realm string, level AuthLevel, maxAge time.Duration) string {
+	if realm == "" {
+		realm = DefaultRealm
+	}
+	challenge := `Bearer realm="` + quoteEscaper.Replace(realm) + `", error="insufficient_user_authentication", error_description="a stronger or more recent authentication is required", acr_values="` + strconv.Itoa(int(level)) + `"`
+	if maxAge > 0 {
+		challenge += `, max_age="` + strconv.FormatInt(int64(maxAge/time.Second), 10) + `"`
+	}
+	return challenge
+}
@@ -0,0 +1,129 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAuthLevelOf(t *testing.T) {
+	tests := map[string]struct {
+		methods  []string
+		expected AuthLevel
+	}{
+		"none":              {nil, AuthLevelNone},
+		"password":          {[]string{AuthMethodPassword}, AuthLevelSingleFactor},
+		"password and ldap": {[]string{AuthMethodPassword, AuthMethodLDAP}, AuthLevelSingleFactor},
+		"oidc":              {[]string{AuthMethodOIDC}, AuthLevelSingleFactor},
+		"password and totp": {[]string{AuthMethodPassword, AuthMethodTOTP}, AuthLevelMultiFactor},
+		"ldap and mtls":     {[]string{AuthMethodLDAP, AuthMethodMTLS}, AuthLevelMultiFactor},
+		"oidc and totp":     {[]string{AuthMethodOIDC, AuthMethodTOTP}, AuthLevelMultiFactor},
+	}
+	for name, test := range tests {
+		if level := AuthLevelOf(test.methods...); level != test.expected {
+			t.Errorf("%v: AuthLevelOf expected %v, actual: %v", name, test.expected, level)
+		}
+	}
+}
+
+func TestAuthMethods(t *testing.T) {
+	secret := "secret"
+	c, err := Parse(secret, New("alice", time.Now().Add(time.Hour), secret, WithAuthMethods(AuthMethodLDAP)))
+	if err != nil || !c.HasAuthMethod(AuthMethodLDAP) || c.ACR != "1" || c.AuthLevel() != AuthLevelSingleFactor {
+		t.Fatalf("Parse WithAuthMethods expected ldap at level 1, actual: %+v %v", c, err)
+	}
+	if refreshed, err := Parse(secret, Refresh(c, secret)); err != nil || !refreshed.HasAuthMethod(AuthMethodLDAP) || refreshed.AuthLevel() != AuthLevelSingleFactor {
+		t.Errorf("Refresh expected authentication claims preserved, actual: %+v %v", refreshed, err)
+	}
+	if c, err := Parse(secret, New("alice", time.Now().Add(time.Hour), secret, WithAuthMethods(AuthMethodOIDC), WithAuthLevel(AuthLevelMultiFactor))); err != nil || c.AuthLevel() != AuthLevelMultiFactor {
+		t.Errorf("Parse WithAuthLevel expected level 2, actual: %+v %v", c, err)
+	}
+	if c, err := Parse(secret, New("alice", time.Now().Add(time.Hour), secret)); err != nil || c.ACR != "" || c.AuthMethods != nil || c.AuthLevel() != AuthLevelNone {
+		t.Errorf("Parse without WithAuthMethods expected no authentication claims, actual: %+v %v", c, err)
+	}
+	if level := (&Cookie{ACR: "urn:mace:incommon:iap:silver"}).AuthLevel(); level != AuthLevelNone {
+		t.Errorf("AuthLevel of non-numeric ACR expected AuthLevelNone, actual: %v", level)
+	}
+}
+
+func TestStepUp(t *testing.T) {
+	secret := "secret"
+	now := time.Now()
+	login := now.Add(-2 * time.Hour)
+	c, _ := Parse(secret, New("alice", now.Add(time.Hour), secret, WithAuthMethods(AuthMethodPassword), WithClock(func() time.Time { return login })), WithLeeway(3*time.Hour))
+	if c.MeetsAuthLevel(AuthLevelMultiFactor, 0, now) {
+		t.Errorf("MeetsAuthLevel of password expected false for multi factor, actual: true")
+	}
+	if !c.MeetsAuthLevel(AuthLevelSingleFactor, 0, now) || c.MeetsAuthLevel(AuthLevelSingleFactor, time.Hour, now) {
+		t.Errorf("MeetsAuthLevel of login 2 hours ago expected single factor without max age only, actual: %v", c.AuthLevelAge(now))
+	}
+
+	stepped, err := Parse(secret, StepUp(c, secret, AuthMethodTOTP))
+	if err != nil {
+		t.Fatalf("StepUp expected a valid cookie, actual: %v", err)
+	}
+	if !stepped.HasAuthMethod(AuthMethodPassword) || !stepped.HasAuthMethod(AuthMethodTOTP) || stepped.AuthLevel() != AuthLevelMultiFactor {
+		t.Errorf("StepUp with totp expected password and totp at level 2, actual: %v %v", stepped.AuthMethods, stepped.ACR)
+	}
+	if !stepped.MeetsAuthLevel(AuthLevelMultiFactor, time.Minute, now) || stepped.AuthAge(now) < 2*time.Hour {
+		t.Errorf("StepUp expected a recent multi factor level, and the login's AuthAge, actual: %v %v", stepped.AuthLevelAge(now), stepped.AuthAge(now))
+	}
+	if stepped.SessionID != c.SessionID || stepped.SessionStart != c.SessionStart {
+		t.Errorf("StepUp expected the same session, actual: %v %v", stepped.SessionID, stepped.SessionStart)
+	}
+	if c.HasAuthMethod(AuthMethodTOTP) {
+		t.Errorf("StepUp expected the cookie unmodified, actual: %v", c.AuthMethods)
+	}
+
+	again, _ := Parse(secret, StepUp(c, secret, AuthMethodPassword))
+	if len(again.AuthMethods) != 1 || again.AuthLevel() != AuthLevelSingleFactor || !again.MeetsAuthLevel(AuthLevelSingleFactor, time.Minute, now) {
+		t.Errorf("StepUp with the same method expected a recent single factor level, actual: %v %v %v", again.AuthMethods, again.ACR, again.AuthLevelAge(now))
+	}
+}
+
+func TestRequireAuthLevel(t *testing.T) {
+	secret := "secret"
+	expiration := time.Now().Add(time.Hour)
+	password, _ := Parse(secret, New("alice", expiration, secret, WithAuthMethods(AuthMethodPassword)))
+	handler := Middleware(secret, RequireAuthLevel(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), AuthLevelMultiFactor, 5*time.Minute))
+
+	tests := map[string]struct {
+		cookie    string
+		code      int
+		challenge string
+	}{
+		"multi factor": {StepUp(password, secret, AuthMethodTOTP), http.StatusOK, ""},
+		"password":     {New("alice", expiration, secret, WithAuthMethods(AuthMethodPassword)), http.StatusUnauthorized, `error="insufficient_user_authentication"`},
+		"no level":     {New("alice", expiration, secret), http.StatusUnauthorized, `acr_values="2", max_age="300"`},
+		"stale":        {New("alice", expiration, secret, WithAuthMethods(AuthMethodPassword, AuthMethodMTLS), WithClock(func() time.Time { return time.Now().Add(-time.Hour) })), http.StatusUnauthorized, "insufficient_user_authentication"},
+	}
+	for name, test := range tests {
+		r := httptest.NewRequest(http.MethodDelete, "/api/4.0/users/2", nil)
+		r.AddCookie(&http.Cookie{Name: Name, Value: test.cookie})
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != test.code || !strings.Contains(w.Header().Get("WWW-Authenticate"), test.challenge) {
+			t.Errorf("%v: RequireAuthLevel expected %v with challenge '%v', actual: %v '%v'", name, test.code, test.challenge, w.Code, w.Header().Get("WWW-Authenticate"))
+		}
+	}
+
+	w := httptest.NewRecorder()
+	RequireAuthLevel(http.NotFoundHandler(), AuthLevelSingleFactor, 0).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusUnauthorized || strings.Contains(w.Header().Get("WWW-Authenticate"), "insufficient_user_authentication") {
+		t.Errorf("RequireAuthLevel without a cookie expected %v without step-up challenge, actual: %v '%v'", http.StatusUnauthorized, w.Code, w.Header().Get("WWW-Authenticate"))
+	}
+}
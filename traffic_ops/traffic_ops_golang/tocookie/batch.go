@@ -0,0 +1,83 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// BatchResult is the result of parsing one cookie of a batch: the cookie and error Parse would have returned for it.
+type BatchResult struct {
+	Cookie *Cookie
+	Err    error
+}
+
+// batchChunk is the number of cookies of a batch a worker takes at a time. Workers take chunks from a shared counter, so a worker slowed by large or malformed cookies doesn't hold up the batch.
+const batchChunk = 32
+
+// minBatchPerWorker is the fewest cookies of a batch worth another goroutine; smaller batches are parsed by fewer workers, and batches smaller than twice this are parsed by the calling goroutine.
+const minBatchPerWorker = 64
+
+// ParseBatch parses the cookies, exactly as Parse does with the secret and options, and returns their results in the same order. See Parser.ParseBatch, which services parsing batches continually should use instead, as it keeps its HMACs and buffers between batches.
+func ParseBatch(secret string, cookies []string, opts ...Option) []BatchResult {
+	return NewParser(secret, opts...).ParseBatch(cookies)
+}
+
+// ParseBatch parses the cookies, exactly as Parse does each of them, and returns their results in the same order, for services such as Traffic Router which verify thousands of cookies at once. Large batches are parsed in parallel across GOMAXPROCS goroutines, which share the Parser's HMACs and buffers, so the cost per cookie is that of Parser.Parse. Observers and metrics are called for every cookie, concurrently.
+func (p *Parser) ParseBatch(cookies []string) []BatchResult {
+	return p.ParseBatchContext(context.Background(), cookies)
+}
+
+// ParseBatchContext is ParseBatch, running the hooks of WithHooks with the context. Cookies which haven't been parsed when the context is done fail with its error.
+func (p *Parser) ParseBatchContext(ctx context.Context, cookies []string) []BatchResult {
+	results := make([]BatchResult, len(cookies))
+	workers := runtime.GOMAXPROCS(0)
+	if max := len(cookies) / minBatchPerWorker; max < workers {
+		workers = max
+	}
+	if workers <= 1 {
+		p.parseBatch(ctx, cookies, results)
+		return results
+	}
+	next := int64(0)
+	wg := sync.WaitGroup{}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				end := int(atomic.AddInt64(&next, batchChunk))
+				start := end - batchChunk
+				if start >= len(cookies) {
+					return
+				}
+				if end > len(cookies) {
+					end = len(cookies)
+				}
+				p.parseBatch(ctx, cookies[start:end], results[start:end])
+			}
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// parseBatch parses the cookies into the results of the same indexes.
+func (p *Parser) parseBatch(ctx context.Context, cookies []string, results []BatchResult) {
+	for i, cookie := range cookies {
+		results[i].Cookie, results[i].Err = p.ParseContext(ctx, cookie)
+	}
+}
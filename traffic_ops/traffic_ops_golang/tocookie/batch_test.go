@@ -0,0 +1,92 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseBatch(t *testing.T) {
+	secret := "secret"
+	for _, size := range []int{0, 1, minBatchPerWorker - 1, 10*minBatchPerWorker + batchChunk/2} {
+		cookies := make([]string, size)
+		for i := range cookies {
+			cookies[i] = New(fmt.Sprintf("user%d", i), time.Now().Add(time.Minute), secret)
+			switch i % 4 {
+			case 1:
+				cookies[i] = New(fmt.Sprintf("user%d", i), time.Now().Add(time.Minute), "wrong")
+			case 2:
+				cookies[i] = "not a cookie"
+			}
+		}
+		parsed := int64(0)
+		results := ParseBatch(secret, cookies, WithObserver(func(Outcome, error) { atomic.AddInt64(&parsed, 1) }))
+		if len(results) != size || parsed != int64(size) {
+			t.Fatalf("ParseBatch of %v cookies expected %v results and observations, actual: %v %v", size, size, len(results), parsed)
+		}
+		for i, result := range results {
+			switch i % 4 {
+			case 1:
+				if !errors.Is(result.Err, ErrBadSignature) {
+					t.Errorf("ParseBatch of cookie %v with wrong secret expected ErrBadSignature, actual: %v", i, result.Err)
+				}
+			case 2:
+				if !errors.Is(result.Err, ErrMalformed) {
+					t.Errorf("ParseBatch of malformed cookie %v expected ErrMalformed, actual: %v", i, result.Err)
+				}
+			default:
+				if result.Err != nil || result.Cookie.AuthData != fmt.Sprintf("user%d", i) {
+					t.Errorf("ParseBatch of cookie %v expected user%v and nil error, actual: %+v %v", i, i, result.Cookie, result.Err)
+				}
+			}
+		}
+	}
+}
+
+func TestParseBatchContext(t *testing.T) {
+	secret := "secret"
+	cookies := make([]string, 4*minBatchPerWorker)
+	for i := range cookies {
+		cookies[i] = New("alice", time.Now().Add(time.Minute), secret)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for i, result := range NewParser(secret).ParseBatchContext(ctx, cookies) {
+		if !errors.Is(result.Err, context.Canceled) {
+			t.Errorf("ParseBatchContext of cookie %v with canceled context expected context.Canceled, actual: %v", i, result.Err)
+		}
+	}
+}
+
+func BenchmarkParseBatch(b *testing.B) {
+	cookies := make([]string, 1024)
+	for i := range cookies {
+		cookies[i] = New(strings.Repeat("u", 32), time.Now().Add(time.Hour), "secret")
+	}
+	p := NewParser("secret")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, result := range p.ParseBatch(cookies) {
+			if result.Err != nil {
+				b.Fatalf("Parser.ParseBatch expected nil error, actual: %v", result.Err)
+			}
+		}
+	}
+}
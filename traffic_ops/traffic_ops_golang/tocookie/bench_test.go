@@ -0,0 +1,251 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"crypto"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var benchHashes = []struct {
+	name string
+	hash crypto.Hash
+}{
+	{"SHA1", crypto.SHA1},
+	{"SHA256", crypto.SHA256},
+}
+
+// benchSizes are the lengths of the auth data of the benchmarked cookies. The cookies also carry a jti and fingerprint, as real sessions do.
+var benchSizes = []struct {
+	name string
+	size int
+}{
+	{"Typical", 16},
+	{"Large", 512},
+	{"Huge", 2048},
+}
+
+func benchOpts(hash crypto.Hash) []Option {
+	return []Option{WithHash(hash), WithJTI("0123456789abcdef0123456789abcdef"), WithFingerprint(Fingerprint("Mozilla/5.0 (X11; Linux x86_64)", "192.0.2.0/24"))}
+}
+
+func BenchmarkNew(b *testing.B) {
+	expiration := time.Now().Add(time.Hour)
+	for _, h := range benchHashes {
+		for _, size := range benchSizes {
+			b.Run(h.name+"/"+size.name, func(b *testing.B) {
+				user := strings.Repeat("u", size.size)
+				opts := benchOpts(h.hash)
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					New(user, expiration, "secret", opts...)
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkParse(b *testing.B) {
+	for _, h := range benchHashes {
+		for _, size := range benchSizes {
+			b.Run(h.name+"/"+size.name, func(b *testing.B) {
+				opts := benchOpts(h.hash)
+				cookie := New(strings.Repeat("u", size.size), time.Now().Add(time.Hour), "secret", opts...)
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					if _, err := Parse("secret", cookie, opts...); err != nil {
+						b.Fatalf("Parse expected nil error, actual: %v", err)
+					}
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkParser(b *testing.B) {
+	for _, h := range benchHashes {
+		for _, size := range benchSizes {
+			b.Run(h.name+"/"+size.name, func(b *testing.B) {
+				opts := benchOpts(h.hash)
+				cookie := New(strings.Repeat("u", size.size), time.Now().Add(time.Hour), "secret", opts...)
+				p := NewParser("secret", opts...)
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					if _, err := p.Parse(cookie); err != nil {
+						b.Fatalf("Parser.Parse expected nil error, actual: %v", err)
+					}
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkValidateSignatureOnly(b *testing.B) {
+	for _, h := range benchHashes {
+		for _, size := range benchSizes {
+			b.Run(h.name+"/"+size.name, func(b *testing.B) {
+				opts := benchOpts(h.hash)
+				cookie := New(strings.Repeat("u", size.size), time.Now().Add(time.Hour), "secret", opts...)
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					if err := ValidateSignatureOnly("secret", cookie, opts...); err != nil {
+						b.Fatalf("ValidateSignatureOnly expected nil error, actual: %v", err)
+					}
+				}
+			})
+		}
+	}
+}
+
+// BenchmarkVerifier benchmarks Verifier.Verify against the ValidateSignatureOnly it replaces in hot paths. To see where the time goes, profile it:
+//
+//	go test -run '^$' -bench 'Verifier|ValidateSignatureOnly' -cpuprofile cpu.out -memprofile mem.out
+//	go tool pprof -top cpu.out
+func BenchmarkVerifier(b *testing.B) {
+	for _, h := range benchHashes {
+		for _, size := range benchSizes {
+			b.Run(h.name+"/"+size.name, func(b *testing.B) {
+				opts := benchOpts(h.hash)
+				cookie := New(strings.Repeat("u", size.size), time.Now().Add(time.Hour), "secret", opts...)
+				v := NewVerifier("secret", opts...)
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					if err := v.Verify(cookie); err != nil {
+						b.Fatalf("Verifier.Verify expected nil error, actual: %v", err)
+					}
+				}
+			})
+		}
+	}
+}
+
+// BenchmarkVerifierParallel benchmarks Verifier.Verify from every CPU at once, as a busy server calls it, which contends for its pooled hash states.
+func BenchmarkVerifierParallel(b *testing.B) {
+	for _, h := range benchHashes {
+		b.Run(h.name, func(b *testing.B) {
+			opts := benchOpts(h.hash)
+			cookie := New(strings.Repeat("u", 16), time.Now().Add(time.Hour), "secret", opts...)
+			v := NewVerifier("secret", opts...)
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					if err := v.Verify(cookie); err != nil {
+						b.Errorf("Verifier.Verify expected nil error, actual: %v", err)
+						return
+					}
+				}
+			})
+		})
+	}
+}
+
+// benchMalformed are cookies which are rejected before their signature is checked, as clients and scanners send them.
+var benchMalformed = []struct {
+	name   string
+	cookie string
+}{
+	{"Empty", ""},
+	{"NoSignature", strings.Repeat("eyJhdXRoX2RhdGEiOiJ1In0", 8)},
+	{"BadSignature", "eyJhdXRoX2RhdGEiOiJ1In0--" + strings.Repeat("zz", 20)},
+	{"ShortSignature", "eyJhdXRoX2RhdGEiOiJ1In0--0123456789abcdef"},
+	{"Junk", `<script>alert("x")</script>--0123456789abcdef0123456789abcdef01234567`},
+}
+
+func BenchmarkParseMalformed(b *testing.B) {
+	for _, m := range benchMalformed {
+		b.Run(m.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := Parse("secret", m.cookie); err == nil {
+					b.Fatalf("Parse expected error, actual: nil")
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkParserMalformed(b *testing.B) {
+	p := NewParser("secret")
+	for _, m := range benchMalformed {
+		b.Run(m.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := p.Parse(m.cookie); err == nil {
+					b.Fatalf("Parser.Parse expected error, actual: nil")
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkMiddlewareRefresh benchmarks Middleware under concurrent requests whose cookies all need refreshing, refreshing them synchronously and WithRefreshQueue, and reports the 99th percentile latency of requests, which the queue takes the refreshes off.
+func BenchmarkMiddlewareRefresh(b *testing.B) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	// the cookies expire within the window, so every request refreshes, or queues a refresh of, its session.
+	cookies := make([]string, 64)
+	for i := range cookies {
+		cookies[i] = New("user"+strconv.Itoa(i), time.Now().Add(time.Hour), "secret", benchOpts(crypto.SHA256)...)
+	}
+	modes := []struct {
+		name  string
+		queue func() *RefreshQueue
+	}{
+		{"Sync", func() *RefreshQueue { return nil }},
+		{"Queue", func() *RefreshQueue { return NewRefreshQueue(4, len(cookies)) }},
+	}
+	for _, mode := range modes {
+		b.Run(mode.name, func(b *testing.B) {
+			opts := append(benchOpts(crypto.SHA256), WithRefreshWindow(2*time.Hour))
+			if q := mode.queue(); q != nil {
+				defer q.Close()
+				opts = append(opts, WithRefreshQueue(q))
+			}
+			handler := Middleware("secret", next, opts...)
+			mu := sync.Mutex{}
+			latencies := make([]time.Duration, 0, b.N)
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				local := []time.Duration{}
+				for i := 0; pb.Next(); i++ {
+					r := httptest.NewRequest(http.MethodGet, "/", nil)
+					r.AddCookie(&http.Cookie{Name: Name, Value: cookies[i%len(cookies)]})
+					start := time.Now()
+					handler.ServeHTTP(httptest.NewRecorder(), r)
+					local = append(local, time.Since(start))
+				}
+				mu.Lock()
+				latencies = append(latencies, local...)
+				mu.Unlock()
+			})
+			b.StopTimer()
+			sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+			if len(latencies) > 0 {
+				b.ReportMetric(float64(latencies[len(latencies)*99/100].Nanoseconds()), "p99-ns")
+			}
+		})
+	}
+}
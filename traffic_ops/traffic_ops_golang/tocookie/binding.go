@@ -0,0 +1,94 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+)
+
+// ClientBinding configures binding cookies to the clients they were minted for; see WithClientBinding.
+type ClientBinding struct {
+	// IP binds cookies to the client's IP address, or its network given IPv4Prefix or IPv6Prefix.
+	IP bool
+	// IPv4Prefix and IPv6Prefix are the lengths of the prefixes of IPv4 and IPv6 addresses which are bound, e.g. 24 and 64, so clients whose addresses change within their network keep their sessions. Zero binds the whole address.
+	IPv4Prefix int
+	IPv6Prefix int
+	// UserAgent binds cookies to the client's User-Agent header.
+	UserAgent bool
+	// ClientIP, if not nil, returns the IP address of the client of the request, e.g. from the X-Forwarded-For header set by a trusted proxy. By default, it is the host of the request's RemoteAddr.
+	ClientIP func(r *http.Request) string
+}
+
+// WithClientBinding makes Middleware bind cookies to the clients they were minted for, rejecting cookies presented by other clients with ErrFingerprintMismatch, so a stolen cookie is useless from another network or browser. Cookies are bound by the Fingerprint of the client's attributes, as WithFingerprint binds them; use BindRequest to mint cookies bound to the client of a login request.
+//
+// Cookies minted without the binding, e.g. before it was enabled, are rejected, so enabling it logs every user out. Clients whose address changes, e.g. mobile clients, lose their sessions, unless IPv4Prefix and IPv6Prefix are short enough to include their new addresses.
+func WithClientBinding(binding ClientBinding) Option {
+	return func(o *options) { o.binding = &binding }
+}
+
+// Fingerprint returns the fingerprint of the client of the request, for WithFingerprint.
+func (b ClientBinding) Fingerprint(r *http.Request) string {
+	attributes := make([]string, 0, 2)
+	if b.IP {
+		attributes = append(attributes, "ip:"+b.network(r))
+	}
+	if b.UserAgent {
+		attributes = append(attributes, "ua:"+r.UserAgent())
+	}
+	return Fingerprint(attributes...)
+}
+
+// network returns the bound prefix of the client's address, or the address as given if it can't be parsed.
+func (b ClientBinding) network(r *http.Request) string {
+	ip := requestIP(r, b.ClientIP)
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return ip
+	}
+	addr = addr.Unmap().WithZone("")
+	bits := b.IPv6Prefix
+	if addr.Is4() {
+		bits = b.IPv4Prefix
+	}
+	if bits <= 0 || bits >= addr.BitLen() {
+		return addr.String()
+	}
+	prefix, err := addr.Prefix(bits)
+	if err != nil {
+		return addr.String()
+	}
+	return prefix.Masked().Addr().String() + "/" + strconv.Itoa(bits)
+}
+
+// requestIP returns the IP address of the client of the request, by clientIP if it isn't nil, or else the host of its RemoteAddr, or the RemoteAddr as given if it has no port.
+func requestIP(r *http.Request, clientIP func(r *http.Request) string) string {
+	if clientIP != nil {
+		return clientIP(r)
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+// BindRequest returns an option binding a cookie to the client of the request, by the WithClientBinding of the options, for minting the cookies of login requests. Without WithClientBinding, the option does nothing.
+func BindRequest(r *http.Request, opts ...Option) Option {
+	o := newOptions(opts)
+	if o.binding == nil {
+		return func(*options) {}
+	}
+	return WithFingerprint(o.binding.Fingerprint(r))
+}
@@ -0,0 +1,91 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClientBindingFingerprint(t *testing.T) {
+	request := func(remoteAddr, userAgent string) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = remoteAddr
+		r.Header.Set("User-Agent", userAgent)
+		return r
+	}
+	subnets := ClientBinding{IP: true, IPv4Prefix: 24, IPv6Prefix: 64}
+	tests := map[string]struct {
+		binding ClientBinding
+		a, b    *http.Request
+		same    bool
+	}{
+		"same address":     {ClientBinding{IP: true}, request("192.0.2.1:1234", "a"), request("192.0.2.1:5678", "b"), true},
+		"other address":    {ClientBinding{IP: true}, request("192.0.2.1:1234", "a"), request("192.0.2.2:1234", "a"), false},
+		"same /24":         {subnets, request("192.0.2.1:1234", "a"), request("192.0.2.200:1234", "a"), true},
+		"other /24":        {subnets, request("192.0.2.1:1234", "a"), request("192.0.3.1:1234", "a"), false},
+		"same /64":         {subnets, request("[2001:db8::1]:1234", "a"), request("[2001:db8::ffff:1]:1234", "a"), true},
+		"other /64":        {subnets, request("[2001:db8::1]:1234", "a"), request("[2001:db8:0:1::1]:1234", "a"), false},
+		"mapped":           {ClientBinding{IP: true}, request("192.0.2.1:1234", "a"), request("[::ffff:192.0.2.1]:1234", "a"), true},
+		"same user agent":  {ClientBinding{UserAgent: true}, request("192.0.2.1:1234", "a"), request("192.0.2.2:1234", "a"), true},
+		"other user agent": {ClientBinding{UserAgent: true}, request("192.0.2.1:1234", "a"), request("192.0.2.1:1234", "b"), false},
+		"both":             {ClientBinding{IP: true, UserAgent: true}, request("192.0.2.1:1234", "a"), request("192.0.2.1:1234", "b"), false},
+		"client ip":        {ClientBinding{IP: true, ClientIP: func(r *http.Request) string { return r.Header.Get("User-Agent") }}, request("192.0.2.1:1234", "198.51.100.1"), request("192.0.2.2:1234", "198.51.100.1"), true},
+	}
+	for name, test := range tests {
+		if same := test.binding.Fingerprint(test.a) == test.binding.Fingerprint(test.b); same != test.same {
+			t.Errorf("%v: Fingerprint expected same %v, actual: %v", name, test.same, same)
+		}
+	}
+}
+
+func TestWithClientBinding(t *testing.T) {
+	secret := "secret"
+	binding := WithClientBinding(ClientBinding{IP: true, IPv4Prefix: 24, UserAgent: true})
+	login := httptest.NewRequest(http.MethodPost, "/login", nil)
+	login.RemoteAddr, login.Header["User-Agent"] = "192.0.2.1:1234", []string{"browser"}
+	cookie := New("alice", time.Now().Add(time.Hour), secret, BindRequest(login, binding))
+	if c, _ := ParseUnverified(cookie); c == nil || c.Fingerprint == "" {
+		t.Fatalf("BindRequest expected fingerprint, actual: %+v", c)
+	}
+	if fp := newOptions([]Option{BindRequest(login)}).fingerprint; fp != "" {
+		t.Errorf("BindRequest without binding expected no fingerprint, actual: %v", fp)
+	}
+
+	handler := Middleware(secret, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), binding)
+	tests := map[string]struct {
+		remoteAddr, userAgent, cookie string
+		expected                      int
+	}{
+		"same client":    {"192.0.2.77:4321", "browser", cookie, http.StatusOK},
+		"other network":  {"198.51.100.1:1234", "browser", cookie, http.StatusUnauthorized},
+		"other browser":  {"192.0.2.1:1234", "curl", cookie, http.StatusUnauthorized},
+		"unbound cookie": {"192.0.2.1:1234", "browser", New("alice", time.Now().Add(time.Hour), secret), http.StatusUnauthorized},
+	}
+	for name, test := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr, r.Header["User-Agent"] = test.remoteAddr, []string{test.userAgent}
+		r.AddCookie(&http.Cookie{Name: Name, Value: test.cookie})
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != test.expected {
+			t.Errorf("%v: Middleware expected %v, actual: %v", name, test.expected, w.Code)
+		}
+	}
+	if _, err := Parse(secret, cookie, WithFingerprint(ClientBinding{IP: true}.Fingerprint(login))); !errors.Is(err, ErrFingerprintMismatch) {
+		t.Errorf("Parse with other binding expected ErrFingerprintMismatch, actual: %v", err)
+	}
+}
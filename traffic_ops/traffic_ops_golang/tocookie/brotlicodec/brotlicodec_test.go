@@ -0,0 +1,43 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package brotlicodec
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/tocookie"
+)
+
+func TestBrotliCookie(t *testing.T) {
+	secret := "secret"
+	user := strings.Repeat("alice", 200)
+	cookie := tocookie.New(user, time.Now().Add(time.Minute), secret, tocookie.WithCompression(Name))
+	if cookie == "" {
+		t.Fatalf("New with brotli compression expected cookie, actual empty")
+	}
+	if uncompressed := tocookie.New(user, time.Now().Add(time.Minute), secret); len(cookie) >= len(uncompressed) {
+		t.Errorf("New with brotli compression expected shorter than %v, actual: %v", len(uncompressed), len(cookie))
+	}
+	c, err := tocookie.Parse(secret, cookie)
+	if err != nil {
+		t.Fatalf("Parse expected nil error, actual: %v", err)
+	}
+	if c.AuthData != user {
+		t.Errorf("Parse expected AuthData to round-trip, actual: '%v'", c.AuthData)
+	}
+	if _, err := tocookie.Parse(secret, cookie, tocookie.WithMaxDecompressedSize(100)); err == nil {
+		t.Errorf("Parse over max decompressed size expected error, actual nil")
+	}
+}
@@ -0,0 +1,138 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+)
+
+// WithCapabilities sets the capabilities of a cookie minted by New, so services can authorize requests from the cookie alone; see HasCapability and RequireCapability. Traffic Ops has a hundred or so capabilities, whose names would take kilobytes, so minters should also give WithCapabilityTable.
+func WithCapabilities(capabilities ...string) Option {
+	copied := append([]string(nil), capabilities...)
+	return func(o *options) { o.capabilities = copied }
+}
+
+// WithCapabilityTable makes New and Refresh store the capabilities in the table as a bitmask of their indexes, of one bit per capability, rather than as names, and Parse expand them back, so a cookie with every capability of a table of a hundred takes 18 bytes rather than kilobytes. Capabilities not in the table are stored as names.
+//
+// Every minter and parser of the cookie must have the same table, so it must only ever be appended to; capabilities must never be removed or reordered, or cookies would be read with the wrong ones. Bits beyond the end of the table, set by minters with a newer table, are ignored, so a parser with an older table grants fewer capabilities rather than rejecting the cookie, and kept, so Refresh doesn't strip them. Parse without the table leaves the bitmask in CapabilityMask.
+func WithCapabilityTable(capabilities ...string) Option {
+	names := append([]string(nil), capabilities...)
+	table := make(map[string]int, len(names))
+	for i, name := range names {
+		if _, ok := table[name]; !ok {
+			table[name] = i
+		}
+	}
+	return func(o *options) {
+		o.capabilityNames = names
+		o.capabilityTable = table
+	}
+}
+
+// HasCapability returns whether the cookie has the given capability.
+func (c *Cookie) HasCapability(capability string) bool {
+	for _, name := range c.Capabilities {
+		if name == capability {
+			return true
+		}
+	}
+	return false
+}
+
+// HasAllCapabilities returns whether the cookie has every one of the given capabilities.
+func (c *Cookie) HasAllCapabilities(capabilities ...string) bool {
+	for _, capability := range capabilities {
+		if !c.HasCapability(capability) {
+			return false
+		}
+	}
+	return true
+}
+
+// RequireCapability returns a handler which only passes requests authenticated by Middleware, whose cookie has all of the given capabilities, to next. Requests without an authenticated cookie are answered with 401 Unauthorized, and those whose cookie lacks a capability with 403 Forbidden. It is for coarse authorization; the cookie is only as current as its last refresh, so capabilities revoked since are still granted until then.
+func RequireCapability(next http.Handler, capabilities ...string) http.Handler {
+	capabilities = append([]string(nil), capabilities...)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, ok := FromContext(r.Context())
+		if !ok {
+			SetChallenge(w, DefaultRealm, nil)
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		if !c.HasAllCapabilities(capabilities...) {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// expandCapabilities adds the capabilities of the cookie's CapabilityMask in the table to its Capabilities. The mask is cleared unless it has bits beyond the end of the table, which are kept for compactCapabilities.
+func (o *options) expandCapabilities(c *Cookie) error {
+	if c.CapabilityMask == "" || o.capabilityNames == nil {
+		return nil
+	}
+	mask, err := base64.RawURLEncoding.DecodeString(c.CapabilityMask)
+	if err != nil {
+		return fmt.Errorf("%w: error decoding capability mask: %w", ErrMalformed, err)
+	}
+	unknown := false
+	for i := 0; i < len(mask)*8; i++ {
+		if mask[i/8]&(1<<uint(i%8)) == 0 {
+			continue
+		}
+		if i >= len(o.capabilityNames) {
+			unknown = true
+			continue
+		}
+		if name := o.capabilityNames[i]; !c.HasCapability(name) {
+			c.Capabilities = append(c.Capabilities, name)
+		}
+	}
+	if !unknown {
+		c.CapabilityMask = ""
+	}
+	return nil
+}
+
+// compactCapabilities returns the cookie with the Capabilities in the table moved into its CapabilityMask, for marshalling. The cookie itself isn't modified. Without a table, it is returned as it is.
+func (o *options) compactCapabilities(c *Cookie) (*Cookie, error) {
+	if o.capabilityTable == nil || len(c.Capabilities) == 0 {
+		return c, nil
+	}
+	mask, err := base64.RawURLEncoding.DecodeString(c.CapabilityMask)
+	if err != nil {
+		return nil, fmt.Errorf("decoding capability mask: %w", err)
+	}
+	var names []string
+	for _, name := range c.Capabilities {
+		i, ok := o.capabilityTable[name]
+		if !ok {
+			names = append(names, name)
+			continue
+		}
+		for len(mask) <= i/8 {
+			mask = append(mask, 0)
+		}
+		mask[i/8] |= 1 << uint(i%8)
+	}
+	for len(mask) > 0 && mask[len(mask)-1] == 0 {
+		mask = mask[:len(mask)-1]
+	}
+	compacted := *c
+	compacted.Capabilities = names
+	compacted.CapabilityMask = base64.RawURLEncoding.EncodeToString(mask)
+	return &compacted, nil
+}
@@ -0,0 +1,129 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestCapabilities(t *testing.T) {
+	secret := "secret"
+	table := []string{"cdns-read", "cdns-write", "servers-read", "servers-write"}
+	tests := map[string]struct {
+		mint  []Option
+		parse []Option
+		caps  []string
+		mask  string
+	}{
+		"names":         {[]Option{WithCapabilities("cdns-read", "other")}, nil, []string{"cdns-read", "other"}, ""},
+		"table":         {[]Option{WithCapabilities("servers-write", "cdns-read"), WithCapabilityTable(table...)}, []Option{WithCapabilityTable(table...)}, []string{"cdns-read", "servers-write"}, ""},
+		"table unknown": {[]Option{WithCapabilities("cdns-write", "other"), WithCapabilityTable(table...)}, []Option{WithCapabilityTable(table...)}, []string{"cdns-write", "other"}, ""},
+		"no table":      {[]Option{WithCapabilities("cdns-write", "other"), WithCapabilityTable(table...)}, nil, []string{"other"}, "Ag"},
+		"newer table":   {[]Option{WithCapabilities("cdns-read", "servers-write"), WithCapabilityTable(table...)}, []Option{WithCapabilityTable(table[:2]...)}, []string{"cdns-read"}, "CQ"},
+		"only names":    {[]Option{WithCapabilities("cdns-read"), WithCapabilityTable()}, []Option{WithCapabilityTable()}, []string{"cdns-read"}, ""},
+	}
+	for name, test := range tests {
+		cookie := New("alice", time.Now().Add(time.Hour), secret, test.mint...)
+		c, err := Parse(secret, cookie, test.parse...)
+		if err != nil {
+			t.Errorf("%v: Parse expected nil error, actual: %v", name, err)
+			continue
+		}
+		caps := append([]string(nil), c.Capabilities...)
+		sort.Strings(caps)
+		if !reflect.DeepEqual(caps, test.caps) {
+			t.Errorf("%v: Parse expected capabilities %v, actual: %v", name, test.caps, caps)
+		}
+		if c.CapabilityMask != test.mask {
+			t.Errorf("%v: Parse expected capability mask '%v', actual: '%v'", name, test.mask, c.CapabilityMask)
+		}
+
+		refreshed, err := Parse(secret, Refresh(c, secret, test.parse...), test.mint...)
+		if err != nil {
+			t.Errorf("%v: Parse of refreshed cookie expected nil error, actual: %v", name, err)
+			continue
+		}
+		original, _ := Parse(secret, cookie, test.mint...)
+		if !refreshed.HasAllCapabilities(original.Capabilities...) || len(refreshed.Capabilities) != len(original.Capabilities) {
+			t.Errorf("%v: Refresh expected capabilities %v preserved, actual: %v", name, original.Capabilities, refreshed.Capabilities)
+		}
+	}
+}
+
+func TestCapabilityTableSize(t *testing.T) {
+	secret := "secret"
+	table := make([]string, 100)
+	for i := range table {
+		table[i] = "capability-with-a-typical-length-" + string(rune('a'+i%26)) + string(rune('a'+i/26))
+	}
+	expiration := time.Now().Add(time.Hour)
+	names := len(New("alice", expiration, secret, WithCapabilities(table...)))
+	compact := New("alice", expiration, secret, WithCapabilities(table...), WithCapabilityTable(table...))
+	if names < 4096 {
+		t.Errorf("New with capability names expected over 4096 bytes, actual: %v", names)
+	}
+	if len(compact) > 512 {
+		t.Errorf("New with capability table expected at most 512 bytes, actual: %v", len(compact))
+	}
+	c, err := Parse(secret, compact, WithCapabilityTable(table...))
+	if err != nil || !c.HasAllCapabilities(table...) {
+		t.Errorf("Parse with capability table expected all capabilities, actual: %v %v", c, err)
+	}
+}
+
+func TestCapabilityMaskMalformed(t *testing.T) {
+	secret := "secret"
+	expires := strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)
+	cookie := newMojoCookie(`{"auth_data":"alice","expires":`+expires+`,"by":"trafficcontrol-go-tocookie","capm":"!!"}`, secret)
+	if _, err := Parse(secret, cookie); err != nil {
+		t.Errorf("Parse without capability table of malformed mask expected nil error, actual: %v", err)
+	}
+	if _, err := Parse(secret, cookie, WithCapabilityTable("a")); !errors.Is(err, ErrMalformed) {
+		t.Errorf("Parse with capability table of malformed mask expected ErrMalformed, actual: %v", err)
+	}
+}
+
+func TestRequireCapability(t *testing.T) {
+	secret := "secret"
+	handler := Middleware(secret, RequireCapability(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), "cdns-read", "cdns-write"))
+	tests := map[string]struct {
+		cookie string
+		status int
+	}{
+		"all":     {New("alice", time.Now().Add(time.Hour), secret, WithCapabilities("cdns-read", "cdns-write", "other")), http.StatusOK},
+		"missing": {New("alice", time.Now().Add(time.Hour), secret, WithCapabilities("cdns-read")), http.StatusForbidden},
+		"none":    {New("alice", time.Now().Add(time.Hour), secret), http.StatusForbidden},
+	}
+	for name, test := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.AddCookie(&http.Cookie{Name: Name, Value: test.cookie})
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != test.status {
+			t.Errorf("%v: RequireCapability expected status %v, actual: %v", name, test.status, w.Code)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	RequireCapability(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("RequireCapability of unauthenticated request expected status %v, actual: %v", http.StatusUnauthorized, w.Code)
+	}
+}
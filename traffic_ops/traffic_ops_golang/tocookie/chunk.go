@@ -0,0 +1,155 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultChunkSize is a chunk size for WithChunking which, with the name and attributes of a cookie, fits within the 4096 bytes per cookie browsers are required to store by RFC 6265.
+const DefaultChunkSize = 3800
+
+// MaxChunks is the most chunks a cookie value is split into, or reassembled from, so a request can't make RequestCookie concatenate an unbounded number of cookies. Browsers are only required to store 50 cookies per domain.
+const MaxChunks = 16
+
+// chunkSep separates the Name of chunked cookies from the index of the chunk.
+const chunkSep = "."
+
+// WithChunking makes SetHTTPCookies and Middleware split cookie values longer than size bytes, which browsers would drop, e.g. of sessions with large claim sets even after WithCompression, across several cookies named ChunkName(0), ChunkName(1), and so on. RequestCookie reassembles them, and the signature is verified over the reassembled value, so chunks can't be dropped, reordered, or mixed between sessions. Values no longer than size are set as a single cookie named Name, as without chunking. See DefaultChunkSize.
+//
+// Chunked values are usually longer than DefaultMaxCookieSize, so Parse and Middleware must be given a WithMaxCookieSize of the longest value expected. Perl Traffic Ops only reads the cookie named Name, so it can't read chunked cookies.
+func WithChunking(size int) Option {
+	return func(o *options) { o.chunkSize = size }
+}
+
+// ChunkName returns the name of the cookie holding chunk i of a chunked cookie value, e.g. "mojolicious.0".
+func ChunkName(i int) string {
+	return Name + chunkSep + strconv.Itoa(i)
+}
+
+// HTTPCookies returns the cookie value as *http.Cookies with the attributes of NewHTTPCookie: a single cookie named Name if chunking isn't enabled WithChunking, or the value is no longer than the chunk size, and otherwise its chunks. It returns nil if the value would need more than MaxChunks chunks.
+func HTTPCookies(value string, expiration time.Time, opts ...Option) []*http.Cookie {
+	return newOptions(opts).httpCookies(value, expiration)
+}
+
+func (o *options) httpCookies(value string, expiration time.Time) []*http.Cookie {
+	if o.chunkSize <= 0 || len(value) <= o.chunkSize {
+		return []*http.Cookie{o.httpCookie(value, expiration)}
+	}
+	if (len(value)+o.chunkSize-1)/o.chunkSize > MaxChunks {
+		return nil
+	}
+	cookies := []*http.Cookie{}
+	for i := 0; len(value) > 0; i++ {
+		size := o.chunkSize
+		if size > len(value) {
+			size = len(value)
+		}
+		c := o.httpCookie(value[:size], expiration)
+		c.Name = o.chunkName(i)
+		cookies = append(cookies, c)
+		value = value[size:]
+	}
+	return cookies
+}
+
+// SetHTTPCookies sets the cookie value on the response, as the cookies of HTTPCookies. Cookies of the request, if it isn't nil, which the new cookies don't replace, such as the chunks of a longer value it sent, or a single cookie replaced by chunks, are expired, so RequestCookie doesn't read a mix of the old and new values. It returns an error if the value would need more than MaxChunks chunks, without setting anything.
+func SetHTTPCookies(w http.ResponseWriter, r *http.Request, value string, expiration time.Time, opts ...Option) error {
+	return newOptions(opts).setHTTPCookies(w, r, value, expiration)
+}
+
+func (o *options) setHTTPCookies(w http.ResponseWriter, r *http.Request, value string, expiration time.Time) error {
+	cookies := o.httpCookies(value, expiration)
+	if cookies == nil {
+		return fmt.Errorf("cookie of %d bytes needs more than %d chunks of %d bytes", len(value), MaxChunks, o.chunkSize)
+	}
+	set := map[string]struct{}{}
+	for _, c := range cookies {
+		http.SetCookie(w, c)
+		set[c.Name] = struct{}{}
+	}
+	o.expireCookies(w, r, set)
+	return nil
+}
+
+// ClearHTTPCookies expires the cookie named Name, or that of WithCookieName, on the response, and any chunks of a chunked cookie the request, if it isn't nil, sent, e.g. on logout.
+func ClearHTTPCookies(w http.ResponseWriter, r *http.Request, opts ...Option) {
+	newOptions(opts).clearHTTPCookies(w, r)
+}
+
+func (o *options) clearHTTPCookies(w http.ResponseWriter, r *http.Request) {
+	o.expireCookie(w, o.name())
+	o.expireCookies(w, r, map[string]struct{}{o.name(): {}})
+}
+
+// expireCookies expires the cookies of the request of the configured name or its chunks, other than those in keep.
+func (o *options) expireCookies(w http.ResponseWriter, r *http.Request, keep map[string]struct{}) {
+	if r == nil {
+		return
+	}
+	for _, c := range r.Cookies() {
+		if _, kept := keep[c.Name]; kept || !o.isSessionCookieName(c.Name) {
+			continue
+		}
+		keep[c.Name] = struct{}{}
+		o.expireCookie(w, c.Name)
+	}
+}
+
+// expireCookie sets a cookie of the name with the configured attributes, which the browser deletes immediately.
+func (o *options) expireCookie(w http.ResponseWriter, name string) {
+	c := o.httpCookie("", time.Unix(0, 0))
+	c.Name = name
+	c.MaxAge = -1
+	http.SetCookie(w, c)
+}
+
+// isSessionCookieName returns whether the name is the configured name, or that of a chunk.
+func (o *options) isSessionCookieName(name string) bool {
+	if name == o.name() {
+		return true
+	}
+	index := strings.TrimPrefix(name, o.name()+chunkSep)
+	i, err := strconv.Atoi(index)
+	return index != name && err == nil && i >= 0 && o.chunkName(i) == name
+}
+
+// RequestCookie returns the cookie value of the request: that of the cookie named Name, or that of WithCookieName, if there is one, and otherwise the concatenation of the chunks set by SetHTTPCookies, which must be verified, e.g. by Parse, as any cookie value must. It returns http.ErrNoCookie if the request has neither, and an error wrapping ErrMalformed if it has more than MaxChunks chunks.
+func RequestCookie(r *http.Request, opts ...Option) (string, error) {
+	return newOptions(opts).requestCookie(r)
+}
+
+func (o *options) requestCookie(r *http.Request) (string, error) {
+	if c, err := r.Cookie(o.name()); err == nil {
+		return c.Value, nil
+	}
+	value := strings.Builder{}
+	for i := 0; ; i++ {
+		c, err := r.Cookie(o.chunkName(i))
+		if errors.Is(err, http.ErrNoCookie) {
+			if i == 0 {
+				return "", http.ErrNoCookie
+			}
+			return value.String(), nil
+		}
+		if i == MaxChunks {
+			return "", fmt.Errorf("%w: more than %d chunks", ErrMalformed, MaxChunks)
+		}
+		value.WriteString(c.Value)
+	}
+}
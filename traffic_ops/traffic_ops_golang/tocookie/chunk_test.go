@@ -0,0 +1,154 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// requestWith returns a request carrying the cookies set on the response.
+func requestWith(cookies []*http.Cookie) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, c := range cookies {
+		if c.MaxAge >= 0 {
+			r.AddCookie(&http.Cookie{Name: c.Name, Value: c.Value})
+		}
+	}
+	return r
+}
+
+func TestChunking(t *testing.T) {
+	secret := "secret"
+	roles := make([]string, 500)
+	for i := range roles {
+		roles[i] = "role-" + strings.Repeat("x", i%13)
+	}
+	value := New("alice", time.Now().Add(time.Hour), secret, WithRoles(roles...))
+	chunking := WithChunking(DefaultChunkSize)
+
+	cookies := HTTPCookies(value, time.Time{}, chunking)
+	if len(cookies) < 2 {
+		t.Fatalf("HTTPCookies of %v bytes expected several chunks, actual: %v", len(value), len(cookies))
+	}
+	for i, c := range cookies {
+		if c.Name != ChunkName(i) || len(c.Value) > DefaultChunkSize || !c.HttpOnly || !c.Secure {
+			t.Errorf("HTTPCookies chunk %v expected %v of at most %v bytes with secure attributes, actual: %v of %v bytes", i, ChunkName(i), DefaultChunkSize, c.Name, len(c.Value))
+		}
+	}
+	reassembled, err := RequestCookie(requestWith(cookies))
+	if err != nil || reassembled != value {
+		t.Fatalf("RequestCookie expected the chunked value, actual: %v bytes, %v", len(reassembled), err)
+	}
+	if c, err := Parse(secret, reassembled, WithMaxCookieSize(MaxChunks*DefaultChunkSize)); err != nil || len(c.Roles) != len(roles) {
+		t.Errorf("Parse of reassembled cookie expected %v roles, actual: %v", len(roles), err)
+	}
+
+	missing := requestWith(append(cookies[:1:1], cookies[2:]...))
+	if value, err := RequestCookie(missing); err == nil {
+		if _, err := Parse(secret, value); !IsAuthFailure(err) {
+			t.Errorf("Parse of cookie missing a chunk expected auth failure, actual: %v", err)
+		}
+	}
+	swapped := requestWith([]*http.Cookie{{Name: ChunkName(0), Value: cookies[1].Value}, {Name: ChunkName(1), Value: cookies[0].Value}})
+	if value, err := RequestCookie(swapped); err == nil {
+		if _, err := Parse(secret, value); !IsAuthFailure(err) {
+			t.Errorf("Parse of cookie with reordered chunks expected auth failure, actual: %v", err)
+		}
+	}
+
+	if cookies := HTTPCookies(value, time.Time{}); len(cookies) != 1 || cookies[0].Name != Name {
+		t.Errorf("HTTPCookies without chunking expected a single cookie named %v, actual: %v", Name, cookies)
+	}
+	short := New("bob", time.Now().Add(time.Hour), secret)
+	if cookies := HTTPCookies(short, time.Time{}, chunking); len(cookies) != 1 || cookies[0].Name != Name {
+		t.Errorf("HTTPCookies of short value expected a single cookie named %v, actual: %v", Name, cookies)
+	}
+	if cookies := HTTPCookies(strings.Repeat("a", MaxChunks*10+1), time.Time{}, WithChunking(10)); cookies != nil {
+		t.Errorf("HTTPCookies of value over MaxChunks expected nil, actual: %v cookies", len(cookies))
+	}
+
+	tooMany := httptest.NewRequest(http.MethodGet, "/", nil)
+	for i := 0; i <= MaxChunks; i++ {
+		tooMany.AddCookie(&http.Cookie{Name: ChunkName(i), Value: "a"})
+	}
+	if _, err := RequestCookie(tooMany); !errors.Is(err, ErrMalformed) {
+		t.Errorf("RequestCookie of more than MaxChunks chunks expected ErrMalformed, actual: %v", err)
+	}
+	if _, err := RequestCookie(httptest.NewRequest(http.MethodGet, "/", nil)); err != http.ErrNoCookie {
+		t.Errorf("RequestCookie without cookies expected http.ErrNoCookie, actual: %v", err)
+	}
+}
+
+func TestSetHTTPCookies(t *testing.T) {
+	chunking := WithChunking(10)
+	old := httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, c := range HTTPCookies(strings.Repeat("a", 35), time.Time{}, chunking) {
+		old.AddCookie(&http.Cookie{Name: c.Name, Value: c.Value})
+	}
+	old.AddCookie(&http.Cookie{Name: "other", Value: "x"})
+
+	w := httptest.NewRecorder()
+	if err := SetHTTPCookies(w, old, strings.Repeat("b", 15), time.Time{}, chunking); err != nil {
+		t.Fatalf("SetHTTPCookies expected nil error, actual: %v", err)
+	}
+	set := map[string]*http.Cookie{}
+	for _, c := range w.Result().Cookies() {
+		set[c.Name] = c
+	}
+	for name, expired := range map[string]bool{ChunkName(0): false, ChunkName(1): false, ChunkName(2): true, ChunkName(3): true} {
+		if c, ok := set[name]; !ok || (c.MaxAge < 0) != expired {
+			t.Errorf("SetHTTPCookies expected %v set with expired %v, actual: %v", name, expired, c)
+		}
+	}
+	if _, ok := set["other"]; ok {
+		t.Errorf("SetHTTPCookies expected other cookies untouched, actual: %v", set["other"])
+	}
+	if value, err := RequestCookie(requestWith(w.Result().Cookies())); err != nil || value != strings.Repeat("b", 15) {
+		t.Errorf("RequestCookie after SetHTTPCookies expected the new value, actual: %v, %v", value, err)
+	}
+
+	w = httptest.NewRecorder()
+	ClearHTTPCookies(w, old, chunking)
+	for _, c := range w.Result().Cookies() {
+		if c.MaxAge >= 0 || !newOptions(nil).isSessionCookieName(c.Name) {
+			t.Errorf("ClearHTTPCookies expected only session cookies expired, actual: %v", c)
+		}
+	}
+	if len(w.Result().Cookies()) != 5 {
+		t.Errorf("ClearHTTPCookies expected %v and 4 chunks expired, actual: %v", Name, w.Result().Cookies())
+	}
+}
+
+func TestMiddlewareChunked(t *testing.T) {
+	secret := "secret"
+	opts := []Option{WithChunking(100), WithMaxCookieSize(MaxChunks * 100), WithRefreshWindow(DefaultRefreshWindow)}
+	value := New("alice", time.Now().Add(time.Minute), secret, WithRoles("admin", "operations", "read-only", "portal", "steering", "federation"))
+	r := requestWith(HTTPCookies(value, time.Time{}, opts...))
+	w := httptest.NewRecorder()
+	Middleware(secret, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), opts...).ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Middleware of chunked cookie expected %v, actual: %v", http.StatusOK, w.Code)
+	}
+	refreshed, err := RequestCookie(requestWith(w.Result().Cookies()))
+	if err != nil {
+		t.Fatalf("RequestCookie of refreshed chunks expected nil error, actual: %v", err)
+	}
+	if _, err := Parse(secret, refreshed, opts...); err != nil {
+		t.Errorf("Parse of refreshed chunks expected nil error, actual: %v", err)
+	}
+}
@@ -0,0 +1,156 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// DefaultMaxClaimsSize is the largest total size, in bytes, of the custom claims of a cookie by default. Browsers limit cookies to about 4KB, including the signature and encoding overhead, so this leaves room for the built-in claims.
+const DefaultMaxClaimsSize = 2048
+
+// WithClaims sets custom claims of cookies minted by New, such as a tenant ID or capabilities, so services can authorize requests without querying the database. The claims are marshalled with encoding/json into Extra, signed along with the built-in claims, and preserved by Refresh; read them with Claim, ClaimString, ClaimInt, ClaimBool, and ClaimStrings.
+//
+// New returns an empty string if a claim has the name of a built-in claim, e.g. FieldAuthData, or can't be marshalled, or if the claims are larger than WithMaxClaimsSize allows.
+func WithClaims(claims map[string]interface{}) Option {
+	copied := make(map[string]interface{}, len(claims))
+	for name, value := range claims {
+		copied[name] = value
+	}
+	return func(o *options) { o.claims = copied }
+}
+
+// NewWithClaims mints a cookie like New, with the given custom claims; see WithClaims.
+func NewWithClaims(user string, expiration time.Time, key string, claims map[string]interface{}, opts ...Option) string {
+	return New(user, expiration, key, append(append(make([]Option, 0, len(opts)+1), opts...), WithClaims(claims))...)
+}
+
+// WithMaxClaimsSize sets the largest total size, in bytes, of the custom claims of a cookie: the lengths of their names and JSON values, including Mojolicious session data in Extra. New and Refresh return an empty string for cookies whose claims are larger, and Parse and Validate reject them with ErrClaimsTooLarge. The default is DefaultMaxClaimsSize.
+func WithMaxClaimsSize(size int) Option {
+	return func(o *options) { o.maxClaimsSize = size }
+}
+
+// claimsSize returns the total size of the custom claims, as limited by WithMaxClaimsSize.
+func (c *Cookie) claimsSize() int {
+	size := 0
+	for name, val := range c.Extra {
+		size += len(name) + len(val)
+	}
+	return size
+}
+
+// SetClaim sets the custom claim with the given name to the value marshalled with encoding/json, e.g. before a Refresh. It returns an error if the name is empty or that of a built-in claim, or if the value can't be marshalled.
+func (c *Cookie) SetClaim(name string, value interface{}) error {
+	if _, ok := claimNames[name]; ok || name == "" {
+		return fmt.Errorf("custom claim name '%s' is empty or reserved", name)
+	}
+	b, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("marshalling custom claim '%s': %w", name, err)
+	}
+	if c.Extra == nil {
+		c.Extra = map[string]json.RawMessage{}
+	}
+	c.Extra[name] = b
+	return nil
+}
+
+// errNoClaim is returned by Claim when the cookie doesn't have the claim.
+var errNoClaim = errors.New("no such claim")
+
+// Claim decodes the custom claim with the given name into v, as json.Unmarshal does. It returns an error if the cookie doesn't have the claim, or it can't be decoded into v.
+func (c *Cookie) Claim(name string, v interface{}) error {
+	val, ok := c.Extra[name]
+	if !ok {
+		return fmt.Errorf("claim '%s': %w", name, errNoClaim)
+	}
+	if err := json.Unmarshal(val, v); err != nil {
+		return fmt.Errorf("decoding claim '%s': %w", name, err)
+	}
+	return nil
+}
+
+// ClaimString returns the custom claim with the given name, and whether the cookie has it as a string.
+func (c *Cookie) ClaimString(name string) (string, bool) {
+	s := ""
+	err := c.Claim(name, &s)
+	return s, err == nil
+}
+
+// ClaimInt returns the custom claim with the given name, and whether the cookie has it as an integer.
+func (c *Cookie) ClaimInt(name string) (int64, bool) {
+	i := int64(0)
+	err := c.Claim(name, &i)
+	return i, err == nil
+}
+
+// ClaimBool returns the custom claim with the given name, and whether the cookie has it as a boolean.
+func (c *Cookie) ClaimBool(name string) (bool, bool) {
+	b := false
+	err := c.Claim(name, &b)
+	return b, err == nil
+}
+
+// ClaimStrings returns the custom claim with the given name, and whether the cookie has it as an array of strings.
+func (c *Cookie) ClaimStrings(name string) ([]string, bool) {
+	strs := []string(nil)
+	err := c.Claim(name, &strs)
+	return strs, err == nil
+}
+
+// WithClaimValidators sets validators for claims, for application-specific policy such as allowed tenants. The keys are the default JSON names of the claims, e.g. FieldAuthData or "aud", or the names of Extra keys. Parse and Validate run the validators after the built-in checks, in order of claim name, and reject the cookie with an error wrapping both ErrClaimInvalid and the validator's error if any fails.
+//
+// Each validator is given the value of its claim as decoded by encoding/json into an interface{}, e.g. a string, float64, or map[string]interface{}, or nil if the cookie doesn't have the claim.
+func WithClaimValidators(validators map[string]func(value interface{}) error) Option {
+	copied := make(map[string]func(value interface{}) error, len(validators))
+	for name, validator := range validators {
+		copied[name] = validator
+	}
+	return func(o *options) { o.claimValidators = copied }
+}
+
+// validateClaims runs the configured claim validators, and returns their errors, in order of claim name. It stops at the first error unless all is true.
+func (o *options) validateClaims(c *Cookie, all bool) []error {
+	if len(o.claimValidators) == 0 {
+		return nil
+	}
+	claims := map[string]interface{}{}
+	b, err := json.Marshal(c)
+	if err == nil {
+		err = json.Unmarshal(b, &claims)
+	}
+	if err != nil {
+		return []error{fmt.Errorf("%w: decoding claims: %w", ErrClaimInvalid, err)}
+	}
+
+	names := make([]string, 0, len(o.claimValidators))
+	for name := range o.claimValidators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs []error
+	for _, name := range names {
+		if err := o.claimValidators[name](claims[name]); err != nil {
+			errs = append(errs, fmt.Errorf("%w: claim '%s': %w", ErrClaimInvalid, name, err))
+			if !all {
+				break
+			}
+		}
+	}
+	return errs
+}
@@ -0,0 +1,143 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWithClaimValidators(t *testing.T) {
+	errNoTenant := errors.New("tenant not allowed")
+	validators := map[string]func(interface{}) error{
+		FieldAuthData: func(v interface{}) error {
+			if s, _ := v.(string); s == "" {
+				return errors.New("empty user")
+			}
+			return nil
+		},
+		"tenant": func(v interface{}) error {
+			if v != "root" && v != "cdn1" {
+				return errNoTenant
+			}
+			return nil
+		},
+	}
+
+	secret := "secret"
+	c := Cookie{AuthData: "alice", By: GeneratedByStr, ExpiresUnix: time.Now().Add(time.Minute).Unix(), Extra: map[string]json.RawMessage{"tenant": json.RawMessage(`"cdn1"`)}}
+	if _, err := Parse(secret, encodeCookie(&c, secret, newOptions(nil)), WithClaimValidators(validators)); err != nil {
+		t.Errorf("Parse valid claims expected nil error, actual: %v", err)
+	}
+
+	c.Extra["tenant"] = json.RawMessage(`"cdn2"`)
+	_, err := Parse(secret, encodeCookie(&c, secret, newOptions(nil)), WithClaimValidators(validators))
+	if !errors.Is(err, ErrClaimInvalid) || !errors.Is(err, errNoTenant) {
+		t.Errorf("Parse invalid tenant expected ErrClaimInvalid wrapping validator error, actual: %v", err)
+	}
+
+	c.Extra = nil
+	if err := Validate(&c, WithClaimValidators(validators)); !errors.Is(err, errNoTenant) {
+		t.Errorf("Validate missing tenant expected validator error, actual: %v", err)
+	}
+
+	c.AuthData = ""
+	err = Validate(&c, WithClaimValidators(validators), WithAllErrors())
+	if joined, ok := err.(interface{ Unwrap() []error }); !ok || len(joined.Unwrap()) != 2 {
+		t.Errorf("Validate WithAllErrors expected both claim errors, actual: %v", err)
+	}
+}
+
+func TestWithClaims(t *testing.T) {
+	secret := "secret"
+	expiration := time.Now().Add(time.Minute)
+	cookie := NewWithClaims("alice", expiration, secret, map[string]interface{}{
+		"tenant_id":    42,
+		"tenant":       "root",
+		"capabilities": []string{"servers-read", "servers-write"},
+		"admin":        true,
+	})
+	c, err := Parse(secret, cookie)
+	if err != nil {
+		t.Fatalf("Parse expected nil error, actual: %v", err)
+	}
+	if id, ok := c.ClaimInt("tenant_id"); !ok || id != 42 {
+		t.Errorf("ClaimInt expected 42, actual: %v %v", id, ok)
+	}
+	if tenant, ok := c.ClaimString("tenant"); !ok || tenant != "root" {
+		t.Errorf("ClaimString expected root, actual: '%v' %v", tenant, ok)
+	}
+	if caps, ok := c.ClaimStrings("capabilities"); !ok || len(caps) != 2 || caps[1] != "servers-write" {
+		t.Errorf("ClaimStrings expected capabilities, actual: %v %v", caps, ok)
+	}
+	if admin, ok := c.ClaimBool("admin"); !ok || !admin {
+		t.Errorf("ClaimBool expected true, actual: %v %v", admin, ok)
+	}
+	if _, ok := c.ClaimInt("tenant"); ok {
+		t.Errorf("ClaimInt of string claim expected false, actual true")
+	}
+	if _, ok := c.ClaimString("missing"); ok {
+		t.Errorf("ClaimString of missing claim expected false, actual true")
+	}
+	if err := c.Claim("missing", new(string)); err == nil {
+		t.Errorf("Claim of missing claim expected error, actual nil")
+	}
+
+	refreshed, err := Parse(secret, Refresh(c, secret))
+	if err != nil {
+		t.Fatalf("Parse of refreshed cookie expected nil error, actual: %v", err)
+	}
+	if id, ok := refreshed.ClaimInt("tenant_id"); !ok || id != 42 {
+		t.Errorf("Refresh expected custom claims preserved, actual: %v %v", id, ok)
+	}
+	if err := refreshed.SetClaim("tenant_id", 7); err != nil {
+		t.Fatalf("SetClaim expected nil error, actual: %v", err)
+	}
+	if refreshed, err = Parse(secret, Refresh(refreshed, secret)); err != nil {
+		t.Fatalf("Parse of refreshed cookie expected nil error, actual: %v", err)
+	}
+	if id, _ := refreshed.ClaimInt("tenant_id"); id != 7 {
+		t.Errorf("SetClaim expected tenant_id 7 after Refresh, actual: %v", id)
+	}
+}
+
+func TestWithClaimsRejects(t *testing.T) {
+	secret := "secret"
+	expiration := time.Now().Add(time.Minute)
+	tests := map[string]map[string]interface{}{
+		"built-in name":  {FieldAuthData: "mallory"},
+		"empty name":     {"": 1},
+		"unmarshallable": {"ch": make(chan int)},
+		"too large":      {"blob": string(make([]byte, DefaultMaxClaimsSize))},
+	}
+	for name, claims := range tests {
+		if cookie := NewWithClaims("alice", expiration, secret, claims); cookie != "" {
+			t.Errorf("%v: NewWithClaims expected empty cookie, actual: '%v'", name, cookie)
+		}
+	}
+
+	limited := []Option{WithMaxClaimsSize(16)}
+	if cookie := NewWithClaims("alice", expiration, secret, map[string]interface{}{"tenant": "root"}, limited...); cookie == "" {
+		t.Errorf("NewWithClaims within size limit expected cookie, actual empty")
+	}
+	large := NewWithClaims("alice", expiration, secret, map[string]interface{}{"tenant": "a long tenant name"})
+	if _, err := Parse(secret, large, limited...); !errors.Is(err, ErrClaimsTooLarge) {
+		t.Errorf("Parse over size limit expected ErrClaimsTooLarge, actual: %v", err)
+	}
+	c := &Cookie{AuthData: "alice", ExpiresUnix: expiration.Unix(), Extra: map[string]json.RawMessage{"tenant": json.RawMessage(`"a long tenant name"`)}}
+	if err := Validate(c, limited...); !errors.Is(err, ErrClaimsTooLarge) {
+		t.Errorf("Validate over size limit expected ErrClaimsTooLarge, actual: %v", err)
+	}
+}
@@ -0,0 +1,40 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"time"
+)
+
+// WithLeeway makes Parse and Validate tolerate clock skew between the servers which mint and parse cookies: cookies are accepted until the leeway after they expire, and from the leeway before their NotBefore time, so a cookie minted by a server whose clock is a few seconds ahead isn't rejected by its peers. The leeway should be a few seconds, or at most minutes, as it extends the life of every cookie. By default, there is none.
+func WithLeeway(leeway time.Duration) Option {
+	return func(o *options) { o.leeway = leeway }
+}
+
+// WithExpiryGrace makes Parse and Validate accept cookies which expired no longer than the grace period ago, after any leeway, marking them Stale, so Middleware refreshes them rather than rejecting requests which were sent just as their cookie expired, e.g. during a long Traffic Portal operation. Unlike WithLeeway, the grace period is meant to be followed by a refresh, and its cookies may be told apart. It doesn't extend the life of sessions past WithMaxLifetime, and doesn't apply to API tokens. By default, there is none.
+func WithExpiryGrace(grace time.Duration) Option {
+	return func(o *options) { o.expiryGrace = grace }
+}
+
+// WithClock makes the functions given it read the current time from clock rather than time.Now: Parse and Validate validate expiry, NotBefore, and lifetimes against it, and New and Refresh stamp cookies with it. It is intended for tests, which can then mint and parse cookies at any time without waiting.
+func WithClock(clock func() time.Time) Option {
+	return func(o *options) { o.clock = clock }
+}
+
+// now returns the current time of the configured clock.
+func (o *options) now() time.Time {
+	if o.clock != nil {
+		return o.clock()
+	}
+	return time.Now()
+}
@@ -0,0 +1,127 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWithClock(t *testing.T) {
+	secret := "secret"
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := WithClock(func() time.Time { return now })
+
+	cookie := New("alice", now.Add(time.Hour), secret, clock)
+	c, err := Parse(secret, cookie, clock)
+	if err != nil {
+		t.Fatalf("Parse with clock expected nil error, actual: %v", err)
+	}
+	if c.IssuedAt != now.Unix() {
+		t.Errorf("New with clock expected IssuedAt %v, actual: %v", now.Unix(), c.IssuedAt)
+	}
+	if _, err := Parse(secret, cookie); !errors.Is(err, ErrExpired) {
+		t.Errorf("Parse without clock of cookie expired in 2020 expected ErrExpired, actual: %v", err)
+	}
+
+	now = now.Add(2 * time.Hour)
+	if _, err := Parse(secret, cookie, clock); !errors.Is(err, ErrExpired) {
+		t.Errorf("Parse with clock after expiry expected ErrExpired, actual: %v", err)
+	}
+	refreshed, err := Parse(secret, Refresh(c, secret, clock), clock)
+	if err != nil || refreshed.IssuedAt != now.Unix() || refreshed.ExpiresUnix != now.Add(DefaultDuration).Unix() {
+		t.Errorf("Refresh with clock expected cookie issued now, actual: %+v %v", refreshed, err)
+	}
+}
+
+func TestWithLeeway(t *testing.T) {
+	secret := "secret"
+	now := time.Now()
+	clock := WithClock(func() time.Time { return now })
+	tests := map[string]struct {
+		cookie   string
+		leeway   time.Duration
+		expected error
+	}{
+		"expired":                  {New("alice", now.Add(-5*time.Second), secret), 0, ErrExpired},
+		"expired within leeway":    {New("alice", now.Add(-5*time.Second), secret), 10 * time.Second, nil},
+		"expired past leeway":      {New("alice", now.Add(-time.Minute), secret), 10 * time.Second, ErrExpired},
+		"not yet valid":            {New("alice", now.Add(time.Hour), secret, WithNotBefore(now.Add(5*time.Second))), 0, ErrNotYetValid},
+		"not before within leeway": {New("alice", now.Add(time.Hour), secret, WithNotBefore(now.Add(5*time.Second))), 10 * time.Second, nil},
+		"not before past leeway":   {New("alice", now.Add(time.Hour), secret, WithNotBefore(now.Add(time.Minute))), 10 * time.Second, ErrNotYetValid},
+	}
+	for name, test := range tests {
+		if _, err := Parse(secret, test.cookie, clock, WithLeeway(test.leeway)); !errors.Is(err, test.expected) || test.expected == nil && err != nil {
+			t.Errorf("%v: Parse expected %v, actual: %v", name, test.expected, err)
+		}
+	}
+
+	cfg := Config{Leeway: 10 * time.Second}
+	if _, err := Parse(secret, tests["expired within leeway"].cookie, append(cfg.Options(), clock)...); err != nil {
+		t.Errorf("Parse with Config leeway expected nil error, actual: %v", err)
+	}
+	if err := (Config{Leeway: -time.Second}).Validate(); err == nil {
+		t.Errorf("Config.Validate of negative leeway expected error, actual: nil")
+	}
+}
+
+func TestWithExpiryGrace(t *testing.T) {
+	secret := "secret"
+	now := time.Now()
+	clock := WithClock(func() time.Time { return now })
+	tests := map[string]struct {
+		cookie   string
+		opts     []Option
+		expected error
+		stale    bool
+	}{
+		"valid":                 {New("alice", now.Add(time.Minute), secret), []Option{WithExpiryGrace(time.Minute)}, nil, false},
+		"expired":               {New("alice", now.Add(-5*time.Second), secret), nil, ErrExpired, false},
+		"within grace":          {New("alice", now.Add(-5*time.Second), secret), []Option{WithExpiryGrace(10 * time.Second)}, nil, true},
+		"past grace":            {New("alice", now.Add(-time.Minute), secret), []Option{WithExpiryGrace(10 * time.Second)}, ErrExpired, false},
+		"within leeway":         {New("alice", now.Add(-5*time.Second), secret), []Option{WithLeeway(10 * time.Second), WithExpiryGrace(10 * time.Second)}, nil, false},
+		"within leeway + grace": {New("alice", now.Add(-15*time.Second), secret), []Option{WithLeeway(10 * time.Second), WithExpiryGrace(10 * time.Second)}, nil, true},
+		"session too old":       {New("alice", now.Add(-5*time.Second), secret, WithClock(func() time.Time { return now.Add(-2 * time.Hour) })), []Option{WithExpiryGrace(10 * time.Second), WithMaxLifetime(time.Hour)}, ErrSessionTooOld, false},
+	}
+	for name, test := range tests {
+		c, err := Parse(secret, test.cookie, append(test.opts, clock)...)
+		if !errors.Is(err, test.expected) || test.expected == nil && err != nil {
+			t.Errorf("%v: Parse expected %v, actual: %v", name, test.expected, err)
+			continue
+		}
+		if err == nil && c.Stale != test.stale {
+			t.Errorf("%v: Parse expected Stale %v, actual: %v", name, test.stale, c.Stale)
+		}
+	}
+
+	c, err := Parse(secret, New("alice", now.Add(-5*time.Second), secret), clock, WithExpiryGrace(10*time.Second))
+	if err != nil || !c.Stale {
+		t.Fatalf("Parse within grace expected Stale cookie, actual: %+v %v", c, err)
+	}
+	if refreshed, err := Parse(secret, Refresh(c, secret, clock), clock, WithExpiryGrace(10*time.Second)); err != nil || refreshed.Stale {
+		t.Errorf("Parse of refreshed Stale cookie expected fresh cookie, actual: %+v %v", refreshed, err)
+	}
+	c.ExpiresUnix = now.Add(time.Minute).Unix()
+	if err := Validate(c, clock, WithExpiryGrace(10*time.Second)); err != nil || c.Stale {
+		t.Errorf("Validate of extended cookie expected Stale reset, actual: %v %v", c.Stale, err)
+	}
+
+	cfg := Config{ExpiryGrace: 10 * time.Second}
+	if c, err := Parse(secret, tests["within grace"].cookie, append(cfg.Options(), clock)...); err != nil || !c.Stale {
+		t.Errorf("Parse with Config grace expected Stale cookie, actual: %v", err)
+	}
+	if err := (Config{ExpiryGrace: -time.Second}).Validate(); err == nil {
+		t.Errorf("Config.Validate of negative grace expected error, actual: nil")
+	}
+}
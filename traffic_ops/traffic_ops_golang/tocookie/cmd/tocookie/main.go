@@ -0,0 +1,238 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command tocookie mints, inspects, and verifies Traffic Ops cookies, for debugging cookies which the Perl and Go components disagree about.
+//
+//	tocookie new -secret-file secrets -user alice -duration 6h
+//	tocookie inspect 'eyJhdXRoX2RhdGEi...--8d2f...'
+//	tocookie verify -secret-file secrets < cookie.txt
+//
+// When verify finds the cookie isn't signed with any of the secrets, it prints their fingerprints; see tocookie.WithSignatureDiagnostics. A node whose fingerprint doesn't match those of the others has a different secret.
+//
+// Secrets are read from a file in the format of tocookie.FileKeyProvider, one per line, current first, so they don't appear in the process list or shell history. Cookies are given as an argument, or on standard input, with or without the "mojolicious=" of a Cookie header.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/tocookie"
+)
+
+const usage = `usage: tocookie <command> [flags] [cookie]
+
+commands:
+  new      mint a cookie for a user
+  inspect  decode and print a cookie, without verifying it
+  verify   verify a cookie against the secrets of a file
+
+Run 'tocookie <command> -h' for the flags of a command.
+`
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run runs the command of the arguments, returning the exit status: 0 on success, 1 if the cookie isn't valid, and 2 for usage errors.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+	commands := map[string]func([]string, io.Reader, io.Writer, io.Writer) int{
+		"new":     runNew,
+		"inspect": runInspect,
+		"verify":  runVerify,
+	}
+	command, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "unknown command '%s'\n%s", args[0], usage)
+		return 2
+	}
+	return command(args[1:], stdin, stdout, stderr)
+}
+
+func runNew(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("new", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	secretFile := flags.String("secret-file", "", "the file of secrets; the first signs the cookie")
+	user := flags.String("user", "", "the user of the cookie")
+	duration := flags.Duration("duration", tocookie.DefaultDuration, "how long the cookie is valid")
+	roles := flags.String("roles", "", "comma-separated roles of the cookie")
+	version := flags.Int("version", tocookie.Version0, "the format version of the cookie; 0 is readable by Perl Traffic Ops")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *secretFile == "" || *user == "" {
+		fmt.Fprintln(stderr, "new: -secret-file and -user are required")
+		return 2
+	}
+	keys, err := loadKeys(*secretFile)
+	if err != nil {
+		fmt.Fprintf(stderr, "new: %v\n", err)
+		return 2
+	}
+	_, secret := keys.Current()
+	opts := []tocookie.Option{tocookie.WithVersion(*version)}
+	if *roles != "" {
+		opts = append(opts, tocookie.WithRoles(strings.Split(*roles, ",")...))
+	}
+	cookie := tocookie.New(*user, time.Now().Add(*duration), string(secret), opts...)
+	if cookie == "" {
+		fmt.Fprintln(stderr, "new: the cookie couldn't be minted with these flags")
+		return 1
+	}
+	fmt.Fprintln(stdout, cookie)
+	return 0
+}
+
+func runInspect(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("inspect", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	cookie, err := readCookie(flags.Args(), stdin)
+	if err != nil {
+		fmt.Fprintf(stderr, "inspect: %v\n", err)
+		return 2
+	}
+	info, dumpErr := tocookie.Dump(cookie)
+	fmt.Fprintln(stdout, "NOT VERIFIED: nothing below may be trusted until the cookie is verified.")
+	printJSON(stdout, dumpOutput(info))
+	if dumpErr != nil {
+		fmt.Fprintf(stdout, "decoding failed: %v\n", dumpErr)
+		return 1
+	}
+	return 0
+}
+
+func runVerify(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("verify", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	secretFile := flags.String("secret-file", "", "the file of secrets the cookie may be signed with")
+	strict := flags.Bool("strict", false, "verify the cookie exactly as Mojolicious would; see tocookie.WithStrict")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *secretFile == "" {
+		fmt.Fprintln(stderr, "verify: -secret-file is required")
+		return 2
+	}
+	keys, err := loadKeys(*secretFile)
+	if err != nil {
+		fmt.Fprintf(stderr, "verify: %v\n", err)
+		return 2
+	}
+	cookie, err := readCookie(flags.Args(), stdin)
+	if err != nil {
+		fmt.Fprintf(stderr, "verify: %v\n", err)
+		return 2
+	}
+	opts := []tocookie.Option{tocookie.WithSignatureDiagnostics()}
+	if *strict {
+		opts = append(opts, tocookie.WithStrict())
+	}
+
+	// each secret is tried in turn, so the output says which one signed the cookie, e.g. a previous secret one component hasn't been given.
+	err = tocookie.ErrBadSignature
+	mismatches := []string{}
+	for i, id := range keys.IDs() {
+		secret, _ := keys.Get(id)
+		var c *tocookie.Cookie
+		c, err = tocookie.Parse(string(secret), cookie, opts...)
+		if sigErr := (*tocookie.SignatureError)(nil); errors.As(err, &sigErr) {
+			mismatches = append(mismatches, fmt.Sprintf("  secret %d (key ID %s): %v", i+1, id, sigErr))
+		}
+		if errors.Is(err, tocookie.ErrBadSignature) {
+			continue
+		}
+		if err == nil || errors.Is(err, tocookie.ErrExpired) {
+			fmt.Fprintf(stdout, "signature: valid, with secret %d of the file (key ID %s)\n", i+1, id)
+		}
+		if err != nil {
+			break
+		}
+		fmt.Fprintln(stdout, "claims: valid")
+		printJSON(stdout, c)
+		return 0
+	}
+	fmt.Fprintf(stdout, "invalid (%s, %s): %v\n", tocookie.Classify(err), tocookie.FailureReason(err), err)
+	if errors.Is(err, tocookie.ErrBadSignature) {
+		fmt.Fprintln(stdout, "the cookie isn't signed with any secret of the file; run 'tocookie inspect' to see its parts")
+		for _, mismatch := range mismatches {
+			fmt.Fprintln(stdout, mismatch)
+		}
+	}
+	return 1
+}
+
+// loadKeys reads the secrets of the file.
+func loadKeys(path string) (tocookie.KeySet, error) {
+	p, err := tocookie.NewFileKeyProvider(path)
+	if err != nil {
+		return tocookie.KeySet{}, err
+	}
+	defer p.Close()
+	return p.Keys(), nil
+}
+
+// readCookie returns the cookie of the arguments, or of stdin if there are none, without any surrounding whitespace, quotes, or cookie name.
+func readCookie(args []string, stdin io.Reader) (string, error) {
+	cookie := ""
+	switch len(args) {
+	case 0:
+		data, err := io.ReadAll(io.LimitReader(stdin, 1<<20))
+		if err != nil {
+			return "", err
+		}
+		cookie = string(data)
+	case 1:
+		cookie = args[0]
+	default:
+		return "", errors.New("expected one cookie")
+	}
+	cookie = strings.TrimSpace(cookie)
+	cookie = strings.TrimPrefix(cookie, tocookie.Name+"=")
+	cookie = strings.Trim(cookie, `"`)
+	if cookie == "" {
+		return "", errors.New("no cookie given")
+	}
+	return cookie, nil
+}
+
+// dumpOutput is the DumpInfo of inspect, with the name of its hash.
+func dumpOutput(info tocookie.DumpInfo) interface{} {
+	hash := ""
+	if info.Hash != 0 {
+		hash = info.Hash.String()
+	}
+	return struct {
+		tocookie.DumpInfo
+		Hash string `json:"Hash,omitempty"`
+	}{info, hash}
+}
+
+func printJSON(w io.Writer, v interface{}) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		fmt.Fprintf(w, "%+v\n", v)
+		return
+	}
+	fmt.Fprintln(w, string(data))
+}
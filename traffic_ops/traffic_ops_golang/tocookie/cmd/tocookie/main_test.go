@@ -0,0 +1,75 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/tocookie"
+)
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	secrets := filepath.Join(dir, "secrets")
+	if err := os.WriteFile(secrets, []byte("current\n# retired last week\nprevious\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	other := filepath.Join(dir, "other")
+	if err := os.WriteFile(other, []byte("other\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	runOut := func(stdin string, args ...string) (int, string) {
+		out := &bytes.Buffer{}
+		status := run(args, strings.NewReader(stdin), out, out)
+		return status, out.String()
+	}
+
+	status, cookie := runOut("", "new", "-secret-file", secrets, "-user", "alice", "-roles", "admin,ops")
+	cookie = strings.TrimSpace(cookie)
+	if c, err := tocookie.Parse("current", cookie); status != 0 || err != nil || c.AuthData != "alice" || !c.HasRole("ops") {
+		t.Fatalf("new expected cookie of alice, actual: %v %v %v", status, cookie, err)
+	}
+
+	previous := tocookie.New("bob", time.Now().Add(time.Hour), "previous")
+	expired := tocookie.New("carol", time.Now().Add(-time.Hour), "current")
+	tests := map[string]struct {
+		stdin    string
+		args     []string
+		status   int
+		expected string
+	}{
+		"verify":           {"", []string{"verify", "-secret-file", secrets, cookie}, 0, "secret 1 of the file"},
+		"verify stdin":     {"mojolicious=" + previous + "\n", []string{"verify", "-secret-file", secrets}, 0, "secret 2 of the file"},
+		"verify expired":   {"", []string{"verify", "-secret-file", secrets, expired}, 1, "invalid (expired, expired)"},
+		"verify bad":       {"", []string{"verify", "-secret-file", other, cookie}, 1, "isn't signed with any secret"},
+		"verify no secret": {"", []string{"verify", cookie}, 2, "-secret-file is required"},
+		"verify mismatch":  {"", []string{"verify", "-secret-file", other, cookie}, 1, "verified with key " + tocookie.SecretFingerprint("other")},
+		"inspect":          {"", []string{"inspect", cookie}, 0, `"auth_data": "alice"`},
+		"inspect bad":      {"", []string{"inspect", "garbage"}, 1, "decoding failed"},
+		"inspect empty":    {"", []string{"inspect"}, 2, "no cookie given"},
+		"new no user":      {"", []string{"new", "-secret-file", secrets}, 2, "-user are required"},
+		"unknown":          {"", []string{"mint"}, 2, "unknown command"},
+		"none":             {"", nil, 2, "usage"},
+	}
+	for name, test := range tests {
+		status, out := runOut(test.stdin, test.args...)
+		if status != test.status || !strings.Contains(out, test.expected) {
+			t.Errorf("%v: expected status %v and output containing '%v', actual: %v %v", name, test.status, test.expected, status, out)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sync"
+)
+
+// DefaultMaxDecompressedSize is the largest payload, in bytes, Parse decompresses by default. Cookies are limited to about 4KB by browsers, so this is generous, while bounding the memory a decompression bomb can consume.
+const DefaultMaxDecompressedSize = 64 * 1024
+
+// The names of the built-in codecs.
+const (
+	// CodecGzip compresses payloads with gzip (RFC 1952).
+	CodecGzip = "gzip"
+	// CodecDeflate compresses payloads with raw DEFLATE (RFC 1951), which is gzip without its 18 byte header and trailer, so it makes the smallest cookies of the built-in codecs.
+	CodecDeflate = "deflate"
+)
+
+// Codec compresses cookie payloads. Codecs are registered with RegisterCodec, and referred to by name in the cookie, so Parse can pick the right decompressor.
+type Codec interface {
+	// Name returns the name the codec is registered and identified in cookies by. It must be stable, as it is part of the cookie format.
+	Name() string
+	NewWriter(w io.Writer) (io.WriteCloser, error)
+	NewReader(r io.Reader) (io.ReadCloser, error)
+}
+
+var codecsMutex sync.RWMutex
+var codecs = map[string]Codec{CodecGzip: gzipCodec{}, CodecDeflate: deflateCodec{}}
+
+// RegisterCodec makes a Codec available to WithCompression and Parse. Codecs with external dependencies live in subpackages which register themselves when imported, e.g. tocookie/zstdcodec and tocookie/brotlicodec. Registering a codec with the name of an existing one replaces it.
+func RegisterCodec(codec Codec) {
+	codecsMutex.Lock()
+	defer codecsMutex.Unlock()
+	codecs[codec.Name()] = codec
+}
+
+func getCodec(name string) (Codec, error) {
+	codecsMutex.RLock()
+	defer codecsMutex.RUnlock()
+	codec, ok := codecs[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown compression codec '%s'", name)
+	}
+	return codec, nil
+}
+
+// WithCompression makes New compress the cookie payload with the named Codec, e.g. CodecGzip, which shrinks cookies with large claim sets. Compressed cookies are minted in Version2, whose header names the codec, so Parse needs no option to read them. New returns an empty string if the codec isn't registered.
+func WithCompression(codec string) Option {
+	return func(o *options) { o.compression = codec }
+}
+
+// WithCompressionThreshold makes New and Refresh only compress payloads of at least size bytes with the codec of WithCompression, and only if compressing them makes them smaller, so small sessions aren't made larger by the overhead of the codec. Cookies are minted in Version2 whether or not their payload is compressed, so the format of a session's cookies doesn't change as its claims grow. By default, payloads are always compressed.
+func WithCompressionThreshold(size int) Option {
+	return func(o *options) { o.compressionThreshold = size }
+}
+
+// compressPayload returns the payload compressed with the configured codec, and the name of the codec, or the payload and an empty name if it is below the threshold of WithCompressionThreshold or compressing it wouldn't make it smaller.
+func (o *options) compressPayload(msg []byte) ([]byte, string, error) {
+	if o.compressionThreshold > 0 && len(msg) < o.compressionThreshold {
+		return msg, "", nil
+	}
+	compressed, err := compress(o.compression, msg)
+	if err != nil {
+		return nil, "", err
+	}
+	if o.compressionThreshold > 0 && len(compressed) >= len(msg) {
+		return msg, "", nil
+	}
+	return compressed, o.compression, nil
+}
+
+// WithMaxDecompressedSize sets the largest payload, in bytes, Parse decompresses. Larger payloads are rejected. The default is DefaultMaxDecompressedSize.
+func WithMaxDecompressedSize(size int64) Option {
+	return func(o *options) { o.maxDecompressedSize = size }
+}
+
+func compress(codecName string, msg []byte) ([]byte, error) {
+	codec, err := getCodec(codecName)
+	if err != nil {
+		return nil, err
+	}
+	buf := bytes.Buffer{}
+	w, err := codec.NewWriter(&buf)
+	if err != nil {
+		return nil, fmt.Errorf("compressing payload: %w", err)
+	}
+	if _, err := w.Write(msg); err != nil {
+		w.Close()
+		return nil, fmt.Errorf("compressing payload: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("compressing payload: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// decompress decompresses the payload, returning an error if the decompressed payload would be larger than maxSize.
+func decompress(codecName string, compressed []byte, maxSize int64) ([]byte, error) {
+	codec, err := getCodec(codecName)
+	if err != nil {
+		return nil, err
+	}
+	r, err := codec.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, fmt.Errorf("decompressing payload: %w", err)
+	}
+	defer r.Close()
+	msg, err := ioutil.ReadAll(io.LimitReader(r, maxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("decompressing payload: %w", err)
+	}
+	if int64(len(msg)) > maxSize {
+		return nil, fmt.Errorf("decompressed payload larger than %d bytes", maxSize)
+	}
+	return msg, nil
+}
+
+type gzipCodec struct{}
+
+func (gzipCodec) Name() string { return CodecGzip }
+
+func (gzipCodec) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return gzip.NewWriterLevel(w, gzip.BestCompression)
+}
+
+func (gzipCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
+
+type deflateCodec struct{}
+
+func (deflateCodec) Name() string { return CodecDeflate }
+
+func (deflateCodec) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return flate.NewWriter(w, flate.BestCompression)
+}
+
+func (deflateCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return flate.NewReader(r), nil
+}
@@ -0,0 +1,130 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"bytes"
+	"crypto/rand"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWithCompression(t *testing.T) {
+	secret := "secret"
+	user := strings.Repeat("alice", 200)
+	cookie := New(user, time.Now().Add(time.Minute), secret, WithCompression(CodecGzip))
+	if !strings.HasPrefix(cookie, "v2.") {
+		t.Fatalf("New with compression expected version 2 cookie, actual: '%v'", cookie)
+	}
+	if uncompressed := New(user, time.Now().Add(time.Minute), secret); len(cookie) >= len(uncompressed) {
+		t.Errorf("New with compression expected shorter than %v, actual: %v", len(uncompressed), len(cookie))
+	}
+
+	c, err := Parse(secret, cookie)
+	if err != nil {
+		t.Fatalf("Parse expected nil error, actual: %v", err)
+	}
+	if c.AuthData != user {
+		t.Errorf("Parse expected AuthData to round-trip, actual: '%v'", c.AuthData)
+	}
+
+	if _, err := Parse(secret, cookie, WithMaxDecompressedSize(100)); err == nil {
+		t.Errorf("Parse over max decompressed size expected error, actual nil")
+	}
+	if cookie := New(user, time.Now().Add(time.Minute), secret, WithCompression("nonexistent")); cookie != "" {
+		t.Errorf("New with unknown codec expected empty cookie, actual: '%v'", cookie)
+	}
+}
+
+func TestDeflateCodec(t *testing.T) {
+	secret := "secret"
+	roles := make([]string, 100)
+	for i := range roles {
+		roles[i] = "role-" + strings.Repeat("x", i%7)
+	}
+	deflated := New("alice", time.Now().Add(time.Minute), secret, WithRoles(roles...), WithCompression(CodecDeflate))
+	gzipped := New("alice", time.Now().Add(time.Minute), secret, WithRoles(roles...), WithCompression(CodecGzip))
+	if len(deflated) >= len(gzipped) {
+		t.Errorf("New with deflate expected shorter than gzip's %v, actual: %v", len(gzipped), len(deflated))
+	}
+	c, err := Parse(secret, deflated)
+	if err != nil {
+		t.Fatalf("Parse expected nil error, actual: %v", err)
+	}
+	if len(c.Roles) != len(roles) {
+		t.Errorf("Parse expected %v roles, actual: %v", len(roles), len(c.Roles))
+	}
+
+	bomb := New(strings.Repeat("a", 1<<20), time.Now().Add(time.Minute), secret, WithCompression(CodecDeflate), WithMaxClaimsSize(1<<21))
+	if len(bomb) > 4096 {
+		t.Fatalf("New of compressible payload expected cookie under 4096 bytes, actual: %v", len(bomb))
+	}
+	if _, err := Parse(secret, bomb, WithMaxCookieSize(8192)); err == nil {
+		t.Errorf("Parse of decompression bomb expected error, actual nil")
+	}
+}
+
+func TestWithCompressionThreshold(t *testing.T) {
+	secret := "secret"
+	tests := map[string]struct {
+		user       string
+		threshold  int
+		compressed bool
+	}{
+		"below threshold":  {"alice", 512, false},
+		"above threshold":  {strings.Repeat("alice", 200), 512, true},
+		"always":           {"alice", 0, true},
+		"always but small": {"a", 0, true},
+	}
+	for name, test := range tests {
+		cookie := New(test.user, time.Now().Add(time.Minute), secret, WithCompression(CodecDeflate), WithCompressionThreshold(test.threshold))
+		info, err := Dump(cookie)
+		if err != nil {
+			t.Fatalf("%v: Dump expected nil error, actual: %v", name, err)
+		}
+		if actual := info.Compression != ""; actual != test.compressed {
+			t.Errorf("%v: New expected compressed %v, actual: %v", name, test.compressed, info.Compression)
+		}
+		if c, err := Parse(secret, cookie); err != nil || c.AuthData != test.user {
+			t.Errorf("%v: Parse expected %v, actual: %v, %v", name, test.user, c, err)
+		}
+	}
+}
+
+func TestCompressPayloadIncompressible(t *testing.T) {
+	msg := make([]byte, 256)
+	if _, err := rand.Read(msg); err != nil {
+		t.Fatalf("rand.Read expected nil error, actual: %v", err)
+	}
+	o := newOptions([]Option{WithCompression(CodecDeflate), WithCompressionThreshold(1)})
+	if payload, codec, err := o.compressPayload(msg); err != nil || codec != "" || !bytes.Equal(payload, msg) {
+		t.Errorf("compressPayload of incompressible payload expected it uncompressed, actual: codec '%v', error %v", codec, err)
+	}
+}
+
+func TestParseV2Rejects(t *testing.T) {
+	secret := "secret"
+	cookie := New("alice", time.Now().Add(time.Minute), secret, WithCompression(CodecGzip))
+	parts := strings.SplitN(cookie, ".", 3)
+	tests := map[string]string{
+		"no header":      "v2." + parts[2],
+		"unknown header": "v2.eyJ4eiI6MX0." + parts[2],
+		"tampered":       "v2.e30." + parts[2],
+	}
+	for name, cookie := range tests {
+		if _, err := Parse(secret, cookie); err == nil {
+			t.Errorf("%v: Parse expected error, actual nil", name)
+		}
+	}
+}
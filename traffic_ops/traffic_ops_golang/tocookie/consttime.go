@@ -0,0 +1,26 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"crypto/subtle"
+)
+
+// constantTimeEqual compares strings in constant time, for comparisons involving secrets.
+//
+// The comparisons of Parse which involve secrets are constant-time: the signature is compared with hmac.Equal by tagMatches (and by VerifyRawMsgStream), and fingerprints, which are derived from client attributes the client may not know, with constantTimeEqual.
+//
+// The other comparisons only involve values whoever presents the cookie already knows, so they needn't be: the structure and version of the cookie, the length of its tag, which is checked against fixed bounds before the tags are compared, and claims such as the audience and issuer, which are readable in the cookie. Rejecting a cookie early for these reveals nothing about the key or a valid signature. The signature is verified before the payload is decoded or validated, so the time taken to reject a forged cookie doesn't depend on its payload.
+func constantTimeEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
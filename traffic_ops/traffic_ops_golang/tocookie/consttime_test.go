@@ -0,0 +1,32 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"testing"
+)
+
+func TestConstantTimeEqual(t *testing.T) {
+	tests := map[[2]string]bool{
+		{"", ""}:       true,
+		{"abc", "abc"}: true,
+		{"abc", "abd"}: false,
+		{"abc", "ab"}:  false,
+		{"", "a"}:      false,
+	}
+	for strs, expected := range tests {
+		if actual := constantTimeEqual(strs[0], strs[1]); actual != expected {
+			t.Errorf("constantTimeEqual(%q, %q) expected %v, actual: %v", strs[0], strs[1], expected, actual)
+		}
+	}
+}
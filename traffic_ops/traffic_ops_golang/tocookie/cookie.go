@@ -13,11 +13,13 @@
 package tocookie
 
 import (
+	"bytes"
+	"context"
 	"crypto/hmac"
-	"crypto/sha1"
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
@@ -31,75 +33,535 @@ type Cookie struct {
 	AuthData    string `json:"auth_data"`
 	ExpiresUnix int64  `json:"expires"`
 	By          string `json:"by"`
+	JTI         string `json:"jti,omitempty"`
+	Fingerprint string `json:"fp,omitempty"`
+	NotBefore   int64  `json:"nbf,omitempty"`
+	Audience    string `json:"aud,omitempty"`
+
+	// ExpiresMillis is the expiration in milliseconds since the Unix epoch. It is only set on cookies minted WithMillisecondExpiry, and takes precedence over ExpiresUnix, which is then the expiration truncated to whole seconds for consumers that only understand seconds.
+	ExpiresMillis int64 `json:"expires_ms,omitempty"`
+
+	// ExpiresRFC3339 is the expiration as an RFC3339 UTC timestamp, for consumers which can't read Unix timestamps. It is only set on cookies minted WithRFC3339Expiry, and is always derived from the integer expiration, which takes precedence. It is only used by Parse if the cookie has no integer expiration.
+	ExpiresRFC3339 string `json:"expires_at,omitempty"`
+
+	// FailedAttempts counts failed authentication attempts, e.g. of a step-up challenge, so stateless front-ends can enforce progressive lockout. It is only as trustworthy as the signature, and is preserved by Refresh.
+	FailedAttempts int `json:"failed_attempts,omitempty"`
+
+	// IssuedAt is when this cookie was minted, in seconds since the Unix epoch. It is set by New, and updated by every Refresh, so it tracks cookie rotation. It is zero for cookies minted before it was introduced.
+	IssuedAt int64 `json:"iat,omitempty"`
+
+	// SessionStart is when the session began, in seconds since the Unix epoch. It is set by New and preserved by Refresh, so it gives the true age of a session however often it is refreshed; see SessionAge and WithMaxLifetime. It is zero for cookies minted before it was introduced.
+	SessionStart int64 `json:"session_start,omitempty"`
+
+	// AuthTime is when the user last authenticated with their credentials, in seconds since the Unix epoch, as OpenID Connect's auth_time, if that was before SessionStart, e.g. of a session restored by a remember-me cookie; see WithAuthTime. It is zero when the session began with the authentication, and is preserved by Refresh, so AuthAge, and WithMaxAuthAge, measure from the credentials however the session was started.
+	AuthTime int64 `json:"auth_time,omitempty"`
+
+	// AuthMethods are the methods the user authenticated with, such as AuthMethodPassword, as the "amr" claim of OpenID Connect. They are set WithAuthMethods, extended by StepUp, and preserved by Refresh.
+	AuthMethods []string `json:"amr,omitempty"`
+
+	// ACR is the AuthLevel of the authentication as a decimal string, as the "acr" claim of OpenID Connect; see AuthLevel and RequireAuthLevel. It is set WithAuthMethods or WithAuthLevel, raised by StepUp, and preserved by Refresh.
+	ACR string `json:"acr,omitempty"`
+
+	// StepUpTime is when the user last authenticated again by StepUp, in seconds since the Unix epoch, which AuthLevelAge measures from. It is zero for sessions which haven't stepped up, and is preserved by Refresh.
+	StepUpTime int64 `json:"step_up_time,omitempty"`
+
+	// SessionID identifies the session, so it can be revoked; see WithRevocationStore. It is set to a random ID by New, and preserved by Refresh. It is empty for cookies minted before it was introduced, and by Perl Traffic Ops.
+	SessionID string `json:"sid,omitempty"`
+
+	// Generation counts the refreshes of the session under WithRotation, which rejects cookies of older generations. It is zero for sessions which haven't been rotated.
+	Generation int64 `json:"gen,omitempty"`
+
+	// Roles are the roles of the user, for authorization checks with HasRole and HasAnyRole. They are set WithRoles, and preserved by Refresh.
+	Roles []string `json:"roles,omitempty"`
+
+	// Capabilities are the capabilities of the user, for authorization checks with HasCapability and RequireCapability. They are set WithCapabilities, and preserved by Refresh. Cookies minted WithCapabilityTable store the capabilities in the table in CapabilityMask, which Parse given the same table expands back into Capabilities.
+	Capabilities []string `json:"caps,omitempty"`
+
+	// CapabilityMask is the compact encoding of the capabilities in the table of WithCapabilityTable. It is only set on cookies parsed without the table, which can't be expanded, or with bits beyond the end of the table.
+	CapabilityMask string `json:"capm,omitempty"`
+
+	// Actor is the user acting on behalf of AuthData, in the style of the RFC 8693 "act" claim. It is only set on cookies minted by NewImpersonation, and is preserved by Refresh; see ActingUser and EffectiveUser.
+	Actor *Actor `json:"act,omitempty"`
+
+	// Tenancy is the tenant of the user, set WithTenant or WithTenantHash, and preserved by Refresh; see TenantID and RequireTenantAncestor. It is named "tenancy" in the payload, so it doesn't collide with custom "tenant" claims.
+	Tenancy *Tenancy `json:"tenancy,omitempty"`
+
+	// Stale is whether the cookie has expired, and was only accepted within the grace period of WithExpiryGrace, so it should be refreshed. It is set by Parse and Validate, and isn't a claim.
+	Stale bool `json:"-"`
+
+	// Extra holds the keys of the payload which aren't claims of this package, such as the flash and new_flash keys of Mojolicious sessions, or custom claims set WithClaims, as raw JSON. They are written back as they were read, so Refresh doesn't strip session data belonging to other consumers of the cookie. Custom claims are read with Claim and its typed variants.
+	Extra map[string]json.RawMessage `json:"-"`
+
+	// payload is the decoded payload the cookie was read from, if it was read by Parse, ParseUnverified, or Dump.
+	payload []byte
+}
+
+// RawPayload returns the exact JSON the cookie was decoded from by Parse, ParseUnverified, or Dump: the payload after base64 decoding and decompression, and before unmarshalling, including keys in their original order and escaping. It is nil for cookies which weren't decoded, and isn't updated when the cookie is modified. The bytes must not be modified.
+func (c *Cookie) RawPayload() []byte {
+	return c.payload
+}
+
+// Expires returns the expiration time of the cookie, to millisecond precision if the cookie has ExpiresMillis.
+func (c *Cookie) Expires() time.Time {
+	if c.ExpiresMillis != 0 {
+		return time.Unix(0, c.ExpiresMillis*int64(time.Millisecond))
+	}
+	if c.ExpiresUnix == 0 && c.ExpiresRFC3339 != "" {
+		if t, err := time.Parse(time.RFC3339Nano, c.ExpiresRFC3339); err == nil {
+			return t
+		}
+	}
+	return time.Unix(c.ExpiresUnix, 0)
+}
+
+// TimeLeft returns the duration until the cookie expires, relative to now. It is negative if the cookie has already expired.
+func (c *Cookie) TimeLeft(now time.Time) time.Duration {
+	return c.Expires().Sub(now)
+}
+
+// Equal returns whether two cookies represent the same session. It compares the identity fields AuthData, By, JTI, Fingerprint, Audience, and the Subject of the Actor, and the custom claims of Extra, as JSON, but for the Mojolicious session data in it, and ignores the time fields ExpiresUnix, ExpiresMillis, ExpiresRFC3339, NotBefore, IssuedAt, SessionStart, AuthTime, and StepUpTime, as well as SessionID, AuthMethods, ACR, Generation, FailedAttempts, Roles, Capabilities, CapabilityMask, Tenancy, Stale, and the RawPayload, so a refreshed cookie is Equal to the cookie it was refreshed from, and one whose custom claims, such as a tenant, changed isn't. Two nil cookies are Equal.
+func (c *Cookie) Equal(other *Cookie) bool {
+	if c == nil || other == nil {
+		return c == other
+	}
+	return c.AuthData == other.AuthData &&
+		c.By == other.By &&
+		c.JTI == other.JTI &&
+		c.Fingerprint == other.Fingerprint &&
+		c.Audience == other.Audience &&
+		c.ActingUser() == other.ActingUser() &&
+		equalClaims(c.Extra, other.Extra)
+}
+
+// mojoliciousSessionKeys are the keys of Extra Mojolicious keeps its own session data in, which change from request to request without changing the session: its flash messages, and the expiration it was given.
+var mojoliciousSessionKeys = map[string]struct{}{"flash": {}, "new_flash": {}, "expiration": {}}
+
+// equalClaims returns whether the custom claims are the same JSON, ignoring whitespace and the keys of mojoliciousSessionKeys.
+func equalClaims(a, b map[string]json.RawMessage) bool {
+	return containsClaims(a, b) && containsClaims(b, a)
+}
+
+// containsClaims returns whether every custom claim of a is in b, as the same JSON.
+func containsClaims(a, b map[string]json.RawMessage) bool {
+	for name, val := range a {
+		if _, ok := mojoliciousSessionKeys[name]; ok {
+			continue
+		}
+		otherVal, ok := b[name]
+		if !ok {
+			return false
+		}
+		if bytes.Equal(val, otherVal) {
+			continue
+		}
+		compact, otherCompact := bytes.Buffer{}, bytes.Buffer{}
+		if json.Compact(&compact, val) != nil || json.Compact(&otherCompact, otherVal) != nil || !bytes.Equal(compact.Bytes(), otherCompact.Bytes()) {
+			return false
+		}
+	}
+	return true
 }
 
-func checkHmac(message, messageMAC, key []byte) bool {
-	mac := hmac.New(sha1.New, key)
+// Clone returns a deep copy of the cookie, which shares no memory with it, so either may be modified without affecting the other. The clone of nil is nil.
+func (c *Cookie) Clone() *Cookie {
+	if c == nil {
+		return nil
+	}
+	clone := *c
+	if c.Roles != nil {
+		clone.Roles = append([]string(nil), c.Roles...)
+	}
+	if c.Capabilities != nil {
+		clone.Capabilities = append([]string(nil), c.Capabilities...)
+	}
+	if c.AuthMethods != nil {
+		clone.AuthMethods = append([]string(nil), c.AuthMethods...)
+	}
+	if c.Actor != nil {
+		actor := *c.Actor
+		clone.Actor = &actor
+	}
+	if c.Tenancy != nil {
+		tenancy := *c.Tenancy
+		tenancy.Subtree = append([]int(nil), c.Tenancy.Subtree...)
+		clone.Tenancy = &tenancy
+	}
+	if c.payload != nil {
+		clone.payload = append([]byte(nil), c.payload...)
+	}
+	if c.Extra != nil {
+		clone.Extra = make(map[string]json.RawMessage, len(c.Extra))
+		for name, val := range c.Extra {
+			clone.Extra[name] = append(json.RawMessage(nil), val...)
+		}
+	}
+	return &clone
+}
+
+// SessionAge returns how long ago the session began, relative to now. Cookies minted before SessionStart was introduced use IssuedAt, which was the session start at the time. It is zero if the cookie has neither.
+func (c *Cookie) SessionAge(now time.Time) time.Duration {
+	start := c.sessionStart()
+	if start == 0 {
+		return 0
+	}
+	return now.Sub(time.Unix(start, 0))
+}
+
+// AuthAge returns how long ago the user last authenticated with their credentials, relative to now: since AuthTime, or the session start if the session began with the authentication. It is zero if the cookie has no session start.
+func (c *Cookie) AuthAge(now time.Time) time.Duration {
+	start := c.authTime()
+	if start == 0 {
+		return 0
+	}
+	return now.Sub(time.Unix(start, 0))
+}
+
+// authTime returns AuthTime, or the session start for sessions which began with the authentication.
+func (c *Cookie) authTime() int64 {
+	if c.AuthTime != 0 {
+		return c.AuthTime
+	}
+	return c.sessionStart()
+}
+
+// sessionStart returns SessionStart, or IssuedAt for cookies minted before SessionStart was introduced.
+func (c *Cookie) sessionStart() int64 {
+	if c.SessionStart != 0 {
+		return c.SessionStart
+	}
+	return c.IssuedAt
+}
+
+// expired returns whether the cookie expired before now, at the precision of the cookie's expiration.
+func (c *Cookie) expired(now time.Time) bool {
+	if c.ExpiresMillis != 0 {
+		return c.ExpiresMillis < now.UnixNano()/int64(time.Millisecond)
+	}
+	if c.ExpiresUnix == 0 && c.ExpiresRFC3339 != "" {
+		return c.Expires().Before(now)
+	}
+	return c.ExpiresUnix-now.Unix() < 0
+}
+
+// IsExpiringSoon returns whether the cookie expires within the given duration of now. It returns false if the cookie has already expired.
+func (c *Cookie) IsExpiringSoon(within time.Duration, now time.Time) bool {
+	left := c.TimeLeft(now)
+	return left >= 0 && left <= within
+}
+
+// checkHmac returns whether messageMAC is a valid, possibly truncated, tag of the message.
+func checkHmac(message, messageMAC, key []byte, o *options) bool {
+	mac := o.newMAC(key)
 	mac.Write(message)
-	expectedMAC := mac.Sum(nil)
-	return hmac.Equal(messageMAC, expectedMAC)
+	return o.tagMatches(messageMAC, mac.Sum(nil))
+}
+
+// tagMatches returns whether messageMAC is a valid, possibly truncated, tag, given the full expected tag. The tag is compared in constant time; its length isn't secret, so out-of-bounds tags are rejected before comparing.
+func (o *options) tagMatches(messageMAC, expectedMAC []byte) bool {
+	if len(messageMAC) < o.minTagLength(len(expectedMAC)) || len(messageMAC) > len(expectedMAC) {
+		return false
+	}
+	return hmac.Equal(messageMAC, expectedMAC[:len(messageMAC)])
 }
 
-func Parse(secret, cookie string) (*Cookie, error) {
+// splitV0 splits a version 0 cookie into its signed bytes, payload, and signature.
+func splitV0(cookie string) (signedCookie, error) {
 	dashPos := strings.Index(cookie, "-")
 	if dashPos == -1 {
-		return nil, fmt.Errorf("malformed cookie '%s' - no dashes", cookie)
+		return signedCookie{}, fmt.Errorf("%w '%s' - no dashes", ErrMalformed, cookie)
 	}
 
 	lastDashPos := strings.LastIndex(cookie, "-")
 	if lastDashPos == -1 {
-		return nil, fmt.Errorf("malformed cookie '%s' - no dashes", cookie)
+		return signedCookie{}, fmt.Errorf("%w '%s' - no dashes", ErrMalformed, cookie)
 	}
 
-	if len(cookie) < lastDashPos+1 {
-		return nil, fmt.Errorf("malformed cookie '%s' -- no signature", cookie)
+	if lastDashPos == 0 || len(cookie) < lastDashPos+1 {
+		return signedCookie{}, fmt.Errorf("%w '%s' -- no signature", ErrMalformed, cookie)
 	}
 
 	base64Txt := cookie[:dashPos]
-	txtBytes, err := base64.RawURLEncoding.DecodeString(base64Txt)
-	if err != nil {
-		return nil, fmt.Errorf("error decoding base64 data: %v", err)
-	}
 	base64TxtSig := cookie[:lastDashPos-1] // the signature signs the base64 including trailing hyphens, but the Go base64 decoder doesn't want the trailing hyphens.
 
 	base64Sig := cookie[lastDashPos+1:]
 	sigBytes, err := hex.DecodeString(base64Sig)
 	if err != nil {
-		return nil, fmt.Errorf("error decoding signature: %v", err)
+		return signedCookie{}, fmt.Errorf("%w: error decoding signature: %w", ErrMalformed, err)
+	}
+	return signedCookie{version: Version0, signed: base64TxtSig, payload: base64Txt, sig: sigBytes}, nil
+}
+
+// Parse verifies and decodes a cookie, and validates its claims. If the cookie is authentic and valid in every respect but having expired, its claims are returned along with an ExpiredError, which matches ErrExpired, so callers may re-issue it; its nonce isn't consumed. Otherwise, if err is non-nil, the returned cookie is nil.
+//
+// Errors for cookies which aren't authentic wrap ErrBadSignature or ErrMalformed; see IsAuthFailure and Classify.
+//
+// The hooks of WithHooks run last, with a background context; use ParseContext to bound and cancel them, and to trace Parse as part of a request with WithTracer.
+//
+// Cookies which can't be authentic by their shape, such as those without a hex signature of the right length, or longer than WithMaxCookieSize, are rejected before anything is decoded. The signature is verified before the payload is decoded, and the claims only unmarshalled once it is, so forged cookies never reach the JSON decoder, and authentic payloads only if they are within WithMaxPayloadSize and WithMaxJSONDepth. See BenchmarkParseMalformed, FuzzParse, and Parser for services which parse cookies at high rates.
+func Parse(secret, cookie string, opts ...Option) (*Cookie, error) {
+	o := newOptions(opts)
+	start := o.startTimer()
+	ctx, span := o.startSpan(context.Background(), SpanParse)
+	c, err := parseContext(ctx, secret, cookie, o)
+	o.endParseSpan(span, c, err)
+	o.observe(start, c, err)
+	return c, err
+}
+
+func parse(secret, cookie string, o *options) (*Cookie, error) {
+	if err := o.checkSecret(secret); err != nil {
+		return nil, err
+	}
+	if err := o.precheck(cookie); err != nil {
+		return nil, err
+	}
+	if !o.perUserKeys && o.macer == nil && o.publicKey == nil {
+		s, err := splitCookie(cookie, o)
+		if err != nil {
+			return nil, err
+		}
+		if err := o.checkHash(); err != nil {
+			return nil, err
+		}
+		key := []byte(secret)
+		bufs := parseBufferPool.Get().(*parseBuffers)
+		c, err := parseHMAC(s, key, hmac.New(o.hash.New, o.macKey(key)), bufs, true, o)
+		parseBufferPool.Put(bufs)
+		return c, err
+	}
+
+	user := ""
+	if o.perUserKeys {
+		unverified, err := decodeUnverified(cookie, o)
+		if err != nil {
+			return nil, err
+		}
+		user = unverified.AuthData
+	}
+
+	txtBytes, err := decodeSigned(cookie, o.signingKey([]byte(secret), user), o)
+	if err != nil {
+		return nil, err
 	}
 
-	if !checkHmac([]byte(base64TxtSig), sigBytes, []byte(secret)) {
-		return nil, fmt.Errorf("bad signature")
+	cookieData, err := decodeClaims(txtBytes, o)
+	if err != nil {
+		return nil, err
 	}
 
-	cookieData := Cookie{}
-	if err := json.Unmarshal(txtBytes, &cookieData); err != nil {
-		return nil, fmt.Errorf("error decoding base64 text '%s' to JSON: %v", string(txtBytes), err)
+	if o.perUserKeys && cookieData.AuthData != user {
+		return nil, fmt.Errorf("%w: cookie user doesn't match the user its key was derived for", ErrBadSignature)
+	}
+
+	return checkVerified(cookieData, o)
+}
+
+// checkVerified validates the claims of a cookie whose signature has been verified, and consumes its nonce, returning the cookie as Parse does.
+func checkVerified(cookieData *Cookie, o *options) (*Cookie, error) {
+	if err := validate(cookieData, o); err != nil {
+		if errors.Is(err, ErrExpired) && onlyExpired(cookieData, o) {
+			return cookieData, err
+		}
+		return nil, err
+	}
+
+	if o.revocations != nil {
+		if err := checkRevoked(o.revocations, cookieData.SessionID); err != nil {
+			return nil, err
+		}
 	}
 
-	if cookieData.ExpiresUnix-time.Now().Unix() < 0 {
-		return nil, fmt.Errorf("signature expired")
+	if o.rotation != nil {
+		if err := o.checkGeneration(cookieData); err != nil {
+			return nil, err
+		}
 	}
 
+	if o.nonces != nil {
+		if err := consumeNonce(o.nonces, cookieData.JTI); err != nil {
+			return nil, err
+		}
+	}
+
+	return cookieData, nil
+}
+
+// onlyExpired returns whether the cookie, which has expired, would otherwise be valid.
+func onlyExpired(c *Cookie, o *options) bool {
+	ignoringExpiry := *o
+	ignoringExpiry.skipExpiry = true
+	return validate(c, &ignoringExpiry) == nil
+}
+
+// decodeClaims unmarshals the decoded payload of a cookie, within the limits of WithMaxPayloadSize and WithMaxJSONDepth.
+func decodeClaims(txtBytes []byte, o *options) (*Cookie, error) {
+	if err := o.checkPayload(txtBytes); err != nil {
+		return nil, err
+	}
+	cookieData := Cookie{}
+	if err := o.unmarshal(txtBytes, &cookieData); err != nil {
+		return nil, fmt.Errorf("%w: error decoding base64 text '%s' to JSON: %w", ErrMalformed, string(txtBytes), err)
+	}
+	if err := o.checkUnknownFields(&cookieData); err != nil {
+		return nil, err
+	}
+	cookieData.payload = txtBytes
+	if err := o.expandCapabilities(&cookieData); err != nil {
+		return nil, err
+	}
 	return &cookieData, nil
 }
 
+// ParseUnverified decodes a cookie without verifying its signature or validating any of its claims, including its expiry. The claims MUST NOT be trusted; they may have been forged by anyone. It is intended for debugging and logging; use Parse, with SkipExpiry if need be, to read claims which must be trusted.
+func ParseUnverified(cookie string, opts ...Option) (*Cookie, error) {
+	return decodeUnverified(cookie, newOptions(opts))
+}
+
+// ValidateSignatureOnly returns whether the cookie's signature is authentic, without decoding its payload or validating any of its claims, including its expiry, for the cheapest possible check, e.g. by edge filters. The error wraps ErrMalformed if the cookie can't be split, and ErrBadSignature if the signature doesn't match; it is nil if the signature is authentic. The options are those of Parse which affect the signature, such as WithHash and WithAssociatedData. Given WithPerUserKeys, the payload must be decoded to derive the key.
+//
+// A nil error doesn't mean the cookie may be accepted; it may have expired long ago. Use Parse to authenticate requests, and Verifier to verify cookies at high rates.
+func ValidateSignatureOnly(secret, cookie string, opts ...Option) error {
+	return validateSignatureOnly(secret, cookie, newOptions(opts))
+}
+
+func validateSignatureOnly(secret, cookie string, o *options) error {
+	if err := o.precheck(cookie); err != nil {
+		return err
+	}
+	user := ""
+	if o.perUserKeys {
+		unverified, err := decodeUnverified(cookie, o)
+		if err != nil {
+			return err
+		}
+		user = unverified.AuthData
+	}
+	_, err := verifySigned(cookie, o.signingKey([]byte(secret), user), o)
+	return err
+}
+
+// decodeUnverified returns the claims of a cookie without verifying its signature or validating them. The claims must not be trusted.
+func decodeUnverified(cookie string, o *options) (*Cookie, error) {
+	s, err := splitCookie(cookie, o)
+	if err != nil {
+		return nil, err
+	}
+	txtBytes, err := s.decodePayload(o)
+	if err != nil {
+		return nil, err
+	}
+	return decodeClaims(txtBytes, o)
+}
+
+// NewRawMsg signs an arbitrary message in the version 0 format, with an unpadded base64url payload.
 func NewRawMsg(msg, key []byte) string {
-	base64Msg := base64.RawURLEncoding.EncodeToString(msg)
-	mac := hmac.New(sha1.New, []byte(key))
-	mac.Write([]byte(base64Msg))
-	encMac := mac.Sum(nil)
-	base64Sig := hex.EncodeToString(encMac)
-	return base64Msg + "--" + base64Sig
+	return encodeV0(msg, key, newOptions([]Option{WithPadding(base64.NoPadding)}))
+}
+
+func New(user string, expiration time.Time, key string, opts ...Option) string {
+	return NewWithContext(context.Background(), user, expiration, key, opts...)
 }
 
-func New(user string, expiration time.Time, key string) string {
-	cookieMsg := Cookie{By: GeneratedByStr, AuthData: user, ExpiresUnix: expiration.Unix()}
-	msg, _ := json.Marshal(cookieMsg)
-	return NewRawMsg(msg, []byte(key))
+// NewWithContext is New, with the span of WithTracer started from the context, so it is part of the trace of the request which logged the user in.
+func NewWithContext(ctx context.Context, user string, expiration time.Time, key string, opts ...Option) string {
+	o := newOptions(opts)
+	_, span := o.startSpan(ctx, SpanNew)
+	cookie := newCookie(user, expiration, key, o)
+	o.endIssueSpan(span, cookie, expiration)
+	return cookie
 }
 
-// Update takes an existing cookie and returns a new serialized cookie with an updated expiration
-func Refresh(c *Cookie, key string) string {
-	return New(c.AuthData, time.Now().Add(DefaultDuration), key)
+func newCookie(user string, expiration time.Time, key string, o *options) string {
+	cookieMsg, err := o.newSession(user, expiration)
+	if err != nil {
+		return ""
+	}
+	cookie := encodeCookie(cookieMsg, key, o)
+	if cookie != "" {
+		o.issued(cookieMsg)
+	}
+	return cookie
+}
+
+// newSession returns the claims of a new session of the user, with a new SessionID and the claims configured by the options.
+func (o *options) newSession(user string, expiration time.Time) (*Cookie, error) {
+	sessionID, err := NewSessionID()
+	if err != nil {
+		return nil, err
+	}
+	now := o.now().Unix()
+	c := &Cookie{By: GeneratedByStr, AuthData: user, IssuedAt: now, SessionStart: now, SessionID: sessionID}
+	if authTime := o.authTime.Unix(); !o.authTime.IsZero() && authTime < now {
+		c.AuthTime = authTime
+	}
+	o.setClaims(c)
+	for name, value := range o.claims {
+		if err := c.SetClaim(name, value); err != nil {
+			return nil, err
+		}
+	}
+	o.setExpiration(c, expiration)
+	return c, nil
+}
+
+// encodeCookie serializes and signs the cookie, returning an empty string if it can't be serialized with the options, or if its custom claims are too large.
+func encodeCookie(c *Cookie, key string, o *options) string {
+	if o.checkSecret(key) != nil || c.claimsSize() > o.maxClaimsSize {
+		return ""
+	}
+	c, err := o.compactCapabilities(c)
+	if err != nil {
+		return ""
+	}
+	msg, err := o.marshal(c)
+	if err != nil {
+		return ""
+	}
+	return encodeSigned(msg, o.signingKey([]byte(key), c.AuthData), o)
+}
+
+// Update takes an existing cookie and returns a new serialized cookie with an updated expiration, DefaultDuration or the duration given by WithIdleTimeout from now. All other claims of the cookie are preserved; options setting claims, such as WithAudience, are ignored.
+//
+// IssuedAt is set to the current time. Cookies without SessionStart, minted before it was introduced, are given their IssuedAt, or the current time if they have none, which starts the clock of WithMaxLifetime. Likewise, cookies without a SessionID are given a new one, so they can be revoked from then on. Given WithMaxLifetime, the expiration is never extended past the end of the session's lifetime, nor, given WithMaxAuthAge, past the end of its authentication's age; AuthTime is preserved. Given WithRotation, the refreshed cookie is of the next Generation, and an empty string is returned if the cookie has already been refreshed, or the store fails. Impersonations minted by NewImpersonation keep their Actor, and are never extended past MaxImpersonationDuration from their start.
+func Refresh(c *Cookie, key string, opts ...Option) string {
+	return RefreshContext(context.Background(), c, key, opts...)
+}
+
+// RefreshContext is Refresh, with the span of WithTracer started from the context, so it is part of the trace of the request which refreshed the cookie.
+func RefreshContext(ctx context.Context, c *Cookie, key string, opts ...Option) string {
+	o := newOptions(opts)
+	_, span := o.startSpan(ctx, SpanRefresh)
+	cookie, expiration := refresh(c, key, o)
+	o.endIssueSpan(span, cookie, expiration)
+	return cookie
+}
+
+func refresh(c *Cookie, key string, o *options) (string, time.Time) {
+	now := o.now()
+	refreshed := c.Clone()
+	if refreshed.SessionStart = refreshed.sessionStart(); refreshed.SessionStart == 0 {
+		refreshed.SessionStart = now.Unix()
+	}
+	if refreshed.SessionID == "" {
+		sessionID, err := NewSessionID()
+		if err != nil {
+			return "", time.Time{}
+		}
+		refreshed.SessionID = sessionID
+	}
+	refreshed.IssuedAt = now.Unix()
+	expiration := capImpersonation(refreshed, o.capLifetime(refreshed, now.Add(o.duration())))
+	o.setExpiration(refreshed, expiration)
+	if o.rotation != nil {
+		rotated, err := o.rotation.Rotate(refreshed.SessionID, c.Generation, expiration.Add(o.leeway))
+		if err != nil || !rotated {
+			return "", time.Time{}
+		}
+		refreshed.Generation = c.Generation + 1
+	}
+	cookie := encodeCookie(refreshed, key, o)
+	if cookie != "" {
+		o.refreshed(refreshed)
+	}
+	return cookie, expiration
 }
@@ -0,0 +1,389 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"crypto"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewParse(t *testing.T) {
+	secret := "secret"
+	user := "alice"
+	expiration := time.Now().Add(time.Minute)
+
+	c, err := Parse(secret, New(user, expiration, secret))
+	if err != nil {
+		t.Fatalf("Parse expected nil error, actual: %v", err)
+	}
+	if c.AuthData != user {
+		t.Errorf("Parse expected AuthData '%v', actual: '%v'", user, c.AuthData)
+	}
+	if c.ExpiresUnix != expiration.Unix() {
+		t.Errorf("Parse expected ExpiresUnix %v, actual: %v", expiration.Unix(), c.ExpiresUnix)
+	}
+	if c.By != GeneratedByStr {
+		t.Errorf("Parse expected By '%v', actual: '%v'", GeneratedByStr, c.By)
+	}
+}
+
+func TestParseRejects(t *testing.T) {
+	secret := "secret"
+	valid := New("alice", time.Now().Add(time.Minute), secret)
+	badSig := valid[:len(valid)-1] + "0"
+	if badSig == valid {
+		badSig = valid[:len(valid)-1] + "1"
+	}
+	tests := map[string]struct {
+		secret string
+		cookie string
+	}{
+		"wrong secret":  {"wrong", valid},
+		"expired":       {secret, New("alice", time.Now().Add(-time.Minute), secret)},
+		"no dashes":     {secret, "eyJhdXRoX2RhdGEiOiJhbGljZSJ9"},
+		"bad signature": {secret, badSig},
+		"empty":         {secret, ""},
+	}
+	for name, test := range tests {
+		if _, err := Parse(test.secret, test.cookie); err == nil {
+			t.Errorf("%v: Parse expected error, actual nil", name)
+		}
+	}
+}
+
+func TestIsExpiringSoon(t *testing.T) {
+	now := time.Unix(1500000000, 0)
+	tests := map[string]struct {
+		expires  time.Time
+		expected bool
+	}{
+		"within window":  {now.Add(time.Minute), true},
+		"outside window": {now.Add(time.Hour), false},
+		"expired":        {now.Add(-time.Minute), false},
+	}
+	for name, test := range tests {
+		c := Cookie{ExpiresUnix: test.expires.Unix()}
+		if actual := c.IsExpiringSoon(5*time.Minute, now); actual != test.expected {
+			t.Errorf("%v: IsExpiringSoon expected %v, actual: %v", name, test.expected, actual)
+		}
+	}
+}
+
+func TestWithHash(t *testing.T) {
+	secret := "secret"
+	for _, hash := range []crypto.Hash{crypto.SHA1, crypto.SHA256, crypto.SHA512} {
+		cookie := New("alice", time.Now().Add(time.Minute), secret, WithHash(hash))
+		if _, err := Parse(secret, cookie, WithHash(hash)); err != nil {
+			t.Errorf("Parse %v expected nil error, actual: %v", hash, err)
+		}
+		if hash != DefaultHash {
+			if _, err := Parse(secret, cookie); err == nil {
+				t.Errorf("Parse %v cookie with default hash expected error, actual nil", hash)
+			}
+		}
+	}
+	if cookie := New("alice", time.Now().Add(time.Minute), secret, WithHash(crypto.MD4)); cookie != "" {
+		t.Errorf("New unavailable hash expected empty cookie, actual: '%v'", cookie)
+	}
+}
+
+func TestWithLegacyHashes(t *testing.T) {
+	secret := "secret"
+	expiration := time.Now().Add(time.Minute)
+	migrating := []Option{WithHash(crypto.SHA256), WithLegacyHashes(crypto.SHA1, crypto.MD4)}
+	legacy := New("alice", expiration, secret)
+
+	for name, cookie := range map[string]string{"legacy": legacy, "new": New("alice", expiration, secret, migrating...)} {
+		if _, err := Parse(secret, cookie, migrating...); err != nil {
+			t.Errorf("%v: Parse migrating expected nil error, actual: %v", name, err)
+		}
+		if _, err := NewParser(secret, migrating...).Parse(cookie); err != nil {
+			t.Errorf("%v: Parser.Parse migrating expected nil error, actual: %v", name, err)
+		}
+	}
+	if _, err := Parse(secret, New("alice", expiration, secret, WithHash(crypto.SHA512)), migrating...); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("Parse migrating of SHA-512 cookie expected ErrBadSignature, actual: %v", err)
+	}
+	if _, err := Parse("wrong", legacy, migrating...); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("Parse migrating of legacy cookie with wrong secret expected ErrBadSignature, actual: %v", err)
+	}
+
+	c, err := Parse(secret, legacy, migrating...)
+	if err != nil {
+		t.Fatalf("Parse migrating expected nil error, actual: %v", err)
+	}
+	if _, err := Parse(secret, Refresh(c, secret, migrating...), WithHash(crypto.SHA256)); err != nil {
+		t.Errorf("Refresh migrating expected a SHA-256 cookie, actual: %v", err)
+	}
+}
+
+func TestParseWrapsErrors(t *testing.T) {
+	secret := "secret"
+
+	corruptInput := base64.CorruptInputError(0)
+	badBase64 := "!!!!--" + hex.EncodeToString(newOptions(nil).sign([]byte("!!!!"), []byte(secret)))
+	if _, err := Parse(secret, badBase64); !errors.As(err, &corruptInput) {
+		t.Errorf("Parse bad base64 expected base64.CorruptInputError, actual: %v", err)
+	}
+
+	invalidByte := hex.InvalidByteError(0)
+	if _, err := Parse(secret, "eyJ9--zz"); !errors.As(err, &invalidByte) {
+		t.Errorf("Parse bad signature hex expected hex.InvalidByteError, actual: %v", err)
+	}
+
+	syntaxErr := &json.SyntaxError{}
+	if _, err := Parse(secret, NewRawMsg([]byte("{not json"), []byte(secret))); !errors.As(err, &syntaxErr) {
+		t.Errorf("Parse bad JSON expected *json.SyntaxError, actual: %v", err)
+	}
+}
+
+func TestWithTagLength(t *testing.T) {
+	secret := "secret"
+	truncated := New("alice", time.Now().Add(time.Minute), secret, WithHash(crypto.SHA256), WithTagLength(16))
+	full := New("alice", time.Now().Add(time.Minute), secret, WithHash(crypto.SHA256))
+	if sigLen := len(truncated) - strings.LastIndex(truncated, "-") - 1; sigLen != 32 {
+		t.Errorf("New with tag length 16 expected 32 hex characters, actual: %v", sigLen)
+	}
+
+	if _, err := Parse(secret, truncated, WithHash(crypto.SHA256), WithTagLength(16)); err != nil {
+		t.Errorf("Parse truncated expected nil error, actual: %v", err)
+	}
+	if _, err := Parse(secret, full, WithHash(crypto.SHA256), WithTagLength(16)); err != nil {
+		t.Errorf("Parse full tag with tag length option expected nil error, actual: %v", err)
+	}
+	if _, err := Parse(secret, truncated, WithHash(crypto.SHA256)); err == nil {
+		t.Errorf("Parse truncated without tag length option expected error, actual nil")
+	}
+
+	tooShort := New("alice", time.Now().Add(time.Minute), secret, WithTagLength(4))
+	if sigLen := len(tooShort) - strings.LastIndex(tooShort, "-") - 1; sigLen != 2*MinTagLength {
+		t.Errorf("New with tag length 4 expected clamping to %v hex characters, actual: %v", 2*MinTagLength, sigLen)
+	}
+	if _, err := Parse(secret, tooShort[:len(tooShort)-8], WithTagLength(4)); err == nil {
+		t.Errorf("Parse tag shorter than MinTagLength expected error, actual nil")
+	}
+}
+
+func TestEqual(t *testing.T) {
+	secret := "secret"
+	original, err := Parse(secret, New("alice", time.Now().Add(time.Minute), secret, WithJTI("1")))
+	if err != nil {
+		t.Fatalf("Parse expected nil error, actual: %v", err)
+	}
+	refreshed, err := Parse(secret, New("alice", time.Now().Add(time.Hour), secret, WithJTI("1"), WithMillisecondExpiry()))
+	if err != nil {
+		t.Fatalf("Parse expected nil error, actual: %v", err)
+	}
+	if !original.Equal(refreshed) {
+		t.Errorf("Equal with different expiry expected true, actual false")
+	}
+
+	other := *original
+	other.JTI = "2"
+	if original.Equal(&other) {
+		t.Errorf("Equal with different jti expected false, actual true")
+	}
+	other = *original
+	other.AuthData = "bob"
+	if original.Equal(&other) {
+		t.Errorf("Equal with different auth data expected false, actual true")
+	}
+	if original.Equal(nil) {
+		t.Errorf("Equal with nil expected false, actual true")
+	}
+	if !(*Cookie)(nil).Equal(nil) {
+		t.Errorf("Equal nil with nil expected true, actual false")
+	}
+}
+
+func TestEqualClaims(t *testing.T) {
+	original := &Cookie{AuthData: "alice", Extra: map[string]json.RawMessage{"tenant": json.RawMessage(`{"id": 1}`), "flash": json.RawMessage(`{"message":"saved"}`)}}
+	tests := map[string]struct {
+		extra    map[string]json.RawMessage
+		expected bool
+	}{
+		"same":               {map[string]json.RawMessage{"tenant": json.RawMessage(`{"id": 1}`), "flash": json.RawMessage(`{"message":"saved"}`)}, true},
+		"whitespace":         {map[string]json.RawMessage{"tenant": json.RawMessage(`{"id":1}`)}, true},
+		"other flash":        {map[string]json.RawMessage{"tenant": json.RawMessage(`{"id":1}`), "new_flash": json.RawMessage(`{"notice":"x"}`), "expiration": json.RawMessage(`3600`)}, true},
+		"other tenant":       {map[string]json.RawMessage{"tenant": json.RawMessage(`{"id":2}`), "flash": json.RawMessage(`{"message":"saved"}`)}, false},
+		"no tenant":          {map[string]json.RawMessage{"flash": json.RawMessage(`{"message":"saved"}`)}, false},
+		"extra role":         {map[string]json.RawMessage{"tenant": json.RawMessage(`{"id":1}`), "role": json.RawMessage(`"admin"`)}, false},
+		"no custom claims":   {nil, false},
+		"invalid json claim": {map[string]json.RawMessage{"tenant": json.RawMessage(`{"id":`)}, false},
+	}
+	for name, test := range tests {
+		other := &Cookie{AuthData: "alice", Extra: test.extra}
+		if actual := original.Equal(other); actual != test.expected {
+			t.Errorf("%v: Equal expected %v, actual: %v", name, test.expected, actual)
+		}
+		if actual := other.Equal(original); actual != test.expected {
+			t.Errorf("%v: Equal reversed expected %v, actual: %v", name, test.expected, actual)
+		}
+	}
+	flashOnly := &Cookie{AuthData: "alice", Extra: map[string]json.RawMessage{"flash": json.RawMessage(`1`)}}
+	if !flashOnly.Equal(&Cookie{AuthData: "alice"}) {
+		t.Errorf("Equal of cookies differing in Mojolicious session data alone expected true, actual false")
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	secret := "secret"
+	original, err := Parse(secret, New("alice", time.Now().Add(time.Minute), secret, WithAudience("api"), WithMillisecondExpiry()))
+	if err != nil {
+		t.Fatalf("Parse expected nil error, actual: %v", err)
+	}
+	refreshed, err := Parse(secret, Refresh(original, secret))
+	if err != nil {
+		t.Fatalf("Parse refreshed expected nil error, actual: %v", err)
+	}
+	if !refreshed.Equal(original) {
+		t.Errorf("Refresh expected claims preserved, actual: %+v", refreshed)
+	}
+	if refreshed.ExpiresMillis == 0 {
+		t.Errorf("Refresh expected millisecond expiry preserved, actual: %+v", refreshed)
+	}
+	if left := refreshed.TimeLeft(time.Now()); left < DefaultDuration-time.Minute {
+		t.Errorf("Refresh expected expiry extended to %v, actual time left: %v", DefaultDuration, left)
+	}
+}
+
+func TestParseUnverified(t *testing.T) {
+	cookie := New("alice", time.Now().Add(-time.Hour), "other")
+	c, err := ParseUnverified(cookie)
+	if err != nil {
+		t.Fatalf("ParseUnverified expected nil error, actual: %v", err)
+	}
+	if c.AuthData != "alice" {
+		t.Errorf("ParseUnverified expected AuthData alice, actual: %v", c.AuthData)
+	}
+	if _, err := ParseUnverified("garbage"); err == nil {
+		t.Errorf("ParseUnverified malformed expected error, actual nil")
+	}
+}
+
+func TestParseReturnsExpiredClaims(t *testing.T) {
+	secret := "secret"
+	expired := New("alice", time.Now().Add(-time.Minute), secret, WithAudience("api"))
+
+	c, err := Parse(secret, expired, WithAudience("api"))
+	if !errors.Is(err, ErrExpired) {
+		t.Fatalf("Parse expired expected ErrExpired, actual: %v", err)
+	}
+	if c == nil || c.AuthData != "alice" {
+		t.Errorf("Parse expired expected claims, actual: %+v", c)
+	}
+
+	if c, err := Parse("wrong", expired); err == nil || c != nil {
+		t.Errorf("Parse expired with bad signature expected nil claims and error, actual: %+v %v", c, err)
+	}
+	if c, err := Parse(secret, expired, WithAudience("portal"), WithAllErrors()); err == nil || c != nil {
+		t.Errorf("Parse expired with wrong audience expected nil claims and error, actual: %+v %v", c, err)
+	}
+	if c, err := Parse(secret, expired, WithAudience("portal")); !errors.Is(err, ErrExpired) || c != nil {
+		t.Errorf("Parse expired with wrong audience expected nil claims and ErrExpired, actual: %+v %v", c, err)
+	}
+}
+
+func TestClone(t *testing.T) {
+	c := &Cookie{AuthData: "alice", ExpiresUnix: 1, Roles: []string{"admin"}, Extra: map[string]json.RawMessage{"flash": json.RawMessage(`{"a":1}`)}}
+	clone := c.Clone()
+	if !reflect.DeepEqual(c, clone) {
+		t.Fatalf("Clone expected deep equal copy, actual: %+v", clone)
+	}
+
+	clone.AuthData = "bob"
+	clone.Roles[0] = "read-only"
+	clone.Roles = append(clone.Roles, "portal")
+	clone.Extra["flash"][1] = 'X'
+	clone.Extra["new_flash"] = json.RawMessage(`1`)
+
+	expected := &Cookie{AuthData: "alice", ExpiresUnix: 1, Roles: []string{"admin"}, Extra: map[string]json.RawMessage{"flash": json.RawMessage(`{"a":1}`)}}
+	if !reflect.DeepEqual(c, expected) {
+		t.Errorf("Clone expected modifying the clone not to modify the source, actual source: %+v", c)
+	}
+	if (*Cookie)(nil).Clone() != nil {
+		t.Errorf("Clone of nil expected nil")
+	}
+}
+
+func TestRawPayload(t *testing.T) {
+	secret := "secret"
+	perl := perlCookies[`{"auth_data":"operator1","expires":4102444800}`]
+
+	tests := map[string]struct {
+		cookie   string
+		expected string
+	}{
+		"perl":       {perl, `{"auth_data":"operator1","expires":4102444800}`},
+		"escaping":   {NewRawMsg([]byte(`{"expires":4102444800, "auth_data":"alice"}`), []byte(secret)), `{"expires":4102444800, "auth_data":"alice"}`},
+		"compressed": {New("alice", time.Unix(4102444800, 0), secret, WithCompression(CodecGzip)), ""},
+	}
+	for name, test := range tests {
+		c, err := ParseUnverified(test.cookie)
+		if err != nil {
+			t.Fatalf("%v: ParseUnverified expected nil error, actual: %v", name, err)
+		}
+		if test.expected == "" {
+			if !json.Valid(c.RawPayload()) || !strings.Contains(string(c.RawPayload()), `"auth_data":"alice"`) {
+				t.Errorf("%v: RawPayload expected decompressed JSON, actual: '%s'", name, c.RawPayload())
+			}
+		} else if string(c.RawPayload()) != test.expected {
+			t.Errorf("%v: RawPayload expected '%v', actual: '%s'", name, test.expected, c.RawPayload())
+		}
+	}
+
+	if c, err := Parse("mysecret", perl); err != nil || string(c.RawPayload()) != `{"auth_data":"operator1","expires":4102444800}` {
+		t.Errorf("Parse RawPayload expected the signed JSON, actual: %v %v", c, err)
+	}
+	if payload := (&Cookie{AuthData: "alice"}).RawPayload(); payload != nil {
+		t.Errorf("RawPayload of cookie which wasn't decoded expected nil, actual: '%s'", payload)
+	}
+}
+
+func TestValidateSignatureOnly(t *testing.T) {
+	secret := "secret"
+	valid := New("alice", time.Now().Add(time.Minute), secret)
+	tampered := []byte(valid)
+	tampered[2] ^= 1
+
+	tests := map[string]struct {
+		cookie   string
+		opts     []Option
+		expected error
+	}{
+		"valid":             {valid, nil, nil},
+		"expired":           {New("alice", time.Now().Add(-time.Hour), secret), nil, nil},
+		"payload not json":  {NewRawMsg([]byte("not json"), []byte(secret)), nil, nil},
+		"aad":               {New("alice", time.Now().Add(time.Minute), secret, WithAssociatedData([]byte("a"))), []Option{WithAssociatedData([]byte("a"))}, nil},
+		"per user keys":     {New("alice", time.Now().Add(time.Minute), secret, WithPerUserKeys()), []Option{WithPerUserKeys()}, nil},
+		"wrong secret":      {New("alice", time.Now().Add(time.Minute), "wrong"), nil, ErrBadSignature},
+		"tampered":          {string(tampered), nil, ErrBadSignature},
+		"wrong aad":         {valid, []Option{WithAssociatedData([]byte("a"))}, ErrBadSignature},
+		"no signature":      {"eyJhdXRoX2RhdGEiOiJhbGljZSJ9", nil, ErrMalformed},
+		"bad signature hex": {valid[:len(valid)-1] + "z", nil, ErrMalformed},
+		"empty":             {"", nil, ErrMalformed},
+	}
+	for name, test := range tests {
+		err := ValidateSignatureOnly(secret, test.cookie, test.opts...)
+		if test.expected == nil && err != nil || test.expected != nil && !errors.Is(err, test.expected) {
+			t.Errorf("%v: ValidateSignatureOnly expected %v, actual: %v", name, test.expected, err)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+)
+
+// WithCookieName sets the name of the session's cookie, which is Name by default, for NewHTTPCookie, SetHTTPCookies, ClearHTTPCookies, RequestCookie, CSRFMiddleware, and the CookieExtractor of Middleware. Its chunks are named after it, as ChunkName names those of Name, and its remember-me cookie is the name with the suffix "_remember", as RememberName is. Services of one domain which shouldn't share sessions, such as an admin console and a public API, can give their cookies different names, so that neither overwrites the other's; see CookieMiddleware. Perl Traffic Ops only reads the cookie named Name.
+func WithCookieName(name string) Option {
+	return func(o *options) { o.cookieName = name }
+}
+
+// CookieName returns the name of the session's cookie of the options: that given WithCookieName, or Name.
+func CookieName(opts ...Option) string {
+	return newOptions(opts).name()
+}
+
+// RememberCookieName returns the name of the remember-me cookie of the options: RememberName, or that of WithCookieName with the suffix "_remember".
+func RememberCookieName(opts ...Option) string {
+	return newOptions(opts).rememberName()
+}
+
+// CookieName returns the name of the session's cookie of the manager's options, like the package-level CookieName.
+func (m *Manager) CookieName() string {
+	return CookieName(m.options(nil)...)
+}
+
+func (o *options) name() string {
+	if o.cookieName == "" {
+		return Name
+	}
+	return o.cookieName
+}
+
+func (o *options) chunkName(i int) string {
+	return o.name() + chunkSep + strconv.Itoa(i)
+}
+
+func (o *options) rememberName() string {
+	if o.cookieName == "" {
+		return RememberName
+	}
+	return o.cookieName + "_remember"
+}
+
+// CookieConfig is a named cookie of CookieMiddleware, with the secret and options it is verified and refreshed with.
+type CookieConfig struct {
+	// Name is the name of the cookie, as WithCookieName sets it. Each cookie of CookieMiddleware must have a different name.
+	Name string
+	// Secret is the secret the cookie is signed with.
+	Secret string
+	// Options are the options of the cookie's Middleware, such as WithMaxAuthAge or WithIdleTimeout, so each cookie can have its own policy.
+	Options []Option
+}
+
+// CookieMiddleware returns a Middleware which accepts several cookies of different names, each with its own secret and options, e.g. so an admin cookie with a short idle timeout and a regular user cookie can coexist on one domain. Requests are authenticated by the Middleware of the first cookie, in order, which the request has, which refreshes that cookie only; requests with none of the cookies are authenticated by the Middleware of the first, so bearer tokens are accepted as its options accept them. Handlers can tell which cookie authenticated a request with CookieNameFromContext.
+func CookieMiddleware(cookies []CookieConfig, next http.Handler) http.Handler {
+	if len(cookies) == 0 {
+		panic("tocookie: CookieMiddleware without cookies")
+	}
+	options := make([]*options, len(cookies))
+	handlers := make([]http.Handler, len(cookies))
+	for i, c := range cookies {
+		opts := append(append(make([]Option, 0, len(c.Options)+1), c.Options...), WithCookieName(c.Name))
+		options[i] = newOptions(opts)
+		handlers[i] = Middleware(c.Secret, withCookieName(c.Name, next), opts...)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for i, o := range options {
+			if _, err := o.requestCookie(r); err != http.ErrNoCookie {
+				handlers[i].ServeHTTP(w, r)
+				return
+			}
+		}
+		handlers[0].ServeHTTP(w, r)
+	})
+}
+
+// cookieNameContextKey is the key of the name of the cookie of CookieMiddleware in request contexts.
+type cookieNameContextKey struct{}
+
+// withCookieName returns a handler calling next with the cookie name in the request's context.
+func withCookieName(name string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), cookieNameContextKey{}, name)))
+	})
+}
+
+// CookieNameFromContext returns the name of the cookie whose Middleware of CookieMiddleware passed on the request, and whether there is one. Requests authenticated by a bearer token have the name of the first cookie.
+func CookieNameFromContext(ctx context.Context) (string, bool) {
+	name, ok := ctx.Value(cookieNameContextKey{}).(string)
+	return name, ok
+}
@@ -0,0 +1,131 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCookieName(t *testing.T) {
+	admin := WithCookieName("admin_session")
+	if name := CookieName(); name != Name {
+		t.Errorf("CookieName expected %v, actual: %v", Name, name)
+	}
+	if name := CookieName(admin); name != "admin_session" {
+		t.Errorf("CookieName WithCookieName expected admin_session, actual: %v", name)
+	}
+	if name := RememberCookieName(); name != RememberName {
+		t.Errorf("RememberCookieName expected %v, actual: %v", RememberName, name)
+	}
+	if name := RememberCookieName(admin); name != "admin_session_remember" {
+		t.Errorf("RememberCookieName WithCookieName expected admin_session_remember, actual: %v", name)
+	}
+	if name := NewManager("secret", admin).CookieName(); name != "admin_session" {
+		t.Errorf("Manager.CookieName expected admin_session, actual: %v", name)
+	}
+	if c := HTTPCookie("value", time.Time{}, admin); c.Name != "admin_session" {
+		t.Errorf("HTTPCookie WithCookieName expected name admin_session, actual: %v", c.Name)
+	}
+	if c := RememberHTTPCookie("value", time.Time{}, admin); c.Name != "admin_session_remember" {
+		t.Errorf("RememberHTTPCookie WithCookieName expected name admin_session_remember, actual: %v", c.Name)
+	}
+
+	chunks := HTTPCookies(strings.Repeat("a", 25), time.Time{}, admin, WithChunking(10))
+	if len(chunks) != 3 || chunks[0].Name != "admin_session.0" || chunks[2].Name != "admin_session.2" {
+		t.Fatalf("HTTPCookies WithCookieName expected chunks of admin_session, actual: %v", chunks)
+	}
+	r := requestWith(append(chunks, &http.Cookie{Name: Name, Value: "other"}))
+	if value, err := RequestCookie(r, admin); err != nil || value != strings.Repeat("a", 25) {
+		t.Errorf("RequestCookie WithCookieName expected the chunks' value, actual: %v, %v", value, err)
+	}
+	if value, err := RequestCookie(r); err != nil || value != "other" {
+		t.Errorf("RequestCookie expected the value of %v, actual: %v, %v", Name, value, err)
+	}
+
+	w := httptest.NewRecorder()
+	ClearHTTPCookies(w, r, admin)
+	for _, c := range w.Result().Cookies() {
+		if !strings.HasPrefix(c.Name, "admin_session") || c.MaxAge >= 0 {
+			t.Errorf("ClearHTTPCookies WithCookieName expected only admin_session cookies expired, actual: %v", c)
+		}
+	}
+	if len(w.Result().Cookies()) != 4 {
+		t.Errorf("ClearHTTPCookies WithCookieName expected admin_session and 3 chunks expired, actual: %v", w.Result().Cookies())
+	}
+}
+
+func TestCookieMiddleware(t *testing.T) {
+	cookies := []CookieConfig{
+		{Name: "admin_session", Secret: "admin-secret", Options: []Option{WithRefreshWindow(DefaultRefreshWindow)}},
+		{Name: "user_session", Secret: "user-secret"},
+	}
+	var authenticated string
+	handler := CookieMiddleware(cookies, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, _ := FromContext(r.Context())
+		name, _ := CookieNameFromContext(r.Context())
+		authenticated = name + ":" + c.AuthData
+	}))
+	expiration := time.Now().Add(time.Minute)
+
+	tests := map[string]struct {
+		cookies  []*http.Cookie
+		bearer   string
+		code     int
+		expected string
+	}{
+		"admin":          {[]*http.Cookie{{Name: "admin_session", Value: New("root", expiration, "admin-secret")}}, "", http.StatusOK, "admin_session:root"},
+		"user":           {[]*http.Cookie{{Name: "user_session", Value: New("alice", expiration, "user-secret")}}, "", http.StatusOK, "user_session:alice"},
+		"both":           {[]*http.Cookie{{Name: "user_session", Value: New("alice", expiration, "user-secret")}, {Name: "admin_session", Value: New("root", expiration, "admin-secret")}}, "", http.StatusOK, "admin_session:root"},
+		"wrong secret":   {[]*http.Cookie{{Name: "user_session", Value: New("alice", expiration, "admin-secret")}}, "", http.StatusUnauthorized, ""},
+		"default name":   {[]*http.Cookie{{Name: Name, Value: New("alice", expiration, "user-secret")}}, "", http.StatusUnauthorized, ""},
+		"bearer":         {nil, New("root", expiration, "admin-secret"), http.StatusOK, "admin_session:root"},
+		"no credentials": {nil, "", http.StatusUnauthorized, ""},
+	}
+	for name, test := range tests {
+		authenticated = ""
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		for _, c := range test.cookies {
+			r.AddCookie(c)
+		}
+		if test.bearer != "" {
+			r.Header.Set("Authorization", "Bearer "+test.bearer)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != test.code || authenticated != test.expected {
+			t.Errorf("%v: CookieMiddleware expected %v '%v', actual: %v '%v'", name, test.code, test.expected, w.Code, authenticated)
+		}
+		for _, c := range w.Result().Cookies() {
+			if c.Name != "admin_session" {
+				t.Errorf("%v: CookieMiddleware expected only admin_session refreshed, actual: %v", name, c.Name)
+			}
+		}
+	}
+}
+
+func TestCSRFMiddlewareCookieName(t *testing.T) {
+	secret := "secret"
+	opts := []Option{WithCookieName("admin_session")}
+	handler := Middleware(secret, CSRFMiddleware(secret, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), opts...), opts...)
+	r := httptest.NewRequest(http.MethodPost, "/", nil)
+	r.AddCookie(&http.Cookie{Name: "admin_session", Value: New("alice", time.Now().Add(time.Hour), secret)})
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("CSRFMiddleware WithCookieName of post without token expected %v, actual: %v", http.StatusForbidden, w.Code)
+	}
+}
@@ -0,0 +1,158 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package credentials
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/tocookie"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/tocookie/login"
+)
+
+// ErrNoSuchUser is returned by UserStores for users they don't have.
+var ErrNoSuchUser = errors.New("no such user")
+
+// User is a user of a UserStore.
+type User struct {
+	// Username is the username of the user, as it is stored, which may differ from the one given at login, e.g. by case.
+	Username string
+	// PasswordHash is the hash of the user's password, or empty for users without a local password, e.g. those who authenticate with LDAP.
+	PasswordHash string
+	// Roles are the roles of the user's sessions.
+	Roles []string
+	// Disabled users can't log in, e.g. those of the disallowed role of Traffic Ops.
+	Disabled bool
+}
+
+// UserStore stores the password hashes of users, such as the tm_user table of Traffic Ops; see NewSQLUsers.
+type UserStore interface {
+	// User returns the user with the username, or an error wrapping ErrNoSuchUser if there is none.
+	User(ctx context.Context, username string) (*User, error)
+	// SetPasswordHash replaces the password hash of the user.
+	SetPasswordHash(ctx context.Context, username, hash string) error
+}
+
+// Authenticator is a login.Authenticator which verifies passwords against the hashes of a UserStore. Once a password is verified, its hash is replaced by one of the Policy if it NeedsRehash, so legacy hashes are upgraded as their users log in. It is safe for concurrent use.
+type Authenticator struct {
+	// Logger, if not nil, receives the errors of rehashing passwords, which don't fail logins. It must not be modified once the Authenticator is used.
+	Logger tocookie.Logger
+
+	users  UserStore
+	policy Policy
+
+	// dummyOnce and dummy are the hash unknown users' passwords are verified against, so they take as long to reject as wrong passwords.
+	dummyOnce sync.Once
+	dummy     string
+}
+
+// New returns an Authenticator of the users, hashing passwords with the policy, e.g. DefaultPolicy.
+func New(users UserStore, policy Policy) *Authenticator {
+	return &Authenticator{users: users, policy: policy}
+}
+
+// Authenticate implements login.Authenticator. Unknown and disabled users, users without a local password, and wrong passwords are rejected with an error wrapping login.ErrInvalidCredentials; empty passwords are always rejected. Other errors of the UserStore, and hashes which can't be parsed, are returned as they are.
+func (a *Authenticator) Authenticate(ctx context.Context, username, password string) (*login.Identity, error) {
+	if username == "" || password == "" {
+		return nil, login.ErrInvalidCredentials
+	}
+	user, err := a.users.User(ctx, username)
+	if errors.Is(err, ErrNoSuchUser) {
+		Verify(password, a.dummyHash())
+		return nil, fmt.Errorf("%w: no such user", login.ErrInvalidCredentials)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getting user: %w", err)
+	}
+	if user.PasswordHash == "" {
+		return nil, fmt.Errorf("%w: user has no local password", login.ErrInvalidCredentials)
+	}
+	if err := Verify(password, user.PasswordHash); err != nil {
+		if errors.Is(err, ErrMismatch) {
+			return nil, fmt.Errorf("%w: wrong password", login.ErrInvalidCredentials)
+		}
+		return nil, fmt.Errorf("verifying password of user '%s': %w", user.Username, err)
+	}
+	if user.Disabled {
+		return nil, fmt.Errorf("%w: user is disabled", login.ErrInvalidCredentials)
+	}
+	if a.policy.NeedsRehash(user.PasswordHash) {
+		a.rehash(ctx, user.Username, password)
+	}
+	return &login.Identity{Username: user.Username, Roles: user.Roles, AuthMethods: []string{tocookie.AuthMethodPassword}}, nil
+}
+
+// rehash replaces the password hash of the user with one of the policy. Errors are logged, as the password has already been verified.
+func (a *Authenticator) rehash(ctx context.Context, username, password string) {
+	hash, err := a.policy.Hash(password)
+	if err == nil {
+		err = a.users.SetPasswordHash(ctx, username, hash)
+	}
+	if err != nil && a.Logger != nil {
+		a.Logger.Warnf("credentials: rehashing password of user '%s': %v", username, err)
+	}
+}
+
+// dummyHash returns a hash of the policy, of no password anyone could log in with.
+func (a *Authenticator) dummyHash() string {
+	a.dummyOnce.Do(func() {
+		a.dummy, _ = a.policy.Hash("\x00")
+	})
+	return a.dummy
+}
+
+// MemoryUsers is a UserStore held in memory, for tests and development. It is safe for concurrent use.
+type MemoryUsers struct {
+	mu    sync.RWMutex
+	users map[string]User
+}
+
+// NewMemoryUsers returns an empty MemoryUsers.
+func NewMemoryUsers() *MemoryUsers {
+	return &MemoryUsers{users: map[string]User{}}
+}
+
+// Add adds the user, replacing any with the same username.
+func (m *MemoryUsers) Add(user User) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	user.Roles = append([]string(nil), user.Roles...)
+	m.users[user.Username] = user
+}
+
+// User implements UserStore.
+func (m *MemoryUsers) User(ctx context.Context, username string) (*User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	user, ok := m.users[username]
+	if !ok {
+		return nil, ErrNoSuchUser
+	}
+	user.Roles = append([]string(nil), user.Roles...)
+	return &user, nil
+}
+
+// SetPasswordHash implements UserStore.
+func (m *MemoryUsers) SetPasswordHash(ctx context.Context, username, hash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	user, ok := m.users[username]
+	if !ok {
+		return ErrNoSuchUser
+	}
+	user.PasswordHash = hash
+	m.users[username] = user
+	return nil
+}
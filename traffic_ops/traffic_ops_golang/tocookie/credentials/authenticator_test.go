@@ -0,0 +1,95 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package credentials
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/tocookie/login"
+)
+
+type testLogger []string
+
+func (l *testLogger) Infof(format string, v ...interface{}) {
+	*l = append(*l, fmt.Sprintf(format, v...))
+}
+func (l *testLogger) Warnf(format string, v ...interface{}) {
+	*l = append(*l, fmt.Sprintf(format, v...))
+}
+
+// failingUsers is a UserStore whose SetPasswordHash fails.
+type failingUsers struct{ *MemoryUsers }
+
+func (failingUsers) SetPasswordHash(ctx context.Context, username, hash string) error {
+	return errors.New("database unavailable")
+}
+
+func TestAuthenticator(t *testing.T) {
+	ctx := context.Background()
+	current, _ := testPolicy.Hash("hunter2")
+	users := NewMemoryUsers()
+	users.Add(User{Username: "alice", PasswordHash: perlSHA1, Roles: []string{"admin"}})
+	users.Add(User{Username: "bob", PasswordHash: current, Roles: []string{"operations"}})
+	users.Add(User{Username: "carol", PasswordHash: perlSHA1, Roles: []string{DisallowedRole}, Disabled: true})
+	users.Add(User{Username: "ldap"})
+	users.Add(User{Username: "broken", PasswordHash: "SCRYPT:x"})
+	a := New(users, testPolicy)
+
+	identity, err := a.Authenticate(ctx, "alice", "hunter2")
+	if err != nil || identity.Username != "alice" || len(identity.Roles) != 1 || identity.Roles[0] != "admin" {
+		t.Fatalf("Authenticate expected identity of alice, actual: %+v %v", identity, err)
+	}
+	if alice, _ := users.User(ctx, "alice"); Scheme(alice.PasswordHash) != SchemeScrypt || Verify("hunter2", alice.PasswordHash) != nil {
+		t.Errorf("Authenticate expected the SHA-1 hash rehashed with scrypt, actual: '%v'", alice.PasswordHash)
+	}
+	if _, err := a.Authenticate(ctx, "alice", "hunter2"); err != nil {
+		t.Errorf("Authenticate with the rehashed password expected nil error, actual: %v", err)
+	}
+	if _, err := a.Authenticate(ctx, "bob", "hunter2"); err != nil {
+		t.Errorf("Authenticate expected nil error, actual: %v", err)
+	}
+	if bob, _ := users.User(ctx, "bob"); bob.PasswordHash != current {
+		t.Errorf("Authenticate expected a hash of the policy kept, actual: '%v'", bob.PasswordHash)
+	}
+
+	tests := map[string]struct{ username, password string }{
+		"wrong password": {"bob", "hunter3"},
+		"unknown user":   {"dave", "hunter2"},
+		"disabled":       {"carol", "hunter2"},
+		"no password":    {"ldap", "hunter2"},
+		"empty password": {"bob", ""},
+	}
+	for name, test := range tests {
+		if _, err := a.Authenticate(ctx, test.username, test.password); !errors.Is(err, login.ErrInvalidCredentials) {
+			t.Errorf("%v: Authenticate expected ErrInvalidCredentials, actual: %v", name, err)
+		}
+	}
+	if carol, _ := users.User(ctx, "carol"); carol.PasswordHash != perlSHA1 {
+		t.Errorf("Authenticate of a disabled user expected their hash kept, actual: '%v'", carol.PasswordHash)
+	}
+	if _, err := a.Authenticate(ctx, "broken", "hunter2"); err == nil || errors.Is(err, login.ErrInvalidCredentials) {
+		t.Errorf("Authenticate with a malformed hash expected an error other than ErrInvalidCredentials, actual: %v", err)
+	}
+
+	logger := &testLogger{}
+	failing := failingUsers{NewMemoryUsers()}
+	failing.Add(User{Username: "alice", PasswordHash: perlSHA1})
+	a = New(failing, testPolicy)
+	a.Logger = logger
+	if _, err := a.Authenticate(ctx, "alice", "hunter2"); err != nil || len(*logger) != 1 {
+		t.Errorf("Authenticate expected a failed rehash logged without failing the login, actual: %v %v", err, *logger)
+	}
+}
@@ -0,0 +1,234 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package credentials hashes and verifies the passwords of Traffic Ops users, in the formats of the local_passwd column of tm_user, so Go login handlers can check them without Perl Traffic Ops:
+//
+//	authenticator := credentials.New(credentials.NewSQLUsers(db), credentials.DefaultPolicy)
+//	handlers := login.New(authenticator, secret)
+//
+// Hashes are told apart by their format:
+//
+//   - "SCRYPT:<N>:<r>:<p>:<base64 salt>:<base64 key>" is scrypt, in the format of Crypt::ScryptKDF, as Perl Traffic Ops and auth.DerivePassword hash passwords.
+//   - "$2a$", "$2b$", and "$2y$" hashes are bcrypt. Perl Traffic Ops can't verify them.
+//   - 40 hex digits are the unsalted SHA-1 of the password, as Perl Traffic Ops hashed passwords before scrypt. They are deprecated, and only verified so users who haven't logged in since can.
+//
+// The Authenticator rehashes passwords whose hashes are weaker than its Policy when their users log in, so legacy hashes are upgraded without resetting passwords.
+package credentials
+
+import (
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/scrypt"
+)
+
+// The schemes of password hashes; see Scheme.
+const (
+	SchemeSHA1   = "sha1"
+	SchemeScrypt = "scrypt"
+	SchemeBcrypt = "bcrypt"
+)
+
+// scryptPrefix is the prefix of scrypt hashes, which is the algorithm name of Crypt::ScryptKDF.
+const scryptPrefix = "SCRYPT:"
+
+// scryptSaltLen and scryptKeyLen are the lengths in bytes of the salts and keys of new scrypt hashes, which are those Perl Traffic Ops hashes passwords with.
+const (
+	scryptSaltLen = 64
+	scryptKeyLen  = 64
+)
+
+// maxScryptN, maxScryptMemory, maxScryptP, and maxScryptKeyLen bound the cost of the scrypt hashes Verify accepts, so a hash edited in the database can't make each login allocate gigabytes, or hash for minutes. scrypt allocates 128·N·r bytes, which is bounded as a whole, as N alone is bounded by maxScryptN.
+const (
+	maxScryptN      = 1 << 20
+	maxScryptMemory = 128 * maxScryptN * 8
+	maxScryptP      = 16
+	maxScryptKeyLen = 1024
+)
+
+// ErrMismatch is returned by Verify when the password doesn't match the hash.
+var ErrMismatch = errors.New("password doesn't match")
+
+// ErrUnknownScheme is returned for hashes of none of the schemes of this package, such as the empty hash of users who authenticate with LDAP.
+var ErrUnknownScheme = errors.New("unknown password hash scheme")
+
+// ErrMalformed is returned for hashes which have the prefix of a scheme, but can't be parsed.
+var ErrMalformed = errors.New("malformed password hash")
+
+// Scheme returns the scheme of the hash, SchemeSHA1, SchemeScrypt, or SchemeBcrypt, or an empty string if it is of none of them.
+func Scheme(hash string) string {
+	switch {
+	case strings.HasPrefix(hash, scryptPrefix):
+		return SchemeScrypt
+	case strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$"):
+		return SchemeBcrypt
+	case isSHA1Hex(hash):
+		return SchemeSHA1
+	}
+	return ""
+}
+
+// isSHA1Hex returns whether the hash is the 40 hex digits of a SHA-1, as Perl's sha1_hex returns it, in lowercase.
+func isSHA1Hex(hash string) bool {
+	if len(hash) != 2*sha1.Size {
+		return false
+	}
+	for _, c := range hash {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// Verify returns nil if the password matches the hash, and ErrMismatch if it doesn't. Hashes of unknown schemes are rejected with ErrUnknownScheme, and hashes which can't be parsed with ErrMalformed. Digests are compared in constant time.
+func Verify(password, hash string) error {
+	switch Scheme(hash) {
+	case SchemeScrypt:
+		return verifyScrypt(password, hash)
+	case SchemeBcrypt:
+		if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)); err != nil {
+			if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+				return ErrMismatch
+			}
+			return fmt.Errorf("%w: %w", ErrMalformed, err)
+		}
+		return nil
+	case SchemeSHA1:
+		sum := sha1.Sum([]byte(password))
+		if subtle.ConstantTimeCompare([]byte(hex.EncodeToString(sum[:])), []byte(hash)) != 1 {
+			return ErrMismatch
+		}
+		return nil
+	}
+	return ErrUnknownScheme
+}
+
+// scryptHash is a parsed scrypt hash.
+type scryptHash struct {
+	n, r, p   int
+	salt, key []byte
+}
+
+// parseScrypt parses a scrypt hash in the format of Crypt::ScryptKDF.
+func parseScrypt(hash string) (scryptHash, error) {
+	parts := strings.Split(strings.TrimPrefix(hash, scryptPrefix), ":")
+	if len(parts) != 5 {
+		return scryptHash{}, fmt.Errorf("%w: scrypt hash has %d fields, not 6", ErrMalformed, len(parts)+1)
+	}
+	h := scryptHash{}
+	params := []*int{&h.n, &h.r, &h.p}
+	for i, param := range params {
+		v, err := strconv.Atoi(parts[i])
+		if err != nil || v < 1 {
+			return scryptHash{}, fmt.Errorf("%w: scrypt parameter '%s' isn't a positive integer", ErrMalformed, parts[i])
+		}
+		*param = v
+	}
+	if h.n > maxScryptN {
+		return scryptHash{}, fmt.Errorf("%w: scrypt N %d is larger than %d", ErrMalformed, h.n, maxScryptN)
+	}
+	// the bound is divided rather than N multiplied, so large r can't overflow
+	if h.r > maxScryptMemory/(128*h.n) {
+		return scryptHash{}, fmt.Errorf("%w: scrypt N %d and r %d need more than %d bytes", ErrMalformed, h.n, h.r, maxScryptMemory)
+	}
+	if h.p > maxScryptP {
+		return scryptHash{}, fmt.Errorf("%w: scrypt p %d is larger than %d", ErrMalformed, h.p, maxScryptP)
+	}
+	var err error
+	if h.salt, err = base64.StdEncoding.DecodeString(parts[3]); err != nil || len(h.salt) == 0 {
+		return scryptHash{}, fmt.Errorf("%w: decoding scrypt salt: %v", ErrMalformed, err)
+	}
+	if h.key, err = base64.StdEncoding.DecodeString(parts[4]); err != nil || len(h.key) == 0 {
+		return scryptHash{}, fmt.Errorf("%w: decoding scrypt key: %v", ErrMalformed, err)
+	}
+	if len(h.key) > maxScryptKeyLen {
+		return scryptHash{}, fmt.Errorf("%w: scrypt key of %d bytes is longer than %d", ErrMalformed, len(h.key), maxScryptKeyLen)
+	}
+	return h, nil
+}
+
+func verifyScrypt(password, hash string) error {
+	h, err := parseScrypt(hash)
+	if err != nil {
+		return err
+	}
+	key, err := scrypt.Key([]byte(password), h.salt, h.n, h.r, h.p, len(h.key))
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrMalformed, err)
+	}
+	if subtle.ConstantTimeCompare(key, h.key) != 1 {
+		return ErrMismatch
+	}
+	return nil
+}
+
+// Policy is how new passwords are hashed, and which hashes are weak enough to be rehashed on login; see NeedsRehash.
+type Policy struct {
+	// Scheme is the scheme of new hashes, SchemeScrypt or SchemeBcrypt. Passwords can't be hashed with SchemeSHA1.
+	Scheme string
+	// ScryptN, ScryptR, and ScryptP are the parameters of new scrypt hashes. N must be a power of 2.
+	ScryptN, ScryptR, ScryptP int
+	// BcryptCost is the cost of new bcrypt hashes.
+	BcryptCost int
+}
+
+// DefaultPolicy hashes passwords with scrypt, with the parameters of Perl Traffic Ops, so both can verify them.
+var DefaultPolicy = Policy{Scheme: SchemeScrypt, ScryptN: 16384, ScryptR: 8, ScryptP: 1, BcryptCost: bcrypt.DefaultCost}
+
+// Hash hashes the password with the scheme of the policy, and a random salt.
+func (p Policy) Hash(password string) (string, error) {
+	switch p.Scheme {
+	case SchemeScrypt:
+		salt := make([]byte, scryptSaltLen)
+		if _, err := rand.Read(salt); err != nil {
+			return "", fmt.Errorf("generating salt: %w", err)
+		}
+		key, err := scrypt.Key([]byte(password), salt, p.ScryptN, p.ScryptR, p.ScryptP, scryptKeyLen)
+		if err != nil {
+			return "", fmt.Errorf("hashing password: %w", err)
+		}
+		return fmt.Sprintf("%s%d:%d:%d:%s:%s", scryptPrefix, p.ScryptN, p.ScryptR, p.ScryptP, base64.StdEncoding.EncodeToString(salt), base64.StdEncoding.EncodeToString(key)), nil
+	case SchemeBcrypt:
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), p.BcryptCost)
+		if err != nil {
+			return "", fmt.Errorf("hashing password: %w", err)
+		}
+		return string(hash), nil
+	}
+	return "", fmt.Errorf("%w '%s' of policy", ErrUnknownScheme, p.Scheme)
+}
+
+// NeedsRehash returns whether the hash should be replaced by one of the policy: if it is of another scheme, or of the same one, but with weaker parameters. SHA-1 hashes always need rehashing. Hashes with stronger parameters than the policy are kept.
+func (p Policy) NeedsRehash(hash string) bool {
+	scheme := Scheme(hash)
+	if scheme != p.Scheme {
+		return true
+	}
+	switch scheme {
+	case SchemeScrypt:
+		h, err := parseScrypt(hash)
+		return err != nil || h.n < p.ScryptN || h.r < p.ScryptR || h.p < p.ScryptP || len(h.key) < scryptKeyLen
+	case SchemeBcrypt:
+		cost, err := bcrypt.Cost([]byte(hash))
+		return err != nil || cost < p.BcryptCost
+	}
+	return true
+}
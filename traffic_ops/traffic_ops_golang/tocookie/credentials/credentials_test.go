@@ -0,0 +1,106 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package credentials
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// perlScrypt is the hash of "hunter2" in the format of Crypt::ScryptKDF, with N=1024, computed independently of this package.
+const perlScrypt = "SCRYPT:1024:8:1:MDEyMzQ1Njc4OWFiY2RlZg==:xhygCB++/lnqkJuXyqpuqIwyXp1fZuC+q3d168khIUDajkfIIk9tM99LSQHE4qtMbYgZ/Uq1IpPLlEYBBCF2dQ=="
+
+// perlSHA1 is sha1_hex("hunter2"), a legacy Perl Traffic Ops hash.
+const perlSHA1 = "f3bbbd66a63d4bf1747940578ec3d0103530e21d"
+
+// testPolicy is a policy cheap enough to hash with in tests.
+var testPolicy = Policy{Scheme: SchemeScrypt, ScryptN: 16, ScryptR: 8, ScryptP: 1, BcryptCost: 4}
+
+func TestVerify(t *testing.T) {
+	tests := map[string]struct {
+		password, hash string
+		expected       error
+	}{
+		"scrypt":               {"hunter2", perlScrypt, nil},
+		"scrypt wrong":         {"hunter3", perlScrypt, ErrMismatch},
+		"sha1":                 {"hunter2", perlSHA1, nil},
+		"sha1 wrong":           {"hunter3", perlSHA1, ErrMismatch},
+		"bcrypt":               {"U*U", "$2a$05$CCCCCCCCCCCCCCCCCCCCC.E5YPO9kmyuRGyh0XouQYb4YMJKvyOeW", nil},
+		"bcrypt wrong":         {"U*V", "$2a$05$CCCCCCCCCCCCCCCCCCCCC.E5YPO9kmyuRGyh0XouQYb4YMJKvyOeW", ErrMismatch},
+		"bcrypt truncated":     {"U*U", "$2a$05$CCCCCCCCCC", ErrMalformed},
+		"empty":                {"", "", ErrUnknownScheme},
+		"uppercase sha1":       {"hunter2", strings.ToUpper(perlSHA1), ErrUnknownScheme},
+		"plaintext":            {"hunter2", "hunter2", ErrUnknownScheme},
+		"scrypt fields":        {"hunter2", "SCRYPT:1024:8:1:MDEy", ErrMalformed},
+		"scrypt parameter":     {"hunter2", "SCRYPT:x:8:1:MDEy:MDEy", ErrMalformed},
+		"scrypt N too large":   {"hunter2", "SCRYPT:16777216:8:1:MDEy:MDEy", ErrMalformed},
+		"scrypt N not power 2": {"hunter2", "SCRYPT:1000:8:1:MDEy:MDEy", ErrMalformed},
+		"scrypt salt":          {"hunter2", "SCRYPT:1024:8:1:!:MDEy", ErrMalformed},
+		"scrypt r too large":   {"hunter2", "SCRYPT:1048576:1024:1:MDEy:MDEy", ErrMalformed},
+		"scrypt r overflow":    {"hunter2", "SCRYPT:1024:9223372036854775807:1:MDEy:MDEy", ErrMalformed},
+		"scrypt p too large":   {"hunter2", "SCRYPT:1024:8:1024:MDEy:MDEy", ErrMalformed},
+		"scrypt key too long":  {"hunter2", "SCRYPT:1024:8:1:MDEy:" + strings.Repeat("A", (maxScryptKeyLen+3)/3*4), ErrMalformed},
+	}
+	for name, test := range tests {
+		if err := Verify(test.password, test.hash); !errors.Is(err, test.expected) || (test.expected == nil) != (err == nil) {
+			t.Errorf("%v: Verify expected %v, actual: %v", name, test.expected, err)
+		}
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	bcryptPolicy := testPolicy
+	bcryptPolicy.Scheme = SchemeBcrypt
+	for _, policy := range []Policy{testPolicy, bcryptPolicy} {
+		hash, err := policy.Hash("hunter2")
+		if err != nil || Scheme(hash) != policy.Scheme {
+			t.Fatalf("Hash expected %v hash, actual: '%v' %v", policy.Scheme, hash, err)
+		}
+		if err := Verify("hunter2", hash); err != nil {
+			t.Errorf("Verify of %v hash expected nil error, actual: %v", policy.Scheme, err)
+		}
+		if other, _ := policy.Hash("hunter2"); other == hash {
+			t.Errorf("Hash of %v expected a random salt, actual: the same hash twice", policy.Scheme)
+		}
+		if policy.NeedsRehash(hash) {
+			t.Errorf("NeedsRehash of a %v hash of the policy expected false, actual: true", policy.Scheme)
+		}
+	}
+
+	if hash, err := DefaultPolicy.Hash("hunter2"); err != nil || !strings.HasPrefix(hash, "SCRYPT:16384:8:1:") {
+		t.Errorf("DefaultPolicy expected the scrypt parameters of Perl Traffic Ops, actual: '%v' %v", hash, err)
+	}
+	if _, err := (Policy{Scheme: SchemeSHA1}).Hash("hunter2"); !errors.Is(err, ErrUnknownScheme) {
+		t.Errorf("Hash with SHA-1 expected ErrUnknownScheme, actual: %v", err)
+	}
+
+	tests := map[string]struct {
+		policy   Policy
+		hash     string
+		expected bool
+	}{
+		"sha1":             {testPolicy, perlSHA1, true},
+		"weaker scrypt":    {DefaultPolicy, perlScrypt, true},
+		"stronger scrypt":  {testPolicy, perlScrypt, false},
+		"other scheme":     {bcryptPolicy, perlScrypt, true},
+		"weaker bcrypt":    {Policy{Scheme: SchemeBcrypt, BcryptCost: 10}, "$2a$05$CCCCCCCCCCCCCCCCCCCCC.E5YPO9kmyuRGyh0XouQYb4YMJKvyOeW", true},
+		"stronger bcrypt":  {bcryptPolicy, "$2a$05$CCCCCCCCCCCCCCCCCCCCC.E5YPO9kmyuRGyh0XouQYb4YMJKvyOeW", false},
+		"malformed scrypt": {testPolicy, "SCRYPT:x", true},
+	}
+	for name, test := range tests {
+		if actual := test.policy.NeedsRehash(test.hash); actual != test.expected {
+			t.Errorf("%v: NeedsRehash expected %v, actual: %v", name, test.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package credentials
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// DisallowedRole is the role of Traffic Ops users who may not log in.
+const DisallowedRole = "disallowed"
+
+const selectUserQuery = `SELECT u.username, u.local_passwd, r.name FROM tm_user u LEFT JOIN role r ON r.id = u.role WHERE u.username = $1`
+
+const updatePasswordQuery = `UPDATE tm_user SET local_passwd = $1 WHERE username = $2`
+
+// SQLUsers is a UserStore of the tm_user table of the Traffic Ops database. Users of DisallowedRole are Disabled. It is safe for concurrent use.
+type SQLUsers struct {
+	db *sql.DB
+}
+
+// NewSQLUsers returns the SQLUsers of the database, typically the *sql.DB of the lib/pq driver, e.g. the DB of the sqlx.DB of Traffic Ops.
+func NewSQLUsers(db *sql.DB) *SQLUsers {
+	return &SQLUsers{db: db}
+}
+
+// User implements UserStore.
+func (s *SQLUsers) User(ctx context.Context, username string) (*User, error) {
+	var hash, role sql.NullString
+	user := &User{}
+	err := s.db.QueryRowContext(ctx, selectUserQuery, username).Scan(&user.Username, &hash, &role)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNoSuchUser
+	}
+	if err != nil {
+		return nil, fmt.Errorf("querying user: %w", err)
+	}
+	user.PasswordHash = hash.String
+	if role.Valid {
+		user.Roles = []string{role.String}
+	}
+	user.Disabled = role.String == DisallowedRole
+	return user, nil
+}
+
+// SetPasswordHash implements UserStore.
+func (s *SQLUsers) SetPasswordHash(ctx context.Context, username, hash string) error {
+	result, err := s.db.ExecContext(ctx, updatePasswordQuery, hash, username)
+	if err != nil {
+		return fmt.Errorf("updating password: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrNoSuchUser
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package credentials
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+)
+
+// fakeUser is a row of the fake tm_user table, joined with its role. Nil values are NULL.
+type fakeUser struct {
+	hash, role interface{}
+}
+
+// fakeDB is a database/sql driver holding tm_user in memory, which understands only the queries of SQLUsers.
+type fakeDB struct {
+	mu    sync.Mutex
+	users map[string]fakeUser
+}
+
+func (d *fakeDB) Open(string) (driver.Conn, error) { return fakeConn{d}, nil }
+
+type fakeConn struct{ d *fakeDB }
+
+func (c fakeConn) Prepare(query string) (driver.Stmt, error) { return fakeStmt{c.d, query}, nil }
+func (c fakeConn) Close() error                              { return nil }
+func (c fakeConn) Begin() (driver.Tx, error)                 { return nil, errors.New("transactions not supported") }
+
+type fakeStmt struct {
+	d     *fakeDB
+	query string
+}
+
+func (s fakeStmt) Close() error  { return nil }
+func (s fakeStmt) NumInput() int { return -1 }
+
+func (s fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+	if s.query != updatePasswordQuery {
+		return nil, errors.New("unexpected query: " + s.query)
+	}
+	user, ok := s.d.users[args[1].(string)]
+	if !ok {
+		return driver.RowsAffected(0), nil
+	}
+	user.hash = args[0]
+	s.d.users[args[1].(string)] = user
+	return driver.RowsAffected(1), nil
+}
+
+func (s fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+	if s.query != selectUserQuery {
+		return nil, errors.New("unexpected query: " + s.query)
+	}
+	rows := &fakeRows{}
+	if user, ok := s.d.users[args[0].(string)]; ok {
+		rows.values = [][]driver.Value{{args[0], user.hash, user.role}}
+	}
+	return rows, nil
+}
+
+type fakeRows struct{ values [][]driver.Value }
+
+func (r *fakeRows) Columns() []string { return []string{"username", "local_passwd", "name"} }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+var fakeDriverOnce sync.Once
+var fakeDriver = &fakeDB{}
+
+func TestSQLUsers(t *testing.T) {
+	fakeDriverOnce.Do(func() { sql.Register("credentials-fake", fakeDriver) })
+	fakeDriver.users = map[string]fakeUser{
+		"alice":  {perlSHA1, "admin"},
+		"carol":  {perlSHA1, DisallowedRole},
+		"ldap":   {nil, "operations"},
+		"orphan": {perlSHA1, nil},
+	}
+	db, err := sql.Open("credentials-fake", "")
+	if err != nil {
+		t.Fatalf("sql.Open expected nil error, actual: %v", err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+	users := NewSQLUsers(db)
+
+	tests := map[string]struct {
+		username string
+		expected User
+	}{
+		"user":     {"alice", User{Username: "alice", PasswordHash: perlSHA1, Roles: []string{"admin"}}},
+		"disabled": {"carol", User{Username: "carol", PasswordHash: perlSHA1, Roles: []string{DisallowedRole}, Disabled: true}},
+		"ldap":     {"ldap", User{Username: "ldap", Roles: []string{"operations"}}},
+		"no role":  {"orphan", User{Username: "orphan", PasswordHash: perlSHA1}},
+	}
+	for name, test := range tests {
+		user, err := users.User(ctx, test.username)
+		if err != nil || user.Username != test.expected.Username || user.PasswordHash != test.expected.PasswordHash || user.Disabled != test.expected.Disabled || len(user.Roles) != len(test.expected.Roles) || len(user.Roles) == 1 && user.Roles[0] != test.expected.Roles[0] {
+			t.Errorf("%v: User expected %+v, actual: %+v %v", name, test.expected, user, err)
+		}
+	}
+	if _, err := users.User(ctx, "dave"); !errors.Is(err, ErrNoSuchUser) {
+		t.Errorf("User of an unknown user expected ErrNoSuchUser, actual: %v", err)
+	}
+
+	if err := users.SetPasswordHash(ctx, "alice", perlScrypt); err != nil {
+		t.Errorf("SetPasswordHash expected nil error, actual: %v", err)
+	}
+	if alice, err := users.User(ctx, "alice"); err != nil || alice.PasswordHash != perlScrypt {
+		t.Errorf("SetPasswordHash expected the hash replaced, actual: %+v %v", alice, err)
+	}
+	if err := users.SetPasswordHash(ctx, "dave", perlScrypt); !errors.Is(err, ErrNoSuchUser) {
+		t.Errorf("SetPasswordHash of an unknown user expected ErrNoSuchUser, actual: %v", err)
+	}
+}
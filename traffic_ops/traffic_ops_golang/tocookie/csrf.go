@@ -0,0 +1,115 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"crypto/hmac"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// CSRFHeader is the header CSRFMiddleware sets the CSRF token of the session in, and reads it from state-changing requests.
+const CSRFHeader = "X-CSRF-Token"
+
+// CSRFFormField is the form field CSRFMiddleware reads the CSRF token from, for form posts which can't set CSRFHeader.
+const CSRFFormField = "csrf_token"
+
+// csrfTokenInfo is the HKDF info of the CSRF tokens of sessions.
+const csrfTokenInfo = "tocookie csrf token v1"
+
+// errNoSessionID is returned for CSRF tokens of cookies without a SessionID, which can't have one.
+var errNoSessionID = errors.New("cookie has no session id")
+
+// NewCSRFToken returns the CSRF token of the cookie's session: an HKDF-SHA256 of the secret, salted with the SessionID, so it can't be computed without the secret, and is the same for every cookie of the session, however often it is refreshed. Pages send it back with state-changing requests, which a cross-site request can't do, as it can't read the token; see ValidateCSRFToken and CSRFMiddleware. The cookie must have been verified, e.g. by Parse, and have a SessionID; cookies minted before session IDs, and by Perl, have none until they are refreshed.
+func NewCSRFToken(c *Cookie, secret string) (string, error) {
+	if c.SessionID == "" {
+		return "", errNoSessionID
+	}
+	return base64.RawURLEncoding.EncodeToString(deriveKey([]byte(secret), []byte(c.SessionID), csrfTokenInfo)), nil
+}
+
+// ValidateCSRFToken returns nil if the token is the CSRF token of the cookie's session, as NewCSRFToken returns it, and an error wrapping ErrCSRFTokenInvalid otherwise. Tokens are compared in constant time.
+func ValidateCSRFToken(c *Cookie, secret, token string) error {
+	expected, err := NewCSRFToken(c, secret)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrCSRFTokenInvalid, err)
+	}
+	if token == "" {
+		return fmt.Errorf("%w: no token", ErrCSRFTokenInvalid)
+	}
+	if !hmac.Equal([]byte(token), []byte(expected)) {
+		return ErrCSRFTokenInvalid
+	}
+	return nil
+}
+
+// CSRFMiddleware returns a handler which enforces CSRF tokens on requests authenticated by Middleware, before passing them to next. It must be given the secret of Middleware, and its WithCookieName if it has one, and be wrapped by it, so the cookie is in the request context. Every response to an authenticated request carries the CSRF token of its session in CSRFHeader, for pages to send back. State-changing requests, with methods other than GET, HEAD, OPTIONS, and TRACE, are only passed to next if they carry the token in CSRFHeader, or the CSRFFormField of a form, and are otherwise answered with 403 Forbidden.
+//
+// Requests authenticated with a bearer token rather than the cookie are passed as they are, as browsers don't send bearer tokens by themselves, so they can't be forged cross-site. Requests without an authenticated cookie are forbidden if they change state.
+func CSRFMiddleware(secret string, next http.Handler, opts ...Option) http.Handler {
+	o := newOptions(opts)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := o.requestCookie(r); err != nil {
+			if _, authenticated := FromContext(r.Context()); authenticated || safeMethod(r.Method) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+		c, ok := FromContext(r.Context())
+		if ok {
+			if token, err := NewCSRFToken(c, secret); err == nil {
+				w.Header().Set(CSRFHeader, token)
+			}
+		}
+		if safeMethod(r.Method) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if ok && ValidateCSRFToken(c, secret, requestCSRFToken(r)) == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+	})
+}
+
+// safeMethod returns whether requests of the method don't change state, and so needn't carry a CSRF token.
+func safeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+// requestCSRFToken returns the CSRF token of the request, from CSRFHeader, or CSRFFormField if it has no header.
+func requestCSRFToken(r *http.Request) string {
+	if token := r.Header.Get(CSRFHeader); token != "" {
+		return token
+	}
+	return r.PostFormValue(CSRFFormField)
+}
+
+// NewCSRFToken returns the CSRF token of the cookie's session with the active secret, like the package-level NewCSRFToken.
+func (m *Manager) NewCSRFToken(c *Cookie) (string, error) {
+	return NewCSRFToken(c, m.Secret())
+}
+
+// ValidateCSRFToken validates a CSRF token of the cookie's session with the active secret, like the package-level ValidateCSRFToken.
+func (m *Manager) ValidateCSRFToken(c *Cookie, token string) error {
+	return ValidateCSRFToken(c, m.Secret(), token)
+}
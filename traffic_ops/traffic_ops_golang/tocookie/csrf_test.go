@@ -0,0 +1,139 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCSRFToken(t *testing.T) {
+	secret := "secret"
+	c, err := Parse(secret, New("alice", time.Now().Add(time.Hour), secret))
+	if err != nil {
+		t.Fatalf("Parse expected nil error, actual: %v", err)
+	}
+	token, err := NewCSRFToken(c, secret)
+	if err != nil || len(token) != 43 {
+		t.Fatalf("NewCSRFToken expected 256-bit base64url token, actual: '%v' %v", token, err)
+	}
+	if err := ValidateCSRFToken(c, secret, token); err != nil {
+		t.Errorf("ValidateCSRFToken expected nil error, actual: %v", err)
+	}
+
+	refreshed, err := Parse(secret, Refresh(c, secret))
+	if err != nil {
+		t.Fatalf("Parse of refreshed cookie expected nil error, actual: %v", err)
+	}
+	if err := ValidateCSRFToken(refreshed, secret, token); err != nil {
+		t.Errorf("ValidateCSRFToken of refreshed cookie expected nil error, actual: %v", err)
+	}
+
+	other, _ := Parse(secret, New("alice", time.Now().Add(time.Hour), secret))
+	for name, test := range map[string]struct {
+		c      *Cookie
+		secret string
+		token  string
+	}{
+		"other session": {other, secret, token},
+		"other secret":  {c, "other", token},
+		"empty":         {c, secret, ""},
+		"truncated":     {c, secret, token[:42]},
+		"no session":    {&Cookie{AuthData: "alice"}, secret, token},
+	} {
+		if err := ValidateCSRFToken(test.c, test.secret, test.token); !errors.Is(err, ErrCSRFTokenInvalid) {
+			t.Errorf("%v: ValidateCSRFToken expected ErrCSRFTokenInvalid, actual: %v", name, err)
+		}
+	}
+	if _, err := NewCSRFToken(&Cookie{AuthData: "alice"}, secret); err == nil {
+		t.Errorf("NewCSRFToken of cookie without session expected error, actual: nil")
+	}
+
+	m := NewManager(secret)
+	if actual, err := m.NewCSRFToken(c); err != nil || actual != token {
+		t.Errorf("Manager.NewCSRFToken expected '%v', actual: '%v' %v", token, actual, err)
+	}
+	if err := m.ValidateCSRFToken(c, token); err != nil {
+		t.Errorf("Manager.ValidateCSRFToken expected nil error, actual: %v", err)
+	}
+}
+
+func TestCSRFMiddleware(t *testing.T) {
+	secret := "secret"
+	cookie := New("alice", time.Now().Add(time.Hour), secret)
+	c, _ := Parse(secret, cookie)
+	token, _ := NewCSRFToken(c, secret)
+	handler := Middleware(secret, CSRFMiddleware(secret, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})))
+
+	tests := map[string]struct {
+		method string
+		cookie bool
+		bearer bool
+		header string
+		form   string
+		status int
+	}{
+		"get":           {http.MethodGet, true, false, "", "", http.StatusOK},
+		"head":          {http.MethodHead, true, false, "", "", http.StatusOK},
+		"post header":   {http.MethodPost, true, false, token, "", http.StatusOK},
+		"post form":     {http.MethodPost, true, false, "", token, http.StatusOK},
+		"post none":     {http.MethodPost, true, false, "", "", http.StatusForbidden},
+		"delete wrong":  {http.MethodDelete, true, false, "wrong", "", http.StatusForbidden},
+		"put bearer":    {http.MethodPut, false, true, "", "", http.StatusOK},
+		"post no login": {http.MethodPost, false, false, token, "", http.StatusUnauthorized},
+	}
+	for name, test := range tests {
+		body := ""
+		if test.form != "" {
+			body = url.Values{CSRFFormField: {test.form}}.Encode()
+		}
+		r := httptest.NewRequest(test.method, "/", strings.NewReader(body))
+		if test.form != "" {
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+		if test.cookie {
+			r.AddCookie(&http.Cookie{Name: Name, Value: cookie})
+		}
+		if test.bearer {
+			r.Header.Set("Authorization", "Bearer "+cookie)
+		}
+		if test.header != "" {
+			r.Header.Set(CSRFHeader, test.header)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != test.status {
+			t.Errorf("%v: CSRFMiddleware expected status %v, actual: %v", name, test.status, w.Code)
+		}
+		if actual := w.Header().Get(CSRFHeader); test.cookie && actual != token {
+			t.Errorf("%v: CSRFMiddleware expected token header '%v', actual: '%v'", name, token, actual)
+		}
+	}
+
+	unauthenticated := CSRFMiddleware(secret, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	r := httptest.NewRequest(http.MethodPost, "/", nil)
+	r.AddCookie(&http.Cookie{Name: Name, Value: cookie})
+	r.Header.Set(CSRFHeader, token)
+	w := httptest.NewRecorder()
+	unauthenticated.ServeHTTP(w, r)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("CSRFMiddleware without Middleware expected status 403, actual: %v", w.Code)
+	}
+}
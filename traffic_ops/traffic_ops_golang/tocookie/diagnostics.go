@@ -0,0 +1,71 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// secretFingerprintLen is the number of bytes of the SHA-256 of a key in its fingerprint: enough to tell the secrets of a cluster apart, and too few to help guess them.
+const secretFingerprintLen = 4
+
+// WithSignatureDiagnostics makes Parse, and the functions which verify cookies as it does, reject cookies whose HMAC doesn't match with a *SignatureError, which says which key the cookie was verified with, and what it was verified over, rather than with ErrBadSignature itself. It is for finding which node of a Traffic Ops cluster, Perl or Go, has a different secret: each logs the fingerprint of its own secret, to be compared with SecretFingerprint of the others'.
+//
+// The fingerprint doesn't reveal the secret, but the error does contain the signed part of the cookie, so it should only be enabled while debugging, and logged where cookies may be.
+func WithSignatureDiagnostics() Option {
+	return func(o *options) { o.signatureDiagnostics = true }
+}
+
+// SignatureError is the error of cookies whose HMAC doesn't match, given WithSignatureDiagnostics. It matches ErrBadSignature with errors.Is.
+type SignatureError struct {
+	// KeyFingerprint is the SecretFingerprint of the key the cookie was verified with. Given WithPerUserKeys, it is that of the key derived for the user of the cookie, not of the secret.
+	KeyFingerprint string
+	// KeyID is the ID of the key version 2 cookies say they are signed with, if any; see WithKeyID.
+	KeyID string
+	// Version is the format version of the cookie.
+	Version int
+	// Signed is the part of the cookie the HMAC was computed over, which is its first len(Signed) bytes, after WithTrim and WithURLEncoding. Associated data given by WithAssociatedData is signed along with it.
+	Signed string
+}
+
+func (e *SignatureError) Error() string {
+	kid := ""
+	if e.KeyID != "" {
+		kid = fmt.Sprintf(", signed with key ID '%s'", e.KeyID)
+	}
+	return fmt.Sprintf("%s: version %d cookie bytes [0:%d) verified with key %s%s", ErrBadSignature, e.Version, len(e.Signed), e.KeyFingerprint, kid)
+}
+
+func (e *SignatureError) Unwrap() error {
+	return ErrBadSignature
+}
+
+// SecretFingerprint returns a fingerprint of the secret, the hex of the first 4 bytes of its SHA-256, which can be logged and compared across nodes without revealing the secret.
+func SecretFingerprint(secret string) string {
+	return keyFingerprint([]byte(secret))
+}
+
+func keyFingerprint(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:secretFingerprintLen])
+}
+
+// badSignature returns the error of the split cookie, whose HMAC with the key doesn't match: ErrBadSignature, or a *SignatureError given WithSignatureDiagnostics.
+func (o *options) badSignature(s signedCookie, key []byte) error {
+	if !o.signatureDiagnostics {
+		return ErrBadSignature
+	}
+	return &SignatureError{KeyFingerprint: keyFingerprint(key), KeyID: s.header.Kid, Version: s.version, Signed: s.signed}
+}
@@ -0,0 +1,74 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWithSignatureDiagnostics(t *testing.T) {
+	expires := time.Now().Add(time.Hour)
+	tests := map[string]struct {
+		cookie, secret string
+		opts           []Option
+		kid            string
+		version        int
+	}{
+		"v0":             {New("alice", expires, "other"), "secret", nil, "", Version0},
+		"v2 with key id": {New("alice", expires, "other", WithKeyID("2024"), WithCompression(CodecGzip)), "secret", nil, "2024", Version2},
+		"parser":         {New("alice", expires, "other", WithVersion(Version1)), "secret", nil, "", Version1},
+		"per-user key":   {New("alice", expires, "other", WithPerUserKeys()), "secret", []Option{WithPerUserKeys()}, "", Version0},
+	}
+	for name, test := range tests {
+		opts := append(test.opts, WithSignatureDiagnostics())
+		var err error
+		if name == "parser" {
+			_, err = NewParser(test.secret, opts...).Parse(test.cookie)
+		} else {
+			_, err = Parse(test.secret, test.cookie, opts...)
+		}
+		sigErr := (*SignatureError)(nil)
+		if !errors.As(err, &sigErr) || !errors.Is(err, ErrBadSignature) {
+			t.Errorf("%v: Parse WithSignatureDiagnostics expected *SignatureError, actual: %v", name, err)
+			continue
+		}
+		if sigErr.KeyID != test.kid || sigErr.Version != test.version || !strings.HasPrefix(test.cookie, sigErr.Signed) || len(sigErr.Signed) == 0 {
+			t.Errorf("%v: SignatureError expected key ID '%v' and version %v, signing a prefix of the cookie, actual: %+v", name, test.kid, test.version, sigErr)
+		}
+		if fingerprint := SecretFingerprint(test.secret); (sigErr.KeyFingerprint == fingerprint) == (name == "per-user key") {
+			t.Errorf("%v: SignatureError expected the fingerprint of the verifying key, actual: %v (secret %v)", name, sigErr.KeyFingerprint, fingerprint)
+		}
+	}
+
+	cookie := New("alice", expires, "other")
+	if _, err := Parse("secret", cookie); err != ErrBadSignature {
+		t.Errorf("Parse without WithSignatureDiagnostics expected ErrBadSignature itself, actual: %v", err)
+	}
+	_, err := Parse("secret", cookie, WithSignatureDiagnostics())
+	if msg := err.Error(); !strings.HasPrefix(msg, ErrBadSignature.Error()) || !strings.Contains(msg, SecretFingerprint("secret")) {
+		t.Errorf("SignatureError expected message with the key fingerprint, actual: %v", msg)
+	}
+}
+
+func TestSecretFingerprint(t *testing.T) {
+	// the SHA-256 of "secret" is 2bb80d53...
+	if fingerprint := SecretFingerprint("secret"); fingerprint != "2bb80d53" {
+		t.Errorf("SecretFingerprint expected '2bb80d53', actual: '%v'", fingerprint)
+	}
+	if SecretFingerprint("secret") == SecretFingerprint("secret2") {
+		t.Errorf("SecretFingerprint expected different secrets to have different fingerprints")
+	}
+}
@@ -0,0 +1,122 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"crypto"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// DumpInfo is the component parts of a cookie, as found by Dump. None of it has been verified.
+type DumpInfo struct {
+	// Version is the format version of the cookie.
+	Version int
+	// Signed is the part of the cookie covered by the signature.
+	Signed string
+	// Payload is the raw base64 payload segment.
+	Payload string
+	// Signature is the raw hex signature segment.
+	Signature string
+	// SplitAt is the byte offset at which the signature was split from the signed part, or -1 if it wasn't found.
+	SplitAt int
+	// Compression is the name of the codec version 2 cookies are compressed with, if any.
+	Compression string
+	// Encryption is the encryption version 2 cookies are sealed with, if any. Encrypted payloads aren't decrypted, as that needs the key.
+	Encryption string
+	// Algorithm is the signature algorithm version 2 cookies say they are signed with, if it isn't an HMAC; see WithSigner.
+	Algorithm string
+	// KeyID is the ID of the key version 2 cookies say they are signed with, if any.
+	KeyID string
+	// Encoding describes the base64 encoding the payload was decoded with. Payloads which are valid in more than one encoding decode to the same bytes in each, and are described as the first.
+	Encoding string
+	// Hash is the hash function whose digest is as long as the signature, or zero if there is none. It is only a guess, as truncated tags can't be told apart from shorter digests.
+	Hash crypto.Hash
+	// Decoded is the decoded, and decompressed, payload, normally JSON.
+	Decoded string
+	// Claims is the payload decoded as the claims of a cookie, or nil if it couldn't be.
+	Claims *Cookie
+}
+
+// dumpHashes are the hashes Dump guesses from the length of the signature.
+var dumpHashes = []crypto.Hash{crypto.SHA1, crypto.SHA256, crypto.SHA384, crypto.SHA512}
+
+// Dump splits and decodes a cookie of any registered version into its component parts, for diagnosing cookies which won't parse. The signature is NOT verified, and no claims are validated; like the claims returned by ParseUnverified, nothing in the DumpInfo may be trusted.
+//
+// If the cookie can't be entirely decoded, the parts found before the failure are returned along with the error. Dump never panics, whatever its input.
+func Dump(cookie string) (DumpInfo, error) {
+	info := DumpInfo{SplitAt: strings.LastIndex(cookie, "--")}
+	info.Version, _ = splitVersion(cookie)
+	if info.SplitAt != -1 {
+		info.Signature = cookie[info.SplitAt+2:]
+	}
+
+	o := newOptions(nil)
+	s, err := splitCookie(cookie, o)
+	if err != nil {
+		return info, err
+	}
+	info.Signed, info.Payload, info.Compression, info.Encryption, info.Algorithm, info.KeyID = s.signed, s.payload, s.header.Zip, s.header.Enc, s.header.Alg, s.header.Kid
+	info.SplitAt, info.Signature = len(s.signed), cookie[strings.LastIndex(cookie, "-")+1:]
+	for _, hash := range dumpHashes {
+		if hash.Size() == len(s.sig) {
+			info.Hash = hash
+		}
+	}
+
+	txtBytes, encoding, err := dumpPayload(s)
+	if err != nil {
+		return info, err
+	}
+	info.Encoding = encoding
+	if s.header.Enc != "" {
+		return info, errEncryptedUnverified
+	}
+	if s.header.Zip != "" {
+		if txtBytes, err = decompress(s.header.Zip, txtBytes, o.maxDecompressedSize); err != nil {
+			return info, err
+		}
+	}
+	info.Decoded = string(txtBytes)
+
+	if info.Claims, err = decodeClaims(txtBytes, o); err != nil {
+		return info, err
+	}
+	return info, nil
+}
+
+// v0FallbackEncodingNames describe v0FallbackEncodings, in the same order.
+var v0FallbackEncodingNames = []string{"base64url, unpadded", "base64, '=' padded", "base64, unpadded"}
+
+// dumpPayload decodes the payload of the split cookie as Parse without WithPadding would, and describes the encoding it was decoded with.
+func dumpPayload(s signedCookie) ([]byte, string, error) {
+	if s.version != Version0 {
+		txtBytes, err := base64.RawURLEncoding.DecodeString(s.payload)
+		if err != nil {
+			return nil, "", fmt.Errorf("%w: error decoding base64 data: %w", ErrMalformed, err)
+		}
+		return txtBytes, "base64url, unpadded", nil
+	}
+	for i, encoding := range v0FallbackEncodings {
+		if txtBytes, err := encoding.DecodeString(s.payload); err == nil {
+			name := v0FallbackEncodingNames[i]
+			if len(s.signed) > len(s.payload) {
+				name = "base64, '-' padded (Mojolicious)"
+			}
+			return txtBytes, name, nil
+		}
+	}
+	txtBytes, err := decodeV0Payload(nil, []byte(s.payload), v0FallbackEncodings)
+	return txtBytes, "", err
+}
@@ -0,0 +1,93 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"crypto"
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDump(t *testing.T) {
+	expiration := time.Unix(4102444800, 0)
+	tests := map[string]struct {
+		cookie      string
+		version     int
+		encoding    string
+		hash        crypto.Hash
+		compression string
+	}{
+		"version 0":     {New("alice1", expiration, "secret"), Version0, "base64url, unpadded", crypto.SHA1, ""},
+		"padded":        {New("operator", expiration, "secret"), Version0, "base64, '-' padded (Mojolicious)", crypto.SHA1, ""},
+		"unpadded":      {New("alice", expiration, "secret", WithPadding(base64.NoPadding)), Version0, "base64url, unpadded", crypto.SHA1, ""},
+		"mojolicious":   {perlCookies[`{"auth_data":"operator1","expires":4102444800}`], Version0, "base64, '-' padded (Mojolicious)", crypto.SHA1, ""},
+		"version 1":     {New("alice", expiration, "secret", WithVersion(Version1), WithHash(crypto.SHA256)), Version1, "base64url, unpadded", crypto.SHA256, ""},
+		"compressed":    {New("alice", expiration, "secret", WithCompression(CodecGzip)), Version2, "base64url, unpadded", crypto.SHA1, CodecGzip},
+		"truncated tag": {New("alice1", expiration, "secret", WithTagLength(MinTagLength)), Version0, "base64url, unpadded", 0, ""},
+	}
+	for name, test := range tests {
+		info, err := Dump(test.cookie)
+		if err != nil {
+			t.Fatalf("%v: Dump expected nil error, actual: %v", name, err)
+		}
+		if info.Version != test.version {
+			t.Errorf("%v: Dump expected version %v, actual: %v", name, test.version, info.Version)
+		}
+		if info.Encoding != test.encoding {
+			t.Errorf("%v: Dump expected encoding '%v', actual: '%v'", name, test.encoding, info.Encoding)
+		}
+		if info.Hash != test.hash {
+			t.Errorf("%v: Dump expected hash %v, actual: %v", name, test.hash, info.Hash)
+		}
+		if info.Compression != test.compression {
+			t.Errorf("%v: Dump expected compression '%v', actual: '%v'", name, test.compression, info.Compression)
+		}
+		if info.Signed+"--"+info.Signature != test.cookie || test.cookie[:info.SplitAt] != info.Signed {
+			t.Errorf("%v: Dump expected signed part and signature split at %v to make up the cookie, actual: '%v' '%v'", name, info.SplitAt, info.Signed, info.Signature)
+		}
+		if info.Claims == nil || !strings.HasPrefix(info.Claims.AuthData, "alice") && !strings.HasPrefix(info.Claims.AuthData, "operator") {
+			t.Errorf("%v: Dump expected claims, actual: %+v", name, info.Claims)
+		}
+	}
+}
+
+func TestDumpMalformed(t *testing.T) {
+	valid := New("alice", time.Unix(4102444800, 0), "secret")
+
+	info, err := Dump(valid[:len(valid)-1] + "z")
+	if err == nil {
+		t.Errorf("Dump bad signature hex expected error, actual nil")
+	}
+	if info.Signature != valid[len(valid)-40:len(valid)-1]+"z" {
+		t.Errorf("Dump bad signature hex expected raw signature segment, actual: '%v'", info.Signature)
+	}
+
+	info, err = Dump("bm90IGpzb24--0000000000000000000000000000000000000000")
+	if err == nil {
+		t.Errorf("Dump payload which isn't JSON expected error, actual nil")
+	}
+	if info.Decoded != "not json" || info.Claims != nil {
+		t.Errorf("Dump payload which isn't JSON expected decoded text and nil claims, actual: '%v' %+v", info.Decoded, info.Claims)
+	}
+}
+
+func FuzzDump(f *testing.F) {
+	for _, seed := range []string{"", "-", "--", "-x", "a-b", "v1.", "v2.--", "v2.e30.--00", "v99999999999999999999.x--00", New("alice", time.Unix(4102444800, 0), "secret", WithCompression(CodecGzip))} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, cookie string) {
+		Dump(cookie)
+	})
+}
@@ -0,0 +1,83 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+)
+
+// EncryptionAES256GCM is the name, in version 2 headers, of payloads sealed with AES-256-GCM; see WithEncryption.
+const EncryptionAES256GCM = "A256GCM"
+
+// encryptionKeyInfo is the HKDF info of the AES-GCM keys of WithEncryption.
+const encryptionKeyInfo = "tocookie encryption key v1"
+
+// errEncryptedUnverified is returned when an encrypted payload is decoded without the key its signature was verified with, which also decrypts it.
+var errEncryptedUnverified = errors.New("encrypted payload can't be decoded without verifying its signature")
+
+// WithEncryption makes New seal the cookie payload with AES-256-GCM before signing it, so the claims, such as the user and expiry, aren't visible to the client. Encrypted cookies are minted in Version2, whose header says they are encrypted, so Parse needs no option to read them. Compressed payloads are compressed before they are sealed.
+//
+// The encryption key is HKDF-SHA256 (RFC 5869) of the signing key, with no salt and the info "tocookie encryption key v1"; 32 bytes are derived. Each cookie is sealed with a random 12 byte nonce, which is prepended to the ciphertext.
+//
+// Perl Traffic Ops can't read encrypted cookies, so cookies are plaintext without this option, which should only be given where no Perl reader remains. The user of a cookie is hidden by encryption, so it can't be combined with WithPerUserKeys: New returns an empty string given both.
+func WithEncryption() Option {
+	return func(o *options) { o.encrypt = true }
+}
+
+// encryptionAEAD returns the AES-256-GCM AEAD of the encryption key derived from the signing key.
+func encryptionAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(deriveKey(key, nil, encryptionKeyInfo))
+	if err != nil {
+		return nil, fmt.Errorf("creating cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts the payload with the encryption key derived from the signing key, returning the nonce followed by the ciphertext.
+func seal(msg, key []byte) ([]byte, error) {
+	aead, err := encryptionAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(msg)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("generating nonce: %w", err)
+	}
+	return aead.Seal(nonce, nonce, msg, nil), nil
+}
+
+// open decrypts a payload sealed by seal with the same signing key.
+func open(sealed, key []byte, encryption string) ([]byte, error) {
+	if encryption != EncryptionAES256GCM {
+		return nil, fmt.Errorf("%w: unsupported encryption '%s'", ErrMalformed, encryption)
+	}
+	if key == nil {
+		return nil, errEncryptedUnverified
+	}
+	aead, err := encryptionAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, fmt.Errorf("%w: encrypted payload shorter than its nonce", ErrMalformed)
+	}
+	msg, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: error decrypting payload: %w", ErrMalformed, err)
+	}
+	return msg, nil
+}
@@ -0,0 +1,99 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWithEncryption(t *testing.T) {
+	secret := "secret"
+	user := "alice"
+	expiry := time.Now().Add(time.Minute)
+	cookie := New(user, expiry, secret, WithEncryption())
+	if !strings.HasPrefix(cookie, "v2.") {
+		t.Fatalf("New with encryption expected version 2 cookie, actual: '%v'", cookie)
+	}
+	if other := New(user, expiry, secret, WithEncryption()); other == cookie {
+		t.Errorf("New with encryption expected a fresh nonce per cookie, actual: identical cookies")
+	}
+
+	info, err := Dump(cookie)
+	if err == nil || info.Encryption != EncryptionAES256GCM {
+		t.Errorf("Dump of encrypted cookie expected encryption %v and error, actual: '%v' %v", EncryptionAES256GCM, info.Encryption, err)
+	}
+	if payload, _ := base64.RawURLEncoding.DecodeString(info.Payload); strings.Contains(string(payload), user) {
+		t.Errorf("New with encryption expected user hidden from payload, actual: '%v'", string(payload))
+	}
+
+	c, err := Parse(secret, cookie)
+	if err != nil || c.AuthData != user || c.ExpiresUnix != expiry.Unix() {
+		t.Errorf("Parse of encrypted cookie expected %v expiring %v, actual: %+v %v", user, expiry.Unix(), c, err)
+	}
+	if c, err := NewParser(secret).Parse(cookie); err != nil || c.AuthData != user {
+		t.Errorf("Parser of encrypted cookie expected %v, actual: %+v %v", user, c, err)
+	}
+	if _, err := Parse("wrong", cookie); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("Parse of encrypted cookie with wrong secret expected ErrBadSignature, actual: %v", err)
+	}
+
+	long := strings.Repeat("alice", 200)
+	compressed := New(long, expiry, secret, WithEncryption(), WithCompression(CodecGzip))
+	if c, err := Parse(secret, compressed); err != nil || c.AuthData != long {
+		t.Errorf("Parse of compressed encrypted cookie expected round-trip, actual: %+v %v", c, err)
+	}
+	if uncompressed := New(long, expiry, secret, WithEncryption()); len(compressed) >= len(uncompressed) {
+		t.Errorf("New expected compression before encryption to shrink cookie below %v, actual: %v", len(uncompressed), len(compressed))
+	}
+
+	ring := NewMemoryKeyRing("k1", []byte(secret))
+	if c, err := ParseWithKeyRing(ring, NewWithKeyRing(user, expiry, ring, WithEncryption())); err != nil || c.AuthData != user {
+		t.Errorf("ParseWithKeyRing of encrypted cookie expected %v, actual: %+v %v", user, c, err)
+	}
+
+	if cookie := New(user, expiry, secret, WithEncryption(), WithPerUserKeys()); cookie != "" {
+		t.Errorf("New with encryption and per-user keys expected empty cookie, actual: '%v'", cookie)
+	}
+	if cookie := New(user, expiry, secret); strings.HasPrefix(cookie, "v2.") {
+		t.Errorf("New without encryption expected plaintext version 0 cookie, actual: '%v'", cookie)
+	}
+}
+
+func TestParseEncryptedRejects(t *testing.T) {
+	secret := "secret"
+	o := newOptions(nil)
+	sign := func(hdr, payload string) string {
+		signed := "v2." + base64.RawURLEncoding.EncodeToString([]byte(hdr)) + "." + base64.RawURLEncoding.EncodeToString([]byte(payload))
+		return signed + "--" + hex.EncodeToString(o.sign([]byte(signed), []byte(secret)))
+	}
+	sealed, err := seal([]byte(`{"auth_data":"alice"}`), []byte("other"))
+	if err != nil {
+		t.Fatalf("seal expected nil error, actual: %v", err)
+	}
+	tests := map[string]string{
+		"unknown encryption": sign(`{"enc":"ROT13"}`, "nonce and ciphertext"),
+		"short":              sign(`{"enc":"A256GCM"}`, "short"),
+		"garbage":            sign(`{"enc":"A256GCM"}`, "nonce and ciphertext"),
+		"other key":          sign(`{"enc":"A256GCM"}`, string(sealed)),
+	}
+	for name, cookie := range tests {
+		if _, err := Parse(secret, cookie); !errors.Is(err, ErrMalformed) {
+			t.Errorf("%v: Parse expected ErrMalformed, actual: %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrExpired is returned when the cookie's expiration has passed. If the cookie is otherwise valid, Parse returns its claims along with an ExpiredError, so the expiration is available from Cookie.Expires.
+//
+// The errors of this package are sentinels, to be matched with errors.Is; their messages aren't stable. ErrBadSignature and ErrMalformed mean the cookie isn't authentic, while the others mean it is, but was rejected; see IsAuthFailure and Classify.
+var ErrExpired = errors.New("signature expired")
+
+// ExpiredError is the error Parse and Validate return for expired cookies, and ParseAPIToken for expired API tokens. It carries when the cookie expired, and when it was checked, so callers can log how stale it was, rather than this package logging it. It matches ErrExpired with errors.Is.
+type ExpiredError struct {
+	// Expired is when the cookie expired.
+	Expired time.Time
+	// Now is when the cookie was checked, which is after Expired by at least the leeway of WithLeeway.
+	Now time.Time
+}
+
+func (e *ExpiredError) Error() string {
+	return fmt.Sprintf("%s at %s, %s ago", ErrExpired, e.Expired.UTC().Format(time.RFC3339), e.Now.Sub(e.Expired).Round(time.Second))
+}
+
+func (e *ExpiredError) Unwrap() error {
+	return ErrExpired
+}
+
+// ErrSessionTooOld is returned when the session was issued longer ago than the maximum lifetime given by WithMaxLifetime.
+var ErrSessionTooOld = errors.New("session exceeded maximum lifetime")
+
+// ErrReauthRequired is returned when the user authenticated with their credentials longer ago than the maximum given by WithMaxAuthAge, so they must log in again.
+var ErrReauthRequired = errors.New("reauthentication required")
+
+// ErrClaimInvalid is returned when a validator given by WithClaimValidators rejects a claim. The error returned by the validator is wrapped along with it.
+var ErrClaimInvalid = errors.New("cookie claim invalid")
+
+// ErrClaimsTooLarge is returned when the custom claims of a cookie exceed the size given by WithMaxClaimsSize.
+var ErrClaimsTooLarge = errors.New("cookie custom claims too large")
+
+// ErrRevoked is returned by Parse when the cookie's session has been revoked; see WithRevocationStore.
+var ErrRevoked = errors.New("cookie session revoked")
+
+// ErrCSRFTokenInvalid is returned by ValidateCSRFToken when the token is missing or wasn't issued for the cookie's session.
+var ErrCSRFTokenInvalid = errors.New("csrf token invalid")
+
+// ErrSessionReused is returned by Parse given WithRotation when the cookie is of an older generation of its session than the latest, i.e. it was refreshed, and then used again, so it may have been stolen. The session is revoked.
+var ErrSessionReused = errors.New("cookie of a rotated session reused")
+
+// ErrHookRejected is returned by Parse when a hook given by WithHooks rejects the cookie. The error returned by the hook, or of the context it ran with, is wrapped along with it.
+var ErrHookRejected = errors.New("cookie rejected by hook")
+
+// ErrUserInactive is returned by the hook of ActiveUserHook when the cookie's user is no longer active.
+var ErrUserInactive = errors.New("cookie user inactive")
+
+// ErrReplayed is returned by Parse when the cookie's nonce has already been consumed, and by ParseActionToken when the token has.
+var ErrReplayed = errors.New("cookie already used")
+
+// ErrActionMismatch is returned by ParseActionToken when the token was minted for a different action, e.g. an email confirmation token presented to reset a password.
+var ErrActionMismatch = errors.New("action token for another action")
+
+// ErrFingerprintMismatch is returned by Parse when the cookie was minted for a different client fingerprint.
+var ErrFingerprintMismatch = errors.New("cookie fingerprint mismatch")
+
+// ErrNotYetValid is returned when the cookie's not-before time is in the future.
+var ErrNotYetValid = errors.New("cookie not yet valid")
+
+// ErrAudienceMismatch is returned when the cookie was minted for a different audience.
+var ErrAudienceMismatch = errors.New("cookie audience mismatch")
+
+// ErrIssuerMismatch is returned when the cookie was minted by a different issuer.
+var ErrIssuerMismatch = errors.New("cookie issuer mismatch")
+
+// ErrUnknownIssuer is returned by ParseWithIssuerSecrets when there is no secret for the cookie's issuer.
+var ErrUnknownIssuer = errors.New("cookie issuer unknown")
+
+// ErrVersionNotAccepted is returned by Parse when the cookie is of a format version the VersionPolicy doesn't accept.
+var ErrVersionNotAccepted = errors.New("cookie version not accepted")
+
+// ErrSessionNotFound is returned by ParseServerSession when the store has no session with the ID of the token, e.g. because it was deleted on logout, or expired.
+var ErrSessionNotFound = errors.New("session not found")
+
+// ErrNoAuthHeader is returned by FromAuthHeader when the request has no Authorization header.
+var ErrNoAuthHeader = errors.New("no Authorization header")
+
+// ErrNoToken is returned by Extractors, and FromRequest, when the request has no token for them.
+var ErrNoToken = errors.New("no token")
+
+// ErrBadSignature is returned when the cookie's signature doesn't match its contents, i.e. it was forged, tampered with, or signed with a different secret.
+var ErrBadSignature = errors.New("bad signature")
+
+// ErrMalformed is returned when the cookie can't be split or decoded, e.g. it has no signature, or its payload isn't valid base64 or JSON.
+var ErrMalformed = errors.New("malformed cookie")
+
+// ErrUnknownKey is returned by ParseWithKeyRing when the key ring has no key with the cookie's key ID.
+var ErrUnknownKey = errors.New("cookie key unknown")
+
+// ErrKeyNotValid is returned by ParseWithKeyRing when the cookie's key ID names a key outside its validity window; see KeyValidity.
+var ErrKeyNotValid = errors.New("cookie key outside its validity window")
+
+// ErrWeakSecret is returned by ValidateSecret for secrets unfit for signing cookies, and by Parse given WithSecretValidation for cookies verified with them.
+var ErrWeakSecret = errors.New("weak secret")
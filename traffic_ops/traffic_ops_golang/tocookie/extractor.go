@@ -0,0 +1,153 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// DefaultQueryParam is the query parameter of the tokens of QueryExtractor and WebSocketExtractor, if none is given: access_token, as RFC 6750 names it.
+const DefaultQueryParam = "access_token"
+
+// Extractor extracts the token of a request, for WithExtractors and FromRequest. Implementations must be safe for concurrent use.
+type Extractor interface {
+	// Extract returns the token of the request, or an error wrapping ErrNoToken if the request has none for the extractor, so the next extractor is tried. Other errors mean the request has a malformed token, which rejects it. Returning an empty token is the same as ErrNoToken.
+	Extract(r *http.Request) (string, error)
+}
+
+// ExtractorFunc is a function which is an Extractor, e.g. of a custom header.
+type ExtractorFunc func(r *http.Request) (string, error)
+
+// Extract implements Extractor.
+func (f ExtractorFunc) Extract(r *http.Request) (string, error) {
+	return f(r)
+}
+
+// defaultExtractors are the extractors of Middleware and FromRequest without WithExtractors.
+var defaultExtractors = []Extractor{CookieExtractor(), BearerExtractor()}
+
+// WithExtractors sets the extractors Middleware and FromRequest try in order for the token of a request: the first which finds one decides, so later extractors are only tried for requests without a token for the earlier ones. By default, they are CookieExtractor then BearerExtractor. Only tokens of CookieExtractor are refreshed by Middleware, as clients which send tokens in other ways don't read cookies.
+func WithExtractors(extractors ...Extractor) Option {
+	return func(o *options) { o.extractors = extractors }
+}
+
+// cookieExtractor is the Extractor of CookieExtractor, distinguished so Middleware refreshes its tokens.
+type cookieExtractor struct{}
+
+func (cookieExtractor) Extract(r *http.Request) (string, error) {
+	return newOptions(nil).cookieToken(r)
+}
+
+// cookieToken returns the token of the request's cookie of the configured name, as CookieExtractor extracts it.
+func (o *options) cookieToken(r *http.Request) (string, error) {
+	cookie, err := o.requestCookie(r)
+	if errors.Is(err, http.ErrNoCookie) {
+		return "", ErrNoToken
+	}
+	return cookie, err
+}
+
+// CookieExtractor returns an Extractor of the cookie named Name, or its chunks, as RequestCookie reads them. Given to Middleware or FromRequest with WithCookieName, it extracts the cookie of that name instead.
+func CookieExtractor() Extractor {
+	return cookieExtractor{}
+}
+
+// BearerExtractor returns an Extractor of the bearer token of the "Authorization: Bearer <token>" header, as FromAuthHeader reads it. Authorization headers of other schemes are rejected as malformed.
+func BearerExtractor() Extractor {
+	return ExtractorFunc(func(r *http.Request) (string, error) {
+		token, err := bearerToken(r)
+		if errors.Is(err, ErrNoAuthHeader) {
+			return "", ErrNoToken
+		}
+		return token, err
+	})
+}
+
+// QueryExtractor returns an Extractor of the query parameter of the request's URL, or DefaultQueryParam if it is empty. URLs are logged by proxies and kept in browser histories, so tokens in them are easily leaked; prefer WebSocketExtractor, which only accepts them where browsers can't send headers.
+func QueryExtractor(param string) Extractor {
+	if param == "" {
+		param = DefaultQueryParam
+	}
+	return ExtractorFunc(func(r *http.Request) (string, error) {
+		if token := r.URL.Query().Get(param); token != "" {
+			return token, nil
+		}
+		return "", ErrNoToken
+	})
+}
+
+// WebSocketExtractor returns an Extractor of the query parameter of WebSocket upgrade requests, or DefaultQueryParam if it is empty, as browsers can't set the headers of WebSocket connections, e.g. those Traffic Portal opens. Other requests have no token for it.
+func WebSocketExtractor(param string) Extractor {
+	query := QueryExtractor(param)
+	return ExtractorFunc(func(r *http.Request) (string, error) {
+		if !isWebSocketUpgrade(r) {
+			return "", ErrNoToken
+		}
+		return query.Extract(r)
+	})
+}
+
+// isWebSocketUpgrade returns whether the request is a WebSocket opening handshake, by RFC 6455.
+func isWebSocketUpgrade(r *http.Request) bool {
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		return false
+	}
+	for _, value := range r.Header.Values("Connection") {
+		for _, token := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), "upgrade") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// requestToken returns the token of the request of the first extractor which finds one, and whether it is that of CookieExtractor. If none do, an error wrapping ErrNoToken is returned.
+func (o *options) requestToken(r *http.Request) (string, bool, error) {
+	extractors := o.extractors
+	if extractors == nil {
+		extractors = defaultExtractors
+	}
+	for _, e := range extractors {
+		_, fromCookie := e.(cookieExtractor)
+		var token string
+		var err error
+		if fromCookie {
+			token, err = o.cookieToken(r)
+		} else {
+			token, err = e.Extract(r)
+		}
+		if errors.Is(err, ErrNoToken) || err == nil && token == "" {
+			continue
+		}
+		return token, fromCookie, err
+	}
+	return "", false, ErrNoToken
+}
+
+// FromRequest parses the token of the request, of the extractors of WithExtractors, or else its cookie, or its bearer token if it has no cookie, as Middleware does, for handlers which authenticate requests themselves. The hooks of WithHooks run with the context of the request. If the request has no token, an error wrapping ErrNoToken is returned.
+func FromRequest(r *http.Request, secret string, opts ...Option) (*Cookie, error) {
+	return FromRequestContext(r.Context(), r, secret, opts...)
+}
+
+// FromRequestContext is FromRequest, running the hooks of WithHooks with the given context.
+func FromRequestContext(ctx context.Context, r *http.Request, secret string, opts ...Option) (*Cookie, error) {
+	token, _, err := newOptions(opts).requestToken(r)
+	if err != nil {
+		return nil, err
+	}
+	return ParseContext(ctx, secret, token, opts...)
+}
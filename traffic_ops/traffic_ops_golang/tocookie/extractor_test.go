@@ -0,0 +1,108 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestExtractors(t *testing.T) {
+	request := func(target string, headers ...string) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		for i := 0; i+1 < len(headers); i += 2 {
+			r.Header.Add(headers[i], headers[i+1])
+		}
+		return r
+	}
+	upgrade := []string{"Connection", "keep-alive, Upgrade", "Upgrade", "websocket"}
+	custom := ExtractorFunc(func(r *http.Request) (string, error) { return r.Header.Get("X-Session"), nil })
+
+	tests := map[string]struct {
+		extractor Extractor
+		r         *http.Request
+		token     string
+		err       error
+	}{
+		"cookie":             {CookieExtractor(), request("/", "Cookie", Name+"=token"), "token", nil},
+		"no cookie":          {CookieExtractor(), request("/"), "", ErrNoToken},
+		"bearer":             {BearerExtractor(), request("/", "Authorization", "bearer token"), "token", nil},
+		"no bearer":          {BearerExtractor(), request("/"), "", ErrNoToken},
+		"basic":              {BearerExtractor(), request("/", "Authorization", "Basic dXNlcg=="), "", errors.New("malformed")},
+		"query":              {QueryExtractor(""), request("/?access_token=token"), "token", nil},
+		"other param":        {QueryExtractor("t"), request("/?t=token&access_token=other"), "token", nil},
+		"no query":           {QueryExtractor(""), request("/"), "", ErrNoToken},
+		"websocket":          {WebSocketExtractor(""), request("/ws?access_token=token", upgrade...), "token", nil},
+		"websocket no param": {WebSocketExtractor(""), request("/ws", upgrade...), "", ErrNoToken},
+		"not websocket":      {WebSocketExtractor(""), request("/ws?access_token=token"), "", ErrNoToken},
+		"custom":             {custom, request("/", "X-Session", "token"), "token", nil},
+	}
+	for name, test := range tests {
+		token, err := test.extractor.Extract(test.r)
+		if token != test.token {
+			t.Errorf("%v: Extract expected token '%v', actual: '%v'", name, test.token, token)
+		}
+		switch {
+		case test.err == nil && err != nil, test.err != nil && err == nil:
+			t.Errorf("%v: Extract expected error %v, actual: %v", name, test.err, err)
+		case errors.Is(test.err, ErrNoToken) && !errors.Is(err, ErrNoToken):
+			t.Errorf("%v: Extract expected ErrNoToken, actual: %v", name, err)
+		}
+	}
+}
+
+func TestWithExtractors(t *testing.T) {
+	secret := "secret"
+	token := New("alice", time.Now().Add(time.Minute), secret)
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, _ := FromContext(r.Context())
+		w.Write([]byte(c.AuthData))
+	})
+	opts := []Option{WithRefreshWindow(DefaultRefreshWindow), WithExtractors(WebSocketExtractor(""), CookieExtractor())}
+	handler := Middleware(secret, next, opts...)
+
+	r := httptest.NewRequest(http.MethodGet, "/ws?access_token="+token, nil)
+	r.Header.Set("Connection", "Upgrade")
+	r.Header.Set("Upgrade", "websocket")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || w.Body.String() != "alice" || len(w.Result().Cookies()) != 0 {
+		t.Errorf("Middleware with WebSocketExtractor expected alice without refresh, actual: %v %v %v", w.Code, w.Body, w.Result().Cookies())
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(&http.Cookie{Name: Name, Value: token})
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || len(w.Result().Cookies()) != 1 {
+		t.Errorf("Middleware with CookieExtractor expected refreshed cookie, actual: %v %v", w.Code, w.Result().Cookies())
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusUnauthorized || w.Header().Get("WWW-Authenticate") != `Bearer realm="traffic_ops"` {
+		t.Errorf("Middleware without BearerExtractor expected 401 without token, actual: %v %v", w.Code, w.Header().Get("WWW-Authenticate"))
+	}
+
+	if c, err := FromRequest(r, secret); err != nil || c.AuthData != "alice" {
+		t.Errorf("FromRequest of bearer token expected alice, actual: %+v %v", c, err)
+	}
+	if _, err := FromRequest(r, secret, opts...); !errors.Is(err, ErrNoToken) {
+		t.Errorf("FromRequest without BearerExtractor expected ErrNoToken, actual: %v", err)
+	}
+}
@@ -0,0 +1,160 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// FailureStore counts the failed verifications of clients, by their IP addresses, for WithFailureLimit. Implementations must be safe for concurrent use. MemoryFailureStore is a FailureStore for a single server.
+type FailureStore interface {
+	// Failed records a failed verification by the client.
+	Failed(client string) error
+	// Blocked returns how long until the client may be verified again, or zero if it may be verified now.
+	Blocked(client string) (time.Duration, error)
+}
+
+// FailureLimit configures limiting the failed verifications of clients; see WithFailureLimit.
+type FailureLimit struct {
+	// Store counts the failures of clients, and decides when they are blocked, e.g. a MemoryFailureStore.
+	Store FailureStore
+	// Tarpit, if positive, makes Middleware delay the requests of blocked clients by Tarpit before verifying them, rather than rejecting them, so brute force is slowed without locking out legitimate users behind the same address.
+	Tarpit time.Duration
+	// ClientIP, if not nil, returns the IP address of the client of the request, e.g. from the X-Forwarded-For header set by a trusted proxy. By default, it is that of WithClientBinding, if given, or else the host of the request's RemoteAddr.
+	ClientIP func(r *http.Request) string
+}
+
+// WithFailureLimit makes Middleware count the cookies and bearer tokens of each client which fail verification, because their signatures are bad or they are malformed, and limit the clients which fail too often, to blunt brute force against the HMAC, and quiet misconfigured clients. Requests of blocked clients are rejected with 429 Too Many Requests and a Retry-After header, before their cookies are parsed, or delayed given Tarpit. Expired and otherwise rejected cookies aren't counted, as they are authentic.
+//
+// Errors of the store are logged to the Logger of WithLogger, and the request is verified as usual, so an unavailable store doesn't lock every client out.
+func WithFailureLimit(limit FailureLimit) Option {
+	return func(o *options) { o.failureLimit = &limit }
+}
+
+// client returns the address of the client of the request, whose failures are counted.
+func (l *FailureLimit) client(r *http.Request, o *options) string {
+	clientIP := l.ClientIP
+	if clientIP == nil && o.binding != nil {
+		clientIP = o.binding.ClientIP
+	}
+	return requestIP(r, clientIP)
+}
+
+// admit returns whether the request of the client may be verified, after tarpitting it if it is blocked. Otherwise, the request has been answered, or its context is done.
+func (l *FailureLimit) admit(w http.ResponseWriter, r *http.Request, client string, o *options) bool {
+	wait, err := l.Store.Blocked(client)
+	if err != nil {
+		o.logger.Warnf("tocookie: checking failures of client '%s': %v", client, err)
+		return true
+	}
+	if wait <= 0 {
+		return true
+	}
+	if l.Tarpit > 0 {
+		t := time.NewTimer(l.Tarpit)
+		defer t.Stop()
+		select {
+		case <-t.C:
+			return true
+		case <-r.Context().Done():
+			return false
+		}
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+	return false
+}
+
+// failed records a failed verification of the client, if err means the cookie isn't authentic.
+func (l *FailureLimit) failed(client string, err error, o *options) {
+	if !IsAuthFailure(err) {
+		return
+	}
+	if err := l.Store.Failed(client); err != nil {
+		o.logger.Warnf("tocookie: recording failure of client '%s': %v", client, err)
+	}
+}
+
+// MemoryFailureStore is a FailureStore held in memory, for a single server, which limits each client by a token bucket: a client may fail burst times in a row, and then once every interval, as its bucket refills. It is safe for concurrent use.
+type MemoryFailureStore struct {
+	interval time.Duration
+	burst    float64
+	// now is the clock of the buckets, which is time.Now but in tests.
+	now func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]*failureBucket
+	// pruned is when full buckets were last pruned.
+	pruned time.Time
+}
+
+// failureBucket is the token bucket of a client: the tokens it had left when it last failed.
+type failureBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewMemoryFailureStore returns a MemoryFailureStore which blocks clients after burst failures, until they have failed no more than once every interval, e.g. 10 failures, then one a minute.
+func NewMemoryFailureStore(burst int, interval time.Duration) *MemoryFailureStore {
+	return &MemoryFailureStore{interval: interval, burst: float64(burst), now: time.Now, buckets: map[string]*failureBucket{}}
+}
+
+// Failed takes a token from the client's bucket. Buckets which have refilled are pruned, so the store only holds clients which have failed recently.
+func (s *MemoryFailureStore) Failed(client string) error {
+	now := s.now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if full := s.interval * time.Duration(s.burst); now.Sub(s.pruned) > full {
+		for c, b := range s.buckets {
+			if s.tokens(b, now) >= s.burst {
+				delete(s.buckets, c)
+			}
+		}
+		s.pruned = now
+	}
+	b, ok := s.buckets[client]
+	if !ok {
+		b = &failureBucket{tokens: s.burst, last: now}
+		s.buckets[client] = b
+	}
+	b.tokens, b.last = math.Max(s.tokens(b, now)-1, 0), now
+	return nil
+}
+
+// Blocked returns how long until the client's bucket has a token, or zero if it has one.
+func (s *MemoryFailureStore) Blocked(client string) (time.Duration, error) {
+	now := s.now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.buckets[client]
+	if !ok {
+		return 0, nil
+	}
+	tokens := s.tokens(b, now)
+	if tokens >= 1 {
+		return 0, nil
+	}
+	return time.Duration((1 - tokens) * float64(s.interval)), nil
+}
+
+// tokens returns the tokens of the bucket at the time, refilled at one every interval since it last failed.
+func (s *MemoryFailureStore) tokens(b *failureBucket, now time.Time) float64 {
+	if s.interval <= 0 {
+		return s.burst
+	}
+	return math.Min(b.tokens+float64(now.Sub(b.last))/float64(s.interval), s.burst)
+}
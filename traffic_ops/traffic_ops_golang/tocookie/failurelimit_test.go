@@ -0,0 +1,121 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMemoryFailureStore(t *testing.T) {
+	now := time.Date(2018, 7, 1, 12, 0, 0, 0, time.UTC)
+	store := NewMemoryFailureStore(3, time.Minute)
+	store.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if wait, err := store.Blocked("192.0.2.1"); err != nil || wait != 0 {
+			t.Errorf("Blocked after %v failures expected 0, actual: %v %v", i, wait, err)
+		}
+		store.Failed("192.0.2.1")
+	}
+	if wait, _ := store.Blocked("192.0.2.1"); wait != time.Minute {
+		t.Errorf("Blocked after burst expected a minute, actual: %v", wait)
+	}
+	if wait, _ := store.Blocked("192.0.2.2"); wait != 0 {
+		t.Errorf("Blocked of other client expected 0, actual: %v", wait)
+	}
+	now = now.Add(20 * time.Second)
+	if wait, _ := store.Blocked("192.0.2.1"); wait != 40*time.Second {
+		t.Errorf("Blocked while refilling expected 40s, actual: %v", wait)
+	}
+	now = now.Add(40 * time.Second)
+	if wait, _ := store.Blocked("192.0.2.1"); wait != 0 {
+		t.Errorf("Blocked after refilling a token expected 0, actual: %v", wait)
+	}
+	store.Failed("192.0.2.1")
+	if wait, _ := store.Blocked("192.0.2.1"); wait != time.Minute {
+		t.Errorf("Blocked after failing again expected a minute, actual: %v", wait)
+	}
+
+	now = now.Add(time.Hour)
+	store.Failed("192.0.2.2")
+	if _, ok := store.buckets["192.0.2.1"]; ok || len(store.buckets) != 1 {
+		t.Errorf("Failed expected refilled buckets pruned, actual: %v buckets", len(store.buckets))
+	}
+}
+
+type failingFailureStore struct{}
+
+func (failingFailureStore) Failed(client string) error { return errors.New("store unavailable") }
+func (failingFailureStore) Blocked(client string) (time.Duration, error) {
+	return 0, errors.New("store unavailable")
+}
+
+func TestWithFailureLimit(t *testing.T) {
+	secret := "secret"
+	serve := func(handler http.Handler, ip, cookie string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = ip + ":1234"
+		r.AddCookie(&http.Cookie{Name: Name, Value: cookie})
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	valid := New("alice", time.Now().Add(time.Hour), secret)
+	forged := New("alice", time.Now().Add(time.Hour), "wrong")
+	expired := New("alice", time.Now().Add(-time.Hour), secret)
+
+	handler := Middleware(secret, next, WithFailureLimit(FailureLimit{Store: NewMemoryFailureStore(2, time.Minute)}))
+	for i := 0; i < 3; i++ {
+		if w := serve(handler, "192.0.2.1", expired); w.Code != http.StatusUnauthorized {
+			t.Errorf("Middleware of expired cookie %v expected status 401, actual: %v", i, w.Code)
+		}
+	}
+	for i, expected := range []int{http.StatusUnauthorized, http.StatusUnauthorized, http.StatusTooManyRequests} {
+		if w := serve(handler, "192.0.2.1", forged); w.Code != expected {
+			t.Errorf("Middleware of forged cookie %v expected status %v, actual: %v", i, expected, w.Code)
+		}
+	}
+	if w := serve(handler, "192.0.2.1", valid); w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "60" {
+		t.Errorf("Middleware of blocked client expected status 429 with Retry-After 60, actual: %v '%v'", w.Code, w.Header().Get("Retry-After"))
+	}
+	if w := serve(handler, "192.0.2.2", valid); w.Code != http.StatusOK {
+		t.Errorf("Middleware of other client expected status 200, actual: %v", w.Code)
+	}
+
+	tarpit := 50 * time.Millisecond
+	handler = Middleware(secret, next, WithFailureLimit(FailureLimit{Store: NewMemoryFailureStore(1, time.Minute), Tarpit: tarpit}))
+	serve(handler, "192.0.2.1", forged)
+	start := time.Now()
+	if w := serve(handler, "192.0.2.1", valid); w.Code != http.StatusOK || time.Since(start) < tarpit {
+		t.Errorf("Middleware of tarpitted client expected status 200 after %v, actual: %v after %v", tarpit, w.Code, time.Since(start))
+	}
+
+	binding := ClientBinding{IP: true, ClientIP: func(r *http.Request) string { return r.Header.Get("X-Forwarded-For") }}
+	if client := (&FailureLimit{}).client(&http.Request{RemoteAddr: "192.0.2.1:1234", Header: http.Header{"X-Forwarded-For": {"198.51.100.7"}}}, newOptions([]Option{WithClientBinding(binding)})); client != "198.51.100.7" {
+		t.Errorf("FailureLimit client with ClientBinding expected 198.51.100.7, actual: %v", client)
+	}
+
+	logger := &testLogger{}
+	handler = Middleware(secret, next, WithFailureLimit(FailureLimit{Store: failingFailureStore{}}), WithLogger(logger))
+	if w := serve(handler, "192.0.2.1", forged); w.Code != http.StatusUnauthorized || logger.numWarnings() != 2 {
+		t.Errorf("Middleware with failing store expected status 401 and 2 warnings, actual: %v %v", w.Code, logger.warnings)
+	}
+	if w := serve(handler, "192.0.2.1", valid); w.Code != http.StatusOK {
+		t.Errorf("Middleware with failing store expected valid cookie admitted, actual: %v", w.Code)
+	}
+}
@@ -0,0 +1,109 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// The default JSON names of the Cookie fields. These are the names Mojolicious uses, and must not be changed for cookies read by Perl Traffic Ops.
+const (
+	FieldAuthData = "auth_data"
+	FieldExpires  = "expires"
+	FieldBy       = "by"
+)
+
+// WithFieldNames renames the JSON fields of the cookie payload, for interop with consumers expecting a different schema, such as "exp" for the expiration. The keys are the default field names, e.g. FieldAuthData, and the values are the names to use in the payload, e.g. "sub". Fields not in the map keep their default names, and the Go fields of Cookie are unaffected.
+//
+// The payload is signed with the renamed fields, so the consumer verifies the same bytes, and Parse must be given the same names. New returns an empty string, and Parse an error, if a key isn't the name of a Cookie field, a name is empty, or two fields would have the same name.
+func WithFieldNames(names map[string]string) Option {
+	copied := make(map[string]string, len(names))
+	for from, to := range names {
+		copied[from] = to
+	}
+	return func(o *options) { o.fieldNames = copied }
+}
+
+// marshal serializes the cookie to JSON, using the configured field names.
+func (o *options) marshal(c *Cookie) ([]byte, error) {
+	b, err := json.Marshal(c)
+	if err != nil || len(o.fieldNames) == 0 {
+		return b, err
+	}
+	if err := checkFieldNames(o.fieldNames); err != nil {
+		return nil, err
+	}
+	return renameFields(b, o.fieldNames)
+}
+
+// unmarshal deserializes the JSON, using the configured field names, into c.
+func (o *options) unmarshal(b []byte, c *Cookie) error {
+	if len(o.fieldNames) > 0 {
+		if err := checkFieldNames(o.fieldNames); err != nil {
+			return err
+		}
+		reversed := make(map[string]string, len(o.fieldNames))
+		for from, to := range o.fieldNames {
+			reversed[to] = from
+		}
+		renamed, err := renameFields(b, reversed)
+		if err != nil {
+			return err
+		}
+		b = renamed
+	}
+	return json.Unmarshal(b, c)
+}
+
+// checkFieldNames returns an error if the names given to WithFieldNames don't rename Cookie fields to distinct, non-empty names.
+func checkFieldNames(names map[string]string) error {
+	renamed := make(map[string]string, len(names))
+	for from, to := range names {
+		if _, ok := claimNames[from]; !ok {
+			return fmt.Errorf("renaming unknown field '%s'", from)
+		}
+		if to == "" {
+			return fmt.Errorf("renaming field '%s' to an empty name", from)
+		}
+		if _, ok := names[to]; !ok && to != from {
+			if _, ok := claimNames[to]; ok {
+				return fmt.Errorf("renaming field '%s' to the name of field '%s'", from, to)
+			}
+		}
+		if other, ok := renamed[to]; ok {
+			return fmt.Errorf("renaming fields '%s' and '%s' to the same name '%s'", other, from, to)
+		}
+		renamed[to] = from
+	}
+	return nil
+}
+
+// renameFields renames the keys of the given JSON object according to names. It returns an error if the bytes aren't a JSON object, or if renaming would make two fields collide.
+func renameFields(b []byte, names map[string]string) ([]byte, error) {
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return nil, err
+	}
+	renamed := make(map[string]json.RawMessage, len(fields))
+	for name, val := range fields {
+		if to, ok := names[name]; ok {
+			name = to
+		}
+		if _, ok := renamed[name]; ok {
+			return nil, fmt.Errorf("duplicate field '%s' after renaming", name)
+		}
+		renamed[name] = val
+	}
+	return json.Marshal(renamed)
+}
@@ -0,0 +1,89 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWithFieldNames(t *testing.T) {
+	secret := "secret"
+	names := WithFieldNames(map[string]string{FieldAuthData: "sub", FieldExpires: "exp"})
+	cookie := New("alice", time.Now().Add(time.Minute), secret, names)
+
+	payload, err := base64.RawURLEncoding.DecodeString(cookie[:strings.Index(cookie, "-")])
+	if err != nil {
+		t.Fatalf("decoding payload expected nil error, actual: %v", err)
+	}
+	fields := map[string]interface{}{}
+	if err := json.Unmarshal(payload, &fields); err != nil {
+		t.Fatalf("unmarshalling payload expected nil error, actual: %v", err)
+	}
+	for _, name := range []string{"sub", "exp", FieldBy} {
+		if _, ok := fields[name]; !ok {
+			t.Errorf("payload expected field '%v', actual: %s", name, payload)
+		}
+	}
+	for _, name := range []string{FieldAuthData, FieldExpires} {
+		if _, ok := fields[name]; ok {
+			t.Errorf("payload expected no field '%v', actual: %s", name, payload)
+		}
+	}
+
+	c, err := Parse(secret, cookie, names)
+	if err != nil {
+		t.Fatalf("Parse expected nil error, actual: %v", err)
+	}
+	if c.AuthData != "alice" {
+		t.Errorf("Parse expected AuthData 'alice', actual: '%v'", c.AuthData)
+	}
+
+	if _, err := Parse(secret, cookie); err == nil {
+		t.Errorf("Parse without field names expected expiry error, actual nil")
+	}
+}
+
+func TestWithFieldNamesCollision(t *testing.T) {
+	if _, err := renameFields([]byte(`{"auth_data":"a","by":"b"}`), map[string]string{FieldAuthData: FieldBy}); err == nil {
+		t.Errorf("renameFields expected collision error, actual nil")
+	}
+}
+
+func TestWithFieldNamesInvalid(t *testing.T) {
+	secret := "secret"
+	valid := New("alice", time.Now().Add(time.Minute), secret)
+	tests := map[string]map[string]string{
+		"unknown field":    {"user": "sub"},
+		"empty name":       {FieldExpires: ""},
+		"same name":        {FieldAuthData: "sub", FieldBy: "sub"},
+		"other field name": {FieldAuthData: FieldBy},
+		"claim field name": {FieldExpires: "iat"},
+	}
+	for name, names := range tests {
+		if cookie := New("alice", time.Now().Add(time.Minute), secret, WithFieldNames(names)); cookie != "" {
+			t.Errorf("%v: New expected empty string, actual: '%v'", name, cookie)
+		}
+		if _, err := Parse(secret, valid, WithFieldNames(names)); err == nil {
+			t.Errorf("%v: Parse expected error, actual nil", name)
+		}
+	}
+
+	swapped := WithFieldNames(map[string]string{FieldAuthData: FieldBy, FieldBy: FieldAuthData})
+	if c, err := Parse(secret, New("alice", time.Now().Add(time.Minute), secret, swapped), swapped); err != nil || c.AuthData != "alice" || c.By != GeneratedByStr {
+		t.Errorf("Parse with swapped field names expected alice by %v, actual: %+v %v", GeneratedByStr, c, err)
+	}
+}
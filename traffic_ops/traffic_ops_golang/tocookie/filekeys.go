@@ -0,0 +1,123 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// WithReloadSignals makes a FileKeyProvider also reload its file when the process receives one of the signals, e.g. syscall.SIGHUP, so operators can apply a rotation immediately rather than at the next poll. Receiving a signal this way replaces its default action; SIGHUP no longer terminates the process.
+func WithReloadSignals(signals ...os.Signal) Option {
+	signals = append([]os.Signal(nil), signals...)
+	return func(o *options) { o.reloadSignals = signals }
+}
+
+// FileKeyProvider is a KeyRing and KeyLister of the secrets in a file, which it watches, atomically swapping in the new keys whenever the file changes, so long-running services pick up secret rotations without restarting. Use it with NewWithKeyRing and ParseWithKeyRing. It is safe for concurrent use.
+//
+// The file contains one secret per line, the current secret first, followed by the previous secrets which cookies are still verified with, newest first, like the secrets of cdn.conf; surrounding whitespace, blank lines, and lines starting with '#' are ignored. Keys are identified by SecretKeyID.
+//
+// The file is polled for changes every DefaultKeyFilePollPeriod, or the period given by WithKeyFilePollPeriod, and additionally reloaded on the signals given by WithReloadSignals. Unreadable or empty files are logged as warnings and ignored, keeping the previous keys, so a partially written file never zeroes the keys.
+type FileKeyProvider struct {
+	path    string
+	keys    atomic.Value // KeySet
+	o       *options
+	done    chan struct{}
+	once    sync.Once
+	signals chan os.Signal
+}
+
+// NewFileKeyProvider loads the secrets from the file at path, and starts watching it. If the file is unreadable or empty, an error is returned and nothing is watched. Close stops watching.
+func NewFileKeyProvider(path string, opts ...Option) (*FileKeyProvider, error) {
+	keys, modTime, err := readKeySetFile(path)
+	if err != nil {
+		return nil, err
+	}
+	p := &FileKeyProvider{path: path, o: newOptions(opts), done: make(chan struct{})}
+	p.keys.Store(keys)
+	if len(p.o.reloadSignals) > 0 {
+		p.signals = make(chan os.Signal, 1)
+		signal.Notify(p.signals, p.o.reloadSignals...)
+	}
+	go watchFile(path, modTime, p.o, p.done, p.signals, p.reload)
+	return p, nil
+}
+
+// reload loads the keys from the file, returning its modification time.
+func (p *FileKeyProvider) reload() (time.Time, error) {
+	keys, modTime, err := readKeySetFile(p.path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	p.keys.Store(keys)
+	p.o.logger.Infof("tocookie: reloaded %d keys from '%s'", len(keys.keys), p.path)
+	return modTime, nil
+}
+
+// Keys returns the currently loaded keys.
+func (p *FileKeyProvider) Keys() KeySet {
+	return p.keys.Load().(KeySet)
+}
+
+// Current returns the ID and secret of the current key.
+func (p *FileKeyProvider) Current() (string, []byte) {
+	return p.Keys().Current()
+}
+
+// Get returns the secret of the key with the given ID, and whether there is one.
+func (p *FileKeyProvider) Get(id string) ([]byte, bool) {
+	return p.Keys().Get(id)
+}
+
+// IDs returns the IDs of the keys, current first.
+func (p *FileKeyProvider) IDs() []string {
+	return p.Keys().IDs()
+}
+
+// Close stops watching the file and receiving signals. The last keys loaded remain available.
+func (p *FileKeyProvider) Close() {
+	p.once.Do(func() {
+		if p.signals != nil {
+			signal.Stop(p.signals)
+		}
+		close(p.done)
+	})
+}
+
+// readKeySetFile reads the secrets from the file at path, as documented by FileKeyProvider, and returns them with the file's modification time.
+func readKeySetFile(path string) (KeySet, time.Time, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return KeySet{}, time.Time{}, fmt.Errorf("reading key file '%s': %w", path, err)
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return KeySet{}, time.Time{}, fmt.Errorf("reading key file '%s': %w", path, err)
+	}
+	secrets := []string{}
+	for _, line := range strings.Split(string(b), "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+			secrets = append(secrets, line)
+		}
+	}
+	if len(secrets) == 0 {
+		return KeySet{}, time.Time{}, fmt.Errorf("key file '%s' has no keys", path)
+	}
+	return NewKeySetFromSecrets(secrets...), info.ModTime(), nil
+}
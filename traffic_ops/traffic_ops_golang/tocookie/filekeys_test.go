@@ -0,0 +1,83 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestFileKeyProvider(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets")
+	start := time.Now().Add(-time.Hour)
+	writeKeyFile(t, path, "# cookie secrets, current first\nfirst\n", start)
+
+	logger := &testLogger{}
+	p, err := NewFileKeyProvider(path, WithLogger(logger), WithKeyFilePollPeriod(time.Millisecond))
+	if err != nil {
+		t.Fatalf("NewFileKeyProvider expected nil error, actual: %v", err)
+	}
+	defer p.Close()
+	if id, key := p.Current(); id != SecretKeyID("first") || string(key) != "first" {
+		t.Fatalf("NewFileKeyProvider expected current key 'first', actual: %v '%s'", id, key)
+	}
+	oldCookie := NewWithKeyRing("alice", time.Now().Add(time.Minute), p)
+
+	writeKeyFile(t, path, "\n# nothing yet\n", start.Add(time.Second))
+	waitFor(t, "empty key file warning", func() bool { return logger.numWarnings() > 0 })
+	if id, _ := p.Current(); id != SecretKeyID("first") {
+		t.Errorf("FileKeyProvider empty file expected previous keys, actual current: %v", id)
+	}
+
+	writeKeyFile(t, path, "second\n  first  \n", start.Add(2*time.Second))
+	waitFor(t, "key reload", func() bool { id, _ := p.Current(); return id == SecretKeyID("second") })
+	if ids := p.IDs(); len(ids) != 2 || ids[1] != SecretKeyID("first") {
+		t.Errorf("FileKeyProvider expected previous key 'first', actual: %v", ids)
+	}
+	if _, err := ParseWithKeyRing(p, oldCookie); err != nil {
+		t.Errorf("ParseWithKeyRing of cookie signed with previous key expected nil error, actual: %v", err)
+	}
+	if _, err := ParseWithKeyRing(p, NewWithKeyRing("alice", time.Now().Add(time.Minute), p)); err != nil {
+		t.Errorf("ParseWithKeyRing of cookie signed with reloaded key expected nil error, actual: %v", err)
+	}
+}
+
+func TestFileKeyProviderSignal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets")
+	modTime := time.Now().Add(-time.Hour)
+	writeKeyFile(t, path, "first", modTime)
+	p, err := NewFileKeyProvider(path, WithKeyFilePollPeriod(time.Hour), WithReloadSignals(syscall.SIGHUP))
+	if err != nil {
+		t.Fatalf("NewFileKeyProvider expected nil error, actual: %v", err)
+	}
+	defer p.Close()
+
+	writeKeyFile(t, path, "second", modTime)
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGHUP); err != nil {
+		t.Fatalf("sending SIGHUP: %v", err)
+	}
+	waitFor(t, "key reload on SIGHUP", func() bool { _, key := p.Current(); return string(key) == "second" })
+}
+
+func TestFileKeyProviderInitialError(t *testing.T) {
+	dir := t.TempDir()
+	empty := filepath.Join(dir, "empty")
+	writeKeyFile(t, empty, "# no keys\n\n", time.Now())
+	for name, path := range map[string]string{"missing": filepath.Join(dir, "missing"), "empty": empty} {
+		if _, err := NewFileKeyProvider(path); err == nil {
+			t.Errorf("%v: NewFileKeyProvider expected error, actual nil", name)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// WithFingerprint binds the cookie to a client fingerprint. Given to New, the fingerprint is embedded in the cookie. Given to Parse, the cookie is rejected with ErrFingerprintMismatch unless it was minted with the same fingerprint. Parse doesn't check fingerprints unless this option is given.
+//
+// The fingerprint should be something coarse and stable for a client, such as the value returned by Fingerprint for the User-Agent and client subnet.
+func WithFingerprint(fingerprint string) Option {
+	return func(o *options) { o.fingerprint = fingerprint }
+}
+
+// Fingerprint returns a compact hash of the given client attributes, suitable for WithFingerprint. The attributes are hashed, so they aren't readable from the cookie.
+func Fingerprint(attributes ...string) string {
+	sum := sha256.Sum256([]byte(strings.Join(attributes, "\x00")))
+	return hex.EncodeToString(sum[:16])
+}
@@ -0,0 +1,39 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWithFingerprint(t *testing.T) {
+	secret := "secret"
+	fp := Fingerprint("Mozilla/5.0", "192.0.2.0/24")
+	cookie := New("alice", time.Now().Add(time.Minute), secret, WithFingerprint(fp))
+
+	if _, err := Parse(secret, cookie, WithFingerprint(fp)); err != nil {
+		t.Errorf("Parse matching fingerprint expected nil error, actual: %v", err)
+	}
+	if _, err := Parse(secret, cookie, WithFingerprint(Fingerprint("curl/7.29.0", "192.0.2.0/24"))); err != ErrFingerprintMismatch {
+		t.Errorf("Parse different fingerprint expected ErrFingerprintMismatch, actual: %v", err)
+	}
+	if _, err := Parse(secret, cookie); err != nil {
+		t.Errorf("Parse without fingerprint option expected nil error, actual: %v", err)
+	}
+
+	unbound := New("alice", time.Now().Add(time.Minute), secret)
+	if _, err := Parse(secret, unbound, WithFingerprint(fp)); err != ErrFingerprintMismatch {
+		t.Errorf("Parse unbound cookie expected ErrFingerprintMismatch, actual: %v", err)
+	}
+}
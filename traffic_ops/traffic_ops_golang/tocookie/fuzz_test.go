@@ -0,0 +1,102 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"testing"
+	"time"
+)
+
+// fuzzSecret is the secret the fuzz targets parse with.
+const fuzzSecret = "secret"
+
+// fuzzModes are the option sets the fuzz targets parse with, covering each way cookies are split and decoded.
+var fuzzModes = map[string][]Option{
+	"default": nil,
+	"strict":  {WithStrict()},
+	"lenient": {WithTrim(), WithURLEncoding()},
+	"padding": {WithPadding('~')},
+	"unknown": {WithDisallowUnknownFields(), WithMaxJSONDepth(4)},
+}
+
+// FuzzParse parses arbitrary cookies, as every unauthenticated request may carry. Besides not panicking, Parse and Parser must agree, accepted cookies must be within the limits, and rejected ones must be classified. The corpus is in testdata/fuzz/FuzzParse; run it with go test -fuzz FuzzParse.
+func FuzzParse(f *testing.F) {
+	expiration := time.Unix(4102444800, 0)
+	for _, seed := range []string{
+		"", "--", "-", "a--", "--00", "v1.--", "v2.e30.--00", "v99999999999999999999.x--00", "\"x--00\"", " x--00 ", "%2D%2D",
+		New("alice", expiration, fuzzSecret),
+		New("alice", expiration, fuzzSecret, WithVersion(Version1)),
+		New("alice", expiration, fuzzSecret, WithCompression(CodecGzip)),
+		New("alice", expiration, fuzzSecret, WithPadding('~')),
+		newMojoCookie(`{"auth_data":"alice","expires":4102444800,"flash":{}}`, fuzzSecret),
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, cookie string) {
+		for mode, opts := range fuzzModes {
+			c, err := Parse(fuzzSecret, cookie, opts...)
+			if pc, perr := NewParser(fuzzSecret, opts...).Parse(cookie); (err == nil) != (perr == nil) || (c == nil) != (pc == nil) {
+				t.Fatalf("%v: Parse and Parser expected to agree, actual: %v %v", mode, err, perr)
+			}
+			checkFuzzResult(t, mode, c, err)
+		}
+	})
+}
+
+// FuzzParsePayload parses authentically signed cookies with arbitrary payloads, which reach the JSON decoder and claim validation, as payloads minted by a compromised or buggy peer would.
+func FuzzParsePayload(f *testing.F) {
+	for _, seed := range []string{
+		`{"auth_data":"alice","expires":4102444800}`,
+		`{"auth_data":"alice","expires":"4102444800.5","roles":["admin"],"caps":["a"],"capm":"AQ"}`,
+		`{"auth_data":"alice","expires":4102444800,"x":[[[[[[]]]]]]}`,
+		`{"auth_data":1}`, `[]`, `null`, `{"expires":1e400}`, `{"capm":"!"}`, "\xff",
+	} {
+		f.Add([]byte(seed))
+	}
+	f.Fuzz(func(t *testing.T, payload []byte) {
+		cookie := newMojoCookie(string(payload), fuzzSecret)
+		for mode, opts := range fuzzModes {
+			c, err := Parse(fuzzSecret, cookie, append(opts, WithCapabilityTable("a", "b"))...)
+			checkFuzzResult(t, mode, c, err)
+			if err == nil && Refresh(c, fuzzSecret) == "" && c.claimsSize() <= DefaultMaxClaimsSize {
+				t.Errorf("%v: Refresh of accepted cookie expected a cookie, actual none: %+v", mode, c)
+			}
+		}
+	})
+}
+
+// FuzzParseAPIToken parses arbitrary API tokens.
+func FuzzParseAPIToken(f *testing.F) {
+	token, _, _ := NewAPIToken("ort", time.Time{}, fuzzSecret, WithCapabilities("servers-read"))
+	for _, seed := range []string{"", APITokenPrefix, APITokenPrefix + ".", token} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, token string) {
+		if parsed, err := ParseAPIToken(fuzzSecret, token); err == nil && (parsed == nil || parsed.ID == "") {
+			t.Errorf("ParseAPIToken expected claims with an id, actual: %+v", parsed)
+		}
+	})
+}
+
+// checkFuzzResult checks the invariants of the result of Parse.
+func checkFuzzResult(t *testing.T, mode string, c *Cookie, err error) {
+	if err == nil && c == nil {
+		t.Fatalf("%v: Parse expected a cookie without error, actual nil", mode)
+	}
+	if err != nil && Classify(err) == OutcomeValid {
+		t.Fatalf("%v: Classify of %v expected an outcome other than valid", mode, err)
+	}
+	if c != nil && len(c.RawPayload()) > DefaultMaxPayloadSize {
+		t.Fatalf("%v: Parse expected payload within %d bytes, actual: %d", mode, DefaultMaxPayloadSize, len(c.RawPayload()))
+	}
+}
@@ -0,0 +1,80 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"crypto"
+	_ "crypto/sha1" // register the hashes usable with WithHash
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+)
+
+// DefaultHash is the hash function of the cookie HMAC. It is SHA-1, because that is what Perl Mojolicious uses, and changing it would lock Perl Traffic Ops out of every cookie. Deployments without Perl readers can migrate to a stronger hash with WithHash and WithLegacyHashes.
+const DefaultHash = crypto.SHA1
+
+// WithHash sets the hash function of the cookie HMAC, e.g. crypto.SHA256. Parse must be given the same hash the cookie was minted with. New returns an empty string, and Parse an error, if the hash isn't linked into the binary; SHA-1, SHA-256, and SHA-512 always are.
+func WithHash(hash crypto.Hash) Option {
+	return func(o *options) { o.hash = hash }
+}
+
+// WithLegacyHashes makes Parse also accept cookies signed with the given hashes, for migrating to a stronger hash without invalidating outstanding sessions. For example, WithHash(crypto.SHA256) and WithLegacyHashes(crypto.SHA1) mint SHA-256 cookies, and accept SHA-1 cookies minted before the migration until they expire; Refresh re-signs them with SHA-256. The legacy hashes are only tried once the configured hash fails to verify, and unavailable ones are ignored.
+//
+// Perl Traffic Ops only verifies SHA-1 cookies, so deployments where it reads Go cookies must keep the default hash.
+func WithLegacyHashes(hashes ...crypto.Hash) Option {
+	hashes = append([]crypto.Hash(nil), hashes...)
+	return func(o *options) { o.legacyHashes = hashes }
+}
+
+// verifiesLegacy returns whether messageMAC is a tag of the message with one of the hashes given to WithLegacyHashes, or, given KeySeparationCompat, with the key itself.
+func (o *options) verifiesLegacy(message, messageMAC, key []byte) bool {
+	if o.verifiesRawKey(message, messageMAC, key) {
+		return true
+	}
+	for _, hash := range o.legacyHashes {
+		if !hash.Available() {
+			continue
+		}
+		legacy := *o
+		legacy.hash = hash
+		if checkHmac(message, messageMAC, key, &legacy) {
+			return true
+		}
+	}
+	return false
+}
+
+// MinTagLength is the shortest HMAC tag, in bytes, WithTagLength allows.
+const MinTagLength = 16
+
+// WithTagLength truncates the HMAC tag to the given number of bytes, for the tightest cookies. Lengths are clamped between MinTagLength and the size of the hash. The hex signature describes its own length, so Parse given this option accepts tags from this length up to the full digest, while Parse without it accepts only full-length tags. Tags are always compared in constant time.
+func WithTagLength(length int) Option {
+	return func(o *options) { o.tagLength = length }
+}
+
+// minTagLength returns the shortest tag length accepted, for a hash of the given size.
+func (o *options) minTagLength(size int) int {
+	if o.tagLength == 0 || o.tagLength > size {
+		return size
+	}
+	if o.tagLength < MinTagLength {
+		return MinTagLength
+	}
+	return o.tagLength
+}
+
+// sign returns the HMAC tag of the message, truncated to the configured length.
+func (o *options) sign(message, key []byte) []byte {
+	mac := o.newMAC(key)
+	mac.Write(message)
+	return mac.Sum(nil)[:o.minTagLength(mac.Size())]
+}
@@ -0,0 +1,134 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Hook is an external check of a cookie, which may do I/O, such as looking up whether its user is still active in the Traffic Ops database, or whether its session has been revoked in a shared store. Hooks are given with WithHooks, and only run for cookies which are authentic and have passed every other check of Parse, including expiry and revocation, so they never see forged cookies. The context is that of ParseContext, and its cancellation and deadline should be honored by any I/O the hook does. A hook may read the cookie, but must not modify it.
+type Hook func(ctx context.Context, c *Cookie) error
+
+// WithHooks adds hooks which every function verifying a cookie runs in order on cookies it would otherwise accept: Parse, ParseContext, Parser, ParseBatch, ParseWithIssuerSecrets, ParseWithKeyFunc, ParseWithKeyRing, ParseWithPublicKey, ParseWithSigningKeys, ParseJWT, ParsePASETO, ParseServerSession, and so Middleware and FromRequest. The first to return an error rejects the cookie, with an error wrapping ErrHookRejected and the hook's error. Hooks run with the context of ParseContext, or, for Parse, a background context; Middleware uses the context of the request. Errors of the hooks reject the cookie, so an unavailable database fails closed.
+func WithHooks(hooks ...Hook) Option {
+	return func(o *options) { o.hooks = append(o.hooks[:len(o.hooks):len(o.hooks)], hooks...) }
+}
+
+// ParseContext is Parse, running the hooks of WithHooks with the context. The context is checked before the cookie is parsed, and before each hook, so a cancelled request does no further work. The span of WithTracer is a child of any span of the context. Expired cookies are returned with their ExpiredError, as Parse returns them, without running the hooks.
+func ParseContext(ctx context.Context, secret, cookie string, opts ...Option) (*Cookie, error) {
+	o := newOptions(opts)
+	start := o.startTimer()
+	ctx, span := o.startSpan(ctx, SpanParse)
+	c, err := parseContext(ctx, secret, cookie, o)
+	o.endParseSpan(span, c, err)
+	o.observe(start, c, err)
+	return c, err
+}
+
+func parseContext(ctx context.Context, secret, cookie string, o *options) (*Cookie, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	c, err := parse(secret, cookie, o)
+	if err != nil {
+		return c, err
+	}
+	if err := o.runHooks(ctx, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// parseWith verifies a cookie with parse, as ParseContext verifies one with its secret: in a span of WithTracer started from the context, running the hooks of WithHooks on the cookie if parse accepts it, and observed by WithMetrics and WithObserver. It is for the entrypoints which verify cookies other than by a secret, so their hooks run as Parse's do.
+func (o *options) parseWith(ctx context.Context, parse func() (*Cookie, error)) (*Cookie, error) {
+	start := o.startTimer()
+	ctx, span := o.startSpan(ctx, SpanParse)
+	c, err := o.parseHooked(ctx, parse)
+	o.endParseSpan(span, c, err)
+	o.observe(start, c, err)
+	return c, err
+}
+
+func (o *options) parseHooked(ctx context.Context, parse func() (*Cookie, error)) (*Cookie, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	c, err := parse()
+	if err != nil {
+		return c, err
+	}
+	if err := o.runHooks(ctx, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// runHooks runs the hooks of WithHooks on the cookie, stopping at the first error, or when the context is done.
+func (o *options) runHooks(ctx context.Context, c *Cookie) error {
+	for _, hook := range o.hooks {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("%w: %w", ErrHookRejected, err)
+		}
+		if err := hook(ctx, c); err != nil {
+			return fmt.Errorf("%w: %w", ErrHookRejected, err)
+		}
+	}
+	return nil
+}
+
+// ActiveUserHook returns a Hook which rejects cookies whose user isn't active, by isActive, with ErrUserInactive, e.g. because the user was deleted or locked out of Traffic Ops after the cookie was minted.
+func ActiveUserHook(isActive func(ctx context.Context, user string) (bool, error)) Hook {
+	return func(ctx context.Context, c *Cookie) error {
+		active, err := isActive(ctx, c.AuthData)
+		if err != nil {
+			return fmt.Errorf("checking user: %w", err)
+		}
+		if !active {
+			return ErrUserInactive
+		}
+		return nil
+	}
+}
+
+// RevocationHook returns a Hook which rejects cookies whose sessions are revoked, by isRevoked, with ErrRevoked, for revocation stores which take a context, such as the Traffic Ops database. Cookies without a SessionID can't be revoked, and are accepted, as they are by WithRevocationStore.
+func RevocationHook(isRevoked func(ctx context.Context, sessionID string) (bool, error)) Hook {
+	return func(ctx context.Context, c *Cookie) error {
+		if c.SessionID == "" {
+			return nil
+		}
+		revoked, err := isRevoked(ctx, c.SessionID)
+		if err != nil {
+			return fmt.Errorf("checking session revocation: %w", err)
+		}
+		if revoked {
+			return ErrRevoked
+		}
+		return nil
+	}
+}
+
+// HookTimeout returns the hook, run with a context which is cancelled after the timeout, or when the context of ParseContext is, whichever is first, bounding how long a slow database can delay each request.
+func HookTimeout(timeout time.Duration, hook Hook) Hook {
+	return func(ctx context.Context, c *Cookie) error {
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		return hook(ctx, c)
+	}
+}
+
+// ParseContext parses a cookie with the active secret, like the package-level ParseContext.
+func (m *Manager) ParseContext(ctx context.Context, cookie string, opts ...Option) (*Cookie, error) {
+	return ParseContext(ctx, m.Secret(), cookie, m.options(opts)...)
+}
@@ -0,0 +1,101 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseContext(t *testing.T) {
+	secret := "secret"
+	cookie := New("alice", time.Now().Add(time.Hour), secret)
+	expired := New("alice", time.Now().Add(-time.Hour), secret)
+	errDB := errors.New("database unavailable")
+	users := map[string]bool{"alice": true}
+	active := ActiveUserHook(func(ctx context.Context, user string) (bool, error) { return users[user], nil })
+	revoked := RevocationHook(func(ctx context.Context, sessionID string) (bool, error) { return true, nil })
+	failing := Hook(func(ctx context.Context, c *Cookie) error { return errDB })
+	slow := HookTimeout(time.Millisecond, func(ctx context.Context, c *Cookie) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := map[string]struct {
+		ctx    context.Context
+		cookie string
+		hooks  []Hook
+		err    error
+	}{
+		"no hooks":     {context.Background(), cookie, nil, nil},
+		"active":       {context.Background(), cookie, []Hook{active}, nil},
+		"inactive":     {context.Background(), New("bob", time.Now().Add(time.Hour), secret), []Hook{active}, ErrUserInactive},
+		"revoked":      {context.Background(), cookie, []Hook{active, revoked}, ErrRevoked},
+		"failing":      {context.Background(), cookie, []Hook{failing}, errDB},
+		"timeout":      {context.Background(), cookie, []Hook{slow}, context.DeadlineExceeded},
+		"cancelled":    {cancelled, cookie, []Hook{active}, context.Canceled},
+		"expired":      {context.Background(), expired, []Hook{failing}, ErrExpired},
+		"forged":       {context.Background(), New("alice", time.Now().Add(time.Hour), "wrong"), []Hook{failing}, ErrBadSignature},
+		"hook wrapped": {context.Background(), cookie, []Hook{failing}, ErrHookRejected},
+	}
+	for name, test := range tests {
+		c, err := ParseContext(test.ctx, secret, test.cookie, WithHooks(test.hooks...))
+		if !errors.Is(err, test.err) || test.err == nil && err != nil {
+			t.Errorf("%v: ParseContext expected %v, actual: %v", name, test.err, err)
+		}
+		if (err == nil || errors.Is(err, ErrExpired)) != (c != nil) {
+			t.Errorf("%v: ParseContext expected cookie only if accepted or expired, actual: %+v %v", name, c, err)
+		}
+		if _, perr := NewParser(secret, WithHooks(test.hooks...)).ParseContext(test.ctx, test.cookie); !errors.Is(perr, test.err) || test.err == nil && perr != nil {
+			t.Errorf("%v: Parser.ParseContext expected %v, actual: %v", name, test.err, perr)
+		}
+	}
+
+	if _, err := Parse(secret, cookie, WithHooks(failing)); !errors.Is(err, errDB) {
+		t.Errorf("Parse with hooks expected hook error, actual: %v", err)
+	}
+	if _, err := ParseContext(context.Background(), secret, cookie, WithHooks(failing)); Classify(err) != OutcomeRejected {
+		t.Errorf("Classify of hook rejection expected %v, actual: %v", OutcomeRejected, Classify(err))
+	}
+
+	calls := 0
+	counting := Hook(func(ctx context.Context, c *Cookie) error { calls++; return nil })
+	ParseContext(context.Background(), secret, cookie, WithHooks(counting), WithHooks(counting))
+	if calls != 2 {
+		t.Errorf("WithHooks expected hooks to accumulate, actual calls: %v", calls)
+	}
+}
+
+func TestMiddlewareHooks(t *testing.T) {
+	secret := "secret"
+	type key struct{}
+	var seen interface{}
+	hook := Hook(func(ctx context.Context, c *Cookie) error {
+		seen = ctx.Value(key{})
+		return nil
+	})
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r = r.WithContext(context.WithValue(r.Context(), key{}, "request"))
+	r.AddCookie(&http.Cookie{Name: Name, Value: New("alice", time.Now().Add(time.Hour), secret)})
+	w := httptest.NewRecorder()
+	Middleware(secret, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), WithHooks(hook)).ServeHTTP(w, r)
+	if w.Code != http.StatusOK || seen != "request" {
+		t.Errorf("Middleware expected hook run with request context, actual: %v %v", w.Code, seen)
+	}
+}
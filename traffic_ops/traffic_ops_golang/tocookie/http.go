@@ -0,0 +1,67 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// DefaultRealm is the realm of WWW-Authenticate challenges when none is configured.
+const DefaultRealm = "traffic_ops"
+
+const bearerPrefix = "Bearer "
+
+// FromAuthHeader parses the token in the request's "Authorization: Bearer <token>" header, for API clients which send the cookie value as a bearer token rather than a cookie. The scheme is matched case-insensitively. If the request has no Authorization header, ErrNoAuthHeader is returned.
+func FromAuthHeader(r *http.Request, secret string, opts ...Option) (*Cookie, error) {
+	token, err := bearerToken(r)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(secret, token, opts...)
+}
+
+// bearerToken returns the token of the request's Authorization header.
+func bearerToken(r *http.Request) (string, error) {
+	auth := r.Header.Get("Authorization")
+	if auth == "" {
+		return "", ErrNoAuthHeader
+	}
+	if len(auth) <= len(bearerPrefix) || !strings.EqualFold(auth[:len(bearerPrefix)], bearerPrefix) {
+		return "", errors.New("malformed Authorization header - expected 'Bearer <token>'")
+	}
+	return strings.TrimSpace(auth[len(bearerPrefix):]), nil
+}
+
+// Challenge returns a WWW-Authenticate challenge, in the style of RFC 6750, for a request which failed to authenticate with the given error. If no token was presented, the challenge only names the realm. Expired tokens are described as "expired", and all other failures as "invalid", so the challenge doesn't reveal why a forged token was rejected.
+func Challenge(realm string, err error) string {
+	if realm == "" {
+		realm = DefaultRealm
+	}
+	challenge := `Bearer realm="` + quoteEscaper.Replace(realm) + `"`
+	switch {
+	case err == nil || errors.Is(err, ErrNoAuthHeader) || errors.Is(err, ErrNoToken) || errors.Is(err, http.ErrNoCookie):
+		return challenge
+	case errors.Is(err, ErrExpired):
+		return challenge + `, error="invalid_token", error_description="expired"`
+	}
+	return challenge + `, error="invalid_token", error_description="invalid"`
+}
+
+// SetChallenge sets the WWW-Authenticate header of a 401 response to the Challenge for the error. It must be called before the response header is written.
+func SetChallenge(w http.ResponseWriter, realm string, err error) {
+	w.Header().Set("WWW-Authenticate", Challenge(realm, err))
+}
+
+var quoteEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
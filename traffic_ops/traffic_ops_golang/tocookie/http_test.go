@@ -0,0 +1,80 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestFromAuthHeader(t *testing.T) {
+	secret := "secret"
+	cookie := New("alice", time.Now().Add(time.Minute), secret)
+
+	for _, scheme := range []string{"Bearer ", "bearer ", "BEARER "} {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Authorization", scheme+cookie)
+		c, err := FromAuthHeader(r, secret)
+		if err != nil {
+			t.Errorf("FromAuthHeader '%v' expected nil error, actual: %v", scheme, err)
+			continue
+		}
+		if c.AuthData != "alice" {
+			t.Errorf("FromAuthHeader '%v' expected AuthData 'alice', actual: '%v'", scheme, c.AuthData)
+		}
+	}
+
+	r := httptest.NewRequest("GET", "/", nil)
+	if _, err := FromAuthHeader(r, secret); err != ErrNoAuthHeader {
+		t.Errorf("FromAuthHeader without header expected ErrNoAuthHeader, actual: %v", err)
+	}
+
+	for _, auth := range []string{"Basic YWxpY2U6cGFzcw==", "Bearer", "Bearer ", cookie} {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Authorization", auth)
+		if _, err := FromAuthHeader(r, secret); err == nil || err == ErrNoAuthHeader {
+			t.Errorf("FromAuthHeader '%v' expected malformed error, actual: %v", auth, err)
+		}
+	}
+}
+
+func TestChallenge(t *testing.T) {
+	secret := "secret"
+	_, expiredErr := Parse(secret, New("alice", time.Now().Add(-time.Minute), secret))
+	_, badSigErr := Parse(secret, New("alice", time.Now().Add(time.Minute), "wrong"))
+
+	tests := map[string]struct {
+		realm    string
+		err      error
+		expected string
+	}{
+		"no token":      {"", ErrNoAuthHeader, `Bearer realm="traffic_ops"`},
+		"no cookie":     {"to", http.ErrNoCookie, `Bearer realm="to"`},
+		"expired":       {"to", expiredErr, `Bearer realm="to", error="invalid_token", error_description="expired"`},
+		"bad signature": {"to", badSigErr, `Bearer realm="to", error="invalid_token", error_description="invalid"`},
+		"quoted realm":  {`a "b"`, nil, `Bearer realm="a \"b\""`},
+	}
+	for name, test := range tests {
+		if actual := Challenge(test.realm, test.err); actual != test.expected {
+			t.Errorf("%v: Challenge expected '%v', actual: '%v'", name, test.expected, actual)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	SetChallenge(w, "to", expiredErr)
+	if actual := w.Header().Get("WWW-Authenticate"); actual != tests["expired"].expected {
+		t.Errorf("SetChallenge expected header '%v', actual: '%v'", tests["expired"].expected, actual)
+	}
+}
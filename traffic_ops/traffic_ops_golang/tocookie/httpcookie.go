@@ -0,0 +1,81 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"net/http"
+	"time"
+)
+
+// DefaultCookiePath is the path of the cookies of NewHTTPCookie, if WithCookiePath isn't given, so the cookie is sent with every request to the host.
+const DefaultCookiePath = "/"
+
+// WithCookieDomain sets the Domain attribute of the cookies of NewHTTPCookie, so they are also sent to subdomains of the domain. By default, cookies have no Domain, and are only sent to the host which set them.
+func WithCookieDomain(domain string) Option {
+	return func(o *options) { o.cookieDomain = domain }
+}
+
+// WithCookiePath sets the Path attribute of the cookies of NewHTTPCookie. By default, it is DefaultCookiePath.
+func WithCookiePath(path string) Option {
+	return func(o *options) { o.cookiePath = path }
+}
+
+// WithSecure sets whether the cookies of NewHTTPCookie have the Secure attribute, so browsers only send them over HTTPS. By default, they do; it should only be disabled for development servers without TLS.
+func WithSecure(secure bool) Option {
+	return func(o *options) { o.insecure = !secure }
+}
+
+// WithHTTPOnly sets whether the cookies of NewHTTPCookie have the HttpOnly attribute, so scripts can't read them, and an XSS vulnerability can't steal the session. By default, they do.
+func WithHTTPOnly(httpOnly bool) Option {
+	return func(o *options) { o.noHTTPOnly = !httpOnly }
+}
+
+// WithSameSite sets the SameSite attribute of the cookies of NewHTTPCookie. By default, it is http.SameSiteLaxMode, so browsers don't send the cookie with cross-site subrequests, such as forged form posts, but do with top-level navigations, such as links to Traffic Portal. http.SameSiteDefaultMode omits the attribute.
+func WithSameSite(sameSite http.SameSite) Option {
+	return func(o *options) { o.sameSite = sameSite }
+}
+
+// NewHTTPCookie mints a cookie for the user, as New does, and returns it as an *http.Cookie named Name, or that of WithCookieName, ready for http.SetCookie. It expires when the cookie does. Its attributes default to the secure settings: Secure, HttpOnly, SameSite=Lax, and the path DefaultCookiePath; see WithSecure, WithHTTPOnly, WithSameSite, WithCookiePath, and WithCookieDomain. It returns nil if New would return an empty string.
+func NewHTTPCookie(user string, expiration time.Time, key string, opts ...Option) *http.Cookie {
+	value := New(user, expiration, key, opts...)
+	if value == "" {
+		return nil
+	}
+	return HTTPCookie(value, expiration, opts...)
+}
+
+// HTTPCookie returns a cookie value, such as one returned by New or Refresh, as an *http.Cookie with the attributes of NewHTTPCookie. If the expiration is zero, it is a session cookie, which the browser discards when it is closed.
+func HTTPCookie(value string, expiration time.Time, opts ...Option) *http.Cookie {
+	return newOptions(opts).httpCookie(value, expiration)
+}
+
+// httpCookie returns the cookie value as an *http.Cookie with the configured attributes.
+func (o *options) httpCookie(value string, expiration time.Time) *http.Cookie {
+	c := &http.Cookie{
+		Name:     o.name(),
+		Value:    value,
+		Path:     o.cookiePath,
+		Domain:   o.cookieDomain,
+		Expires:  expiration,
+		Secure:   !o.insecure,
+		HttpOnly: !o.noHTTPOnly,
+		SameSite: o.sameSite,
+	}
+	if c.Path == "" {
+		c.Path = DefaultCookiePath
+	}
+	if c.SameSite == 0 {
+		c.SameSite = http.SameSiteLaxMode
+	}
+	return c
+}
@@ -0,0 +1,49 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestNewHTTPCookie(t *testing.T) {
+	secret := "secret"
+	expiration := time.Now().Add(time.Hour).Truncate(time.Second)
+	c := NewHTTPCookie("alice", expiration, secret)
+	if c == nil {
+		t.Fatalf("NewHTTPCookie expected cookie, actual: nil")
+	}
+	if c.Name != Name || c.Path != DefaultCookiePath || c.Domain != "" || !c.Expires.Equal(expiration) || !c.Secure || !c.HttpOnly || c.SameSite != http.SameSiteLaxMode {
+		t.Errorf("NewHTTPCookie expected secure defaults, actual: %+v", c)
+	}
+	if parsed, err := Parse(secret, c.Value); err != nil || parsed.AuthData != "alice" {
+		t.Errorf("NewHTTPCookie expected value for alice, actual: %+v %v", parsed, err)
+	}
+
+	c = NewHTTPCookie("alice", expiration, secret, WithCookieDomain("example.net"), WithCookiePath("/api"), WithSecure(false), WithHTTPOnly(false), WithSameSite(http.SameSiteStrictMode))
+	if c.Domain != "example.net" || c.Path != "/api" || c.Secure || c.HttpOnly || c.SameSite != http.SameSiteStrictMode {
+		t.Errorf("NewHTTPCookie expected configured attributes, actual: %+v", c)
+	}
+	if c := NewHTTPCookie("alice", expiration, secret, WithSameSite(http.SameSiteDefaultMode)); c.SameSite != http.SameSiteDefaultMode {
+		t.Errorf("NewHTTPCookie with SameSiteDefaultMode expected no SameSite, actual: %v", c.SameSite)
+	}
+	if c := NewHTTPCookie("alice", expiration, secret, WithVersion(-1)); c != nil {
+		t.Errorf("NewHTTPCookie with invalid options expected nil, actual: %+v", c)
+	}
+
+	if c := HTTPCookie("value", time.Time{}); c.Value != "value" || !c.Expires.IsZero() || !c.Secure || !c.HttpOnly {
+		t.Errorf("HTTPCookie expected secure session cookie, actual: %+v", c)
+	}
+}
@@ -0,0 +1,85 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"time"
+)
+
+// MaxImpersonationDuration is the longest an impersonation session minted by NewImpersonation lasts, however often it is refreshed.
+const MaxImpersonationDuration = time.Hour
+
+// Actor is a user acting on behalf of another, as recorded in the Actor of an impersonation cookie.
+type Actor struct {
+	// Subject is the AuthData of the acting user's own cookie.
+	Subject string `json:"sub"`
+	// SessionID is the SessionID of the acting user's own cookie, so impersonations can be traced back to, and revoked with, the session which started them.
+	SessionID string `json:"sid,omitempty"`
+}
+
+// NewImpersonation mints a cookie for the user, as New does, on behalf of the user of the actor's cookie, which must have been verified, e.g. by Parse, and be authorized to impersonate, e.g. by RequireCapability; NewImpersonation doesn't check that. The cookie's AuthData is the user, and its Actor is the actor's AuthData and SessionID, so both the effective and acting users are known to every service which reads it; see EffectiveUser and ActingUser.
+//
+// The expiration is brought forward to MaxImpersonationDuration from now, or the expiration of the actor's cookie, whichever is earlier, so an impersonation never outlives the session which started it. Refresh likewise never extends an impersonation past MaxImpersonationDuration from its start. Options setting claims, such as WithRoles, set those of the impersonated user; the actor's roles and capabilities aren't carried over.
+//
+// It returns an empty string if the actor is nil, or is itself an impersonation, as impersonations can't be nested, or if the cookie can't be minted, as New does.
+func NewImpersonation(actor *Cookie, user string, expiration time.Time, key string, opts ...Option) string {
+	if actor == nil || actor.Actor != nil || actor.AuthData == "" {
+		return ""
+	}
+	o := newOptions(opts)
+	if latest := o.now().Add(MaxImpersonationDuration); expiration.After(latest) {
+		expiration = latest
+	}
+	if latest := actor.Expires(); expiration.After(latest) {
+		expiration = latest
+	}
+	c, err := o.newSession(user, expiration)
+	if err != nil {
+		return ""
+	}
+	c.Actor = &Actor{Subject: actor.AuthData, SessionID: actor.SessionID}
+	cookie := encodeCookie(c, key, o)
+	if cookie != "" {
+		o.issued(c)
+	}
+	return cookie
+}
+
+// IsImpersonation returns whether the cookie was minted by NewImpersonation, on behalf of another user.
+func (c *Cookie) IsImpersonation() bool {
+	return c.Actor != nil
+}
+
+// EffectiveUser returns the user the cookie grants access as, which is its AuthData, whether or not it is an impersonation. Authorization checks should use the effective user.
+func (c *Cookie) EffectiveUser() string {
+	return c.AuthData
+}
+
+// ActingUser returns the user actually making requests with the cookie: the Subject of its Actor if it is an impersonation, and otherwise its AuthData. Audit logs should record the acting user alongside the effective user.
+func (c *Cookie) ActingUser() string {
+	if c.Actor != nil {
+		return c.Actor.Subject
+	}
+	return c.AuthData
+}
+
+// capImpersonation returns the expiration, moved back to MaxImpersonationDuration after the start of the session if the cookie is an impersonation and it is later.
+func capImpersonation(c *Cookie, expiration time.Time) time.Time {
+	if c.Actor == nil || c.sessionStart() == 0 {
+		return expiration
+	}
+	if end := time.Unix(c.sessionStart(), 0).Add(MaxImpersonationDuration); end.Before(expiration) {
+		return end
+	}
+	return expiration
+}
@@ -0,0 +1,105 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewImpersonation(t *testing.T) {
+	secret := "secret"
+	now := time.Unix(1700000000, 0)
+	clock := WithClock(func() time.Time { return now })
+	admin, err := Parse(secret, New("admin", now.Add(DefaultDuration), secret, clock, WithRoles("admin")), clock)
+	if err != nil {
+		t.Fatalf("Parse admin cookie expected nil error, actual: %v", err)
+	}
+
+	cookie := NewImpersonation(admin, "alice", now.Add(DefaultDuration), secret, clock, WithRoles("operations"))
+	c, err := Parse(secret, cookie, clock)
+	if err != nil {
+		t.Fatalf("Parse impersonation cookie expected nil error, actual: %v", err)
+	}
+	if !c.IsImpersonation() {
+		t.Errorf("IsImpersonation expected true, actual: false")
+	}
+	if c.EffectiveUser() != "alice" {
+		t.Errorf("EffectiveUser expected alice, actual: %s", c.EffectiveUser())
+	}
+	if c.ActingUser() != "admin" {
+		t.Errorf("ActingUser expected admin, actual: %s", c.ActingUser())
+	}
+	if c.Actor.SessionID != admin.SessionID {
+		t.Errorf("Actor.SessionID expected %s, actual: %s", admin.SessionID, c.Actor.SessionID)
+	}
+	if c.SessionID == admin.SessionID {
+		t.Errorf("SessionID expected a new session, actual: the admin's %s", c.SessionID)
+	}
+	if c.HasRole("admin") || !c.HasRole("operations") {
+		t.Errorf("Roles expected [operations], actual: %v", c.Roles)
+	}
+	if expected := now.Add(MaxImpersonationDuration); !c.Expires().Equal(expected) {
+		t.Errorf("Expires expected %v, actual: %v", expected, c.Expires())
+	}
+	if Introspect(c).Actor == nil || Introspect(c).Actor.Subject != "admin" {
+		t.Errorf("Introspect Actor expected admin, actual: %+v", Introspect(c).Actor)
+	}
+
+	shortAdmin := admin.Clone()
+	shortAdmin.ExpiresUnix = now.Add(10 * time.Minute).Unix()
+	c, err = Parse(secret, NewImpersonation(shortAdmin, "alice", now.Add(DefaultDuration), secret, clock), clock)
+	if err != nil {
+		t.Fatalf("Parse impersonation of short session expected nil error, actual: %v", err)
+	}
+	if expected := shortAdmin.Expires(); !c.Expires().Equal(expected) {
+		t.Errorf("Expires of impersonation of short session expected %v, actual: %v", expected, c.Expires())
+	}
+
+	for name, actor := range map[string]*Cookie{"nil": nil, "impersonation": c, "no user": {}} {
+		if cookie := NewImpersonation(actor, "bob", now.Add(time.Minute), secret, clock); cookie != "" {
+			t.Errorf("NewImpersonation of %s actor expected empty string, actual: %s", name, cookie)
+		}
+	}
+}
+
+func TestRefreshImpersonation(t *testing.T) {
+	secret := "secret"
+	now := time.Unix(1700000000, 0)
+	admin := &Cookie{AuthData: "admin", By: GeneratedByStr, ExpiresUnix: now.Add(DefaultDuration).Unix(), SessionID: "admin-session"}
+	cookie := NewImpersonation(admin, "alice", now.Add(30*time.Minute), secret, WithClock(func() time.Time { return now }))
+
+	later := now.Add(25 * time.Minute)
+	clock := WithClock(func() time.Time { return later })
+	c, err := Parse(secret, cookie, clock)
+	if err != nil {
+		t.Fatalf("Parse expected nil error, actual: %v", err)
+	}
+	refreshed, err := Parse(secret, Refresh(c, secret, clock), clock)
+	if err != nil {
+		t.Fatalf("Parse refreshed expected nil error, actual: %v", err)
+	}
+	if refreshed.ActingUser() != "admin" || refreshed.EffectiveUser() != "alice" {
+		t.Errorf("refreshed users expected admin acting as alice, actual: %s acting as %s", refreshed.ActingUser(), refreshed.EffectiveUser())
+	}
+	if expected := now.Add(MaxImpersonationDuration); !refreshed.Expires().Equal(expected) {
+		t.Errorf("refreshed Expires expected %v, actual: %v", expected, refreshed.Expires())
+	}
+	if !refreshed.Equal(c) {
+		t.Errorf("refreshed Equal expected true, actual: false")
+	}
+	own := &Cookie{AuthData: "alice", By: GeneratedByStr}
+	if own.Equal(c) {
+		t.Errorf("Equal of alice's own cookie and impersonation expected false, actual: true")
+	}
+}
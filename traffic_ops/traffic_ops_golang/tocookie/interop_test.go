@@ -0,0 +1,100 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// perlCookies are session cookies in the format Mojolicious 5.24 (the version in the Traffic Ops cpanfile) writes, for the session hashes in their keys, signed with the secret "mysecret": sorted JSON keys, standard base64 with '=' padding replaced by '-', and an HMAC-SHA1 over the base64 text including its padding.
+var perlCookies = map[string]string{
+	`{"auth_data":"admin","expires":4102444800}`:     "eyJhdXRoX2RhdGEiOiJhZG1pbiIsImV4cGlyZXMiOjQxMDI0NDQ4MDB9--7f51a7c1534986df6c8a991920710867bfe01a42",
+	`{"auth_data":"operator1","expires":4102444800}`: "eyJhdXRoX2RhdGEiOiJvcGVyYXRvcjEiLCJleHBpcmVzIjo0MTAyNDQ0ODAwfQ----420c6faf5c376ef41dd6fafe9afcd0a872f74a58",
+}
+
+// perlSignedCookieRe is the pattern Mojolicious::Controller::signed_cookie splits cookies with.
+var perlSignedCookieRe = regexp.MustCompile(`^(.*)--([^\-]+)$`)
+
+// perlLoad reads a cookie the way Mojolicious::Sessions::load does, returning the session hash, or nil if Perl would reject the cookie.
+func perlLoad(cookie, secret string) map[string]interface{} {
+	parts := perlSignedCookieRe.FindStringSubmatch(cookie)
+	if parts == nil {
+		return nil
+	}
+	mac := hmac.New(sha1.New, []byte(secret))
+	mac.Write([]byte(parts[1]))
+	if !hmac.Equal([]byte(parts[2]), []byte(hex.EncodeToString(mac.Sum(nil)))) {
+		return nil
+	}
+	b, err := base64.StdEncoding.DecodeString(strings.Replace(parts[1], "-", "=", -1))
+	if err != nil {
+		return nil
+	}
+	session := map[string]interface{}{}
+	if err := json.Unmarshal(b, &session); err != nil {
+		return nil
+	}
+	return session
+}
+
+func TestParsePerlCookies(t *testing.T) {
+	for session, cookie := range perlCookies {
+		expected := map[string]interface{}{}
+		json.Unmarshal([]byte(session), &expected)
+		c, err := Parse("mysecret", cookie)
+		if err != nil {
+			t.Errorf("%v: Parse Perl cookie expected nil error, actual: %v", session, err)
+		} else if c.AuthData != expected["auth_data"] || float64(c.ExpiresUnix) != expected["expires"] {
+			t.Errorf("%v: Parse Perl cookie expected session claims, actual: %+v", session, c)
+		}
+	}
+}
+
+func TestNewMatchesPerl(t *testing.T) {
+	secret := "mysecret"
+	for _, user := range []string{"admin", "operator1", "op", "bob???"} {
+		c := Cookie{AuthData: user, By: GeneratedByStr, ExpiresUnix: 4102444800, IssuedAt: 1700000000, SessionStart: 1700000000, Roles: []string{"admin"}}
+		// The same session, as Mojolicious encodes it: with sorted keys.
+		session := `{"auth_data":"` + user + `","by":"` + GeneratedByStr + `","expires":4102444800,"iat":1700000000,"roles":["admin"],"session_start":1700000000}`
+		if expected, actual := newMojoCookie(session, secret), encodeCookie(&c, secret, newOptions(nil)); actual != expected {
+			t.Errorf("%v: encodeCookie expected Perl's cookie '%v', actual: '%v'", user, expected, actual)
+		}
+	}
+}
+
+func TestPerlReadsNew(t *testing.T) {
+	secret := "mysecret"
+	// Usernames chosen so the base64 has every padding length, and the characters which differ between the base64 alphabets.
+	for _, user := range []string{"admin", "operator1", "op", "bob???", "alice?>", "~~~"} {
+		cookie := New(user, time.Now().Add(time.Minute), secret, WithRoles("admin"))
+		session := perlLoad(cookie, secret)
+		if session == nil {
+			t.Errorf("%v: Perl expected to load cookie '%v', actual: rejected", user, cookie)
+			continue
+		}
+		if session["auth_data"] != user || session["by"] != GeneratedByStr {
+			t.Errorf("%v: Perl expected session of user, actual: %v", user, session)
+		}
+		if _, err := Parse(secret, cookie); err != nil {
+			t.Errorf("%v: Parse expected nil error, actual: %v", user, err)
+		}
+	}
+}
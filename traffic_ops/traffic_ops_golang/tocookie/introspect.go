@@ -0,0 +1,114 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Introspection is the response of IntrospectionHandler, in the style of RFC 7662 token introspection. Tokens which aren't active have only Active, so the response doesn't reveal why they were rejected.
+type Introspection struct {
+	Active bool `json:"active"`
+	// Username and Subject are both the AuthData of the cookie.
+	Username string `json:"username,omitempty"`
+	Subject  string `json:"sub,omitempty"`
+	// TokenType is "cookie" for active tokens, as they're cookies of this package, whether they were sent as cookies or bearer tokens.
+	TokenType string `json:"token_type,omitempty"`
+	Expires   int64  `json:"exp,omitempty"`
+	IssuedAt  int64  `json:"iat,omitempty"`
+	NotBefore int64  `json:"nbf,omitempty"`
+	// AuthTime is when the user last authenticated with their credentials, whether or not it was when the session began; see Cookie.AuthAge.
+	AuthTime int64 `json:"auth_time,omitempty"`
+	// AuthMethods and ACR are those of the cookie; see Cookie.AuthLevel.
+	AuthMethods []string `json:"amr,omitempty"`
+	ACR         string   `json:"acr,omitempty"`
+	Audience    string   `json:"aud,omitempty"`
+	// Issuer is the By field of the cookie.
+	Issuer    string   `json:"iss,omitempty"`
+	JTI       string   `json:"jti,omitempty"`
+	SessionID string   `json:"sid,omitempty"`
+	Roles     []string `json:"roles,omitempty"`
+	// Capabilities are the capabilities of the cookie, with those of a CapabilityMask the handler has no table for omitted.
+	Capabilities []string `json:"capabilities,omitempty"`
+	// Actor is the user acting on behalf of the Subject, for cookies minted by NewImpersonation.
+	Actor *Actor `json:"act,omitempty"`
+	// Tenancy is the tenant of the Subject, for cookies minted WithTenant or WithTenantHash.
+	Tenancy *Tenancy `json:"tenancy,omitempty"`
+	// Claims are the custom claims of the cookie, and any other keys of its payload; see Cookie.Extra.
+	Claims map[string]json.RawMessage `json:"claims,omitempty"`
+}
+
+// introspectionTokenType is the TokenType of active tokens.
+const introspectionTokenType = "cookie"
+
+// Introspect returns the introspection of a cookie which has been verified and validated, e.g. by Parse.
+func Introspect(c *Cookie) Introspection {
+	return Introspection{
+		Active:       true,
+		Username:     c.AuthData,
+		Subject:      c.AuthData,
+		TokenType:    introspectionTokenType,
+		Expires:      c.Expires().Unix(),
+		IssuedAt:     c.IssuedAt,
+		NotBefore:    c.NotBefore,
+		AuthTime:     c.authTime(),
+		AuthMethods:  c.AuthMethods,
+		ACR:          c.ACR,
+		Audience:     c.Audience,
+		Issuer:       c.By,
+		JTI:          c.JTI,
+		SessionID:    c.SessionID,
+		Roles:        c.Roles,
+		Capabilities: c.Capabilities,
+		Actor:        c.Actor,
+		Tenancy:      c.Tenancy,
+		Claims:       c.Extra,
+	}
+}
+
+// IntrospectionHandler returns a handler of an RFC 7662 style token introspection endpoint, so services such as Traffic Router, and auditing tools, can validate sessions without being given the secret. Tokens are POSTed as the "token" field of a form, and parsed with the keys and options, as ParseWithKeyRing does; the response is their Introspection as JSON. Expired, forged, and otherwise rejected tokens are reported as not active, with 200 OK, as the RFC requires. Requests of other methods are answered with 405 Method Not Allowed, and forms without a token with 400 Bad Request.
+//
+// The endpoint reveals the claims of any token it is given, so, as the RFC requires, it must only be served to authorized clients, e.g. behind Middleware or mutual TLS.
+func IntrospectionHandler(keys KeyRing, opts ...Option) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		token := r.PostFormValue("token")
+		if token == "" {
+			writeIntrospectionJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request", "error_description": "missing token"})
+			return
+		}
+		resp := Introspection{}
+		if c, err := ParseWithKeyRing(keys, token, opts...); err == nil {
+			resp = Introspect(c)
+		}
+		writeIntrospectionJSON(w, http.StatusOK, resp)
+	})
+}
+
+// writeIntrospectionJSON writes the JSON of v as the response, which mustn't be cached, as it describes a token which may be revoked.
+func writeIntrospectionJSON(w http.ResponseWriter, status int, v interface{}) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	w.Write(body)
+}
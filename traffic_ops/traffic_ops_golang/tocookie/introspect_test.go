@@ -0,0 +1,89 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIntrospectionHandler(t *testing.T) {
+	keys := NewKeySetFromSecrets("current", "previous")
+	expiration := time.Now().Add(time.Hour)
+	handler := IntrospectionHandler(keys, WithAudience("traffic_router"))
+	introspect := func(method, token string) (*httptest.ResponseRecorder, map[string]interface{}) {
+		r := httptest.NewRequest(method, "/introspect", strings.NewReader(url.Values{"token": {token}}.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		resp := map[string]interface{}{}
+		if method == http.MethodPost {
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Errorf("IntrospectionHandler expected JSON, actual: '%v' %v", w.Body.String(), err)
+			}
+		}
+		return w, resp
+	}
+
+	token := New("alice", expiration, "previous", WithAudience("traffic_router"), WithRoles("admin"), WithClaims(map[string]interface{}{"tenant": "root"}))
+	w, resp := introspect(http.MethodPost, token)
+	if w.Code != http.StatusOK || w.Header().Get("Cache-Control") != "no-store" || w.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("IntrospectionHandler expected uncached 200 JSON, actual: %v %v", w.Code, w.Header())
+	}
+	for name, expected := range map[string]interface{}{
+		"active":     true,
+		"username":   "alice",
+		"sub":        "alice",
+		"token_type": "cookie",
+		"exp":        float64(expiration.Unix()),
+		"aud":        "traffic_router",
+		"iss":        GeneratedByStr,
+	} {
+		if resp[name] != expected {
+			t.Errorf("IntrospectionHandler expected %v %v, actual: %v", name, expected, resp[name])
+		}
+	}
+	if roles, _ := resp["roles"].([]interface{}); len(roles) != 1 || roles[0] != "admin" {
+		t.Errorf("IntrospectionHandler expected roles [admin], actual: %v", resp["roles"])
+	}
+	if claims, _ := resp["claims"].(map[string]interface{}); claims["tenant"] != "root" {
+		t.Errorf("IntrospectionHandler expected claims with tenant, actual: %v", resp["claims"])
+	}
+	if sid, _ := resp["sid"].(string); len(sid) != 32 {
+		t.Errorf("IntrospectionHandler expected session ID, actual: %v", resp["sid"])
+	}
+
+	for name, token := range map[string]string{
+		"expired":        New("alice", time.Now().Add(-time.Hour), "current", WithAudience("traffic_router")),
+		"forged":         New("alice", expiration, "other", WithAudience("traffic_router")),
+		"other audience": New("alice", expiration, "current", WithAudience("other")),
+		"garbage":        "garbage",
+	} {
+		w, resp := introspect(http.MethodPost, token)
+		if w.Code != http.StatusOK || len(resp) != 1 || resp["active"] != false {
+			t.Errorf("%v: IntrospectionHandler expected only active false, actual: %v %v", name, w.Code, resp)
+		}
+	}
+
+	if w, resp := introspect(http.MethodPost, ""); w.Code != http.StatusBadRequest || resp["error"] != "invalid_request" {
+		t.Errorf("IntrospectionHandler without token expected 400 invalid_request, actual: %v %v", w.Code, resp)
+	}
+	if w, _ := introspect(http.MethodGet, token); w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") != http.MethodPost {
+		t.Errorf("IntrospectionHandler GET expected 405, actual: %v", w.Code)
+	}
+}
@@ -0,0 +1,33 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+// ParseWithIssuerSecrets parses a cookie signed with the secret of its issuer, for deployments where each organization has its own signing secret. The secrets map issuers, i.e. the By field of the cookie, to their secrets. Cookies are minted for an issuer by giving New the issuer's secret and WithIssuer.
+//
+// The issuer is read from the cookie before its signature is verified, but the cookie is then verified with that issuer's secret alone, and must have been minted by that issuer. If there is no secret for the issuer, ErrUnknownIssuer is returned.
+func ParseWithIssuerSecrets(secrets map[string]string, cookie string, opts ...Option) (*Cookie, error) {
+	o := newOptions(opts)
+	start := o.startTimer()
+	unverified, err := decodeUnverified(cookie, o)
+	if err != nil {
+		o.observe(start, nil, err)
+		return nil, err
+	}
+	secret, ok := secrets[unverified.By]
+	if !ok {
+		o.observe(start, nil, ErrUnknownIssuer)
+		return nil, ErrUnknownIssuer
+	}
+	issuerOpts := append(append(make([]Option, 0, len(opts)+1), opts...), WithIssuer(unverified.By))
+	return Parse(secret, cookie, issuerOpts...)
+}
@@ -0,0 +1,66 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseWithIssuerSecrets(t *testing.T) {
+	secrets := map[string]string{"org1": "secret1", "org2": "secret2"}
+	org1 := New("alice", time.Now().Add(time.Minute), "secret1", WithIssuer("org1"))
+
+	c, err := ParseWithIssuerSecrets(secrets, org1)
+	if err != nil {
+		t.Fatalf("ParseWithIssuerSecrets expected nil error, actual: %v", err)
+	}
+	if c.AuthData != "alice" || c.By != "org1" {
+		t.Errorf("ParseWithIssuerSecrets expected alice from org1, actual: %+v", c)
+	}
+
+	if _, err := ParseWithIssuerSecrets(secrets, New("alice", time.Now().Add(time.Minute), "secret1", WithIssuer("org3"))); err != ErrUnknownIssuer {
+		t.Errorf("ParseWithIssuerSecrets unknown issuer expected ErrUnknownIssuer, actual: %v", err)
+	}
+
+	forged := New("alice", time.Now().Add(time.Minute), "secret1", WithIssuer("org2"))
+	if _, err := ParseWithIssuerSecrets(secrets, forged); err == nil {
+		t.Errorf("ParseWithIssuerSecrets signed with another issuer's secret expected error, actual nil")
+	}
+}
+
+func TestParseWithKeyFunc(t *testing.T) {
+	keys := map[string]string{"alice": "secret1", "bob": "secret2"}
+	keyFunc := func(c *Cookie) (string, error) {
+		if key, ok := keys[c.AuthData]; ok {
+			return key, nil
+		}
+		return "", ErrUnknownIssuer
+	}
+
+	c, err := ParseWithKeyFunc(New("alice", time.Now().Add(time.Minute), "secret1"), keyFunc)
+	if err != nil || c.AuthData != "alice" {
+		t.Errorf("ParseWithKeyFunc expected alice and nil error, actual: %+v %v", c, err)
+	}
+	if _, err := ParseWithKeyFunc(New("bob", time.Now().Add(time.Minute), "secret1"), keyFunc); err == nil {
+		t.Errorf("ParseWithKeyFunc signed with another key expected error, actual nil")
+	}
+	if _, err := ParseWithKeyFunc(New("carol", time.Now().Add(time.Minute), "secret1"), keyFunc); !errors.Is(err, ErrUnknownIssuer) {
+		t.Errorf("ParseWithKeyFunc expected key func error, actual: %v", err)
+	}
+	empty := func(*Cookie) (string, error) { return "", nil }
+	if _, err := ParseWithKeyFunc(New("alice", time.Now().Add(time.Minute), ""), empty); err == nil {
+		t.Errorf("ParseWithKeyFunc empty secret expected error, actual nil")
+	}
+}
@@ -0,0 +1,127 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// plainCookie is a Cookie without methods, so marshalling it doesn't recurse into the methods of Cookie.
+type plainCookie Cookie
+
+// claimNames is the set of JSON names of the Cookie fields. Keys of the payload not in it are kept in Extra.
+var claimNames = func() map[string]struct{} {
+	names := map[string]struct{}{}
+	t := reflect.TypeOf(Cookie{})
+	for i := 0; i < t.NumField(); i++ {
+		if name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]; name != "" && name != "-" {
+			names[name] = struct{}{}
+		}
+	}
+	return names
+}()
+
+// MarshalJSON encodes a cookie payload, including the keys in Extra. Extra keys which collide with claims are ignored. The keys are sorted, as Mojolicious sorts them, so cookies minted for the same session by Go and Perl are identical, unless a string contains '/', '<', '>', or '&', which encoding/json and Mojolicious escape differently. Both verify the signature over the base64 text and read either escaping, so the difference doesn't affect interop.
+func (c Cookie) MarshalJSON() ([]byte, error) {
+	b, err := json.Marshal(plainCookie(c))
+	if err != nil {
+		return nil, err
+	}
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return nil, err
+	}
+	for name, val := range c.Extra {
+		if _, ok := claimNames[name]; !ok {
+			fields[name] = val
+		}
+	}
+	return json.Marshal(fields)
+}
+
+// UnmarshalJSON decodes a cookie payload, keeping unknown keys in Extra. It accepts the expiration as a floating-point Unix time, as Perl Mojolicious sometimes writes it, and truncates it to whole seconds. It also accepts the expiration as a string containing a number, as some clients write it.
+func (c *Cookie) UnmarshalJSON(b []byte) error {
+	aux := struct {
+		*plainCookie
+		ExpiresUnix json.RawMessage `json:"expires"`
+	}{plainCookie: (*plainCookie)(c)}
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+	if len(aux.ExpiresUnix) > 0 {
+		expires, err := decodeExpires(aux.ExpiresUnix)
+		if err != nil {
+			return fmt.Errorf("decoding expires: %w", err)
+		}
+		c.ExpiresUnix = expires
+	}
+
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return err
+	}
+	c.Extra = nil
+	for name, val := range fields {
+		if _, ok := claimNames[name]; ok {
+			continue
+		}
+		if c.Extra == nil {
+			c.Extra = map[string]json.RawMessage{}
+		}
+		c.Extra[name] = val
+	}
+	return nil
+}
+
+// decodeExpires decodes the raw JSON expiration, which may be a number or a string containing a number. A null expiration is zero.
+func decodeExpires(raw json.RawMessage) (int64, error) {
+	switch raw[0] {
+	case 'n':
+		if string(raw) == "null" {
+			return 0, nil
+		}
+	case '"':
+		s := ""
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return 0, err
+		}
+		expires, err := parseUnixSeconds(strings.TrimSpace(s))
+		if err != nil {
+			return 0, fmt.Errorf("string '%s' is not a number", s)
+		}
+		return expires, nil
+	case '-', '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+		return parseUnixSeconds(string(raw))
+	}
+	return 0, fmt.Errorf("%s is not a number", raw)
+}
+
+// parseUnixSeconds parses an integer or floating-point Unix time, truncated to whole seconds.
+func parseUnixSeconds(s string) (int64, error) {
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return i, nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, err
+	}
+	if math.IsNaN(f) || f >= math.MaxInt64 || f <= math.MinInt64 {
+		return 0, fmt.Errorf("time '%s' out of range", s)
+	}
+	return int64(f), nil
+}
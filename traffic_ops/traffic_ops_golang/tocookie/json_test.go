@@ -0,0 +1,101 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// mojoFloatExpiryCookie is a cookie in the Mojolicious format, with sorted keys and a floating-point expiration, signed with the secret "mojolicious-secret".
+const mojoFloatExpiryCookie = "eyJhdXRoX2RhdGEiOiJhbGljZSIsImJ5IjoidHJhZmZpY2NvbnRyb2wtZ28tdG9jb29raWUiLCJleHBpcmVzIjo0MTAyNDQ0ODAwLjV9--f0b612e16b1275dac4c6df81f0baa9e829a7dcd9"
+
+func TestParseFloatExpiry(t *testing.T) {
+	c, err := Parse("mojolicious-secret", mojoFloatExpiryCookie)
+	if err != nil {
+		t.Fatalf("Parse float expiry expected nil error, actual: %v", err)
+	}
+	if c.ExpiresUnix != 4102444800 || c.AuthData != "alice" {
+		t.Errorf("Parse float expiry expected alice expiring at 4102444800, actual: %+v", c)
+	}
+}
+
+func TestUnmarshalExpires(t *testing.T) {
+	valid := map[string]int64{
+		`{"expires":1700000000}`:      1700000000,
+		`{"expires":1700000000.5}`:    1700000000,
+		`{"expires":1.7e9}`:           1700000000,
+		`{"auth_data":"alice"}`:       0,
+		`{"expires":-1700000000.25}`:  -1700000000,
+		`{"expires":"1700000000"}`:    1700000000,
+		`{"expires":" 1700000000.5"}`: 1700000000,
+		`{"expires":null}`:            0,
+	}
+	for payload, expected := range valid {
+		c := Cookie{}
+		if err := json.Unmarshal([]byte(payload), &c); err != nil {
+			t.Errorf("%v: Unmarshal expected nil error, actual: %v", payload, err)
+		} else if c.ExpiresUnix != expected {
+			t.Errorf("%v: Unmarshal expected ExpiresUnix %v, actual: %v", payload, expected, c.ExpiresUnix)
+		}
+	}
+
+	for _, payload := range []string{`{"expires":1e400}`, `{"expires":true}`, `{"expires":{}}`, `{"expires":"soon"}`, `{"expires":""}`, `{"expires":"NaN"}`} {
+		if err := json.Unmarshal([]byte(payload), &Cookie{}); err == nil {
+			t.Errorf("%v: Unmarshal expected error, actual nil", payload)
+		}
+	}
+}
+
+// mojoFlashCookie is a Mojolicious session cookie with flash data, signed with the secret "mojolicious-secret".
+const mojoFlashCookie = "eyJhdXRoX2RhdGEiOiJhbGljZSIsImJ5IjoidHJhZmZpY2NvbnRyb2wtZ28tdG9jb29raWUiLCJleHBpcmVzIjo0MTAyNDQ0ODAwLCJmbGFzaCI6eyJtZXNzYWdlIjoiU2F2ZWQhIn0sIm5ld19mbGFzaCI6eyJub3RpY2UiOlsiYSIsImIiXX19--c1fe5aba203a6064a89e844fb186f0423198a477"
+
+func TestRefreshPreservesExtra(t *testing.T) {
+	secret := "mojolicious-secret"
+	c, err := Parse(secret, mojoFlashCookie)
+	if err != nil {
+		t.Fatalf("Parse flash cookie expected nil error, actual: %v", err)
+	}
+	expected := map[string]string{"flash": `{"message":"Saved!"}`, "new_flash": `{"notice":["a","b"]}`}
+	if len(c.Extra) != len(expected) {
+		t.Fatalf("Parse flash cookie expected Extra keys %v, actual: %v", expected, c.Extra)
+	}
+
+	refreshed, err := Parse(secret, Refresh(c, secret))
+	if err != nil {
+		t.Fatalf("Parse refreshed flash cookie expected nil error, actual: %v", err)
+	}
+	for name, val := range expected {
+		if actual := string(refreshed.Extra[name]); actual != val {
+			t.Errorf("Refresh expected Extra '%v' to be '%v', actual: '%v'", name, val, actual)
+		}
+	}
+	if refreshed.AuthData != "alice" {
+		t.Errorf("Refresh expected AuthData alice, actual: %v", refreshed.AuthData)
+	}
+}
+
+func TestMarshalExtraDoesNotOverrideClaims(t *testing.T) {
+	c := Cookie{AuthData: "alice", Extra: map[string]json.RawMessage{"auth_data": json.RawMessage(`"mallory"`), "flash": json.RawMessage(`1`)}}
+	b, err := json.Marshal(c)
+	if err != nil {
+		t.Fatalf("Marshal expected nil error, actual: %v", err)
+	}
+	decoded := Cookie{}
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatalf("Unmarshal expected nil error, actual: %v", err)
+	}
+	if decoded.AuthData != "alice" || string(decoded.Extra["flash"]) != "1" {
+		t.Errorf("Marshal expected claims to take precedence over Extra, actual: %s", b)
+	}
+}
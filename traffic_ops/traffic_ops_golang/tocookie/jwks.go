@@ -0,0 +1,289 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultJWKSMaxAge is the default time JWKSHandler lets clients cache the key set.
+const DefaultJWKSMaxAge = 5 * time.Minute
+
+// rotationRSABits is the size of the RSA keys StartRotation generates.
+const rotationRSABits = 2048
+
+// WithKeyRetention sets how long SigningKeys keeps publishing, and verifying cookies with, a key after it is rotated out. It should be at least the lifetime of cookies, as cookies signed with the key before it was rotated are verified until it is retired. The default is DefaultDuration, plus the leeway of WithLeeway.
+func WithKeyRetention(retention time.Duration) Option {
+	return func(o *options) { o.keyRetention = retention }
+}
+
+// SigningKeys are the private key which signs cookies, and the public keys of the keys it replaced, which are retained until the cookies they signed have expired. They are published by JWKSHandler, so services such as Traffic Router can verify cookies without the keys being distributed to them. SigningKeys is safe for concurrent use.
+type SigningKeys struct {
+	o *options
+
+	mu       sync.RWMutex
+	activeID string
+	active   crypto.Signer
+	// retired are the keys rotated out, newest first.
+	retired []retiredKey
+}
+
+type retiredKey struct {
+	id    string
+	pub   crypto.PublicKey
+	until time.Time
+}
+
+// NewSigningKeys returns SigningKeys signing with the key, an ed25519.PrivateKey or *rsa.PrivateKey, as WithSigner takes, with the ID. If the ID is empty, the JWKThumbprint of the key is its ID. The options configure WithKeyRetention, WithLeeway, WithClock, and WithLogger.
+func NewSigningKeys(id string, key crypto.Signer, opts ...Option) (*SigningKeys, error) {
+	s := &SigningKeys{o: newOptions(opts)}
+	if s.o.keyRetention <= 0 {
+		s.o.keyRetention = DefaultDuration + s.o.leeway
+	}
+	id, err := signingKeyID(id, key)
+	if err != nil {
+		return nil, err
+	}
+	s.activeID, s.active = id, key
+	return s, nil
+}
+
+// signingKeyID returns the ID, or the thumbprint of the key if the ID is empty, or an error if the key isn't supported.
+func signingKeyID(id string, key crypto.Signer) (string, error) {
+	if signerAlg(key) == "" {
+		return "", fmt.Errorf("unsupported private key type %T", key)
+	}
+	if id != "" {
+		return id, nil
+	}
+	return JWKThumbprint(key.Public())
+}
+
+// Active returns the ID and key which sign new cookies.
+func (s *SigningKeys) Active() (string, crypto.Signer) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.activeID, s.active
+}
+
+// Rotate makes the key, with the ID, or its thumbprint if the ID is empty, sign new cookies. The key it replaces is retired, and only verifies cookies until its retention lapses. Clients which cached the key set before the rotation don't know the new key; they should fetch it again when they see a cookie with an unknown key ID, as tocookie/oidc does for ID tokens.
+func (s *SigningKeys) Rotate(id string, key crypto.Signer) error {
+	id, err := signingKeyID(id, key)
+	if err != nil {
+		return err
+	}
+	now := s.o.now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	retired := append([]retiredKey{{id: s.activeID, pub: s.active.Public(), until: now.Add(s.o.keyRetention)}}, s.retired...)
+	s.retired = retired[:0]
+	for _, k := range retired {
+		if now.Before(k.until) && k.id != id {
+			s.retired = append(s.retired, k)
+		}
+	}
+	s.activeID, s.active = id, key
+	return nil
+}
+
+// PublicKey returns the public key of the ID, of the active key or of a retired key whose retention hasn't lapsed.
+func (s *SigningKeys) PublicKey(id string) (crypto.PublicKey, bool) {
+	now := s.o.now()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if id == s.activeID {
+		return s.active.Public(), true
+	}
+	for _, k := range s.retired {
+		if k.id == id && now.Before(k.until) {
+			return k.pub, true
+		}
+	}
+	return nil, false
+}
+
+// JWKS returns the key set of the active key, followed by the retired keys whose retention hasn't lapsed, newest first.
+func (s *SigningKeys) JWKS() JWKS {
+	now := s.o.now()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	set := JWKS{Keys: []JWK{newJWK(s.activeID, s.active.Public())}}
+	for _, k := range s.retired {
+		if now.Before(k.until) {
+			set.Keys = append(set.Keys, newJWK(k.id, k.pub))
+		}
+	}
+	return set
+}
+
+// StartRotation rotates to a new key every period, of the type of the active key, identified by its thumbprint, until the returned function is called. Failures to generate keys are logged, and the active key is kept until the next period; see WithLogger.
+func (s *SigningKeys) StartRotation(period time.Duration) func() {
+	done := make(chan struct{})
+	ticker := time.NewTicker(period)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				_, active := s.Active()
+				key, err := generateLike(active)
+				if err == nil {
+					err = s.Rotate("", key)
+				}
+				if err != nil {
+					s.o.logger.Warnf("tocookie: rotating signing key: %v", err)
+					continue
+				}
+				id, _ := s.Active()
+				s.o.logger.Infof("tocookie: rotated signing key to '%s'", id)
+			}
+		}
+	}()
+	var once sync.Once
+	return func() { once.Do(func() { close(done) }) }
+}
+
+// generateLike generates a private key of the type, and size, of the key.
+func generateLike(key crypto.Signer) (crypto.Signer, error) {
+	switch key := key.(type) {
+	case ed25519.PrivateKey:
+		_, priv, err := ed25519.GenerateKey(rand.Reader)
+		return priv, err
+	case *rsa.PrivateKey:
+		bits := key.N.BitLen()
+		if bits < rotationRSABits {
+			bits = rotationRSABits
+		}
+		return rsa.GenerateKey(rand.Reader, bits)
+	}
+	return nil, fmt.Errorf("unsupported private key type %T", key)
+}
+
+// WithSigningKeys makes New and Refresh sign cookies with the active key of the SigningKeys, with its ID, as WithSigner and WithKeyID do. The key is that which is active when the options are applied, i.e. when New or Refresh is called.
+func WithSigningKeys(keys *SigningKeys) Option {
+	return func(o *options) { o.keyID, o.signer = keys.Active() }
+}
+
+// ParseWithSigningKeys parses a cookie like ParseWithPublicKey, verified with the public key of the SigningKeys with the cookie's key ID. Cookies without a key ID, or with the ID of a key which isn't active or retained, are rejected with ErrUnknownKey.
+func ParseWithSigningKeys(keys *SigningKeys, cookie string, opts ...Option) (*Cookie, error) {
+	o := newOptions(opts)
+	return o.parseWith(context.Background(), func() (*Cookie, error) { return parseWithSigningKeys(keys, cookie, o) })
+}
+
+func parseWithSigningKeys(keys *SigningKeys, cookie string, o *options) (*Cookie, error) {
+	s, err := splitCookie(cookie, o)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := keys.PublicKey(s.header.Kid)
+	if s.header.Kid == "" || !ok {
+		return nil, ErrUnknownKey
+	}
+	o.publicKey = key
+	return parse("", cookie, o)
+}
+
+// JWKS is a JSON Web Key Set of RFC 7517.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWK is a public JSON Web Key of RFC 7517: an RSA key, or an Ed25519 "OKP" key of RFC 8037, for verifying signatures.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	// Crv and X are the curve and public key of OKP keys.
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	// N and E are the modulus and exponent of RSA keys.
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+}
+
+// newJWK returns the JWK of a public key which publicKeyAlg supports.
+func newJWK(id string, pub crypto.PublicKey) JWK {
+	k := jwkFields(pub)
+	k.Kid, k.Use, k.Alg = id, "sig", publicKeyAlg(pub)
+	return k
+}
+
+// jwkFields returns the JWK of the public key with only its required members, as its thumbprint is computed over, or a JWK without a type if the key isn't supported.
+func jwkFields(pub crypto.PublicKey) JWK {
+	switch pub := pub.(type) {
+	case ed25519.PublicKey:
+		return JWK{Kty: "OKP", Crv: "Ed25519", X: base64.RawURLEncoding.EncodeToString(pub)}
+	case *rsa.PublicKey:
+		return JWK{Kty: "RSA", N: base64.RawURLEncoding.EncodeToString(pub.N.Bytes()), E: base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())}
+	}
+	return JWK{}
+}
+
+// JWKThumbprint returns the JWK thumbprint of RFC 7638 of the public key, an ed25519.PublicKey or *rsa.PublicKey: the unpadded base64url SHA-256 digest of its required members, which is a stable ID of the key.
+func JWKThumbprint(pub crypto.PublicKey) (string, error) {
+	k := jwkFields(pub)
+	var members string
+	// the members are in lexicographic order, without whitespace, as RFC 7638 §3.3 requires.
+	switch k.Kty {
+	case "OKP":
+		members = `{"crv":` + strconv.Quote(k.Crv) + `,"kty":"OKP","x":` + strconv.Quote(k.X) + `}`
+	case "RSA":
+		members = `{"e":` + strconv.Quote(k.E) + `,"kty":"RSA","n":` + strconv.Quote(k.N) + `}`
+	default:
+		return "", fmt.Errorf("unsupported public key type %T", pub)
+	}
+	sum := sha256.Sum256([]byte(members))
+	return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+// JWKSHandler returns a handler serving the JWKS of the keys, with a Cache-Control max-age of maxAge, or DefaultJWKSMaxAge if it isn't positive. It serves the keys current at each request, so rotations are published as soon as clients' caches expire. Requests other than GET and HEAD are answered with 405 Method Not Allowed.
+func JWKSHandler(keys *SigningKeys, maxAge time.Duration) http.Handler {
+	if maxAge <= 0 {
+		maxAge = DefaultJWKSMaxAge
+	}
+	cacheControl := "public, max-age=" + strconv.Itoa(int(maxAge/time.Second))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", http.MethodGet+", "+http.MethodHead)
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		body, err := json.Marshal(keys.JWKS())
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/jwk-set+json")
+		w.Header().Set("Cache-Control", cacheControl)
+		w.WriteHeader(http.StatusOK)
+		if r.Method == http.MethodGet {
+			w.Write(body)
+		}
+	})
+}
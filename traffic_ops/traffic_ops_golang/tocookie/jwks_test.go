@@ -0,0 +1,149 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestJWKThumbprint(t *testing.T) {
+	// the examples of RFC 7638 §3.1 and RFC 8037 §A.3
+	n, _ := base64.RawURLEncoding.DecodeString("0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw")
+	x, _ := base64.RawURLEncoding.DecodeString("11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo")
+	tests := map[string]struct {
+		key      interface{}
+		expected string
+	}{
+		"rsa":     {&rsa.PublicKey{N: new(big.Int).SetBytes(n), E: 65537}, "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs"},
+		"ed25519": {ed25519.PublicKey(x), "kPrK_qmxVWaYVA9wwBF6Iuo3vVzz7TxHCTwXBygrS4k"},
+	}
+	for name, test := range tests {
+		if actual, err := JWKThumbprint(test.key); err != nil || actual != test.expected {
+			t.Errorf("%v: JWKThumbprint expected %v, actual: %v %v", name, test.expected, actual, err)
+		}
+	}
+	if _, err := JWKThumbprint("key"); err == nil {
+		t.Errorf("JWKThumbprint unsupported key expected error, actual nil")
+	}
+}
+
+func TestSigningKeys(t *testing.T) {
+	now := time.Now()
+	clock := WithClock(func() time.Time { return now })
+	_, first, _ := ed25519.GenerateKey(rand.Reader)
+	keys, err := NewSigningKeys("", first, clock, WithKeyRetention(time.Hour))
+	if err != nil {
+		t.Fatalf("NewSigningKeys expected nil error, actual: %v", err)
+	}
+	firstID, _ := keys.Active()
+	if expected, _ := JWKThumbprint(first.Public()); firstID != expected {
+		t.Errorf("NewSigningKeys expected thumbprint ID %v, actual: %v", expected, firstID)
+	}
+
+	old := New("alice", now.Add(time.Minute), "", WithSigningKeys(keys), clock)
+	second, _ := rsa.GenerateKey(rand.Reader, 2048)
+	if err := keys.Rotate("second", second); err != nil {
+		t.Fatalf("Rotate expected nil error, actual: %v", err)
+	}
+	current := New("alice", now.Add(time.Minute), "", WithSigningKeys(keys), clock)
+	for name, cookie := range map[string]string{"retired": old, "active": current} {
+		if c, err := ParseWithSigningKeys(keys, cookie, clock); err != nil || c.AuthData != "alice" {
+			t.Errorf("ParseWithSigningKeys %v key expected alice, actual: %+v %v", name, c, err)
+		}
+	}
+	if set := keys.JWKS(); len(set.Keys) != 2 || set.Keys[0].Kid != "second" || set.Keys[0].Alg != AlgRS256 || set.Keys[1].Kid != firstID || set.Keys[1].Kty != "OKP" {
+		t.Errorf("JWKS expected active RSA key then retired Ed25519 key, actual: %+v", set)
+	}
+
+	now = now.Add(time.Hour)
+	if _, err := ParseWithSigningKeys(keys, old, SkipExpiry(), clock); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("ParseWithSigningKeys with lapsed key expected ErrUnknownKey, actual: %v", err)
+	}
+	if set := keys.JWKS(); len(set.Keys) != 1 {
+		t.Errorf("JWKS expected lapsed key unpublished, actual: %+v", set)
+	}
+	if _, err := ParseWithSigningKeys(keys, New("alice", now.Add(time.Minute), "secret"), clock); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("ParseWithSigningKeys HMAC cookie expected ErrUnknownKey, actual: %v", err)
+	}
+	if _, err := NewSigningKeys("x", nil); err == nil {
+		t.Errorf("NewSigningKeys nil key expected error, actual nil")
+	}
+}
+
+func TestSigningKeysStartRotation(t *testing.T) {
+	_, key, _ := ed25519.GenerateKey(rand.Reader)
+	keys, _ := NewSigningKeys("first", key)
+	stop := keys.StartRotation(time.Millisecond)
+	deadline := time.Now().Add(5 * time.Second)
+	for id, _ := keys.Active(); id == "first" && time.Now().Before(deadline); id, _ = keys.Active() {
+		time.Sleep(time.Millisecond)
+	}
+	stop()
+	stop()
+	if id, _ := keys.Active(); id == "first" {
+		t.Fatalf("StartRotation expected key rotated, actual: %v", id)
+	}
+	if _, ok := keys.PublicKey("first"); !ok {
+		t.Errorf("StartRotation expected first key retained")
+	}
+}
+
+func TestJWKSHandler(t *testing.T) {
+	_, key, _ := ed25519.GenerateKey(rand.Reader)
+	keys, _ := NewSigningKeys("k1", key)
+	handler := JWKSHandler(keys, time.Hour)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil))
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/jwk-set+json" || w.Header().Get("Cache-Control") != "public, max-age=3600" {
+		t.Errorf("JWKSHandler expected 200 cacheable JWKS, actual: %v %v", w.Code, w.Header())
+	}
+	set := JWKS{}
+	if err := json.Unmarshal(w.Body.Bytes(), &set); err != nil || len(set.Keys) != 1 || set.Keys[0].Kid != "k1" || set.Keys[0].Use != "sig" {
+		t.Errorf("JWKSHandler expected key k1, actual: %v %v", w.Body, err)
+	}
+	if x, _ := base64.RawURLEncoding.DecodeString(set.Keys[0].X); !ed25519.PublicKey(x).Equal(key.Public()) {
+		t.Errorf("JWKSHandler expected public key, actual: %v", set.Keys[0].X)
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/.well-known/jwks.json", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("JWKSHandler POST expected 405, actual: %v", w.Code)
+	}
+}
+
+func TestSigningKeysHooks(t *testing.T) {
+	_, key, _ := ed25519.GenerateKey(rand.Reader)
+	keys, err := NewSigningKeys("1", key)
+	if err != nil {
+		t.Fatalf("NewSigningKeys expected nil error, actual: %v", err)
+	}
+	errDenied := errors.New("denied")
+	deny := WithHooks(func(ctx context.Context, c *Cookie) error { return errDenied })
+	cookie := New("alice", time.Now().Add(time.Minute), "", WithSigningKeys(keys))
+	if _, err := ParseWithSigningKeys(keys, cookie, deny); !errors.Is(err, ErrHookRejected) || !errors.Is(err, errDenied) {
+		t.Errorf("ParseWithSigningKeys with a rejecting hook expected ErrHookRejected, actual: %v", err)
+	}
+}
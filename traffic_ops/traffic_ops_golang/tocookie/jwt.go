@@ -0,0 +1,203 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// The JWT signature algorithms of RFC 7518 supported by JWTKey.
+const (
+	JWTAlgHS256 = "HS256"
+	JWTAlgRS256 = "RS256"
+)
+
+// jwtFieldNames are the registered JWT claims of RFC 7519 the Cookie fields with other names are stored as. IssuedAt, NotBefore, Audience, and JTI already have the names of registered claims; the other fields keep their cookie names as private claims.
+var jwtFieldNames = map[string]string{FieldAuthData: "sub", FieldExpires: "exp", FieldBy: "iss"}
+
+// JWTKey is a key which signs or verifies RFC 7519 JSON Web Tokens, with a single algorithm. A JWT is only verified if its header names the algorithm of the key, so a token can't be verified with a different algorithm than it was signed with.
+type JWTKey struct {
+	alg    string
+	secret []byte
+	priv   *rsa.PrivateKey
+	pub    *rsa.PublicKey
+}
+
+// HS256Key returns a key which signs and verifies JWTs with HMAC-SHA256 and the secret.
+func HS256Key(secret []byte) JWTKey {
+	return JWTKey{alg: JWTAlgHS256, secret: append([]byte(nil), secret...)}
+}
+
+// RS256Key returns a key which signs JWTs with RSASSA-PKCS1-v1_5 SHA-256 and the private key, and verifies them with its public key.
+func RS256Key(priv *rsa.PrivateKey) JWTKey {
+	return JWTKey{alg: JWTAlgRS256, priv: priv, pub: &priv.PublicKey}
+}
+
+// RS256PublicKey returns a key which only verifies JWTs signed with RSASSA-PKCS1-v1_5 SHA-256, for services which accept tokens but don't mint them.
+func RS256PublicKey(pub *rsa.PublicKey) JWTKey {
+	return JWTKey{alg: JWTAlgRS256, pub: pub}
+}
+
+// jwtHeader is the JOSE header of a JWT.
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ,omitempty"`
+}
+
+// sign returns the signature of the signing input of a JWT.
+func (k JWTKey) sign(input string) ([]byte, error) {
+	switch k.alg {
+	case JWTAlgHS256:
+		mac := hmac.New(sha256.New, k.secret)
+		mac.Write([]byte(input))
+		return mac.Sum(nil), nil
+	case JWTAlgRS256:
+		if k.priv == nil {
+			return nil, errors.New("RS256 key has no private key to sign with")
+		}
+		digest := sha256.Sum256([]byte(input))
+		return rsa.SignPKCS1v15(nil, k.priv, crypto.SHA256, digest[:])
+	}
+	return nil, fmt.Errorf("unsupported JWT algorithm '%s'", k.alg)
+}
+
+// verify returns whether the signature of the signing input of a JWT is authentic.
+func (k JWTKey) verify(input string, sig []byte) bool {
+	switch k.alg {
+	case JWTAlgHS256:
+		expected, _ := k.sign(input)
+		return hmac.Equal(sig, expected)
+	case JWTAlgRS256:
+		digest := sha256.Sum256([]byte(input))
+		return k.pub != nil && rsa.VerifyPKCS1v15(k.pub, crypto.SHA256, digest[:], sig) == nil
+	}
+	return false
+}
+
+// NewJWT mints a JWT carrying the same claims New would put in a cookie, signed with the key. The user is the "sub" claim, the expiration is "exp", and the issuer is "iss"; see CookieToJWT for the mapping of the other claims. Options setting claims, such as WithAudience, WithRoles, and WithClaims, are applied, while options of the cookie format, such as WithVersion and WithCompression, are ignored. It returns an empty string if the token can't be signed.
+func NewJWT(user string, expiration time.Time, key JWTKey, opts ...Option) string {
+	o := newOptions(opts)
+	c, err := o.newSession(user, expiration)
+	if err != nil {
+		return ""
+	}
+	token, err := encodeJWT(c, key, o)
+	if err != nil {
+		return ""
+	}
+	return token
+}
+
+// ParseJWT verifies a JWT minted by NewJWT or CookieToJWT, or by any issuer using the same claims, and validates its claims as Parse validates a cookie's. The token is rejected if its header names an algorithm other than the key's; in particular, unsigned "none" tokens are never accepted. The "aud" claim must be a single string.
+//
+// Errors for tokens which aren't authentic wrap ErrBadSignature or ErrMalformed, as Parse's do.
+func ParseJWT(token string, key JWTKey, opts ...Option) (*Cookie, error) {
+	o := newOptions(opts)
+	return o.parseWith(context.Background(), func() (*Cookie, error) { return parseJWT(token, key, o) })
+}
+
+func parseJWT(token string, key JWTKey, o *options) (*Cookie, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: JWT has %d parts, expected 3", ErrMalformed, len(parts))
+	}
+	hdrBytes, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("%w: error decoding JWT header: %w", ErrMalformed, err)
+	}
+	hdr := jwtHeader{}
+	if err := json.Unmarshal(hdrBytes, &hdr); err != nil {
+		return nil, fmt.Errorf("%w: error decoding JWT header '%s': %w", ErrMalformed, string(hdrBytes), err)
+	}
+	if hdr.Alg != key.alg {
+		return nil, fmt.Errorf("%w: JWT algorithm '%s' doesn't match key algorithm '%s'", ErrBadSignature, hdr.Alg, key.alg)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: error decoding JWT signature: %w", ErrMalformed, err)
+	}
+	if !key.verify(parts[0]+"."+parts[1], sig) {
+		return nil, ErrBadSignature
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("%w: error decoding JWT payload: %w", ErrMalformed, err)
+	}
+	c, err := decodeClaims(payload, jwtOptions(o))
+	if err != nil {
+		return nil, err
+	}
+	return checkVerified(c, o)
+}
+
+// jwtOptions returns the options with the JWT names of the Cookie fields.
+func jwtOptions(o *options) *options {
+	jwt := *o
+	jwt.fieldNames = jwtFieldNames
+	return &jwt
+}
+
+// encodeJWT serializes and signs the claims of the cookie as a JWT.
+func encodeJWT(c *Cookie, key JWTKey, o *options) (string, error) {
+	if c.claimsSize() > o.maxClaimsSize {
+		return "", ErrClaimsTooLarge
+	}
+	hdrBytes, err := json.Marshal(jwtHeader{Alg: key.alg, Typ: "JWT"})
+	if err != nil {
+		return "", err
+	}
+	payload, err := jwtOptions(o).marshal(c)
+	if err != nil {
+		return "", fmt.Errorf("encoding JWT claims: %w", err)
+	}
+	input := base64.RawURLEncoding.EncodeToString(hdrBytes) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	sig, err := key.sign(input)
+	if err != nil {
+		return "", fmt.Errorf("signing JWT: %w", err)
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// CookieToJWT exchanges a valid cookie for a JWT signed with the key, carrying the same claims and expiration. The cookie is parsed with the secret and options, as by Parse, so only authentic, unexpired cookies are exchanged.
+//
+// AuthData is stored as the "sub" claim, ExpiresUnix as "exp", and By as "iss"; IssuedAt, NotBefore, Audience, and JTI are stored as the registered claims of the same names, "iat", "nbf", "aud", and "jti". Every other claim, including those in Extra, keeps its cookie name.
+func CookieToJWT(secret, cookie string, key JWTKey, opts ...Option) (string, error) {
+	c, err := Parse(secret, cookie, opts...)
+	if err != nil {
+		return "", err
+	}
+	return encodeJWT(c, key, newOptions(opts))
+}
+
+// JWTToCookie exchanges a valid JWT for a cookie signed with the secret, carrying the same claims and expiration; it is the inverse of CookieToJWT. The token is parsed with the key and options, as by ParseJWT, and the cookie is minted with the options, e.g. WithVersion. Cookies for Perl Traffic Ops must be minted without options which it can't read.
+func JWTToCookie(token string, key JWTKey, secret string, opts ...Option) (string, error) {
+	c, err := ParseJWT(token, key, opts...)
+	if err != nil {
+		return "", err
+	}
+	cookie := encodeCookie(c, secret, newOptions(opts))
+	if cookie == "" {
+		return "", errors.New("the claims can't be encoded as a cookie with the options")
+	}
+	return cookie, nil
+}
@@ -0,0 +1,72 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"crypto/sha256"
+	"io"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// signingKeyInfo is the HKDF info of the HMAC keys of WithKeySeparation.
+const signingKeyInfo = "tocookie signing key v1"
+
+// subkeyLen is the length in bytes of the subkeys derived by deriveKey.
+const subkeyLen = 32
+
+// KeySeparation is how the HMAC key cookies are signed with is derived from their secret; see WithKeySeparation.
+type KeySeparation int
+
+const (
+	// KeySeparationOff signs cookies with the secret itself, as Perl Traffic Ops does. It is the default.
+	KeySeparationOff KeySeparation = iota
+	// KeySeparationCompat signs cookies with the signing subkey, but still accepts cookies signed with the secret itself, for migrating to KeySeparationStrict once every cookie minted before has expired or been refreshed.
+	KeySeparationCompat
+	// KeySeparationStrict signs cookies with the signing subkey, and accepts no others.
+	KeySeparationStrict
+)
+
+// WithKeySeparation makes New sign cookies, and Parse verify them, with a subkey derived from the secret for signing alone, rather than with the secret itself, so the secret is only ever used as the input of key derivations: the signing, encryption, CSRF, and API token keys are each derived from it for their one purpose, and a weakness of one use can't be exploited through another. By default, KeySeparationOff, cookies are signed with the secret, as Perl Traffic Ops signs them, which can't read cookies signed with the subkey.
+//
+// The signing subkey is HKDF-SHA256 (RFC 5869) of the secret, with no salt and the info "tocookie signing key v1"; 32 bytes are derived. The encryption key of WithEncryption is derived from the secret as it is without this option, so encrypted cookies are decrypted alike in every mode. Given WithPerUserKeys, the subkey is derived from the user's key. Given KeySeparationCompat, Parse also accepts cookies signed with the secret itself, which are signed with the subkey once they are refreshed.
+func WithKeySeparation(mode KeySeparation) Option {
+	return func(o *options) { o.keySeparation = mode }
+}
+
+// deriveKey returns the subkey of the secret for the purpose named by info, salted with salt, which may be nil: HKDF-SHA256 of subkeyLen bytes. Every key this package derives from a secret for a single purpose is derived by it, with an info string naming the purpose, so keys for different purposes never collide. The info strings end in a version, so a derivation can be changed by changing its version, without its new keys colliding with the old.
+func deriveKey(secret, salt []byte, info string) []byte {
+	key := make([]byte, subkeyLen)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, secret, salt, []byte(info)), key); err != nil {
+		panic("deriving " + info + ": " + err.Error()) // only possible if subkeyLen exceeds the HKDF limit
+	}
+	return key
+}
+
+// macKey returns the key of the HMAC of cookies signed with the key, which is the key itself unless WithKeySeparation is given.
+func (o *options) macKey(key []byte) []byte {
+	if o.keySeparation == KeySeparationOff {
+		return key
+	}
+	return deriveKey(key, nil, signingKeyInfo)
+}
+
+// verifiesRawKey returns whether messageMAC is a tag of the message with the key itself, rather than its signing subkey, for KeySeparationCompat.
+func (o *options) verifiesRawKey(message, messageMAC, key []byte) bool {
+	if o.keySeparation != KeySeparationCompat {
+		return false
+	}
+	raw := *o
+	raw.keySeparation = KeySeparationOff
+	return checkHmac(message, messageMAC, key, &raw) || raw.verifiesLegacy(message, messageMAC, key)
+}
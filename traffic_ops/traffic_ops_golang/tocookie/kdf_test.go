@@ -0,0 +1,92 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+func TestWithKeySeparation(t *testing.T) {
+	secret := "secret"
+	expiration := time.Now().Add(time.Hour)
+	raw := New("alice", expiration, secret)
+	separated := New("alice", expiration, secret, WithKeySeparation(KeySeparationStrict))
+
+	subkey := make([]byte, 32)
+	io.ReadFull(hkdf.New(sha256.New, []byte(secret), nil, []byte("tocookie signing key v1")), subkey)
+	signed := separated[:strings.LastIndex(separated, "--")]
+	mac := hmac.New(sha1.New, subkey)
+	mac.Write([]byte(signed))
+	if expected := signed + "--" + hex.EncodeToString(mac.Sum(nil)); separated != expected {
+		t.Errorf("New with KeySeparationStrict expected cookie signed with the subkey '%v', actual: '%v'", expected, separated)
+	}
+
+	tests := map[string]struct {
+		cookie string
+		mode   KeySeparation
+		valid  bool
+	}{
+		"raw off":          {raw, KeySeparationOff, true},
+		"separated off":    {separated, KeySeparationOff, false},
+		"raw compat":       {raw, KeySeparationCompat, true},
+		"separated compat": {separated, KeySeparationCompat, true},
+		"raw strict":       {raw, KeySeparationStrict, false},
+		"separated strict": {separated, KeySeparationStrict, true},
+	}
+	for name, test := range tests {
+		for _, parse := range []func(string, ...Option) (*Cookie, error){
+			func(cookie string, opts ...Option) (*Cookie, error) { return Parse(secret, cookie, opts...) },
+			func(cookie string, opts ...Option) (*Cookie, error) { return NewParser(secret, opts...).Parse(cookie) },
+		} {
+			_, err := parse(test.cookie, WithKeySeparation(test.mode))
+			if test.valid && err != nil || !test.valid && !errors.Is(err, ErrBadSignature) {
+				t.Errorf("%v: Parse expected valid %v, actual: %v", name, test.valid, err)
+			}
+		}
+	}
+
+	c, _ := Parse(secret, raw, WithKeySeparation(KeySeparationCompat))
+	if _, err := Parse(secret, Refresh(c, secret, WithKeySeparation(KeySeparationCompat)), WithKeySeparation(KeySeparationStrict)); err != nil {
+		t.Errorf("Parse of cookie refreshed with KeySeparationCompat expected signed with the subkey, actual: %v", err)
+	}
+}
+
+func TestKeySeparationEncryption(t *testing.T) {
+	secret := "secret"
+	expiration := time.Now().Add(time.Hour)
+	raw := New("alice", expiration, secret, WithEncryption())
+	if c, err := Parse(secret, raw, WithKeySeparation(KeySeparationCompat)); err != nil || c.AuthData != "alice" {
+		t.Errorf("Parse with KeySeparationCompat of encrypted cookie signed with the secret expected alice, actual: %+v %v", c, err)
+	}
+	separated := New("alice", expiration, secret, WithEncryption(), WithKeySeparation(KeySeparationStrict))
+	if c, err := Parse(secret, separated, WithKeySeparation(KeySeparationStrict)); err != nil || c.AuthData != "alice" {
+		t.Errorf("Parse with KeySeparationStrict of encrypted cookie expected alice, actual: %+v %v", c, err)
+	}
+	userKeyed := New("alice", expiration, secret, WithPerUserKeys(), WithKeySeparation(KeySeparationStrict))
+	if _, err := Parse(secret, userKeyed, WithPerUserKeys()); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("Parse without KeySeparationStrict of per-user cookie signed with the subkey expected ErrBadSignature, actual: %v", err)
+	}
+	if c, err := Parse(secret, userKeyed, WithPerUserKeys(), WithKeySeparation(KeySeparationStrict)); err != nil || c.AuthData != "alice" {
+		t.Errorf("Parse with KeySeparationStrict of per-user cookie expected alice, actual: %+v %v", c, err)
+	}
+}
@@ -0,0 +1,112 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultKeyFilePollPeriod is how often WatchKeyFile checks the key file for changes.
+const DefaultKeyFilePollPeriod = 5 * time.Second
+
+// WithKeyFilePollPeriod sets how often a Manager's WatchKeyFile checks the key file for changes.
+func WithKeyFilePollPeriod(period time.Duration) Option {
+	return func(o *options) { o.pollPeriod = period }
+}
+
+// WatchKeyFile loads the secret from the file at path, and then polls the file, atomically swapping the active secret whenever the file changes. This allows the secret to be rotated by configuration management without restarting the service.
+//
+// The file must contain the secret on a single line; surrounding whitespace is ignored. If the file is initially unreadable or malformed, an error is returned and nothing is watched. Later, unreadable, empty, or malformed files are logged as warnings and ignored, keeping the previous secret, so a partially written file never zeroes the key.
+//
+// The returned function stops watching.
+func (m *Manager) WatchKeyFile(path string) (func(), error) {
+	secret, modTime, err := readKeyFile(path)
+	if err != nil {
+		return nil, err
+	}
+	m.SetSecret(secret)
+
+	done := make(chan struct{})
+	go watchFile(path, modTime, m.o, done, nil, func() (time.Time, error) {
+		newSecret, newModTime, err := readKeyFile(path)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if newSecret != m.Secret() {
+			m.SetSecret(newSecret)
+			m.o.logger.Infof("tocookie: reloaded key from '%s'", path)
+		}
+		return newModTime, nil
+	})
+	once := sync.Once{}
+	return func() { once.Do(func() { close(done) }) }, nil
+}
+
+// watchFile calls reload whenever the modification time of the file at path changes from modTime, as checked every poll period, or one of the signals is received, until done is closed. Reload returns the new modification time. Failures are logged as warnings, and retried at the next poll.
+func watchFile(path string, modTime time.Time, o *options, done <-chan struct{}, signals <-chan os.Signal, reload func() (time.Time, error)) {
+	ticker := time.NewTicker(o.pollPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-signals:
+			if newModTime, err := reload(); err != nil {
+				o.logger.Warnf("tocookie: reloading key file, keeping previous key: %v", err)
+			} else {
+				modTime = newModTime
+			}
+			continue
+		case <-ticker.C:
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			o.logger.Warnf("tocookie: checking key file '%s', keeping previous key: %v", path, err)
+			continue
+		}
+		if info.ModTime().Equal(modTime) {
+			continue
+		}
+		newModTime, err := reload()
+		if err != nil {
+			o.logger.Warnf("tocookie: reloading key file, keeping previous key: %v", err)
+			continue
+		}
+		modTime = newModTime
+	}
+}
+
+// readKeyFile reads a single-line secret from the file at path, and returns it with the file's modification time.
+func readKeyFile(path string) (string, time.Time, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("reading key file '%s': %w", path, err)
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("reading key file '%s': %w", path, err)
+	}
+	secret := strings.TrimSpace(string(b))
+	if secret == "" {
+		return "", time.Time{}, fmt.Errorf("key file '%s' is empty", path)
+	}
+	if strings.ContainsAny(secret, "\r\n") {
+		return "", time.Time{}, fmt.Errorf("malformed key file '%s' - more than one line", path)
+	}
+	return secret, info.ModTime(), nil
+}
@@ -0,0 +1,105 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testLogger struct {
+	sync.Mutex
+	warnings []string
+}
+
+func (l *testLogger) Infof(format string, v ...interface{}) {}
+
+func (l *testLogger) Warnf(format string, v ...interface{}) {
+	l.Lock()
+	defer l.Unlock()
+	l.warnings = append(l.warnings, fmt.Sprintf(format, v...))
+}
+
+func (l *testLogger) numWarnings() int {
+	l.Lock()
+	defer l.Unlock()
+	return len(l.warnings)
+}
+
+// writeKeyFile writes the file with a modification time distinct from any previous write, so the change is seen regardless of the filesystem's timestamp granularity.
+func writeKeyFile(t *testing.T, path string, contents string, modTime time.Time) {
+	if err := ioutil.WriteFile(path, []byte(contents), 0600); err != nil {
+		t.Fatalf("writing key file: %v", err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatalf("setting key file time: %v", err)
+	}
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		if cond() {
+			return
+		}
+	}
+	t.Fatalf("timed out waiting for %v", what)
+}
+
+func TestWatchKeyFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tocookie")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "secret")
+	start := time.Now().Add(-time.Hour)
+	writeKeyFile(t, path, "first\n", start)
+
+	logger := &testLogger{}
+	m := NewManager("", WithLogger(logger), WithKeyFilePollPeriod(time.Millisecond))
+	stop, err := m.WatchKeyFile(path)
+	if err != nil {
+		t.Fatalf("WatchKeyFile expected nil error, actual: %v", err)
+	}
+	defer stop()
+	if m.Secret() != "first" {
+		t.Fatalf("WatchKeyFile expected initial secret 'first', actual: '%v'", m.Secret())
+	}
+	oldCookie := m.New("alice", time.Now().Add(time.Minute))
+
+	writeKeyFile(t, path, "  \n", start.Add(time.Second))
+	waitFor(t, "empty key file warning", func() bool { return logger.numWarnings() > 0 })
+	if m.Secret() != "first" {
+		t.Errorf("WatchKeyFile empty file expected previous secret 'first', actual: '%v'", m.Secret())
+	}
+
+	writeKeyFile(t, path, "second", start.Add(2*time.Second))
+	waitFor(t, "secret reload", func() bool { return m.Secret() == "second" })
+	if _, err := m.Parse(oldCookie); err == nil {
+		t.Errorf("Parse cookie signed with previous secret expected error, actual nil")
+	}
+	if _, err := m.Parse(m.New("alice", time.Now().Add(time.Minute))); err != nil {
+		t.Errorf("Parse cookie signed with reloaded secret expected nil error, actual: %v", err)
+	}
+}
+
+func TestWatchKeyFileInitialError(t *testing.T) {
+	if _, err := NewManager("").WatchKeyFile(filepath.Join(os.TempDir(), "tocookie-does-not-exist")); err == nil {
+		t.Errorf("WatchKeyFile missing file expected error, actual nil")
+	}
+}
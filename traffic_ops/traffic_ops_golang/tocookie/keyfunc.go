@@ -0,0 +1,48 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ParseWithKeyFunc parses a cookie with a secret chosen from its claims, such as its issuer, in the manner of the key functions of JWT libraries. The cookie is decoded without verification, keyFunc is called with the unverified claims to obtain the secret, and the cookie is then parsed with Parse and that secret.
+//
+// The claims given to keyFunc have NOT been verified, and may have been forged by anyone; they must only be used to select the secret, never to make any other decision. Only the claims returned by ParseWithKeyFunc, with a nil error, are verified. Errors returned by keyFunc are wrapped and returned, and an empty secret is an error.
+func ParseWithKeyFunc(cookie string, keyFunc func(*Cookie) (string, error), opts ...Option) (*Cookie, error) {
+	o := newOptions(opts)
+	start := o.startTimer()
+	secret, err := selectKey(cookie, keyFunc, o)
+	if err != nil {
+		o.observe(start, nil, err)
+		return nil, err
+	}
+	return Parse(secret, cookie, opts...)
+}
+
+// selectKey returns the secret keyFunc chooses for the unverified claims of the cookie.
+func selectKey(cookie string, keyFunc func(*Cookie) (string, error), o *options) (string, error) {
+	unverified, err := decodeUnverified(cookie, o)
+	if err != nil {
+		return "", err
+	}
+	secret, err := keyFunc(unverified)
+	if err != nil {
+		return "", fmt.Errorf("selecting key: %w", err)
+	}
+	if secret == "" {
+		return "", errors.New("selecting key: empty secret")
+	}
+	return secret, nil
+}
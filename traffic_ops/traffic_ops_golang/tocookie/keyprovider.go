@@ -0,0 +1,60 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// KeyProvider fetches the signing secret from a secret store, such as the Vault KV engine of tocookie/vaultkeys or the AWS KMS of tocookie/kmskeys, so it needn't be kept in cdn.conf in plaintext. Implementations must be safe for concurrent use.
+type KeyProvider interface {
+	// Secret returns the current signing secret.
+	Secret(ctx context.Context) (string, error)
+}
+
+// KeyProviderFunc is a function which is a KeyProvider.
+type KeyProviderFunc func(ctx context.Context) (string, error)
+
+// Secret returns f(ctx).
+func (f KeyProviderFunc) Secret(ctx context.Context) (string, error) {
+	return f(ctx)
+}
+
+// LoadSecret returns the secret of the provider, as New, Parse, and NewKeySetFromSecrets take it. Empty secrets are an error, because they would sign cookies anyone can forge.
+func LoadSecret(ctx context.Context, provider KeyProvider) (string, error) {
+	secret, err := provider.Secret(ctx)
+	if err != nil {
+		return "", fmt.Errorf("loading secret: %w", err)
+	}
+	if secret == "" {
+		return "", errors.New("loading secret: provider returned an empty secret")
+	}
+	return secret, nil
+}
+
+// MACer computes and verifies cookie MACs without revealing its key, such as the Vault transit engine of tocookie/vaultkeys or the AWS KMS of tocookie/kmskeys, so the key never leaves the secret store. Implementations must be safe for concurrent use.
+type MACer interface {
+	// MAC returns the MAC of the message.
+	MAC(message []byte) ([]byte, error)
+	// VerifyMAC returns whether mac is a MAC of the message. It returns an error only if the MAC couldn't be checked, not if it is wrong.
+	VerifyMAC(message, mac []byte) (bool, error)
+}
+
+// WithMACer makes New sign cookies, and Parse verify them, with the MACer, rather than with an HMAC of the secret, which is ignored and may be empty. The MACer is given the signed part of the cookie, preceded by any associated data as WithAssociatedData signs it; WithHash and WithTagLength don't apply, the MACer chooses its own algorithm.
+//
+// The key isn't known locally, so it can't be combined with WithEncryption or WithPerUserKeys, which derive keys from it; New returns an empty string if it is. Errors of the MACer fail closed: New returns an empty string, and Parse rejects the cookie with an error which isn't ErrBadSignature, so an unavailable secret store isn't mistaken for forged cookies. Each signature and verification is a round trip to the store, so it suits deployments where the latency is small next to the request it authenticates.
+func WithMACer(macer MACer) Option {
+	return func(o *options) { o.macer = macer }
+}
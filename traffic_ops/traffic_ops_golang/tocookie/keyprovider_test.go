@@ -0,0 +1,115 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeMACer is a MACer with a key the tests can't see, like a remote one.
+type fakeMACer struct {
+	key   []byte
+	err   error
+	calls int32
+}
+
+func (m *fakeMACer) MAC(message []byte) ([]byte, error) {
+	atomic.AddInt32(&m.calls, 1)
+	if m.err != nil {
+		return nil, m.err
+	}
+	mac := hmac.New(sha256.New, m.key)
+	mac.Write(message)
+	return mac.Sum(nil), nil
+}
+
+func (m *fakeMACer) VerifyMAC(message, messageMAC []byte) (bool, error) {
+	expected, err := m.MAC(message)
+	if err != nil {
+		return false, err
+	}
+	return hmac.Equal(expected, messageMAC), nil
+}
+
+func TestWithMACer(t *testing.T) {
+	macer := &fakeMACer{key: []byte("remote key")}
+	expiration := time.Now().Add(time.Minute)
+	for _, version := range []int{Version0, Version1, Version2} {
+		cookie := New("alice", expiration, "", WithMACer(macer), WithVersion(version))
+		if cookie == "" {
+			t.Fatalf("v%v: New with MACer expected cookie, actual: empty", version)
+		}
+		c, err := Parse("", cookie, WithMACer(macer))
+		if err != nil || c.AuthData != "alice" {
+			t.Errorf("v%v: Parse with MACer expected alice, actual: %+v %v", version, c, err)
+		}
+		if _, err := Parse("", cookie); !errors.Is(err, ErrBadSignature) {
+			t.Errorf("v%v: Parse without MACer expected ErrBadSignature, actual: %v", version, err)
+		}
+		if _, err := Parse("", cookie, WithMACer(&fakeMACer{key: []byte("other key")})); !errors.Is(err, ErrBadSignature) {
+			t.Errorf("v%v: Parse with other MACer expected ErrBadSignature, actual: %v", version, err)
+		}
+		if _, err := NewParser("", WithMACer(macer)).Parse(cookie); err != nil {
+			t.Errorf("v%v: Parser with MACer expected nil error, actual: %v", version, err)
+		}
+	}
+
+	if _, err := Parse("secret", New("alice", expiration, "secret"), WithMACer(macer)); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("Parse with MACer of HMAC cookie expected ErrBadSignature, actual: %v", err)
+	}
+
+	aad := []byte("cdn1.example.net")
+	cookie := New("alice", expiration, "", WithMACer(macer), WithAssociatedData(aad))
+	if _, err := Parse("", cookie, WithMACer(macer), WithAssociatedData(aad)); err != nil {
+		t.Errorf("Parse with MACer and same associated data expected nil error, actual: %v", err)
+	}
+	if _, err := Parse("", cookie, WithMACer(macer)); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("Parse with MACer and no associated data expected ErrBadSignature, actual: %v", err)
+	}
+
+	for name, opt := range map[string]Option{"encryption": WithEncryption(), "per-user keys": WithPerUserKeys()} {
+		if cookie := New("alice", expiration, "", WithMACer(macer), opt, WithVersion(Version2)); cookie != "" {
+			t.Errorf("New with MACer and %v expected empty string, actual: '%v'", name, cookie)
+		}
+	}
+
+	errStore := errors.New("store unavailable")
+	if cookie := New("alice", expiration, "", WithMACer(&fakeMACer{err: errStore})); cookie != "" {
+		t.Errorf("New with failing MACer expected empty string, actual: '%v'", cookie)
+	}
+	cookie = New("alice", expiration, "", WithMACer(macer))
+	_, err := Parse("", cookie, WithMACer(&fakeMACer{err: errStore}))
+	if !errors.Is(err, errStore) || errors.Is(err, ErrBadSignature) {
+		t.Errorf("Parse with failing MACer expected store error, actual: %v", err)
+	}
+}
+
+func TestLoadSecret(t *testing.T) {
+	secret, err := LoadSecret(context.Background(), KeyProviderFunc(func(context.Context) (string, error) { return "secret", nil }))
+	if err != nil || secret != "secret" {
+		t.Errorf("LoadSecret expected secret, actual: '%v' %v", secret, err)
+	}
+	if _, err := LoadSecret(context.Background(), KeyProviderFunc(func(context.Context) (string, error) { return "", nil })); err == nil {
+		t.Errorf("LoadSecret of empty secret expected error, actual: nil")
+	}
+	errStore := errors.New("store unavailable")
+	if _, err := LoadSecret(context.Background(), KeyProviderFunc(func(context.Context) (string, error) { return "", errStore })); !errors.Is(err, errStore) {
+		t.Errorf("LoadSecret expected provider error, actual: %v", err)
+	}
+}
@@ -0,0 +1,238 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// KeyRing holds the versioned keys cookies are signed with, identified by key IDs, so keys may be rotated without invalidating the cookies signed with their predecessors. Implementations must be safe for concurrent use.
+type KeyRing interface {
+	// Current returns the ID and key new cookies are signed with.
+	Current() (id string, key []byte)
+	// Get returns the key with the given ID, and whether there is one.
+	Get(id string) ([]byte, bool)
+}
+
+// KeyLister is implemented by KeyRings which can list their key IDs, in the order ParseWithKeyRing should try them for cookies without a key ID. Without it, such cookies are only verified with the current key.
+type KeyLister interface {
+	IDs() []string
+}
+
+// WithKeyID makes New embed the ID of the key it is given in the cookie, so the key can be looked up by ParseWithKeyRing. The ID is stored in the version 2 header, so cookies with key IDs are always version 2, and it is covered by the signature. Cookies minted with NewWithKeyRing have the ID of their key already; this is for re-issuing them with Refresh.
+func WithKeyID(id string) Option {
+	return func(o *options) { o.keyID = id }
+}
+
+// NewWithKeyRing mints a cookie like New, signed with the current key of the ring, and embeds the key's ID.
+func NewWithKeyRing(user string, expiration time.Time, ring KeyRing, opts ...Option) string {
+	id, key := ring.Current()
+	return New(user, expiration, string(key), append(append(make([]Option, 0, len(opts)+1), opts...), WithKeyID(id))...)
+}
+
+// ParseWithKeyRing parses a cookie like Parse, verified with the key of the ring with the cookie's key ID. If the cookie has no key ID, e.g. because it was minted before key IDs were introduced, each key is tried in turn: the current key, followed by the others if the ring is a KeyLister. If the ring has no key with the cookie's ID, ErrUnknownKey is returned.
+//
+// If the ring is a KeyValidity, keys are only used while their windows include both the time of verification and, if the cookie has IssuedAt, its issuance. Other keys aren't tried for cookies without key IDs. The issuance of an encrypted cookie is only checked once it is verified, as it can't be read before. Cookies whose key ID names a key outside its window are rejected with ErrKeyNotValid, and a warning is logged; see WithLogger.
+//
+// The key ID is read before the signature is verified, but the cookie is then verified with that key alone.
+func ParseWithKeyRing(ring KeyRing, cookie string, opts ...Option) (*Cookie, error) {
+	o := newOptions(opts)
+	return o.parseWith(context.Background(), func() (*Cookie, error) { return parseWithKeyRing(ring, cookie, o) })
+}
+
+func parseWithKeyRing(ring KeyRing, cookie string, o *options) (*Cookie, error) {
+	s, err := splitCookie(cookie, o)
+	if err != nil {
+		return nil, err
+	}
+	validity, hasValidity := ring.(KeyValidity)
+	issuedAt := int64(0)
+	if hasValidity && s.header.Enc == "" {
+		unverified, err := decodeUnverified(cookie, o)
+		if err != nil {
+			return nil, err
+		}
+		issuedAt = unverified.IssuedAt
+	}
+	now := o.now()
+	inWindow := func(id string) bool {
+		return !hasValidity || keyInWindow(validity, id, issuedAt, now)
+	}
+	// the issuance of an encrypted cookie can't be read until it is verified, so it is checked against the window of the key afterwards
+	checkIssuance := func(id string, c *Cookie, err error) (*Cookie, error) {
+		if c == nil || !hasValidity || s.header.Enc == "" || keyInWindow(validity, id, c.IssuedAt, now) {
+			return c, err
+		}
+		o.logger.Warnf("tocookie: rejecting cookie issued outside the validity window of key '%s'", id)
+		return nil, ErrKeyNotValid
+	}
+
+	if s.header.Kid != "" {
+		key, ok := ring.Get(s.header.Kid)
+		if !ok {
+			return nil, ErrUnknownKey
+		}
+		if !inWindow(s.header.Kid) {
+			o.logger.Warnf("tocookie: rejecting cookie signed with key '%s' outside its validity window", s.header.Kid)
+			return nil, ErrKeyNotValid
+		}
+		c, err := parse(string(key), cookie, o)
+		return checkIssuance(s.header.Kid, c, err)
+	}
+
+	currentID, _ := ring.Current()
+	ids := []string{currentID}
+	if lister, ok := ring.(KeyLister); ok {
+		ids = append(ids, lister.IDs()...)
+	}
+	err = ErrBadSignature
+	tried := map[string]bool{}
+	for _, id := range ids {
+		if tried[id] || !inWindow(id) {
+			continue
+		}
+		tried[id] = true
+		key, ok := ring.Get(id)
+		if !ok {
+			continue
+		}
+		c := (*Cookie)(nil)
+		if c, err = parse(string(key), cookie, o); !errors.Is(err, ErrBadSignature) {
+			return checkIssuance(id, c, err)
+		}
+	}
+	return nil, err
+}
+
+// KeyValidity is implemented by KeyRings whose keys are only valid within time windows, so old keys are retired automatically after a grace period. ParseWithKeyRing ignores keys outside their windows.
+type KeyValidity interface {
+	// Validity returns the window of the key with the given ID. A zero time leaves that end of the window open.
+	Validity(id string) (notBefore, notAfter time.Time)
+}
+
+// keyInWindow returns whether the key may verify a cookie issued at issuedAt, which is zero if unknown, at now. Both times must be within its window.
+func keyInWindow(validity KeyValidity, id string, issuedAt int64, now time.Time) bool {
+	notBefore, notAfter := validity.Validity(id)
+	if !notBefore.IsZero() && (now.Before(notBefore) || issuedAt != 0 && issuedAt < notBefore.Unix()) {
+		return false
+	}
+	return notAfter.IsZero() || !now.After(notAfter)
+}
+
+// MemoryKeyRing is a KeyRing, KeyLister, and KeyValidity held in memory. It is safe for concurrent use, including rotation while cookies are being minted and parsed.
+type MemoryKeyRing struct {
+	mu      sync.RWMutex
+	current string
+	keys    map[string][]byte
+	// ids are the key IDs, newest first.
+	ids []string
+	// windows are the validity windows of the keys which have them.
+	windows map[string][2]time.Time
+}
+
+// NewMemoryKeyRing returns a MemoryKeyRing whose current key is the given one.
+func NewMemoryKeyRing(id string, key []byte) *MemoryKeyRing {
+	r := &MemoryKeyRing{keys: map[string][]byte{}, windows: map[string][2]time.Time{}}
+	r.Rotate(id, key)
+	return r
+}
+
+// Current returns the ID and key new cookies are signed with. The key must not be modified.
+func (r *MemoryKeyRing) Current() (string, []byte) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.current, r.keys[r.current]
+}
+
+// Get returns the key with the given ID, and whether there is one. The key must not be modified.
+func (r *MemoryKeyRing) Get(id string) ([]byte, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	key, ok := r.keys[id]
+	return key, ok
+}
+
+// IDs returns the IDs of the keys, newest first.
+func (r *MemoryKeyRing) IDs() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]string(nil), r.ids...)
+}
+
+// Validity returns the window of the key with the given ID, set by SetValidity. Zero times leave the window open.
+func (r *MemoryKeyRing) Validity(id string) (time.Time, time.Time) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	window := r.windows[id]
+	return window[0], window[1]
+}
+
+// SetValidity sets the window in which the key with the given ID is valid for verification, and returns whether there is such a key. A zero time leaves that end of the window open. Setting notAfter to the end of a grace period after rotation retires a key without having to remember to remove it.
+func (r *MemoryKeyRing) SetValidity(id string, notBefore, notAfter time.Time) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.keys[id]; !ok {
+		return false
+	}
+	r.windows[id] = [2]time.Time{notBefore, notAfter}
+	return true
+}
+
+// Add adds a key which cookies are verified with, but not signed with, such as a key which will be rotated to once every server has it. A key with the same ID is replaced.
+func (r *MemoryKeyRing) Add(id string, key []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.add(id, key)
+}
+
+// Rotate adds a key, replacing any key with the same ID, and makes it the current key. The previous keys are kept, so the cookies signed with them still parse until they are removed.
+func (r *MemoryKeyRing) Rotate(id string, key []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.add(id, key)
+	r.current = id
+}
+
+// Remove removes the key with the given ID, so cookies signed with it no longer parse, and returns whether it was removed. The current key can't be removed.
+func (r *MemoryKeyRing) Remove(id string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.keys[id]; !ok || id == r.current {
+		return false
+	}
+	delete(r.keys, id)
+	delete(r.windows, id)
+	r.ids = removeID(r.ids, id)
+	return true
+}
+
+// add adds a copy of the key as the newest, with an open validity window. The caller must hold the write lock.
+func (r *MemoryKeyRing) add(id string, key []byte) {
+	r.keys[id] = append([]byte(nil), key...)
+	delete(r.windows, id)
+	r.ids = append([]string{id}, removeID(r.ids, id)...)
+}
+
+// removeID returns the IDs without id, in a newly allocated slice.
+func removeID(ids []string, id string) []string {
+	without := make([]string, 0, len(ids))
+	for _, other := range ids {
+		if other != id {
+			without = append(without, other)
+		}
+	}
+	return without
+}
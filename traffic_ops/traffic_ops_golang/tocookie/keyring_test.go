@@ -0,0 +1,191 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// currentOnlyRing is a KeyRing which isn't a KeyLister.
+type currentOnlyRing struct{ ring *MemoryKeyRing }
+
+func (r currentOnlyRing) Current() (string, []byte)    { return r.ring.Current() }
+func (r currentOnlyRing) Get(id string) ([]byte, bool) { return r.ring.Get(id) }
+
+func TestKeyRing(t *testing.T) {
+	expiration := time.Now().Add(time.Minute)
+	ring := NewMemoryKeyRing("2023", []byte("old secret"))
+	old := NewWithKeyRing("alice", expiration, ring)
+	legacy := New("alice", expiration, "old secret")
+
+	ring.Rotate("2024", []byte("new secret"))
+	current := NewWithKeyRing("alice", expiration, ring)
+
+	if info, err := Dump(current); err != nil || info.KeyID != "2024" || info.Version != Version2 {
+		t.Errorf("NewWithKeyRing expected version 2 cookie with key ID 2024, actual: %+v %v", info, err)
+	}
+	if id, _ := ring.Current(); id != "2024" {
+		t.Errorf("Rotate expected current key 2024, actual: %v", id)
+	}
+	for name, cookie := range map[string]string{"current": current, "old": old, "legacy": legacy} {
+		if c, err := ParseWithKeyRing(ring, cookie); err != nil || c.AuthData != "alice" {
+			t.Errorf("%v: ParseWithKeyRing expected alice and nil error, actual: %+v %v", name, c, err)
+		}
+	}
+	if _, err := ParseWithKeyRing(currentOnlyRing{ring}, legacy); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("ParseWithKeyRing of legacy cookie with a ring which isn't a KeyLister expected ErrBadSignature, actual: %v", err)
+	}
+	if _, err := ParseWithKeyRing(ring, New("alice", expiration, "other secret")); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("ParseWithKeyRing of legacy cookie with no matching key expected ErrBadSignature, actual: %v", err)
+	}
+	if _, err := ParseWithKeyRing(ring, New("alice", expiration, "old secret", WithKeyID("2024"))); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("ParseWithKeyRing of cookie with the wrong key ID expected ErrBadSignature, actual: %v", err)
+	}
+	if _, err := ParseWithKeyRing(ring, New("alice", expiration, "old secret", WithKeyID("2022"))); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("ParseWithKeyRing of cookie with an unknown key ID expected ErrUnknownKey, actual: %v", err)
+	}
+	if _, err := ParseWithKeyRing(ring, NewWithKeyRing("alice", time.Now().Add(-time.Minute), ring)); !errors.Is(err, ErrExpired) {
+		t.Errorf("ParseWithKeyRing of expired cookie expected ErrExpired, actual: %v", err)
+	}
+
+	if ring.Remove("2024") {
+		t.Errorf("Remove of the current key expected false, actual true")
+	}
+	if !ring.Remove("2023") {
+		t.Errorf("Remove of an old key expected true, actual false")
+	}
+	for name, cookie := range map[string]string{"old": old, "legacy": legacy} {
+		if _, err := ParseWithKeyRing(ring, cookie); err == nil {
+			t.Errorf("%v: ParseWithKeyRing after removing the key expected error, actual nil", name)
+		}
+	}
+	if ids := ring.IDs(); fmt.Sprint(ids) != "[2024]" {
+		t.Errorf("IDs expected [2024], actual: %v", ids)
+	}
+}
+
+func TestKeyRingObserver(t *testing.T) {
+	ring := NewMemoryKeyRing("1", []byte("one"))
+	ring.Add("2", []byte("two"))
+	ring.Add("3", []byte("three"))
+
+	observed := []Outcome{}
+	observer := WithObserver(func(outcome Outcome, err error) { observed = append(observed, outcome) })
+	ParseWithKeyRing(ring, New("alice", time.Now().Add(time.Minute), "three"), observer)
+	ParseWithKeyRing(ring, New("alice", time.Now().Add(time.Minute), "four"), observer)
+
+	if expected := []Outcome{OutcomeValid, OutcomeInvalid}; fmt.Sprint(observed) != fmt.Sprint(expected) {
+		t.Errorf("ParseWithKeyRing expected one observation per cookie %v, actual: %v", expected, observed)
+	}
+}
+
+func TestMemoryKeyRingConcurrentRotation(t *testing.T) {
+	ring := NewMemoryKeyRing("0", []byte("secret 0"))
+	wg := sync.WaitGroup{}
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if _, err := ParseWithKeyRing(ring, NewWithKeyRing("alice", time.Now().Add(time.Minute), ring)); err != nil {
+					t.Errorf("ParseWithKeyRing during rotation expected nil error, actual: %v", err)
+				}
+			}
+		}()
+	}
+	for i := 1; i <= 100; i++ {
+		ring.Rotate(fmt.Sprint(i), []byte(fmt.Sprint("secret ", i)))
+	}
+	wg.Wait()
+}
+
+// warnings is a Logger which records warnings.
+type warnings []string
+
+func (w *warnings) Infof(format string, v ...interface{}) {}
+func (w *warnings) Warnf(format string, v ...interface{}) { *w = append(*w, fmt.Sprintf(format, v...)) }
+
+func TestKeyRingValidity(t *testing.T) {
+	now := time.Now()
+	expiration := now.Add(time.Minute)
+	ring := NewMemoryKeyRing("old", []byte("old secret"))
+	old := NewWithKeyRing("alice", expiration, ring)
+	legacy := New("alice", expiration, "old secret")
+	ring.Rotate("new", []byte("new secret"))
+
+	if !ring.SetValidity("old", time.Time{}, now.Add(time.Hour)) {
+		t.Fatalf("SetValidity expected true, actual false")
+	}
+	for name, cookie := range map[string]string{"old": old, "legacy": legacy} {
+		if _, err := ParseWithKeyRing(ring, cookie); err != nil {
+			t.Errorf("%v: ParseWithKeyRing within grace period expected nil error, actual: %v", name, err)
+		}
+	}
+
+	ring.SetValidity("old", time.Time{}, now.Add(-time.Second))
+	logged := warnings{}
+	if _, err := ParseWithKeyRing(ring, old, WithLogger(&logged)); !errors.Is(err, ErrKeyNotValid) {
+		t.Errorf("ParseWithKeyRing with retired key expected ErrKeyNotValid, actual: %v", err)
+	}
+	if len(logged) != 1 {
+		t.Errorf("ParseWithKeyRing with retired key expected one warning, actual: %v", logged)
+	}
+	if _, err := ParseWithKeyRing(ring, legacy); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("ParseWithKeyRing of legacy cookie with retired key expected ErrBadSignature, actual: %v", err)
+	}
+	if _, err := ParseWithKeyRing(ring, old, WithObserver(func(outcome Outcome, err error) {
+		if outcome != OutcomeRejected {
+			t.Errorf("ParseWithKeyRing with retired key expected OutcomeRejected, actual: %v", outcome)
+		}
+	})); err == nil {
+		t.Errorf("ParseWithKeyRing with retired key expected error, actual nil")
+	}
+
+	ring.Add("next", []byte("next secret"))
+	ring.SetValidity("next", now.Add(time.Hour), time.Time{})
+	if _, err := ParseWithKeyRing(ring, New("alice", expiration, "next secret", WithKeyID("next"))); !errors.Is(err, ErrKeyNotValid) {
+		t.Errorf("ParseWithKeyRing with key not yet valid expected ErrKeyNotValid, actual: %v", err)
+	}
+
+	ring.SetValidity("next", now.Add(-time.Minute), time.Time{})
+	c := &Cookie{AuthData: "alice", ExpiresUnix: expiration.Unix(), IssuedAt: now.Add(-time.Hour).Unix()}
+	if _, err := ParseWithKeyRing(ring, encodeCookie(c, "next secret", newOptions([]Option{WithKeyID("next")}))); !errors.Is(err, ErrKeyNotValid) {
+		t.Errorf("ParseWithKeyRing of cookie issued before its key was valid expected ErrKeyNotValid, actual: %v", err)
+	}
+	if _, err := ParseWithKeyRing(ring, encodeCookie(c, "next secret", newOptions([]Option{WithKeyID("next"), WithEncryption()}))); !errors.Is(err, ErrKeyNotValid) {
+		t.Errorf("ParseWithKeyRing of encrypted cookie issued before its key was valid expected ErrKeyNotValid, actual: %v", err)
+	}
+	if _, err := ParseWithKeyRing(ring, New("alice", expiration, "next secret", WithKeyID("next"))); err != nil {
+		t.Errorf("ParseWithKeyRing with key in its window expected nil error, actual: %v", err)
+	}
+
+	if ring.SetValidity("missing", time.Time{}, time.Time{}) {
+		t.Errorf("SetValidity of missing key expected false, actual true")
+	}
+}
+
+func TestKeyRingHooks(t *testing.T) {
+	ring := NewMemoryKeyRing("1", []byte("one"))
+	errDenied := errors.New("denied")
+	deny := WithHooks(func(ctx context.Context, c *Cookie) error { return errDenied })
+	for name, cookie := range map[string]string{"key ID": NewWithKeyRing("alice", time.Now().Add(time.Minute), ring), "no key ID": New("alice", time.Now().Add(time.Minute), "one")} {
+		if _, err := ParseWithKeyRing(ring, cookie, deny); !errors.Is(err, ErrHookRejected) || !errors.Is(err, errDenied) {
+			t.Errorf("%v: ParseWithKeyRing with a rejecting hook expected ErrHookRejected, actual: %v", name, err)
+		}
+	}
+}
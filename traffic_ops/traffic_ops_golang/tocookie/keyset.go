@@ -0,0 +1,77 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// Key is a secret, and the ID embedded in cookies signed with it.
+type Key struct {
+	ID     string
+	Secret string
+}
+
+// KeySet is a current key, which cookies are signed with, and the previous keys, which cookies are still verified with, for rotating secrets without invalidating outstanding sessions. It is a KeyRing and KeyLister, for NewWithKeyRing and ParseWithKeyRing, which verify cookies with the key their ID names, and cookies without IDs with each key in order, current first. A KeySet is immutable, and so safe for concurrent use; rotate keys by replacing it, or use a MemoryKeyRing.
+type KeySet struct {
+	// keys are the current key, followed by the previous keys, newest first.
+	keys []Key
+}
+
+// NewKeySet returns a KeySet with the current key, and the previous keys, newest first.
+func NewKeySet(current Key, previous ...Key) KeySet {
+	return KeySet{keys: append([]Key{current}, previous...)}
+}
+
+// NewKeySetFromSecrets returns a KeySet of the secrets, the first of which is current, with IDs from SecretKeyID. This is how Mojolicious treats its list of secrets, so it suits the secrets of cdn.conf.
+func NewKeySetFromSecrets(secrets ...string) KeySet {
+	keys := make([]Key, 0, len(secrets))
+	for _, secret := range secrets {
+		keys = append(keys, Key{ID: SecretKeyID(secret), Secret: secret})
+	}
+	return KeySet{keys: keys}
+}
+
+// SecretKeyID returns a stable ID for the secret, for key sets which are configured as bare secrets. It is a truncated SHA-256 digest, so it identifies the secret without revealing it.
+func SecretKeyID(secret string) string {
+	sum := sha256.Sum256([]byte("tocookie key id\x00" + secret))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Current returns the ID and secret of the current key. It is empty if the KeySet has no keys.
+func (s KeySet) Current() (string, []byte) {
+	if len(s.keys) == 0 {
+		return "", nil
+	}
+	return s.keys[0].ID, []byte(s.keys[0].Secret)
+}
+
+// Get returns the secret of the key with the given ID, and whether there is one.
+func (s KeySet) Get(id string) ([]byte, bool) {
+	for _, key := range s.keys {
+		if key.ID == id {
+			return []byte(key.Secret), true
+		}
+	}
+	return nil, false
+}
+
+// IDs returns the IDs of the keys, current first.
+func (s KeySet) IDs() []string {
+	ids := make([]string, 0, len(s.keys))
+	for _, key := range s.keys {
+		ids = append(ids, key.ID)
+	}
+	return ids
+}
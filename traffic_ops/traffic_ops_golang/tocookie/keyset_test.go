@@ -0,0 +1,64 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestKeySet(t *testing.T) {
+	expiration := time.Now().Add(time.Minute)
+	before := NewKeySet(Key{ID: "1", Secret: "one"})
+	old := NewWithKeyRing("alice", expiration, before)
+	legacy := New("alice", expiration, "one")
+
+	after := NewKeySet(Key{ID: "2", Secret: "two"}, Key{ID: "1", Secret: "one"})
+	current := NewWithKeyRing("alice", expiration, after)
+	if info, err := Dump(current); err != nil || info.KeyID != "2" {
+		t.Errorf("NewWithKeyRing expected key ID 2, actual: %+v %v", info, err)
+	}
+	for name, cookie := range map[string]string{"current": current, "previous": old, "legacy": legacy} {
+		if c, err := ParseWithKeyRing(after, cookie); err != nil || c.AuthData != "alice" {
+			t.Errorf("%v: ParseWithKeyRing expected alice and nil error, actual: %+v %v", name, c, err)
+		}
+	}
+
+	retired := NewKeySet(Key{ID: "2", Secret: "two"})
+	if _, err := ParseWithKeyRing(retired, old); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("ParseWithKeyRing with retired key expected ErrUnknownKey, actual: %v", err)
+	}
+	if _, err := ParseWithKeyRing(retired, legacy); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("ParseWithKeyRing of legacy cookie with retired key expected ErrBadSignature, actual: %v", err)
+	}
+	if id, key := (KeySet{}).Current(); id != "" || key != nil {
+		t.Errorf("Current of empty KeySet expected no key, actual: '%v' '%s'", id, key)
+	}
+}
+
+func TestNewKeySetFromSecrets(t *testing.T) {
+	keys := NewKeySetFromSecrets("new", "old")
+	if id, key := keys.Current(); id != SecretKeyID("new") || string(key) != "new" {
+		t.Errorf("NewKeySetFromSecrets expected current key 'new', actual: '%v' '%s'", id, key)
+	}
+	if key, ok := keys.Get(SecretKeyID("old")); !ok || string(key) != "old" {
+		t.Errorf("NewKeySetFromSecrets expected previous key 'old', actual: '%s' %v", key, ok)
+	}
+	if SecretKeyID("new") == SecretKeyID("old") || len(SecretKeyID("new")) != 16 {
+		t.Errorf("SecretKeyID expected distinct 16 character IDs, actual: '%v' '%v'", SecretKeyID("new"), SecretKeyID("old"))
+	}
+	if c, err := ParseWithKeyRing(keys, New("alice", time.Now().Add(time.Minute), "old")); err != nil || c.AuthData != "alice" {
+		t.Errorf("ParseWithKeyRing of cookie signed with previous secret expected alice and nil error, actual: %+v %v", c, err)
+	}
+}
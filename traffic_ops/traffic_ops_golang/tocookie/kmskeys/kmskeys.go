@@ -0,0 +1,305 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package kmskeys provides a tocookie.KeyProvider and tocookie.MACer backed by AWS KMS, so the cookie secret needn't be kept in cdn.conf. Decrypt decrypts a secret which was encrypted with a KMS key, so only the ciphertext is kept in configuration:
+//
+//	client := &kmskeys.Client{Region: "us-east-1", Credentials: kmskeys.EnvCredentials()}
+//	secret, err := tocookie.LoadSecret(ctx, &kmskeys.Decrypt{Client: client, CiphertextBlob: blob})
+//
+// MAC goes further, and has KMS compute and verify cookie MACs with an HMAC key, so the key never leaves KMS:
+//
+//	macer := &kmskeys.MAC{Client: client, KeyID: "alias/traffic-ops-cookie"}
+//	cookie := tocookie.New(user, expiration, "", tocookie.WithMACer(macer))
+//
+// It speaks the KMS JSON API, and signs requests with Signature Version 4, itself, so it has no external dependencies.
+package kmskeys
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultTimeout is how long a request may take, if Timeout isn't set.
+const DefaultTimeout = 5 * time.Second
+
+// MacAlgorithm is the algorithm of MAC, so its KMS key must be an HMAC_256 key.
+const MacAlgorithm = "HMAC_SHA_256"
+
+// Credentials authenticate requests to AWS.
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	// SessionToken is the token of temporary credentials, and empty for long-term ones.
+	SessionToken string
+}
+
+// EnvCredentials returns the credentials of the standard AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, and AWS_SESSION_TOKEN environment variables.
+func EnvCredentials() Credentials {
+	return Credentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+}
+
+// Client makes requests to KMS. It is safe for concurrent use.
+type Client struct {
+	// Region is the AWS region of the keys, e.g. "us-east-1".
+	Region string
+	// Endpoint is the URL of KMS. If empty, the public endpoint of the region is used.
+	Endpoint string
+	// Credentials sign the requests, and must be allowed the operations used: kms:Decrypt, or kms:GenerateMac and kms:VerifyMac.
+	Credentials Credentials
+	// HTTPClient makes the requests. If nil, http.DefaultClient is used.
+	HTTPClient *http.Client
+	// Timeout is how long a request may take. If 0, DefaultTimeout is used.
+	Timeout time.Duration
+}
+
+// Decrypt is a tocookie.KeyProvider which decrypts a secret encrypted with a KMS key, e.g. with `aws kms encrypt`.
+type Decrypt struct {
+	Client *Client
+	// CiphertextBlob is the encrypted secret.
+	CiphertextBlob []byte
+	// EncryptionContext is the encryption context the secret was encrypted with, if any.
+	EncryptionContext map[string]string
+}
+
+// Secret returns the decrypted secret.
+func (d *Decrypt) Secret(ctx context.Context) (string, error) {
+	req := struct {
+		CiphertextBlob    []byte            `json:"CiphertextBlob"`
+		EncryptionContext map[string]string `json:"EncryptionContext,omitempty"`
+	}{CiphertextBlob: d.CiphertextBlob, EncryptionContext: d.EncryptionContext}
+	resp := struct {
+		Plaintext []byte `json:"Plaintext"`
+	}{}
+	if err := d.Client.call(ctx, "Decrypt", req, &resp); err != nil {
+		return "", err
+	}
+	return string(resp.Plaintext), nil
+}
+
+// MAC is a tocookie.MACer which has KMS compute and verify HMAC-SHA256s with an HMAC_256 key, so the key never leaves KMS. KMS MACs messages of up to 4096 bytes, so cookies with large claims can't be signed.
+type MAC struct {
+	Client *Client
+	// KeyID is the ID, ARN, or alias of the key.
+	KeyID string
+}
+
+// MAC returns the HMAC-SHA256 of the message with the KMS key.
+func (m *MAC) MAC(message []byte) ([]byte, error) {
+	req := struct {
+		KeyID        string `json:"KeyId"`
+		Message      []byte `json:"Message"`
+		MacAlgorithm string `json:"MacAlgorithm"`
+	}{KeyID: m.KeyID, Message: message, MacAlgorithm: MacAlgorithm}
+	resp := struct {
+		Mac []byte `json:"Mac"`
+	}{}
+	if err := m.Client.call(context.Background(), "GenerateMac", req, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Mac, nil
+}
+
+// VerifyMAC returns whether mac is an HMAC-SHA256 of the message with the KMS key.
+func (m *MAC) VerifyMAC(message, mac []byte) (bool, error) {
+	req := struct {
+		KeyID        string `json:"KeyId"`
+		Message      []byte `json:"Message"`
+		Mac          []byte `json:"Mac"`
+		MacAlgorithm string `json:"MacAlgorithm"`
+	}{KeyID: m.KeyID, Message: message, Mac: mac, MacAlgorithm: MacAlgorithm}
+	resp := struct {
+		MacValid bool `json:"MacValid"`
+	}{}
+	err := m.Client.call(context.Background(), "VerifyMac", req, &resp)
+	if apiErr := (*Error)(nil); errors.As(err, &apiErr) && apiErr.Type == "KMSInvalidMacException" {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return resp.MacValid, nil
+}
+
+// Error is an error returned by KMS.
+type Error struct {
+	// Type is the type of the error, without its namespace, e.g. "NotFoundException".
+	Type    string
+	Message string
+	Status  int
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("kms returned %d %s: %s", e.Status, e.Type, e.Message)
+}
+
+// maxResponseSize is the largest KMS response read.
+const maxResponseSize = 1 << 20
+
+// call makes a request of a KMS operation, with the JSON of req as its body, and decodes the JSON response into resp.
+func (c *Client) call(ctx context.Context, operation string, req interface{}, resp interface{}) error {
+	timeout := c.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("encoding kms request: %w", err)
+	}
+	endpoint := c.Endpoint
+	if endpoint == "" {
+		endpoint = "https://kms." + c.Region + ".amazonaws.com/"
+	}
+	httpReq, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("creating kms request: %w", err)
+	}
+	httpReq = httpReq.WithContext(ctx)
+	httpReq.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	httpReq.Header.Set("X-Amz-Target", "TrentService."+operation)
+	sign(httpReq, body, c.Credentials, c.Region, "kms", time.Now())
+
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	httpResp, err := client.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("requesting kms %s: %w", operation, err)
+	}
+	defer httpResp.Body.Close()
+	b, err := ioutil.ReadAll(io.LimitReader(httpResp.Body, maxResponseSize))
+	if err != nil {
+		return fmt.Errorf("reading kms response: %w", err)
+	}
+	if httpResp.StatusCode != http.StatusOK {
+		apiErr := struct {
+			Type    string `json:"__type"`
+			Message string `json:"message"`
+		}{}
+		json.Unmarshal(b, &apiErr)
+		// types may be namespaced, e.g. "com.amazonaws.kms#NotFoundException"
+		return &Error{Type: apiErr.Type[strings.LastIndex(apiErr.Type, "#")+1:], Message: apiErr.Message, Status: httpResp.StatusCode}
+	}
+	if err := json.Unmarshal(b, resp); err != nil {
+		return fmt.Errorf("decoding kms response: %w", err)
+	}
+	return nil
+}
+
+// sigV4Algorithm is the algorithm of Signature Version 4.
+const sigV4Algorithm = "AWS4-HMAC-SHA256"
+
+// sign signs the request, with the given body, for the service in the region, with Signature Version 4 at the given time. Every header of the request, and its host, is signed, and any previous signature replaced.
+func sign(req *http.Request, body []byte, creds Credentials, region, service string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	req.Header.Del("Authorization")
+	req.Header.Set("X-Amz-Date", amzDate)
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		trimmed := make([]string, 0, len(values))
+		for _, v := range values {
+			trimmed = append(trimmed, strings.Join(strings.Fields(v), " "))
+		}
+		headers[strings.ToLower(name)] = strings.Join(trimmed, ",")
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	canonicalHeaders := strings.Builder{}
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	bodySum := sha256.Sum256(body)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		hex.EncodeToString(bodySum[:]),
+	}, "\n")
+
+	date := now.Format("20060102")
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	requestSum := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := sigV4Algorithm + "\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestSum[:])
+
+	key := []byte("AWS4" + creds.SecretAccessKey)
+	for _, part := range []string{date, region, service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", sigV4Algorithm+" Credential="+creds.AccessKeyID+"/"+scope+", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+// canonicalQuery returns the query of Signature Version 4: its parameters sorted by name and then value, and escaped.
+func canonicalQuery(query url.Values) string {
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	params := []string{}
+	for _, name := range names {
+		values := append([]string(nil), query[name]...)
+		sort.Strings(values)
+		for _, v := range values {
+			params = append(params, escape(name)+"="+escape(v))
+		}
+	}
+	return strings.Join(params, "&")
+}
+
+// escape escapes s as Signature Version 4 does, which is url.QueryEscape but with spaces as %20, and with '~' unescaped.
+func escape(s string) string {
+	return strings.Replace(strings.Replace(url.QueryEscape(s), "+", "%20", -1), "%7E", "~", -1)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,158 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kmskeys
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/tocookie"
+)
+
+func TestSign(t *testing.T) {
+	// the example of the AWS Signature Version 4 documentation
+	req, err := http.NewRequest(http.MethodGet, "https://iam.amazonaws.com/?Version=2010-05-08&Action=ListUsers", nil)
+	if err != nil {
+		t.Fatalf("creating request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	creds := Credentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+	sign(req, nil, creds, "us-east-1", "iam", time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+
+	expected := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/iam/aws4_request, SignedHeaders=content-type;host;x-amz-date, Signature=5d672d79c15b13162d9279b0855cfba6789a8edb4c82c400e06b5924a6f2b5d7"
+	if actual := req.Header.Get("Authorization"); actual != expected {
+		t.Errorf("sign expected Authorization '%v', actual: '%v'", expected, actual)
+	}
+	if actual := req.Header.Get("X-Amz-Date"); actual != "20150830T123600Z" {
+		t.Errorf("sign expected X-Amz-Date '20150830T123600Z', actual: '%v'", actual)
+	}
+
+	creds.SessionToken = "token"
+	sign(req, nil, creds, "us-east-1", "iam", time.Now())
+	if actual := req.Header.Get("Authorization"); !strings.Contains(actual, "SignedHeaders=content-type;host;x-amz-date;x-amz-security-token,") {
+		t.Errorf("sign with session token expected it signed, actual: '%v'", actual)
+	}
+}
+
+func TestCanonicalQuery(t *testing.T) {
+	for query, expected := range map[string]string{
+		"":                  "",
+		"b=2&a=1":           "a=1&b=2",
+		"a-b=1&a=2&a=1":     "a=1&a=2&a-b=1",
+		"k=a+b&t=~x&s=%2F/": "k=a%20b&s=%2F%2F&t=~x",
+	} {
+		req, _ := http.NewRequest(http.MethodGet, "https://example.net/?"+query, nil)
+		if actual := canonicalQuery(req.URL.Query()); actual != expected {
+			t.Errorf("canonicalQuery('%v') expected '%v', actual: '%v'", query, expected, actual)
+		}
+	}
+}
+
+// fakeKMS serves GenerateMac, VerifyMac, and Decrypt, with an HMAC key and a "ciphertext" which is the plaintext reversed.
+func fakeKMS(t *testing.T, key []byte) *httptest.Server {
+	fail := func(w http.ResponseWriter, status int, typ string) {
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]string{"__type": "com.amazonaws.kms#" + typ, "message": typ})
+	}
+	sum := func(msg []byte) []byte {
+		mac := hmac.New(sha256.New, key)
+		mac.Write(msg)
+		return mac.Sum(nil)
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKID/") || !strings.Contains(auth, "/us-east-1/kms/aws4_request") || !strings.Contains(auth, ";x-amz-target,") {
+			fail(w, http.StatusBadRequest, "IncompleteSignatureException")
+			return
+		}
+		req := struct {
+			KeyID          string `json:"KeyId"`
+			Message        []byte
+			Mac            []byte
+			MacAlgorithm   string
+			CiphertextBlob []byte
+		}{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("kms request expected JSON body, actual: %v", err)
+		}
+		if req.KeyID != "" && req.KeyID != "alias/cookie" {
+			fail(w, http.StatusBadRequest, "NotFoundException")
+			return
+		}
+		switch r.Header.Get("X-Amz-Target") {
+		case "TrentService.GenerateMac":
+			json.NewEncoder(w).Encode(map[string]interface{}{"Mac": sum(req.Message), "KeyId": req.KeyID, "MacAlgorithm": req.MacAlgorithm})
+		case "TrentService.VerifyMac":
+			if !hmac.Equal(sum(req.Message), req.Mac) {
+				fail(w, http.StatusBadRequest, "KMSInvalidMacException")
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"MacValid": true})
+		case "TrentService.Decrypt":
+			plaintext := make([]byte, len(req.CiphertextBlob))
+			for i, b := range req.CiphertextBlob {
+				plaintext[len(plaintext)-1-i] = b
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"Plaintext": plaintext})
+		default:
+			fail(w, http.StatusBadRequest, "UnknownOperationException")
+		}
+	}))
+}
+
+func TestDecrypt(t *testing.T) {
+	srv := fakeKMS(t, nil)
+	defer srv.Close()
+	client := &Client{Region: "us-east-1", Endpoint: srv.URL, Credentials: Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret"}}
+
+	secret, err := tocookie.LoadSecret(context.Background(), &Decrypt{Client: client, CiphertextBlob: []byte("terces smk")})
+	if err != nil || secret != "kms secret" {
+		t.Errorf("Decrypt expected 'kms secret', actual: '%v' %v", secret, err)
+	}
+}
+
+func TestMAC(t *testing.T) {
+	srv := fakeKMS(t, []byte("kms key"))
+	defer srv.Close()
+	client := &Client{Region: "us-east-1", Endpoint: srv.URL, Credentials: Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret"}}
+	macer := &MAC{Client: client, KeyID: "alias/cookie"}
+
+	cookie := tocookie.New("alice", time.Now().Add(time.Minute), "", tocookie.WithMACer(macer))
+	if cookie == "" {
+		t.Fatalf("New with MAC expected cookie, actual: empty")
+	}
+	if c, err := tocookie.Parse("", cookie, tocookie.WithMACer(macer)); err != nil || c.AuthData != "alice" {
+		t.Errorf("Parse with MAC expected alice, actual: %+v %v", c, err)
+	}
+	forged := cookie[:len(cookie)-1] + "0"
+	if strings.HasSuffix(cookie, "0") {
+		forged = cookie[:len(cookie)-1] + "1"
+	}
+	if _, err := tocookie.Parse("", forged, tocookie.WithMACer(macer)); !errors.Is(err, tocookie.ErrBadSignature) {
+		t.Errorf("Parse with MAC of tampered cookie expected ErrBadSignature, actual: %v", err)
+	}
+
+	_, err := tocookie.Parse("", cookie, tocookie.WithMACer(&MAC{Client: client, KeyID: "alias/missing"}))
+	apiErr := (*Error)(nil)
+	if !errors.As(err, &apiErr) || apiErr.Type != "NotFoundException" || errors.Is(err, tocookie.ErrBadSignature) {
+		t.Errorf("Parse with MAC of missing key expected NotFoundException, actual: %v", err)
+	}
+}
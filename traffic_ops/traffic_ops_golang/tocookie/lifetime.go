@@ -0,0 +1,169 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// DefaultRefreshWindow is the default window before a cookie's expiration in which middleware refreshes it with RefreshIfNeeded. Cookies with more time left aren't refreshed, so responses don't carry a new cookie on every request.
+const DefaultRefreshWindow = DefaultDuration / 2
+
+// WithMaxLifetime caps the total age of a session, regardless of how often it is refreshed. Given to Parse or Validate, cookies issued longer ago than the lifetime are rejected with ErrSessionTooOld. Given to Refresh, the expiration is not extended past the end of the lifetime, and RefreshIfNeeded refuses to refresh sessions which are too old.
+//
+// The age is measured from SessionStart, as by SessionAge. Cookies with neither SessionStart nor IssuedAt aren't checked; Refresh gives them a SessionStart.
+func WithMaxLifetime(lifetime time.Duration) Option {
+	return func(o *options) { o.maxLifetime = lifetime }
+}
+
+// WithMaxAuthAge caps how long ago the user may have last authenticated with their credentials, e.g. 24 hours, so they must log in again however they kept their session alive, whether by refreshing it, as WithMaxLifetime caps, or by starting new sessions without credentials, such as with a remember-me cookie. Given to Parse or Validate, cookies of older authentications are rejected with ErrReauthRequired. Given to Refresh, the expiration is not extended past the end of the age, and RefreshIfNeeded refuses to refresh cookies which are too old.
+//
+// The age is measured from AuthTime, or from SessionStart for sessions which began with the authentication, as by AuthAge.
+func WithMaxAuthAge(age time.Duration) Option {
+	return func(o *options) { o.maxAuthAge = age }
+}
+
+// WithAuthTime sets the time the user last authenticated with their credentials, for New to mint a session started without them, such as one restored by a remember-me cookie, which WithMaxAuthAge measures from. By default, it is when the session starts.
+func WithAuthTime(authTime time.Time) Option {
+	return func(o *options) { o.authTime = authTime }
+}
+
+// reauthRequired returns whether the cookie's authentication is older than the maximum age, if there is one.
+func (o *options) reauthRequired(c *Cookie, now time.Time) bool {
+	return o.maxAuthAge > 0 && c.authTime() != 0 && c.AuthAge(now) > o.maxAuthAge
+}
+
+// sessionTooOld returns whether the cookie's session has exceeded the maximum lifetime, if there is one.
+func (o *options) sessionTooOld(c *Cookie, now time.Time) bool {
+	return o.maxLifetime > 0 && c.sessionStart() != 0 && c.SessionAge(now) > o.maxLifetime
+}
+
+// capLifetime returns the expiration, moved back to the end of the cookie's maximum lifetime, or of its maximum authentication age, if it is later.
+func (o *options) capLifetime(c *Cookie, expiration time.Time) time.Time {
+	if o.maxLifetime > 0 && c.sessionStart() != 0 {
+		if end := time.Unix(c.sessionStart(), 0).Add(o.maxLifetime); end.Before(expiration) {
+			expiration = end
+		}
+	}
+	if o.maxAuthAge > 0 && c.authTime() != 0 {
+		if end := time.Unix(c.authTime(), 0).Add(o.maxAuthAge); end.Before(expiration) {
+			expiration = end
+		}
+	}
+	return expiration
+}
+
+// RefreshIfNeeded returns the refreshed cookie if it expires within the given duration, and the empty string if it doesn't need refreshing yet. Sessions which have exceeded the lifetime given by WithMaxLifetime are not refreshed, and ErrSessionTooOld is returned, nor are those of authentications older than WithMaxAuthAge, for which ErrReauthRequired is returned.
+func RefreshIfNeeded(c *Cookie, key string, within time.Duration, opts ...Option) (string, error) {
+	return RefreshIfNeededContext(context.Background(), c, key, within, opts...)
+}
+
+// RefreshIfNeededContext is RefreshIfNeeded, refreshing the cookie with RefreshContext.
+func RefreshIfNeededContext(ctx context.Context, c *Cookie, key string, within time.Duration, opts ...Option) (string, error) {
+	o := newOptions(opts)
+	now := o.now()
+	if o.sessionTooOld(c, now) {
+		return "", ErrSessionTooOld
+	}
+	if o.reauthRequired(c, now) {
+		return "", ErrReauthRequired
+	}
+	if !c.IsExpiringSoon(within, now) {
+		return "", nil
+	}
+	return RefreshContext(ctx, c, key, opts...), nil
+}
+
+// WithIdleTimeout sets how long Refresh extends the expiration of a cookie, so a session expires once it has gone that long without being refreshed. The default is DefaultDuration.
+func WithIdleTimeout(timeout time.Duration) Option {
+	return func(o *options) { o.idleTimeout = timeout }
+}
+
+// duration returns how long a refreshed cookie is valid.
+func (o *options) duration() time.Duration {
+	if o.idleTimeout <= 0 {
+		return DefaultDuration
+	}
+	return o.idleTimeout
+}
+
+// Config is the lifetime policy of sessions with sliding expiration, e.g. "8-hour sessions with a 30-minute idle timeout":
+//
+//	Config{MaxLifetime: 8 * time.Hour, IdleTimeout: 30 * time.Minute, RefreshThreshold: 10 * time.Minute}
+//
+// Cookies are minted and refreshed to expire IdleTimeout later, and only refreshed when less than RefreshThreshold remains, so a session idle for IdleTimeout always expires, while one idle for less than RefreshThreshold never does. However often a session is refreshed, it expires MaxLifetime after it began.
+type Config struct {
+	// MaxLifetime is the maximum age of a session; see WithMaxLifetime. If 0, sessions may be refreshed indefinitely.
+	MaxLifetime time.Duration
+	// MaxAuthAge is the maximum time since a user last logged in with their credentials, however their sessions were kept alive; see WithMaxAuthAge. If 0, there is none.
+	MaxAuthAge time.Duration
+	// IdleTimeout is how long cookies are valid after they are minted or refreshed; see WithIdleTimeout. If 0, DefaultDuration is used.
+	IdleTimeout time.Duration
+	// RefreshThreshold is how long before their expiration cookies are refreshed. If 0, half the idle timeout is used.
+	RefreshThreshold time.Duration
+	// Leeway is the clock skew tolerated between servers; see WithLeeway. If 0, there is none.
+	Leeway time.Duration
+	// ExpiryGrace is how long after they expire cookies are still accepted, and refreshed; see WithExpiryGrace. If 0, there is none.
+	ExpiryGrace time.Duration
+}
+
+// Validate returns an error if no session could satisfy the policy: if a duration is negative, the idle timeout is longer than the maximum lifetime or authentication age, or the refresh threshold isn't shorter than the idle timeout, which would refresh cookies on every request.
+func (cfg Config) Validate() error {
+	if cfg.MaxLifetime < 0 || cfg.MaxAuthAge < 0 || cfg.IdleTimeout < 0 || cfg.RefreshThreshold < 0 || cfg.Leeway < 0 || cfg.ExpiryGrace < 0 {
+		return errors.New("session durations must not be negative")
+	}
+	if cfg.MaxLifetime > 0 && cfg.idleTimeout() > cfg.MaxLifetime {
+		return errors.New("session idle timeout is longer than its maximum lifetime")
+	}
+	if cfg.MaxAuthAge > 0 && cfg.idleTimeout() > cfg.MaxAuthAge {
+		return errors.New("session idle timeout is longer than its maximum authentication age")
+	}
+	if cfg.refreshThreshold() >= cfg.idleTimeout() {
+		return errors.New("session refresh threshold must be shorter than its idle timeout")
+	}
+	return nil
+}
+
+func (cfg Config) idleTimeout() time.Duration {
+	return (&options{idleTimeout: cfg.IdleTimeout}).duration()
+}
+
+func (cfg Config) refreshThreshold() time.Duration {
+	if cfg.RefreshThreshold <= 0 {
+		return cfg.idleTimeout() / 2
+	}
+	return cfg.RefreshThreshold
+}
+
+// Options returns the options implementing the policy: WithMaxLifetime, WithMaxAuthAge, WithIdleTimeout, WithLeeway, WithExpiryGrace, and WithRefreshWindow, so Middleware refreshes cookies at the threshold.
+func (cfg Config) Options() []Option {
+	return []Option{WithMaxLifetime(cfg.MaxLifetime), WithMaxAuthAge(cfg.MaxAuthAge), WithIdleTimeout(cfg.IdleTimeout), WithLeeway(cfg.Leeway), WithExpiryGrace(cfg.ExpiryGrace), WithRefreshWindow(cfg.refreshThreshold())}
+}
+
+// New mints a cookie for a new session, expiring after the idle timeout, like the package-level New with the policy's Options followed by opts.
+func (cfg Config) New(user string, key string, opts ...Option) string {
+	opts = cfg.options(opts)
+	return New(user, newOptions(opts).now().Add(cfg.idleTimeout()), key, opts...)
+}
+
+// RefreshIfNeeded refreshes the cookie if less than the refresh threshold remains before it expires, like the package-level RefreshIfNeeded with the policy's Options followed by opts.
+func (cfg Config) RefreshIfNeeded(c *Cookie, key string, opts ...Option) (string, error) {
+	return RefreshIfNeeded(c, key, cfg.refreshThreshold(), cfg.options(opts)...)
+}
+
+// options returns the policy's Options followed by opts.
+func (cfg Config) options(opts []Option) []Option {
+	return append(cfg.Options(), opts...)
+}
@@ -0,0 +1,226 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWithMaxLifetime(t *testing.T) {
+	secret := "secret"
+	now := time.Now()
+	c := Cookie{AuthData: "alice", By: GeneratedByStr, IssuedAt: now.Add(-13 * time.Hour).Unix(), ExpiresUnix: now.Add(time.Minute).Unix()}
+
+	if err := Validate(&c); err != nil {
+		t.Errorf("Validate old session without max lifetime expected nil error, actual: %v", err)
+	}
+	if err := Validate(&c, WithMaxLifetime(12*time.Hour)); err != ErrSessionTooOld {
+		t.Errorf("Validate old session expected ErrSessionTooOld, actual: %v", err)
+	}
+	if err := Validate(&c, WithMaxLifetime(14*time.Hour)); err != nil {
+		t.Errorf("Validate session within max lifetime expected nil error, actual: %v", err)
+	}
+
+	started := c
+	started.IssuedAt = now.Unix()
+	started.SessionStart = now.Add(-13 * time.Hour).Unix()
+	if err := Validate(&started, WithMaxLifetime(12*time.Hour)); err != ErrSessionTooOld {
+		t.Errorf("Validate old session start expected ErrSessionTooOld, actual: %v", err)
+	}
+
+	legacy := c
+	legacy.IssuedAt = 0
+	if err := Validate(&legacy, WithMaxLifetime(time.Second)); err != nil {
+		t.Errorf("Validate cookie without IssuedAt expected nil error, actual: %v", err)
+	}
+
+	if _, err := Parse(secret, encodeCookie(&c, secret, newOptions(nil)), WithMaxLifetime(12*time.Hour)); err != ErrSessionTooOld {
+		t.Errorf("Parse old session expected ErrSessionTooOld, actual: %v", err)
+	}
+}
+
+func TestWithMaxAuthAge(t *testing.T) {
+	secret := "secret"
+	now := time.Now()
+	loggedIn := now.Add(-25 * time.Hour)
+	restored, err := Parse(secret, New("alice", now.Add(time.Hour), secret, WithAuthTime(loggedIn)))
+	if err != nil || restored.AuthTime != loggedIn.Unix() || restored.SessionAge(now) > time.Minute {
+		t.Fatalf("New WithAuthTime expected new session authenticated at %v, actual: %+v %v", loggedIn.Unix(), restored, err)
+	}
+	if age := restored.AuthAge(now); age < 25*time.Hour || age > 25*time.Hour+time.Minute {
+		t.Errorf("AuthAge expected 25h, actual: %v", age)
+	}
+	if err := Validate(restored, WithMaxAuthAge(24*time.Hour)); err != ErrReauthRequired {
+		t.Errorf("Validate of old authentication expected ErrReauthRequired, actual: %v", err)
+	}
+	if err := Validate(restored, WithMaxLifetime(24*time.Hour), WithMaxAuthAge(26*time.Hour)); err != nil {
+		t.Errorf("Validate of authentication within max age expected nil error, actual: %v", err)
+	}
+	if refreshed, err := RefreshIfNeeded(restored, secret, 2*time.Hour, WithMaxAuthAge(24*time.Hour)); err != ErrReauthRequired || refreshed != "" {
+		t.Errorf("RefreshIfNeeded of old authentication expected ErrReauthRequired, actual: '%v' %v", refreshed, err)
+	}
+
+	fresh, _ := Parse(secret, New("alice", now.Add(time.Hour), secret, WithAuthTime(now.Add(time.Hour))))
+	if fresh.AuthTime != 0 || fresh.AuthAge(now) > time.Minute {
+		t.Errorf("New WithAuthTime not before the session expected no AuthTime, actual: %+v", fresh)
+	}
+	ending := restored.Clone()
+	ending.AuthTime = now.Add(-24*time.Hour + 10*time.Minute).Unix()
+	r, err := Parse(secret, Refresh(ending, secret, WithMaxAuthAge(24*time.Hour)))
+	if err != nil || r.AuthTime != ending.AuthTime || r.TimeLeft(now) > 10*time.Minute {
+		t.Errorf("Refresh expected AuthTime preserved and expiry capped by the max auth age, actual: %+v %v", r, err)
+	}
+	if i := Introspect(r); i.AuthTime != ending.AuthTime {
+		t.Errorf("Introspect expected auth_time %v, actual: %v", ending.AuthTime, i.AuthTime)
+	}
+}
+
+func TestRefreshSessionStart(t *testing.T) {
+	secret := "secret"
+	parsed, err := Parse(secret, New("alice", time.Now().Add(time.Minute), secret))
+	if err != nil {
+		t.Fatalf("Parse expected nil error, actual: %v", err)
+	}
+	if parsed.IssuedAt == 0 || parsed.SessionStart != parsed.IssuedAt {
+		t.Fatalf("New expected IssuedAt and SessionStart to be set and equal, actual: %+v", parsed)
+	}
+
+	parsed.IssuedAt -= 100
+	parsed.SessionStart -= 100
+	refreshed, err := Parse(secret, Refresh(parsed, secret))
+	if err != nil {
+		t.Fatalf("Parse refreshed expected nil error, actual: %v", err)
+	}
+	if refreshed.SessionStart != parsed.SessionStart {
+		t.Errorf("Refresh expected SessionStart %v, actual: %v", parsed.SessionStart, refreshed.SessionStart)
+	}
+	if refreshed.IssuedAt <= parsed.IssuedAt {
+		t.Errorf("Refresh expected IssuedAt after %v, actual: %v", parsed.IssuedAt, refreshed.IssuedAt)
+	}
+
+	legacy := *parsed
+	legacy.SessionStart = 0
+	if refreshed, err := Parse(secret, Refresh(&legacy, secret)); err != nil || refreshed.SessionStart != legacy.IssuedAt {
+		t.Errorf("Refresh without SessionStart expected SessionStart %v, actual: %+v %v", legacy.IssuedAt, refreshed, err)
+	}
+
+	// A session ending within DefaultDuration isn't extended past its end.
+	lifetime := 100*time.Second + DefaultDuration/2
+	capped, err := Parse(secret, Refresh(parsed, secret, WithMaxLifetime(lifetime)))
+	if err != nil {
+		t.Fatalf("Parse capped expected nil error, actual: %v", err)
+	}
+	if expected := time.Unix(parsed.SessionStart, 0).Add(lifetime).Unix(); capped.ExpiresUnix != expected {
+		t.Errorf("Refresh with max lifetime expected expiration %v, actual: %v", expected, capped.ExpiresUnix)
+	}
+}
+
+func TestSessionAge(t *testing.T) {
+	now := time.Now()
+	tests := map[string]struct {
+		c        Cookie
+		expected time.Duration
+	}{
+		"session start": {Cookie{IssuedAt: now.Add(-time.Minute).Unix(), SessionStart: now.Add(-time.Hour).Unix()}, time.Hour},
+		"legacy":        {Cookie{IssuedAt: now.Add(-time.Minute).Unix()}, time.Minute},
+		"neither":       {Cookie{}, 0},
+	}
+	for name, test := range tests {
+		if actual := test.c.SessionAge(now).Truncate(time.Second); actual != test.expected {
+			t.Errorf("%v: SessionAge expected %v, actual: %v", name, test.expected, actual)
+		}
+	}
+}
+
+func TestRefreshIfNeeded(t *testing.T) {
+	secret := "secret"
+	now := time.Now()
+	fresh := Cookie{AuthData: "alice", By: GeneratedByStr, IssuedAt: now.Unix(), ExpiresUnix: now.Add(time.Hour).Unix()}
+	expiring := fresh
+	expiring.ExpiresUnix = now.Add(time.Minute).Unix()
+	old := expiring
+	old.IssuedAt = now.Add(-13 * time.Hour).Unix()
+
+	if cookie, err := RefreshIfNeeded(&fresh, secret, 5*time.Minute); err != nil || cookie != "" {
+		t.Errorf("RefreshIfNeeded fresh expected no cookie and nil error, actual: '%v' %v", cookie, err)
+	}
+	if cookie, err := RefreshIfNeeded(&expiring, secret, 5*time.Minute, WithMaxLifetime(12*time.Hour)); err != nil || cookie == "" {
+		t.Errorf("RefreshIfNeeded expiring expected cookie and nil error, actual: '%v' %v", cookie, err)
+	}
+	if cookie, err := RefreshIfNeeded(&old, secret, 5*time.Minute, WithMaxLifetime(12*time.Hour)); err != ErrSessionTooOld || cookie != "" {
+		t.Errorf("RefreshIfNeeded old expected ErrSessionTooOld, actual: '%v' %v", cookie, err)
+	}
+}
+
+func TestConfig(t *testing.T) {
+	secret := "secret"
+	cfg := Config{MaxLifetime: 8 * time.Hour, IdleTimeout: 30 * time.Minute, RefreshThreshold: 10 * time.Minute}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate expected nil error, actual: %v", err)
+	}
+
+	c, err := Parse(secret, cfg.New("alice", secret))
+	if err != nil {
+		t.Fatalf("Parse expected nil error, actual: %v", err)
+	}
+	if left := c.TimeLeft(time.Now()); left <= 29*time.Minute || left > 30*time.Minute {
+		t.Errorf("Config.New expected expiry after the idle timeout, actual: %v left", left)
+	}
+	if refreshed, err := cfg.RefreshIfNeeded(c, secret); err != nil || refreshed != "" {
+		t.Errorf("Config.RefreshIfNeeded with more than the threshold left expected no refresh, actual: '%v' %v", refreshed, err)
+	}
+
+	now := time.Now()
+	expiring := c.Clone()
+	expiring.ExpiresUnix = now.Add(5 * time.Minute).Unix()
+	refreshed, err := cfg.RefreshIfNeeded(expiring, secret)
+	if err != nil || refreshed == "" {
+		t.Fatalf("Config.RefreshIfNeeded within the threshold expected refresh, actual: '%v' %v", refreshed, err)
+	}
+	if r, err := Parse(secret, refreshed); err != nil || r.TimeLeft(now) <= 29*time.Minute || r.TimeLeft(now) > 31*time.Minute {
+		t.Errorf("Config.RefreshIfNeeded expected expiry extended by the idle timeout, actual: %+v %v", r, err)
+	}
+
+	ending := expiring.Clone()
+	ending.SessionStart = now.Add(-8*time.Hour + 10*time.Minute).Unix()
+	if r, err := Parse(secret, Refresh(ending, secret, cfg.Options()...)); err != nil || r.TimeLeft(now) > 11*time.Minute {
+		t.Errorf("Refresh with Config expected expiry capped at the max lifetime, actual: %+v %v", r, err)
+	}
+	ending.SessionStart = now.Add(-9 * time.Hour).Unix()
+	if _, err := cfg.RefreshIfNeeded(ending, secret); err != ErrSessionTooOld {
+		t.Errorf("Config.RefreshIfNeeded of session past its max lifetime expected ErrSessionTooOld, actual: %v", err)
+	}
+}
+
+func TestConfigDefaults(t *testing.T) {
+	cfg := Config{}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate of zero Config expected nil error, actual: %v", err)
+	}
+	if cfg.idleTimeout() != DefaultDuration || cfg.refreshThreshold() != DefaultRefreshWindow {
+		t.Errorf("zero Config expected DefaultDuration and DefaultRefreshWindow, actual: %v %v", cfg.idleTimeout(), cfg.refreshThreshold())
+	}
+	invalid := map[string]Config{
+		"negative":             {IdleTimeout: -time.Minute},
+		"idle beyond lifetime": {MaxLifetime: time.Hour, IdleTimeout: 2 * time.Hour},
+		"idle beyond auth age": {MaxAuthAge: time.Hour, IdleTimeout: 2 * time.Hour},
+		"negative auth age":    {MaxAuthAge: -time.Hour},
+		"threshold too long":   {IdleTimeout: time.Hour, RefreshThreshold: time.Hour},
+	}
+	for name, cfg := range invalid {
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("%v: Validate expected error, actual nil", name)
+		}
+	}
+}
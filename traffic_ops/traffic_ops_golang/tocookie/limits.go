@@ -0,0 +1,103 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"fmt"
+)
+
+// DefaultMaxCookieSize is the longest cookie, in bytes, Parse reads by default. Browsers don't store cookies over about 4KB, so this leaves room for bearer tokens and encoding, while bounding the work done on every unauthenticated request.
+const DefaultMaxCookieSize = 8192
+
+// DefaultMaxPayloadSize is the largest decoded payload, in bytes, Parse unmarshals by default.
+const DefaultMaxPayloadSize = DefaultMaxDecompressedSize
+
+// DefaultMaxJSONDepth is the deepest nesting of JSON objects and arrays Parse unmarshals by default. The claims of this package are at most two deep, so this only limits the values of custom claims and Mojolicious session data.
+const DefaultMaxJSONDepth = 32
+
+// WithMaxCookieSize sets the longest cookie, in bytes, Parse and the other functions which read cookies accept. Longer cookies are rejected with ErrMalformed, before they are scanned, trimmed, or decoded in any way. The default is DefaultMaxCookieSize; a size of zero or less removes the limit.
+func WithMaxCookieSize(size int) Option {
+	return func(o *options) { o.maxCookieSize = size }
+}
+
+// WithMaxPayloadSize sets the largest decoded payload, in bytes, Parse unmarshals: the payload after base64 decoding, decryption, and decompression. Larger payloads are rejected with ErrMalformed. Decompression stops early at WithMaxDecompressedSize, so a decompression bomb is bounded by the least of the two. The default is DefaultMaxPayloadSize; a size of zero or less removes the limit.
+func WithMaxPayloadSize(size int) Option {
+	return func(o *options) { o.maxPayloadSize = size }
+}
+
+// WithMaxJSONDepth sets the deepest nesting of JSON objects and arrays in a payload Parse unmarshals, which is checked by scanning the payload before it is unmarshalled. Deeper payloads are rejected with ErrMalformed. The default is DefaultMaxJSONDepth; a depth of zero or less removes the limit.
+func WithMaxJSONDepth(depth int) Option {
+	return func(o *options) { o.maxJSONDepth = depth }
+}
+
+// WithDisallowUnknownFields makes Parse reject payloads with keys other than the claims of this package, the names of WithFieldNames, and custom claims with a validator given by WithClaimValidators, with ErrMalformed, as json.Decoder.DisallowUnknownFields does. Such keys are otherwise kept in Extra. It suits services which only accept cookies minted by Go, as Perl Traffic Ops stores Mojolicious session data, such as flash messages, in the cookie.
+func WithDisallowUnknownFields() Option {
+	return func(o *options) { o.disallowUnknownFields = true }
+}
+
+// errCookieTooLong is allocated once, so rejecting long cookies allocates nothing.
+var errCookieTooLong = fmt.Errorf("%w: cookie too long", ErrMalformed)
+
+// checkCookieSize returns an error if the cookie is longer than WithMaxCookieSize.
+func (o *options) checkCookieSize(cookie string) error {
+	if o.maxCookieSize > 0 && len(cookie) > o.maxCookieSize {
+		return errCookieTooLong
+	}
+	return nil
+}
+
+// checkPayload returns an error if the decoded payload is larger than WithMaxPayloadSize, or nested deeper than WithMaxJSONDepth.
+func (o *options) checkPayload(b []byte) error {
+	if o.maxPayloadSize > 0 && len(b) > o.maxPayloadSize {
+		return fmt.Errorf("%w: payload of %d bytes exceeds the maximum of %d", ErrMalformed, len(b), o.maxPayloadSize)
+	}
+	if o.maxJSONDepth > 0 && jsonTooDeep(b, o.maxJSONDepth) {
+		return fmt.Errorf("%w: payload nested deeper than %d", ErrMalformed, o.maxJSONDepth)
+	}
+	return nil
+}
+
+// checkUnknownFields returns an error WithDisallowUnknownFields if the cookie has keys in Extra without a claim validator.
+func (o *options) checkUnknownFields(c *Cookie) error {
+	if !o.disallowUnknownFields {
+		return nil
+	}
+	for name := range c.Extra {
+		if _, ok := o.claimValidators[name]; !ok {
+			return fmt.Errorf("%w: unknown claim '%s'", ErrMalformed, name)
+		}
+	}
+	return nil
+}
+
+// jsonTooDeep returns whether the JSON nests objects and arrays deeper than max. It only counts brackets outside strings, so it is correct for valid JSON, and bounded for anything else, which the decoder rejects.
+func jsonTooDeep(b []byte, max int) bool {
+	depth := 0
+	inString := false
+	for i := 0; i < len(b); i++ {
+		switch c := b[i]; {
+		case inString && c == '\\':
+			i++
+		case c == '"':
+			inString = !inString
+		case inString:
+		case c == '{' || c == '[':
+			if depth++; depth > max {
+				return true
+			}
+		case c == '}' || c == ']':
+			depth--
+		}
+	}
+	return false
+}
@@ -0,0 +1,85 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLimits(t *testing.T) {
+	secret := "secret"
+	expires := strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)
+	payload := func(extra string) string {
+		return newMojoCookie(`{"auth_data":"alice","expires":`+expires+extra+`}`, secret)
+	}
+	deep := strings.Repeat("[", DefaultMaxJSONDepth-1) + strings.Repeat("]", DefaultMaxJSONDepth-1) // within the payload object
+	tests := map[string]struct {
+		cookie string
+		opts   []Option
+		err    error
+	}{
+		"valid":               {payload(""), nil, nil},
+		"too long":            {payload(`,"x":"` + strings.Repeat("x", DefaultMaxCookieSize) + `"`), []Option{WithMaxClaimsSize(0)}, ErrMalformed},
+		"too long trimmed":    {strings.Repeat(" ", DefaultMaxCookieSize) + payload(""), []Option{WithTrim()}, ErrMalformed},
+		"cookie size":         {payload(""), []Option{WithMaxCookieSize(32)}, ErrMalformed},
+		"no cookie size":      {payload(`,"x":"` + strings.Repeat("x", DefaultMaxCookieSize) + `"`), []Option{WithMaxCookieSize(0), WithMaxClaimsSize(1 << 20)}, nil},
+		"payload size":        {payload(""), []Option{WithMaxPayloadSize(16)}, ErrMalformed},
+		"depth":               {payload(`,"x":` + deep), nil, nil},
+		"too deep":            {payload(`,"x":[` + deep + `]`), nil, ErrMalformed},
+		"brackets in strings": {payload(`,"x":"` + strings.Repeat("[", 100) + `\"{"`), nil, nil},
+		"no depth":            {payload(`,"x":[` + deep + `]`), []Option{WithMaxJSONDepth(0)}, nil},
+		"unknown allowed":     {payload(`,"flash":{}`), nil, nil},
+		"unknown":             {payload(`,"flash":{}`), []Option{WithDisallowUnknownFields()}, ErrMalformed},
+		"known":               {payload(`,"iat":1`), []Option{WithDisallowUnknownFields()}, nil},
+		"validated":           {payload(`,"tenant":1`), []Option{WithDisallowUnknownFields(), WithClaimValidators(map[string]func(interface{}) error{"tenant": func(interface{}) error { return nil }})}, nil},
+	}
+	for name, test := range tests {
+		if _, err := Parse(secret, test.cookie, test.opts...); !errors.Is(err, test.err) || test.err == nil && err != nil {
+			t.Errorf("%v: Parse expected %v, actual: %v", name, test.err, err)
+		}
+		if _, err := NewParser(secret, test.opts...).Parse(test.cookie); !errors.Is(err, test.err) || test.err == nil && err != nil {
+			t.Errorf("%v: Parser expected %v, actual: %v", name, test.err, err)
+		}
+	}
+}
+
+func TestJSONTooDeep(t *testing.T) {
+	tests := map[string]struct {
+		json     string
+		max      int
+		expected bool
+	}{
+		"flat":           {`{"a":1}`, 1, false},
+		"nested":         {`{"a":{"b":[1]}}`, 2, true},
+		"at limit":       {`{"a":{"b":[1]}}`, 3, false},
+		"escaped quote":  {`{"a":"\"[[[["}`, 1, false},
+		"escaped escape": {`{"a":"\\","b":[[1]]}`, 2, true},
+		"unbalanced":     {`]]]]{`, 1, false},
+	}
+	for name, test := range tests {
+		if actual := jsonTooDeep([]byte(test.json), test.max); actual != test.expected {
+			t.Errorf("%v: jsonTooDeep expected %v, actual: %v", name, test.expected, actual)
+		}
+	}
+}
+
+func TestLimitsAllocations(t *testing.T) {
+	long := strings.Repeat("a", DefaultMaxCookieSize+1)
+	if allocs := testing.AllocsPerRun(100, func() { Parse("secret", long) }); allocs > 1 {
+		t.Errorf("Parse of too long cookie expected at most 1 allocation, actual: %v", allocs)
+	}
+}
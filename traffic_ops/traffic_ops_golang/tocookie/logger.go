@@ -0,0 +1,29 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+// Logger receives messages from the long-running parts of this package, such as key file watchers, and warnings of retired keys from ParseWithKeyRing. Parse and New never log; their errors carry the details, such as the ExpiredError of expired cookies, for callers to log as they see fit. Logger is satisfied by a thin adapter over lib/go-log.
+type Logger interface {
+	Infof(format string, v ...interface{})
+	Warnf(format string, v ...interface{})
+}
+
+// WithLogger sets the logger of a Manager, of ParseWithKeyRing, or of the WithFailureLimit of Middleware. The default discards all messages.
+func WithLogger(logger Logger) Option {
+	return func(o *options) { o.logger = logger }
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Infof(format string, v ...interface{}) {}
+func (nopLogger) Warnf(format string, v ...interface{}) {}
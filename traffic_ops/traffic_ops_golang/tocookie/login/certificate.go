@@ -0,0 +1,176 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package login
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/tocookie"
+)
+
+// CertificateAuthenticator maps the verified client certificates of TLS connections to users, for automation clients which authenticate with certificates rather than passwords. Implementations must be safe for concurrent use.
+type CertificateAuthenticator interface {
+	// AuthenticateCertificate returns the identity of the user of the certificate, which has been verified by the TLS server, or an error wrapping ErrInvalidCredentials if it maps to no user. The context is that of the login request.
+	AuthenticateCertificate(ctx context.Context, cert *x509.Certificate) (*Identity, error)
+}
+
+// CertificateAuthenticatorFunc is a function which is a CertificateAuthenticator.
+type CertificateAuthenticatorFunc func(ctx context.Context, cert *x509.Certificate) (*Identity, error)
+
+// AuthenticateCertificate implements CertificateAuthenticator.
+func (f CertificateAuthenticatorFunc) AuthenticateCertificate(ctx context.Context, cert *x509.Certificate) (*Identity, error) {
+	return f(ctx, cert)
+}
+
+// The fields of certificates which CertificateRules match.
+const (
+	// FieldSubject is the distinguished name of the subject, as x509.Certificate.Subject formats it, e.g. "CN=ort,OU=automation,O=Example".
+	FieldSubject = "subject"
+	// FieldCommonName is the common name of the subject.
+	FieldCommonName = "cn"
+	// FieldDNSName is each DNS name of the subject alternative names.
+	FieldDNSName = "dns"
+	// FieldEmail is each email address of the subject alternative names.
+	FieldEmail = "email"
+	// FieldURI is each URI of the subject alternative names, e.g. a SPIFFE ID.
+	FieldURI = "uri"
+)
+
+// CertificateRule maps the certificates whose Field matches Pattern to a user. It is configured as JSON, e.g. {"field": "dns", "pattern": "(.+)\\.automation\\.example\\.com", "username": "$1"}.
+type CertificateRule struct {
+	// Field is the field of the certificate which is matched, e.g. FieldCommonName.
+	Field string `json:"field"`
+	// Pattern is the regular expression which must match the whole of a value of the field.
+	Pattern string `json:"pattern"`
+	// Username is the user of matching certificates, expanded as by regexp.Regexp.Expand with the submatches of Pattern, so "$1" is the first. If it is empty, the user is the matched value.
+	Username string `json:"username,omitempty"`
+
+	re *regexp.Regexp
+}
+
+// CertificateRules is a CertificateAuthenticator which maps certificates to users by the first of its rules which matches, and looks their identities up with its resolver, so sessions get the users' roles and capabilities. Certificates which no rule matches are rejected with ErrInvalidCredentials.
+type CertificateRules struct {
+	rules    []CertificateRule
+	resolver IdentityResolver
+}
+
+// NewCertificateRules returns CertificateRules of the rules, in order, resolving users with the resolver, which is usually the Authenticator of the Handlers. It returns an error if a rule has an unknown field, or a pattern which doesn't compile.
+func NewCertificateRules(resolver IdentityResolver, rules ...CertificateRule) (*CertificateRules, error) {
+	compiled := make([]CertificateRule, len(rules))
+	for i, rule := range rules {
+		switch rule.Field {
+		case FieldSubject, FieldCommonName, FieldDNSName, FieldEmail, FieldURI:
+		default:
+			return nil, fmt.Errorf("certificate rule %d: unknown field '%v'", i, rule.Field)
+		}
+		re, err := regexp.Compile(`^(?:` + rule.Pattern + `)$`)
+		if err != nil {
+			return nil, fmt.Errorf("certificate rule %d: compiling pattern: %w", i, err)
+		}
+		rule.re = re
+		compiled[i] = rule
+	}
+	return &CertificateRules{rules: compiled, resolver: resolver}, nil
+}
+
+// AuthenticateCertificate implements CertificateAuthenticator.
+func (c *CertificateRules) AuthenticateCertificate(ctx context.Context, cert *x509.Certificate) (*Identity, error) {
+	username, ok := c.Username(cert)
+	if !ok {
+		return nil, fmt.Errorf("%w: certificate of '%v' matches no rule", ErrInvalidCredentials, cert.Subject)
+	}
+	return c.resolver.Identity(ctx, username)
+}
+
+// Username returns the user which the first matching rule maps the certificate to, and whether any rule matches.
+func (c *CertificateRules) Username(cert *x509.Certificate) (string, bool) {
+	for _, rule := range c.rules {
+		for _, value := range certificateValues(cert, rule.Field) {
+			match := rule.re.FindStringSubmatchIndex(value)
+			if match == nil {
+				continue
+			}
+			if rule.Username == "" {
+				return value, true
+			}
+			if username := string(rule.re.ExpandString(nil, rule.Username, value, match)); username != "" {
+				return username, true
+			}
+		}
+	}
+	return "", false
+}
+
+// certificateValues returns the values of the field of the certificate.
+func certificateValues(cert *x509.Certificate, field string) []string {
+	switch field {
+	case FieldSubject:
+		return []string{cert.Subject.String()}
+	case FieldCommonName:
+		if cert.Subject.CommonName == "" {
+			return nil
+		}
+		return []string{cert.Subject.CommonName}
+	case FieldDNSName:
+		return cert.DNSNames
+	case FieldEmail:
+		return cert.EmailAddresses
+	case FieldURI:
+		uris := make([]string, len(cert.URIs))
+		for i, uri := range cert.URIs {
+			uris[i] = uri.String()
+		}
+		return uris
+	}
+	return nil
+}
+
+// CertificateLogin is the handler of POST requests to log in with the client certificate of the TLS connection, mapped to a user by Certificates. On success, the session's cookie is set on the response, as Login sets it. The certificate must have been verified by the server, i.e. its tls.Config has a ClientAuth of tls.VerifyClientCertIfGiven or tls.RequireAndVerifyClientCert, and ClientCAs of the automation clients; requests without a verified certificate, and certificates which map to no user, are answered with 401 Unauthorized. Requests of other methods are answered with 405 Method Not Allowed, and all requests with 404 Not Found if Certificates is nil.
+func (h *Handlers) CertificateLogin(w http.ResponseWriter, r *http.Request) {
+	if h.Certificates == nil {
+		WriteAlert(w, http.StatusNotFound, ErrorLevel, http.StatusText(http.StatusNotFound))
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		WriteAlert(w, http.StatusMethodNotAllowed, ErrorLevel, http.StatusText(http.StatusMethodNotAllowed))
+		return
+	}
+	// without verified chains, the peer certificates were only requested, and aren't proof of anything.
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.PeerCertificates) == 0 {
+		WriteAlert(w, http.StatusUnauthorized, ErrorLevel, "Invalid client certificate.")
+		return
+	}
+	cert := r.TLS.PeerCertificates[0]
+	identity, err := h.Certificates.AuthenticateCertificate(r.Context(), cert)
+	if err != nil {
+		// unlike wrong passwords, unmapped certificates are logged, as they are usually misconfigured rules or clients.
+		h.warnf("authenticating certificate of '%v': %v", cert.Subject, err)
+		if errors.Is(err, ErrInvalidCredentials) {
+			WriteAlert(w, http.StatusUnauthorized, ErrorLevel, "Invalid client certificate.")
+			return
+		}
+		WriteAlert(w, http.StatusInternalServerError, ErrorLevel, http.StatusText(http.StatusInternalServerError))
+		return
+	}
+	if h.startSession(w, r, identity, authMethods(identity, tocookie.AuthMethodMTLS)) == "" {
+		WriteAlert(w, http.StatusInternalServerError, ErrorLevel, http.StatusText(http.StatusInternalServerError))
+		return
+	}
+	WriteAlert(w, http.StatusOK, SuccessLevel, "Successfully logged in.")
+}
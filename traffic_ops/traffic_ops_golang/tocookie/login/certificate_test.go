@@ -0,0 +1,133 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package login
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/tocookie"
+)
+
+func TestCertificateRules(t *testing.T) {
+	spiffe, _ := url.Parse("spiffe://example.com/user/alice")
+	rules, err := NewCertificateRules(testResolver{testAuthenticator},
+		CertificateRule{Field: FieldURI, Pattern: `spiffe://example\.com/user/(\w+)`, Username: "$1"},
+		CertificateRule{Field: FieldDNSName, Pattern: `([a-z]+)\.automation\.example\.com`, Username: "${1}"},
+		CertificateRule{Field: FieldEmail, Pattern: `.+@example\.com`},
+		CertificateRule{Field: FieldSubject, Pattern: `CN=(\w+),OU=automation,O=Example`, Username: "$1"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		cert     *x509.Certificate
+		expected string
+	}{
+		"uri":           {&x509.Certificate{URIs: []*url.URL{spiffe}}, "alice"},
+		"dns":           {&x509.Certificate{DNSNames: []string{"other.example.com", "ort.automation.example.com"}}, "ort"},
+		"email":         {&x509.Certificate{EmailAddresses: []string{"bob@example.com"}}, "bob@example.com"},
+		"subject":       {&x509.Certificate{Subject: pkix.Name{CommonName: "ansible", OrganizationalUnit: []string{"automation"}, Organization: []string{"Example"}}}, "ansible"},
+		"first rule":    {&x509.Certificate{URIs: []*url.URL{spiffe}, DNSNames: []string{"ort.automation.example.com"}}, "alice"},
+		"partial match": {&x509.Certificate{DNSNames: []string{"ort.automation.example.com.evil.net"}}, ""},
+		"no match":      {&x509.Certificate{Subject: pkix.Name{CommonName: "ort"}}, ""},
+	}
+	for name, test := range tests {
+		if username, ok := rules.Username(test.cert); username != test.expected || ok != (test.expected != "") {
+			t.Errorf("%v: Username expected '%v', actual: '%v' %v", name, test.expected, username, ok)
+		}
+	}
+
+	if _, err := NewCertificateRules(testResolver{}, CertificateRule{Field: "serial", Pattern: ".+"}); err == nil {
+		t.Error("NewCertificateRules of unknown field expected error, actual: nil")
+	}
+	if _, err := NewCertificateRules(testResolver{}, CertificateRule{Field: FieldCommonName, Pattern: "("}); err == nil {
+		t.Error("NewCertificateRules of invalid pattern expected error, actual: nil")
+	}
+}
+
+func TestCertificateLogin(t *testing.T) {
+	secret := "secret"
+	h := New(testAuthenticator, secret)
+	rules, err := NewCertificateRules(testResolver{testAuthenticator}, CertificateRule{Field: FieldCommonName, Pattern: `(\w+)\.automation`, Username: "$1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Certificates = rules
+	alice := &x509.Certificate{Subject: pkix.Name{CommonName: "alice.automation"}}
+	bob := &x509.Certificate{Subject: pkix.Name{CommonName: "bob.automation"}}
+	unmapped := &x509.Certificate{Subject: pkix.Name{CommonName: "alice"}}
+	verified := func(cert *x509.Certificate) *tls.ConnectionState {
+		return &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}, VerifiedChains: [][]*x509.Certificate{{cert}}}
+	}
+
+	tests := map[string]struct {
+		method   string
+		tls      *tls.ConnectionState
+		expected int
+	}{
+		"verified":   {http.MethodPost, verified(alice), http.StatusOK},
+		"unverified": {http.MethodPost, &tls.ConnectionState{PeerCertificates: []*x509.Certificate{alice}}, http.StatusUnauthorized},
+		"no tls":     {http.MethodPost, nil, http.StatusUnauthorized},
+		"unmapped":   {http.MethodPost, verified(unmapped), http.StatusUnauthorized},
+		"no user":    {http.MethodPost, verified(bob), http.StatusUnauthorized},
+		"get":        {http.MethodGet, verified(alice), http.StatusMethodNotAllowed},
+	}
+	for name, test := range tests {
+		r := httptest.NewRequest(test.method, "/login/certificate", nil)
+		r.TLS = test.tls
+		w := httptest.NewRecorder()
+		h.CertificateLogin(w, r)
+		if w.Code != test.expected {
+			t.Errorf("%v: CertificateLogin expected status %v, actual: %v %v", name, test.expected, w.Code, w.Body)
+		}
+		cookie, ok := responseCookies(w)[tocookie.Name]
+		if test.expected != http.StatusOK {
+			if ok {
+				t.Errorf("%v: CertificateLogin expected no cookie, actual: %v", name, cookie)
+			}
+			continue
+		}
+		if !ok {
+			t.Fatalf("%v: CertificateLogin expected session cookie, actual: none", name)
+		}
+		if c, err := tocookie.Parse(secret, cookie.Value); err != nil || c.AuthData != "alice" || !c.HasRole("admin") {
+			t.Errorf("%v: CertificateLogin expected cookie of alice with role admin, actual: %+v %v", name, c, err)
+		}
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/login/certificate", nil)
+	r.TLS = verified(alice)
+	h.Certificates = CertificateAuthenticatorFunc(func(ctx context.Context, cert *x509.Certificate) (*Identity, error) {
+		return nil, errors.New("database unavailable")
+	})
+	w := httptest.NewRecorder()
+	h.CertificateLogin(w, r)
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("CertificateLogin of failing authenticator expected status 500, actual: %v", w.Code)
+	}
+	h.Certificates = nil
+	w = httptest.NewRecorder()
+	h.CertificateLogin(w, r)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("CertificateLogin without Certificates expected status 404, actual: %v", w.Code)
+	}
+}
@@ -0,0 +1,294 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ldapauth provides a login.Authenticator which binds as the user to an LDAP directory, such as Active Directory, and maps the user's groups to the roles of their session, as Traffic Ops' LDAP login does:
+//
+//	authenticator := ldapauth.New(ldapauth.Config{
+//		URL:          "ldaps://ldap.example.net:636",
+//		BindDN:       "cn=trafficops,ou=services,dc=example,dc=net",
+//		BindPassword: password,
+//		BaseDN:       "ou=people,dc=example,dc=net",
+//		UserFilter:   "(&(objectClass=person)(sAMAccountName=%s))",
+//		GroupRoles:   map[string]string{"cn=cdn-admins,ou=groups,dc=example,dc=net": "admin"},
+//	})
+//	handlers := login.New(authenticator, secret)
+package ldapauth
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/tocookie"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/tocookie/login"
+
+	"gopkg.in/ldap.v2"
+)
+
+// DefaultTimeout is the default time limit of connecting to the directory and of each operation.
+const DefaultTimeout = 10 * time.Second
+
+// DefaultGroupAttribute is the default attribute of user entries listing the DNs of their groups, as Active Directory and the memberof overlay of OpenLDAP name it.
+const DefaultGroupAttribute = "memberOf"
+
+// Config configures an Authenticator.
+type Config struct {
+	// URL is the ldap:// or ldaps:// URL of the directory. The port defaults to 389 or 636.
+	URL string
+	// StartTLS upgrades ldap:// connections to TLS before binding, so passwords aren't sent in the clear.
+	StartTLS bool
+	// TLSConfig, if not nil, configures TLS. By default, the certificate of the host of URL is verified.
+	TLSConfig *tls.Config
+	// BindDN and BindPassword are the credentials of the service account which searches for users. If BindDN is empty, the search is anonymous.
+	BindDN       string
+	BindPassword string
+	// BaseDN is the base of the search for users.
+	BaseDN string
+	// UserFilter is the filter of the search for users, with %s for the username, which is escaped, e.g. "(&(objectClass=person)(uid=%s))".
+	UserFilter string
+	// GroupAttribute is the attribute of user entries listing the DNs of their groups. It defaults to DefaultGroupAttribute. It is ignored given GroupFilter.
+	GroupAttribute string
+	// GroupBaseDN and GroupFilter, if GroupFilter isn't empty, find the user's groups by searching for group entries, for directories without GroupAttribute, e.g. "(&(objectClass=groupOfNames)(member=%s))", with %s for the escaped DN of the user. GroupBaseDN defaults to BaseDN.
+	GroupBaseDN string
+	GroupFilter string
+	// GroupRoles maps the DNs of groups to roles of the user's session. DNs are matched case-insensitively, ignoring insignificant spaces.
+	GroupRoles map[string]string
+	// DefaultRoles are the roles of users of no group of GroupRoles.
+	DefaultRoles []string
+	// RequireRole rejects users without roles, so only members of the groups of GroupRoles can log in, unless DefaultRoles are given.
+	RequireRole bool
+	// Timeout is the time limit of connecting and of each operation. It defaults to DefaultTimeout.
+	Timeout time.Duration
+}
+
+// conn is the subset of *ldap.Conn used by the Authenticator.
+type conn interface {
+	Bind(username, password string) error
+	Search(req *ldap.SearchRequest) (*ldap.SearchResult, error)
+	Close()
+}
+
+// Authenticator is a login.Authenticator backed by an LDAP directory. Each login opens a connection, binds as the service account to search for the user, then binds as the user to check their password. It is safe for concurrent use.
+type Authenticator struct {
+	cfg        Config
+	groupRoles map[string]string
+	dial       func(ctx context.Context) (conn, error)
+}
+
+// New returns an Authenticator for the directory. It doesn't connect until the first login.
+func New(cfg Config) *Authenticator {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+	if cfg.GroupAttribute == "" {
+		cfg.GroupAttribute = DefaultGroupAttribute
+	}
+	if cfg.GroupBaseDN == "" {
+		cfg.GroupBaseDN = cfg.BaseDN
+	}
+	a := &Authenticator{cfg: cfg, groupRoles: make(map[string]string, len(cfg.GroupRoles))}
+	for dn, role := range cfg.GroupRoles {
+		a.groupRoles[normalizeDN(dn)] = role
+	}
+	a.dial = a.dialLDAP
+	return a
+}
+
+// Authenticate implements login.Authenticator. Unknown users, wrong passwords, and, given RequireRole, users without roles are rejected with an error wrapping login.ErrInvalidCredentials. Empty passwords are always rejected, as LDAP servers accept them as an unauthenticated bind, without checking the password.
+func (a *Authenticator) Authenticate(ctx context.Context, username, password string) (*login.Identity, error) {
+	if username == "" || password == "" {
+		return nil, login.ErrInvalidCredentials
+	}
+	c, err := a.dial(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to ldap: %w", err)
+	}
+	defer c.Close()
+	stop := closeOnDone(ctx, c)
+	defer stop()
+
+	if err := c.Bind(a.cfg.BindDN, a.cfg.BindPassword); err != nil {
+		return nil, fmt.Errorf("binding service account: %w", err)
+	}
+	attributes := []string{"dn"}
+	if a.cfg.GroupFilter == "" {
+		attributes = append(attributes, a.cfg.GroupAttribute)
+	}
+	users, err := c.Search(ldap.NewSearchRequest(a.cfg.BaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, a.timeLimit(), false,
+		fmt.Sprintf(a.cfg.UserFilter, escapeFilter(username)), attributes, nil))
+	if err != nil && !ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) {
+		return nil, fmt.Errorf("searching for user: %w", err)
+	}
+	switch {
+	case users == nil || len(users.Entries) == 0:
+		return nil, fmt.Errorf("%w: no such user", login.ErrInvalidCredentials)
+	case len(users.Entries) > 1:
+		return nil, fmt.Errorf("searching for user '%v': found more than one", username)
+	}
+	user := users.Entries[0]
+
+	if err := c.Bind(user.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return nil, fmt.Errorf("%w: wrong password", login.ErrInvalidCredentials)
+		}
+		return nil, fmt.Errorf("binding user: %w", err)
+	}
+
+	groups := user.GetAttributeValues(a.cfg.GroupAttribute)
+	if a.cfg.GroupFilter != "" {
+		// the user may not be allowed to search for groups, so the service account searches.
+		if err := c.Bind(a.cfg.BindDN, a.cfg.BindPassword); err != nil {
+			return nil, fmt.Errorf("binding service account: %w", err)
+		}
+		if groups, err = a.searchGroups(c, user.DN); err != nil {
+			return nil, err
+		}
+	}
+	roles := a.roles(groups)
+	if len(roles) == 0 && a.cfg.RequireRole {
+		return nil, fmt.Errorf("%w: user has no role", login.ErrInvalidCredentials)
+	}
+	return &login.Identity{Username: username, Roles: roles, AuthMethods: []string{tocookie.AuthMethodLDAP}}, nil
+}
+
+// searchGroups returns the DNs of the groups of GroupFilter of the user.
+func (a *Authenticator) searchGroups(c conn, userDN string) ([]string, error) {
+	result, err := c.Search(ldap.NewSearchRequest(a.cfg.GroupBaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, a.timeLimit(), false,
+		fmt.Sprintf(a.cfg.GroupFilter, escapeFilter(userDN)), []string{"dn"}, nil))
+	if err != nil {
+		return nil, fmt.Errorf("searching for groups: %w", err)
+	}
+	groups := make([]string, 0, len(result.Entries))
+	for _, entry := range result.Entries {
+		groups = append(groups, entry.DN)
+	}
+	return groups, nil
+}
+
+// roles returns the roles of the groups, in the order of the groups without duplicates, or DefaultRoles if they have none.
+func (a *Authenticator) roles(groups []string) []string {
+	roles := []string{}
+	seen := map[string]bool{}
+	for _, group := range groups {
+		if role, ok := a.groupRoles[normalizeDN(group)]; ok && !seen[role] {
+			seen[role] = true
+			roles = append(roles, role)
+		}
+	}
+	if len(roles) == 0 {
+		return append(roles, a.cfg.DefaultRoles...)
+	}
+	return roles
+}
+
+// timeLimit is the time limit of searches, in whole seconds, as the protocol takes them.
+func (a *Authenticator) timeLimit() int {
+	if limit := int(a.cfg.Timeout / time.Second); limit > 0 {
+		return limit
+	}
+	return 1
+}
+
+// dialLDAP connects to the directory of URL, upgrading the connection with StartTLS if configured.
+func (a *Authenticator) dialLDAP(ctx context.Context) (conn, error) {
+	u, err := url.Parse(a.cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("parsing url: %w", err)
+	}
+	host, port := u.Hostname(), u.Port()
+	tlsConfig := a.cfg.TLSConfig
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{ServerName: host}
+	}
+	isTLS := false
+	switch strings.ToLower(u.Scheme) {
+	case "ldaps":
+		isTLS = true
+		if port == "" {
+			port = "636"
+		}
+	case "ldap":
+		if port == "" {
+			port = "389"
+		}
+	default:
+		return nil, errors.New("url scheme must be ldap or ldaps, not '" + u.Scheme + "'")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, a.cfg.Timeout)
+	defer cancel()
+	dialer := &net.Dialer{}
+	netConn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(host, port))
+	if err != nil {
+		return nil, err
+	}
+	if isTLS {
+		tlsConn := tls.Client(netConn, tlsConfig)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			netConn.Close()
+			return nil, err
+		}
+		netConn = tlsConn
+	}
+	c := ldap.NewConn(netConn, isTLS)
+	c.Start()
+	c.SetTimeout(a.cfg.Timeout)
+	if a.cfg.StartTLS && !isTLS {
+		if err := c.StartTLS(tlsConfig); err != nil {
+			c.Close()
+			return nil, fmt.Errorf("starting tls: %w", err)
+		}
+	}
+	return c, nil
+}
+
+// closeOnDone closes the connection when the context is done, so a cancelled login doesn't wait on the directory. The returned function stops watching the context.
+func closeOnDone(ctx context.Context, c conn) func() {
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			c.Close()
+		case <-done:
+		}
+	}()
+	return func() { close(done) }
+}
+
+// filterEscaper escapes the special characters of search filters, as RFC 4515 requires, so usernames can't change the filter.
+var filterEscaper = strings.NewReplacer(`\`, `\5c`, `*`, `\2a`, `(`, `\28`, `)`, `\29`, "\x00", `\00`)
+
+func escapeFilter(s string) string {
+	return filterEscaper.Replace(s)
+}
+
+// normalizeDN returns the DN with its attribute types and values lower-cased, and insignificant spaces removed, for matching group DNs. DNs which can't be parsed are only lower-cased.
+func normalizeDN(dn string) string {
+	parsed, err := ldap.ParseDN(dn)
+	if err != nil {
+		return strings.ToLower(strings.TrimSpace(dn))
+	}
+	rdns := make([]string, 0, len(parsed.RDNs))
+	for _, rdn := range parsed.RDNs {
+		attrs := make([]string, 0, len(rdn.Attributes))
+		for _, attr := range rdn.Attributes {
+			attrs = append(attrs, strings.ToLower(attr.Type)+"="+strings.ToLower(attr.Value))
+		}
+		rdns = append(rdns, strings.Join(attrs, "+"))
+	}
+	return strings.Join(rdns, ",")
+}
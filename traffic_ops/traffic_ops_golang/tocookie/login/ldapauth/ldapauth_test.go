@@ -0,0 +1,167 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ldapauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/tocookie/login"
+
+	"gopkg.in/ldap.v2"
+)
+
+// fakeDirectory is a directory of users, by DN, with their passwords and groups.
+type fakeDirectory struct {
+	users    map[string]fakeUser
+	groups   map[string][]string // group DN to member DNs
+	bound    string
+	filters  []string
+	closed   bool
+	bindErrs map[string]error
+}
+
+type fakeUser struct {
+	uid, password string
+	memberOf      []string
+}
+
+const (
+	serviceDN = "cn=trafficops,ou=services,dc=example,dc=net"
+	servicePW = "service"
+)
+
+func (d *fakeDirectory) Bind(username, password string) error {
+	if err := d.bindErrs[username]; err != nil {
+		return err
+	}
+	if username == serviceDN && password == servicePW {
+		d.bound = username
+		return nil
+	}
+	if u, ok := d.users[username]; ok && u.password == password {
+		d.bound = username
+		return nil
+	}
+	return ldap.NewError(ldap.LDAPResultInvalidCredentials, errors.New("invalid credentials"))
+}
+
+func (d *fakeDirectory) Search(req *ldap.SearchRequest) (*ldap.SearchResult, error) {
+	if d.bound != serviceDN {
+		return nil, ldap.NewError(ldap.LDAPResultInsufficientAccessRights, errors.New("not allowed"))
+	}
+	d.filters = append(d.filters, req.Filter)
+	result := &ldap.SearchResult{}
+	for dn, u := range d.users {
+		if req.Filter == fmt.Sprintf("(uid=%s)", u.uid) {
+			result.Entries = append(result.Entries, ldap.NewEntry(dn, map[string][]string{DefaultGroupAttribute: u.memberOf}))
+		}
+	}
+	for group, members := range d.groups {
+		for _, member := range members {
+			if req.Filter == fmt.Sprintf("(member=%s)", member) {
+				result.Entries = append(result.Entries, ldap.NewEntry(group, nil))
+			}
+		}
+	}
+	return result, nil
+}
+
+func (d *fakeDirectory) Close() { d.closed = true }
+
+func newTestAuthenticator(cfg Config, d *fakeDirectory) *Authenticator {
+	cfg.BindDN, cfg.BindPassword, cfg.UserFilter = serviceDN, servicePW, "(uid=%s)"
+	a := New(cfg)
+	a.dial = func(ctx context.Context) (conn, error) { return d, nil }
+	return a
+}
+
+func TestAuthenticate(t *testing.T) {
+	d := &fakeDirectory{users: map[string]fakeUser{
+		"uid=alice,ou=people,dc=example,dc=net": {"alice", "hunter2", []string{"CN=CDN-Admins, OU=Groups,DC=example,DC=net", "cn=other,dc=example,dc=net"}},
+		"uid=bob,ou=people,dc=example,dc=net":   {"bob", "password", nil},
+	}}
+	a := newTestAuthenticator(Config{
+		GroupRoles:   map[string]string{"cn=cdn-admins,ou=groups,dc=example,dc=net": "admin"},
+		DefaultRoles: []string{"read-only"},
+	}, d)
+
+	tests := map[string]struct {
+		username, password string
+		roles              []string
+		err                error
+	}{
+		"admin":          {"alice", "hunter2", []string{"admin"}, nil},
+		"default role":   {"bob", "password", []string{"read-only"}, nil},
+		"wrong password": {"alice", "wrong", nil, login.ErrInvalidCredentials},
+		"unknown user":   {"carol", "password", nil, login.ErrInvalidCredentials},
+		"empty password": {"alice", "", nil, login.ErrInvalidCredentials},
+		"injection":      {"*", "hunter2", nil, login.ErrInvalidCredentials},
+	}
+	for name, test := range tests {
+		identity, err := a.Authenticate(context.Background(), test.username, test.password)
+		if !errors.Is(err, test.err) {
+			t.Errorf("%v: Authenticate expected error %v, actual: %v", name, test.err, err)
+			continue
+		}
+		if err == nil && (identity.Username != test.username || !reflect.DeepEqual(identity.Roles, test.roles)) {
+			t.Errorf("%v: Authenticate expected %v with roles %v, actual: %+v", name, test.username, test.roles, identity)
+		}
+	}
+	escaped := false
+	for _, filter := range d.filters {
+		escaped = escaped || filter == `(uid=\2a)`
+	}
+	if !escaped {
+		t.Errorf("Authenticate expected escaped filter, actual: %v", d.filters)
+	}
+	if !d.closed {
+		t.Errorf("Authenticate expected connection closed")
+	}
+}
+
+func TestAuthenticateGroupFilter(t *testing.T) {
+	aliceDN := "uid=alice,ou=people,dc=example,dc=net"
+	d := &fakeDirectory{
+		users:  map[string]fakeUser{aliceDN: {"alice", "hunter2", nil}, "uid=bob,ou=people,dc=example,dc=net": {"bob", "password", nil}},
+		groups: map[string][]string{"cn=ops,ou=groups,dc=example,dc=net": {aliceDN}},
+	}
+	a := newTestAuthenticator(Config{
+		GroupFilter: "(member=%s)",
+		GroupRoles:  map[string]string{"cn=ops,ou=groups,dc=example,dc=net": "operations"},
+		RequireRole: true,
+	}, d)
+
+	identity, err := a.Authenticate(context.Background(), "alice", "hunter2")
+	if err != nil || !reflect.DeepEqual(identity.Roles, []string{"operations"}) {
+		t.Errorf("Authenticate with group filter expected role operations, actual: %+v %v", identity, err)
+	}
+	if _, err := a.Authenticate(context.Background(), "bob", "password"); !errors.Is(err, login.ErrInvalidCredentials) {
+		t.Errorf("Authenticate without role given RequireRole expected ErrInvalidCredentials, actual: %v", err)
+	}
+}
+
+func TestAuthenticateUnavailable(t *testing.T) {
+	d := &fakeDirectory{bindErrs: map[string]error{serviceDN: ldap.NewError(ldap.LDAPResultUnavailable, errors.New("down"))}}
+	a := newTestAuthenticator(Config{}, d)
+	if _, err := a.Authenticate(context.Background(), "alice", "hunter2"); err == nil || errors.Is(err, login.ErrInvalidCredentials) {
+		t.Errorf("Authenticate with directory unavailable expected error other than ErrInvalidCredentials, actual: %v", err)
+	}
+	a.cfg.URL = "http://ldap.example.net"
+	if _, err := a.dialLDAP(context.Background()); err == nil {
+		t.Errorf("dialLDAP with http url expected error, actual nil")
+	}
+}
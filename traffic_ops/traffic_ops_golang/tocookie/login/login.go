@@ -0,0 +1,274 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package login provides the /login and /logout handlers of services which authenticate users with tocookie sessions, so each service needn't reimplement establishing and ending sessions:
+//
+//	handlers := login.New(authenticator, secret, tocookie.WithRevocationStore(store))
+//	http.HandleFunc("/login", handlers.Login)
+//	http.HandleFunc("/logout", handlers.Logout)
+//	http.Handle("/", tocookie.Middleware(secret, api, tocookie.WithRevocationStore(store)))
+//
+// Users may opt into staying signed in across browser restarts with remember-me cookies; see Handlers.Remember and Handlers.Remembered. Automation clients may log in with client certificates instead of passwords; see Handlers.CertificateLogin. Users may enroll in a second factor, such as the one-time passwords of package totp, which Login then requires; see Handlers.TwoFactor. Users of server-side sessions may list and revoke them, e.g. to sign out of their other devices; see Sessions. Credentials are checked by an Authenticator, such as that of package credentials, which verifies the password hashes of the users of the Traffic Ops database, or of package ldapauth, which binds to an LDAP directory. Responses are Traffic Ops alerts, as the Traffic Ops login endpoint returns them.
+package login
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/tocookie"
+)
+
+// ErrInvalidCredentials is returned by Authenticators for unknown users, and wrong passwords. Login answers it with 401 Unauthorized; other errors of Authenticators are answered with 500 Internal Server Error, as they mean the credentials couldn't be checked.
+var ErrInvalidCredentials = errors.New("invalid username or password")
+
+// Identity is an authenticated user, whose claims are given to the session's cookie.
+type Identity struct {
+	// Username is the AuthData of the cookie, which may be canonicalized from the username the user gave, e.g. by case.
+	Username string
+	// Roles are the roles of the cookie; see tocookie.WithRoles.
+	Roles []string
+	// Capabilities are the capabilities of the cookie; see tocookie.WithCapabilities.
+	Capabilities []string
+	// AuthMethods are the methods the user authenticated with, such as tocookie.AuthMethodLDAP; see tocookie.WithAuthMethods. If there are none, the cookie records the method of the handler, such as tocookie.AuthMethodPassword for Login.
+	AuthMethods []string
+}
+
+// Authenticator checks the credentials of a user logging in. Implementations must be safe for concurrent use.
+type Authenticator interface {
+	// Authenticate returns the identity of the user, or an error wrapping ErrInvalidCredentials if the credentials are wrong. The context is that of the login request.
+	Authenticate(ctx context.Context, username, password string) (*Identity, error)
+}
+
+// AuthenticatorFunc is a function which is an Authenticator.
+type AuthenticatorFunc func(ctx context.Context, username, password string) (*Identity, error)
+
+// Authenticate implements Authenticator.
+func (f AuthenticatorFunc) Authenticate(ctx context.Context, username, password string) (*Identity, error) {
+	return f(ctx, username, password)
+}
+
+// MaxRequestSize is the largest body of a login request which is read.
+const MaxRequestSize = 64 * 1024
+
+// Handlers are the login and logout handlers of a service. Fields must not be modified once the handlers are serving.
+type Handlers struct {
+	// Authenticator checks the credentials of login requests.
+	Authenticator Authenticator
+	// Secret signs the cookies of sessions, and verifies them on logout.
+	Secret string
+	// Duration is how long the cookies of new sessions last; it should be the idle timeout of the tocookie.WithIdleTimeout option of the service, if it has one.
+	Duration time.Duration
+	// Revocations, if not nil, revokes the sessions of logout requests, so copies of their cookies are rejected by services given tocookie.WithRevocationStore of the same store. Otherwise, logout only clears the cookie from the browser.
+	Revocations tocookie.RevocationStore
+	// Options are the options of minting and parsing cookies, and of their HTTP cookie attributes, such as tocookie.WithSecure. Given tocookie.WithAuditSink, logins, failed logouts, and revocations are audited with the clients of their requests.
+	Options []tocookie.Option
+	// Remember, if not nil, stores the remember-me series of users who log in with the remember field set, so Remembered can sign them in again once their session has ended, e.g. as the browser was closed. The Authenticator must be an IdentityResolver, or logins don't offer it.
+	Remember tocookie.RememberStore
+	// RememberDuration is how long remember-me series last, from the login which started them.
+	RememberDuration time.Duration
+	// Certificates, if not nil, maps the client certificates of CertificateLogin requests to users.
+	Certificates CertificateAuthenticator
+	// TwoFactor, if not nil, checks the second factor of users who have enrolled in one: Login answers them with a pre-auth cookie, which TwoFactorLogin exchanges for their session once they give their code.
+	TwoFactor TwoFactor
+	// Logger, if not nil, receives the errors of Authenticator, Certificates, TwoFactor, Revocations, and Remember, which aren't revealed to clients.
+	Logger tocookie.Logger
+}
+
+// New returns Handlers for the authenticator and secret, with cookies of tocookie.DefaultDuration, and remember-me series of tocookie.DefaultRememberDuration. If the options include tocookie.WithRevocationStore, give the store to Revocations as well.
+func New(authenticator Authenticator, secret string, opts ...tocookie.Option) *Handlers {
+	return &Handlers{Authenticator: authenticator, Secret: secret, Duration: tocookie.DefaultDuration, RememberDuration: tocookie.DefaultRememberDuration, Options: opts}
+}
+
+// credentials are the credentials of a login request. The names of Traffic Ops' login form, u and p, and their long forms, are both accepted. Remember opts into a remember-me series.
+type credentials struct {
+	U        string `json:"u"`
+	P        string `json:"p"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Remember bool   `json:"remember"`
+}
+
+// Login is the handler of POST requests to log in, with credentials in a JSON body, {"u": "user", "p": "password"}, as Traffic Ops takes them, or a form of the same fields. The fields username and password are accepted as well. On success, the session's cookie is set on the response, bound to the client given tocookie.WithClientBinding. Given Remember, a request with the field remember true, or a form value of 1, true, or on, also gets a remember-me cookie, for Remembered. Requests of other methods are answered with 405 Method Not Allowed, requests without credentials with 400 Bad Request, and wrong credentials with 401 Unauthorized. Users enrolled in TwoFactor are answered with 202 Accepted and a pre-auth cookie instead of their session's, for TwoFactorLogin.
+func (h *Handlers) Login(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		WriteAlert(w, http.StatusMethodNotAllowed, ErrorLevel, http.StatusText(http.StatusMethodNotAllowed))
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, MaxRequestSize)
+	creds, err := readCredentials(r)
+	if err != nil {
+		WriteAlert(w, http.StatusBadRequest, ErrorLevel, err.Error())
+		return
+	}
+	identity, err := h.Authenticator.Authenticate(r.Context(), creds.Username, creds.Password)
+	if err != nil {
+		if errors.Is(err, ErrInvalidCredentials) {
+			WriteAlert(w, http.StatusUnauthorized, ErrorLevel, "Invalid username or password.")
+			return
+		}
+		h.warnf("authenticating user '%v': %v", creds.Username, err)
+		WriteAlert(w, http.StatusInternalServerError, ErrorLevel, http.StatusText(http.StatusInternalServerError))
+		return
+	}
+	if h.TwoFactor != nil {
+		enrolled, err := h.TwoFactor.Enrolled(r.Context(), identity.Username)
+		if err != nil {
+			h.warnf("checking two-factor enrollment of user '%v': %v", identity.Username, err)
+			WriteAlert(w, http.StatusInternalServerError, ErrorLevel, http.StatusText(http.StatusInternalServerError))
+			return
+		}
+		if enrolled {
+			h.startPreAuth(w, r, identity, tocookie.AuthMethodPassword, creds.Remember)
+			return
+		}
+	}
+	if h.startSession(w, r, identity, authMethods(identity, tocookie.AuthMethodPassword)) == "" {
+		WriteAlert(w, http.StatusInternalServerError, ErrorLevel, http.StatusText(http.StatusInternalServerError))
+		return
+	}
+	if creds.Remember {
+		h.remember(w, identity)
+	}
+	WriteAlert(w, http.StatusOK, SuccessLevel, "Successfully logged in.")
+}
+
+// startSession mints the cookie of a new session of the identity, with the options added to Options, sets it on the response, and returns it. It returns "", having logged why, if it couldn't.
+func (h *Handlers) startSession(w http.ResponseWriter, r *http.Request, identity *Identity, opts ...tocookie.Option) string {
+	expiration := time.Now().Add(h.Duration)
+	opts = append(append(h.Options[:len(h.Options):len(h.Options)], opts...), tocookie.WithRoles(identity.Roles...), tocookie.WithCapabilities(identity.Capabilities...), tocookie.BindRequest(r, h.Options...), tocookie.AuditRequest(r, h.Options...))
+	cookie := tocookie.NewWithContext(r.Context(), identity.Username, expiration, h.Secret, opts...)
+	if cookie == "" {
+		h.warnf("minting cookie of user '%v' failed", identity.Username)
+		return ""
+	}
+	if err := tocookie.SetHTTPCookies(w, r, cookie, expiration, h.Options...); err != nil {
+		h.warnf("setting cookie of user '%v': %v", identity.Username, err)
+		return ""
+	}
+	return cookie
+}
+
+// authMethods returns the option of the AuthMethods of the identity, or of the method if it has none.
+func authMethods(identity *Identity, method string) tocookie.Option {
+	if len(identity.AuthMethods) == 0 {
+		return tocookie.WithAuthMethods(method)
+	}
+	return tocookie.WithAuthMethods(identity.AuthMethods...)
+}
+
+// readCredentials reads the credentials of a login request, as JSON or a form.
+func readCredentials(r *http.Request) (credentials, error) {
+	creds := credentials{}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "application/x-www-form-urlencoded", "multipart/form-data":
+		creds.U, creds.P = r.PostFormValue("u"), r.PostFormValue("p")
+		creds.Username, creds.Password = r.PostFormValue("username"), r.PostFormValue("password")
+		switch r.PostFormValue("remember") {
+		case "1", "true", "on":
+			creds.Remember = true
+		}
+	default:
+		if err := json.NewDecoder(r.Body).Decode(&creds); err != nil {
+			return creds, fmt.Errorf("malformed credentials: %w", err)
+		}
+	}
+	if creds.Username == "" {
+		creds.Username, creds.Password = creds.U, creds.P
+	}
+	if creds.Username == "" || creds.Password == "" {
+		return creds, errors.New("missing username or password")
+	}
+	return creds, nil
+}
+
+// Logout is the handler of POST requests to log out. The session's cookie is cleared from the browser and, given Revocations, the session is revoked. Given Remember, the remember-me cookie is cleared as well, and its series deleted, so the session isn't restored. Requests with a bearer token rather than a cookie are revoked likewise. Requests of other methods are answered with 405 Method Not Allowed, and requests without a valid session with 401 Unauthorized, though their cookie is still cleared.
+func (h *Handlers) Logout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		WriteAlert(w, http.StatusMethodNotAllowed, ErrorLevel, http.StatusText(http.StatusMethodNotAllowed))
+		return
+	}
+	tocookie.ClearHTTPCookies(w, r, h.Options...)
+	if !h.forget(w, r) {
+		WriteAlert(w, http.StatusInternalServerError, ErrorLevel, http.StatusText(http.StatusInternalServerError))
+		return
+	}
+
+	opts := append(h.Options[:len(h.Options):len(h.Options)], tocookie.AuditRequest(r, h.Options...))
+	var c *tocookie.Cookie
+	var err error
+	if cookie, cookieErr := tocookie.RequestCookie(r, h.Options...); cookieErr == nil {
+		c, err = tocookie.ParseContext(r.Context(), h.Secret, cookie, opts...)
+	} else {
+		c, err = tocookie.FromAuthHeader(r, h.Secret, opts...)
+	}
+	if err != nil {
+		tocookie.SetChallenge(w, tocookie.DefaultRealm, err)
+		WriteAlert(w, http.StatusUnauthorized, ErrorLevel, http.StatusText(http.StatusUnauthorized))
+		return
+	}
+	if h.Revocations != nil {
+		// refreshed copies of the cookie, e.g. of other tabs, expire no later than a Duration from now.
+		until := time.Now().Add(h.Duration)
+		if c.Expires().After(until) {
+			until = c.Expires()
+		}
+		if err := tocookie.RevokeSession(h.Revocations, c, until, "logout", opts...); err != nil {
+			h.warnf("revoking session of user '%v': %v", c.AuthData, err)
+			WriteAlert(w, http.StatusInternalServerError, ErrorLevel, http.StatusText(http.StatusInternalServerError))
+			return
+		}
+	}
+	WriteAlert(w, http.StatusOK, SuccessLevel, "You are logged out.")
+}
+
+func (h *Handlers) warnf(format string, v ...interface{}) {
+	if h.Logger != nil {
+		h.Logger.Warnf(format, v...)
+	}
+}
+
+// The levels of the alerts of WriteAlert, as Traffic Ops names them.
+const (
+	SuccessLevel = "success"
+	InfoLevel    = "info"
+	ErrorLevel   = "error"
+)
+
+type alert struct {
+	Level string `json:"level"`
+	Text  string `json:"text"`
+}
+
+type alerts struct {
+	Alerts []alert `json:"alerts"`
+}
+
+// WriteAlert writes a response of a single Traffic Ops alert, as the handlers of this package respond, for handlers which establish sessions in other ways, such as that of tocookie/oidc. It mustn't be cached, as it may carry a session cookie.
+func WriteAlert(w http.ResponseWriter, status int, level, text string) {
+	body, err := json.Marshal(alerts{Alerts: []alert{{Level: level, Text: text}}})
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	w.Write(body)
+}
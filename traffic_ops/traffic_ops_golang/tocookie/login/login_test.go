@@ -0,0 +1,153 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package login
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/tocookie"
+)
+
+var testAuthenticator = AuthenticatorFunc(func(ctx context.Context, username, password string) (*Identity, error) {
+	switch {
+	case username == "down":
+		return nil, errors.New("directory unavailable")
+	case username != "alice" || password != "hunter2":
+		return nil, ErrInvalidCredentials
+	}
+	return &Identity{Username: "alice", Roles: []string{"admin"}}, nil
+})
+
+func TestLogin(t *testing.T) {
+	secret := "secret"
+	h := New(testAuthenticator, secret)
+	form := url.Values{"u": {"alice"}, "p": {"hunter2"}}.Encode()
+
+	tests := map[string]struct {
+		method, contentType, body string
+		expected                  int
+	}{
+		"json":           {http.MethodPost, "application/json", `{"u":"alice","p":"hunter2"}`, http.StatusOK},
+		"long json":      {http.MethodPost, "application/json", `{"username":"alice","password":"hunter2"}`, http.StatusOK},
+		"form":           {http.MethodPost, "application/x-www-form-urlencoded", form, http.StatusOK},
+		"wrong password": {http.MethodPost, "application/json", `{"u":"alice","p":"wrong"}`, http.StatusUnauthorized},
+		"missing":        {http.MethodPost, "application/json", `{"u":"alice"}`, http.StatusBadRequest},
+		"malformed":      {http.MethodPost, "application/json", `{"u":`, http.StatusBadRequest},
+		"unavailable":    {http.MethodPost, "application/json", `{"u":"down","p":"x"}`, http.StatusInternalServerError},
+		"get":            {http.MethodGet, "", "", http.StatusMethodNotAllowed},
+	}
+	for name, test := range tests {
+		r := httptest.NewRequest(test.method, "/login", strings.NewReader(test.body))
+		r.Header.Set("Content-Type", test.contentType)
+		w := httptest.NewRecorder()
+		h.Login(w, r)
+		if w.Code != test.expected {
+			t.Errorf("%v: Login expected status %v, actual: %v %v", name, test.expected, w.Code, w.Body)
+		}
+		cookies := w.Result().Cookies()
+		if test.expected != http.StatusOK {
+			if len(cookies) != 0 {
+				t.Errorf("%v: Login expected no cookie, actual: %v", name, cookies)
+			}
+			continue
+		}
+		if len(cookies) != 1 || cookies[0].Name != tocookie.Name || !cookies[0].Secure || !cookies[0].HttpOnly {
+			t.Fatalf("%v: Login expected secure session cookie, actual: %v", name, cookies)
+		}
+		c, err := tocookie.Parse(secret, cookies[0].Value)
+		if err != nil || c.AuthData != "alice" || !c.HasRole("admin") {
+			t.Errorf("%v: Login expected cookie of alice with role admin, actual: %+v %v", name, c, err)
+		}
+		if err == nil && (!c.HasAuthMethod(tocookie.AuthMethodPassword) || c.AuthLevel() != tocookie.AuthLevelSingleFactor) {
+			t.Errorf("%v: Login expected single factor password authentication, actual: %v %v", name, c.AuthMethods, c.ACR)
+		}
+	}
+}
+
+func TestLogout(t *testing.T) {
+	secret := "secret"
+	store := tocookie.NewMemoryRevocationStore()
+	h := New(testAuthenticator, secret, tocookie.WithRevocationStore(store))
+	h.Revocations = store
+
+	r := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(`{"u":"alice","p":"hunter2"}`))
+	w := httptest.NewRecorder()
+	h.Login(w, r)
+	cookie := w.Result().Cookies()[0]
+
+	r = httptest.NewRequest(http.MethodPost, "/logout", nil)
+	r.AddCookie(cookie)
+	w = httptest.NewRecorder()
+	h.Logout(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("Logout expected status 200, actual: %v %v", w.Code, w.Body)
+	}
+	if cleared := w.Result().Cookies(); len(cleared) != 1 || cleared[0].Value != "" || cleared[0].MaxAge >= 0 {
+		t.Errorf("Logout expected cleared cookie, actual: %v", cleared)
+	}
+	if _, err := tocookie.Parse(secret, cookie.Value, tocookie.WithRevocationStore(store)); !errors.Is(err, tocookie.ErrRevoked) {
+		t.Errorf("Parse after Logout expected ErrRevoked, actual: %v", err)
+	}
+
+	w = httptest.NewRecorder()
+	h.Logout(w, r)
+	if w.Code != http.StatusUnauthorized || w.Header().Get("WWW-Authenticate") == "" {
+		t.Errorf("Logout of revoked session expected status 401 with challenge, actual: %v", w.Code)
+	}
+	if cleared := w.Result().Cookies(); len(cleared) != 1 || cleared[0].MaxAge >= 0 {
+		t.Errorf("Logout of revoked session expected cleared cookie, actual: %v", cleared)
+	}
+}
+
+type recordingSink []tocookie.AuditRecord
+
+func (s *recordingSink) Audit(record tocookie.AuditRecord) {
+	*s = append(*s, record)
+}
+
+func TestAudit(t *testing.T) {
+	secret := "secret"
+	store := tocookie.NewMemoryRevocationStore()
+	sink := &recordingSink{}
+	h := New(testAuthenticator, secret, tocookie.WithRevocationStore(store), tocookie.WithAuditSink(sink))
+	h.Revocations = store
+
+	r := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(`{"u":"alice","p":"hunter2"}`))
+	r.RemoteAddr = "192.0.2.1:1234"
+	w := httptest.NewRecorder()
+	h.Login(w, r)
+	r = httptest.NewRequest(http.MethodPost, "/logout", nil)
+	r.RemoteAddr = "192.0.2.1:1234"
+	r.AddCookie(w.Result().Cookies()[0])
+	h.Logout(httptest.NewRecorder(), r)
+	h.Logout(httptest.NewRecorder(), r)
+
+	expected := []struct {
+		event  tocookie.AuditEvent
+		reason string
+	}{{tocookie.AuditIssued, ""}, {tocookie.AuditRevoked, "logout"}, {tocookie.AuditRejected, "revoked"}}
+	if len(*sink) != len(expected) {
+		t.Fatalf("Login and Logout expected %v records, actual: %+v", len(expected), *sink)
+	}
+	for i, record := range *sink {
+		if record.Event != expected[i].event || record.Reason != expected[i].reason || record.ClientIP != "192.0.2.1" {
+			t.Errorf("record %v expected %v of 192.0.2.1 with reason '%v', actual: %+v", i, expected[i].event, expected[i].reason, record)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package login
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/tocookie"
+)
+
+// IdentityResolver is implemented by Authenticators which can look up users without their credentials, so sessions restored by remember-me cookies get the users' current roles and capabilities, rather than those they had when they logged in.
+type IdentityResolver interface {
+	// Identity returns the identity of the user, or an error wrapping ErrInvalidCredentials if the user no longer exists, or may no longer log in. The context is that of the request.
+	Identity(ctx context.Context, username string) (*Identity, error)
+}
+
+// Remembered returns a handler which, given Remember, restores the sessions of requests with a remember-me cookie and no session, e.g. of a browser restarted after its session's cookie expired, before calling next, which is usually tocookie.Middleware:
+//
+//	http.Handle("/", handlers.Remembered(tocookie.Middleware(secret, api, opts...)))
+//
+// The token of the remember-me cookie is rotated, and a new session of Duration, as Login starts, is set on the response and added to the request, so next sees it. The session's AuthTime is that of the login which started the series, so tocookie.WithMaxAuthAge makes users log in again however they are remembered. Requests whose remember-me cookie is invalid are passed to next unchanged, to be rejected as without it. A reused token clears the cookie, as its series, and every other series of the user, have been deleted as stolen.
+func (h *Handlers) Remembered(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.Remember != nil && !h.hasSession(r) {
+			h.restore(w, r)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// hasSession returns whether the request has a session's cookie or a bearer token, whether or not it is valid.
+func (h *Handlers) hasSession(r *http.Request) bool {
+	if _, err := tocookie.RequestCookie(r, h.Options...); err == nil {
+		return true
+	}
+	return r.Header.Get("Authorization") != ""
+}
+
+// restore restores the session of the request's remember-me cookie, if it has a valid one.
+func (h *Handlers) restore(w http.ResponseWriter, r *http.Request) {
+	remembered, err := r.Cookie(tocookie.RememberCookieName(h.Options...))
+	if err != nil {
+		return
+	}
+	resolver, ok := h.Authenticator.(IdentityResolver)
+	if !ok {
+		return
+	}
+	s, value, err := tocookie.UseRememberToken(h.Remember, remembered.Value)
+	switch {
+	case errors.Is(err, tocookie.ErrRememberTheft):
+		h.warnf("restoring session: %v", err)
+		tocookie.ClearRememberHTTPCookie(w, h.Options...)
+		return
+	case errors.Is(err, tocookie.ErrRememberInvalid):
+		// the cookie may have just been rotated by a concurrent request, whose new cookie clearing it could replace.
+		return
+	case err != nil:
+		h.warnf("restoring session: %v", err)
+		return
+	}
+	http.SetCookie(w, tocookie.RememberHTTPCookie(value, s.Expires, h.Options...))
+
+	identity, err := resolver.Identity(r.Context(), s.User)
+	if err != nil {
+		if !errors.Is(err, ErrInvalidCredentials) {
+			h.warnf("resolving remembered user '%v': %v", s.User, err)
+			return
+		}
+		if err := tocookie.ForgetRememberToken(h.Remember, value); err != nil {
+			h.warnf("forgetting series of remembered user '%v': %v", s.User, err)
+		}
+		tocookie.ClearRememberHTTPCookie(w, h.Options...)
+		return
+	}
+	if cookie := h.startSession(w, r, identity, tocookie.WithAuthTime(s.AuthTime), tocookie.WithAuthMethods(identity.AuthMethods...)); cookie != "" {
+		r.AddCookie(&http.Cookie{Name: tocookie.CookieName(h.Options...), Value: cookie})
+	}
+}
+
+// remember starts a remember-me series of the identity, and sets its cookie on the response. Failures are logged, and the login succeeds without it.
+func (h *Handlers) remember(w http.ResponseWriter, identity *Identity) {
+	if h.Remember == nil {
+		return
+	}
+	if _, ok := h.Authenticator.(IdentityResolver); !ok {
+		return
+	}
+	expiration := time.Now().Add(h.RememberDuration)
+	value, err := tocookie.NewRememberToken(h.Remember, identity.Username, expiration)
+	if err != nil {
+		h.warnf("remembering user '%v': %v", identity.Username, err)
+		return
+	}
+	http.SetCookie(w, tocookie.RememberHTTPCookie(value, expiration, h.Options...))
+}
+
+// forget clears the remember-me cookie of a logout request, if it has one, and deletes its series. It returns false, having logged why, if the series couldn't be deleted.
+func (h *Handlers) forget(w http.ResponseWriter, r *http.Request) bool {
+	if h.Remember == nil {
+		return true
+	}
+	remembered, err := r.Cookie(tocookie.RememberCookieName(h.Options...))
+	if err != nil {
+		return true
+	}
+	tocookie.ClearRememberHTTPCookie(w, h.Options...)
+	if err := tocookie.ForgetRememberToken(h.Remember, remembered.Value); err != nil {
+		h.warnf("forgetting remembered session: %v", err)
+		return false
+	}
+	return true
+}
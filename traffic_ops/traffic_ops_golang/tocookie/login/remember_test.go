@@ -0,0 +1,141 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package login
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/tocookie"
+)
+
+type testResolver struct {
+	AuthenticatorFunc
+}
+
+func (testResolver) Identity(ctx context.Context, username string) (*Identity, error) {
+	if username != "alice" {
+		return nil, ErrInvalidCredentials
+	}
+	return &Identity{Username: "alice", Roles: []string{"admin"}}, nil
+}
+
+// responseCookies returns the cookies set on the response, by name.
+func responseCookies(w *httptest.ResponseRecorder) map[string]*http.Cookie {
+	cookies := map[string]*http.Cookie{}
+	for _, c := range w.Result().Cookies() {
+		cookies[c.Name] = c
+	}
+	return cookies
+}
+
+func TestRemembered(t *testing.T) {
+	secret := "secret"
+	h := New(testResolver{testAuthenticator}, secret)
+	h.Remember = tocookie.NewMemoryRememberStore()
+	api := h.Remembered(tocookie.Middleware(secret, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, _ := tocookie.FromContext(r.Context())
+		w.Write([]byte(c.AuthData))
+	})))
+
+	login := func(body string) map[string]*http.Cookie {
+		w := httptest.NewRecorder()
+		h.Login(w, httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(body)))
+		return responseCookies(w)
+	}
+	if cookies := login(`{"u":"alice","p":"hunter2"}`); cookies[tocookie.RememberName] != nil {
+		t.Errorf("Login without remember expected no remember-me cookie, actual: %v", cookies[tocookie.RememberName])
+	}
+	remembered := login(`{"u":"alice","p":"hunter2","remember":true}`)[tocookie.RememberName]
+	if remembered == nil || !remembered.HttpOnly || remembered.Expires.IsZero() {
+		t.Fatalf("Login with remember expected persistent remember-me cookie, actual: %v", remembered)
+	}
+
+	get := func(cookies ...*http.Cookie) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		for _, c := range cookies {
+			r.AddCookie(c)
+		}
+		w := httptest.NewRecorder()
+		api.ServeHTTP(w, r)
+		return w
+	}
+	w := get(remembered)
+	cookies := responseCookies(w)
+	if w.Code != http.StatusOK || w.Body.String() != "alice" {
+		t.Fatalf("Remembered expected session of alice, actual: %v %v", w.Code, w.Body)
+	}
+	if c, err := tocookie.Parse(secret, cookies[tocookie.Name].Value); err != nil || !c.HasRole("admin") {
+		t.Errorf("Remembered expected session cookie with role admin, actual: %+v %v", c, err)
+	}
+	rotated := cookies[tocookie.RememberName]
+	if rotated == nil || rotated.Value == remembered.Value {
+		t.Fatalf("Remembered expected rotated remember-me cookie, actual: %v", rotated)
+	}
+
+	if w := get(cookies[tocookie.Name], rotated); len(w.Result().Cookies()) != 0 {
+		t.Errorf("Remembered of request with session expected no cookies, actual: %v", w.Result().Cookies())
+	}
+	next := responseCookies(get(rotated))[tocookie.RememberName]
+	if next == nil {
+		t.Fatalf("Remembered expected rotated remember-me cookie, actual none")
+	}
+
+	w = get(remembered)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Remembered of stolen token expected status 401, actual: %v", w.Code)
+	}
+	if cleared := responseCookies(w)[tocookie.RememberName]; cleared == nil || cleared.MaxAge >= 0 {
+		t.Errorf("Remembered of stolen token expected cleared remember-me cookie, actual: %v", cleared)
+	}
+	if w := get(next); w.Code != http.StatusUnauthorized {
+		t.Errorf("Remembered of series after theft expected status 401, actual: %v", w.Code)
+	}
+}
+
+func TestLogoutForgets(t *testing.T) {
+	secret := "secret"
+	h := New(testResolver{testAuthenticator}, secret)
+	h.Remember = tocookie.NewMemoryRememberStore()
+	w := httptest.NewRecorder()
+	h.Login(w, httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(`{"u":"alice","p":"hunter2","remember":true}`)))
+	cookies := responseCookies(w)
+
+	r := httptest.NewRequest(http.MethodPost, "/logout", nil)
+	r.AddCookie(cookies[tocookie.Name])
+	r.AddCookie(cookies[tocookie.RememberName])
+	w = httptest.NewRecorder()
+	h.Logout(w, r)
+	if cleared := responseCookies(w)[tocookie.RememberName]; w.Code != http.StatusOK || cleared == nil || cleared.MaxAge >= 0 {
+		t.Errorf("Logout expected status 200 with cleared remember-me cookie, actual: %v %v", w.Code, cleared)
+	}
+	if _, _, err := tocookie.UseRememberToken(h.Remember, cookies[tocookie.RememberName].Value); err == nil {
+		t.Errorf("UseRememberToken after Logout expected error, actual: nil")
+	}
+}
+
+func TestRememberWithoutResolver(t *testing.T) {
+	h := New(testAuthenticator, "secret")
+	h.Remember = tocookie.NewMemoryRememberStore()
+	form := "u=alice&p=hunter2&remember=on"
+	r := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(form))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	h.Login(w, r)
+	if cookies := responseCookies(w); w.Code != http.StatusOK || cookies[tocookie.RememberName] != nil {
+		t.Errorf("Login of Authenticator without IdentityResolver expected no remember-me cookie, actual: %v %v", w.Code, cookies)
+	}
+}
@@ -0,0 +1,140 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package login
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/tocookie"
+)
+
+// Sessions is the handler of the server-side sessions of users, for Traffic Portal's page of a user's signed-in devices, and for administrators. The request is authenticated by its own server-side session, minted by tocookie.NewServerSession, preferably WithSessionClient so the sessions can be told apart:
+//
+//   - GET lists the sessions of the user, most recently seen first, as the response of a Traffic Ops response object, with the session of the request marked current.
+//   - DELETE with the id query parameter of a session revokes it.
+//   - DELETE without it revokes every other session of the user, signing them out of their other devices.
+//
+// Given Admin, the user query parameter names another user, whose sessions are listed or revoked likewise; DELETE without an id then revokes all of them. Fields must not be modified once the handler is serving.
+type Sessions struct {
+	// Store holds the sessions.
+	Store tocookie.SessionLister
+	// Secret verifies the cookies of the sessions of requests.
+	Secret string
+	// Options are the options of parsing the sessions of requests, such as tocookie.WithRevocationStore, which listing honours too.
+	Options []tocookie.Option
+	// Admin, if not nil, returns whether the user of the session may manage the sessions of other users, e.g. by tocookie.Cookie.HasRole. Otherwise, users may only manage their own.
+	Admin func(c *tocookie.Cookie) bool
+	// Logger, if not nil, receives the errors of Store, which aren't revealed to clients.
+	Logger tocookie.Logger
+}
+
+// NewSessions returns the Sessions handler of the store and secret.
+func NewSessions(store tocookie.SessionLister, secret string, opts ...tocookie.Option) *Sessions {
+	return &Sessions{Store: store, Secret: secret, Options: opts}
+}
+
+// sessionsResponse is a Traffic Ops response object of sessions.
+type sessionsResponse struct {
+	Response []tocookie.SessionInfo `json:"response"`
+}
+
+// ServeHTTP implements http.Handler. Requests without a valid session are answered with 401 Unauthorized, requests for another user's sessions without Admin with 403 Forbidden, the revocation of a session the user doesn't have with 404 Not Found, and requests of other methods with 405 Method Not Allowed.
+func (s *Sessions) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodDelete {
+		w.Header().Set("Allow", http.MethodGet+", "+http.MethodDelete)
+		WriteAlert(w, http.StatusMethodNotAllowed, ErrorLevel, http.StatusText(http.StatusMethodNotAllowed))
+		return
+	}
+	cookie, err := tocookie.RequestCookie(r, s.Options...)
+	var c *tocookie.Cookie
+	if err == nil {
+		c, err = tocookie.ParseServerSessionContext(r.Context(), s.Store, s.Secret, cookie, s.Options...)
+	}
+	if err != nil {
+		tocookie.SetChallenge(w, tocookie.DefaultRealm, err)
+		WriteAlert(w, http.StatusUnauthorized, ErrorLevel, http.StatusText(http.StatusUnauthorized))
+		return
+	}
+	user := r.URL.Query().Get("user")
+	if user == "" {
+		user = c.AuthData
+	}
+	if user != c.AuthData && (s.Admin == nil || !s.Admin(c)) {
+		WriteAlert(w, http.StatusForbidden, ErrorLevel, http.StatusText(http.StatusForbidden))
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		s.list(w, c, user)
+		return
+	}
+	if id := r.URL.Query().Get("id"); id != "" {
+		s.revoke(w, user, id)
+		return
+	}
+	except := ""
+	if user == c.AuthData {
+		except = c.SessionID
+	}
+	n, err := tocookie.RevokeUserSessions(s.Store, user, except)
+	if err != nil {
+		s.warnf("revoking sessions of user '%v': %v", user, err)
+		WriteAlert(w, http.StatusInternalServerError, ErrorLevel, http.StatusText(http.StatusInternalServerError))
+		return
+	}
+	WriteAlert(w, http.StatusOK, SuccessLevel, fmt.Sprintf("Revoked %d sessions.", n))
+}
+
+// list writes the sessions of the user, marking that of the request's claims current.
+func (s *Sessions) list(w http.ResponseWriter, c *tocookie.Cookie, user string) {
+	sessions, err := tocookie.ListSessions(s.Store, user, s.Options...)
+	if err != nil {
+		s.warnf("listing sessions of user '%v': %v", user, err)
+		WriteAlert(w, http.StatusInternalServerError, ErrorLevel, http.StatusText(http.StatusInternalServerError))
+		return
+	}
+	for i := range sessions {
+		sessions[i].Current = c.SessionID != "" && sessions[i].ID == c.SessionID
+	}
+	body, err := json.Marshal(sessionsResponse{Response: sessions})
+	if err != nil {
+		WriteAlert(w, http.StatusInternalServerError, ErrorLevel, http.StatusText(http.StatusInternalServerError))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(body)
+}
+
+// revoke revokes the session of the user with the ID.
+func (s *Sessions) revoke(w http.ResponseWriter, user, id string) {
+	if err := tocookie.RevokeUserSession(s.Store, user, id); err != nil {
+		if errors.Is(err, tocookie.ErrSessionNotFound) {
+			WriteAlert(w, http.StatusNotFound, ErrorLevel, "No such session.")
+			return
+		}
+		s.warnf("revoking session of user '%v': %v", user, err)
+		WriteAlert(w, http.StatusInternalServerError, ErrorLevel, http.StatusText(http.StatusInternalServerError))
+		return
+	}
+	WriteAlert(w, http.StatusOK, SuccessLevel, "Session revoked.")
+}
+
+func (s *Sessions) warnf(format string, v ...interface{}) {
+	if s.Logger != nil {
+		s.Logger.Warnf(format, v...)
+	}
+}
@@ -0,0 +1,121 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package login
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/tocookie"
+)
+
+func TestSessions(t *testing.T) {
+	secret := "secret"
+	store := tocookie.NewMemorySessionStore()
+	expires := time.Now().Add(time.Hour)
+	alice, _ := tocookie.NewServerSession(store, "alice", expires, secret, tocookie.WithRoles("admin"))
+	other, _ := tocookie.NewServerSession(store, "alice", expires, secret)
+	bob, _ := tocookie.NewServerSession(store, "bob", expires, secret)
+	newSession := func(user string) string {
+		c, _ := tocookie.NewServerSession(store, user, expires, secret)
+		return c
+	}
+	h := NewSessions(store, secret)
+	serve := func(method, target, cookie string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, target, nil)
+		if cookie != "" {
+			r.AddCookie(&http.Cookie{Name: tocookie.Name, Value: cookie})
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	w := serve(http.MethodGet, "/user/sessions", alice)
+	listed := sessionsResponse{}
+	if err := json.Unmarshal(w.Body.Bytes(), &listed); w.Code != http.StatusOK || err != nil || len(listed.Response) != 2 {
+		t.Fatalf("GET expected the 2 sessions of alice, actual: %v %v %v", w.Code, w.Body.String(), err)
+	}
+	current := 0
+	for _, session := range listed.Response {
+		if session.User != "alice" {
+			t.Errorf("GET expected sessions of alice, actual: %+v", session)
+		}
+		if session.Current {
+			current++
+		}
+	}
+	if current != 1 {
+		t.Errorf("GET expected 1 current session, actual: %v", current)
+	}
+
+	tests := map[string]struct {
+		method, target, cookie string
+		expected               int
+	}{
+		"no session":        {http.MethodGet, "/user/sessions", "", http.StatusUnauthorized},
+		"forged":            {http.MethodGet, "/user/sessions", tocookie.New("alice", expires, secret), http.StatusUnauthorized},
+		"another user":      {http.MethodGet, "/user/sessions?user=alice", bob, http.StatusForbidden},
+		"not admin":         {http.MethodGet, "/user/sessions?user=bob", alice, http.StatusForbidden},
+		"unknown session":   {http.MethodDelete, "/user/sessions?id=nope", alice, http.StatusNotFound},
+		"other's session":   {http.MethodDelete, "/user/sessions?id=" + sessionID(t, store, secret, bob), alice, http.StatusNotFound},
+		"post":              {http.MethodPost, "/user/sessions", alice, http.StatusMethodNotAllowed},
+		"own other session": {http.MethodDelete, "/user/sessions?id=" + sessionID(t, store, secret, other), alice, http.StatusOK},
+	}
+	for name, test := range tests {
+		if w := serve(test.method, test.target, test.cookie); w.Code != test.expected {
+			t.Errorf("%v: expected %v, actual: %v %v", name, test.expected, w.Code, w.Body.String())
+		}
+	}
+	if w := serve(http.MethodGet, "/user/sessions", other); w.Code != http.StatusUnauthorized {
+		t.Errorf("GET with revoked session expected 401, actual: %v", w.Code)
+	}
+	if w := serve(http.MethodPost, "/user/sessions", alice); w.Header().Get("Allow") != "GET, DELETE" {
+		t.Errorf("POST expected Allow 'GET, DELETE', actual: '%v'", w.Header().Get("Allow"))
+	}
+
+	newSession("alice")
+	newSession("alice")
+	if w := serve(http.MethodDelete, "/user/sessions", alice); w.Code != http.StatusOK {
+		t.Errorf("DELETE expected 200, actual: %v %v", w.Code, w.Body.String())
+	}
+	if sessions, _ := store.Sessions("alice"); len(sessions) != 1 {
+		t.Errorf("DELETE expected only the current session kept, actual: %v", sessions)
+	}
+
+	h.Admin = func(c *tocookie.Cookie) bool { return c.HasRole("admin") }
+	if w := serve(http.MethodGet, "/user/sessions?user=bob", alice); w.Code != http.StatusOK {
+		t.Errorf("GET of another user by an admin expected 200, actual: %v %v", w.Code, w.Body.String())
+	}
+	if w := serve(http.MethodGet, "/user/sessions?user=alice", bob); w.Code != http.StatusForbidden {
+		t.Errorf("GET of another user by a non-admin expected 403, actual: %v", w.Code)
+	}
+	if w := serve(http.MethodDelete, "/user/sessions?user=bob", alice); w.Code != http.StatusOK {
+		t.Errorf("DELETE of another user by an admin expected 200, actual: %v %v", w.Code, w.Body.String())
+	}
+	if w := serve(http.MethodGet, "/user/sessions", bob); w.Code != http.StatusUnauthorized {
+		t.Errorf("GET with session revoked by an admin expected 401, actual: %v", w.Code)
+	}
+}
+
+// sessionID returns the SessionID of the server-side session of the cookie.
+func sessionID(t *testing.T, store tocookie.SessionStore, secret, cookie string) string {
+	c, err := tocookie.ParseServerSession(store, secret, cookie)
+	if err != nil {
+		t.Fatalf("ParseServerSession expected nil error, actual: %v", err)
+	}
+	return c.SessionID
+}
@@ -0,0 +1,151 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package login
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/tocookie"
+)
+
+// TwoFactor checks the second factor of users who have enrolled in one, for Login and TwoFactorLogin. Implementations must be safe for concurrent use. The Checker of package totp is a TwoFactor of time-based one-time passwords.
+type TwoFactor interface {
+	// Enrolled returns whether the user must give a second factor to log in. The context is that of the login request.
+	Enrolled(ctx context.Context, username string) (bool, error)
+	// Check returns nil if the code is a valid second factor of the user, or an error wrapping ErrInvalidCredentials if it isn't. The context is that of the request.
+	Check(ctx context.Context, username, code string) error
+}
+
+// TwoFactorDuration is how long the pre-auth cookies of Login last, for users to give their second factor to TwoFactorLogin.
+const TwoFactorDuration = 5 * time.Minute
+
+// preAuthAudience is the audience of pre-auth cookies, which TwoFactorLogin requires.
+const preAuthAudience = "tocookie/login two-factor"
+
+// preAuthKeyInfo is what the key of pre-auth cookies is derived from Secret with, so they can't be passed off as the cookies of sessions.
+const preAuthKeyInfo = "tocookie/login pre-auth key v1"
+
+// rememberClaim is the claim of pre-auth cookies of logins which asked to be remembered.
+const rememberClaim = "remember"
+
+// TwoFactorCookieName returns the name of the pre-auth cookie of the options: that of the session's cookie, as tocookie.CookieName returns it, with the suffix "_2fa".
+func TwoFactorCookieName(opts ...tocookie.Option) string {
+	return tocookie.CookieName(opts...) + "_2fa"
+}
+
+// preAuthKey returns the key pre-auth cookies are signed with: the HMAC-SHA256 of preAuthKeyInfo keyed by the secret.
+func preAuthKey(secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(preAuthKeyInfo))
+	return base64.RawStdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// startPreAuth sets the pre-auth cookie of a user who passed their first factor, and must give their second to TwoFactorLogin before TwoFactorDuration is up.
+func (h *Handlers) startPreAuth(w http.ResponseWriter, r *http.Request, identity *Identity, method string, remember bool) {
+	expiration := time.Now().Add(TwoFactorDuration)
+	opts := append(h.Options[:len(h.Options):len(h.Options)], tocookie.WithRoles(identity.Roles...), tocookie.WithCapabilities(identity.Capabilities...), authMethods(identity, method), tocookie.BindRequest(r, h.Options...), tocookie.WithAudience(preAuthAudience))
+	if remember {
+		opts = append(opts, tocookie.WithClaims(map[string]interface{}{rememberClaim: true}))
+	}
+	cookie := tocookie.NewWithContext(r.Context(), identity.Username, expiration, preAuthKey(h.Secret), opts...)
+	if cookie == "" {
+		h.warnf("minting pre-auth cookie of user '%v' failed", identity.Username)
+		WriteAlert(w, http.StatusInternalServerError, ErrorLevel, http.StatusText(http.StatusInternalServerError))
+		return
+	}
+	http.SetCookie(w, tocookie.HTTPCookie(cookie, expiration, h.twoFactorOptions()...))
+	WriteAlert(w, http.StatusAccepted, InfoLevel, "Two-factor code required.")
+}
+
+// twoFactorOptions returns Options with the name of the pre-auth cookie.
+func (h *Handlers) twoFactorOptions() []tocookie.Option {
+	return append(h.Options[:len(h.Options):len(h.Options)], tocookie.WithCookieName(TwoFactorCookieName(h.Options...)))
+}
+
+// TwoFactorLogin is the handler of POST requests giving the second factor of a login, in a JSON body, {"code": "123456"}, or a form of the same field, for users whose login was answered with 202 Accepted and a pre-auth cookie, as Login answers users enrolled in TwoFactor. If the code is valid for the user of the pre-auth cookie, the pre-auth cookie is cleared, and the session's cookie set, as Login sets it, with the methods of both factors, so tocookie.RequireAuthLevel sees a multi-factor authentication; a login which asked to be remembered gets its remember-me cookie. Requests of other methods are answered with 405 Method Not Allowed, those without a code with 400 Bad Request, and those without a valid pre-auth cookie, or with a wrong code, with 401 Unauthorized.
+//
+// The pre-auth cookie is signed with a key derived from Secret, so it isn't accepted by tocookie.Middleware, and lasts TwoFactorDuration. TwoFactor must limit wrong codes, as the cookie can be reused until it expires.
+func (h *Handlers) TwoFactorLogin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		WriteAlert(w, http.StatusMethodNotAllowed, ErrorLevel, http.StatusText(http.StatusMethodNotAllowed))
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, MaxRequestSize)
+	code, err := readCode(r)
+	if err != nil || code == "" {
+		WriteAlert(w, http.StatusBadRequest, ErrorLevel, "Missing two-factor code.")
+		return
+	}
+	c, err := h.preAuth(r)
+	if err != nil {
+		WriteAlert(w, http.StatusUnauthorized, ErrorLevel, "Two-factor login expired or invalid; log in again.")
+		return
+	}
+	if err := h.TwoFactor.Check(r.Context(), c.AuthData, code); err != nil {
+		if errors.Is(err, ErrInvalidCredentials) {
+			WriteAlert(w, http.StatusUnauthorized, ErrorLevel, "Invalid two-factor code.")
+			return
+		}
+		h.warnf("checking two-factor code of user '%v': %v", c.AuthData, err)
+		WriteAlert(w, http.StatusInternalServerError, ErrorLevel, http.StatusText(http.StatusInternalServerError))
+		return
+	}
+	tocookie.ClearHTTPCookies(w, nil, h.twoFactorOptions()...)
+	identity := &Identity{Username: c.AuthData, Roles: c.Roles, Capabilities: c.Capabilities, AuthMethods: append(c.AuthMethods, tocookie.AuthMethodTOTP)}
+	if h.startSession(w, r, identity, tocookie.WithAuthMethods(identity.AuthMethods...)) == "" {
+		WriteAlert(w, http.StatusInternalServerError, ErrorLevel, http.StatusText(http.StatusInternalServerError))
+		return
+	}
+	if remember, _ := c.ClaimBool(rememberClaim); remember {
+		h.remember(w, identity)
+	}
+	WriteAlert(w, http.StatusOK, SuccessLevel, "Successfully logged in.")
+}
+
+// preAuth returns the verified pre-auth cookie of the request. TwoFactor must be set.
+func (h *Handlers) preAuth(r *http.Request) (*tocookie.Cookie, error) {
+	if h.TwoFactor == nil {
+		return nil, errors.New("two-factor authentication isn't enabled")
+	}
+	opts := h.twoFactorOptions()
+	cookie, err := tocookie.RequestCookie(r, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return tocookie.ParseContext(r.Context(), preAuthKey(h.Secret), cookie, append(opts, tocookie.WithAudience(preAuthAudience))...)
+}
+
+// readCode reads the second factor of a TwoFactorLogin request, as JSON or a form.
+func readCode(r *http.Request) (string, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "application/x-www-form-urlencoded", "multipart/form-data":
+		return r.PostFormValue("code"), nil
+	}
+	body := struct {
+		Code string `json:"code"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		return "", err
+	}
+	return body.Code, nil
+}
@@ -0,0 +1,105 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package login
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/tocookie"
+)
+
+// testTwoFactor requires the code 123456 of alice, and of no one else.
+type testTwoFactor struct{}
+
+func (testTwoFactor) Enrolled(ctx context.Context, username string) (bool, error) {
+	return username == "alice", nil
+}
+
+func (testTwoFactor) Check(ctx context.Context, username, code string) error {
+	switch {
+	case code == "down":
+		return errors.New("store unavailable")
+	case username != "alice" || code != "123456":
+		return ErrInvalidCredentials
+	}
+	return nil
+}
+
+func TestTwoFactorLogin(t *testing.T) {
+	secret := "secret"
+	h := New(testResolver{testAuthenticator}, secret)
+	h.TwoFactor = testTwoFactor{}
+	h.Remember = tocookie.NewMemoryRememberStore()
+
+	w := httptest.NewRecorder()
+	h.Login(w, httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(`{"u":"alice","p":"hunter2","remember":true}`)))
+	cookies := responseCookies(w)
+	preAuth := cookies[TwoFactorCookieName()]
+	if w.Code != http.StatusAccepted || preAuth == nil || cookies[tocookie.Name] != nil || cookies[tocookie.RememberName] != nil {
+		t.Fatalf("Login of enrolled user expected %v with only a pre-auth cookie, actual: %v %v", http.StatusAccepted, w.Code, cookies)
+	}
+	if _, err := tocookie.Parse(secret, preAuth.Value); !errors.Is(err, tocookie.ErrBadSignature) {
+		t.Errorf("Parse of pre-auth cookie with the secret expected ErrBadSignature, actual: %v", err)
+	}
+
+	twoFactor := func(body string, withCookie bool) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/login/2fa", strings.NewReader(body))
+		if withCookie {
+			r.AddCookie(&http.Cookie{Name: preAuth.Name, Value: preAuth.Value})
+		}
+		w := httptest.NewRecorder()
+		h.TwoFactorLogin(w, r)
+		return w
+	}
+	tests := map[string]struct {
+		body       string
+		withCookie bool
+		expected   int
+	}{
+		"wrong code":  {`{"code":"654321"}`, true, http.StatusUnauthorized},
+		"no cookie":   {`{"code":"123456"}`, false, http.StatusUnauthorized},
+		"no code":     {`{}`, true, http.StatusBadRequest},
+		"unavailable": {`{"code":"down"}`, true, http.StatusInternalServerError},
+	}
+	for name, test := range tests {
+		if w := twoFactor(test.body, test.withCookie); w.Code != test.expected || responseCookies(w)[tocookie.Name] != nil {
+			t.Errorf("%v: TwoFactorLogin expected %v without a session, actual: %v %v", name, test.expected, w.Code, w.Body)
+		}
+	}
+
+	w = twoFactor(`{"code":"123456"}`, true)
+	cookies = responseCookies(w)
+	if w.Code != http.StatusOK || cookies[tocookie.Name] == nil || cookies[tocookie.RememberName] == nil || cookies[preAuth.Name] == nil || cookies[preAuth.Name].MaxAge >= 0 {
+		t.Fatalf("TwoFactorLogin expected %v with a session and remember-me cookie, and the pre-auth cookie cleared, actual: %v %v", http.StatusOK, w.Code, cookies)
+	}
+	c, err := tocookie.Parse(secret, cookies[tocookie.Name].Value)
+	if err != nil || c.AuthData != "alice" || !c.HasRole("admin") || c.AuthLevel() != tocookie.AuthLevelMultiFactor || !c.HasAuthMethod(tocookie.AuthMethodTOTP) {
+		t.Errorf("TwoFactorLogin expected multi-factor session of alice with role admin, actual: %+v %v", c, err)
+	}
+
+	w = httptest.NewRecorder()
+	h.Login(w, httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(`{"u":"alice","p":"wrong"}`)))
+	if w.Code != http.StatusUnauthorized || len(w.Result().Cookies()) != 0 {
+		t.Errorf("Login of enrolled user with wrong password expected %v without cookies, actual: %v %v", http.StatusUnauthorized, w.Code, w.Result().Cookies())
+	}
+	w = httptest.NewRecorder()
+	h.TwoFactorLogin(w, httptest.NewRequest(http.MethodGet, "/login/2fa", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("TwoFactorLogin of get expected %v, actual: %v", http.StatusMethodNotAllowed, w.Code)
+	}
+}
@@ -0,0 +1,74 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// Manager mints and parses cookies with a secret which may be swapped at runtime, e.g. by WatchKeyFile, without restarting the service. It is safe for concurrent use.
+type Manager struct {
+	secret atomic.Value // string
+	opts   []Option
+	o      *options
+}
+
+// NewManager returns a Manager which mints and parses cookies with the given secret and options. The options are applied to every cookie, before any options given to the Manager's methods.
+func NewManager(secret string, opts ...Option) *Manager {
+	m := &Manager{opts: opts, o: newOptions(opts)}
+	m.secret.Store(secret)
+	return m
+}
+
+// Secret returns the active secret.
+func (m *Manager) Secret() string {
+	return m.secret.Load().(string)
+}
+
+// SetSecret atomically replaces the active secret. Cookies signed with the previous secret no longer parse.
+func (m *Manager) SetSecret(secret string) {
+	m.secret.Store(secret)
+}
+
+// New mints a cookie with the active secret, like the package-level New.
+func (m *Manager) New(user string, expiration time.Time, opts ...Option) string {
+	return New(user, expiration, m.Secret(), m.options(opts)...)
+}
+
+// NewWithContext mints a cookie with the active secret, like the package-level NewWithContext.
+func (m *Manager) NewWithContext(ctx context.Context, user string, expiration time.Time, opts ...Option) string {
+	return NewWithContext(ctx, user, expiration, m.Secret(), m.options(opts)...)
+}
+
+// Parse parses a cookie with the active secret, like the package-level Parse.
+func (m *Manager) Parse(cookie string, opts ...Option) (*Cookie, error) {
+	return Parse(m.Secret(), cookie, m.options(opts)...)
+}
+
+// Refresh re-issues a cookie with the active secret, like the package-level Refresh.
+func (m *Manager) Refresh(c *Cookie, opts ...Option) string {
+	return Refresh(c, m.Secret(), m.options(opts)...)
+}
+
+// RefreshContext re-issues a cookie with the active secret, like the package-level RefreshContext.
+func (m *Manager) RefreshContext(ctx context.Context, c *Cookie, opts ...Option) string {
+	return RefreshContext(ctx, c, m.Secret(), m.options(opts)...)
+}
+
+// options returns the Manager's options followed by opts, without modifying either.
+func (m *Manager) options(opts []Option) []Option {
+	all := make([]Option, 0, len(m.opts)+len(opts))
+	return append(append(all, m.opts...), opts...)
+}
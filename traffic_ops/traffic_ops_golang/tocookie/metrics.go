@@ -0,0 +1,82 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"errors"
+	"time"
+)
+
+// Metrics receives instrumentation of cookie operations, given WithMetrics, e.g. to alert on spikes in signature failures, which usually mean a secret mismatch after a deploy. Implementations must be fast, and safe for concurrent use. The Prometheus implementation is in tocookie/prommetrics.
+type Metrics interface {
+	// Issued is called for every cookie minted by New, including through NewHTTPCookie, NewWithKeyRing, and Manager.New.
+	Issued()
+	// Refreshed is called for every cookie re-issued by Refresh, including through RefreshIfNeeded and Middleware.
+	Refreshed()
+	// Parsed is called for every cookie parsed by Parse and the other functions which verify cookies, with its outcome, the FailureReason of its error, and how long parsing took, including any hooks.
+	Parsed(outcome Outcome, reason string, elapsed time.Duration)
+}
+
+// WithMetrics makes New, Refresh, and Parse report to the metrics. By default, nothing is measured, and Parse doesn't read the clock for it.
+func WithMetrics(metrics Metrics) Option {
+	return func(o *options) { o.metrics = metrics }
+}
+
+// failureReasons are the reasons of FailureReason, in the order they are matched, so an error wrapping several, e.g. of WithAllErrors, has the reason of the first.
+var failureReasons = []struct {
+	err    error
+	reason string
+}{
+	{ErrBadSignature, "bad_signature"},
+	{ErrMalformed, "malformed"},
+	{ErrVersionNotAccepted, "version_not_accepted"},
+	{ErrUnknownKey, "unknown_key"},
+	{ErrKeyNotValid, "key_not_valid"},
+	{ErrUnknownIssuer, "unknown_issuer"},
+	{ErrRevoked, "revoked"},
+	{ErrSessionNotFound, "session_not_found"},
+	{ErrSessionReused, "session_reused"},
+	{ErrReplayed, "replayed"},
+	{ErrActionMismatch, "action_mismatch"},
+	{ErrNotYetValid, "not_yet_valid"},
+	{ErrAudienceMismatch, "audience_mismatch"},
+	{ErrIssuerMismatch, "issuer_mismatch"},
+	{ErrFingerprintMismatch, "fingerprint_mismatch"},
+	{ErrSessionTooOld, "session_too_old"},
+	{ErrReauthRequired, "reauth_required"},
+	{ErrClaimsTooLarge, "claims_too_large"},
+	{ErrClaimInvalid, "claim_invalid"},
+	{ErrHookRejected, "hook_rejected"},
+	{ErrExpired, "expired"},
+}
+
+// FailureReason returns a short, stable label of why the error returned by Parse rejected the cookie, for metrics: "expired", "bad_signature", "malformed", "revoked", and so on, for each error of this package. It is empty for nil, and "other" for errors of no reason, such as an unavailable hash.
+func FailureReason(err error) string {
+	if err == nil {
+		return ""
+	}
+	for _, r := range failureReasons {
+		if errors.Is(err, r.err) {
+			return r.reason
+		}
+	}
+	return "other"
+}
+
+// startTimer returns the time a parse began, for the latency of WithMetrics. It is the zero time without metrics, so parsing doesn't read the clock for nothing.
+func (o *options) startTimer() time.Time {
+	if o.metrics == nil {
+		return time.Time{}
+	}
+	return time.Now()
+}
@@ -0,0 +1,74 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+type testMetrics struct {
+	issued, refreshed int
+	reasons           []string
+}
+
+func (m *testMetrics) Issued()    { m.issued++ }
+func (m *testMetrics) Refreshed() { m.refreshed++ }
+func (m *testMetrics) Parsed(outcome Outcome, reason string, elapsed time.Duration) {
+	m.reasons = append(m.reasons, outcome.String()+":"+reason)
+}
+
+func TestWithMetrics(t *testing.T) {
+	secret := "secret"
+	m := &testMetrics{}
+	cookie := New("alice", time.Now().Add(time.Minute), secret, WithMetrics(m))
+	c, err := Parse(secret, cookie, WithMetrics(m))
+	if err != nil {
+		t.Fatalf("Parse expected nil error, actual: %v", err)
+	}
+	Refresh(c, secret, WithMetrics(m))
+	Parse("wrong", cookie, WithMetrics(m))
+	Parse(secret, New("alice", time.Now().Add(-time.Minute), secret), WithMetrics(m))
+
+	if m.issued != 1 || m.refreshed != 1 {
+		t.Errorf("WithMetrics expected 1 issued and 1 refreshed, actual: %v %v", m.issued, m.refreshed)
+	}
+	expected := fmt.Sprint([]string{"valid:", "invalid:bad_signature", "expired:expired"})
+	if actual := fmt.Sprint(m.reasons); actual != expected {
+		t.Errorf("WithMetrics expected parses %v, actual: %v", expected, actual)
+	}
+}
+
+func TestFailureReason(t *testing.T) {
+	tests := map[string]struct {
+		err      error
+		expected string
+	}{
+		"nil":           {nil, ""},
+		"expired":       {&ExpiredError{}, "expired"},
+		"bad signature": {ErrBadSignature, "bad_signature"},
+		"malformed":     {fmt.Errorf("%w: bad base64", ErrMalformed), "malformed"},
+		"revoked":       {ErrRevoked, "revoked"},
+		"version":       {fmt.Errorf("%w: version 0", ErrVersionNotAccepted), "version_not_accepted"},
+		"hook":          {fmt.Errorf("%w: %w", ErrHookRejected, ErrUserInactive), "hook_rejected"},
+		"joined":        {errors.Join(&ExpiredError{}, ErrAudienceMismatch), "audience_mismatch"},
+		"other":         {errors.New("hash unavailable"), "other"},
+	}
+	for name, test := range tests {
+		if actual := FailureReason(test.err); actual != test.expected {
+			t.Errorf("%v: FailureReason expected '%v', actual: '%v'", name, test.expected, actual)
+		}
+	}
+}
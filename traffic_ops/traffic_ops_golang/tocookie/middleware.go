@@ -0,0 +1,97 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// WithRefreshWindow makes Middleware refresh cookies which expire within the window, e.g. DefaultRefreshWindow, setting the refreshed cookie on the response. By default, Middleware doesn't refresh cookies.
+func WithRefreshWindow(window time.Duration) Option {
+	return func(o *options) { o.refreshWindow = window }
+}
+
+// Middleware returns a handler which authenticates requests with the secret and options, as ParseContext does with the context of the request, before passing them to next. The cookie named Name, or the chunks of WithChunking, are used, or, for requests without either, the bearer token of the Authorization header; WithExtractors sets other ways of finding tokens, such as a query parameter of WebSocket upgrades. Authenticated requests are passed to next with the parsed cookie in their context; see FromContext. Other requests are answered with 401 Unauthorized and a WWW-Authenticate challenge, and aren't passed to next.
+//
+// Given WithRefreshWindow, cookies about to expire are refreshed with RefreshIfNeededContext, and the refreshed cookie is set on the response, as a session cookie with the attributes of NewHTTPCookie, chunked as by SetHTTPCookies. Bearer tokens, and the tokens of extractors other than CookieExtractor, are never refreshed, as clients which send them don't read cookies.
+//
+// Given WithExpiryGrace, Stale cookies are always refreshed, with RefreshContext, though Stale bearer tokens are only accepted.
+//
+// Given WithVersionPolicy, cookies of older versions than the policy mints are refreshed in that version, so clients are upgraded to it as they return.
+//
+// Given WithRefreshQueue, cookies are refreshed in the background, and set on a later response of their session.
+//
+// Given WithClientBinding, cookies presented by clients other than those they were minted for are rejected.
+//
+// Given WithFailureLimit, clients which present too many forged or malformed cookies are rejected, or tarpitted, before their cookies are parsed.
+//
+// Given WithAuditSink, the rejected and refreshed cookies of requests are audited with the clients of the requests, as by AuditRequest. Requests without a token aren't audited.
+func Middleware(secret string, next http.Handler, opts ...Option) http.Handler {
+	o := newOptions(opts)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client := ""
+		if o.failureLimit != nil {
+			if client = o.failureLimit.client(r, o); !o.failureLimit.admit(w, r, client, o) {
+				return
+			}
+		}
+		token, fromCookie, err := o.requestToken(r)
+		if err == nil {
+			opts := opts
+			if o.binding != nil {
+				opts = append(opts[:len(opts):len(opts)], WithFingerprint(o.binding.Fingerprint(r)))
+			}
+			if o.auditSink != nil {
+				opts = append(opts[:len(opts):len(opts)], AuditRequest(r, opts...))
+			}
+			var c *Cookie
+			if c, err = ParseContext(r.Context(), secret, token, opts...); err == nil {
+				upgrade := o.versionPolicy != nil && o.versionPolicy.NeedsUpgrade(token)
+				if fromCookie && !c.Stale && o.refreshQueue != nil && o.rotation == nil && (upgrade || o.refreshWindow > 0) {
+					o.refreshQueue.refreshLater(w, r, c, token, secret, upgrade, opts, o)
+				} else if fromCookie && (c.Stale || upgrade) {
+					if refreshed := RefreshContext(r.Context(), c, secret, opts...); refreshed != "" {
+						o.setHTTPCookies(w, r, refreshed, time.Time{})
+					}
+				} else if fromCookie && o.refreshWindow > 0 {
+					if refreshed, err := RefreshIfNeededContext(r.Context(), c, secret, o.refreshWindow, opts...); err == nil && refreshed != "" {
+						o.setHTTPCookies(w, r, refreshed, time.Time{})
+					}
+				}
+				next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), c)))
+				return
+			}
+		}
+		if o.failureLimit != nil {
+			o.failureLimit.failed(client, err, o)
+		}
+		SetChallenge(w, DefaultRealm, err)
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+	})
+}
+
+// contextKey is the key of the cookie in request contexts.
+type contextKey struct{}
+
+// NewContext returns a copy of the context which carries the authenticated cookie.
+func NewContext(ctx context.Context, c *Cookie) context.Context {
+	return context.WithValue(ctx, contextKey{}, c)
+}
+
+// FromContext returns the authenticated cookie of a request passed on by Middleware, and whether there is one.
+func FromContext(ctx context.Context) (*Cookie, bool) {
+	c, ok := ctx.Value(contextKey{}).(*Cookie)
+	return c, ok
+}
@@ -0,0 +1,93 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMiddleware(t *testing.T) {
+	secret := "secret"
+	handler := func(opts ...Option) http.Handler {
+		return Middleware(secret, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			c, ok := FromContext(r.Context())
+			if !ok {
+				t.Errorf("Middleware expected cookie in context, actual none")
+				return
+			}
+			w.Write([]byte(c.AuthData))
+		}), opts...)
+	}
+
+	tests := map[string]struct {
+		cookie    string
+		bearer    string
+		opts      []Option
+		status    int
+		challenge string
+		refreshed bool
+	}{
+		"cookie":           {New("alice", time.Now().Add(time.Hour), secret), "", nil, http.StatusOK, "", false},
+		"bearer":           {"", New("alice", time.Now().Add(time.Hour), secret), nil, http.StatusOK, "", false},
+		"none":             {"", "", nil, http.StatusUnauthorized, `Bearer realm="traffic_ops"`, false},
+		"expired":          {New("alice", time.Now().Add(-time.Hour), secret), "", nil, http.StatusUnauthorized, `error_description="expired"`, false},
+		"forged":           {New("alice", time.Now().Add(time.Hour), "wrong"), "", nil, http.StatusUnauthorized, `error_description="invalid"`, false},
+		"refresh":          {New("alice", time.Now().Add(time.Minute), secret), "", []Option{WithRefreshWindow(DefaultRefreshWindow)}, http.StatusOK, "", true},
+		"outside window":   {New("alice", time.Now().Add(time.Hour), secret), "", []Option{WithRefreshWindow(time.Minute)}, http.StatusOK, "", false},
+		"bearer unrefresh": {"", New("alice", time.Now().Add(time.Minute), secret), []Option{WithRefreshWindow(DefaultRefreshWindow)}, http.StatusOK, "", false},
+		"upgrade":          {New("alice", time.Now().Add(time.Hour), secret), "", []Option{WithVersionPolicy(VersionPolicy{Mint: Version1})}, http.StatusOK, "", true},
+		"upgraded":         {New("alice", time.Now().Add(time.Hour), secret, WithVersion(Version1)), "", []Option{WithVersionPolicy(VersionPolicy{Mint: Version1})}, http.StatusOK, "", false},
+		"stale":            {New("alice", time.Now().Add(-30*time.Second), secret), "", []Option{WithExpiryGrace(time.Minute)}, http.StatusOK, "", true},
+		"stale bearer":     {"", New("alice", time.Now().Add(-30*time.Second), secret), []Option{WithExpiryGrace(time.Minute)}, http.StatusOK, "", false},
+		"past grace":       {New("alice", time.Now().Add(-2*time.Minute), secret), "", []Option{WithExpiryGrace(time.Minute)}, http.StatusUnauthorized, `error_description="expired"`, false},
+		"audience":         {New("alice", time.Now().Add(time.Hour), secret), "", []Option{WithAudience("other")}, http.StatusUnauthorized, `error_description="invalid"`, false},
+	}
+	for name, test := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if test.cookie != "" {
+			r.AddCookie(&http.Cookie{Name: Name, Value: test.cookie})
+		}
+		if test.bearer != "" {
+			r.Header.Set("Authorization", "Bearer "+test.bearer)
+		}
+		w := httptest.NewRecorder()
+		handler(test.opts...).ServeHTTP(w, r)
+
+		if w.Code != test.status {
+			t.Errorf("%v: Middleware expected status %v, actual: %v", name, test.status, w.Code)
+		}
+		if test.status == http.StatusOK && w.Body.String() != "alice" {
+			t.Errorf("%v: Middleware expected next to see alice, actual: '%v'", name, w.Body.String())
+		}
+		if challenge := w.Header().Get("WWW-Authenticate"); !strings.Contains(challenge, test.challenge) || test.challenge == "" && challenge != "" {
+			t.Errorf("%v: Middleware expected challenge containing '%v', actual: '%v'", name, test.challenge, challenge)
+		}
+		cookies := w.Result().Cookies()
+		if refreshed := len(cookies) > 0; refreshed != test.refreshed {
+			t.Errorf("%v: Middleware expected refreshed %v, actual: %v", name, test.refreshed, refreshed)
+		}
+		if test.refreshed {
+			if c, err := Parse(secret, cookies[0].Value); err != nil || c.AuthData != "alice" || !cookies[0].HttpOnly {
+				t.Errorf("%v: Middleware expected refreshed HttpOnly cookie for alice, actual: %+v %v", name, cookies[0], err)
+			}
+		}
+	}
+
+	if _, ok := FromContext(httptest.NewRequest(http.MethodGet, "/", nil).Context()); ok {
+		t.Errorf("FromContext of unauthenticated request expected false, actual true")
+	}
+}
@@ -0,0 +1,177 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"hash/fnv"
+	"net/http"
+	"time"
+)
+
+// The formats of MigrationMiddleware, as its MigrationMetrics are told them, and as Migration.Header selects them.
+const (
+	// FormatLegacy is the Mojolicious format of Perl Traffic Ops: version 0 cookies of the session's cookie name.
+	FormatLegacy = "legacy"
+	// FormatNew is the format being migrated to: cookies of the version and name of the Migration.
+	FormatNew = "new"
+)
+
+// Migration configures MigrationMiddleware.
+type Migration struct {
+	// Version is the format version being migrated to. If it is Version0, it is Version2.
+	Version int
+	// Name is the name of the cookie of the new format, which must differ from that of the legacy cookie, so Perl Traffic Ops, which only reads the legacy cookie, keeps reading it. If it is empty, it is the legacy cookie's name with the suffix "_v2".
+	Name string
+	// Percent is the percentage of sessions, from 0 to 100, which are given the new format's cookie as well as the legacy cookie. Sessions are assigned by a hash of their SessionID, so each stays in or out of the rollout as it grows.
+	Percent int
+	// Header, if not empty, is a request header which overrides Percent: FormatNew gives the session both cookies, and FormatLegacy only the legacy cookie, e.g. so testers and canaries can opt in.
+	Header string
+	// Metrics, if not nil, is told the format of every request authenticated by a cookie.
+	Metrics MigrationMetrics
+}
+
+// MigrationMetrics is told which formats requests use, given to Migration, e.g. to know when no client relies on the legacy format anymore. Implementations must be fast, and safe for concurrent use. The Metrics of tocookie/prommetrics is a MigrationMetrics.
+type MigrationMetrics interface {
+	// FormatUsed is called with FormatLegacy or FormatNew for every request authenticated by a cookie of the format.
+	FormatUsed(format string)
+}
+
+// MigrationMiddleware returns a Middleware which accepts and emits cookies in both the legacy format and the new format of the migration at once, so Perl and Go Traffic Ops accept each other's sessions while the new format is rolled out. Requests are authenticated by their new cookie, if they have one, and otherwise by their legacy cookie, or bearer token, with the secret and options, as by Middleware. Sessions in the rollout, by Migration.Percent or Migration.Header, are given the cookie of the other format if they lack it, and whenever Middleware refreshes one of their cookies, the other is reissued with the same claims, so neither stack sees a stale session. Sessions taken out of the rollout have their new cookie expired.
+//
+// The legacy cookie is minted as version 0, so the options must be those Perl Traffic Ops reads, without e.g. WithCompression; WithRotation can't be given, as each refresh reissues both cookies. Logout must clear both cookies, e.g. with ClearHTTPCookies given each name, and Perl Traffic Ops only clears the legacy one, so give WithRevocationStore to revoke sessions across the stacks. Once the metrics show no request uses FormatLegacy, the legacy cookie can be retired for Middleware WithVersionPolicy of the new version.
+func MigrationMiddleware(secret string, m Migration, next http.Handler, opts ...Option) http.Handler {
+	legacy := append(append(make([]Option, 0, len(opts)+1), opts...), WithVersion(Version0))
+	lo := newOptions(legacy)
+	if m.Version == Version0 {
+		m.Version = Version2
+	}
+	if m.Name == "" {
+		m.Name = lo.name() + "_v2"
+	}
+	current := append(append(make([]Option, 0, len(opts)+2), opts...), WithVersion(m.Version), WithCookieName(m.Name))
+	mig := &migration{Migration: m, secret: secret, formats: map[string]*options{FormatLegacy: lo, FormatNew: newOptions(current)}}
+	legacyHandler := Middleware(secret, mig.handler(FormatLegacy, next), legacy...)
+	newHandler := Middleware(secret, mig.handler(FormatNew, next), current...)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if mig.prefersNew(r) {
+			newHandler.ServeHTTP(w, r)
+			return
+		}
+		legacyHandler.ServeHTTP(w, r)
+	})
+}
+
+// migration is the state of a MigrationMiddleware.
+type migration struct {
+	Migration
+	secret  string
+	formats map[string]*options
+}
+
+// prefersNew returns whether the request is to be authenticated by its new cookie: if it has one, which doesn't expire before its legacy cookie, if it has one, e.g. as Perl Traffic Ops refreshed only the legacy cookie. The cookies aren't verified here; the Middleware of the format does.
+func (m *migration) prefersNew(r *http.Request) bool {
+	current, err := m.formats[FormatNew].requestCookie(r)
+	if err == http.ErrNoCookie {
+		return false
+	}
+	legacy, err := m.formats[FormatLegacy].requestCookie(r)
+	if err != nil {
+		return true
+	}
+	return !m.expires(FormatLegacy, legacy).After(m.expires(FormatNew, current))
+}
+
+// expires returns the expiration of the unverified cookie value of the format, or the zero time if it can't be decoded.
+func (m *migration) expires(format, value string) time.Time {
+	c, err := decodeUnverified(value, m.formats[format])
+	if err != nil {
+		return time.Time{}
+	}
+	return c.Expires()
+}
+
+// handler returns the handler of requests authenticated by the Middleware of the format, which emits the other format's cookie, if the session needs it, before calling next.
+func (m *migration) handler(format string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, _ := FromContext(r.Context())
+		if _, err := m.formats[format].requestCookie(r); err == nil {
+			if m.Metrics != nil {
+				m.Metrics.FormatUsed(format)
+			}
+			m.emit(w, r, c, format)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// emit sets the cookie of the other format than the request was authenticated by, if it is the legacy cookie, or the session is in the rollout, and the request lacks it, or it expires before the cookie of the format, or the Middleware refreshed the cookie of the format. The new cookie of sessions not in the rollout is expired.
+func (m *migration) emit(w http.ResponseWriter, r *http.Request, c *Cookie, format string) {
+	other := FormatNew
+	if format == FormatNew {
+		other = FormatLegacy
+	}
+	oo := m.formats[other]
+	if other == FormatNew && !m.inRollout(r, c) {
+		return
+	}
+	if format == FormatNew && !m.inRollout(r, c) {
+		m.formats[FormatNew].clearHTTPCookies(w, r)
+	}
+	claims := c
+	if refreshed := m.refreshed(w, format); refreshed != nil {
+		claims = refreshed
+	} else if value, err := oo.requestCookie(r); err == nil && !m.expires(other, value).Before(c.Expires()) {
+		return
+	}
+	if cookie := encodeCookie(claims, m.secret, oo); cookie != "" {
+		oo.setHTTPCookies(w, r, cookie, time.Time{})
+	}
+}
+
+// refreshed returns the claims of the cookie of the format which the Middleware set on the response, or nil if it set none.
+func (m *migration) refreshed(w http.ResponseWriter, format string) *Cookie {
+	o := m.formats[format]
+	value := ""
+	for _, set := range (&http.Response{Header: w.Header()}).Cookies() {
+		if set.MaxAge >= 0 && o.isSessionCookieName(set.Name) {
+			value += set.Value
+		}
+	}
+	if value == "" {
+		return nil
+	}
+	c, err := decodeUnverified(value, o)
+	if err != nil {
+		return nil
+	}
+	return c
+}
+
+// inRollout returns whether the session is to have the new format's cookie, by the Header of the request, or its SessionID, or its user if it has none, hashed into Percent.
+func (m *migration) inRollout(r *http.Request, c *Cookie) bool {
+	if m.Header != "" {
+		switch r.Header.Get(m.Header) {
+		case FormatNew:
+			return true
+		case FormatLegacy:
+			return false
+		}
+	}
+	key := c.SessionID
+	if key == "" {
+		key = c.AuthData
+	}
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32()%100) < m.Percent
+}
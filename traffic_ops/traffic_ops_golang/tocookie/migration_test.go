@@ -0,0 +1,153 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+type countingFormats struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func (f *countingFormats) FormatUsed(format string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.counts[format]++
+}
+
+func TestMigrationMiddleware(t *testing.T) {
+	secret := "secret"
+	expiration := time.Now().Add(time.Hour)
+	legacy := New("alice", expiration, secret)
+	c, _ := Parse(secret, legacy)
+	current := encodeCookie(c, secret, newOptions([]Option{WithVersion(Version2)}))
+	refreshedByPerl := c.Clone()
+	refreshedByPerl.ExpiresUnix += 60
+	later := encodeCookie(refreshedByPerl, secret, newOptions(nil))
+	if CookieVersion(legacy) != Version0 || CookieVersion(current) != Version2 {
+		t.Fatalf("expected cookies of versions 0 and 2, actual: %v %v", CookieVersion(legacy), CookieVersion(current))
+	}
+	newName := Name + "_v2"
+
+	tests := map[string]struct {
+		percent  int
+		header   string
+		cookies  map[string]string
+		format   string
+		set      map[string]int
+		expired  []string
+		authData string
+	}{
+		"legacy in rollout":      {100, "", map[string]string{Name: legacy}, FormatLegacy, map[string]int{newName: Version2}, nil, "alice"},
+		"legacy out of rollout":  {0, "", map[string]string{Name: legacy}, FormatLegacy, nil, nil, "alice"},
+		"legacy with header":     {0, FormatNew, map[string]string{Name: legacy}, FormatLegacy, map[string]int{newName: Version2}, nil, "alice"},
+		"both in rollout":        {100, "", map[string]string{Name: legacy, newName: current}, FormatNew, nil, nil, "alice"},
+		"both out of rollout":    {0, "", map[string]string{Name: legacy, newName: current}, FormatNew, nil, []string{newName}, "alice"},
+		"both opted out":         {100, FormatLegacy, map[string]string{Name: legacy, newName: current}, FormatNew, nil, []string{newName}, "alice"},
+		"new only":               {100, "", map[string]string{newName: current}, FormatNew, map[string]int{Name: Version0}, nil, "alice"},
+		"legacy refreshed later": {100, "", map[string]string{Name: later, newName: current}, FormatLegacy, map[string]int{newName: Version2}, nil, "alice"},
+		"bearer":                 {100, "", nil, "", nil, nil, "alice"},
+	}
+	for name, test := range tests {
+		formats := &countingFormats{counts: map[string]int{}}
+		authenticated := ""
+		handler := MigrationMiddleware(secret, Migration{Percent: test.percent, Header: "X-Cookie-Format", Metrics: formats}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			c, _ := FromContext(r.Context())
+			authenticated = c.AuthData
+		}))
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		for cookieName, value := range test.cookies {
+			r.AddCookie(&http.Cookie{Name: cookieName, Value: value})
+		}
+		if test.cookies == nil {
+			r.Header.Set("Authorization", "Bearer "+legacy)
+		}
+		if test.header != "" {
+			r.Header.Set("X-Cookie-Format", test.header)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != http.StatusOK || authenticated != test.authData {
+			t.Errorf("%v: MigrationMiddleware expected %v of %v, actual: %v of '%v'", name, http.StatusOK, test.authData, w.Code, authenticated)
+		}
+		if test.format != "" && (formats.counts[test.format] != 1 || len(formats.counts) != 1) {
+			t.Errorf("%v: MigrationMiddleware expected format %v used, actual: %v", name, test.format, formats.counts)
+		}
+		if test.format == "" && len(formats.counts) != 0 {
+			t.Errorf("%v: MigrationMiddleware expected no format used, actual: %v", name, formats.counts)
+		}
+		set := map[string]int{}
+		expired := []string{}
+		for _, cookie := range w.Result().Cookies() {
+			if cookie.MaxAge < 0 {
+				expired = append(expired, cookie.Name)
+				continue
+			}
+			set[cookie.Name] = CookieVersion(cookie.Value)
+			emitted, err := Parse(secret, cookie.Value)
+			if err != nil || emitted.SessionID != c.SessionID || emitted.AuthData != "alice" {
+				t.Errorf("%v: MigrationMiddleware expected %v of the same session, actual: %+v %v", name, cookie.Name, emitted, err)
+			}
+		}
+		if len(set) != len(test.set) || len(expired) != len(test.expired) {
+			t.Errorf("%v: MigrationMiddleware expected %v set and %v expired, actual: %v %v", name, test.set, test.expired, set, expired)
+			continue
+		}
+		for cookieName, version := range test.set {
+			if v, ok := set[cookieName]; !ok || v != version {
+				t.Errorf("%v: MigrationMiddleware expected %v of version %v set, actual: %v", name, cookieName, version, set)
+			}
+		}
+		for i, cookieName := range test.expired {
+			if expired[i] != cookieName {
+				t.Errorf("%v: MigrationMiddleware expected %v expired, actual: %v", name, cookieName, expired)
+			}
+		}
+	}
+}
+
+func TestMigrationMiddlewareRefresh(t *testing.T) {
+	secret := "secret"
+	expiration := time.Now().Add(time.Minute)
+	legacy := New("alice", expiration, secret)
+	c, _ := Parse(secret, legacy)
+	current := encodeCookie(c, secret, newOptions([]Option{WithVersion(Version2)}))
+	handler := MigrationMiddleware(secret, Migration{Percent: 100}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), WithRefreshWindow(DefaultRefreshWindow))
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(&http.Cookie{Name: Name, Value: legacy})
+	r.AddCookie(&http.Cookie{Name: Name + "_v2", Value: current})
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	cookies := map[string]*Cookie{}
+	for _, cookie := range w.Result().Cookies() {
+		parsed, err := Parse(secret, cookie.Value)
+		if err != nil {
+			t.Fatalf("MigrationMiddleware expected valid %v, actual: %v", cookie.Name, err)
+		}
+		cookies[cookie.Name] = parsed
+	}
+	refreshed, reissued := cookies[Name+"_v2"], cookies[Name]
+	if refreshed == nil || reissued == nil {
+		t.Fatalf("MigrationMiddleware of expiring cookie expected both formats set, actual: %v", cookies)
+	}
+	if !refreshed.Expires().After(expiration) || !reissued.Expires().Equal(refreshed.Expires()) || reissued.IssuedAt != refreshed.IssuedAt || reissued.SessionID != c.SessionID {
+		t.Errorf("MigrationMiddleware expected the legacy cookie reissued with the refreshed claims, actual: %+v %+v", reissued, refreshed)
+	}
+}
@@ -0,0 +1,90 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// NonceStore records the nonces (JTIs) of single-use cookies, such as password reset or email confirmation links, and the IDs of action tokens; see ParseActionToken. Implementations must be safe for concurrent use. MemoryNonceStore is a NonceStore for a single server.
+type NonceStore interface {
+	// Consume marks the nonce as used. It returns true if the nonce had not been used before, and false if it was already consumed.
+	Consume(jti string) (bool, error)
+}
+
+// WithJTI sets the nonce of a cookie minted by New. NewJTI may be used to generate one.
+func WithJTI(jti string) Option {
+	return func(o *options) { o.jti = jti }
+}
+
+// WithNonceStore makes Parse consume the cookie's nonce from the given store, which makes the cookie valid exactly once. Cookies without a nonce are rejected. The store is only consulted after the signature and expiry have been verified, so forged or expired cookies can't burn nonces.
+func WithNonceStore(store NonceStore) Option {
+	return func(o *options) { o.nonces = store }
+}
+
+// NewJTI returns a random 128-bit nonce, hex-encoded, suitable for WithJTI.
+func NewJTI() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generating jti: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func consumeNonce(store NonceStore, jti string) error {
+	if jti == "" {
+		return errors.New("cookie has no jti")
+	}
+	firstUse, err := store.Consume(jti)
+	if err != nil {
+		return fmt.Errorf("consuming jti: %w", err)
+	}
+	if !firstUse {
+		return ErrReplayed
+	}
+	return nil
+}
+
+// MemoryNonceStore is a NonceStore held in memory, for a single server. Consumed nonces are lost when the process exits, so a restart makes tokens which hadn't expired usable again. It is safe for concurrent use.
+type MemoryNonceStore struct {
+	mu        sync.Mutex
+	retention time.Duration
+	consumed  map[string]time.Time
+}
+
+// NewMemoryNonceStore returns an empty MemoryNonceStore, which remembers consumed nonces for the retention, which must be at least as long as any single-use token lasts, e.g. DefaultActionTokenDuration, plus any leeway.
+func NewMemoryNonceStore(retention time.Duration) *MemoryNonceStore {
+	return &MemoryNonceStore{retention: retention, consumed: map[string]time.Time{}}
+}
+
+// Consume marks the nonce as used. Nonces consumed longer ago than the retention are pruned.
+func (s *MemoryNonceStore) Consume(jti string) (bool, error) {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for nonce, consumed := range s.consumed {
+		if now.Sub(consumed) > s.retention {
+			delete(s.consumed, nonce)
+		}
+	}
+	if _, used := s.consumed[jti]; used {
+		return false, nil
+	}
+	s.consumed[jti] = now
+	return true, nil
+}
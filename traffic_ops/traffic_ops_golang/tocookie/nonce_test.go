@@ -0,0 +1,72 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"testing"
+	"time"
+)
+
+type mapNonceStore map[string]struct{}
+
+func (s mapNonceStore) Consume(jti string) (bool, error) {
+	if _, ok := s[jti]; ok {
+		return false, nil
+	}
+	s[jti] = struct{}{}
+	return true, nil
+}
+
+func TestNonceStore(t *testing.T) {
+	secret := "secret"
+	store := mapNonceStore{}
+	jti, err := NewJTI()
+	if err != nil {
+		t.Fatalf("NewJTI expected nil error, actual: %v", err)
+	}
+
+	cookie := New("alice", time.Now().Add(time.Minute), secret, WithJTI(jti))
+	if _, err := Parse(secret, cookie, WithNonceStore(store)); err != nil {
+		t.Fatalf("Parse first use expected nil error, actual: %v", err)
+	}
+	if _, err := Parse(secret, cookie, WithNonceStore(store)); err != ErrReplayed {
+		t.Errorf("Parse second use expected ErrReplayed, actual: %v", err)
+	}
+
+	if _, err := Parse(secret, New("alice", time.Now().Add(time.Minute), secret), WithNonceStore(store)); err == nil {
+		t.Errorf("Parse without jti expected error, actual nil")
+	}
+
+	forged := New("alice", time.Now().Add(time.Minute), "wrong", WithJTI("forged"))
+	if _, err := Parse(secret, forged, WithNonceStore(store)); err == nil {
+		t.Errorf("Parse forged expected error, actual nil")
+	}
+	if _, ok := store["forged"]; ok {
+		t.Errorf("Parse forged expected nonce not to be consumed")
+	}
+}
+
+func TestMemoryNonceStore(t *testing.T) {
+	store := NewMemoryNonceStore(time.Hour)
+	if first, err := store.Consume("a"); !first || err != nil {
+		t.Errorf("Consume first use expected true, actual: %v %v", first, err)
+	}
+	if first, err := store.Consume("a"); first || err != nil {
+		t.Errorf("Consume second use expected false, actual: %v %v", first, err)
+	}
+
+	store.consumed["a"] = time.Now().Add(-2 * time.Hour)
+	if first, _ := store.Consume("b"); !first || len(store.consumed) != 1 {
+		t.Errorf("Consume expected nonces past the retention pruned, actual: %v", store.consumed)
+	}
+}
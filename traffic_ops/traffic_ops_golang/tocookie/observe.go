@@ -0,0 +1,85 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"errors"
+	"time"
+)
+
+// Outcome classifies the result of parsing a cookie, e.g. for metrics and alerting.
+type Outcome int
+
+const (
+	// OutcomeValid is a cookie which was accepted.
+	OutcomeValid Outcome = iota
+	// OutcomeExpired is an authentic cookie which was rejected only because it expired. This is normal turnover.
+	OutcomeExpired
+	// OutcomeInvalid is a cookie with a bad signature, or which couldn't be decoded. It may be an attack.
+	OutcomeInvalid
+	// OutcomeRejected is an authentic cookie which was rejected by a policy check other than expiry, such as its audience, not-before time, fingerprint, or nonce, or which couldn't be parsed for some other reason, such as an unavailable hash.
+	OutcomeRejected
+)
+
+func (o Outcome) String() string {
+	switch o {
+	case OutcomeValid:
+		return "valid"
+	case OutcomeExpired:
+		return "expired"
+	case OutcomeInvalid:
+		return "invalid"
+	case OutcomeRejected:
+		return "rejected"
+	}
+	return "unknown"
+}
+
+// IsAuthFailure returns whether err means the cookie is not authentic: its signature is bad, or it is malformed. It is false for expired cookies, for authentic cookies rejected by other policy checks, and for nil.
+func IsAuthFailure(err error) bool {
+	return errors.Is(err, ErrBadSignature) || errors.Is(err, ErrMalformed)
+}
+
+// Classify returns the Outcome of an error returned by Parse. A cookie is only OutcomeExpired if expiry was its sole failure; with WithAllErrors, an expired cookie which also failed other checks is OutcomeRejected.
+func Classify(err error) Outcome {
+	if err == nil {
+		return OutcomeValid
+	}
+	if IsAuthFailure(err) {
+		return OutcomeInvalid
+	}
+	if _, joined := err.(interface{ Unwrap() []error }); !joined && errors.Is(err, ErrExpired) {
+		return OutcomeExpired
+	}
+	return OutcomeRejected
+}
+
+// WithObserver makes Parse call observe with the Outcome and error of every cookie it parses, including through Manager.Parse, FromAuthHeader, and the other functions built on Parse. The observer is called synchronously, so it must be fast, and safe for concurrent use.
+func WithObserver(observe func(outcome Outcome, err error)) Option {
+	return func(o *options) { o.observer = observe }
+}
+
+// observe reports the result of parsing a cookie, begun at start, to the observer and the metrics, if there are any, and rejected cookies, whose claims may be nil, to the audit sink.
+func (o *options) observe(start time.Time, c *Cookie, err error) {
+	if o.observer != nil {
+		o.observer(Classify(err), err)
+	}
+	if o.metrics != nil {
+		o.metrics.Parsed(Classify(err), FailureReason(err), time.Since(start))
+	}
+	if err != nil && o.auditSink != nil {
+		record := o.auditRecord(AuditRejected, c)
+		record.Reason = FailureReason(err)
+		o.auditSink.Audit(record)
+	}
+}
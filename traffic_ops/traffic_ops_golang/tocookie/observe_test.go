@@ -0,0 +1,95 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestClassifyParse(t *testing.T) {
+	secret := "secret"
+	valid := New("alice", time.Now().Add(time.Minute), secret)
+	tampered := []byte(valid)
+	tampered[2] ^= 1
+
+	tests := map[string]struct {
+		secret   string
+		cookie   string
+		opts     []Option
+		expected Outcome
+	}{
+		"valid":              {secret, valid, nil, OutcomeValid},
+		"expired":            {secret, New("alice", time.Now().Add(-time.Minute), secret), nil, OutcomeExpired},
+		"wrong secret":       {"wrong", valid, nil, OutcomeInvalid},
+		"tampered":           {secret, string(tampered), nil, OutcomeInvalid},
+		"no dashes":          {secret, "eyJhdXRoX2RhdGEiOiJhbGljZSJ9", nil, OutcomeInvalid},
+		"bad signature hex":  {secret, valid[:len(valid)-1] + "z", nil, OutcomeInvalid},
+		"empty":              {secret, "", nil, OutcomeInvalid},
+		"unsupported":        {secret, "v9.e30--00", nil, OutcomeInvalid},
+		"audience mismatch":  {secret, valid, []Option{WithAudience("other")}, OutcomeRejected},
+		"expired and other":  {secret, New("alice", time.Now().Add(-time.Minute), secret), []Option{WithAudience("other"), WithAllErrors()}, OutcomeRejected},
+		"expired all errors": {secret, New("alice", time.Now().Add(-time.Minute), secret), []Option{WithAllErrors()}, OutcomeExpired},
+	}
+	for name, test := range tests {
+		observed := []Outcome{}
+		opts := append(test.opts, WithObserver(func(outcome Outcome, err error) { observed = append(observed, outcome) }))
+		_, err := Parse(test.secret, test.cookie, opts...)
+		if actual := Classify(err); actual != test.expected {
+			t.Errorf("%v: Classify expected %v, actual: %v (%v)", name, test.expected, actual, err)
+		}
+		if len(observed) != 1 || observed[0] != test.expected {
+			t.Errorf("%v: observer expected [%v], actual: %v", name, test.expected, observed)
+		}
+		if actual := IsAuthFailure(err); actual != (test.expected == OutcomeInvalid) {
+			t.Errorf("%v: IsAuthFailure expected %v, actual: %v", name, test.expected == OutcomeInvalid, actual)
+		}
+	}
+}
+
+func TestIsAuthFailure(t *testing.T) {
+	tests := map[string]struct {
+		err      error
+		expected bool
+	}{
+		"nil":               {nil, false},
+		"expired":           {ErrExpired, false},
+		"wrapped expired":   {fmt.Errorf("refreshing: %w", ErrExpired), false},
+		"replayed":          {ErrReplayed, false},
+		"bad signature":     {ErrBadSignature, true},
+		"wrapped malformed": {fmt.Errorf("parsing: %w", ErrMalformed), true},
+		"joined":            {errors.Join(ErrExpired, ErrBadSignature), true},
+		"other":             {errors.New("other"), false},
+	}
+	for name, test := range tests {
+		if actual := IsAuthFailure(test.err); actual != test.expected {
+			t.Errorf("%v: IsAuthFailure expected %v, actual: %v", name, test.expected, actual)
+		}
+	}
+}
+
+func TestObserverBeforeParse(t *testing.T) {
+	observed := []Outcome{}
+	observer := WithObserver(func(outcome Outcome, err error) { observed = append(observed, outcome) })
+
+	ParseWithIssuerSecrets(map[string]string{}, "not a cookie", observer)
+	ParseWithIssuerSecrets(map[string]string{}, New("alice", time.Now().Add(time.Minute), "secret"), observer)
+	ParseWithKeyFunc("not a cookie", func(*Cookie) (string, error) { return "secret", nil }, observer)
+
+	expected := []Outcome{OutcomeInvalid, OutcomeRejected, OutcomeInvalid}
+	if fmt.Sprint(observed) != fmt.Sprint(expected) {
+		t.Errorf("observer expected %v, actual: %v", expected, observed)
+	}
+}
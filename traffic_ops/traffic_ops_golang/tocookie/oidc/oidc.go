@@ -0,0 +1,555 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package oidc verifies the ID tokens of an OpenID Connect provider, and exchanges them for tocookie sessions, so Traffic Ops can be fronted by single sign-on without a proxy injecting cookies:
+//
+//	verifier := oidc.NewVerifier(oidc.Config{Issuer: "https://sso.example.net", ClientID: "traffic-ops"})
+//	http.Handle("/login/oidc", verifier.Handler(secret, nil))
+//
+// The provider's signing keys are found by OpenID Connect Discovery, and its JWKS is cached, and fetched again when a token is signed by a key it doesn't have, so the provider can rotate keys. Tokens signed with RS256, ES256, and EdDSA are accepted; unsigned tokens, and tokens signed with an HMAC, never are.
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/tocookie"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/tocookie/login"
+)
+
+// The signature algorithms of ID tokens which are supported, as named by JWS (RFC 7518 and RFC 8037).
+const (
+	AlgRS256 = "RS256"
+	AlgES256 = "ES256"
+	AlgEdDSA = "EdDSA"
+)
+
+// DefaultJWKSCacheTTL is how long the provider's JWKS is cached, if its response has no Cache-Control max-age.
+const DefaultJWKSCacheTTL = time.Hour
+
+// MinRefreshInterval is the shortest time between fetches of the JWKS for tokens of unknown keys, so forged tokens with random key IDs can't make the verifier hammer the provider.
+const MinRefreshInterval = time.Minute
+
+// DefaultLeeway is the default allowance for clock skew between the provider and the verifier.
+const DefaultLeeway = time.Minute
+
+// maxResponseSize is the largest discovery document or JWKS which is read.
+const maxResponseSize = 1 << 20
+
+// ErrNonceMismatch is returned for ID tokens whose nonce isn't the one the authentication request was made with, which may be a replayed token.
+var ErrNonceMismatch = errors.New("oidc: nonce mismatch")
+
+// errNotMinted is returned by Exchange if the cookie couldn't be minted.
+var errNotMinted = errors.New("minting cookie failed")
+
+// Config configures a Verifier.
+type Config struct {
+	// Issuer is the issuer identifier of the provider, e.g. "https://sso.example.net". Its discovery document is fetched from Issuer, without any trailing slash, + "/.well-known/openid-configuration", and must name exactly the same issuer, as must the "iss" claim of tokens, so providers whose issuers end in a slash, such as Auth0, must be configured with it.
+	Issuer string
+	// ClientID is the client ID Traffic Ops is registered with at the provider, which tokens must have as an audience.
+	ClientID string
+	// HTTPClient fetches the discovery document and JWKS. It defaults to a client with a 10 second timeout.
+	HTTPClient *http.Client
+	// Algorithms are the accepted signature algorithms. They default to AlgRS256, AlgES256, and AlgEdDSA.
+	Algorithms []string
+	// Leeway allows for clock skew in checking the expiration, not-before, and issued-at times of tokens. It defaults to DefaultLeeway.
+	Leeway time.Duration
+	// UsernameClaim is the claim of tokens which is the user of their sessions. It defaults to "sub"; providers of human-readable names, such as "preferred_username" or "email", are better for audit logs, if the claim is unique and can't be changed by users.
+	UsernameClaim string
+	// RolesClaim, if not empty, is a claim of tokens whose strings are the roles of their sessions, e.g. "groups".
+	RolesClaim string
+	// SessionDuration is how long the cookies of exchanged tokens last, regardless of the tokens' expirations, which are usually minutes. It defaults to tocookie.DefaultDuration.
+	SessionDuration time.Duration
+	// Now returns the current time. It defaults to time.Now.
+	Now func() time.Time
+}
+
+// Verifier verifies the ID tokens of a provider. It is safe for concurrent use.
+type Verifier struct {
+	cfg Config
+
+	mu        sync.Mutex
+	jwksURI   string
+	keys      map[string]jwk
+	expires   time.Time
+	lastFetch time.Time
+}
+
+// NewVerifier returns a Verifier of the provider. It doesn't fetch the discovery document until the first token is verified.
+func NewVerifier(cfg Config) *Verifier {
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	if len(cfg.Algorithms) == 0 {
+		cfg.Algorithms = []string{AlgRS256, AlgES256, AlgEdDSA}
+	}
+	if cfg.Leeway == 0 {
+		cfg.Leeway = DefaultLeeway
+	}
+	if cfg.UsernameClaim == "" {
+		cfg.UsernameClaim = "sub"
+	}
+	if cfg.SessionDuration <= 0 {
+		cfg.SessionDuration = tocookie.DefaultDuration
+	}
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+	return &Verifier{cfg: cfg}
+}
+
+// IDToken is a verified ID token.
+type IDToken struct {
+	Issuer   string
+	Subject  string
+	Audience []string
+	Expiry   time.Time
+	IssuedAt time.Time
+	Nonce    string
+	// Username is the UsernameClaim of the token.
+	Username string
+	// Roles are the strings of the RolesClaim of the token.
+	Roles []string
+	// Claims are all of the token's claims.
+	Claims map[string]json.RawMessage
+}
+
+// idTokenClaims are the claims of ID tokens which are verified.
+type idTokenClaims struct {
+	Issuer    string   `json:"iss"`
+	Subject   string   `json:"sub"`
+	Audience  audience `json:"aud"`
+	AZP       string   `json:"azp"`
+	Expiry    *float64 `json:"exp"`
+	IssuedAt  *float64 `json:"iat"`
+	NotBefore *float64 `json:"nbf"`
+	Nonce     string   `json:"nonce"`
+}
+
+// audience is the "aud" claim, which is either a string or an array of strings.
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	single := ""
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	many := []string{}
+	if err := json.Unmarshal(data, &many); err != nil {
+		return errors.New("aud must be a string or an array of strings")
+	}
+	*a = many
+	return nil
+}
+
+type joseHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// Verify verifies the ID token's signature, issuer, audience, and times, as OpenID Connect Core 1.0 §3.1.3.7 requires. If the nonce isn't empty, it must be the token's nonce, or ErrNonceMismatch is returned; it should be the nonce of the authentication request which returned the token.
+//
+// Errors of tokens which aren't authentic wrap tocookie.ErrBadSignature or tocookie.ErrMalformed, and of tokens which are, but are rejected, the tocookie error of the claim, such as tocookie.ErrExpired, tocookie.ErrAudienceMismatch, or tocookie.ErrIssuerMismatch.
+func (v *Verifier) Verify(ctx context.Context, rawToken, nonce string) (*IDToken, error) {
+	parts := strings.Split(rawToken, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: token has %d parts, expected 3", tocookie.ErrMalformed, len(parts))
+	}
+	hdrBytes, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("%w: error decoding token header: %w", tocookie.ErrMalformed, err)
+	}
+	hdr := joseHeader{}
+	if err := json.Unmarshal(hdrBytes, &hdr); err != nil {
+		return nil, fmt.Errorf("%w: error decoding token header: %w", tocookie.ErrMalformed, err)
+	}
+	if !v.algorithmAllowed(hdr.Alg) {
+		return nil, fmt.Errorf("%w: token algorithm '%s' isn't accepted", tocookie.ErrBadSignature, hdr.Alg)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: error decoding token signature: %w", tocookie.ErrMalformed, err)
+	}
+	key, err := v.key(ctx, hdr.Kid, hdr.Alg)
+	if err != nil {
+		return nil, err
+	}
+	if !key.verify(hdr.Alg, []byte(parts[0]+"."+parts[1]), sig) {
+		return nil, tocookie.ErrBadSignature
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("%w: error decoding token payload: %w", tocookie.ErrMalformed, err)
+	}
+	claims := idTokenClaims{}
+	all := map[string]json.RawMessage{}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, fmt.Errorf("%w: error decoding token claims: %w", tocookie.ErrMalformed, err)
+	}
+	if err := json.Unmarshal(payload, &all); err != nil {
+		return nil, fmt.Errorf("%w: error decoding token claims: %w", tocookie.ErrMalformed, err)
+	}
+	if err := v.validate(&claims, nonce); err != nil {
+		return nil, err
+	}
+	return v.idToken(&claims, all)
+}
+
+// validate checks the claims of a token whose signature has been verified.
+func (v *Verifier) validate(claims *idTokenClaims, nonce string) error {
+	now := v.cfg.Now()
+	if claims.Issuer != v.cfg.Issuer {
+		return fmt.Errorf("%w: token issuer '%s'", tocookie.ErrIssuerMismatch, claims.Issuer)
+	}
+	if !claims.Audience.contains(v.cfg.ClientID) {
+		return fmt.Errorf("%w: token audience %v", tocookie.ErrAudienceMismatch, []string(claims.Audience))
+	}
+	if len(claims.Audience) > 1 && claims.AZP != v.cfg.ClientID {
+		return fmt.Errorf("%w: token of several audiences authorized for '%s'", tocookie.ErrAudienceMismatch, claims.AZP)
+	}
+	if claims.Subject == "" || claims.Expiry == nil || claims.IssuedAt == nil {
+		return fmt.Errorf("%w: token has no sub, exp, or iat", tocookie.ErrMalformed)
+	}
+	if expiry := unixTime(*claims.Expiry); !now.Before(expiry.Add(v.cfg.Leeway)) {
+		return &tocookie.ExpiredError{Expired: expiry, Now: now}
+	}
+	if claims.NotBefore != nil && now.Add(v.cfg.Leeway).Before(unixTime(*claims.NotBefore)) {
+		return tocookie.ErrNotYetValid
+	}
+	if now.Add(v.cfg.Leeway).Before(unixTime(*claims.IssuedAt)) {
+		return fmt.Errorf("%w: token issued in the future", tocookie.ErrNotYetValid)
+	}
+	if nonce != "" && claims.Nonce != nonce {
+		return ErrNonceMismatch
+	}
+	return nil
+}
+
+// idToken returns the IDToken of the validated claims.
+func (v *Verifier) idToken(claims *idTokenClaims, all map[string]json.RawMessage) (*IDToken, error) {
+	t := &IDToken{
+		Issuer:   claims.Issuer,
+		Subject:  claims.Subject,
+		Audience: claims.Audience,
+		Expiry:   unixTime(*claims.Expiry),
+		IssuedAt: unixTime(*claims.IssuedAt),
+		Nonce:    claims.Nonce,
+		Claims:   all,
+	}
+	if raw, ok := all[v.cfg.UsernameClaim]; !ok || json.Unmarshal(raw, &t.Username) != nil || t.Username == "" {
+		return nil, fmt.Errorf("%w: token has no string claim '%s'", tocookie.ErrClaimInvalid, v.cfg.UsernameClaim)
+	}
+	if v.cfg.RolesClaim != "" {
+		if raw, ok := all[v.cfg.RolesClaim]; ok && json.Unmarshal(raw, &t.Roles) != nil {
+			return nil, fmt.Errorf("%w: token claim '%s' isn't an array of strings", tocookie.ErrClaimInvalid, v.cfg.RolesClaim)
+		}
+	}
+	return t, nil
+}
+
+// Exchange verifies the ID token, as Verify does, and mints a tocookie session for its user, lasting SessionDuration, with its roles, and the AuthMethods of tocookie.AuthMethodOIDC, unless the options set others. The cookie is minted with the secret and options, as by tocookie.NewWithContext.
+func (v *Verifier) Exchange(ctx context.Context, rawToken, nonce, secret string, opts ...tocookie.Option) (string, *IDToken, error) {
+	t, err := v.Verify(ctx, rawToken, nonce)
+	if err != nil {
+		return "", nil, err
+	}
+	expiration := v.cfg.Now().Add(v.cfg.SessionDuration)
+	opts = append(append([]tocookie.Option{tocookie.WithAuthMethods(tocookie.AuthMethodOIDC)}, opts...), tocookie.WithRoles(t.Roles...))
+	cookie := tocookie.NewWithContext(ctx, t.Username, expiration, secret, opts...)
+	if cookie == "" {
+		return "", nil, errNotMinted
+	}
+	return cookie, t, nil
+}
+
+// Handler returns a handler of POST requests exchanging the ID token of their "id_token" form field for a session, whose cookie is set on the response, bound to the client given tocookie.WithClientBinding, as tocookie/login's Login does. If nonce isn't nil, it returns the nonce of the authentication request of the request, e.g. from a cookie the service set before redirecting to the provider. Tokens which aren't valid are answered with 401 Unauthorized, tokens which can't be verified because the provider's keys can't be fetched with 502 Bad Gateway, and requests of other methods with 405 Method Not Allowed.
+func (v *Verifier) Handler(secret string, nonce func(r *http.Request) string, opts ...tocookie.Option) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			login.WriteAlert(w, http.StatusMethodNotAllowed, login.ErrorLevel, http.StatusText(http.StatusMethodNotAllowed))
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, login.MaxRequestSize)
+		rawToken := r.PostFormValue("id_token")
+		if rawToken == "" {
+			login.WriteAlert(w, http.StatusBadRequest, login.ErrorLevel, "missing id_token")
+			return
+		}
+		expectedNonce := ""
+		if nonce != nil {
+			if expectedNonce = nonce(r); expectedNonce == "" {
+				login.WriteAlert(w, http.StatusBadRequest, login.ErrorLevel, "missing nonce")
+				return
+			}
+		}
+		cookie, _, err := v.Exchange(r.Context(), rawToken, expectedNonce, secret, append(opts[:len(opts):len(opts)], tocookie.BindRequest(r, opts...), tocookie.AuditRequest(r, opts...))...)
+		if err != nil {
+			status := http.StatusUnauthorized
+			switch {
+			case errors.Is(err, errNotMinted):
+				status = http.StatusInternalServerError
+			case tocookie.FailureReason(err) == "other" && !errors.Is(err, ErrNonceMismatch):
+				// the provider's keys couldn't be fetched.
+				status = http.StatusBadGateway
+			}
+			login.WriteAlert(w, status, login.ErrorLevel, http.StatusText(status))
+			return
+		}
+		if err := tocookie.SetHTTPCookies(w, r, cookie, v.cfg.Now().Add(v.cfg.SessionDuration), opts...); err != nil {
+			login.WriteAlert(w, http.StatusInternalServerError, login.ErrorLevel, http.StatusText(http.StatusInternalServerError))
+			return
+		}
+		login.WriteAlert(w, http.StatusOK, login.SuccessLevel, "Successfully logged in.")
+	}
+}
+
+func (v *Verifier) algorithmAllowed(alg string) bool {
+	for _, allowed := range v.cfg.Algorithms {
+		if alg == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+func (a audience) contains(s string) bool {
+	for _, aud := range a {
+		if aud == s {
+			return true
+		}
+	}
+	return false
+}
+
+func unixTime(seconds float64) time.Time {
+	return time.Unix(0, int64(seconds*float64(time.Second)))
+}
+
+// key returns the provider's key of the ID and algorithm, fetching the JWKS if it isn't cached, has expired, or, at most once every MinRefreshInterval, doesn't have the key. Tokens of keys the provider doesn't have are rejected with tocookie.ErrUnknownKey.
+func (v *Verifier) key(ctx context.Context, kid, alg string) (jwk, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	now := v.cfg.Now()
+	if v.keys == nil || now.After(v.expires) {
+		if err := v.fetchKeys(ctx, now); err != nil {
+			return jwk{}, err
+		}
+	}
+	key, ok := v.findKey(kid, alg)
+	if !ok && now.Sub(v.lastFetch) >= MinRefreshInterval {
+		if err := v.fetchKeys(ctx, now); err != nil {
+			return jwk{}, err
+		}
+		key, ok = v.findKey(kid, alg)
+	}
+	if !ok {
+		return jwk{}, fmt.Errorf("%w: provider has no %s key '%s'", tocookie.ErrUnknownKey, alg, kid)
+	}
+	return key, nil
+}
+
+// findKey returns the cached key of the ID, or, for tokens without a key ID, the only key of the algorithm.
+func (v *Verifier) findKey(kid, alg string) (jwk, bool) {
+	if kid != "" {
+		key, ok := v.keys[kid]
+		return key, ok && key.usable(alg)
+	}
+	found, n := jwk{}, 0
+	for _, key := range v.keys {
+		if key.usable(alg) {
+			found, n = key, n+1
+		}
+	}
+	return found, n == 1
+}
+
+// fetchKeys fetches the discovery document, if it hasn't been, and the JWKS. v.mu must be held.
+func (v *Verifier) fetchKeys(ctx context.Context, now time.Time) error {
+	v.lastFetch = now
+	if v.jwksURI == "" {
+		discovery := struct {
+			Issuer  string `json:"issuer"`
+			JWKSURI string `json:"jwks_uri"`
+		}{}
+		if _, err := v.getJSON(ctx, strings.TrimSuffix(v.cfg.Issuer, "/")+"/.well-known/openid-configuration", &discovery); err != nil {
+			return fmt.Errorf("fetching provider configuration: %w", err)
+		}
+		if discovery.Issuer != v.cfg.Issuer {
+			return fmt.Errorf("provider configuration of issuer '%s', expected '%s'", discovery.Issuer, v.cfg.Issuer)
+		}
+		if discovery.JWKSURI == "" {
+			return errors.New("provider configuration has no jwks_uri")
+		}
+		v.jwksURI = discovery.JWKSURI
+	}
+	set := struct {
+		Keys []json.RawMessage `json:"keys"`
+	}{}
+	header, err := v.getJSON(ctx, v.jwksURI, &set)
+	if err != nil {
+		return fmt.Errorf("fetching provider keys: %w", err)
+	}
+	keys := make(map[string]jwk, len(set.Keys))
+	for _, raw := range set.Keys {
+		// keys of unsupported types or uses are skipped, as RFC 7517 §5 requires.
+		if key, err := parseJWK(raw); err == nil {
+			keys[key.kid] = key
+		}
+	}
+	v.keys = keys
+	v.expires = now.Add(cacheTTL(header))
+	return nil
+}
+
+// getJSON gets the JSON document at the URL into v, returning the response header.
+func (v *Verifier) getJSON(ctx context.Context, url string, doc interface{}) (http.Header, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := v.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(doc); err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", url, err)
+	}
+	return resp.Header, nil
+}
+
+// cacheTTL returns the max-age of the Cache-Control header, or DefaultJWKSCacheTTL. Responses which mustn't be cached are cached for MinRefreshInterval regardless, so every token doesn't fetch the keys.
+func cacheTTL(header http.Header) time.Duration {
+	for _, directive := range strings.Split(header.Get("Cache-Control"), ",") {
+		directive = strings.ToLower(strings.TrimSpace(directive))
+		if directive == "no-store" || directive == "no-cache" {
+			return MinRefreshInterval
+		}
+		if strings.HasPrefix(directive, "max-age=") {
+			if seconds, err := strconv.Atoi(strings.TrimPrefix(directive, "max-age=")); err == nil {
+				if ttl := time.Duration(seconds) * time.Second; ttl > MinRefreshInterval {
+					return ttl
+				}
+				return MinRefreshInterval
+			}
+		}
+	}
+	return DefaultJWKSCacheTTL
+}
+
+// jwk is a public key of the provider's JWKS.
+type jwk struct {
+	kid string
+	alg string
+	key crypto.PublicKey
+}
+
+// parseJWK parses a JSON Web Key of RFC 7517: an RSA key, a P-256 EC key, or an Ed25519 OKP key of RFC 8037. Keys for encryption are rejected.
+func parseJWK(raw json.RawMessage) (jwk, error) {
+	k := struct {
+		Kty string `json:"kty"`
+		Kid string `json:"kid"`
+		Use string `json:"use"`
+		Alg string `json:"alg"`
+		Crv string `json:"crv"`
+		N   string `json:"n"`
+		E   string `json:"e"`
+		X   string `json:"x"`
+		Y   string `json:"y"`
+	}{}
+	if err := json.Unmarshal(raw, &k); err != nil {
+		return jwk{}, err
+	}
+	if k.Use != "" && k.Use != "sig" {
+		return jwk{}, fmt.Errorf("key use '%s'", k.Use)
+	}
+	switch k.Kty {
+	case "RSA":
+		n, err1 := base64.RawURLEncoding.DecodeString(k.N)
+		e, err2 := base64.RawURLEncoding.DecodeString(k.E)
+		if err1 != nil || err2 != nil || len(n) == 0 || len(e) == 0 || len(e) > 4 {
+			return jwk{}, errors.New("malformed RSA key")
+		}
+		pub := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		return jwk{kid: k.Kid, alg: AlgRS256, key: pub}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return jwk{}, fmt.Errorf("curve '%s'", k.Crv)
+		}
+		x, err1 := base64.RawURLEncoding.DecodeString(k.X)
+		y, err2 := base64.RawURLEncoding.DecodeString(k.Y)
+		if err1 != nil || err2 != nil || len(x) != 32 || len(y) != 32 {
+			return jwk{}, errors.New("malformed EC key")
+		}
+		pub := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !pub.Curve.IsOnCurve(pub.X, pub.Y) {
+			return jwk{}, errors.New("EC key isn't on its curve")
+		}
+		return jwk{kid: k.Kid, alg: AlgES256, key: pub}, nil
+	case "OKP":
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if k.Crv != "Ed25519" || err != nil || len(x) != ed25519.PublicKeySize {
+			return jwk{}, errors.New("malformed OKP key")
+		}
+		return jwk{kid: k.Kid, alg: AlgEdDSA, key: ed25519.PublicKey(x)}, nil
+	}
+	return jwk{}, fmt.Errorf("key type '%s'", k.Kty)
+}
+
+// usable returns whether the key verifies signatures of the algorithm.
+func (k jwk) usable(alg string) bool {
+	return k.alg == alg
+}
+
+// verify returns whether the signature of the signing input is authentic.
+func (k jwk) verify(alg string, input, sig []byte) bool {
+	if !k.usable(alg) {
+		return false
+	}
+	switch key := k.key.(type) {
+	case *rsa.PublicKey:
+		digest := sha256.Sum256(input)
+		return rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig) == nil
+	case *ecdsa.PublicKey:
+		// JWS ES256 signatures are the 32-byte R and S concatenated, not ASN.1.
+		if len(sig) != 64 {
+			return false
+		}
+		digest := sha256.Sum256(input)
+		return ecdsa.Verify(key, digest[:], new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:]))
+	case ed25519.PublicKey:
+		return ed25519.Verify(key, input, sig)
+	}
+	return false
+}
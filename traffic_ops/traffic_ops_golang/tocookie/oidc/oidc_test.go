@@ -0,0 +1,287 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/tocookie"
+)
+
+// testProvider is an OpenID provider serving discovery and a JWKS of its keys.
+type testProvider struct {
+	*httptest.Server
+	rsaKey *rsa.PrivateKey
+	ecKey  *ecdsa.PrivateKey
+	edKey  ed25519.PrivateKey
+	kids   []string
+	// issuerPath is appended to the URL of the provider to make its issuer, e.g. "/" for providers whose issuers end in a slash.
+	issuerPath string
+	jwksHits   int32
+	jwksHeader string
+}
+
+func newTestProvider(t *testing.T) *testProvider {
+	p := &testProvider{kids: []string{"rsa1", "ec1", "ed1"}}
+	var err error
+	if p.rsaKey, err = rsa.GenerateKey(rand.Reader, 2048); err != nil {
+		t.Fatal(err)
+	}
+	if p.ecKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader); err != nil {
+		t.Fatal(err)
+	}
+	if _, p.edKey, err = ed25519.GenerateKey(rand.Reader); err != nil {
+		t.Fatal(err)
+	}
+	p.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			json.NewEncoder(w).Encode(map[string]string{"issuer": p.issuer(), "jwks_uri": p.URL + "/jwks"})
+		case "/jwks":
+			atomic.AddInt32(&p.jwksHits, 1)
+			if p.jwksHeader != "" {
+				w.Header().Set("Cache-Control", p.jwksHeader)
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"keys": p.jwks()})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(p.Close)
+	return p
+}
+
+func (p *testProvider) issuer() string {
+	return p.URL + p.issuerPath
+}
+
+func (p *testProvider) jwks() []map[string]string {
+	b64 := base64.RawURLEncoding.EncodeToString
+	pad := func(b []byte) []byte { return append(make([]byte, 32-len(b)), b...) }
+	return []map[string]string{
+		{"kty": "RSA", "kid": p.kids[0], "use": "sig", "n": b64(p.rsaKey.N.Bytes()), "e": b64(big.NewInt(int64(p.rsaKey.E)).Bytes())},
+		{"kty": "EC", "kid": p.kids[1], "crv": "P-256", "x": b64(pad(p.ecKey.X.Bytes())), "y": b64(pad(p.ecKey.Y.Bytes()))},
+		{"kty": "OKP", "kid": p.kids[2], "crv": "Ed25519", "x": b64(p.edKey.Public().(ed25519.PublicKey))},
+		{"kty": "RSA", "kid": "enc", "use": "enc", "n": b64(p.rsaKey.N.Bytes()), "e": "AQAB"},
+	}
+}
+
+// sign returns an ID token of the claims, signed with the algorithm and key ID.
+func (p *testProvider) sign(t *testing.T, alg, kid string, claims map[string]interface{}) string {
+	hdr, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	input := base64.RawURLEncoding.EncodeToString(hdr) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(input))
+	var sig []byte
+	var err error
+	switch alg {
+	case AlgRS256:
+		sig, err = rsa.SignPKCS1v15(rand.Reader, p.rsaKey, crypto.SHA256, digest[:])
+	case AlgES256:
+		r, s, signErr := ecdsa.Sign(rand.Reader, p.ecKey, digest[:])
+		sig, err = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...), signErr
+	case AlgEdDSA:
+		sig = ed25519.Sign(p.edKey, []byte(input))
+	case "none":
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func (p *testProvider) claims(now time.Time) map[string]interface{} {
+	return map[string]interface{}{
+		"iss": p.issuer(), "sub": "248289761001", "aud": "traffic-ops", "nonce": "n-0S6_WzA2Mj",
+		"exp": now.Add(5 * time.Minute).Unix(), "iat": now.Unix(),
+		"preferred_username": "alice", "groups": []string{"admin", "ops"},
+	}
+}
+
+func TestVerify(t *testing.T) {
+	p := newTestProvider(t)
+	now := time.Now()
+	v := NewVerifier(Config{Issuer: p.URL, ClientID: "traffic-ops", UsernameClaim: "preferred_username", RolesClaim: "groups"})
+
+	for _, signer := range []struct{ alg, kid string }{{AlgRS256, "rsa1"}, {AlgES256, "ec1"}, {AlgEdDSA, "ed1"}} {
+		token, err := v.Verify(context.Background(), p.sign(t, signer.alg, signer.kid, p.claims(now)), "n-0S6_WzA2Mj")
+		if err != nil {
+			t.Errorf("Verify %v expected nil error, actual: %v", signer.alg, err)
+			continue
+		}
+		if token.Username != "alice" || token.Subject != "248289761001" || !reflect.DeepEqual(token.Roles, []string{"admin", "ops"}) {
+			t.Errorf("Verify %v expected alice with roles, actual: %+v", signer.alg, token)
+		}
+	}
+	if hits := atomic.LoadInt32(&p.jwksHits); hits != 1 {
+		t.Errorf("Verify expected JWKS fetched once, actual: %v", hits)
+	}
+
+	tests := map[string]struct {
+		alg, kid string
+		modify   func(c map[string]interface{})
+		nonce    string
+		expected error
+	}{
+		"expired":         {AlgRS256, "rsa1", func(c map[string]interface{}) { c["exp"] = now.Add(-time.Hour).Unix() }, "", tocookie.ErrExpired},
+		"wrong issuer":    {AlgRS256, "rsa1", func(c map[string]interface{}) { c["iss"] = "https://evil.example.net" }, "", tocookie.ErrIssuerMismatch},
+		"wrong audience":  {AlgRS256, "rsa1", func(c map[string]interface{}) { c["aud"] = "portal" }, "", tocookie.ErrAudienceMismatch},
+		"no azp":          {AlgRS256, "rsa1", func(c map[string]interface{}) { c["aud"] = []string{"portal", "traffic-ops"} }, "", tocookie.ErrAudienceMismatch},
+		"azp":             {AlgRS256, "rsa1", func(c map[string]interface{}) { c["aud"], c["azp"] = []string{"portal", "traffic-ops"}, "traffic-ops" }, "", nil},
+		"not yet valid":   {AlgRS256, "rsa1", func(c map[string]interface{}) { c["nbf"] = now.Add(time.Hour).Unix() }, "", tocookie.ErrNotYetValid},
+		"wrong nonce":     {AlgRS256, "rsa1", func(c map[string]interface{}) {}, "other", ErrNonceMismatch},
+		"no username":     {AlgRS256, "rsa1", func(c map[string]interface{}) { delete(c, "preferred_username") }, "", tocookie.ErrClaimInvalid},
+		"none":            {"none", "rsa1", func(c map[string]interface{}) {}, "", tocookie.ErrBadSignature},
+		"HS256":           {"HS256", "rsa1", func(c map[string]interface{}) {}, "", tocookie.ErrBadSignature},
+		"wrong key alg":   {AlgES256, "rsa1", func(c map[string]interface{}) {}, "", tocookie.ErrUnknownKey},
+		"encryption key":  {AlgRS256, "enc", func(c map[string]interface{}) {}, "", tocookie.ErrUnknownKey},
+		"unknown key":     {AlgRS256, "rsa2", func(c map[string]interface{}) {}, "", tocookie.ErrUnknownKey},
+		"tampered claims": {AlgRS256, "rsa1", nil, "", tocookie.ErrBadSignature},
+	}
+	for name, test := range tests {
+		claims := p.claims(now)
+		token := ""
+		if test.modify == nil {
+			parts := strings.Split(p.sign(t, test.alg, test.kid, claims), ".")
+			claims["preferred_username"] = "admin"
+			forged := strings.Split(p.sign(t, test.alg, test.kid, claims), ".")
+			token = parts[0] + "." + forged[1] + "." + parts[2]
+		} else {
+			test.modify(claims)
+			token = p.sign(t, test.alg, test.kid, claims)
+		}
+		if _, err := v.Verify(context.Background(), token, test.nonce); !errors.Is(err, test.expected) || (err == nil) != (test.expected == nil) {
+			t.Errorf("%v: Verify expected %v, actual: %v", name, test.expected, err)
+		}
+	}
+	if _, err := v.Verify(context.Background(), "not.a-token", ""); !errors.Is(err, tocookie.ErrMalformed) {
+		t.Errorf("Verify malformed expected ErrMalformed, actual: %v", err)
+	}
+}
+
+func TestVerifyIssuerTrailingSlash(t *testing.T) {
+	p := newTestProvider(t)
+	p.issuerPath = "/"
+	now := time.Now()
+	v := NewVerifier(Config{Issuer: p.issuer(), ClientID: "traffic-ops"})
+	if _, err := v.Verify(context.Background(), p.sign(t, AlgRS256, "rsa1", p.claims(now)), ""); err != nil {
+		t.Errorf("Verify of issuer with trailing slash expected nil error, actual: %v", err)
+	}
+	claims := p.claims(now)
+	claims["iss"] = p.URL
+	if _, err := v.Verify(context.Background(), p.sign(t, AlgRS256, "rsa1", claims), ""); !errors.Is(err, tocookie.ErrIssuerMismatch) {
+		t.Errorf("Verify of token issuer without trailing slash expected ErrIssuerMismatch, actual: %v", err)
+	}
+	trimmed := NewVerifier(Config{Issuer: p.URL, ClientID: "traffic-ops"})
+	if _, err := trimmed.Verify(context.Background(), p.sign(t, AlgRS256, "rsa1", p.claims(now)), ""); err == nil {
+		t.Errorf("Verify with issuer configured without its trailing slash expected error, actual: nil")
+	}
+}
+
+func TestVerifyKeyRotation(t *testing.T) {
+	p := newTestProvider(t)
+	now := time.Now()
+	v := NewVerifier(Config{Issuer: p.URL, ClientID: "traffic-ops", Now: func() time.Time { return now }})
+	if _, err := v.Verify(context.Background(), p.sign(t, AlgRS256, "rsa1", p.claims(now)), ""); err != nil {
+		t.Fatalf("Verify expected nil error, actual: %v", err)
+	}
+
+	p.kids[0] = "rsa2"
+	if _, err := v.Verify(context.Background(), p.sign(t, AlgRS256, "rsa2", p.claims(now)), ""); !errors.Is(err, tocookie.ErrUnknownKey) {
+		t.Errorf("Verify of new key within MinRefreshInterval expected ErrUnknownKey, actual: %v", err)
+	}
+	now = now.Add(MinRefreshInterval)
+	if _, err := v.Verify(context.Background(), p.sign(t, AlgRS256, "rsa2", p.claims(now)), ""); err != nil {
+		t.Errorf("Verify of new key after MinRefreshInterval expected nil error, actual: %v", err)
+	}
+	if hits := atomic.LoadInt32(&p.jwksHits); hits != 2 {
+		t.Errorf("Verify expected JWKS fetched twice, actual: %v", hits)
+	}
+}
+
+func TestCacheTTL(t *testing.T) {
+	tests := map[string]time.Duration{
+		"":                        DefaultJWKSCacheTTL,
+		"public, max-age=7200":    2 * time.Hour,
+		"max-age=5":               MinRefreshInterval,
+		"no-store":                MinRefreshInterval,
+		"max-age=oops, no-cache":  MinRefreshInterval,
+		"must-revalidate, public": DefaultJWKSCacheTTL,
+	}
+	for header, expected := range tests {
+		if actual := cacheTTL(http.Header{"Cache-Control": {header}}); actual != expected {
+			t.Errorf("cacheTTL '%v' expected %v, actual: %v", header, expected, actual)
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	p := newTestProvider(t)
+	secret := "secret"
+	v := NewVerifier(Config{Issuer: p.URL, ClientID: "traffic-ops", UsernameClaim: "preferred_username", RolesClaim: "groups"})
+	handler := v.Handler(secret, func(r *http.Request) string { return "n-0S6_WzA2Mj" })
+
+	post := func(token string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/login/oidc", strings.NewReader(url.Values{"id_token": {token}}.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		handler(w, r)
+		return w
+	}
+
+	w := post(p.sign(t, AlgES256, "ec1", p.claims(time.Now())))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Handler expected status 200, actual: %v %v", w.Code, w.Body)
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("Handler expected session cookie, actual: %v", cookies)
+	}
+	c, err := tocookie.Parse(secret, cookies[0].Value)
+	if err != nil || c.AuthData != "alice" || !c.HasRole("ops") {
+		t.Errorf("Handler expected cookie of alice with role ops, actual: %+v %v", c, err)
+	}
+
+	if w := post(p.sign(t, AlgRS256, "rsa1", map[string]interface{}{"iss": p.URL})); w.Code != http.StatusUnauthorized {
+		t.Errorf("Handler invalid token expected status 401, actual: %v", w.Code)
+	}
+	token := p.sign(t, AlgRS256, "rsa1", p.claims(time.Now()))
+	p.Close()
+	down := NewVerifier(Config{Issuer: p.URL, ClientID: "traffic-ops"}).Handler(secret, nil)
+	r := httptest.NewRequest(http.MethodPost, "/login/oidc", strings.NewReader(url.Values{"id_token": {token}}.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w = httptest.NewRecorder()
+	down(w, r)
+	if w.Code != http.StatusBadGateway {
+		t.Errorf("Handler with provider down expected status 502, actual: %v", w.Code)
+	}
+}
@@ -0,0 +1,53 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// opaqueIDLen is the length of the hex-encoded IDs of opaque tokens, as returned by NewJTI.
+const opaqueIDLen = 32
+
+// NewOpaque mints a signed opaque token, for sessions whose state is kept server-side. The token carries no claims, only a random ID, which is returned along with the token so the caller can store the session under it.
+//
+// The version and hash options apply as they do to New. Claim options, such as WithAudience, are ignored.
+func NewOpaque(key string, opts ...Option) (string, string, error) {
+	o := newOptions(opts)
+	id, err := NewJTI()
+	if err != nil {
+		return "", "", err
+	}
+	token := encodeSigned([]byte(id), []byte(key), o)
+	if token == "" {
+		return "", "", errors.New("unable to sign token with the given options")
+	}
+	return token, id, nil
+}
+
+// ParseOpaque verifies the signature of a token minted by NewOpaque, and returns its ID. Forged tokens are rejected here, before the session is looked up.
+//
+// The token has no expiration; the lifetime of the session must be enforced by the store.
+func ParseOpaque(key, token string, opts ...Option) (string, error) {
+	txtBytes, err := decodeSigned(token, []byte(key), newOptions(opts))
+	if err != nil {
+		return "", err
+	}
+	id := string(txtBytes)
+	if _, err := hex.DecodeString(id); err != nil || len(id) != opaqueIDLen {
+		return "", fmt.Errorf("token is not opaque: malformed id '%s'", id)
+	}
+	return id, nil
+}
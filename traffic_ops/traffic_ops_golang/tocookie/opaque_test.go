@@ -0,0 +1,66 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"crypto"
+	"testing"
+	"time"
+)
+
+func TestOpaqueRoundTrip(t *testing.T) {
+	for name, opts := range map[string][]Option{
+		"default": nil,
+		"v1":      {WithVersion(Version1)},
+		"sha256":  {WithHash(crypto.SHA256)},
+	} {
+		token, id, err := NewOpaque("secret", opts...)
+		if err != nil {
+			t.Fatalf("%v: NewOpaque expected nil error, actual: %v", name, err)
+		}
+		actual, err := ParseOpaque("secret", token, opts...)
+		if err != nil {
+			t.Fatalf("%v: ParseOpaque expected nil error, actual: %v", name, err)
+		}
+		if actual != id {
+			t.Errorf("%v: ParseOpaque expected id '%v', actual: '%v'", name, id, actual)
+		}
+	}
+
+	_, a, _ := NewOpaque("secret")
+	_, b, _ := NewOpaque("secret")
+	if a == b {
+		t.Errorf("NewOpaque expected unique ids, actual: '%v' twice", a)
+	}
+}
+
+func TestParseOpaqueRejects(t *testing.T) {
+	token, _, err := NewOpaque("secret")
+	if err != nil {
+		t.Fatalf("NewOpaque expected nil error, actual: %v", err)
+	}
+	tests := map[string]string{
+		"wrong key":   token,
+		"claims":      New("alice", time.Now().Add(time.Minute), "other"),
+		"not a token": "garbage",
+	}
+	for name, token := range tests {
+		if _, err := ParseOpaque("other", token); err == nil {
+			t.Errorf("%v: ParseOpaque expected error, actual nil", name)
+		}
+	}
+
+	if _, _, err := NewOpaque("secret", WithVersion(maxVersion+1)); err == nil {
+		t.Errorf("NewOpaque with unsupported version expected error, actual nil")
+	}
+}
@@ -0,0 +1,150 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"crypto"
+	"net/http"
+	"os"
+	"time"
+)
+
+// Option configures how cookies are minted by New and Refresh, and how they are read by Parse. Parse must be given options compatible with those the cookie was minted with.
+type Option func(*options)
+
+type options struct {
+	hash                  crypto.Hash
+	tagLength             int
+	version               int
+	versionPolicy         *VersionPolicy
+	fieldNames            map[string]string
+	jti                   string
+	nonces                NonceStore
+	fingerprint           string
+	notBefore             int64
+	audience              string
+	issuer                string
+	millis                bool
+	rfc3339               bool
+	failedAttempts        int
+	roles                 []string
+	compression           string
+	compressionThreshold  int
+	maxDecompressedSize   int64
+	logger                Logger
+	pollPeriod            time.Duration
+	perUserKeys           bool
+	kdfIterations         int
+	skipExpiry            bool
+	maxLifetime           time.Duration
+	maxAuthAge            time.Duration
+	authTime              time.Time
+	padding               rune
+	paddingSet            bool
+	allErrors             bool
+	claimValidators       map[string]func(value interface{}) error
+	observer              func(outcome Outcome, err error)
+	trim                  bool
+	urlEncoding           bool
+	strict                bool
+	maxCookieSize         int
+	maxPayloadSize        int
+	maxJSONDepth          int
+	disallowUnknownFields bool
+	canonicalBase64       bool
+	requireIdentity       bool
+	maxExpiry             time.Duration
+	secretValidation      bool
+	signatureDiagnostics  bool
+	hooks                 []Hook
+	metrics               Metrics
+	auditSink             AuditSink
+	auditClientIP         string
+	tracer                Tracer
+	keyRetention          time.Duration
+	binding               *ClientBinding
+	failureLimit          *FailureLimit
+	aad                   []byte
+	keyID                 string
+	legacyHashes          []crypto.Hash
+	keySeparation         KeySeparation
+	refreshWindow         time.Duration
+	refreshQueue          *RefreshQueue
+	extractors            []Extractor
+	encrypt               bool
+	claims                map[string]interface{}
+	maxClaimsSize         int
+	revocations           RevocationStore
+	idleTimeout           time.Duration
+	signer                crypto.Signer
+	publicKey             crypto.PublicKey
+	reloadSignals         []os.Signal
+	macer                 MACer
+	cookieName            string
+	cookieDomain          string
+	cookiePath            string
+	insecure              bool
+	noHTTPOnly            bool
+	sameSite              http.SameSite
+	chunkSize             int
+	leeway                time.Duration
+	expiryGrace           time.Duration
+	clock                 func() time.Time
+	rotation              RotationStore
+	rotationGrace         time.Duration
+	capabilities          []string
+	authMethods           []string
+	authLevel             AuthLevel
+	capabilityTable       map[string]int
+	capabilityNames       []string
+	tenancy               *Tenancy
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{hash: DefaultHash, logger: nopLogger{}, pollPeriod: DefaultKeyFilePollPeriod, maxDecompressedSize: DefaultMaxDecompressedSize, maxClaimsSize: DefaultMaxClaimsSize, rotationGrace: DefaultRotationGrace, maxCookieSize: DefaultMaxCookieSize, maxPayloadSize: DefaultMaxPayloadSize, maxJSONDepth: DefaultMaxJSONDepth}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// setClaims sets the claims configured by the options on a cookie being minted.
+func (o *options) setClaims(c *Cookie) {
+	c.JTI = o.jti
+	c.Fingerprint = o.fingerprint
+	c.NotBefore = o.notBefore
+	c.Audience = o.audience
+	c.FailedAttempts = o.failedAttempts
+	c.Roles = o.roles
+	c.Capabilities = o.capabilities
+	o.setAuthClaims(c)
+	if o.tenancy != nil {
+		tenancy := *o.tenancy
+		tenancy.Subtree = append([]int(nil), o.tenancy.Subtree...)
+		c.Tenancy = &tenancy
+	}
+	if o.issuer != "" {
+		c.By = o.issuer
+	}
+}
+
+// setExpiration sets the expiration of a cookie being minted or refreshed, in every configured format. A cookie which already has millisecond or RFC3339 expiration keeps it.
+func (o *options) setExpiration(c *Cookie, expiration time.Time) {
+	c.ExpiresUnix = expiration.Unix()
+	if o.millis || c.ExpiresMillis != 0 {
+		c.ExpiresMillis = expiration.UnixNano() / int64(time.Millisecond)
+	}
+	if o.rfc3339 || c.ExpiresRFC3339 != "" {
+		c.ExpiresRFC3339 = c.Expires().UTC().Format(time.RFC3339Nano)
+	}
+}
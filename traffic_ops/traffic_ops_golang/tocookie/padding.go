@@ -0,0 +1,94 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// WithPadding sets the character used to pad the base64 payload of version 0 cookies. By default, cookies are minted as Perl Mojolicious mints them, in the standard base64 alphabet padded with '-', so Perl Traffic Ops can read them. With base64.NoPadding, the payload is unpadded, in the base64url alphabet, which Perl can't read. With any other padding, the payload is in the standard alphabet, unless the padding is itself in the standard alphabet. The padding is signed.
+//
+// Without WithPadding, Parse accepts unpadded cookies, Mojolicious cookies, and cookies in standard base64 with '=' padding, by treating every '-' before the signature as padding and trying each alphabet. This is ambiguous, since '-' is also a base64url character: unpadded payloads containing it can't be parsed. Given WithPadding, Parse only accepts cookies with that padding, which removes the ambiguity; in particular, WithPadding(base64.NoPadding) reads every unpadded payload correctly. But cookies minted with any other padding, including those minted by Perl, are then rejected, so every minter and parser of the cookie must be changed together.
+func WithPadding(padding rune) Option {
+	return func(o *options) {
+		o.padding = padding
+		o.paddingSet = true
+	}
+}
+
+// v0Encoding returns the base64 encoding of version 0 payloads, as configured by WithPadding.
+func (o *options) v0Encoding() (*base64.Encoding, error) {
+	if !o.paddingSet {
+		return mojoEncoding, nil
+	}
+	if o.padding == base64.NoPadding {
+		return base64.RawURLEncoding, nil
+	}
+	if o.padding <= ' ' || o.padding > '~' || strings.ContainsRune(invalidPadding, o.padding) {
+		return nil, fmt.Errorf("invalid base64 padding %q", o.padding)
+	}
+	if o.padding == '+' || o.padding == '/' {
+		return base64.URLEncoding.WithPadding(o.padding), nil
+	}
+	return base64.StdEncoding.WithPadding(o.padding), nil
+}
+
+// mojoEncoding is the encoding of Mojolicious session cookies.
+var mojoEncoding = base64.StdEncoding.WithPadding('-')
+
+// invalidPadding are the printable characters which can't be padding: those both base64 alphabets share, those not allowed in cookie values, and the version separator, which never appears in version 0 cookies.
+const invalidPadding = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789\",;\\" + versionSep
+
+// v0FallbackEncodings are the encodings of version 0 payloads accepted by Parse without WithPadding, in the order they are tried: unpadded base64url, as minted by Go; standard base64 with '=' padding, as minted by other systems; and unpadded standard base64, as left by splitV0 of Mojolicious cookies. Trying several encodings is safe, because a payload valid in more than one has no characters which differ between the alphabets, so it decodes to the same bytes in each.
+var v0FallbackEncodings = []*base64.Encoding{base64.RawURLEncoding, base64.StdEncoding, base64.RawStdEncoding}
+
+// decodeV0Payload decodes the payload of a version 0 cookie in the first of the encodings, usually v0FallbackEncodings, it is valid in, for Parse without WithPadding. The payload is decoded into dst, which is grown if need be.
+func decodeV0Payload(dst, payload []byte, encodings []*base64.Encoding) ([]byte, error) {
+	var err error
+	for _, encoding := range encodings {
+		txtBytes, decodeErr := decodeBase64(dst, encoding, payload)
+		if decodeErr == nil {
+			return txtBytes, nil
+		}
+		if err == nil {
+			err = decodeErr
+		}
+	}
+	return nil, fmt.Errorf("%w: error decoding base64 data: %w", ErrMalformed, err)
+}
+
+// decodeBase64 decodes the base64 text into dst, which is grown if need be, and returns the decoded bytes.
+func decodeBase64(dst []byte, encoding *base64.Encoding, text []byte) ([]byte, error) {
+	n := encoding.DecodedLen(len(text))
+	if cap(dst) < n {
+		dst = make([]byte, n)
+	}
+	n, err := encoding.Decode(dst[:n], text)
+	return dst[:n], err
+}
+
+// splitV0Padded splits a version 0 cookie minted with the padding configured by WithPadding. Unlike splitV0, the entire text before the last "--" is the payload.
+func splitV0Padded(cookie string) (signedCookie, error) {
+	sepPos := strings.LastIndex(cookie, "--")
+	if sepPos == -1 {
+		return signedCookie{}, fmt.Errorf("%w -- no signature", ErrMalformed)
+	}
+	sigBytes, err := hex.DecodeString(cookie[sepPos+2:])
+	if err != nil {
+		return signedCookie{}, fmt.Errorf("%w: error decoding signature: %w", ErrMalformed, err)
+	}
+	return signedCookie{version: Version0, signed: cookie[:sepPos], payload: cookie[:sepPos], sig: sigBytes}, nil
+}
@@ -0,0 +1,99 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWithPadding(t *testing.T) {
+	secret := "secret"
+	for _, padding := range []rune{base64.NoPadding, '-', '=', '~', '/'} {
+		cookie := New("alice", time.Now().Add(time.Minute), secret, WithPadding(padding))
+		if cookie == "" {
+			t.Fatalf("%q: New expected cookie, actual empty", padding)
+		}
+		if c, err := Parse(secret, cookie, WithPadding(padding)); err != nil || c.AuthData != "alice" {
+			t.Errorf("%q: Parse expected alice and nil error, actual: %+v %v", padding, c, err)
+		}
+	}
+
+	for _, padding := range []rune{'A', '0', '\n', 'é', '.'} {
+		if cookie := New("alice", time.Now().Add(time.Minute), secret, WithPadding(padding)); cookie != "" {
+			t.Errorf("%q: New with invalid padding expected empty cookie, actual: %v", padding, cookie)
+		}
+	}
+}
+
+func TestWithPaddingMojolicious(t *testing.T) {
+	secret := "secret"
+	msg := `{"auth_data":"alice","expires":4102444800,"x":12}`
+	padded := encodeV0([]byte(msg), []byte(secret), newOptions([]Option{WithPadding('-')}))
+	if !strings.Contains(padded, "----") {
+		t.Fatalf("WithPadding('-') expected padded payload, actual: %v", padded)
+	}
+	if expected := newMojoCookie(msg, secret); padded != expected {
+		t.Errorf("WithPadding('-') expected Mojolicious cookie '%v', actual: '%v'", expected, padded)
+	}
+	if _, err := Parse(secret, padded); err != nil {
+		t.Errorf("Parse default padding expected Mojolicious cookie accepted, actual: %v", err)
+	}
+	if _, err := Parse(secret, padded, WithPadding(base64.NoPadding)); err == nil {
+		t.Errorf("Parse WithPadding(NoPadding) expected Mojolicious cookie rejected, actual nil error")
+	}
+}
+
+func TestWithNoPaddingDashes(t *testing.T) {
+	secret := "secret"
+	// The unpadded base64url encoding of this payload contains '-', which the default Parse mistakes for padding.
+	msg := `{"auth_data":"~~~","expires":4102444800}`
+	cookie := encodeV0([]byte(msg), []byte(secret), newOptions([]Option{WithPadding(base64.NoPadding)}))
+	if !strings.Contains(strings.Split(cookie, "--")[0], "-") {
+		t.Fatalf("expected payload containing '-', actual: %v", cookie)
+	}
+	if c, err := Parse(secret, cookie, WithPadding(base64.NoPadding)); err != nil || c.AuthData != "~~~" {
+		t.Errorf("Parse WithPadding(NoPadding) expected '~~~' and nil error, actual: %+v %v", c, err)
+	}
+}
+
+// stdPaddedCookies are cookies in standard base64 with '=' padding, as minted by a federated system, signed with the secret "federation-secret".
+var stdPaddedCookies = map[string]string{
+	"alice?>": "eyJhdXRoX2RhdGEiOiJhbGljZT8+IiwiYnkiOiJmZWRlcmF0ZWQtc3NvIiwiZXhwaXJlcyI6NDEwMjQ0NDgwMH0=--13a0e6ffce7c82308d9f16c4239224f011137af5",
+	"bob???":  "eyJhdXRoX2RhdGEiOiJib2I/Pz8iLCJieSI6ImZlZGVyYXRlZC1zc28iLCJleHBpcmVzIjo0MTAyNDQ0ODAwfQ==--1cc02f182405fc64c09183b58156ec2d9e9b4174",
+}
+
+func TestParseStdPadding(t *testing.T) {
+	secret := "federation-secret"
+	for user, cookie := range stdPaddedCookies {
+		for name, opts := range map[string][]Option{"fallback": nil, "explicit": {WithPadding('=')}} {
+			c, err := Parse(secret, cookie, opts...)
+			if err != nil {
+				t.Errorf("%v %v: Parse expected nil error, actual: %v", user, name, err)
+			} else if c.AuthData != user || c.By != "federated-sso" {
+				t.Errorf("%v %v: Parse expected user and issuer claims, actual: %+v", user, name, c)
+			}
+		}
+		if _, err := Parse("wrong", cookie); err == nil {
+			t.Errorf("%v: Parse wrong secret expected error, actual nil", user)
+		}
+	}
+
+	// Mojolicious cookies with payloads containing '+' or '/' are standard base64 once their padding is stripped.
+	msg := `{"auth_data":"bob???","expires":4102444800}`
+	if c, err := Parse("secret", newMojoCookie(msg, "secret")); err != nil || c.AuthData != "bob???" {
+		t.Errorf("Parse Mojolicious cookie with '/' expected bob??? and nil error, actual: %+v %v", c, err)
+	}
+}
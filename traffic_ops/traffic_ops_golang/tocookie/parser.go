@@ -0,0 +1,152 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"context"
+	"crypto/hmac"
+	"fmt"
+	"hash"
+	"sync"
+)
+
+// Parser parses cookies with a fixed secret and options, like Parse, for services such as auth gateways which parse cookies at high rates. It reuses its HMACs, which are bound to the secret, and its intermediate buffers between calls, so verifying the signature and decoding the payload allocate almost nothing, and most of what remains is unmarshalling the claims, while malformed cookies are rejected without allocating at all. See BenchmarkParser and BenchmarkParserMalformed. It is safe for concurrent use.
+//
+// Cookies returned by a Parser have no RawPayload, as the payload is decoded into a reused buffer. Parsers given WithPerUserKeys derive a key per cookie, and parse like Parse, without reusing HMACs.
+type Parser struct {
+	secret string
+	// key is the secret, as the key of the HMACs.
+	key []byte
+	o   *options
+	// err is the error of WithSecretValidation for the secret, which every Parse returns.
+	err error
+	// macs are HMACs of the configured hash keyed with the secret.
+	macs sync.Pool
+	// bufs are *parseBuffers.
+	bufs sync.Pool
+}
+
+// parseBuffers are the intermediate buffers of Parser.Parse.
+type parseBuffers struct {
+	// text holds the signed text, and then the base64 payload.
+	text    []byte
+	sum     []byte
+	payload []byte
+}
+
+// NewParser returns a Parser which parses cookies signed with the secret, with the given options, which are the same as those of Parse.
+func NewParser(secret string, opts ...Option) *Parser {
+	p := &Parser{secret: secret, key: []byte(secret), o: newOptions(opts)}
+	p.err = p.o.checkSecret(secret)
+	macKey := p.o.macKey(p.key)
+	p.macs.New = func() interface{} { return hmac.New(p.o.hash.New, macKey) }
+	p.bufs.New = func() interface{} { return &parseBuffers{} }
+	return p
+}
+
+// Parse verifies and decodes a cookie, and validates its claims, exactly as the package-level Parse does with the Parser's secret and options.
+func (p *Parser) Parse(cookie string) (*Cookie, error) {
+	return p.ParseContext(context.Background(), cookie)
+}
+
+// ParseContext is Parse, running the hooks of WithHooks with the context, exactly as the package-level ParseContext does.
+func (p *Parser) ParseContext(ctx context.Context, cookie string) (*Cookie, error) {
+	start := p.o.startTimer()
+	ctx, span := p.o.startSpan(ctx, SpanParse)
+	c, err := p.parseContext(ctx, cookie)
+	p.o.endParseSpan(span, c, err)
+	p.o.observe(start, c, err)
+	return c, err
+}
+
+func (p *Parser) parseContext(ctx context.Context, cookie string) (*Cookie, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	c, err := p.parse(cookie)
+	if err != nil {
+		return c, err
+	}
+	if err := p.o.runHooks(ctx, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (p *Parser) parse(cookie string) (*Cookie, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	if p.o.perUserKeys || p.o.macer != nil {
+		c, err := parse(p.secret, cookie, p.o)
+		if c != nil {
+			c.payload = nil
+		}
+		return c, err
+	}
+	if err := p.o.precheck(cookie); err != nil {
+		return nil, err
+	}
+	s, err := splitCookie(cookie, p.o)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.o.checkHash(); err != nil {
+		return nil, err
+	}
+	bufs := p.bufs.Get().(*parseBuffers)
+	mac := p.macs.Get().(hash.Hash)
+	c, err := parseHMAC(s, p.key, mac, bufs, false, p.o)
+	p.macs.Put(mac)
+	p.bufs.Put(bufs)
+	return c, err
+}
+
+// parseBufferPool holds the *parseBuffers of Parse, which are shared by every secret and option.
+var parseBufferPool = sync.Pool{New: func() interface{} { return &parseBuffers{} }}
+
+// parseHMAC verifies the HMAC of the split cookie with mac, which must be an HMAC of the configured hash keyed with the secret, and only then decodes and validates it, using bufs for its intermediate buffers. The payload is only kept as the RawPayload of the cookie if keepPayload is set, as it is otherwise in a buffer which will be reused.
+func parseHMAC(s signedCookie, secret []byte, mac hash.Hash, bufs *parseBuffers, keepPayload bool, o *options) (*Cookie, error) {
+	if s.header.Alg != "" {
+		return nil, fmt.Errorf("%w: cookie signed with %s, not an HMAC", ErrBadSignature, s.header.Alg)
+	}
+	mac.Reset()
+	o.writeAAD(mac)
+	bufs.text = append(bufs.text[:0], s.signed...)
+	mac.Write(bufs.text)
+	bufs.sum = mac.Sum(bufs.sum[:0])
+	if !o.tagMatches(s.sig, bufs.sum) && !o.verifiesLegacy(bufs.text, s.sig, secret) {
+		return nil, o.badSignature(s, secret)
+	}
+	if s.header.Enc != "" {
+		s.key = secret
+	}
+
+	bufs.text = append(bufs.text[:0], s.payload...)
+	txtBytes, err := s.decodePayloadInto(bufs.payload[:0], bufs.text, o)
+	if err != nil {
+		return nil, err
+	}
+	if s.header.Zip == "" && s.header.Enc == "" {
+		bufs.payload = txtBytes[:0]
+	}
+	cookieData, err := decodeClaims(txtBytes, o)
+	if err != nil {
+		return nil, err
+	}
+	cookieData.payload = nil
+	if keepPayload {
+		cookieData.payload = append([]byte(nil), txtBytes...)
+	}
+	return checkVerified(cookieData, o)
+}
@@ -0,0 +1,116 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"crypto"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParserMatchesParse(t *testing.T) {
+	secret := "secret"
+	expiration := time.Now().Add(time.Minute)
+	tests := map[string]struct {
+		cookie string
+		opts   []Option
+	}{
+		"version 0":     {New("alice", expiration, secret), nil},
+		"mojolicious":   {perlCookies[`{"auth_data":"operator1","expires":4102444800}`], nil},
+		"version 1":     {New("alice", expiration, secret, WithVersion(Version1), WithHash(crypto.SHA256)), []Option{WithHash(crypto.SHA256)}},
+		"compressed":    {New("alice", expiration, secret, WithCompression(CodecGzip)), nil},
+		"aad":           {New("alice", expiration, secret, WithAssociatedData([]byte("host"))), []Option{WithAssociatedData([]byte("host"))}},
+		"wrong aad":     {New("alice", expiration, secret, WithAssociatedData([]byte("host"))), []Option{WithAssociatedData([]byte("other"))}},
+		"padded":        {New("alice", expiration, secret, WithPadding('=')), []Option{WithPadding('=')}},
+		"truncated tag": {New("alice", expiration, secret, WithTagLength(MinTagLength)), []Option{WithTagLength(MinTagLength)}},
+		"per user keys": {New("alice", expiration, secret, WithPerUserKeys()), []Option{WithPerUserKeys()}},
+		"audience":      {New("alice", expiration, secret, WithAudience("a")), []Option{WithAudience("b")}},
+		"expired":       {New("alice", time.Now().Add(-time.Minute), secret), nil},
+		"wrong secret":  {New("alice", expiration, "wrong"), nil},
+		"malformed":     {"not a cookie", nil},
+		"trimmed":       {` "` + New("alice", expiration, secret) + `" `, []Option{WithTrim()}},
+		"bad hash":      {New("alice", expiration, secret), []Option{WithHash(crypto.MD4)}},
+	}
+	for name, test := range tests {
+		secret := secret
+		if name == "mojolicious" {
+			secret = "mysecret"
+		}
+		expected, expectedErr := Parse(secret, test.cookie, test.opts...)
+		p := NewParser(secret, test.opts...)
+		for i := 0; i < 2; i++ {
+			actual, err := p.Parse(test.cookie)
+			if (err == nil) != (expectedErr == nil) || err != nil && err.Error() != expectedErr.Error() {
+				t.Errorf("%v: Parser.Parse expected error %v, actual: %v", name, expectedErr, err)
+			}
+			if expected != nil {
+				expected.payload = nil
+			}
+			if !reflect.DeepEqual(actual, expected) {
+				t.Errorf("%v: Parser.Parse expected %+v, actual: %+v", name, expected, actual)
+			}
+		}
+	}
+}
+
+func TestParserDoesNotRetainBuffers(t *testing.T) {
+	secret := "secret"
+	p := NewParser(secret)
+	c, err := p.Parse(NewRawMsg([]byte(`{"auth_data":"alice","expires":4102444800,"flash":{"message":"saved"}}`), []byte(secret)))
+	if err != nil {
+		t.Fatalf("Parser.Parse expected nil error, actual: %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		p.Parse(NewRawMsg([]byte(`{"auth_data":"mallory","expires":4102444800,"flash":{"message":"evil!"}}`), []byte(secret)))
+	}
+	if c.AuthData != "alice" || string(c.Extra["flash"]) != `{"message":"saved"}` {
+		t.Errorf("Parser.Parse expected cookie unaffected by later parses, actual: %+v %s", c, c.Extra["flash"])
+	}
+	if c.RawPayload() != nil {
+		t.Errorf("Parser.Parse expected nil RawPayload, actual: '%s'", c.RawPayload())
+	}
+}
+
+func TestParserConcurrent(t *testing.T) {
+	secret := "secret"
+	p := NewParser(secret, WithAssociatedData([]byte("host")))
+	wg := sync.WaitGroup{}
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				user := fmt.Sprintf("user%d-%d", i, j)
+				cookie := New(user, time.Now().Add(time.Minute), secret, WithAssociatedData([]byte("host")))
+				if j%3 == 0 {
+					cookie = New(user, time.Now().Add(time.Minute), "wrong", WithAssociatedData([]byte("host")))
+				}
+				c, err := p.Parse(cookie)
+				if j%3 == 0 {
+					if !errors.Is(err, ErrBadSignature) {
+						t.Errorf("Parser.Parse of cookie with wrong secret expected ErrBadSignature, actual: %v", err)
+					}
+					continue
+				}
+				if err != nil || c.AuthData != user {
+					t.Errorf("Parser.Parse expected %v and nil error, actual: %+v %v", user, c, err)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
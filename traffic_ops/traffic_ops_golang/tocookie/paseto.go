@@ -0,0 +1,335 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/chacha20"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// The versions of the PASETO specification supported by PASETOKey. Version 2 is the widely implemented one; version 4 is its successor, which authenticates local tokens with BLAKE2b rather than Poly1305, and is preferred for new deployments.
+const (
+	PASETOVersion2 = 2
+	PASETOVersion4 = 4
+)
+
+// The purposes of PASETO tokens: local tokens are encrypted and authenticated with a shared key, and public tokens are signed with an Ed25519 key, and readable by anyone.
+const (
+	pasetoLocal  = "local"
+	pasetoPublic = "public"
+)
+
+// PASETOLocalKeySize is the size in bytes of the keys of local PASETO tokens.
+const PASETOLocalKeySize = 32
+
+// pasetoTimeClaims are the registered claims PASETO stores as RFC 3339 times, rather than the seconds since the Unix epoch of cookies and JWTs.
+var pasetoTimeClaims = []string{"exp", "iat", "nbf"}
+
+// PASETOKey is a key which mints or verifies Platform-Agnostic Security Tokens, of a single version and purpose, which are chosen by the key rather than by the token, so tokens can't be verified as another version or purpose than they were minted as.
+type PASETOKey struct {
+	version int
+	purpose string
+	secret  []byte
+	priv    ed25519.PrivateKey
+	pub     ed25519.PublicKey
+}
+
+// PASETOLocalKey returns a key which encrypts and decrypts local tokens of the version, PASETOVersion2 or PASETOVersion4, with the secret, which must be PASETOLocalKeySize random bytes. Unlike the secrets of cookies, it is used as it is, so it mustn't be a password, nor a secret shared with other formats.
+func PASETOLocalKey(version int, secret []byte) PASETOKey {
+	return PASETOKey{version: version, purpose: pasetoLocal, secret: append([]byte(nil), secret...)}
+}
+
+// PASETOPublicKey returns a key which signs public tokens of the version with the private key, and verifies them with its public key.
+func PASETOPublicKey(version int, priv ed25519.PrivateKey) PASETOKey {
+	return PASETOKey{version: version, purpose: pasetoPublic, priv: priv, pub: priv.Public().(ed25519.PublicKey)}
+}
+
+// PASETOVerifyKey returns a key which only verifies public tokens of the version signed with the private key of the public key, for services which accept tokens but don't mint them.
+func PASETOVerifyKey(version int, pub ed25519.PublicKey) PASETOKey {
+	return PASETOKey{version: version, purpose: pasetoPublic, pub: pub}
+}
+
+// header returns the header of the tokens of the key, e.g. "v4.local.".
+func (k PASETOKey) header() string {
+	return "v" + strconv.Itoa(k.version) + "." + k.purpose + "."
+}
+
+// check returns an error if the key isn't of a supported version, or its key material is the wrong size.
+func (k PASETOKey) check() error {
+	if k.version != PASETOVersion2 && k.version != PASETOVersion4 {
+		return fmt.Errorf("unsupported PASETO version %d", k.version)
+	}
+	switch {
+	case k.purpose == pasetoLocal && len(k.secret) != PASETOLocalKeySize:
+		return fmt.Errorf("PASETO local key is %d bytes, not %d", len(k.secret), PASETOLocalKeySize)
+	case k.purpose == pasetoPublic && len(k.pub) != ed25519.PublicKeySize:
+		return fmt.Errorf("PASETO public key is %d bytes, not %d", len(k.pub), ed25519.PublicKeySize)
+	}
+	return nil
+}
+
+// NewPASETO mints a PASETO token of the version and purpose of the key, carrying the same claims New would put in a cookie. The user is the "sub" claim, the expiration is "exp", and the issuer is "iss", as in NewJWT, and the times "exp", "iat", and "nbf" are RFC 3339 strings, as PASETO requires. Options setting claims, such as WithAudience, WithRoles, and WithClaims, are applied, while options of the cookie format, such as WithVersion and WithCompression, are ignored. It returns an empty string if the token can't be minted, e.g. with a key which only verifies.
+func NewPASETO(user string, expiration time.Time, key PASETOKey, opts ...Option) string {
+	o := newOptions(opts)
+	c, err := o.newSession(user, expiration)
+	if err != nil {
+		return ""
+	}
+	token, err := encodePASETO(c, key, rand.Reader, o)
+	if err != nil {
+		return ""
+	}
+	return token
+}
+
+// ParsePASETO verifies a PASETO token minted by NewPASETO, or by any issuer using the same claims, and validates its claims as Parse validates a cookie's. The token is rejected unless it is of the version and purpose of the key, so neither can be confused with another. Footers are authenticated, but otherwise ignored, and version 4 tokens must have no implicit assertion.
+//
+// Errors for tokens which aren't authentic wrap ErrBadSignature or ErrMalformed, as Parse's do.
+func ParsePASETO(token string, key PASETOKey, opts ...Option) (*Cookie, error) {
+	o := newOptions(opts)
+	return o.parseWith(context.Background(), func() (*Cookie, error) { return parsePASETO(token, key, o) })
+}
+
+func parsePASETO(token string, key PASETOKey, o *options) (*Cookie, error) {
+	if err := key.check(); err != nil {
+		return nil, err
+	}
+	if err := o.checkCookieSize(token); err != nil {
+		return nil, err
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 && len(parts) != 4 {
+		return nil, fmt.Errorf("%w: PASETO token has %d parts, expected 3 or 4", ErrMalformed, len(parts))
+	}
+	if h := parts[0] + "." + parts[1] + "."; h != key.header() {
+		return nil, fmt.Errorf("%w: PASETO token is %s, not %s", ErrBadSignature, strings.TrimSuffix(h, "."), strings.TrimSuffix(key.header(), "."))
+	}
+	body, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: error decoding PASETO token: %w", ErrMalformed, err)
+	}
+	footer := []byte{}
+	if len(parts) == 4 {
+		if footer, err = base64.RawURLEncoding.DecodeString(parts[3]); err != nil {
+			return nil, fmt.Errorf("%w: error decoding PASETO footer: %w", ErrMalformed, err)
+		}
+	}
+	msg, err := key.open(body, footer)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := pasetoToJWTClaims(msg)
+	if err != nil {
+		return nil, err
+	}
+	c, err := decodeClaims(payload, jwtOptions(o))
+	if err != nil {
+		return nil, err
+	}
+	return checkVerified(c, o)
+}
+
+// encodePASETO serializes the claims of the cookie as a PASETO token, reading the nonces of local tokens from random.
+func encodePASETO(c *Cookie, key PASETOKey, random io.Reader, o *options) (string, error) {
+	if err := key.check(); err != nil {
+		return "", err
+	}
+	if c.claimsSize() > o.maxClaimsSize {
+		return "", ErrClaimsTooLarge
+	}
+	payload, err := jwtOptions(o).marshal(c)
+	if err != nil {
+		return "", fmt.Errorf("encoding PASETO claims: %w", err)
+	}
+	if payload, err = jwtToPASETOClaims(payload); err != nil {
+		return "", err
+	}
+	body, err := key.seal(payload, random)
+	if err != nil {
+		return "", err
+	}
+	return key.header() + base64.RawURLEncoding.EncodeToString(body), nil
+}
+
+// seal encrypts or signs the message, without a footer, returning the body of the token.
+func (k PASETOKey) seal(msg []byte, random io.Reader) ([]byte, error) {
+	h := []byte(k.header())
+	switch {
+	case k.purpose == pasetoPublic && k.priv == nil:
+		return nil, fmt.Errorf("PASETO public key has no private key to sign with")
+	case k.purpose == pasetoPublic && k.version == PASETOVersion2:
+		return append(msg[:len(msg):len(msg)], ed25519.Sign(k.priv, pae(h, msg, nil))...), nil
+	case k.purpose == pasetoPublic:
+		return append(msg[:len(msg):len(msg)], ed25519.Sign(k.priv, pae(h, msg, nil, nil))...), nil
+	case k.version == PASETOVersion2:
+		b := make([]byte, chacha20poly1305.NonceSizeX)
+		if _, err := io.ReadFull(random, b); err != nil {
+			return nil, fmt.Errorf("generating PASETO nonce: %w", err)
+		}
+		// the nonce is a hash of the message keyed with random bytes, so a weak random source can't repeat nonces of different messages.
+		mac, _ := blake2b.New(chacha20poly1305.NonceSizeX, b)
+		mac.Write(msg)
+		n := mac.Sum(nil)
+		aead, err := chacha20poly1305.NewX(k.secret)
+		if err != nil {
+			return nil, err
+		}
+		return aead.Seal(n, n, msg, pae(h, n, nil)), nil
+	}
+	n := make([]byte, 32)
+	if _, err := io.ReadFull(random, n); err != nil {
+		return nil, fmt.Errorf("generating PASETO nonce: %w", err)
+	}
+	ek, n2, ak := k.v4LocalKeys(n)
+	c := make([]byte, len(msg))
+	cipher, err := chacha20.NewUnauthenticatedCipher(ek, n2)
+	if err != nil {
+		return nil, err
+	}
+	cipher.XORKeyStream(c, msg)
+	mac, _ := blake2b.New256(ak)
+	mac.Write(pae(h, n, c, nil, nil))
+	return append(append(n, c...), mac.Sum(nil)...), nil
+}
+
+// open decrypts or verifies the body of a token with the footer, returning its message. Tokens which aren't authentic are rejected with ErrBadSignature.
+func (k PASETOKey) open(body, footer []byte) ([]byte, error) {
+	h := []byte(k.header())
+	switch {
+	case k.purpose == pasetoPublic:
+		if len(body) < ed25519.SignatureSize {
+			return nil, fmt.Errorf("%w: PASETO token too short", ErrMalformed)
+		}
+		msg, sig := body[:len(body)-ed25519.SignatureSize], body[len(body)-ed25519.SignatureSize:]
+		m2 := pae(h, msg, footer)
+		if k.version == PASETOVersion4 {
+			m2 = pae(h, msg, footer, nil)
+		}
+		if !ed25519.Verify(k.pub, m2, sig) {
+			return nil, ErrBadSignature
+		}
+		return msg, nil
+	case k.version == PASETOVersion2:
+		if len(body) < chacha20poly1305.NonceSizeX+chacha20poly1305.Overhead {
+			return nil, fmt.Errorf("%w: PASETO token too short", ErrMalformed)
+		}
+		aead, err := chacha20poly1305.NewX(k.secret)
+		if err != nil {
+			return nil, err
+		}
+		n := body[:chacha20poly1305.NonceSizeX]
+		msg, err := aead.Open(nil, n, body[len(n):], pae(h, n, footer))
+		if err != nil {
+			return nil, ErrBadSignature
+		}
+		return msg, nil
+	}
+	if len(body) < 32+blake2b.Size256 {
+		return nil, fmt.Errorf("%w: PASETO token too short", ErrMalformed)
+	}
+	n, c, t := body[:32], body[32:len(body)-blake2b.Size256], body[len(body)-blake2b.Size256:]
+	ek, n2, ak := k.v4LocalKeys(n)
+	mac, _ := blake2b.New256(ak)
+	mac.Write(pae(h, n, c, footer, nil))
+	if !hmac.Equal(t, mac.Sum(nil)) {
+		return nil, ErrBadSignature
+	}
+	msg := make([]byte, len(c))
+	cipher, err := chacha20.NewUnauthenticatedCipher(ek, n2)
+	if err != nil {
+		return nil, err
+	}
+	cipher.XORKeyStream(msg, c)
+	return msg, nil
+}
+
+// v4LocalKeys derives the encryption key, the XChaCha20 nonce, and the authentication key of a version 4 local token from the key and the nonce of the token.
+func (k PASETOKey) v4LocalKeys(n []byte) (ek, n2, ak []byte) {
+	tmp, _ := blake2b.New(32+chacha20.NonceSizeX, k.secret)
+	tmp.Write([]byte("paseto-encryption-key"))
+	tmp.Write(n)
+	sum := tmp.Sum(nil)
+	auth, _ := blake2b.New256(k.secret)
+	auth.Write([]byte("paseto-auth-key-for-aead"))
+	auth.Write(n)
+	return sum[:32], sum[32:], auth.Sum(nil)
+}
+
+// pae is the pre-authentication encoding of PASETO, which encodes the pieces unambiguously, each prefixed by its length.
+func pae(pieces ...[]byte) []byte {
+	out := make([]byte, 8, 8+8*len(pieces))
+	binary.LittleEndian.PutUint64(out, uint64(len(pieces)))
+	for _, piece := range pieces {
+		out = binary.LittleEndian.AppendUint64(out, uint64(len(piece)))
+		out = append(out, piece...)
+	}
+	return out
+}
+
+// jwtToPASETOClaims converts the registered time claims of JWT claims from seconds since the Unix epoch to the RFC 3339 times of PASETO.
+func jwtToPASETOClaims(payload []byte) ([]byte, error) {
+	claims := map[string]json.RawMessage{}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, err
+	}
+	for _, name := range pasetoTimeClaims {
+		raw, ok := claims[name]
+		if !ok {
+			continue
+		}
+		unix := int64(0)
+		if err := json.Unmarshal(raw, &unix); err != nil {
+			return nil, fmt.Errorf("encoding PASETO claim '%s': %w", name, err)
+		}
+		claims[name], _ = json.Marshal(time.Unix(unix, 0).UTC().Format(time.RFC3339))
+	}
+	return json.Marshal(claims)
+}
+
+// pasetoToJWTClaims is the inverse of jwtToPASETOClaims. Time claims which aren't RFC 3339 strings are rejected with ErrMalformed.
+func pasetoToJWTClaims(payload []byte) ([]byte, error) {
+	claims := map[string]json.RawMessage{}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, fmt.Errorf("%w: error decoding PASETO claims: %w", ErrMalformed, err)
+	}
+	for _, name := range pasetoTimeClaims {
+		raw, ok := claims[name]
+		if !ok {
+			continue
+		}
+		s := ""
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return nil, fmt.Errorf("%w: PASETO claim '%s' isn't a string", ErrMalformed, name)
+		}
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return nil, fmt.Errorf("%w: PASETO claim '%s': %w", ErrMalformed, name, err)
+		}
+		claims[name] = json.RawMessage(strconv.FormatInt(t.Unix(), 10))
+	}
+	return json.Marshal(claims)
+}
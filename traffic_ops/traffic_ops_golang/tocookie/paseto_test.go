@@ -0,0 +1,143 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The test vectors of the PASETO specification: 2-S-1, 4-S-1, and 4-E-1, whose nonce is 32 zero bytes.
+const (
+	pasetoVectorSecretKey = "b4cbfb43df4ce210727d953e4a713307fa19bb7d9f85041438d9e11b942a37741eb9dbbbbc047c03fd70604e0071f0987e16b28b757225c11f00415d0e20b1a2"
+	pasetoVectorLocalKey  = "707172737475767778797a7b7c7d7e7f808182838485868788898a8b8c8d8e8f"
+)
+
+var pasetoVectors = []struct {
+	name, token, payload string
+	local                bool
+	version              int
+}{
+	{"2-S-1", "v2.public.eyJkYXRhIjoidGhpcyBpcyBhIHNpZ25lZCBtZXNzYWdlIiwiZXhwIjoiMjAxOS0wMS0wMVQwMDowMDowMCswMDowMCJ9HQr8URrGntTu7Dz9J2IF23d1M7-9lH9xiqdGyJNvzp4angPW5Esc7C5huy_M8I8_DjJK2ZXC2SUYuOFM-Q_5Cw", `{"data":"this is a signed message","exp":"2019-01-01T00:00:00+00:00"}`, false, PASETOVersion2},
+	{"4-S-1", "v4.public.eyJkYXRhIjoidGhpcyBpcyBhIHNpZ25lZCBtZXNzYWdlIiwiZXhwIjoiMjAyMi0wMS0wMVQwMDowMDowMCswMDowMCJ9bg_XBBzds8lTZShVlwwKSgeKpLT3yukTw6JUz3W4h_ExsQV-P0V54zemZDcAxFaSeef1QlXEFtkqxT1ciiQEDA", `{"data":"this is a signed message","exp":"2022-01-01T00:00:00+00:00"}`, false, PASETOVersion4},
+	{"4-E-1", "v4.local.AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAQAr68PS4AXe7If_ZgesdkUMvSwscFlAl1pk5HC0e8kApeaqMfGo_7OpBnwJOAbY9V7WU6abu74MmcUE8YWAiaArVI8XJ5hOb_4v9RmDkneN0S92dx0OW4pgy7omxgf3S8c3LlQg", `{"data":"this is a secret message","exp":"2022-01-01T00:00:00+00:00"}`, true, PASETOVersion4},
+}
+
+func TestPASETOVectors(t *testing.T) {
+	sk, _ := hex.DecodeString(pasetoVectorSecretKey)
+	lk, _ := hex.DecodeString(pasetoVectorLocalKey)
+	for _, v := range pasetoVectors {
+		key := PASETOPublicKey(v.version, ed25519.PrivateKey(sk))
+		if v.local {
+			key = PASETOLocalKey(v.version, lk)
+		}
+		body, err := key.seal([]byte(v.payload), bytes.NewReader(make([]byte, 32)))
+		if token := key.header() + base64.RawURLEncoding.EncodeToString(body); err != nil || token != v.token {
+			t.Errorf("%v: seal expected '%v', actual: '%v' %v", v.name, v.token, token, err)
+		}
+		body, _ = base64.RawURLEncoding.DecodeString(strings.Split(v.token, ".")[2])
+		if msg, err := key.open(body, nil); err != nil || string(msg) != v.payload {
+			t.Errorf("%v: open expected '%v', actual: '%s' %v", v.name, v.payload, msg, err)
+		}
+	}
+}
+
+func TestPASETO(t *testing.T) {
+	_, priv, _ := ed25519.GenerateKey(nil)
+	secret := bytes.Repeat([]byte{7}, PASETOLocalKeySize)
+	expiration := time.Now().Add(time.Hour)
+	keys := map[string]PASETOKey{
+		"v2.local":  PASETOLocalKey(PASETOVersion2, secret),
+		"v4.local":  PASETOLocalKey(PASETOVersion4, secret),
+		"v2.public": PASETOPublicKey(PASETOVersion2, priv),
+		"v4.public": PASETOPublicKey(PASETOVersion4, priv),
+	}
+	for name, key := range keys {
+		token := NewPASETO("alice", expiration, key, WithRoles("admin"), WithAudience("traffic_ops"))
+		if !strings.HasPrefix(token, name+".") {
+			t.Errorf("%v: NewPASETO expected token of %v, actual: '%v'", name, name, token)
+			continue
+		}
+		c, err := ParsePASETO(token, key, WithAudience("traffic_ops"))
+		if err != nil || c.AuthData != "alice" || !c.HasRole("admin") || c.ExpiresUnix != expiration.Unix() {
+			t.Errorf("%v: ParsePASETO expected claims of alice, actual: %+v %v", name, c, err)
+		}
+		for other, otherKey := range keys {
+			if _, err := ParsePASETO(token, otherKey); other != name && !errors.Is(err, ErrBadSignature) {
+				t.Errorf("%v: ParsePASETO with a %v key expected ErrBadSignature, actual: %v", name, other, err)
+			}
+		}
+		// a character of the middle of the last part is flipped, as the last character may have bits base64 ignores.
+		i := len(token) - 8
+		flipped := "A"
+		if token[i] == 'A' {
+			flipped = "B"
+		}
+		if _, err := ParsePASETO(token[:i]+flipped+token[i+1:], key); !errors.Is(err, ErrBadSignature) {
+			t.Errorf("%v: ParsePASETO of a tampered token expected ErrBadSignature, actual: %v", name, err)
+		}
+		if _, err := ParsePASETO(token+"."+base64.RawURLEncoding.EncodeToString([]byte(`{"kid":"1"}`)), key); !errors.Is(err, ErrBadSignature) {
+			t.Errorf("%v: ParsePASETO of a token with an added footer expected ErrBadSignature, actual: %v", name, err)
+		}
+		if expired := NewPASETO("alice", time.Now().Add(-time.Hour), key); !errors.Is(func() error { _, err := ParsePASETO(expired, key); return err }(), ErrExpired) {
+			t.Errorf("%v: ParsePASETO of an expired token expected ErrExpired", name)
+		}
+	}
+
+	key := keys["v4.public"]
+	body, _ := base64.RawURLEncoding.DecodeString(strings.Split(NewPASETO("alice", expiration, key), ".")[2])
+	claims := map[string]interface{}{}
+	if err := json.Unmarshal(body[:len(body)-ed25519.SignatureSize], &claims); err != nil || claims["sub"] != "alice" || claims["exp"] != expiration.UTC().Format(time.RFC3339) {
+		t.Errorf("NewPASETO expected sub and RFC 3339 exp claims, actual: %v %v", claims, err)
+	}
+
+	numeric, _ := json.Marshal(map[string]interface{}{"sub": "alice", "exp": expiration.Unix()})
+	signed, _ := key.seal(numeric, nil)
+	if _, err := ParsePASETO(key.header()+base64.RawURLEncoding.EncodeToString(signed), key); !errors.Is(err, ErrMalformed) {
+		t.Errorf("ParsePASETO of a numeric exp claim expected ErrMalformed, actual: %v", err)
+	}
+	verifyOnly := PASETOVerifyKey(PASETOVersion4, priv.Public().(ed25519.PublicKey))
+	if token := NewPASETO("alice", expiration, verifyOnly); token != "" {
+		t.Errorf("NewPASETO with a verify-only key expected empty token, actual: '%v'", token)
+	}
+	if _, err := ParsePASETO(NewPASETO("alice", expiration, key), verifyOnly); err != nil {
+		t.Errorf("ParsePASETO with a verify-only key expected nil error, actual: %v", err)
+	}
+	if token := NewPASETO("alice", expiration, PASETOLocalKey(PASETOVersion4, []byte("short"))); token != "" {
+		t.Errorf("NewPASETO with a short local key expected empty token, actual: '%v'", token)
+	}
+	if token := NewPASETO("alice", expiration, PASETOLocalKey(3, secret)); token != "" {
+		t.Errorf("NewPASETO with an unsupported version expected empty token, actual: '%v'", token)
+	}
+	if _, err := ParsePASETO("v4.public", key); !errors.Is(err, ErrMalformed) {
+		t.Errorf("ParsePASETO of a token without a body expected ErrMalformed, actual: %v", err)
+	}
+}
+
+func TestParsePASETOHooks(t *testing.T) {
+	key := PASETOLocalKey(PASETOVersion4, bytes.Repeat([]byte{7}, PASETOLocalKeySize))
+	errDenied := errors.New("denied")
+	deny := WithHooks(func(ctx context.Context, c *Cookie) error { return errDenied })
+	token := NewPASETO("alice", time.Now().Add(time.Hour), key)
+	if _, err := ParsePASETO(token, key, deny); !errors.Is(err, ErrHookRejected) || !errors.Is(err, errDenied) {
+		t.Errorf("ParsePASETO with a rejecting hook expected ErrHookRejected, actual: %v", err)
+	}
+}
@@ -0,0 +1,119 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"crypto"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// The errors of precheck are allocated once, or are small values, so rejecting garbage allocates nothing.
+var (
+	errPrecheckNoSignature = fmt.Errorf("%w: no signature", ErrMalformed)
+	errPrecheckOddHex      = fmt.Errorf("%w: error decoding signature: %w", ErrMalformed, hex.ErrLength)
+	errPrecheckTagLength   = fmt.Errorf("%w: signature has the wrong length", ErrBadSignature)
+)
+
+// signatureHexError is an ErrMalformed for a signature containing the byte, which isn't hex. It wraps the hex.InvalidByteError hex.DecodeString returns.
+type signatureHexError byte
+
+func (e signatureHexError) Error() string {
+	return ErrMalformed.Error() + ": error decoding signature: " + hex.InvalidByteError(e).Error()
+}
+
+func (e signatureHexError) Unwrap() []error {
+	return []error{ErrMalformed, hex.InvalidByteError(e)}
+}
+
+// cookieCharError is an ErrMalformed for a cookie containing a byte, at the given offset, which isn't in any encoding of this package. It wraps a base64.CorruptInputError, as the payload would fail to decode.
+type cookieCharError int
+
+func (e cookieCharError) Error() string {
+	return ErrMalformed.Error() + ": illegal character at offset " + strconv.Itoa(int(e))
+}
+
+func (e cookieCharError) Unwrap() []error {
+	return []error{ErrMalformed, base64.CorruptInputError(e)}
+}
+
+// precheck rejects cookies which can't verify, by their shape alone, before anything is decoded or allocated: cookies without a "--" separated hex signature, with characters no encoding of this package, or the padding of WithPadding, writes, or with an HMAC tag too short or long for the configured hashes. It only inspects the structure of the cookie, which isn't secret, so it doesn't leak anything about the secret or the expected tag; the tag itself is still compared in constant time. Cookies it accepts may well be rejected later.
+//
+// Cookies are only prechecked as they are given, so it accepts anything within WithMaxCookieSize if WithTrim or WithURLEncoding are, and leaves their cookies to splitCookie.
+func (o *options) precheck(cookie string) error {
+	if err := o.checkCookieSize(cookie); err != nil {
+		return err
+	}
+	if o.trim || o.urlEncoding {
+		return nil
+	}
+	sepPos := strings.LastIndex(cookie, "--")
+	if sepPos <= 0 || sepPos+2 == len(cookie) {
+		return errPrecheckNoSignature
+	}
+	sig := cookie[sepPos+2:]
+	for i := 0; i < len(sig); i++ {
+		if !isHexChar(sig[i]) {
+			return signatureHexError(sig[i])
+		}
+	}
+	if len(sig)%2 != 0 {
+		return errPrecheckOddHex
+	}
+	for i := 0; i < sepPos; i++ {
+		if c := cookie[i]; !isCookieChar(c) && (!o.paddingSet || rune(c) != o.padding) {
+			return cookieCharError(i)
+		}
+	}
+	if !o.tagLengthPlausible(len(sig) / 2) {
+		return errPrecheckTagLength
+	}
+	return nil
+}
+
+// tagLengthPlausible returns whether a signature of n bytes could verify with the configured hash, or one of the legacy hashes. Signatures which aren't HMACs, of WithMACer and ParseWithPublicKey, only need to be non-empty.
+func (o *options) tagLengthPlausible(n int) bool {
+	if o.macer != nil || o.publicKey != nil {
+		return n > 0
+	}
+	if o.hashTagLengthPlausible(o.hash, n) {
+		return true
+	}
+	for _, hash := range o.legacyHashes {
+		if o.hashTagLengthPlausible(hash, n) {
+			return true
+		}
+	}
+	return false
+}
+
+// hashTagLengthPlausible returns whether an HMAC tag of the hash may be n bytes long, as tagMatches checks it. Unavailable hashes are plausible, so the error of checkHash isn't masked.
+func (o *options) hashTagLengthPlausible(hash crypto.Hash, n int) bool {
+	if !hash.Available() {
+		return true
+	}
+	size := hash.Size()
+	return n >= o.minTagLength(size) && n <= size
+}
+
+func isHexChar(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+// isCookieChar returns whether c may be in the signed part of a cookie: the characters of standard and URL base64, the '=' of padding, the '-' of the padding of version 0 cookies, and the '.' of version separators.
+func isCookieChar(c byte) bool {
+	return 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '+' || c == '/' || c == '=' || c == '_' || c == '-' || c == '.'
+}
@@ -0,0 +1,86 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"crypto"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPrecheck(t *testing.T) {
+	sig := strings.Repeat("ab", 20)
+	for name, test := range map[string]struct {
+		cookie   string
+		expected error
+	}{
+		"empty":             {"", ErrMalformed},
+		"no separator":      {"eyJ9" + sig, ErrMalformed},
+		"no payload":        {"--" + sig, ErrMalformed},
+		"no signature":      {"eyJ9--", ErrMalformed},
+		"odd hex":           {"eyJ9--" + sig + "a", hex.ErrLength},
+		"non-hex":           {"eyJ9--" + sig[:38] + "zz", hex.InvalidByteError('z')},
+		"illegal character": {"ey J9--" + sig, base64.CorruptInputError(2)},
+		"short tag":         {"eyJ9--" + sig[:38], ErrBadSignature},
+		"long tag":          {"eyJ9--" + sig + "ab", ErrBadSignature},
+	} {
+		if err := newOptions(nil).precheck(test.cookie); !errors.Is(err, test.expected) {
+			t.Errorf("%v: precheck expected %v, actual: %v", name, test.expected, err)
+		}
+	}
+
+	expiration := time.Now().Add(time.Minute)
+	for name, opts := range map[string][]Option{
+		"default":     nil,
+		"v1":          {WithVersion(Version1)},
+		"v2":          {WithVersion(Version2), WithKeyID("k1")},
+		"compressed":  {WithCompression("gzip")},
+		"encrypted":   {WithEncryption()},
+		"padded":      {WithPadding('~')},
+		"unpadded":    {WithPadding(base64.NoPadding)},
+		"SHA-256":     {WithHash(crypto.SHA256)},
+		"truncated":   {WithHash(crypto.SHA256), WithTagLength(MinTagLength)},
+		"url-encoded": {WithURLEncoding()},
+	} {
+		cookie := New(strings.Repeat("alice", 10), expiration, "secret", opts...)
+		if err := newOptions(opts).precheck(cookie); err != nil {
+			t.Errorf("%v: precheck of valid cookie expected nil error, actual: %v", name, err)
+		}
+	}
+
+	legacy := New("alice", expiration, "secret")
+	if err := newOptions([]Option{WithHash(crypto.SHA256)}).precheck(legacy); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("precheck of SHA-1 tag with SHA-256 expected ErrBadSignature, actual: %v", err)
+	}
+	if err := newOptions([]Option{WithHash(crypto.SHA256), WithLegacyHashes(crypto.SHA1)}).precheck(legacy); err != nil {
+		t.Errorf("precheck of SHA-1 tag with legacy SHA-1 expected nil error, actual: %v", err)
+	}
+}
+
+func TestPrecheckAllocs(t *testing.T) {
+	p := NewParser("secret")
+	for _, m := range benchMalformed {
+		allocs := testing.AllocsPerRun(100, func() {
+			if _, err := p.Parse(m.cookie); err == nil {
+				t.Fatalf("%v: Parser.Parse expected error, actual: nil", m.name)
+			}
+		})
+		if allocs != 0 {
+			t.Errorf("%v: Parser.Parse of malformed cookie expected no allocations, actual: %v", m.name, allocs)
+		}
+	}
+}
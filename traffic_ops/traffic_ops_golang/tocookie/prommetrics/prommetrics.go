@@ -0,0 +1,230 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package prommetrics provides a tocookie.Metrics which counts cookies issued, refreshed, and parsed, and serves them in the Prometheus text exposition format. It writes the format itself, so it has no external dependencies:
+//
+//	metrics := prommetrics.New("")
+//	c, err := tocookie.Parse(secret, cookie, tocookie.WithMetrics(metrics))
+//	http.Handle("/metrics", metrics)
+//
+// The metrics are tocookie_issued_total, tocookie_refreshed_total, tocookie_parses_total by outcome, tocookie_parse_failures_total by the reason of tocookie.FailureReason, and the tocookie_parse_duration_seconds histogram. Given to a tocookie.Migration, it also counts tocookie_format_requests_total by the format requests used.
+package prommetrics
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/tocookie"
+)
+
+// DefaultNamespace prefixes the metric names of New("").
+const DefaultNamespace = "tocookie"
+
+// DefaultBuckets are the upper bounds, in seconds, of the parse latency histogram. Parsing takes microseconds, unless hooks or a revocation store query a database, so they range from 10µs to 1s.
+var DefaultBuckets = []float64{0.00001, 0.000025, 0.00005, 0.0001, 0.00025, 0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.1, 0.25, 1}
+
+// ContentType is the content type of the text exposition format served by Metrics.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Metrics is a tocookie.Metrics which serves its counts, as an http.Handler, in the Prometheus text exposition format. It is safe for concurrent use.
+type Metrics struct {
+	namespace string
+	buckets   []float64
+
+	issued    uint64
+	refreshed uint64
+
+	// bucketCounts are the counts of parses at most each bucket's bound, not cumulative; the cumulative counts are summed when written.
+	bucketCounts []uint64
+	count        uint64
+	// sumBits are the bits of the float64 sum of the parse durations, in seconds.
+	sumBits uint64
+
+	mu       sync.Mutex
+	outcomes map[string]uint64
+	reasons  map[string]uint64
+	formats  map[string]uint64
+}
+
+// New returns Metrics with names prefixed by the namespace, or DefaultNamespace if it is empty, and DefaultBuckets.
+func New(namespace string) *Metrics {
+	return NewWithBuckets(namespace, DefaultBuckets)
+}
+
+// NewWithBuckets returns Metrics with names prefixed by the namespace, or DefaultNamespace if it is empty, and the given upper bounds, in seconds, of the parse latency histogram. The bounds are sorted, and the +Inf bucket is implied.
+func NewWithBuckets(namespace string, buckets []float64) *Metrics {
+	if namespace == "" {
+		namespace = DefaultNamespace
+	}
+	sorted := make([]float64, 0, len(buckets))
+	for _, b := range buckets {
+		if !math.IsInf(b, 1) && !math.IsNaN(b) {
+			sorted = append(sorted, b)
+		}
+	}
+	sort.Float64s(sorted)
+	return &Metrics{
+		namespace:    namespace,
+		buckets:      sorted,
+		bucketCounts: make([]uint64, len(sorted)),
+		outcomes:     map[string]uint64{},
+		reasons:      map[string]uint64{},
+		formats:      map[string]uint64{},
+	}
+}
+
+// Issued implements tocookie.Metrics.
+func (m *Metrics) Issued() { atomic.AddUint64(&m.issued, 1) }
+
+// Refreshed implements tocookie.Metrics.
+func (m *Metrics) Refreshed() { atomic.AddUint64(&m.refreshed, 1) }
+
+// Parsed implements tocookie.Metrics.
+func (m *Metrics) Parsed(outcome tocookie.Outcome, reason string, elapsed time.Duration) {
+	seconds := elapsed.Seconds()
+	if i := sort.SearchFloat64s(m.buckets, seconds); i < len(m.buckets) {
+		atomic.AddUint64(&m.bucketCounts[i], 1)
+	}
+	for {
+		old := atomic.LoadUint64(&m.sumBits)
+		if atomic.CompareAndSwapUint64(&m.sumBits, old, math.Float64bits(math.Float64frombits(old)+seconds)) {
+			break
+		}
+	}
+	atomic.AddUint64(&m.count, 1)
+
+	m.mu.Lock()
+	m.outcomes[outcome.String()]++
+	if reason != "" {
+		m.reasons[reason]++
+	}
+	m.mu.Unlock()
+}
+
+// FormatUsed implements tocookie.MigrationMetrics.
+func (m *Metrics) FormatUsed(format string) {
+	m.mu.Lock()
+	m.formats[format]++
+	m.mu.Unlock()
+}
+
+// ServeHTTP writes the metrics in the text exposition format.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", ContentType)
+	m.WriteTo(w)
+}
+
+// WriteTo writes the metrics in the text exposition format, e.g. to append them to the exposition of other metrics.
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	cw := &countWriter{w: w}
+	bw := bufio.NewWriter(cw)
+
+	m.writeHeader(bw, "issued_total", "counter", "Cookies issued.")
+	m.writeSample(bw, "issued_total", "", float64(atomic.LoadUint64(&m.issued)))
+	m.writeHeader(bw, "refreshed_total", "counter", "Cookies refreshed.")
+	m.writeSample(bw, "refreshed_total", "", float64(atomic.LoadUint64(&m.refreshed)))
+
+	m.mu.Lock()
+	outcomes, reasons, formats := copyCounts(m.outcomes), copyCounts(m.reasons), copyCounts(m.formats)
+	m.mu.Unlock()
+	m.writeHeader(bw, "parses_total", "counter", "Cookies parsed, by outcome.")
+	for _, outcome := range sortedKeys(outcomes) {
+		m.writeSample(bw, "parses_total", label("outcome", outcome), float64(outcomes[outcome]))
+	}
+	m.writeHeader(bw, "parse_failures_total", "counter", "Cookies rejected, by reason.")
+	for _, reason := range sortedKeys(reasons) {
+		m.writeSample(bw, "parse_failures_total", label("reason", reason), float64(reasons[reason]))
+	}
+	if len(formats) > 0 {
+		m.writeHeader(bw, "format_requests_total", "counter", "Requests authenticated by cookies of tocookie.MigrationMiddleware, by format.")
+		for _, format := range sortedKeys(formats) {
+			m.writeSample(bw, "format_requests_total", label("format", format), float64(formats[format]))
+		}
+	}
+
+	m.writeHeader(bw, "parse_duration_seconds", "histogram", "Time to parse cookies, in seconds.")
+	cumulative := uint64(0)
+	for i, bound := range m.buckets {
+		cumulative += atomic.LoadUint64(&m.bucketCounts[i])
+		m.writeSample(bw, "parse_duration_seconds_bucket", label("le", formatFloat(bound)), float64(cumulative))
+	}
+	count := atomic.LoadUint64(&m.count)
+	m.writeSample(bw, "parse_duration_seconds_bucket", label("le", "+Inf"), float64(count))
+	m.writeSample(bw, "parse_duration_seconds_sum", "", math.Float64frombits(atomic.LoadUint64(&m.sumBits)))
+	m.writeSample(bw, "parse_duration_seconds_count", "", float64(count))
+
+	err := bw.Flush()
+	return cw.n, err
+}
+
+func (m *Metrics) writeHeader(w *bufio.Writer, name, typ, help string) {
+	w.WriteString("# HELP " + m.namespace + "_" + name + " " + help + "\n")
+	w.WriteString("# TYPE " + m.namespace + "_" + name + " " + typ + "\n")
+}
+
+func (m *Metrics) writeSample(w *bufio.Writer, name, labels string, value float64) {
+	w.WriteString(m.namespace + "_" + name + labels + " " + formatFloat(value) + "\n")
+}
+
+// label returns the label set of one label. Values are escaped as the format requires, though those of this package have nothing to escape.
+func label(name, value string) string {
+	return "{" + name + "=" + strconv.Quote(value) + "}"
+}
+
+// formatFloat formats the value as the format requires.
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func copyCounts(counts map[string]uint64) map[string]uint64 {
+	c := make(map[string]uint64, len(counts))
+	for k, v := range counts {
+		c[k] = v
+	}
+	return c
+}
+
+func sortedKeys(counts map[string]uint64) []string {
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// countWriter counts the bytes written, for WriteTo.
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
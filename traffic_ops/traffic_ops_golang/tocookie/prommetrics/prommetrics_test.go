@@ -0,0 +1,108 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prommetrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/tocookie"
+)
+
+func TestMetrics(t *testing.T) {
+	m := New("")
+	secret := "secret"
+	opts := []tocookie.Option{tocookie.WithMetrics(m)}
+
+	cookie := tocookie.New("alice", time.Now().Add(time.Minute), secret, opts...)
+	tocookie.New("bob", time.Now().Add(-time.Minute), secret, opts...)
+	if c, err := tocookie.Parse(secret, cookie, opts...); err != nil {
+		t.Fatalf("Parse expected nil error, actual: %v", err)
+	} else if tocookie.Refresh(c, secret, opts...) == "" {
+		t.Fatalf("Refresh expected cookie, actual empty")
+	}
+	tocookie.Parse("wrong", cookie, opts...)
+	tocookie.Parse(secret, "garbage", opts...)
+	tocookie.Parse(secret, tocookie.New("carol", time.Now().Add(-time.Minute), secret), opts...)
+
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if ct := w.Header().Get("Content-Type"); ct != ContentType {
+		t.Errorf("ServeHTTP expected Content-Type %v, actual: %v", ContentType, ct)
+	}
+	body := w.Body.String()
+	for _, expected := range []string{
+		"# TYPE tocookie_issued_total counter\ntocookie_issued_total 2\n",
+		"tocookie_refreshed_total 1\n",
+		`tocookie_parses_total{outcome="valid"} 1` + "\n",
+		`tocookie_parses_total{outcome="invalid"} 2` + "\n",
+		`tocookie_parses_total{outcome="expired"} 1` + "\n",
+		`tocookie_parse_failures_total{reason="bad_signature"} 1` + "\n",
+		`tocookie_parse_failures_total{reason="malformed"} 1` + "\n",
+		`tocookie_parse_failures_total{reason="expired"} 1` + "\n",
+		"# TYPE tocookie_parse_duration_seconds histogram\n",
+		`tocookie_parse_duration_seconds_bucket{le="+Inf"} 4` + "\n",
+		"tocookie_parse_duration_seconds_count 4\n",
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("ServeHTTP expected %q, actual: %v", expected, body)
+		}
+	}
+	if strings.Contains(body, "format_requests_total") {
+		t.Errorf("ServeHTTP without migration expected no format counts, actual: %v", body)
+	}
+}
+
+func TestFormatUsed(t *testing.T) {
+	m := New("")
+	m.FormatUsed(tocookie.FormatLegacy)
+	m.FormatUsed(tocookie.FormatNew)
+	m.FormatUsed(tocookie.FormatNew)
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	for _, expected := range []string{
+		"# TYPE tocookie_format_requests_total counter\n",
+		`tocookie_format_requests_total{format="legacy"} 1` + "\n",
+		`tocookie_format_requests_total{format="new"} 2` + "\n",
+	} {
+		if !strings.Contains(w.Body.String(), expected) {
+			t.Errorf("ServeHTTP expected %q, actual: %v", expected, w.Body)
+		}
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	m := NewWithBuckets("test", []float64{1, 0.1})
+	m.Parsed(tocookie.OutcomeValid, "", 50*time.Millisecond)
+	m.Parsed(tocookie.OutcomeValid, "", 100*time.Millisecond)
+	m.Parsed(tocookie.OutcomeValid, "", 500*time.Millisecond)
+	m.Parsed(tocookie.OutcomeValid, "", 2*time.Second)
+
+	b := &strings.Builder{}
+	n, err := m.WriteTo(b)
+	if err != nil || n != int64(b.Len()) {
+		t.Fatalf("WriteTo expected %v bytes and nil error, actual: %v %v", b.Len(), n, err)
+	}
+	expected := `test_parse_duration_seconds_bucket{le="0.1"} 2
+test_parse_duration_seconds_bucket{le="1"} 3
+test_parse_duration_seconds_bucket{le="+Inf"} 4
+test_parse_duration_seconds_sum 2.65
+test_parse_duration_seconds_count 4
+`
+	if !strings.HasSuffix(b.String(), expected) {
+		t.Errorf("WriteTo expected histogram %v, actual: %v", expected, b.String())
+	}
+}
@@ -0,0 +1,92 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package redisrevocation provides a tocookie.RevocationStore backed by a standalone Redis server, so a session revoked on one Traffic Ops server is rejected by all of them. It is configured by the fields of its Store, and is a thin wrapper around the revocations of tocookie/redissession, whose Store also supports Sentinel and Cluster deployments, and server-side sessions:
+//
+//	store := redisrevocation.New("localhost:6379")
+//	c, err := tocookie.Parse(secret, cookie, tocookie.WithRevocationStore(store))
+//
+// Revocations are stored as keys which expire when the revocation lapses, plus redissession.DefaultTTLSlack, so Redis holds no more than the sessions which could still have valid cookies.
+package redisrevocation
+
+import (
+	"sync"
+	"time"
+
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/tocookie/redissession"
+)
+
+// DefaultPrefix prefixes the session IDs in Redis keys, so revocations don't collide with other keys. It is the prefix of the revocations of a redissession.Store with its default prefix, so the two share revocations.
+const DefaultPrefix = redissession.DefaultPrefix + "revoked:"
+
+// DefaultTimeout is the default time limit of connecting to Redis and of each command.
+const DefaultTimeout = redissession.DefaultTimeout
+
+// Store is a tocookie.RevocationStore backed by Redis. Its fields must not be changed once it has run a command, as it connects with them on the first. It holds a single connection, which is re-established on the next command after any failure, and pipelines the commands of concurrent callers. It is safe for concurrent use.
+type Store struct {
+	// Addr is the host:port of the Redis server.
+	Addr string
+	// Password, if not empty, authenticates the connection with the AUTH command.
+	Password string
+	// Prefix prefixes the session IDs in keys. The default is DefaultPrefix.
+	Prefix string
+	// Timeout is the time limit of connecting and of each command. The default is DefaultTimeout.
+	Timeout time.Duration
+
+	once  sync.Once
+	store *redissession.Store
+	err   error
+}
+
+// New returns a Store for the Redis server at the address, with DefaultPrefix and DefaultTimeout. It doesn't connect until the first command.
+func New(addr string) *Store {
+	return &Store{Addr: addr, Prefix: DefaultPrefix, Timeout: DefaultTimeout}
+}
+
+// Revoke revokes the session until the given time, when its key expires. Sessions revoked until a time which has passed are ignored.
+func (s *Store) Revoke(sessionID string, until time.Time) error {
+	store, err := s.redis()
+	if err != nil {
+		return err
+	}
+	return store.Revoke(sessionID, until)
+}
+
+// IsRevoked returns whether the session's revocation key exists.
+func (s *Store) IsRevoked(sessionID string) (bool, error) {
+	store, err := s.redis()
+	if err != nil {
+		return false, err
+	}
+	return store.IsRevoked(sessionID)
+}
+
+// Close closes the connection to the server. Commands fail once the store is closed.
+func (s *Store) Close() error {
+	store, err := s.redis()
+	if err != nil {
+		return nil
+	}
+	return store.Close()
+}
+
+// redis returns the redissession.Store of the fields, creating it on first use.
+func (s *Store) redis() (*redissession.Store, error) {
+	s.once.Do(func() {
+		prefix := s.Prefix
+		if prefix == "" {
+			prefix = DefaultPrefix
+		}
+		s.store, s.err = redissession.New(redissession.Config{Addrs: []string{s.Addr}, Password: s.Password, RevocationPrefix: prefix, Timeout: s.Timeout})
+	})
+	return s.store, s.err
+}
@@ -0,0 +1,115 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redisrevocation
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/tocookie"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/tocookie/redissession"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/tocookie/tocookietest"
+)
+
+func TestStore(t *testing.T) {
+	f := tocookietest.NewRedisServer(t)
+	store := New(f.Addr())
+	t.Cleanup(func() { store.Close() })
+	secret := "secret"
+	cookie := tocookie.New("alice", time.Now().Add(time.Minute), secret)
+	c, err := tocookie.Parse(secret, cookie, tocookie.WithRevocationStore(store))
+	if err != nil {
+		t.Fatalf("Parse expected nil error, actual: %v", err)
+	}
+	if err := store.Revoke(c.SessionID, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("Revoke expected nil error, actual: %v", err)
+	}
+	if _, ok := f.Key(DefaultPrefix + c.SessionID); !ok {
+		t.Errorf("Revoke expected key %v, actual: %v", DefaultPrefix+c.SessionID, f.Keys())
+	}
+	if _, err := tocookie.Parse(secret, cookie, tocookie.WithRevocationStore(store)); !errors.Is(err, tocookie.ErrRevoked) {
+		t.Errorf("Parse of revoked session expected ErrRevoked, actual: %v", err)
+	}
+	if revoked, err := store.IsRevoked("other"); err != nil || revoked {
+		t.Errorf("IsRevoked of other session expected false, actual: %v %v", revoked, err)
+	}
+	if err := store.Revoke("lapsed", time.Now().Add(-time.Second)); err != nil || len(f.Keys()) != 1 {
+		t.Errorf("Revoke of lapsed revocation expected no key, actual: %v %v", f.Keys(), err)
+	}
+}
+
+func TestStoreSharesRevocations(t *testing.T) {
+	f := tocookietest.NewRedisServer(t)
+	sessions, err := redissession.New(redissession.Config{Addrs: []string{f.Addr()}})
+	if err != nil {
+		t.Fatalf("redissession.New expected nil error, actual: %v", err)
+	}
+	t.Cleanup(func() { sessions.Close() })
+	store := New(f.Addr())
+	t.Cleanup(func() { store.Close() })
+	if err := sessions.Revoke("sid", time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("redissession Revoke expected nil error, actual: %v", err)
+	}
+	if revoked, err := store.IsRevoked("sid"); err != nil || !revoked {
+		t.Errorf("IsRevoked of session revoked by redissession expected true, actual: %v %v", revoked, err)
+	}
+
+	prefixed := &Store{Addr: f.Addr(), Prefix: "to:"}
+	t.Cleanup(func() { prefixed.Close() })
+	if err := prefixed.Revoke("sid2", time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("Revoke with prefix expected nil error, actual: %v", err)
+	}
+	if _, ok := f.Key("to:sid2"); !ok {
+		t.Errorf("Revoke with prefix expected key to:sid2, actual: %v", f.Keys())
+	}
+}
+
+func TestStoreReconnects(t *testing.T) {
+	f := tocookietest.NewRedisServer(t)
+	store := New(f.Addr())
+	t.Cleanup(func() { store.Close() })
+	if err := store.Revoke("sid", time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("Revoke expected nil error, actual: %v", err)
+	}
+	f.Drop()
+	if _, err := store.IsRevoked("sid"); err == nil {
+		t.Errorf("IsRevoked on dropped connection expected error, actual nil")
+	}
+	if revoked, err := store.IsRevoked("sid"); err != nil || !revoked {
+		t.Errorf("IsRevoked after reconnecting expected true, actual: %v %v", revoked, err)
+	}
+}
+
+func TestStoreAuth(t *testing.T) {
+	f := tocookietest.NewRedisServer(t)
+	f.Set(func(f *tocookietest.RedisServer) { f.Password = "hunter2" })
+	for password, ok := range map[string]bool{"": false, "wrong": false, "hunter2": true} {
+		store := New(f.Addr())
+		t.Cleanup(func() { store.Close() })
+		store.Password = password
+		if _, err := store.IsRevoked("sid"); (err == nil) != ok {
+			t.Errorf("IsRevoked with password '%v' expected success %v, actual: %v", password, ok, err)
+		}
+	}
+}
+
+func TestStoreUnavailable(t *testing.T) {
+	f := tocookietest.NewRedisServer(t)
+	f.Close()
+	store := New(f.Addr())
+	t.Cleanup(func() { store.Close() })
+	if _, err := store.IsRevoked("sid"); err == nil {
+		t.Errorf("IsRevoked with unavailable redis expected error, actual nil")
+	}
+}
@@ -0,0 +1,132 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redissession
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// numSlots is the number of hash slots of a Redis Cluster.
+const numSlots = 16384
+
+// keySlot returns the Cluster hash slot of the key: the CRC16 of the key, or of its hash tag, the part between the first '{' and the next '}' if it isn't empty, modulo the number of slots.
+func keySlot(key string) int {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			key = key[start+1 : start+1+end]
+		}
+	}
+	return int(crc16(key)) % numSlots
+}
+
+// crc16 is the CRC16-CCITT (XMODEM) checksum Redis Cluster hashes keys with.
+func crc16(s string) uint16 {
+	crc := uint16(0)
+	for i := 0; i < len(s); i++ {
+		crc ^= uint16(s[i]) << 8
+		for bit := 0; bit < 8; bit++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+// clusterSlots returns the addresses of the nodes serving each slot, asking the nodes of Addrs in turn with CLUSTER SLOTS if they aren't known. Slots no node serves have empty addresses.
+func (s *Store) clusterSlots() ([]string, error) {
+	s.mu.Lock()
+	slots := s.slots
+	s.mu.Unlock()
+	if slots != nil {
+		return slots, nil
+	}
+	errs := []error{}
+	for _, addr := range s.cfg.Addrs {
+		reps, err := s.node(addr, false).do([]string{"CLUSTER", "SLOTS"})
+		if err == nil {
+			err = reps[0].err()
+		}
+		if err == nil {
+			slots, err = parseClusterSlots(reps[0], addr)
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		s.mu.Lock()
+		s.slots = slots
+		s.mu.Unlock()
+		return slots, nil
+	}
+	return nil, fmt.Errorf("discovering redis cluster slots: %w", errors.Join(errs...))
+}
+
+// parseClusterSlots returns the addresses of the masters serving each slot of the reply to CLUSTER SLOTS from the node at addr. Masters without a host are on the host of addr, as Redis reports them.
+func parseClusterSlots(rep reply, addr string) ([]string, error) {
+	if rep.kind != '*' {
+		return nil, fmt.Errorf("unexpected CLUSTER SLOTS reply type '%c'", rep.kind)
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	slots := make([]string, numSlots)
+	for _, r := range rep.array {
+		if len(r.array) < 3 || len(r.array[2].array) < 2 {
+			return nil, errors.New("malformed CLUSTER SLOTS reply")
+		}
+		start, err := r.array[0].int()
+		if err != nil {
+			return nil, err
+		}
+		end, err := r.array[1].int()
+		if err != nil {
+			return nil, err
+		}
+		if start < 0 || end >= numSlots || start > end {
+			return nil, fmt.Errorf("CLUSTER SLOTS reply has invalid slot range %d-%d", start, end)
+		}
+		master := r.array[2]
+		masterHost := master.array[0].str
+		if masterHost == "" {
+			masterHost = host
+		}
+		port, err := master.array[1].int()
+		if err != nil {
+			return nil, err
+		}
+		masterAddr := net.JoinHostPort(masterHost, fmt.Sprint(port))
+		for slot := start; slot <= end; slot++ {
+			slots[slot] = masterAddr
+		}
+	}
+	return slots, nil
+}
+
+// moved records that the slot of the key has moved to the node at addr, after a MOVED redirect.
+func (s *Store) moved(key, addr string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.slots == nil {
+		return
+	}
+	slots := append([]string(nil), s.slots...)
+	slots[keySlot(key)] = addr
+	s.slots = slots
+}
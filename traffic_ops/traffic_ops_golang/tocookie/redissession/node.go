@@ -0,0 +1,218 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redissession
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// errClosed is returned by the commands of a Store which has been closed.
+var errClosed = errors.New("redis session store closed")
+
+// request is a pipeline of commands sent to a node together, and their replies.
+type request struct {
+	cmds    [][]string
+	replies []reply
+	err     error
+	done    chan struct{}
+}
+
+// node is a connection to a Redis server, which pipelines the commands of concurrent callers: while the commands of one batch are in flight, the requests of other callers queue, and are sent together as the next batch, in a single write and round trip. The connection is established on first use, and re-established on the next batch after any failure.
+type node struct {
+	addr string
+	cfg  *Config
+	// requireMaster makes the node check, when it connects, that the server is a master, so a stale address of a Sentinel master which has been demoted is detected.
+	requireMaster bool
+
+	queue   chan *request
+	closed  chan struct{}
+	stopped chan struct{}
+	// closeOnce guards closed.
+	closeOnce sync.Once
+}
+
+func newNode(addr string, cfg *Config, requireMaster bool) *node {
+	n := &node{addr: addr, cfg: cfg, requireMaster: requireMaster, queue: make(chan *request, cfg.MaxPipeline), closed: make(chan struct{}), stopped: make(chan struct{})}
+	go n.run()
+	return n
+}
+
+// do sends the commands to the server as a pipeline, and returns their replies. The error is only that of the connection; error replies are returned as replies.
+func (n *node) do(cmds ...[]string) ([]reply, error) {
+	req := &request{cmds: cmds, done: make(chan struct{})}
+	select {
+	case n.queue <- req:
+	case <-n.closed:
+		return nil, errClosed
+	}
+	select {
+	case <-req.done:
+		return req.replies, req.err
+	case <-n.stopped:
+		select {
+		case <-req.done:
+			return req.replies, req.err
+		default:
+			return nil, errClosed
+		}
+	}
+}
+
+// close stops the node, failing the commands it hasn't sent.
+func (n *node) close() {
+	n.closeOnce.Do(func() { close(n.closed) })
+	<-n.stopped
+}
+
+// run sends the queued requests in batches until the node is closed.
+func (n *node) run() {
+	defer close(n.stopped)
+	var conn net.Conn
+	var r *bufio.Reader
+	defer func() {
+		if conn != nil {
+			conn.Close()
+		}
+	}()
+	batch := []*request{}
+	buf := []byte{}
+	for {
+		batch = batch[:0]
+		select {
+		case req := <-n.queue:
+			batch = append(batch, req)
+		case <-n.closed:
+			return
+		}
+		numCmds := len(batch[0].cmds)
+	drain:
+		for numCmds < n.cfg.MaxPipeline {
+			select {
+			case req := <-n.queue:
+				batch = append(batch, req)
+				numCmds += len(req.cmds)
+			default:
+				break drain
+			}
+		}
+
+		var err error
+		if conn == nil {
+			if conn, r, err = n.dial(); err != nil {
+				conn = nil
+			}
+		}
+		if err == nil {
+			buf = buf[:0]
+			for _, req := range batch {
+				for _, cmd := range req.cmds {
+					buf = appendCommand(buf, cmd)
+				}
+			}
+			err = n.roundTrip(conn, r, buf, batch)
+		}
+		if err != nil && conn != nil {
+			conn.Close()
+			conn = nil
+		}
+		for _, req := range batch {
+			if req.replies == nil || len(req.replies) < len(req.cmds) {
+				req.replies, req.err = nil, err
+			}
+			close(req.done)
+		}
+	}
+}
+
+// roundTrip writes the pipelined commands, and reads the replies of the requests of the batch in order.
+func (n *node) roundTrip(conn net.Conn, r *bufio.Reader, cmds []byte, batch []*request) error {
+	if err := conn.SetDeadline(time.Now().Add(n.cfg.Timeout)); err != nil {
+		return err
+	}
+	if _, err := conn.Write(cmds); err != nil {
+		return fmt.Errorf("writing to redis %s: %w", n.addr, err)
+	}
+	for _, req := range batch {
+		replies := make([]reply, len(req.cmds))
+		for i := range replies {
+			rep, err := readReply(r)
+			if err != nil {
+				return fmt.Errorf("reading from redis %s: %w", n.addr, err)
+			}
+			replies[i] = rep
+		}
+		req.replies = replies
+	}
+	return nil
+}
+
+// dial connects to the server, authenticating if there is a password, and checking that it is a master if the node requires one.
+func (n *node) dial() (net.Conn, *bufio.Reader, error) {
+	conn, err := net.DialTimeout("tcp", n.addr, n.cfg.Timeout)
+	if err != nil {
+		return nil, nil, fmt.Errorf("connecting to redis %s: %w", n.addr, err)
+	}
+	r := bufio.NewReader(conn)
+	setup := [][]string{}
+	if n.cfg.Password != "" {
+		setup = append(setup, []string{"AUTH", n.cfg.Password})
+	}
+	if n.requireMaster {
+		setup = append(setup, []string{"ROLE"})
+	}
+	if len(setup) == 0 {
+		return conn, r, nil
+	}
+	req := &request{cmds: setup}
+	if err := n.roundTrip(conn, r, appendSetup(setup), []*request{req}); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	for i, rep := range req.replies {
+		if err := rep.err(); err != nil {
+			conn.Close()
+			return nil, nil, fmt.Errorf("setting up connection to redis %s: %w", n.addr, err)
+		}
+		if setup[i][0] == "ROLE" && (len(rep.array) == 0 || rep.array[0].str != "master") {
+			conn.Close()
+			return nil, nil, fmt.Errorf("redis %s is not a master", n.addr)
+		}
+	}
+	return conn, r, nil
+}
+
+func appendSetup(cmds [][]string) []byte {
+	buf := []byte{}
+	for _, cmd := range cmds {
+		buf = appendCommand(buf, cmd)
+	}
+	return buf
+}
+
+// isRedirect returns whether the error reply redirects a Cluster command to another node, and the slot and address it redirects to.
+func isRedirect(rep reply) (kind string, addr string, ok bool) {
+	if rep.kind != '-' {
+		return "", "", false
+	}
+	fields := strings.Fields(rep.str)
+	if len(fields) != 3 || (fields[0] != "MOVED" && fields[0] != "ASK") {
+		return "", "", false
+	}
+	return fields[0], fields[2], true
+}
@@ -0,0 +1,423 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package redissession provides a tocookie.SessionStore and a tocookie.RevocationStore backed by Redis, for large Traffic Ops deployments whose servers share server-side sessions and revocations. It supports standalone Redis, Sentinel, and Cluster deployments, and speaks the Redis protocol itself, so it has no external dependencies:
+//
+//	store, err := redissession.New(redissession.Config{Addrs: []string{"sentinel1:26379", "sentinel2:26379"}, MasterName: "traffic_ops"})
+//	cookie, err := tocookie.NewServerSession(store, user, expiration, secret)
+//	c, err := tocookie.ParseServerSession(store, secret, cookie, tocookie.WithRevocationStore(store))
+//
+// Sessions and revocations are stored as keys which expire with them, plus TTLSlack, so Redis holds no more than could still be used. The commands of concurrent callers, such as the lookups of Middleware on every request, are pipelined: each Redis server has a single connection, and the commands queued while a round trip is in flight are sent together as the next. LoadMany and AreRevoked look up many keys in one round trip per server.
+//
+// tocookie/redisrevocation wraps a Store of revocations alone on a standalone server, configured by fields.
+package redissession
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/tocookie"
+)
+
+// DefaultPrefix prefixes the keys of the store, so they don't collide with other keys.
+const DefaultPrefix = "tocookie:"
+
+// DefaultTimeout is the default time limit of connecting to Redis and of each round trip.
+const DefaultTimeout = time.Second
+
+// DefaultTTLSlack is how long keys outlive the sessions and revocations they store by default, which covers the leeway of clocks between servers; see tocookie.WithLeeway.
+const DefaultTTLSlack = time.Minute
+
+// DefaultMaxPipeline is the default most commands sent to a server in one round trip.
+const DefaultMaxPipeline = 128
+
+// The infixes of the keys of sessions and revocations, after the prefix.
+const (
+	sessionInfix    = "session:"
+	revocationInfix = "revoked:"
+)
+
+// Config configures a Store.
+type Config struct {
+	// Addrs are the host:port addresses of the servers: the server of a standalone deployment, the sentinels of a Sentinel deployment, or some of the nodes of a Cluster, from which the rest are discovered.
+	Addrs []string
+	// MasterName, if it isn't empty, makes the store discover the master of the name from the sentinels of Addrs, and discover it again after a failover.
+	MasterName string
+	// Cluster makes the store route each key to the node of a Redis Cluster serving its slot, following MOVED and ASK redirects.
+	Cluster bool
+	// Password, if it isn't empty, authenticates connections to the servers, but not to the sentinels, with AUTH.
+	Password string
+	// SentinelPassword, if it isn't empty, authenticates connections to the sentinels with AUTH.
+	SentinelPassword string
+	// Prefix prefixes the keys. The default is DefaultPrefix.
+	Prefix string
+	// RevocationPrefix, if it isn't empty, prefixes the keys of revocations in place of Prefix followed by "revoked:", for stores sharing the revocations of tocookie/redisrevocation with a prefix of its own.
+	RevocationPrefix string
+	// Timeout is the time limit of connecting and of each round trip. The default is DefaultTimeout.
+	Timeout time.Duration
+	// TTLSlack is how long keys outlive the expiration of their sessions, and the end of their revocations. The default is DefaultTTLSlack.
+	TTLSlack time.Duration
+	// MaxPipeline is the most commands sent to a server in one round trip. The default is DefaultMaxPipeline.
+	MaxPipeline int
+}
+
+// Store is a tocookie.SessionStore and tocookie.RevocationStore backed by Redis. It is safe for concurrent use.
+type Store struct {
+	cfg Config
+
+	mu sync.Mutex
+	// nodes are the connections to the servers, by address.
+	nodes map[string]*node
+	// master is the address of the master of a Sentinel deployment, or empty if it must be discovered.
+	master string
+	// slots are the addresses of the nodes serving the slots of a Cluster, or nil if they must be discovered.
+	slots  []string
+	closed bool
+}
+
+// New returns a Store of the deployment. It doesn't connect until the first command.
+func New(cfg Config) (*Store, error) {
+	if len(cfg.Addrs) == 0 {
+		return nil, errors.New("no redis addresses")
+	}
+	if cfg.MasterName != "" && cfg.Cluster {
+		return nil, errors.New("redis can't be both a sentinel and a cluster deployment")
+	}
+	if cfg.MasterName == "" && !cfg.Cluster && len(cfg.Addrs) != 1 {
+		return nil, fmt.Errorf("standalone redis must have a single address, not %d", len(cfg.Addrs))
+	}
+	if cfg.Prefix == "" {
+		cfg.Prefix = DefaultPrefix
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+	if cfg.TTLSlack == 0 {
+		cfg.TTLSlack = DefaultTTLSlack
+	}
+	if cfg.MaxPipeline <= 0 {
+		cfg.MaxPipeline = DefaultMaxPipeline
+	}
+	if cfg.RevocationPrefix == "" {
+		cfg.RevocationPrefix = cfg.Prefix + revocationInfix
+	}
+	cfg.Addrs = append([]string(nil), cfg.Addrs...)
+	return &Store{cfg: cfg, nodes: map[string]*node{}}, nil
+}
+
+// Save stores the claims of the session, until they expire.
+func (s *Store) Save(id string, c *tocookie.Cookie) error {
+	claims, err := json.Marshal(c)
+	if err != nil {
+		return fmt.Errorf("encoding session claims: %w", err)
+	}
+	key := s.cfg.Prefix + sessionInfix + id
+	cmd := []string{"DEL", key}
+	if ttl := s.ttl(c.Expires()); ttl > 0 {
+		cmd = []string{"SET", key, string(claims), "PX", strconv.FormatInt(ttl, 10)}
+	}
+	if _, err := s.do(key, cmd); err != nil {
+		return fmt.Errorf("saving session: %w", err)
+	}
+	return nil
+}
+
+// Load returns the claims of the session, or an error wrapping tocookie.ErrSessionNotFound if Redis has none.
+func (s *Store) Load(id string) (*tocookie.Cookie, error) {
+	sessions, err := s.LoadMany([]string{id})
+	if err != nil {
+		return nil, err
+	}
+	if sessions[0] == nil {
+		return nil, tocookie.ErrSessionNotFound
+	}
+	return sessions[0], nil
+}
+
+// LoadMany returns the claims of the sessions, in one round trip per server, with nil for the sessions Redis doesn't have.
+func (s *Store) LoadMany(ids []string) ([]*tocookie.Cookie, error) {
+	keys, cmds := make([]string, len(ids)), make([][]string, len(ids))
+	for i, id := range ids {
+		keys[i] = s.cfg.Prefix + sessionInfix + id
+		cmds[i] = []string{"GET", keys[i]}
+	}
+	replies, err := s.doMany(keys, cmds)
+	if err != nil {
+		return nil, fmt.Errorf("loading sessions: %w", err)
+	}
+	sessions := make([]*tocookie.Cookie, len(ids))
+	for i, rep := range replies {
+		if rep.null {
+			continue
+		}
+		if rep.kind != '$' {
+			return nil, fmt.Errorf("loading session: unexpected reply type '%c'", rep.kind)
+		}
+		c := &tocookie.Cookie{}
+		if err := json.Unmarshal([]byte(rep.str), c); err != nil {
+			return nil, fmt.Errorf("decoding session claims: %w", err)
+		}
+		sessions[i] = c
+	}
+	return sessions, nil
+}
+
+// Delete removes the session.
+func (s *Store) Delete(id string) error {
+	key := s.cfg.Prefix + sessionInfix + id
+	if _, err := s.do(key, []string{"DEL", key}); err != nil {
+		return fmt.Errorf("deleting session: %w", err)
+	}
+	return nil
+}
+
+// Revoke revokes the session until the given time, when its key expires, plus TTLSlack. Sessions revoked until a time which has passed are ignored.
+func (s *Store) Revoke(sessionID string, until time.Time) error {
+	if !until.After(time.Now()) {
+		return nil
+	}
+	key := s.cfg.RevocationPrefix + sessionID
+	if _, err := s.do(key, []string{"SET", key, "1", "PX", strconv.FormatInt(s.ttl(until), 10)}); err != nil {
+		return fmt.Errorf("revoking session: %w", err)
+	}
+	return nil
+}
+
+// IsRevoked returns whether the session's revocation key exists.
+func (s *Store) IsRevoked(sessionID string) (bool, error) {
+	revoked, err := s.AreRevoked([]string{sessionID})
+	if err != nil {
+		return false, err
+	}
+	return revoked[0], nil
+}
+
+// AreRevoked returns whether each of the sessions is revoked, in one round trip per server.
+func (s *Store) AreRevoked(sessionIDs []string) ([]bool, error) {
+	keys, cmds := make([]string, len(sessionIDs)), make([][]string, len(sessionIDs))
+	for i, id := range sessionIDs {
+		keys[i] = s.cfg.RevocationPrefix + id
+		cmds[i] = []string{"EXISTS", keys[i]}
+	}
+	replies, err := s.doMany(keys, cmds)
+	if err != nil {
+		return nil, fmt.Errorf("checking session revocation: %w", err)
+	}
+	revoked := make([]bool, len(sessionIDs))
+	for i, rep := range replies {
+		n, err := rep.int()
+		if err != nil {
+			return nil, fmt.Errorf("checking session revocation: %w", err)
+		}
+		revoked[i] = n != 0
+	}
+	return revoked, nil
+}
+
+// Close closes the connections to the servers. Commands fail once the store is closed.
+func (s *Store) Close() error {
+	s.mu.Lock()
+	nodes := s.nodes
+	s.nodes, s.closed = map[string]*node{}, true
+	s.mu.Unlock()
+	for _, n := range nodes {
+		n.close()
+	}
+	return nil
+}
+
+// ttl returns the TTL, in milliseconds, of a key of something which lasts until the time.
+func (s *Store) ttl(until time.Time) int64 {
+	return int64(time.Until(until.Add(s.cfg.TTLSlack)) / time.Millisecond)
+}
+
+// do sends the command, of the key, and returns its reply, or the error of an error reply.
+func (s *Store) do(key string, cmd []string) (reply, error) {
+	replies, err := s.doMany([]string{key}, [][]string{cmd})
+	if err != nil {
+		return reply{}, err
+	}
+	return replies[0], nil
+}
+
+// doMany sends the commands, each of the key at the same index, pipelined to the servers of their keys, and returns their replies. Any error reply fails them all.
+func (s *Store) doMany(keys []string, cmds [][]string) ([]reply, error) {
+	replies := make([]reply, len(cmds))
+	groups, err := s.route(keys)
+	if err != nil {
+		return nil, err
+	}
+	errs := make([]error, len(groups))
+	wg := sync.WaitGroup{}
+	i := 0
+	for n, indexes := range groups {
+		wg.Add(1)
+		go func(i int, n *node, indexes []int) {
+			defer wg.Done()
+			errs[i] = s.doNode(n, indexes, cmds, replies)
+		}(i, n, indexes)
+		i++
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	for _, rep := range replies {
+		if err := rep.err(); err != nil {
+			return nil, err
+		}
+	}
+	return replies, nil
+}
+
+// doNode sends the commands at the indexes to the node, storing their replies at the same indexes. Commands redirected by a Cluster are sent again to the node they were redirected to, once.
+func (s *Store) doNode(n *node, indexes []int, cmds [][]string, replies []reply) error {
+	batch := make([][]string, len(indexes))
+	for i, index := range indexes {
+		batch[i] = cmds[index]
+	}
+	reps, err := n.do(batch...)
+	if err != nil {
+		s.failed(n)
+		return err
+	}
+	for i, index := range indexes {
+		replies[index] = reps[i]
+		kind, addr, redirected := isRedirect(reps[i])
+		if !redirected || !s.cfg.Cluster {
+			continue
+		}
+		target := s.node(addr, false)
+		redirect := [][]string{cmds[index]}
+		if kind == "ASK" {
+			redirect = [][]string{{"ASKING"}, cmds[index]}
+		} else {
+			// the key is the first argument of every command of the store.
+			s.moved(cmds[index][1], addr)
+		}
+		redirectReps, err := target.do(redirect...)
+		if err != nil {
+			s.failed(target)
+			return err
+		}
+		replies[index] = redirectReps[len(redirectReps)-1]
+	}
+	return nil
+}
+
+// route groups the indexes of the keys by the node serving them.
+func (s *Store) route(keys []string) (map[*node][]int, error) {
+	groups := map[*node][]int{}
+	switch {
+	case s.cfg.Cluster:
+		slots, err := s.clusterSlots()
+		if err != nil {
+			return nil, err
+		}
+		for i, key := range keys {
+			addr := slots[keySlot(key)]
+			if addr == "" {
+				addr = s.cfg.Addrs[0]
+			}
+			n := s.node(addr, false)
+			groups[n] = append(groups[n], i)
+		}
+	case s.cfg.MasterName != "":
+		master, err := s.sentinelMaster()
+		if err != nil {
+			return nil, err
+		}
+		n := s.node(master, true)
+		for i := range keys {
+			groups[n] = append(groups[n], i)
+		}
+	default:
+		n := s.node(s.cfg.Addrs[0], false)
+		for i := range keys {
+			groups[n] = append(groups[n], i)
+		}
+	}
+	s.mu.Lock()
+	closed := s.closed
+	s.mu.Unlock()
+	if closed {
+		return nil, errClosed
+	}
+	return groups, nil
+}
+
+// node returns the connection to the server at the address, creating it if there is none.
+func (s *Store) node(addr string, requireMaster bool) *node {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n, ok := s.nodes[addr]
+	if !ok {
+		n = newNode(addr, &s.cfg, requireMaster)
+		if !s.closed {
+			s.nodes[addr] = n
+		} else {
+			n.close()
+		}
+	}
+	return n
+}
+
+// failed forgets the topology which led to the node after its connection failed, so the Sentinel master or Cluster slots are discovered again by the next command, e.g. after a failover.
+func (s *Store) failed(n *node) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if n.addr == s.master {
+		s.master = ""
+	}
+	if s.cfg.Cluster {
+		s.slots = nil
+	}
+}
+
+// sentinelMaster returns the address of the master, asking the sentinels of Addrs in turn if it isn't known.
+func (s *Store) sentinelMaster() (string, error) {
+	s.mu.Lock()
+	master := s.master
+	s.mu.Unlock()
+	if master != "" {
+		return master, nil
+	}
+	cfg := s.cfg
+	cfg.Password = s.cfg.SentinelPassword
+	errs := []error{}
+	for _, addr := range s.cfg.Addrs {
+		sentinel := newNode(addr, &cfg, false)
+		reps, err := sentinel.do([]string{"SENTINEL", "get-master-addr-by-name", s.cfg.MasterName})
+		sentinel.close()
+		if err == nil {
+			err = reps[0].err()
+		}
+		if err == nil && (len(reps[0].array) != 2 || reps[0].null) {
+			err = fmt.Errorf("sentinel %s doesn't know master '%s'", addr, s.cfg.MasterName)
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		master = net.JoinHostPort(reps[0].array[0].str, reps[0].array[1].str)
+		s.mu.Lock()
+		s.master = master
+		s.mu.Unlock()
+		return master, nil
+	}
+	return "", fmt.Errorf("discovering redis master '%s': %w", s.cfg.MasterName, errors.Join(errs...))
+}
@@ -0,0 +1,337 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redissession
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/tocookie"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/tocookie/tocookietest"
+)
+
+func newStore(t *testing.T, cfg Config) *Store {
+	store, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New expected nil error, actual: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestNew(t *testing.T) {
+	tests := map[string]struct {
+		cfg Config
+		ok  bool
+	}{
+		"standalone":          {Config{Addrs: []string{"redis:6379"}}, true},
+		"sentinel":            {Config{Addrs: []string{"s1:26379", "s2:26379"}, MasterName: "mymaster"}, true},
+		"cluster":             {Config{Addrs: []string{"n1:6379", "n2:6379"}, Cluster: true}, true},
+		"no addresses":        {Config{}, false},
+		"standalone of two":   {Config{Addrs: []string{"r1:6379", "r2:6379"}}, false},
+		"sentinel as cluster": {Config{Addrs: []string{"s1:26379"}, MasterName: "mymaster", Cluster: true}, false},
+	}
+	for name, test := range tests {
+		store, err := New(test.cfg)
+		if (err == nil) != test.ok {
+			t.Errorf("New of %v expected success %v, actual: %v", name, test.ok, err)
+		}
+		if store != nil {
+			if store.cfg.Prefix != DefaultPrefix || store.cfg.Timeout != DefaultTimeout || store.cfg.TTLSlack != DefaultTTLSlack || store.cfg.MaxPipeline != DefaultMaxPipeline || store.cfg.RevocationPrefix != DefaultPrefix+revocationInfix {
+				t.Errorf("New of %v expected defaults, actual: %+v", name, store.cfg)
+			}
+			store.Close()
+		}
+	}
+}
+
+func TestStore(t *testing.T) {
+	f := tocookietest.NewRedisServer(t)
+	store := newStore(t, Config{Addrs: []string{f.Addr()}})
+	secret := "secret"
+	expiration := time.Now().Add(time.Hour)
+	cookie, err := tocookie.NewServerSession(store, "alice", expiration, secret)
+	if err != nil {
+		t.Fatalf("NewServerSession expected nil error, actual: %v", err)
+	}
+	c, err := tocookie.ParseServerSession(store, secret, cookie, tocookie.WithRevocationStore(store))
+	if err != nil {
+		t.Fatalf("ParseServerSession expected nil error, actual: %v", err)
+	}
+	if c.AuthData != "alice" {
+		t.Errorf("ParseServerSession expected AuthData alice, actual: %v", c.AuthData)
+	}
+	for key, e := range f.Keys() {
+		if ttl := time.Until(e.Expiry); ttl < time.Hour || ttl > time.Hour+DefaultTTLSlack {
+			t.Errorf("Save of %v expected TTL of the expiration plus slack, actual: %v", key, ttl)
+		}
+	}
+
+	if err := store.Revoke(c.SessionID, expiration); err != nil {
+		t.Fatalf("Revoke expected nil error, actual: %v", err)
+	}
+	if _, ok := f.Key(DefaultPrefix + revocationInfix + c.SessionID); !ok {
+		t.Errorf("Revoke expected key %v, actual: none", DefaultPrefix+revocationInfix+c.SessionID)
+	}
+	if _, err := tocookie.ParseServerSession(store, secret, cookie, tocookie.WithRevocationStore(store)); !errors.Is(err, tocookie.ErrRevoked) {
+		t.Errorf("ParseServerSession of revoked session expected ErrRevoked, actual: %v", err)
+	}
+	if revoked, err := store.AreRevoked([]string{"other", c.SessionID}); err != nil || revoked[0] || !revoked[1] {
+		t.Errorf("AreRevoked expected [false true], actual: %v %v", revoked, err)
+	}
+	if err := store.Revoke("lapsed", time.Now().Add(-time.Second)); err != nil {
+		t.Errorf("Revoke of lapsed revocation expected nil error, actual: %v", err)
+	}
+	if _, ok := f.Key(DefaultPrefix + revocationInfix + "lapsed"); ok {
+		t.Errorf("Revoke of lapsed revocation expected no key, actual: a key")
+	}
+}
+
+func TestStoreSessions(t *testing.T) {
+	f := tocookietest.NewRedisServer(t)
+	store := newStore(t, Config{Addrs: []string{f.Addr()}, Prefix: "to:"})
+	c := &tocookie.Cookie{AuthData: "bob", ExpiresUnix: time.Now().Add(time.Hour).Unix()}
+	if err := store.Save("a", c); err != nil {
+		t.Fatalf("Save expected nil error, actual: %v", err)
+	}
+	if _, ok := f.Key("to:session:a"); !ok {
+		t.Errorf("Save expected key to:session:a, actual: none")
+	}
+	sessions, err := store.LoadMany([]string{"a", "missing", "a"})
+	if err != nil {
+		t.Fatalf("LoadMany expected nil error, actual: %v", err)
+	}
+	if sessions[0] == nil || sessions[0].AuthData != "bob" || sessions[1] != nil || sessions[2] == nil {
+		t.Errorf("LoadMany expected [bob nil bob], actual: %v", sessions)
+	}
+	if err := store.Delete("a"); err != nil {
+		t.Fatalf("Delete expected nil error, actual: %v", err)
+	}
+	if _, err := store.Load("a"); !errors.Is(err, tocookie.ErrSessionNotFound) {
+		t.Errorf("Load of deleted session expected ErrSessionNotFound, actual: %v", err)
+	}
+
+	if err := store.Save("b", c); err != nil {
+		t.Fatalf("Save expected nil error, actual: %v", err)
+	}
+	expired := &tocookie.Cookie{AuthData: "bob", ExpiresUnix: time.Now().Add(-time.Hour).Unix()}
+	if err := store.Save("b", expired); err != nil {
+		t.Fatalf("Save of expired session expected nil error, actual: %v", err)
+	}
+	if _, ok := f.Key("to:session:b"); ok {
+		t.Errorf("Save of expired session expected its key deleted, actual: a key")
+	}
+}
+
+func TestStorePipelines(t *testing.T) {
+	f := tocookietest.NewRedisServer(t)
+	store := newStore(t, Config{Addrs: []string{f.Addr()}})
+	ids := make([]string, 50)
+	for i := range ids {
+		ids[i] = strconv.Itoa(i)
+	}
+	if _, err := store.AreRevoked(ids[:1]); err != nil {
+		t.Fatalf("AreRevoked expected nil error, actual: %v", err)
+	}
+	flushes := f.Flushes()
+	if _, err := store.AreRevoked(ids); err != nil {
+		t.Fatalf("AreRevoked expected nil error, actual: %v", err)
+	}
+	if round := f.Flushes() - flushes; round >= len(ids) {
+		t.Errorf("AreRevoked of %v sessions expected a pipeline, actual: %v round trips", len(ids), round)
+	}
+
+	wg := sync.WaitGroup{}
+	errs := make(chan error, len(ids))
+	for _, id := range ids {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			if err := store.Revoke(id, time.Now().Add(time.Hour)); err != nil {
+				errs <- err
+				return
+			}
+			if revoked, err := store.IsRevoked(id); err != nil || !revoked {
+				errs <- fmt.Errorf("IsRevoked of %v: %v %v", id, revoked, err)
+			}
+		}(id)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("concurrent commands expected nil error, actual: %v", err)
+	}
+}
+
+func TestStoreReconnects(t *testing.T) {
+	f := tocookietest.NewRedisServer(t)
+	store := newStore(t, Config{Addrs: []string{f.Addr()}})
+	if err := store.Revoke("sid", time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("Revoke expected nil error, actual: %v", err)
+	}
+	f.Drop()
+	if _, err := store.IsRevoked("sid"); err == nil {
+		t.Errorf("IsRevoked on dropped connection expected error, actual nil")
+	}
+	if revoked, err := store.IsRevoked("sid"); err != nil || !revoked {
+		t.Errorf("IsRevoked after reconnecting expected true, actual: %v %v", revoked, err)
+	}
+}
+
+func TestStoreAuth(t *testing.T) {
+	f := tocookietest.NewRedisServer(t)
+	f.Set(func(f *tocookietest.RedisServer) { f.Password = "hunter2" })
+	for password, ok := range map[string]bool{"": false, "wrong": false, "hunter2": true} {
+		store := newStore(t, Config{Addrs: []string{f.Addr()}, Password: password})
+		if _, err := store.IsRevoked("sid"); (err == nil) != ok {
+			t.Errorf("IsRevoked with password '%v' expected success %v, actual: %v", password, ok, err)
+		}
+	}
+}
+
+func TestStoreUnavailable(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listening: %v", err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+	if _, err := newStore(t, Config{Addrs: []string{addr}}).IsRevoked("sid"); err == nil {
+		t.Errorf("IsRevoked with unavailable redis expected error, actual nil")
+	}
+}
+
+func TestStoreClosed(t *testing.T) {
+	f := tocookietest.NewRedisServer(t)
+	store := newStore(t, Config{Addrs: []string{f.Addr()}})
+	store.Close()
+	if _, err := store.IsRevoked("sid"); !errors.Is(err, errClosed) {
+		t.Errorf("IsRevoked of closed store expected errClosed, actual: %v", err)
+	}
+}
+
+func TestStoreSentinel(t *testing.T) {
+	sentinel, master, replica := tocookietest.NewRedisServer(t), tocookietest.NewRedisServer(t), tocookietest.NewRedisServer(t)
+	sentinel.Set(func(f *tocookietest.RedisServer) { f.Password, f.Master = "sentinel", master.Addr() })
+	replica.Set(func(f *tocookietest.RedisServer) { f.Role = "slave" })
+	unavailable := tocookietest.NewRedisServer(t)
+	unavailable.Close()
+	store := newStore(t, Config{Addrs: []string{unavailable.Addr(), sentinel.Addr()}, MasterName: "mymaster", SentinelPassword: "sentinel"})
+
+	if err := store.Revoke("sid", time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("Revoke expected nil error, actual: %v", err)
+	}
+	if _, ok := master.Key(DefaultPrefix + revocationInfix + "sid"); !ok {
+		t.Errorf("Revoke expected key on master, actual: none")
+	}
+
+	// the master fails over to the replica, but the sentinel hasn't noticed yet.
+	master.Set(func(f *tocookietest.RedisServer) { f.Role = "slave" })
+	master.Drop()
+	if _, err := store.IsRevoked("sid"); err == nil {
+		t.Errorf("IsRevoked during failover expected error, actual nil")
+	}
+	if _, err := store.IsRevoked("sid"); err == nil {
+		t.Errorf("IsRevoked of demoted master expected error, actual nil")
+	}
+
+	sentinel.Set(func(f *tocookietest.RedisServer) { f.Master = replica.Addr() })
+	replica.Set(func(f *tocookietest.RedisServer) { f.Role = "master" })
+	if err := store.Revoke("sid2", time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("Revoke after failover expected nil error, actual: %v", err)
+	}
+	if _, ok := replica.Key(DefaultPrefix + revocationInfix + "sid2"); !ok {
+		t.Errorf("Revoke after failover expected key on new master, actual: none")
+	}
+
+	unknown := newStore(t, Config{Addrs: []string{sentinel.Addr()}, MasterName: "other", SentinelPassword: "sentinel"})
+	if _, err := unknown.IsRevoked("sid"); err == nil {
+		t.Errorf("IsRevoked of unknown master expected error, actual nil")
+	}
+}
+
+func TestStoreCluster(t *testing.T) {
+	a, b := tocookietest.NewRedisServer(t), tocookietest.NewRedisServer(t)
+	split := numSlots / 2
+	slots := tocookietest.RedisClusterSlots(split, a, b)
+	a.Set(func(f *tocookietest.RedisServer) {
+		f.Slots, f.Owned, f.Redirect = slots, func(slot int) bool { return slot < split }, "MOVED "+b.Addr()
+	})
+	b.Set(func(f *tocookietest.RedisServer) {
+		f.Slots, f.Owned, f.Redirect = slots, func(slot int) bool { return slot >= split }, "MOVED "+a.Addr()
+	})
+	store := newStore(t, Config{Addrs: []string{a.Addr()}, Cluster: true})
+
+	ids := make([]string, 20)
+	for i := range ids {
+		ids[i] = strconv.Itoa(i)
+		if err := store.Revoke(ids[i], time.Now().Add(time.Hour)); err != nil {
+			t.Fatalf("Revoke expected nil error, actual: %v", err)
+		}
+	}
+	for _, id := range ids {
+		key := DefaultPrefix + revocationInfix + id
+		owner := a
+		if keySlot(key) >= split {
+			owner = b
+		}
+		if _, ok := owner.Key(key); !ok {
+			t.Errorf("Revoke of %v expected key on the node of slot %v, actual: none", id, keySlot(key))
+		}
+	}
+	if revoked, err := store.AreRevoked(append(ids, "other")); err != nil || !revoked[0] || revoked[len(ids)] {
+		t.Errorf("AreRevoked across nodes expected revocations, actual: %v %v", revoked, err)
+	}
+
+	// the slots of b move to a.
+	a.Set(func(f *tocookietest.RedisServer) { f.Owned = func(slot int) bool { return true } })
+	b.Set(func(f *tocookietest.RedisServer) { f.Owned = func(slot int) bool { return false } })
+	if err := store.Save("moved", &tocookie.Cookie{AuthData: "carol", ExpiresUnix: time.Now().Add(time.Hour).Unix()}); err != nil {
+		t.Fatalf("Save after MOVED expected nil error, actual: %v", err)
+	}
+	if c, err := store.Load("moved"); err != nil || c.AuthData != "carol" {
+		t.Errorf("Load after MOVED expected carol, actual: %v %v", c, err)
+	}
+	if slots, _ := store.clusterSlots(); slots[keySlot(DefaultPrefix+sessionInfix+"moved")] != a.Addr() {
+		t.Errorf("MOVED expected slot to be updated to %v, actual: %v", a.Addr(), slots[keySlot(DefaultPrefix+sessionInfix+"moved")])
+	}
+}
+
+func TestStoreClusterAsk(t *testing.T) {
+	a, b := tocookietest.NewRedisServer(t), tocookietest.NewRedisServer(t)
+	key := DefaultPrefix + revocationInfix + "sid"
+	migrating := keySlot(key)
+	slots := tocookietest.RedisClusterSlots(numSlots/2, a, a)
+	a.Set(func(f *tocookietest.RedisServer) {
+		f.Slots, f.Owned, f.Redirect = slots, func(slot int) bool { return slot != migrating }, "ASK "+b.Addr()
+	})
+	b.Set(func(f *tocookietest.RedisServer) {
+		f.Slots, f.Owned, f.Redirect = slots, func(slot int) bool { return false }, "MOVED "+a.Addr()
+		f.Importing = func(slot int) bool { return slot == migrating }
+	})
+	store := newStore(t, Config{Addrs: []string{a.Addr()}, Cluster: true})
+	if err := store.Revoke("sid", time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("Revoke during migration expected nil error, actual: %v", err)
+	}
+	if _, ok := b.Key(key); !ok {
+		t.Errorf("Revoke during migration expected key on importing node, actual: none")
+	}
+	if slots, _ := store.clusterSlots(); slots[migrating] != a.Addr() {
+		t.Errorf("ASK expected slot to stay on %v, actual: %v", a.Addr(), slots[migrating])
+	}
+}
@@ -0,0 +1,134 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redissession
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// maxBulkLen bounds the length of the bulk strings read from Redis, so a corrupt reply can't make the client allocate without limit. Sessions are far smaller.
+const maxBulkLen = 1 << 20
+
+// maxArrayLen bounds the length of the arrays read from Redis, such as the reply of CLUSTER SLOTS.
+const maxArrayLen = 1 << 16
+
+// maxReplyDepth bounds the nesting of the arrays read from Redis.
+const maxReplyDepth = 8
+
+// reply is a RESP reply. Error replies are replies rather than Go errors, so the other replies of a pipeline can still be read; see err.
+type reply struct {
+	// kind is the type byte of the reply: '+', '-', ':', '$', or '*'.
+	kind byte
+	// str is the value of simple strings, errors, integers, and bulk strings.
+	str string
+	// null is whether a bulk string or array is the null reply.
+	null  bool
+	array []reply
+}
+
+// err returns the error of an error reply, and nil for other replies.
+func (r reply) err() error {
+	if r.kind == '-' {
+		return redisError(r.str)
+	}
+	return nil
+}
+
+// int returns the value of an integer reply.
+func (r reply) int() (int64, error) {
+	if r.kind != ':' {
+		return 0, fmt.Errorf("unexpected reply type '%c', expected integer", r.kind)
+	}
+	return strconv.ParseInt(r.str, 10, 64)
+}
+
+// redisError is an error reply from Redis, after which the connection is still usable.
+type redisError string
+
+func (e redisError) Error() string { return "redis: " + string(e) }
+
+// appendCommand appends the command to the buffer as an array of bulk strings.
+func appendCommand(buf []byte, args []string) []byte {
+	buf = append(buf, '*')
+	buf = strconv.AppendInt(buf, int64(len(args)), 10)
+	buf = append(buf, '\r', '\n')
+	for _, arg := range args {
+		buf = append(buf, '$')
+		buf = strconv.AppendInt(buf, int64(len(arg)), 10)
+		buf = append(buf, '\r', '\n')
+		buf = append(buf, arg...)
+		buf = append(buf, '\r', '\n')
+	}
+	return buf
+}
+
+// readReply reads a reply of any type.
+func readReply(r *bufio.Reader) (reply, error) {
+	return readReplyDepth(r, 0)
+}
+
+func readReplyDepth(r *bufio.Reader, depth int) (reply, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return reply{}, err
+	}
+	if !strings.HasSuffix(line, "\r\n") || len(line) < 3 {
+		return reply{}, fmt.Errorf("malformed reply line '%s'", strings.TrimSpace(line))
+	}
+	rep := reply{kind: line[0], str: line[1 : len(line)-2]}
+	switch rep.kind {
+	case '+', '-', ':':
+		return rep, nil
+	case '$':
+		n, err := strconv.Atoi(rep.str)
+		if err != nil || n < -1 || n > maxBulkLen {
+			return reply{}, fmt.Errorf("malformed bulk string length '%s'", rep.str)
+		}
+		if n == -1 {
+			rep.str, rep.null = "", true
+			return rep, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return reply{}, err
+		}
+		if buf[n] != '\r' || buf[n+1] != '\n' {
+			return reply{}, errors.New("malformed bulk string terminator")
+		}
+		rep.str = string(buf[:n])
+		return rep, nil
+	case '*':
+		n, err := strconv.Atoi(rep.str)
+		if err != nil || n < -1 || n > maxArrayLen || depth >= maxReplyDepth {
+			return reply{}, fmt.Errorf("malformed array length '%s'", rep.str)
+		}
+		rep.str = ""
+		if n == -1 {
+			rep.null = true
+			return rep, nil
+		}
+		rep.array = make([]reply, n)
+		for i := range rep.array {
+			if rep.array[i], err = readReplyDepth(r, depth+1); err != nil {
+				return reply{}, err
+			}
+		}
+		return rep, nil
+	}
+	return reply{}, fmt.Errorf("unexpected reply '%s'", strings.TrimSpace(line))
+}
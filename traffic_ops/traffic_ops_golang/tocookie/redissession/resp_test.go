@@ -0,0 +1,132 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redissession
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/tocookie/tocookietest"
+)
+
+func TestReadReply(t *testing.T) {
+	tests := map[string]struct {
+		in       string
+		expected reply
+		ok       bool
+	}{
+		"simple":          {"+OK\r\n", reply{kind: '+', str: "OK"}, true},
+		"error":           {"-ERR bad\r\n", reply{kind: '-', str: "ERR bad"}, true},
+		"integer":         {":42\r\n", reply{kind: ':', str: "42"}, true},
+		"bulk":            {"$5\r\nhe\r\no\r\n", reply{kind: '$', str: "he\r\no"}, true},
+		"empty bulk":      {"$0\r\n\r\n", reply{kind: '$'}, true},
+		"null bulk":       {"$-1\r\n", reply{kind: '$', null: true}, true},
+		"null array":      {"*-1\r\n", reply{kind: '*', null: true}, true},
+		"array":           {"*2\r\n$1\r\na\r\n:1\r\n", reply{kind: '*', array: []reply{{kind: '$', str: "a"}, {kind: ':', str: "1"}}}, true},
+		"truncated bulk":  {"$5\r\nhe", reply{}, false},
+		"bad terminator":  {"$2\r\nhello\r\n", reply{}, false},
+		"huge bulk":       {"$2000000\r\n", reply{}, false},
+		"huge array":      {"*2000000\r\n", reply{}, false},
+		"deep array":      {strings.Repeat("*1\r\n", maxReplyDepth+1) + ":1\r\n", reply{}, false},
+		"bare newline":    {"+OK\n", reply{}, false},
+		"unknown type":    {"?what\r\n", reply{}, false},
+		"bad bulk length": {"$x\r\n", reply{}, false},
+	}
+	for name, test := range tests {
+		actual, err := readReply(bufio.NewReader(strings.NewReader(test.in)))
+		if (err == nil) != test.ok {
+			t.Errorf("readReply of %v expected success %v, actual: %v", name, test.ok, err)
+			continue
+		}
+		if test.ok && !equalReplies(actual, test.expected) {
+			t.Errorf("readReply of %v expected %+v, actual: %+v", name, test.expected, actual)
+		}
+	}
+}
+
+func equalReplies(a, b reply) bool {
+	if a.kind != b.kind || a.str != b.str || a.null != b.null || len(a.array) != len(b.array) {
+		return false
+	}
+	for i := range a.array {
+		if !equalReplies(a.array[i], b.array[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestReplyErr(t *testing.T) {
+	if err := (reply{kind: '-', str: "NOAUTH"}).err(); err == nil || err.Error() != "redis: NOAUTH" {
+		t.Errorf("err of error reply expected 'redis: NOAUTH', actual: %v", err)
+	}
+	if err := (reply{kind: '+', str: "OK"}).err(); err != nil {
+		t.Errorf("err of simple string expected nil, actual: %v", err)
+	}
+	if _, err := (reply{kind: '$', str: "1"}).int(); err == nil {
+		t.Errorf("int of bulk string expected error, actual nil")
+	}
+}
+
+func TestAppendCommand(t *testing.T) {
+	expected := "*3\r\n$3\r\nSET\r\n$1\r\nk\r\n$0\r\n\r\n"
+	if actual := string(appendCommand(nil, []string{"SET", "k", ""})); actual != expected {
+		t.Errorf("appendCommand expected %q, actual: %q", expected, actual)
+	}
+}
+
+func TestKeySlot(t *testing.T) {
+	if crc := crc16("123456789"); crc != 0x31C3 {
+		t.Errorf("crc16 of check string expected 0x31C3, actual: %#x", crc)
+	}
+	// the slots CLUSTER KEYSLOT returns.
+	tests := map[string]int{
+		"foo":                  12182,
+		"somekey":              11058,
+		"{user1000}.following": keySlot("user1000"),
+		"{user1000}.followers": keySlot("user1000"),
+		"foo{}{bar}":           keySlot("foo{}{bar}"),
+		"foo{{bar}}zap":        keySlot("{bar"),
+	}
+	for key, expected := range tests {
+		if actual := keySlot(key); actual != expected {
+			t.Errorf("keySlot of %v expected %v, actual: %v", key, expected, actual)
+		}
+		if fake := tocookietest.RedisKeySlot(key); fake != expected {
+			t.Errorf("tocookietest.RedisKeySlot of %v expected %v, actual: %v", key, expected, fake)
+		}
+	}
+	if keySlot("foo{}{bar}") == keySlot("bar") {
+		t.Errorf("keySlot of empty hash tag expected the whole key hashed, actual: the slot of bar")
+	}
+}
+
+func TestIsRedirect(t *testing.T) {
+	tests := map[string]struct {
+		rep        reply
+		kind, addr string
+		ok         bool
+	}{
+		"moved":       {reply{kind: '-', str: "MOVED 3999 127.0.0.1:6381"}, "MOVED", "127.0.0.1:6381", true},
+		"ask":         {reply{kind: '-', str: "ASK 3999 127.0.0.1:6381"}, "ASK", "127.0.0.1:6381", true},
+		"other error": {reply{kind: '-', str: "ERR unknown command"}, "", "", false},
+		"not error":   {reply{kind: '+', str: "MOVED 3999 127.0.0.1:6381"}, "", "", false},
+	}
+	for name, test := range tests {
+		kind, addr, ok := isRedirect(test.rep)
+		if kind != test.kind || addr != test.addr || ok != test.ok {
+			t.Errorf("isRedirect of %v expected %v %v %v, actual: %v %v %v", name, test.kind, test.addr, test.ok, kind, addr, ok)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// refreshReadyTTL is how long a cookie refreshed by a RefreshQueue waits for a response of its session before it is discarded, so the cookies of clients which went away don't use up the queue.
+const refreshReadyTTL = 5 * time.Minute
+
+// RefreshQueue refreshes cookies for Middleware in the background, on a bounded pool of workers, so the HMAC and JSON work of refreshing isn't on the path of the request; see WithRefreshQueue. It is safe for concurrent use, and may be shared by Middlewares. Close stops its workers.
+type RefreshQueue struct {
+	jobs chan refreshJob
+	size int
+	wg   sync.WaitGroup
+
+	mu sync.Mutex
+	// pending are the keys of the sessions waiting for or being refreshed.
+	pending map[string]bool
+	// ready are the refreshed cookies of sessions, waiting for a response of the session to be set on.
+	ready  map[string]readyCookie
+	closed bool
+}
+
+// refreshJob is the refresh of the cookie of a session, which returns the refreshed cookie, or an empty string if it wasn't refreshed.
+type refreshJob struct {
+	key     string
+	refresh func() string
+}
+
+// readyCookie is a refreshed cookie, and when it was refreshed.
+type readyCookie struct {
+	cookie string
+	at     time.Time
+}
+
+// NewRefreshQueue returns a RefreshQueue refreshing cookies on the given number of workers, which holds at most size refreshes waiting for a worker, and size refreshed cookies waiting for a response. Refreshes beyond that are dropped, to be queued again by a later request of the session. Workers and size are at least 1.
+func NewRefreshQueue(workers, size int) *RefreshQueue {
+	if workers < 1 {
+		workers = 1
+	}
+	if size < 1 {
+		size = 1
+	}
+	q := &RefreshQueue{jobs: make(chan refreshJob, size), size: size, pending: map[string]bool{}, ready: map[string]readyCookie{}}
+	q.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go q.work()
+	}
+	return q
+}
+
+// WithRefreshQueue makes Middleware refresh cookies on the queue rather than while handling the request. A request whose cookie needs refreshing, within the window of WithRefreshWindow or in an older version than WithVersionPolicy mints, queues the refresh and is handled at once; the refreshed cookie is set on the next response of the same session, after it is ready. Refreshes are deduplicated by the SessionID of cookies, or by the cookie itself if it has none, so concurrent requests of a session queue one refresh.
+//
+// Stale cookies of WithExpiryGrace are still refreshed synchronously, as they can't wait for another request. So are all cookies given WithRotation, as a refreshed cookie which is never delivered would leave its client with a rotated-out cookie. Refreshes run with a background context, as the request may have ended.
+func WithRefreshQueue(q *RefreshQueue) Option {
+	return func(o *options) { o.refreshQueue = q }
+}
+
+// Close stops the workers of the queue, after the refreshes already queued, and waits for them. Middleware stops refreshing cookies on the queue once it is closed. It is safe to call more than once.
+func (q *RefreshQueue) Close() {
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.jobs)
+	}
+	q.mu.Unlock()
+	q.wg.Wait()
+}
+
+func (q *RefreshQueue) work() {
+	defer q.wg.Done()
+	for job := range q.jobs {
+		q.finish(job.key, job.refresh())
+	}
+}
+
+// enqueue queues the refresh of the session, unless one is pending or ready, or the queue is full or closed, and returns whether it was queued.
+func (q *RefreshQueue) enqueue(key string, refresh func() string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if _, ok := q.ready[key]; q.closed || ok || q.pending[key] {
+		return false
+	}
+	select {
+	case q.jobs <- refreshJob{key: key, refresh: refresh}:
+		q.pending[key] = true
+		return true
+	default:
+		return false
+	}
+}
+
+// finish records the refreshed cookie of the session, if it was refreshed, and there is room for it once expired cookies are discarded.
+func (q *RefreshQueue) finish(key, cookie string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.pending, key)
+	if cookie == "" {
+		return
+	}
+	now := time.Now()
+	if len(q.ready) >= q.size {
+		for k, r := range q.ready {
+			if now.Sub(r.at) > refreshReadyTTL {
+				delete(q.ready, k)
+			}
+		}
+	}
+	if len(q.ready) < q.size {
+		q.ready[key] = readyCookie{cookie: cookie, at: now}
+	}
+}
+
+// take returns the refreshed cookie of the session, if one is ready, and removes it, so it is only set on one response.
+func (q *RefreshQueue) take(key string) (string, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	r, ok := q.ready[key]
+	if !ok {
+		return "", false
+	}
+	delete(q.ready, key)
+	if time.Since(r.at) > refreshReadyTTL {
+		return "", false
+	}
+	return r.cookie, true
+}
+
+// refreshLater sets the refreshed cookie of the session of c on the response if one is ready, or else queues its refresh if it needs one, i.e. upgrade is set or it is within the refresh window.
+func (q *RefreshQueue) refreshLater(w http.ResponseWriter, r *http.Request, c *Cookie, token, secret string, upgrade bool, opts []Option, o *options) {
+	key := c.SessionID
+	if key == "" {
+		key = token
+	}
+	if refreshed, ok := q.take(key); ok {
+		o.setHTTPCookies(w, r, refreshed, time.Time{})
+		return
+	}
+	if !upgrade && !c.IsExpiringSoon(o.refreshWindow, o.now()) {
+		return
+	}
+	q.enqueue(key, func() string {
+		if upgrade {
+			return RefreshContext(context.Background(), c, secret, opts...)
+		}
+		refreshed, err := RefreshIfNeededContext(context.Background(), c, secret, o.refreshWindow, opts...)
+		if err != nil {
+			return ""
+		}
+		return refreshed
+	})
+}
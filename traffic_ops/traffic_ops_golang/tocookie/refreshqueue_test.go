@@ -0,0 +1,117 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// waitReady waits for the refreshed cookie of the session to be ready on the queue.
+func waitReady(t *testing.T, q *RefreshQueue, key string) {
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		q.mu.Lock()
+		_, ok := q.ready[key]
+		q.mu.Unlock()
+		if ok {
+			return
+		}
+	}
+	t.Fatalf("RefreshQueue expected refreshed cookie of '%v' to be ready, actual: none after 5s", key)
+}
+
+func TestWithRefreshQueue(t *testing.T) {
+	secret := "secret"
+	q := NewRefreshQueue(2, 8)
+	defer q.Close()
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	serve := func(cookie string, opts ...Option) *http.Response {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.AddCookie(&http.Cookie{Name: Name, Value: cookie})
+		w := httptest.NewRecorder()
+		Middleware(secret, next, append(opts, WithRefreshWindow(DefaultRefreshWindow), WithRefreshQueue(q))...).ServeHTTP(w, r)
+		return w.Result()
+	}
+
+	cookie := New("alice", time.Now().Add(time.Minute), secret)
+	if resp := serve(cookie); resp.StatusCode != http.StatusOK || len(resp.Cookies()) != 0 {
+		t.Fatalf("Middleware WithRefreshQueue expected no cookie set on the first response, actual: %v %v", resp.StatusCode, resp.Cookies())
+	}
+	c, _ := Parse(secret, cookie)
+	waitReady(t, q, c.SessionID)
+	resp := serve(cookie)
+	if len(resp.Cookies()) != 1 {
+		t.Fatalf("Middleware WithRefreshQueue expected the refreshed cookie set on the next response, actual: %v", resp.Cookies())
+	}
+	if refreshed, err := Parse(secret, resp.Cookies()[0].Value); err != nil || refreshed.SessionID != c.SessionID || time.Until(refreshed.Expires()) < DefaultDuration-time.Minute {
+		t.Errorf("Middleware WithRefreshQueue expected a refreshed cookie of the session, actual: %+v %v", refreshed, err)
+	}
+	if resp := serve(cookie); len(resp.Cookies()) != 0 {
+		t.Errorf("Middleware WithRefreshQueue expected the refreshed cookie set on one response only, actual: %v", resp.Cookies())
+	}
+
+	if resp := serve(New("alice", time.Now().Add(time.Hour), secret, WithRefreshWindow(time.Minute)), WithRefreshWindow(time.Minute)); len(resp.Cookies()) != 0 {
+		t.Errorf("Middleware WithRefreshQueue outside the window expected no cookie set, actual: %v", resp.Cookies())
+	}
+	if resp := serve(New("alice", time.Now().Add(-30*time.Second), secret), WithExpiryGrace(time.Minute)); len(resp.Cookies()) != 1 {
+		t.Errorf("Middleware WithRefreshQueue of a stale cookie expected it refreshed synchronously, actual: %v", resp.Cookies())
+	}
+	if resp := serve(New("alice", time.Now().Add(time.Minute), secret), WithRotation(NewMemoryRotationStore())); len(resp.Cookies()) != 1 {
+		t.Errorf("Middleware WithRefreshQueue WithRotation expected the cookie refreshed synchronously, actual: %v", resp.Cookies())
+	}
+}
+
+func TestRefreshQueue(t *testing.T) {
+	q := NewRefreshQueue(1, 2)
+	block, started := make(chan struct{}), make(chan struct{})
+	if !q.enqueue("a", func() string { close(started); <-block; return "refreshed a" }) {
+		t.Fatalf("enqueue expected true, actual: false")
+	}
+	<-started
+	if q.enqueue("a", func() string { return "again" }) {
+		t.Errorf("enqueue of a pending session expected false, actual: true")
+	}
+	if !q.enqueue("b", func() string { return "refreshed b" }) || !q.enqueue("c", func() string { return "" }) {
+		t.Errorf("enqueue of other sessions expected true, actual: false")
+	}
+	if q.enqueue("d", func() string { return "refreshed d" }) {
+		t.Errorf("enqueue of a full queue expected false, actual: true")
+	}
+	close(block)
+	q.Close()
+	q.Close()
+
+	if cookie, ok := q.take("a"); !ok || cookie != "refreshed a" {
+		t.Errorf("take expected 'refreshed a', actual: '%v' %v", cookie, ok)
+	}
+	if _, ok := q.take("a"); ok {
+		t.Errorf("take of a taken cookie expected false, actual: true")
+	}
+	if _, ok := q.take("c"); ok {
+		t.Errorf("take of an unrefreshed cookie expected false, actual: true")
+	}
+	if q.enqueue("e", func() string { return "refreshed e" }) {
+		t.Errorf("enqueue of a closed queue expected false, actual: true")
+	}
+
+	q = NewRefreshQueue(1, 1)
+	defer q.Close()
+	q.finish("old", "refreshed old")
+	q.ready["old"] = readyCookie{"refreshed old", time.Now().Add(-2 * refreshReadyTTL)}
+	q.finish("new", "refreshed new")
+	if _, ok := q.take("new"); !ok {
+		t.Errorf("finish of a full queue expected expired cookies discarded for room, actual: %v", q.ready)
+	}
+}
@@ -0,0 +1,237 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RememberName is the name of the persistent login cookie of RememberHTTPCookie. It is distinct from Name and its chunks, so setting or clearing the session's cookies never touches it.
+const RememberName = Name + "_remember"
+
+// DefaultRememberDuration is how long remember-me series last, by default: users who opt in stay signed in across browser restarts for up to this long after logging in with their credentials, however often the token is used.
+const DefaultRememberDuration = 30 * 24 * time.Hour
+
+// RememberGrace is how long after a remember-me token is rotated its previous token is rejected with ErrRememberInvalid rather than treated as theft, so concurrent requests of the same browser, which all send the previous token, don't revoke its series.
+const RememberGrace = 10 * time.Second
+
+// rememberTokenLen is the number of random bytes of remember-me series IDs and tokens.
+const rememberTokenLen = 32
+
+// ErrRememberInvalid is returned by UseRememberToken for remember-me cookies which are malformed, expired, unknown to the store, or were just rotated by a concurrent request.
+var ErrRememberInvalid = errors.New("remember-me token invalid")
+
+// ErrRememberTheft is returned by UseRememberToken when a known series is presented with a token which isn't its current one. As tokens are rotated on every use, this means the cookie was copied and used elsewhere, by the user or by a thief, so every series of the user has been deleted.
+var ErrRememberTheft = errors.New("remember-me token reused")
+
+// RememberSeries is a remember-me series, as a RememberStore stores it. Tokens are stored as their SHA-256 hashes, so the contents of the store can't be used to log in.
+type RememberSeries struct {
+	// User is the user the series signs in.
+	User string
+	// TokenHash is the hex-encoded SHA-256 hash of the series' current token.
+	TokenHash string
+	// PreviousHash is the hash of the token before it, accepted without theft detection until RememberGrace after Rotated.
+	PreviousHash string
+	// Rotated is when the token was last rotated.
+	Rotated time.Time
+	// Expires is when the series expires. It isn't extended by rotation.
+	Expires time.Time
+	// AuthTime is when the user logged in with their credentials, starting the series, for WithAuthTime of the sessions it restores.
+	AuthTime time.Time
+}
+
+// RememberStore stores the remember-me series of persistent logins, by the scheme of Barry Jaspan's "Improved Persistent Login Cookie Best Practice": each cookie is a series ID and a single-use token, so a stolen cookie is detected as soon as either copy is used after the other. Implementations must be safe for concurrent use. MemoryRememberStore is a RememberStore for a single server.
+type RememberStore interface {
+	// Save stores the new series with the ID.
+	Save(series string, s RememberSeries) error
+	// Load returns the series with the ID, or an error wrapping ErrRememberInvalid if the store has none.
+	Load(series string) (*RememberSeries, error)
+	// Rotate replaces the series with the ID by next, only if its TokenHash is still tokenHash, and otherwise returns an error wrapping ErrRememberInvalid, so only one of concurrent uses of a token succeeds.
+	Rotate(series, tokenHash string, next RememberSeries) error
+	// Delete removes the series with the ID. Deleting a series the store doesn't have isn't an error.
+	Delete(series string) error
+	// DeleteUser removes every series of the user.
+	DeleteUser(user string) error
+}
+
+// NewRememberToken starts a remember-me series of the user in the store, expiring at the given time, and returns its cookie value, for RememberHTTPCookie. It is independent of the user's session: a service presented with the cookie and no session uses it with UseRememberToken, and mints a new session, of its usual duration.
+func NewRememberToken(store RememberStore, user string, expiration time.Time) (string, error) {
+	series, err := newRememberRandom()
+	if err != nil {
+		return "", err
+	}
+	token, err := newRememberRandom()
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	s := RememberSeries{User: user, TokenHash: hashRememberToken(token), Rotated: now, Expires: expiration, AuthTime: now}
+	if err := store.Save(series, s); err != nil {
+		return "", fmt.Errorf("saving remember-me series: %w", err)
+	}
+	return series + "." + token, nil
+}
+
+// UseRememberToken verifies the remember-me cookie value against the store, and rotates its token, returning the series, whose User is the user to sign in, and the new cookie value, which must replace the old one in the browser. Cookies which are malformed, expired, or unknown are rejected with an error wrapping ErrRememberInvalid; a known series with a token other than its current one is rejected with an error wrapping ErrRememberTheft, after every series of its user has been deleted.
+func UseRememberToken(store RememberStore, value string) (*RememberSeries, string, error) {
+	series, token, ok := strings.Cut(value, ".")
+	if !ok || len(series) != 2*rememberTokenLen || len(token) != 2*rememberTokenLen {
+		return nil, "", fmt.Errorf("%w: malformed", ErrRememberInvalid)
+	}
+	s, err := store.Load(series)
+	if err != nil {
+		return nil, "", fmt.Errorf("loading remember-me series: %w", err)
+	}
+	now := time.Now()
+	if now.After(s.Expires) {
+		if err := store.Delete(series); err != nil {
+			return nil, "", fmt.Errorf("deleting expired remember-me series: %w", err)
+		}
+		return nil, "", fmt.Errorf("%w: expired", ErrRememberInvalid)
+	}
+	tokenHash := hashRememberToken(token)
+	if !constantTimeEqual(tokenHash, s.TokenHash) {
+		if s.PreviousHash != "" && constantTimeEqual(tokenHash, s.PreviousHash) && now.Before(s.Rotated.Add(RememberGrace)) {
+			return nil, "", fmt.Errorf("%w: just rotated", ErrRememberInvalid)
+		}
+		if err := store.DeleteUser(s.User); err != nil {
+			return nil, "", fmt.Errorf("%w: deleting series of user '%v': %v", ErrRememberTheft, s.User, err)
+		}
+		return nil, "", fmt.Errorf("%w: series of user '%v' deleted", ErrRememberTheft, s.User)
+	}
+	next, err := newRememberRandom()
+	if err != nil {
+		return nil, "", err
+	}
+	rotated := RememberSeries{User: s.User, TokenHash: hashRememberToken(next), PreviousHash: tokenHash, Rotated: now, Expires: s.Expires, AuthTime: s.AuthTime}
+	if err := store.Rotate(series, tokenHash, rotated); err != nil {
+		return nil, "", fmt.Errorf("rotating remember-me series: %w", err)
+	}
+	return &rotated, series + "." + next, nil
+}
+
+// ForgetRememberToken deletes the series of the remember-me cookie value from the store, e.g. on logout, so neither it nor any copy can be used again. Malformed values are ignored, as they have no series.
+func ForgetRememberToken(store RememberStore, value string) error {
+	series, _, ok := strings.Cut(value, ".")
+	if !ok {
+		return nil
+	}
+	if err := store.Delete(series); err != nil {
+		return fmt.Errorf("deleting remember-me series: %w", err)
+	}
+	return nil
+}
+
+// RememberHTTPCookie returns the remember-me cookie value as an *http.Cookie named RememberName, or after WithCookieName, with the attributes of NewHTTPCookie, expiring with its series, so the browser keeps it across restarts.
+func RememberHTTPCookie(value string, expiration time.Time, opts ...Option) *http.Cookie {
+	o := newOptions(opts)
+	c := o.httpCookie(value, expiration)
+	c.Name = o.rememberName()
+	return c
+}
+
+// ClearRememberHTTPCookie expires the remember-me cookie on the response.
+func ClearRememberHTTPCookie(w http.ResponseWriter, opts ...Option) {
+	o := newOptions(opts)
+	o.expireCookie(w, o.rememberName())
+}
+
+// newRememberRandom returns a random series ID or token, hex-encoded.
+func newRememberRandom() (string, error) {
+	b := make([]byte, rememberTokenLen)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generating remember-me token: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func hashRememberToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// MemoryRememberStore is a RememberStore held in memory, for a single server. Series are lost when the process exits, signing their users out. It is safe for concurrent use.
+type MemoryRememberStore struct {
+	mu     sync.Mutex
+	series map[string]RememberSeries
+}
+
+// NewMemoryRememberStore returns an empty MemoryRememberStore.
+func NewMemoryRememberStore() *MemoryRememberStore {
+	return &MemoryRememberStore{series: map[string]RememberSeries{}}
+}
+
+// Save stores the series. Series which have expired are pruned, so the store only holds series which could still be used.
+func (s *MemoryRememberStore) Save(series string, r RememberSeries) error {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, existing := range s.series {
+		if now.After(existing.Expires) {
+			delete(s.series, id)
+		}
+	}
+	s.series[series] = r
+	return nil
+}
+
+// Load returns a copy of the series.
+func (s *MemoryRememberStore) Load(series string) (*RememberSeries, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.series[series]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown series", ErrRememberInvalid)
+	}
+	return &r, nil
+}
+
+// Rotate replaces the series, if its token hasn't changed.
+func (s *MemoryRememberStore) Rotate(series, tokenHash string, next RememberSeries) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.series[series]
+	if !ok || r.TokenHash != tokenHash {
+		return fmt.Errorf("%w: rotated concurrently", ErrRememberInvalid)
+	}
+	s.series[series] = next
+	return nil
+}
+
+// Delete removes the series.
+func (s *MemoryRememberStore) Delete(series string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.series, series)
+	return nil
+}
+
+// DeleteUser removes every series of the user.
+func (s *MemoryRememberStore) DeleteUser(user string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, r := range s.series {
+		if r.User == user {
+			delete(s.series, id)
+		}
+	}
+	return nil
+}
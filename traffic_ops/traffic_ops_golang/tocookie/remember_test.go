@@ -0,0 +1,112 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRememberToken(t *testing.T) {
+	store := NewMemoryRememberStore()
+	expiration := time.Now().Add(time.Hour)
+	value, err := NewRememberToken(store, "alice", expiration)
+	if err != nil {
+		t.Fatalf("NewRememberToken expected no error, actual: %v", err)
+	}
+
+	s, rotated, err := UseRememberToken(store, value)
+	if err != nil || s.User != "alice" || !s.Expires.Equal(expiration) {
+		t.Fatalf("UseRememberToken expected series of alice expiring at %v, actual: %+v %v", expiration, s, err)
+	}
+	if rotated == value || !strings.HasPrefix(rotated, strings.SplitN(value, ".", 2)[0]+".") {
+		t.Errorf("UseRememberToken expected new token of the same series, actual: '%v' from '%v'", rotated, value)
+	}
+	if _, _, err := UseRememberToken(store, value); !errors.Is(err, ErrRememberInvalid) || errors.Is(err, ErrRememberTheft) {
+		t.Errorf("UseRememberToken of just rotated token expected ErrRememberInvalid, actual: %v", err)
+	}
+	if s, _, err := UseRememberToken(store, rotated); err != nil || s.User != "alice" || s.AuthTime.IsZero() || s.AuthTime.After(time.Now()) {
+		t.Errorf("UseRememberToken of rotated token expected alice, actual: %+v %v", s, err)
+	}
+}
+
+func TestRememberTokenTheft(t *testing.T) {
+	store := NewMemoryRememberStore()
+	stolen, _ := NewRememberToken(store, "alice", time.Now().Add(time.Hour))
+	other, _ := NewRememberToken(store, "alice", time.Now().Add(time.Hour))
+	bob, _ := NewRememberToken(store, "bob", time.Now().Add(time.Hour))
+
+	if _, _, err := UseRememberToken(store, stolen); err != nil {
+		t.Fatalf("UseRememberToken expected no error, actual: %v", err)
+	}
+	series := strings.SplitN(stolen, ".", 2)[0]
+	r, _ := store.Load(series)
+	r.Rotated = time.Now().Add(-2 * RememberGrace)
+	store.series[series] = *r
+
+	if _, _, err := UseRememberToken(store, stolen); !errors.Is(err, ErrRememberTheft) {
+		t.Errorf("UseRememberToken of reused token expected ErrRememberTheft, actual: %v", err)
+	}
+	if _, _, err := UseRememberToken(store, other); !errors.Is(err, ErrRememberInvalid) {
+		t.Errorf("UseRememberToken of other series of the user after theft expected ErrRememberInvalid, actual: %v", err)
+	}
+	if s, _, err := UseRememberToken(store, bob); err != nil || s.User != "bob" {
+		t.Errorf("UseRememberToken of other user after theft expected bob, actual: %+v %v", s, err)
+	}
+}
+
+func TestUseRememberTokenInvalid(t *testing.T) {
+	store := NewMemoryRememberStore()
+	expired, _ := NewRememberToken(store, "alice", time.Now().Add(-time.Second))
+	forgotten, _ := NewRememberToken(store, "alice", time.Now().Add(time.Hour))
+	if err := ForgetRememberToken(store, forgotten); err != nil {
+		t.Fatalf("ForgetRememberToken expected no error, actual: %v", err)
+	}
+
+	tests := map[string]string{
+		"expired":   expired,
+		"forgotten": forgotten,
+		"unknown":   strings.Repeat("a", 64) + "." + strings.Repeat("b", 64),
+		"malformed": "series",
+		"short":     "a.b",
+		"empty":     "",
+	}
+	for name, value := range tests {
+		if _, _, err := UseRememberToken(store, value); !errors.Is(err, ErrRememberInvalid) {
+			t.Errorf("%v: UseRememberToken expected ErrRememberInvalid, actual: %v", name, err)
+		}
+	}
+	if _, err := store.Load(strings.SplitN(expired, ".", 2)[0]); !errors.Is(err, ErrRememberInvalid) {
+		t.Errorf("UseRememberToken of expired series expected it deleted, actual: %v", err)
+	}
+}
+
+func TestRememberHTTPCookie(t *testing.T) {
+	expiration := time.Now().Add(time.Hour).Truncate(time.Second)
+	c := RememberHTTPCookie("series.token", expiration, WithCookiePath("/api"))
+	if c.Name != RememberName || c.Value != "series.token" || !c.Expires.Equal(expiration) || c.Path != "/api" || !c.Secure || !c.HttpOnly {
+		t.Errorf("RememberHTTPCookie expected secure cookie %v, actual: %+v", RememberName, c)
+	}
+	if newOptions(nil).isSessionCookieName(RememberName) {
+		t.Errorf("isSessionCookieName of %v expected false, actual: true", RememberName)
+	}
+
+	w := httptest.NewRecorder()
+	ClearRememberHTTPCookie(w)
+	if cleared := w.Result().Cookies(); len(cleared) != 1 || cleared[0].Name != RememberName || cleared[0].MaxAge >= 0 {
+		t.Errorf("ClearRememberHTTPCookie expected expired %v, actual: %v", RememberName, cleared)
+	}
+}
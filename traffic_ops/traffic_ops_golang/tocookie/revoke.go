@@ -0,0 +1,92 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// RevocationStore records revoked sessions, so their cookies are rejected before they expire, e.g. on logout or when an account is compromised. Implementations must be safe for concurrent use. MemoryRevocationStore is a RevocationStore for a single server; stores shared by several servers, such as the Redis store of tocookie/redisrevocation, the PostgreSQL store of tocookie/sqlsession, and the Redis store of tocookie/redissession, which supports Sentinel and Cluster, live in subpackages.
+type RevocationStore interface {
+	// Revoke revokes the session with the given ID until the given time. The entry may be forgotten after then, so the time must be no earlier than the expiration of any cookie of the session, however it is refreshed, e.g. its SessionStart plus the duration of WithMaxLifetime.
+	Revoke(sessionID string, until time.Time) error
+	// IsRevoked returns whether the session with the given ID has been revoked.
+	IsRevoked(sessionID string) (bool, error)
+}
+
+// WithRevocationStore makes Parse, and so Middleware, reject cookies whose sessions have been revoked in the store with ErrRevoked. The store is only consulted after the signature and claims have been verified, so forged cookies can't be used to probe it. Errors of the store reject the cookie, so an unavailable store fails closed.
+//
+// Cookies without a SessionID, minted before it was introduced or by Perl Traffic Ops, can't be revoked, and are accepted until they are refreshed and given one.
+func WithRevocationStore(store RevocationStore) Option {
+	return func(o *options) { o.revocations = store }
+}
+
+// NewSessionID returns a random 128-bit session ID, hex-encoded, as New embeds in cookies.
+func NewSessionID() (string, error) {
+	id, err := NewJTI()
+	if err != nil {
+		return "", fmt.Errorf("generating session id: %w", err)
+	}
+	return id, nil
+}
+
+func checkRevoked(store RevocationStore, sessionID string) error {
+	if sessionID == "" {
+		return nil
+	}
+	revoked, err := store.IsRevoked(sessionID)
+	if err != nil {
+		return fmt.Errorf("checking session revocation: %w", err)
+	}
+	if revoked {
+		return ErrRevoked
+	}
+	return nil
+}
+
+// MemoryRevocationStore is a RevocationStore held in memory, for a single server. Revocations are lost when the process exits. It is safe for concurrent use.
+type MemoryRevocationStore struct {
+	mu      sync.RWMutex
+	revoked map[string]time.Time
+}
+
+// NewMemoryRevocationStore returns an empty MemoryRevocationStore.
+func NewMemoryRevocationStore() *MemoryRevocationStore {
+	return &MemoryRevocationStore{revoked: map[string]time.Time{}}
+}
+
+// Revoke revokes the session until the given time. Entries which have lapsed are pruned, so the store only holds sessions which could still have valid cookies.
+func (s *MemoryRevocationStore) Revoke(sessionID string, until time.Time) error {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, lapses := range s.revoked {
+		if now.After(lapses) {
+			delete(s.revoked, id)
+		}
+	}
+	if now.Before(until) {
+		s.revoked[sessionID] = until
+	}
+	return nil
+}
+
+// IsRevoked returns whether the session is revoked, and its revocation hasn't lapsed.
+func (s *MemoryRevocationStore) IsRevoked(sessionID string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	until, ok := s.revoked[sessionID]
+	return ok && !time.Now().After(until), nil
+}
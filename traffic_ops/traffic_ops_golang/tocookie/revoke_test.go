@@ -0,0 +1,84 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWithRevocationStore(t *testing.T) {
+	secret := "secret"
+	store := NewMemoryRevocationStore()
+	cookie := New("alice", time.Now().Add(time.Minute), secret)
+	c, err := Parse(secret, cookie, WithRevocationStore(store))
+	if err != nil {
+		t.Fatalf("Parse expected nil error, actual: %v", err)
+	}
+	if len(c.SessionID) != 32 {
+		t.Errorf("New expected 128-bit hex session ID, actual: '%v'", c.SessionID)
+	}
+	if other, _ := Parse(secret, New("alice", time.Now().Add(time.Minute), secret)); other.SessionID == c.SessionID {
+		t.Errorf("New expected a new session ID per session, actual: '%v' twice", c.SessionID)
+	}
+	refreshed := Refresh(c, secret)
+	if r, err := Parse(secret, refreshed); err != nil || r.SessionID != c.SessionID {
+		t.Errorf("Refresh expected session ID preserved, actual: %+v %v", r, err)
+	}
+
+	if err := store.Revoke(c.SessionID, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("Revoke expected nil error, actual: %v", err)
+	}
+	for name, cookie := range map[string]string{"original": cookie, "refreshed": refreshed} {
+		if _, err := Parse(secret, cookie, WithRevocationStore(store)); !errors.Is(err, ErrRevoked) {
+			t.Errorf("%v: Parse of revoked session expected ErrRevoked, actual: %v", name, err)
+		}
+	}
+	if _, err := Parse(secret, New("alice", time.Now().Add(time.Minute), secret), WithRevocationStore(store)); err != nil {
+		t.Errorf("Parse of other session expected nil error, actual: %v", err)
+	}
+
+	legacy := &Cookie{AuthData: "alice", ExpiresUnix: time.Now().Add(time.Minute).Unix()}
+	if _, err := Parse(secret, encodeCookie(legacy, secret, newOptions(nil)), WithRevocationStore(store)); err != nil {
+		t.Errorf("Parse of cookie without session ID expected nil error, actual: %v", err)
+	}
+	if r, err := Parse(secret, Refresh(legacy, secret)); err != nil || r.SessionID == "" {
+		t.Errorf("Refresh of cookie without session ID expected one, actual: %+v %v", r, err)
+	}
+
+	errStore := errors.New("store unavailable")
+	if _, err := Parse(secret, cookie, WithRevocationStore(failingRevocationStore{errStore})); !errors.Is(err, errStore) {
+		t.Errorf("Parse with failing store expected its error, actual: %v", err)
+	}
+}
+
+func TestMemoryRevocationStoreLapses(t *testing.T) {
+	store := NewMemoryRevocationStore()
+	store.Revoke("lapsed", time.Now().Add(-time.Second))
+	store.Revoke("revoked", time.Now().Add(time.Hour))
+	if revoked, _ := store.IsRevoked("lapsed"); revoked {
+		t.Errorf("IsRevoked of lapsed revocation expected false, actual true")
+	}
+	if revoked, _ := store.IsRevoked("revoked"); !revoked {
+		t.Errorf("IsRevoked of revocation expected true, actual false")
+	}
+	if len(store.revoked) != 1 {
+		t.Errorf("Revoke expected lapsed revocations pruned, actual: %v", store.revoked)
+	}
+}
+
+type failingRevocationStore struct{ err error }
+
+func (s failingRevocationStore) Revoke(string, time.Time) error { return s.err }
+func (s failingRevocationStore) IsRevoked(string) (bool, error) { return false, s.err }
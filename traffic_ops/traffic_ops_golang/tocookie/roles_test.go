@@ -0,0 +1,43 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRoles(t *testing.T) {
+	secret := "secret"
+	c, err := Parse(secret, New("alice", time.Now().Add(time.Minute), secret, WithRoles("operations", "read-only")))
+	if err != nil {
+		t.Fatalf("Parse expected nil error, actual: %v", err)
+	}
+	if !c.HasRole("operations") || !c.HasRole("read-only") || c.HasRole("admin") {
+		t.Errorf("HasRole expected operations and read-only only, actual: %v", c.Roles)
+	}
+	if !c.HasAnyRole("admin", "operations") || c.HasAnyRole("admin", "portal") || c.HasAnyRole() {
+		t.Errorf("HasAnyRole expected to match operations only, actual: %v", c.Roles)
+	}
+
+	refreshed, err := Parse(secret, Refresh(c, secret))
+	if err != nil || !refreshed.HasRole("operations") {
+		t.Errorf("Refresh expected roles preserved, actual: %+v %v", refreshed, err)
+	}
+
+	msg, err := newOptions(nil).marshal(&Cookie{AuthData: "alice"})
+	if err != nil || strings.Contains(string(msg), "roles") {
+		t.Errorf("marshal without roles expected no roles key, actual: %s %v", msg, err)
+	}
+}
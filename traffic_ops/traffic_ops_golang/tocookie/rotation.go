@@ -0,0 +1,122 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultRotationGrace is how long after a session is rotated its previous cookie is still accepted, by default; see WithRotationGrace.
+const DefaultRotationGrace = 10 * time.Second
+
+// RotationStore tracks the latest generation of sessions, for WithRotation. Implementations must be safe for concurrent use; MemoryRotationStore is one for a single server.
+type RotationStore interface {
+	// Rotate advances the session from the given generation to the next, if the given generation is the latest, and returns whether it did. It must be atomic, so only one of any concurrent rotations of a generation succeeds. Sessions the store hasn't seen are at generation 0. Cookies of the session are valid until the given time, after which the store may forget it.
+	Rotate(sessionID string, generation int64, until time.Time) (bool, error)
+	// Check returns whether a cookie of the session of the given generation may be used: if it is of the latest generation, or of the one before, rotated no longer than grace ago. Otherwise, the cookie has been reused after being refreshed, and the store revokes the session, so that no cookie of it may be used again, including the latest. Sessions the store hasn't seen may be used.
+	Check(sessionID string, generation int64, grace time.Duration) (bool, error)
+}
+
+// WithRotation makes Refresh rotate sessions, so each cookie can only be refreshed once, and Parse reject cookies which have already been refreshed: a stolen cookie can't be kept alive by refreshing it, and if either the thief or the user uses a cookie after the other refreshed it, the reuse is detected, and the whole session revoked with ErrSessionReused. Every server which mints and parses the cookies must share the store.
+//
+// Browsers send concurrent requests with the same cookie, so the previous cookie is accepted for a grace period after it was refreshed; see WithRotationGrace. Only one of concurrent refreshes succeeds, so Middleware refreshes cookies for only one of the requests. Cookies without a SessionID, and sessions the store has forgotten, can't be checked, and are accepted.
+func WithRotation(store RotationStore) Option {
+	return func(o *options) { o.rotation = store }
+}
+
+// WithRotationGrace sets how long after a session is rotated its previous cookie is still accepted, for WithRotation. The default is DefaultRotationGrace.
+func WithRotationGrace(grace time.Duration) Option {
+	return func(o *options) { o.rotationGrace = grace }
+}
+
+// checkGeneration returns an error if the cookie is of an older generation of its session than the store allows, which revokes the session.
+func (o *options) checkGeneration(c *Cookie) error {
+	if c.SessionID == "" {
+		return nil
+	}
+	ok, err := o.rotation.Check(c.SessionID, c.Generation, o.rotationGrace)
+	if err != nil {
+		return fmt.Errorf("checking session generation: %w", err)
+	}
+	if !ok {
+		o.logger.Warnf("tocookie: rejecting reused cookie of generation %d of session '%s' for user '%s'; the session is revoked", c.Generation, c.SessionID, c.AuthData)
+		return ErrSessionReused
+	}
+	return nil
+}
+
+// MemoryRotationStore is a RotationStore held in memory, for a single server. Sessions are lost when the process exits, after which their cookies are accepted, as those of unseen sessions are. It is safe for concurrent use.
+type MemoryRotationStore struct {
+	mu       sync.Mutex
+	sessions map[string]*rotation
+}
+
+// rotation is the state of a session in a MemoryRotationStore.
+type rotation struct {
+	generation int64
+	rotated    time.Time
+	until      time.Time
+	revoked    bool
+}
+
+// NewMemoryRotationStore returns an empty MemoryRotationStore.
+func NewMemoryRotationStore() *MemoryRotationStore {
+	return &MemoryRotationStore{sessions: map[string]*rotation{}}
+}
+
+// Rotate advances the session, if the generation is its latest, and it hasn't been revoked. Sessions whose cookies have all expired are pruned, so the store only holds sessions which could still have valid cookies.
+func (s *MemoryRotationStore) Rotate(sessionID string, generation int64, until time.Time) (bool, error) {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, r := range s.sessions {
+		if now.After(r.until) {
+			delete(s.sessions, id)
+		}
+	}
+	r, ok := s.sessions[sessionID]
+	if !ok {
+		r = &rotation{}
+		s.sessions[sessionID] = r
+	}
+	if r.revoked || r.generation != generation {
+		return false, nil
+	}
+	r.generation++
+	r.rotated = now
+	if until.After(r.until) {
+		r.until = until
+	}
+	return true, nil
+}
+
+// Check returns whether a cookie of the generation may be used, and revokes the session if it may not.
+func (s *MemoryRotationStore) Check(sessionID string, generation int64, grace time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.sessions[sessionID]
+	switch {
+	case !ok:
+		return true, nil
+	case r.revoked:
+		return false, nil
+	case generation >= r.generation:
+		return true, nil
+	case generation == r.generation-1 && time.Since(r.rotated) <= grace:
+		return true, nil
+	}
+	r.revoked = true
+	return false, nil
+}
@@ -0,0 +1,109 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWithRotation(t *testing.T) {
+	secret := "secret"
+	store := NewMemoryRotationStore()
+	opts := []Option{WithRotation(store), WithRotationGrace(0)}
+	cookie := New("alice", time.Now().Add(time.Hour), secret, opts...)
+	c, err := Parse(secret, cookie, opts...)
+	if err != nil || c.Generation != 0 {
+		t.Fatalf("Parse of new session expected generation 0, actual: %+v %v", c, err)
+	}
+
+	refreshed := Refresh(c, secret, opts...)
+	r, err := Parse(secret, refreshed, opts...)
+	if err != nil || r.Generation != 1 || r.SessionID != c.SessionID {
+		t.Fatalf("Parse of refreshed cookie expected generation 1 of the session, actual: %+v %v", r, err)
+	}
+	if again := Refresh(c, secret, opts...); again != "" {
+		t.Errorf("Refresh of already refreshed cookie expected empty string, actual: '%v'", again)
+	}
+
+	if _, err := Parse(secret, cookie, opts...); !errors.Is(err, ErrSessionReused) {
+		t.Errorf("Parse of reused cookie expected ErrSessionReused, actual: %v", err)
+	}
+	if _, err := Parse(secret, refreshed, opts...); !errors.Is(err, ErrSessionReused) {
+		t.Errorf("Parse of latest cookie of revoked session expected ErrSessionReused, actual: %v", err)
+	}
+	if again := Refresh(r, secret, opts...); again != "" {
+		t.Errorf("Refresh of revoked session expected empty string, actual: '%v'", again)
+	}
+
+	other := New("bob", time.Now().Add(time.Hour), secret, opts...)
+	if _, err := Parse(secret, other, opts...); err != nil {
+		t.Errorf("Parse of other session expected nil error, actual: %v", err)
+	}
+	legacy := &Cookie{AuthData: "carol", ExpiresUnix: time.Now().Add(time.Hour).Unix()}
+	if _, err := Parse(secret, encodeCookie(legacy, secret, newOptions(nil)), opts...); err != nil {
+		t.Errorf("Parse of cookie without session ID expected nil error, actual: %v", err)
+	}
+}
+
+func TestWithRotationGrace(t *testing.T) {
+	secret := "secret"
+	store := NewMemoryRotationStore()
+	opts := []Option{WithRotation(store)}
+	cookie := New("alice", time.Now().Add(time.Hour), secret, opts...)
+	c, _ := Parse(secret, cookie, opts...)
+
+	// concurrent requests with the same cookie: only one refreshes it, and the others are still accepted
+	refreshes := int32(0)
+	wg := sync.WaitGroup{}
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if Refresh(c, secret, opts...) != "" {
+				atomic.AddInt32(&refreshes, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	if refreshes != 1 {
+		t.Errorf("concurrent Refresh expected exactly one refresh, actual: %v", refreshes)
+	}
+	if _, err := Parse(secret, cookie, opts...); err != nil {
+		t.Errorf("Parse of previous cookie within grace expected nil error, actual: %v", err)
+	}
+
+	store.sessions[c.SessionID].rotated = time.Now().Add(-2 * DefaultRotationGrace)
+	if _, err := Parse(secret, cookie, opts...); !errors.Is(err, ErrSessionReused) {
+		t.Errorf("Parse of previous cookie after grace expected ErrSessionReused, actual: %v", err)
+	}
+}
+
+func TestMemoryRotationStorePrunes(t *testing.T) {
+	store := NewMemoryRotationStore()
+	if ok, err := store.Rotate("lapsed", 0, time.Now().Add(-time.Second)); !ok || err != nil {
+		t.Fatalf("Rotate of new session expected true, actual: %v %v", ok, err)
+	}
+	if ok, _ := store.Rotate("current", 0, time.Now().Add(time.Hour)); !ok {
+		t.Fatalf("Rotate of new session expected true, actual: false")
+	}
+	if _, ok := store.sessions["lapsed"]; ok {
+		t.Errorf("Rotate expected lapsed session pruned, actual: kept")
+	}
+	if ok, _ := store.Rotate("current", 0, time.Now().Add(time.Hour)); ok {
+		t.Errorf("Rotate of old generation expected false, actual: true")
+	}
+}
@@ -0,0 +1,108 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// MinSecretLength is the shortest secret ValidateSecret accepts, in bytes.
+const MinSecretLength = 16
+
+// MinSecretEntropy is the least estimated entropy of a secret ValidateSecret accepts, in bits; see ValidateSecret.
+const MinSecretEntropy = 48
+
+// minSecretEntropyPerByte is the least estimated entropy of each byte of a secret ValidateSecret accepts, in bits, so a long secret of few distinct bytes, such as a repeated word, isn't accepted for its length. Random hex has about 4 bits a byte, and random base64 about 5.
+const minSecretEntropyPerByte = 3
+
+// secretAdvice ends the errors of ValidateSecret, saying how to replace a weak secret.
+const secretAdvice = "; generate one with e.g. `openssl rand -base64 32`, and set it as the first of the secrets of cdn.conf"
+
+// defaultSecrets are the secrets of the example cdn.conf files, CDN in a Box, and documentation, and other secrets commonly left unchanged, which ValidateSecret rejects whatever their length. They are compared case-insensitively.
+var defaultSecrets = []string{
+	"mONKEYDOmONKEYSEE.",
+	"mONKEYDOmONKEYSEE",
+	"secret",
+	"secrets",
+	"changeme",
+	"change me",
+	"changethis",
+	"password",
+	"trafficops",
+	"traffic ops",
+	"traffic_ops",
+	"trafficcontrol",
+	"traffic control",
+	"traffic_control",
+}
+
+// ValidateSecret returns an error wrapping ErrWeakSecret if the secret is unfit for signing cookies: empty, shorter than MinSecretLength bytes, one of the well-known defaults of example cdn.conf files, or of less than MinSecretEntropy bits of estimated entropy, such as a repeated character or word. Services should call it at startup, for each of their secrets, as a cookie signed with a weak secret can be forged by anyone who guesses it, which compromises every session of the CDN.
+//
+// The entropy is estimated by the Shannon entropy of the secret's byte frequencies, times its length, which is at most 8 bits a byte; the secret must also have at least 3 bits a byte, so repeating a short pattern doesn't make up for its few distinct bytes. It is a lower bar than a random secret meets; passing it doesn't make a chosen secret strong. Secrets should be 32 random bytes, base64-encoded.
+func ValidateSecret(secret string) error {
+	switch {
+	case secret == "":
+		return fmt.Errorf("%w: secret is empty%s", ErrWeakSecret, secretAdvice)
+	case isDefaultSecret(secret):
+		return fmt.Errorf("%w: secret is a well-known default%s", ErrWeakSecret, secretAdvice)
+	case len(secret) < MinSecretLength:
+		return fmt.Errorf("%w: secret is %d bytes, shorter than the minimum of %d%s", ErrWeakSecret, len(secret), MinSecretLength, secretAdvice)
+	}
+	perByte := secretEntropy(secret)
+	if bits := perByte * float64(len(secret)); bits < MinSecretEntropy || perByte < minSecretEntropyPerByte {
+		return fmt.Errorf("%w: secret has an estimated %.0f bits of entropy, %.1f a byte, less than the minimum of %d, %d a byte%s", ErrWeakSecret, bits, perByte, MinSecretEntropy, minSecretEntropyPerByte, secretAdvice)
+	}
+	return nil
+}
+
+// WithSecretValidation makes New, Refresh, and Parse refuse to use secrets which ValidateSecret rejects: New and Refresh return an empty string, and Parse returns the error of ValidateSecret, which wraps ErrWeakSecret, for every cookie. It applies to every secret cookies are signed or verified with, including those of key rings and issuers, but not to WithSigner, ParseWithPublicKey, or WithMACer, which sign without a secret. SelfTest returns the error of ValidateSecret before minting a cookie, so services fail at startup rather than at the first login.
+func WithSecretValidation() Option {
+	return func(o *options) { o.secretValidation = true }
+}
+
+// checkSecret returns the error of ValidateSecret for the secret, WithSecretValidation, and nil if cookies aren't signed with a secret.
+func (o *options) checkSecret(secret string) error {
+	if !o.secretValidation || o.signer != nil || o.publicKey != nil || o.macer != nil {
+		return nil
+	}
+	return ValidateSecret(secret)
+}
+
+// isDefaultSecret returns whether the secret is one of defaultSecrets.
+func isDefaultSecret(secret string) bool {
+	for _, s := range defaultSecrets {
+		if strings.EqualFold(secret, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// secretEntropy returns the estimated entropy of each byte of the secret, in bits: the Shannon entropy of its byte frequencies.
+func secretEntropy(secret string) float64 {
+	var counts [256]int
+	for i := 0; i < len(secret); i++ {
+		counts[secret[i]]++
+	}
+	n := float64(len(secret))
+	perByte := 0.0
+	for _, count := range counts {
+		if count > 0 {
+			p := float64(count) / n
+			perByte -= p * math.Log2(p)
+		}
+	}
+	return perByte
+}
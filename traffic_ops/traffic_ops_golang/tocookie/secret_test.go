@@ -0,0 +1,83 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateSecret(t *testing.T) {
+	tests := map[string]struct {
+		secret string
+		valid  bool
+	}{
+		"random":              {"q3Zk8vR2nX0bLw5tYp7hJm4sGd1fCa9e", true},
+		"base64":              {"7NwKQ1R0yB3o6m4m1nS0iH8dJ2xXl6VtqY1kRt4u3ZQ=", true},
+		"hex":                 {"9f86d081884c7d659a2feaa0c55ad015", true},
+		"empty":               {"", false},
+		"short":               {"Xk9#pQ2!", false},
+		"cdn.conf default":    {"mONKEYDOmONKEYSEE.", false},
+		"default other case":  {"MonkeyDoMonkeySee.", false},
+		"changeme":            {"changeme", false},
+		"repeated character":  {strings.Repeat("a", 64), false},
+		"repeated characters": {strings.Repeat("ab", 32), false},
+	}
+	for name, test := range tests {
+		err := ValidateSecret(test.secret)
+		if test.valid && err != nil {
+			t.Errorf("%v: ValidateSecret expected nil error, actual: %v", name, err)
+		} else if !test.valid && !errors.Is(err, ErrWeakSecret) {
+			t.Errorf("%v: ValidateSecret expected ErrWeakSecret, actual: %v", name, err)
+		}
+	}
+	if err := ValidateSecret("secret"); err == nil || !strings.Contains(err.Error(), "openssl rand") {
+		t.Errorf("ValidateSecret expected error saying how to generate a secret, actual: %v", err)
+	}
+}
+
+func TestWithSecretValidation(t *testing.T) {
+	strong := "q3Zk8vR2nX0bLw5tYp7hJm4sGd1fCa9e"
+	weak := "mONKEYDOmONKEYSEE."
+	expiration := time.Now().Add(time.Hour)
+
+	if New("alice", expiration, weak, WithSecretValidation()) != "" {
+		t.Error("New WithSecretValidation of weak secret expected empty string")
+	}
+	if Refresh(&Cookie{AuthData: "alice"}, weak, WithSecretValidation()) != "" {
+		t.Error("Refresh WithSecretValidation of weak secret expected empty string")
+	}
+	cookie := New("alice", expiration, weak)
+	if _, err := Parse(weak, cookie, WithSecretValidation()); !errors.Is(err, ErrWeakSecret) {
+		t.Errorf("Parse WithSecretValidation of weak secret expected ErrWeakSecret, actual: %v", err)
+	}
+	if _, err := NewParser(weak, WithSecretValidation()).Parse(cookie); !errors.Is(err, ErrWeakSecret) {
+		t.Errorf("Parser WithSecretValidation of weak secret expected ErrWeakSecret, actual: %v", err)
+	}
+	if err := SelfTest(weak, WithSecretValidation()); !errors.Is(err, ErrWeakSecret) {
+		t.Errorf("SelfTest WithSecretValidation of weak secret expected ErrWeakSecret, actual: %v", err)
+	}
+
+	cookie = New("alice", expiration, strong, WithSecretValidation())
+	if _, err := Parse(strong, cookie, WithSecretValidation()); err != nil {
+		t.Errorf("Parse WithSecretValidation of strong secret expected nil error, actual: %v", err)
+	}
+	if _, err := NewParser(strong, WithSecretValidation()).Parse(cookie); err != nil {
+		t.Errorf("Parser WithSecretValidation of strong secret expected nil error, actual: %v", err)
+	}
+	if err := SelfTest(strong, WithSecretValidation()); err != nil {
+		t.Errorf("SelfTest WithSecretValidation of strong secret expected nil error, actual: %v", err)
+	}
+}
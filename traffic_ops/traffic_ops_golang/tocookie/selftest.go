@@ -0,0 +1,67 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// selfTestUser is the user of the cookie minted by SelfTest.
+const selfTestUser = "tocookie-self-test"
+
+// SelfTest mints a cookie with the secret and options, parses it back, and checks that its claims survived the round trip and that a tampered copy is rejected. It exercises the configured version, hash, compression, and field names, and returns an error describing the first failure. Services should call it at startup, to fail fast on a broken secret or crypto configuration.
+//
+// Given WithSecretValidation, a secret which ValidateSecret rejects is reported as such, before any cookie is minted.
+//
+// The nonce store and not-before time, if configured, are ignored, so the self-test neither consumes a nonce nor fails for a cookie not yet valid.
+func SelfTest(secret string, opts ...Option) error {
+	if secret == "" {
+		return errors.New("self-test: secret is empty")
+	}
+	if err := newOptions(opts).checkSecret(secret); err != nil {
+		return fmt.Errorf("self-test: %w", err)
+	}
+	opts = append(append(make([]Option, 0, len(opts)+1), opts...), func(o *options) {
+		o.nonces = nil
+		o.notBefore = 0
+	})
+
+	expiration := time.Now().Add(DefaultDuration)
+	cookie := New(selfTestUser, expiration, secret, opts...)
+	if cookie == "" {
+		return errors.New("self-test: unable to mint a cookie with the given options")
+	}
+	expected := &Cookie{By: GeneratedByStr, AuthData: selfTestUser}
+	newOptions(opts).setClaims(expected)
+
+	actual, err := Parse(secret, cookie, opts...)
+	if err != nil {
+		return fmt.Errorf("self-test: parsing minted cookie: %w", err)
+	}
+	if !actual.Equal(expected) || actual.ExpiresUnix != expiration.Unix() {
+		return fmt.Errorf("self-test: parsed claims %+v don't match minted claims %+v", actual, expected)
+	}
+
+	tampered := []byte(cookie)
+	if tampered[len(tampered)-1] == '0' {
+		tampered[len(tampered)-1] = '1'
+	} else {
+		tampered[len(tampered)-1] = '0'
+	}
+	if _, err := Parse(secret, string(tampered), opts...); err == nil {
+		return errors.New("self-test: cookie with tampered signature was accepted")
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"crypto"
+	"testing"
+)
+
+func TestSelfTest(t *testing.T) {
+	valid := map[string][]Option{
+		"default":     nil,
+		"v2 gzip":     {WithCompression(CodecGzip)},
+		"sha256":      {WithHash(crypto.SHA256), WithVersion(Version1)},
+		"claims":      {WithAudience("cdn"), WithIssuer("org"), WithFingerprint(Fingerprint("ua"))},
+		"field names": {WithFieldNames(map[string]string{FieldAuthData: "u"})},
+		"nonce store": {WithJTI("abc"), WithNonceStore(mapNonceStore{})},
+	}
+	for name, opts := range valid {
+		if err := SelfTest("secret", opts...); err != nil {
+			t.Errorf("%v: SelfTest expected nil error, actual: %v", name, err)
+		}
+	}
+
+	invalid := map[string]struct {
+		secret string
+		opts   []Option
+	}{
+		"empty secret":      {"", nil},
+		"unknown version":   {"secret", []Option{WithVersion(maxVersion + 1)}},
+		"unavailable hash":  {"secret", []Option{WithHash(crypto.MD4)}},
+		"unknown codec":     {"secret", []Option{WithCompression("nope")}},
+		"conflicting names": {"secret", []Option{WithFieldNames(map[string]string{FieldAuthData: "by"})}},
+	}
+	for name, test := range invalid {
+		if err := SelfTest(test.secret, test.opts...); err == nil {
+			t.Errorf("%v: SelfTest expected error, actual nil", name)
+		}
+	}
+}
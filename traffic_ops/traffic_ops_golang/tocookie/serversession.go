@@ -0,0 +1,207 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// serverSessionIDLen is the length of the hex-encoded 256-bit IDs of server-side sessions.
+const serverSessionIDLen = 64
+
+// SessionStore stores the claims of server-side sessions, for deployments which can't accept self-contained cookies: the cookie carries only a random ID, and everything else stays on the server; see NewServerSession. Implementations must be safe for concurrent use. MemorySessionStore is a SessionStore for a single server; stores shared by several servers, such as the PostgreSQL store of tocookie/sqlsession and the Redis store of tocookie/redissession, live in subpackages.
+type SessionStore interface {
+	// Save stores the claims of the session with the ID, replacing any it had. The store may forget them once they expire.
+	Save(id string, c *Cookie) error
+	// Load returns the claims of the session with the ID, or an error wrapping ErrSessionNotFound if the store has none. Claims which have expired may be returned, as Parse rejects them.
+	Load(id string) (*Cookie, error)
+	// Delete removes the session with the ID. Deleting a session the store doesn't have isn't an error.
+	Delete(id string) error
+}
+
+// NewServerSession starts a server-side session of the user in the store, and returns its cookie. The claims New would put in a cookie, including those of options such as WithRoles, are saved in the store under a random 256-bit ID, and the cookie is only that ID, signed with the key, so the claims are never sent to the client. The format options of New, such as WithVersion, apply to the cookie.
+func NewServerSession(store SessionStore, user string, expiration time.Time, key string, opts ...Option) (string, error) {
+	o := newOptions(opts)
+	c, err := o.newSession(user, expiration)
+	if err != nil {
+		return "", err
+	}
+	id, err := newServerSessionID()
+	if err != nil {
+		return "", err
+	}
+	cookie := encodeSigned([]byte(id), []byte(key), o)
+	if cookie == "" {
+		return "", errors.New("unable to sign session cookie with the given options")
+	}
+	if err := store.Save(id, c); err != nil {
+		return "", fmt.Errorf("saving session: %w", err)
+	}
+	o.issued(c)
+	return cookie, nil
+}
+
+// ParseServerSession verifies the signature of a cookie minted by NewServerSession, loads the claims of its session from the store, and validates them, as Parse validates the claims of a cookie, so the options of Parse, such as WithRevocationStore and WithAudience, apply. Forged cookies are rejected before the store is consulted. Sessions the store doesn't have are rejected with an error wrapping ErrSessionNotFound.
+func ParseServerSession(store SessionStore, key, cookie string, opts ...Option) (*Cookie, error) {
+	return ParseServerSessionContext(context.Background(), store, key, cookie, opts...)
+}
+
+// ParseServerSessionContext is ParseServerSession, running the hooks of WithHooks with the context, as ParseContext does.
+func ParseServerSessionContext(ctx context.Context, store SessionStore, key, cookie string, opts ...Option) (*Cookie, error) {
+	o := newOptions(opts)
+	start := o.startTimer()
+	ctx, span := o.startSpan(ctx, SpanParse)
+	_, c, err := parseServerSession(ctx, store, key, cookie, o)
+	o.endParseSpan(span, c, err)
+	o.observe(start, c, err)
+	return c, err
+}
+
+func parseServerSession(ctx context.Context, store SessionStore, key, cookie string, o *options) (string, *Cookie, error) {
+	if err := ctx.Err(); err != nil {
+		return "", nil, err
+	}
+	id, err := parseServerSessionID(key, cookie, o)
+	if err != nil {
+		return "", nil, err
+	}
+	c, err := store.Load(id)
+	if err != nil {
+		return "", nil, fmt.Errorf("loading session: %w", err)
+	}
+	if c, err = checkVerified(c, o); err != nil {
+		return "", c, err
+	}
+	if err := o.runHooks(ctx, c); err != nil {
+		return "", nil, err
+	}
+	return id, c, nil
+}
+
+// parseServerSessionID verifies the signature of a cookie minted by NewServerSession, and returns the ID of its session.
+func parseServerSessionID(key, cookie string, o *options) (string, error) {
+	if err := o.precheck(cookie); err != nil {
+		return "", err
+	}
+	txtBytes, err := decodeSigned(cookie, []byte(key), o)
+	if err != nil {
+		return "", err
+	}
+	id := string(txtBytes)
+	if _, err := hex.DecodeString(id); err != nil || len(id) != serverSessionIDLen {
+		return "", fmt.Errorf("%w: not a session cookie", ErrMalformed)
+	}
+	return id, nil
+}
+
+// RefreshServerSession extends the server-side session of a cookie minted by NewServerSession, as Refresh extends a cookie, and returns its refreshed claims. The session is parsed as by ParseServerSession, and its expiration in the store set to DefaultDuration or the duration given by WithIdleTimeout from now, capped by WithMaxLifetime. The cookie itself doesn't change, so it needn't be set on the response again.
+func RefreshServerSession(store SessionStore, key, cookie string, opts ...Option) (*Cookie, error) {
+	o := newOptions(opts)
+	id, c, err := parseServerSession(context.Background(), store, key, cookie, o)
+	if err != nil {
+		return nil, err
+	}
+	now := o.now()
+	refreshed := c.Clone()
+	refreshed.IssuedAt = now.Unix()
+	o.setExpiration(refreshed, capImpersonation(refreshed, o.capLifetime(refreshed, now.Add(o.duration()))))
+	if err := store.Save(id, refreshed); err != nil {
+		return nil, fmt.Errorf("saving session: %w", err)
+	}
+	o.refreshed(refreshed)
+	return refreshed, nil
+}
+
+// DeleteServerSession deletes the server-side session of a cookie minted by NewServerSession from the store, e.g. on logout, so the cookie can't be used again. Forged cookies are rejected, and expired sessions are deleted without error.
+func DeleteServerSession(store SessionStore, key, cookie string, opts ...Option) error {
+	id, err := parseServerSessionID(key, cookie, newOptions(opts))
+	if err != nil {
+		return err
+	}
+	if err := store.Delete(id); err != nil {
+		return fmt.Errorf("deleting session: %w", err)
+	}
+	return nil
+}
+
+// newServerSessionID returns a random 256-bit server-side session ID, hex-encoded.
+func newServerSessionID() (string, error) {
+	b := make([]byte, serverSessionIDLen/2)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generating session id: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// MemorySessionStore is a SessionStore held in memory, for a single server. Sessions are lost when the process exits. It is safe for concurrent use.
+type MemorySessionStore struct {
+	mu       sync.RWMutex
+	sessions map[string]*Cookie
+}
+
+// NewMemorySessionStore returns an empty MemorySessionStore.
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{sessions: map[string]*Cookie{}}
+}
+
+// Save stores a copy of the claims of the session. Sessions which have expired are pruned, so the store only holds sessions which could still be used.
+func (s *MemorySessionStore) Save(id string, c *Cookie) error {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, session := range s.sessions {
+		if now.After(session.Expires()) {
+			delete(s.sessions, id)
+		}
+	}
+	s.sessions[id] = c.Clone()
+	return nil
+}
+
+// Load returns a copy of the claims of the session.
+func (s *MemorySessionStore) Load(id string) (*Cookie, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	c, ok := s.sessions[id]
+	if !ok {
+		return nil, ErrSessionNotFound
+	}
+	return c.Clone(), nil
+}
+
+// Delete removes the session.
+func (s *MemorySessionStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, id)
+	return nil
+}
+
+// Sessions returns copies of the claims of the sessions of the user, which makes MemorySessionStore a SessionLister. It scans every session, which is fine for the sessions of a single server.
+func (s *MemorySessionStore) Sessions(user string) (map[string]*Cookie, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	sessions := map[string]*Cookie{}
+	for id, c := range s.sessions {
+		if c.AuthData == user {
+			sessions[id] = c.Clone()
+		}
+	}
+	return sessions, nil
+}
@@ -0,0 +1,100 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServerSession(t *testing.T) {
+	secret := "secret"
+	store := NewMemorySessionStore()
+	now := time.Now()
+	cookie, err := NewServerSession(store, "alice", now.Add(time.Minute), secret, WithRoles("admin"), WithAudience("traffic_ops"))
+	if err != nil {
+		t.Fatalf("NewServerSession expected nil error, actual: %v", err)
+	}
+	if strings.Contains(cookie, "alice") {
+		t.Errorf("NewServerSession expected cookie without claims, actual: %v", cookie)
+	}
+	if info, _ := Dump(cookie); len(info.Decoded) != serverSessionIDLen {
+		t.Errorf("NewServerSession expected cookie of a %v character ID, actual: %v", serverSessionIDLen, info.Decoded)
+	}
+	if _, err := Parse(secret, cookie); err == nil {
+		t.Errorf("Parse of server session cookie expected error, actual nil")
+	}
+
+	c, err := ParseServerSession(store, secret, cookie, WithAudience("traffic_ops"))
+	if err != nil {
+		t.Fatalf("ParseServerSession expected nil error, actual: %v", err)
+	}
+	if c.AuthData != "alice" || !c.HasRole("admin") || c.SessionID == "" {
+		t.Errorf("ParseServerSession expected alice with role admin and a session ID, actual: %+v", c)
+	}
+	if _, err := ParseServerSession(store, secret, cookie, WithAudience("other")); !errors.Is(err, ErrAudienceMismatch) {
+		t.Errorf("ParseServerSession of other audience expected ErrAudienceMismatch, actual: %v", err)
+	}
+	if _, err := ParseServerSession(store, "wrong", cookie); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("ParseServerSession with wrong key expected ErrBadSignature, actual: %v", err)
+	}
+	if _, err := ParseServerSession(store, secret, New("alice", now.Add(time.Minute), secret)); !errors.Is(err, ErrMalformed) {
+		t.Errorf("ParseServerSession of self-contained cookie expected ErrMalformed, actual: %v", err)
+	}
+
+	later := now.Add(50 * time.Second)
+	clock := WithClock(func() time.Time { return later })
+	refreshed, err := RefreshServerSession(store, secret, cookie, clock, WithIdleTimeout(time.Hour))
+	if err != nil {
+		t.Fatalf("RefreshServerSession expected nil error, actual: %v", err)
+	}
+	if expected := later.Add(time.Hour).Unix(); refreshed.ExpiresUnix != expected {
+		t.Errorf("RefreshServerSession expected expiration %v, actual: %v", expected, refreshed.ExpiresUnix)
+	}
+	if c, err := ParseServerSession(store, secret, cookie, WithClock(func() time.Time { return now.Add(30 * time.Minute) })); err != nil || !c.Equal(refreshed) {
+		t.Errorf("ParseServerSession after refresh expected the session to be extended, actual: %v", err)
+	}
+
+	if err := DeleteServerSession(store, "wrong", cookie); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("DeleteServerSession with wrong key expected ErrBadSignature, actual: %v", err)
+	}
+	if err := DeleteServerSession(store, secret, cookie); err != nil {
+		t.Fatalf("DeleteServerSession expected nil error, actual: %v", err)
+	}
+	if _, err := ParseServerSession(store, secret, cookie); !errors.Is(err, ErrSessionNotFound) || FailureReason(err) != "session_not_found" {
+		t.Errorf("ParseServerSession after delete expected ErrSessionNotFound, actual: %v", err)
+	}
+}
+
+func TestServerSessionExpired(t *testing.T) {
+	secret := "secret"
+	store := NewMemorySessionStore()
+	cookie, err := NewServerSession(store, "alice", time.Now().Add(-time.Minute), secret)
+	if err != nil {
+		t.Fatalf("NewServerSession expected nil error, actual: %v", err)
+	}
+	if _, err := ParseServerSession(store, secret, cookie); !errors.Is(err, ErrExpired) {
+		t.Errorf("ParseServerSession of expired session expected ErrExpired, actual: %v", err)
+	}
+	if _, err := RefreshServerSession(store, secret, cookie); !errors.Is(err, ErrExpired) {
+		t.Errorf("RefreshServerSession of expired session expected ErrExpired, actual: %v", err)
+	}
+	if _, err := NewServerSession(store, "bob", time.Now().Add(time.Minute), secret); err != nil {
+		t.Fatalf("NewServerSession expected nil error, actual: %v", err)
+	}
+	if len(store.sessions) != 1 {
+		t.Errorf("MemorySessionStore expected expired session pruned, actual: %v sessions", len(store.sessions))
+	}
+}
@@ -0,0 +1,286 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"context"
+	"crypto"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ServiceTokenPrefix is the prefix of service tokens, so they can be told apart from cookies, API tokens, and action tokens. The version is bumped if the format changes.
+const ServiceTokenPrefix = "tosvc_v1_"
+
+// DefaultServiceTokenDuration is how long service tokens last, if NewServiceToken isn't given a duration: long enough to outlast clock skew and retries, and short enough that a token captured from a request is soon useless.
+const DefaultServiceTokenDuration = 5 * time.Minute
+
+// MaxServiceTokenDuration is the longest service tokens may last. NewServiceToken refuses to mint, and ParseServiceToken rejects, tokens which last longer, so a leaked private key can't mint tokens which outlive its replacement by long.
+const MaxServiceTokenDuration = time.Hour
+
+// serviceTokenMaxSize is the maximum length of a service token, which is checked before it is decoded. It allows for the signatures of 4096-bit RSA keys.
+const serviceTokenMaxSize = 2048
+
+// ServiceToken is the claims of a token minted by NewServiceToken, with which a component such as Traffic Ops, Traffic Monitor, or Traffic Router authenticates its calls to another. Service tokens are signed with the private key of their issuer, and verified with its public key, so components needn't share the secret of user cookies, and a component which can verify tokens can't mint them in another's name. Each token is scoped to the one service it was minted for.
+type ServiceToken struct {
+	// ID identifies the token, e.g. in logs. It is random hex, like a SessionID.
+	ID string `json:"id"`
+	// Issuer is the service which minted the token, whose public key verifies it.
+	Issuer string `json:"iss"`
+	// Audience is the service the token was minted for.
+	Audience string `json:"aud"`
+	// Alg is the algorithm of the signature, AlgEdDSA or AlgRS256.
+	Alg string `json:"alg"`
+	// KeyID identifies the issuer's key which signed the token, if it was given WithKeyID or WithSigningKeys.
+	KeyID string `json:"kid,omitempty"`
+	// IssuedAt is when the token was minted, in seconds since the Unix epoch.
+	IssuedAt int64 `json:"iat"`
+	// ExpiresUnix is when the token expires, in seconds since the Unix epoch.
+	ExpiresUnix int64 `json:"exp"`
+}
+
+// Expires returns the expiration time of the token.
+func (t *ServiceToken) Expires() time.Time {
+	return time.Unix(t.ExpiresUnix, 0)
+}
+
+// IsServiceToken returns whether the string has the prefix of a service token. It doesn't verify the token.
+func IsServiceToken(s string) bool {
+	return strings.HasPrefix(s, ServiceTokenPrefix)
+}
+
+// ServiceKeys are the public keys of the services whose tokens are accepted, for ParseServiceToken.
+type ServiceKeys interface {
+	// ServicePublicKey returns the public key of the issuer with the key ID, which is empty for tokens minted without one, and whether there is one.
+	ServicePublicKey(issuer, keyID string) (crypto.PublicKey, bool)
+}
+
+// ServicePublicKeys is ServiceKeys of one public key per issuer, e.g. loaded with LoadPublicKeyFile. Key IDs are ignored.
+type ServicePublicKeys map[string]crypto.PublicKey
+
+// ServicePublicKey implements ServiceKeys.
+func (k ServicePublicKeys) ServicePublicKey(issuer, keyID string) (crypto.PublicKey, bool) {
+	key, ok := k[issuer]
+	return key, ok
+}
+
+// ServiceSigningKeys is ServiceKeys of the SigningKeys of each issuer, for services which rotate their keys. Tokens are verified with the key of their key ID, so tokens without one are rejected.
+type ServiceSigningKeys map[string]*SigningKeys
+
+// ServicePublicKey implements ServiceKeys.
+func (k ServiceSigningKeys) ServicePublicKey(issuer, keyID string) (crypto.PublicKey, bool) {
+	keys, ok := k[issuer]
+	if !ok || keyID == "" {
+		return nil, false
+	}
+	return keys.PublicKey(keyID)
+}
+
+// NewServiceToken mints a service token of the issuer for the audience, lasting the duration, or DefaultServiceTokenDuration if it isn't positive, signed with the private key, an ed25519.PrivateKey or *rsa.PrivateKey, as WithSigner takes. If the key is nil, the key of WithSigner or WithSigningKeys is used. The key ID of WithKeyID or WithSigningKeys is embedded in the token; WithClock applies, and other options are ignored.
+//
+// The token is ServiceTokenPrefix, followed by its claims as JSON, and the signature, both base64url-encoded and separated by a period. Durations longer than MaxServiceTokenDuration are refused.
+func NewServiceToken(issuer, audience string, duration time.Duration, key crypto.Signer, opts ...Option) (string, *ServiceToken, error) {
+	if issuer == "" || audience == "" {
+		return "", nil, errors.New("service token needs an issuer and audience")
+	}
+	if duration <= 0 {
+		duration = DefaultServiceTokenDuration
+	}
+	if duration > MaxServiceTokenDuration {
+		return "", nil, fmt.Errorf("service token duration %v longer than %v", duration, MaxServiceTokenDuration)
+	}
+	o := newOptions(opts)
+	if key != nil {
+		o.signer = key
+	}
+	if o.signer == nil {
+		return "", nil, errors.New("service token needs a private key")
+	}
+	id, err := NewJTI()
+	if err != nil {
+		return "", nil, fmt.Errorf("generating service token id: %w", err)
+	}
+	now := o.now()
+	t := &ServiceToken{
+		ID:          id,
+		Issuer:      issuer,
+		Audience:    audience,
+		Alg:         signerAlg(o.signer),
+		KeyID:       o.keyID,
+		IssuedAt:    now.Unix(),
+		ExpiresUnix: now.Add(duration).Unix(),
+	}
+	claims, err := json.Marshal(t)
+	if err != nil {
+		return "", nil, fmt.Errorf("marshalling service token: %w", err)
+	}
+	signed := ServiceTokenPrefix + base64.RawURLEncoding.EncodeToString(claims)
+	sig, err := o.signAsymmetric([]byte(signed))
+	if err != nil {
+		return "", nil, fmt.Errorf("signing service token: %w", err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig), t, nil
+}
+
+// ParseServiceToken verifies a service token minted by NewServiceToken for the audience, i.e. the service calling this, with the public key of its issuer of the keys, and returns its claims. Tokens without ServiceTokenPrefix, including cookies, are rejected with ErrMalformed, as are tokens lasting longer than MaxServiceTokenDuration. Tokens of issuers, or key IDs, without a key are rejected with ErrUnknownIssuer, and forged tokens, or tokens of another algorithm than the key's, with ErrBadSignature. Tokens minted for another audience, or any token if the audience is empty, are rejected with ErrAudienceMismatch. Tokens minted in the future are rejected with ErrNotYetValid, and expired tokens with ErrExpired, both allowing for WithLeeway. The caller must still check the token's issuer may make the call.
+func ParseServiceToken(keys ServiceKeys, audience, token string, opts ...Option) (*ServiceToken, error) {
+	o := newOptions(opts)
+	start := o.startTimer()
+	t, err := parseServiceToken(keys, audience, token, o)
+	o.observe(start, nil, err)
+	return t, err
+}
+
+func parseServiceToken(keys ServiceKeys, audience, token string, o *options) (*ServiceToken, error) {
+	if !IsServiceToken(token) {
+		return nil, fmt.Errorf("%w: not a service token", ErrMalformed)
+	}
+	if len(token) > serviceTokenMaxSize {
+		return nil, fmt.Errorf("%w: service token longer than %d bytes", ErrMalformed, serviceTokenMaxSize)
+	}
+	dot := strings.LastIndexByte(token, '.')
+	if dot == -1 {
+		return nil, fmt.Errorf("%w: service token has no signature", ErrMalformed)
+	}
+	signed := token[:dot]
+	sig, err := base64.RawURLEncoding.DecodeString(token[dot+1:])
+	if err != nil {
+		return nil, fmt.Errorf("%w: decoding service token signature: %w", ErrMalformed, err)
+	}
+	claims, err := base64.RawURLEncoding.DecodeString(signed[len(ServiceTokenPrefix):])
+	if err != nil {
+		return nil, fmt.Errorf("%w: decoding service token: %w", ErrMalformed, err)
+	}
+	t := &ServiceToken{}
+	if err := json.Unmarshal(claims, t); err != nil {
+		return nil, fmt.Errorf("%w: unmarshalling service token: %w", ErrMalformed, err)
+	}
+	if t.ID == "" || t.Issuer == "" || t.Audience == "" {
+		return nil, fmt.Errorf("%w: service token has no id, issuer, or audience", ErrMalformed)
+	}
+	// The issuer is read before the signature is verified, as by ParseWithIssuerSecrets, but only to select the one key the token must verify with.
+	key, ok := keys.ServicePublicKey(t.Issuer, t.KeyID)
+	if !ok {
+		return nil, ErrUnknownIssuer
+	}
+	if alg := publicKeyAlg(key); alg == "" || t.Alg != alg {
+		return nil, fmt.Errorf("%w: service token algorithm '%s' doesn't match public key type %T", ErrBadSignature, t.Alg, key)
+	}
+	if err := verifyPublicKey(key, signed, sig); err != nil {
+		return nil, err
+	}
+	if audience == "" || t.Audience != audience {
+		return t, ErrAudienceMismatch
+	}
+	if t.ExpiresUnix-t.IssuedAt > int64(MaxServiceTokenDuration/time.Second) {
+		return t, fmt.Errorf("%w: service token lasts longer than %v", ErrMalformed, MaxServiceTokenDuration)
+	}
+	now := o.now()
+	if now.Add(o.leeway).Unix() < t.IssuedAt {
+		return t, ErrNotYetValid
+	}
+	if now.Add(-o.leeway).Unix() > t.ExpiresUnix {
+		return t, &ExpiredError{Expired: t.Expires(), Now: now}
+	}
+	return t, nil
+}
+
+// ServiceTransport is an http.RoundTripper which authenticates the requests of a service to another with a service token, as a bearer token of their Authorization header. Tokens are minted as by NewServiceToken, with the fields and Options of the transport, and reused until half of their duration has passed, so a token is minted every few minutes rather than for every request.
+type ServiceTransport struct {
+	// Base makes the requests. If it is nil, http.DefaultTransport is used.
+	Base http.RoundTripper
+	// Issuer is the name of the calling service, and Audience the name of the service called.
+	Issuer   string
+	Audience string
+	// Key is the private key tokens are signed with. If it is nil, the key of WithSigner or WithSigningKeys of the Options is used.
+	Key crypto.Signer
+	// Duration is how long tokens last. If it isn't positive, DefaultServiceTokenDuration is used.
+	Duration time.Duration
+	// Options configure the tokens, as they do NewServiceToken.
+	Options []Option
+
+	mu    sync.Mutex
+	token string
+	renew time.Time
+}
+
+// RoundTrip implements http.RoundTripper. The request isn't modified; a copy with the Authorization header is made. If a token can't be minted, the request isn't made.
+func (t *ServiceTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	token, err := t.serviceToken()
+	if err != nil {
+		if r.Body != nil {
+			r.Body.Close()
+		}
+		return nil, err
+	}
+	r = r.Clone(r.Context())
+	r.Header.Set("Authorization", bearerPrefix+token)
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return base.RoundTrip(r)
+}
+
+// serviceToken returns the current token, minting a new one if half of its duration has passed.
+func (t *ServiceTransport) serviceToken() (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := newOptions(t.Options).now()
+	if t.token != "" && now.Before(t.renew) {
+		return t.token, nil
+	}
+	token, claims, err := NewServiceToken(t.Issuer, t.Audience, t.Duration, t.Key, t.Options...)
+	if err != nil {
+		return "", err
+	}
+	t.token = token
+	t.renew = now.Add(claims.Expires().Sub(now) / 2)
+	return token, nil
+}
+
+// ServiceMiddleware returns a handler which authenticates requests of other services by the service tokens of their Authorization headers, verified for the audience with the keys and options as by ParseServiceToken, before passing them to next. Authenticated requests are passed to next with the token's claims in their context; see ServiceTokenFromContext. Other requests, including those with user cookies, are answered with 401 Unauthorized and a WWW-Authenticate challenge, and aren't passed to next.
+func ServiceMiddleware(keys ServiceKeys, audience string, next http.Handler, opts ...Option) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, err := bearerToken(r)
+		if err == nil {
+			var t *ServiceToken
+			if t, err = ParseServiceToken(keys, audience, token, opts...); err == nil {
+				next.ServeHTTP(w, r.WithContext(NewServiceTokenContext(r.Context(), t)))
+				return
+			}
+		}
+		SetChallenge(w, DefaultRealm, err)
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+	})
+}
+
+// serviceTokenContextKey is the key of the service token in request contexts.
+type serviceTokenContextKey struct{}
+
+// NewServiceTokenContext returns a copy of the context which carries the authenticated service token.
+func NewServiceTokenContext(ctx context.Context, t *ServiceToken) context.Context {
+	return context.WithValue(ctx, serviceTokenContextKey{}, t)
+}
+
+// ServiceTokenFromContext returns the authenticated service token of a request passed on by ServiceMiddleware, and whether there is one.
+func ServiceTokenFromContext(ctx context.Context) (*ServiceToken, bool) {
+	t, ok := ctx.Value(serviceTokenContextKey{}).(*ServiceToken)
+	return t, ok
+}
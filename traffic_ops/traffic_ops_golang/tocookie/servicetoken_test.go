@@ -0,0 +1,211 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServiceToken(t *testing.T) {
+	edPub, edPriv, _ := ed25519.GenerateKey(rand.Reader)
+	rsaPriv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := ServicePublicKeys{"traffic_ops": edPub, "traffic_monitor": &rsaPriv.PublicKey}
+
+	tests := map[string]struct {
+		issuer string
+		key    crypto.Signer
+	}{
+		"ed25519": {"traffic_ops", edPriv},
+		"rsa":     {"traffic_monitor", rsaPriv},
+	}
+	for name, test := range tests {
+		token, claims, err := NewServiceToken(test.issuer, "traffic_router", 0, test.key)
+		if err != nil || !IsServiceToken(token) || IsAPIToken(token) {
+			t.Fatalf("%v: NewServiceToken expected service token, actual: '%v' %v", name, token, err)
+		}
+		if left := time.Until(claims.Expires()); left <= DefaultServiceTokenDuration-time.Minute || left > DefaultServiceTokenDuration {
+			t.Errorf("%v: NewServiceToken without duration expected DefaultServiceTokenDuration, actual: %v left", name, left)
+		}
+		parsed, err := ParseServiceToken(keys, "traffic_router", token)
+		if err != nil || parsed.ID != claims.ID || parsed.Issuer != test.issuer || parsed.Audience != "traffic_router" {
+			t.Errorf("%v: ParseServiceToken expected claims %+v, actual: %+v %v", name, claims, parsed, err)
+		}
+		if _, err := ParseServiceToken(keys, "traffic_monitor", token); !errors.Is(err, ErrAudienceMismatch) {
+			t.Errorf("%v: ParseServiceToken for another audience expected ErrAudienceMismatch, actual: %v", name, err)
+		}
+		if _, err := ParseServiceToken(keys, "", token); !errors.Is(err, ErrAudienceMismatch) {
+			t.Errorf("%v: ParseServiceToken without audience expected ErrAudienceMismatch, actual: %v", name, err)
+		}
+	}
+
+	if _, _, err := NewServiceToken("traffic_ops", "traffic_router", MaxServiceTokenDuration+time.Second, edPriv); err == nil {
+		t.Error("NewServiceToken longer than MaxServiceTokenDuration expected error, actual: nil")
+	}
+	if _, _, err := NewServiceToken("traffic_ops", "", 0, edPriv); err == nil {
+		t.Error("NewServiceToken without audience expected error, actual: nil")
+	}
+	if _, _, err := NewServiceToken("traffic_ops", "traffic_router", 0, nil); err == nil {
+		t.Error("NewServiceToken without key expected error, actual: nil")
+	}
+}
+
+func TestParseServiceTokenInvalid(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	_, other, _ := ed25519.GenerateKey(rand.Reader)
+	keys := ServicePublicKeys{"traffic_ops": pub}
+	now := time.Now()
+	valid, _, _ := NewServiceToken("traffic_ops", "traffic_router", 0, priv)
+	forged, _, _ := NewServiceToken("traffic_ops", "traffic_router", 0, other)
+	unknown, _, _ := NewServiceToken("traffic_portal", "traffic_router", 0, priv)
+	expired, _, _ := NewServiceToken("traffic_ops", "traffic_router", time.Minute, priv, WithClock(func() time.Time { return now.Add(-time.Hour) }))
+	future, _, _ := NewServiceToken("traffic_ops", "traffic_router", time.Minute, priv, WithClock(func() time.Time { return now.Add(time.Hour) }))
+	long := signServiceToken(t, priv, ServiceToken{ID: "id", Issuer: "traffic_ops", Audience: "traffic_router", Alg: AlgEdDSA, IssuedAt: now.Unix(), ExpiresUnix: now.Add(24 * time.Hour).Unix()})
+	wrongAlg := signServiceToken(t, priv, ServiceToken{ID: "id", Issuer: "traffic_ops", Audience: "traffic_router", Alg: AlgRS256, IssuedAt: now.Unix(), ExpiresUnix: now.Add(time.Minute).Unix()})
+	cookie := NewWithPrivateKey("alice", now.Add(time.Hour), priv, WithAudience("traffic_router"))
+
+	tests := map[string]struct {
+		token    string
+		expected error
+	}{
+		"forged":         {forged, ErrBadSignature},
+		"unknown issuer": {unknown, ErrUnknownIssuer},
+		"expired":        {expired, ErrExpired},
+		"not yet valid":  {future, ErrNotYetValid},
+		"too long lived": {long, ErrMalformed},
+		"wrong alg":      {wrongAlg, ErrBadSignature},
+		"cookie":         {cookie, ErrMalformed},
+		"unsigned":       {strings.SplitN(valid, ".", 2)[0], ErrMalformed},
+		"bad signature":  {valid + "!", ErrMalformed},
+		"too long":       {ServiceTokenPrefix + strings.Repeat("a", serviceTokenMaxSize), ErrMalformed},
+	}
+	for name, test := range tests {
+		if _, err := ParseServiceToken(keys, "traffic_router", test.token); !errors.Is(err, test.expected) {
+			t.Errorf("%v: ParseServiceToken expected %v, actual: %v", name, test.expected, err)
+		}
+	}
+	if _, err := ParseServiceToken(keys, "traffic_router", expired, WithLeeway(time.Hour)); err != nil {
+		t.Errorf("ParseServiceToken within the leeway expected nil error, actual: %v", err)
+	}
+}
+
+// signServiceToken signs the claims as NewServiceToken does, for tokens it refuses to mint.
+func signServiceToken(t *testing.T, key ed25519.PrivateKey, claims ServiceToken) string {
+	t.Helper()
+	raw, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed := ServiceTokenPrefix + base64.RawURLEncoding.EncodeToString(raw)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(ed25519.Sign(key, []byte(signed)))
+}
+
+func TestServiceSigningKeys(t *testing.T) {
+	_, first, _ := ed25519.GenerateKey(rand.Reader)
+	_, second, _ := ed25519.GenerateKey(rand.Reader)
+	signing, err := NewSigningKeys("first", first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := ServiceSigningKeys{"traffic_ops": signing}
+
+	old, _, _ := NewServiceToken("traffic_ops", "traffic_router", 0, nil, WithSigningKeys(signing))
+	if err := signing.Rotate("second", second); err != nil {
+		t.Fatal(err)
+	}
+	current, claims, _ := NewServiceToken("traffic_ops", "traffic_router", 0, nil, WithSigningKeys(signing))
+	if claims.KeyID != "second" {
+		t.Errorf("NewServiceToken WithSigningKeys expected key ID second, actual: '%v'", claims.KeyID)
+	}
+	for name, token := range map[string]string{"retired key": old, "active key": current} {
+		if _, err := ParseServiceToken(keys, "traffic_router", token); err != nil {
+			t.Errorf("%v: ParseServiceToken expected nil error, actual: %v", name, err)
+		}
+	}
+	unidentified, _, _ := NewServiceToken("traffic_ops", "traffic_router", 0, second)
+	if _, err := ParseServiceToken(keys, "traffic_router", unidentified); !errors.Is(err, ErrUnknownIssuer) {
+		t.Errorf("ParseServiceToken without key ID expected ErrUnknownIssuer, actual: %v", err)
+	}
+}
+
+func TestServiceTransport(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	keys := ServicePublicKeys{"traffic_ops": pub}
+	now := time.Now()
+	clock := WithClock(func() time.Time { return now })
+	issued := map[string]bool{}
+	server := httptest.NewServer(ServiceMiddleware(keys, "traffic_monitor", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := ServiceTokenFromContext(r.Context())
+		if !ok || token.Issuer != "traffic_ops" {
+			t.Errorf("ServiceMiddleware expected service token of traffic_ops in context, actual: %+v", token)
+		}
+		issued[token.ID] = true
+	}), clock))
+	defer server.Close()
+
+	transport := &ServiceTransport{
+		Issuer:   "traffic_ops",
+		Audience: "traffic_monitor",
+		Key:      priv,
+		Duration: 2 * time.Minute,
+		Options:  []Option{clock},
+	}
+	client := &http.Client{Transport: transport}
+	get := func() int {
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	for i := 0; i < 3; i++ {
+		if code := get(); code != http.StatusOK {
+			t.Errorf("ServiceTransport request expected status 200, actual: %v", code)
+		}
+	}
+	if len(issued) != 1 {
+		t.Errorf("ServiceTransport expected one token reused, actual: %v", len(issued))
+	}
+	now = now.Add(time.Minute)
+	if get(); len(issued) != 2 {
+		t.Errorf("ServiceTransport after half its duration expected a new token, actual: %v tokens", len(issued))
+	}
+
+	transport.Audience = "traffic_router"
+	transport.token = ""
+	if code := get(); code != http.StatusUnauthorized {
+		t.Errorf("ServiceMiddleware of token for another audience expected status 401, actual: %v", code)
+	}
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized || resp.Header.Get("WWW-Authenticate") == "" {
+		t.Errorf("ServiceMiddleware without token expected status 401 with challenge, actual: %v", resp.StatusCode)
+	}
+}
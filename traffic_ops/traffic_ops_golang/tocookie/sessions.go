@@ -0,0 +1,145 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// The names of the custom claims WithSessionClient records.
+const (
+	ClaimClientIP  = "client_ip"
+	ClaimUserAgent = "user_agent"
+)
+
+// SessionLister is a SessionStore which can list the sessions of a user, so they can be shown to the user, and revoked, as by ListSessions and RevokeUserSessions. MemorySessionStore and the store of tocookie/sqlsession are SessionListers.
+type SessionLister interface {
+	SessionStore
+	// Sessions returns the claims of the sessions of the user, by their IDs in the store. Sessions which have expired may be included, as ListSessions skips them.
+	Sessions(user string) (map[string]*Cookie, error)
+}
+
+// SessionInfo describes a server-side session of a user, as ListSessions returns it, e.g. for a page of the user's signed-in devices.
+type SessionInfo struct {
+	// ID is the SessionID of the session, by which RevokeUserSession revokes it. It isn't the ID of the session in the store, which its cookie carries, so listing sessions doesn't reveal their cookies.
+	ID string `json:"id"`
+	// User is the AuthData of the session.
+	User string `json:"user"`
+	// ClientIP and UserAgent are those of the client which started the session, as recorded WithSessionClient. They are empty for sessions started without it.
+	ClientIP  string `json:"clientIp,omitempty"`
+	UserAgent string `json:"userAgent,omitempty"`
+	// Started is when the session began.
+	Started time.Time `json:"started"`
+	// LastSeen is when the session was last used, as of its last refresh by RefreshServerSession.
+	LastSeen time.Time `json:"lastSeen"`
+	// Expires is when the session expires, unless it is refreshed.
+	Expires time.Time `json:"expires"`
+	// Current is whether the session is that of the request which listed the sessions.
+	Current bool `json:"current,omitempty"`
+}
+
+// WithSessionClient records the IP address and User-Agent of the client of the request in the claims ClaimClientIP and ClaimUserAgent of the session minted by NewServerSession, so ListSessions can tell the user's devices apart. The IP address is that of the ClientIP of WithClientBinding, if the options have one. It adds to the claims of WithClaims, if it is given after them. The claims are only as private as the session: use it only with server-side sessions, whose claims aren't sent to the client.
+func WithSessionClient(r *http.Request, opts ...Option) Option {
+	var clientIP func(r *http.Request) string
+	if o := newOptions(opts); o.binding != nil {
+		clientIP = o.binding.ClientIP
+	}
+	ip, ua := requestIP(r, clientIP), r.UserAgent()
+	return func(o *options) {
+		claims := make(map[string]interface{}, len(o.claims)+2)
+		for name, value := range o.claims {
+			claims[name] = value
+		}
+		claims[ClaimClientIP], claims[ClaimUserAgent] = ip, ua
+		o.claims = claims
+	}
+}
+
+// ListSessions returns the sessions of the user in the store which haven't expired, most recently seen first. The options are WithClock, and WithRevocationStore, whose revoked sessions are skipped.
+func ListSessions(store SessionLister, user string, opts ...Option) ([]SessionInfo, error) {
+	o := newOptions(opts)
+	sessions, err := store.Sessions(user)
+	if err != nil {
+		return nil, fmt.Errorf("listing sessions: %w", err)
+	}
+	now := o.now()
+	infos := make([]SessionInfo, 0, len(sessions))
+	for _, c := range sessions {
+		if c.AuthData != user || c.expired(now) {
+			continue
+		}
+		if o.revocations != nil && c.SessionID != "" {
+			if err := checkRevoked(o.revocations, c.SessionID); err != nil {
+				continue
+			}
+		}
+		infos = append(infos, sessionInfo(c))
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		if !infos[i].LastSeen.Equal(infos[j].LastSeen) {
+			return infos[i].LastSeen.After(infos[j].LastSeen)
+		}
+		return infos[i].ID < infos[j].ID
+	})
+	return infos, nil
+}
+
+// sessionInfo returns the description of the session of the claims.
+func sessionInfo(c *Cookie) SessionInfo {
+	info := SessionInfo{ID: c.SessionID, User: c.AuthData, Started: time.Unix(c.sessionStart(), 0), LastSeen: time.Unix(c.IssuedAt, 0), Expires: c.Expires()}
+	info.ClientIP, _ = c.ClaimString(ClaimClientIP)
+	info.UserAgent, _ = c.ClaimString(ClaimUserAgent)
+	if c.IssuedAt == 0 {
+		info.LastSeen = info.Started
+	}
+	return info
+}
+
+// RevokeUserSession deletes the session of the user with the SessionID from the store, so its cookie is rejected by ParseServerSession. If the user has no such session, e.g. because it is another user's, an error wrapping ErrSessionNotFound is returned.
+func RevokeUserSession(store SessionLister, user, sessionID string) error {
+	sessions, err := store.Sessions(user)
+	if err != nil {
+		return fmt.Errorf("listing sessions: %w", err)
+	}
+	for id, c := range sessions {
+		if c.AuthData == user && sessionID != "" && c.SessionID == sessionID {
+			if err := store.Delete(id); err != nil {
+				return fmt.Errorf("deleting session: %w", err)
+			}
+			return nil
+		}
+	}
+	return ErrSessionNotFound
+}
+
+// RevokeUserSessions deletes every session of the user from the store, except that with the SessionID except, if it isn't empty, for signing out of the user's other devices while keeping the current one. It returns how many sessions were deleted, including those deleted before an error.
+func RevokeUserSessions(store SessionLister, user, except string) (int, error) {
+	sessions, err := store.Sessions(user)
+	if err != nil {
+		return 0, fmt.Errorf("listing sessions: %w", err)
+	}
+	revoked := 0
+	for id, c := range sessions {
+		if c.AuthData != user || except != "" && c.SessionID == except {
+			continue
+		}
+		if err := store.Delete(id); err != nil {
+			return revoked, fmt.Errorf("deleting session: %w", err)
+		}
+		revoked++
+	}
+	return revoked, nil
+}
@@ -0,0 +1,83 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestListSessions(t *testing.T) {
+	secret := "secret"
+	store := NewMemorySessionStore()
+	now := time.Now()
+	clock := func(at time.Time) Option { return WithClock(func() time.Time { return at }) }
+
+	r := httptest.NewRequest("GET", "/login", nil)
+	r.RemoteAddr = "192.0.2.1:1234"
+	r.Header.Set("User-Agent", "Firefox")
+	laptop, _ := NewServerSession(store, "alice", now.Add(time.Hour), secret, WithSessionClient(r), clock(now.Add(-time.Hour)))
+	r.RemoteAddr = "198.51.100.7:4321"
+	r.Header.Set("User-Agent", "Safari")
+	phone, _ := NewServerSession(store, "alice", now.Add(time.Hour), secret, WithClaims(map[string]interface{}{"tenant": "root"}), WithSessionClient(r), clock(now.Add(-2*time.Hour)))
+	NewServerSession(store, "alice", now.Add(-time.Minute), secret)
+	NewServerSession(store, "bob", now.Add(time.Hour), secret)
+	if _, err := RefreshServerSession(store, secret, phone, clock(now.Add(-time.Minute))); err != nil {
+		t.Fatalf("RefreshServerSession expected nil error, actual: %v", err)
+	}
+
+	sessions, err := ListSessions(store, "alice", clock(now))
+	if err != nil || len(sessions) != 2 {
+		t.Fatalf("ListSessions expected 2 unexpired sessions of alice, actual: %+v %v", sessions, err)
+	}
+	if sessions[0].ClientIP != "198.51.100.7" || sessions[0].UserAgent != "Safari" || sessions[1].ClientIP != "192.0.2.1" || sessions[1].UserAgent != "Firefox" {
+		t.Errorf("ListSessions expected the refreshed phone, then the laptop, actual: %+v", sessions)
+	}
+	if !sessions[0].Started.Equal(time.Unix(now.Add(-2*time.Hour).Unix(), 0)) || !sessions[0].LastSeen.Equal(time.Unix(now.Add(-time.Minute).Unix(), 0)) {
+		t.Errorf("ListSessions expected phone started 2h ago and last seen 1m ago, actual: %+v", sessions[0])
+	}
+	phoneClaims, _ := ParseServerSession(store, secret, phone)
+	if tenant, _ := phoneClaims.ClaimString("tenant"); tenant != "root" || sessions[0].ID != phoneClaims.SessionID {
+		t.Errorf("WithSessionClient expected claims of WithClaims kept and ID the SessionID, actual: %+v", phoneClaims)
+	}
+
+	revocations := NewMemoryRevocationStore()
+	revocations.Revoke(phoneClaims.SessionID, now.Add(time.Hour))
+	if sessions, err := ListSessions(store, "alice", clock(now), WithRevocationStore(revocations)); err != nil || len(sessions) != 1 {
+		t.Errorf("ListSessions WithRevocationStore expected revoked session skipped, actual: %+v %v", sessions, err)
+	}
+
+	if err := RevokeUserSession(store, "bob", phoneClaims.SessionID); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("RevokeUserSession of another user's session expected ErrSessionNotFound, actual: %v", err)
+	}
+	if err := RevokeUserSession(store, "alice", phoneClaims.SessionID); err != nil {
+		t.Errorf("RevokeUserSession expected nil error, actual: %v", err)
+	}
+	if _, err := ParseServerSession(store, secret, phone); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("ParseServerSession of revoked session expected ErrSessionNotFound, actual: %v", err)
+	}
+
+	laptopClaims, _ := ParseServerSession(store, secret, laptop)
+	NewServerSession(store, "alice", now.Add(time.Hour), secret)
+	if n, err := RevokeUserSessions(store, "alice", laptopClaims.SessionID); err != nil || n != 1 {
+		t.Errorf("RevokeUserSessions except the laptop expected the other session revoked, actual: %v %v", n, err)
+	}
+	if _, err := ParseServerSession(store, secret, laptop); err != nil {
+		t.Errorf("ParseServerSession of excepted session expected nil error, actual: %v", err)
+	}
+	if sessions, _ := store.Sessions("bob"); len(sessions) != 1 {
+		t.Errorf("RevokeUserSessions expected other users' sessions kept, actual: %v", sessions)
+	}
+}
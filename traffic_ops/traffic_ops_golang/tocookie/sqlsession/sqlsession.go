@@ -0,0 +1,295 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sqlsession provides a tocookie.SessionStore and a tocookie.RevocationStore backed by tables of a PostgreSQL database, such as the Traffic Ops database, so server-side sessions and revocations are shared by every Traffic Ops server:
+//
+//	sessions, revocations := sqlsession.New(db), sqlsession.NewRevocations(db)
+//	stop := sqlsession.StartJanitor(sqlsession.DefaultJanitorInterval, logError, sessions, revocations)
+//	cookie, err := tocookie.NewServerSession(sessions, user, expiration, secret)
+//	c, err := tocookie.ParseServerSession(sessions, secret, cookie, tocookie.WithRevocationStore(revocations))
+//
+// The tables are created by the tocookie_sessions migration of traffic_ops/app/db/migrations, or by Schema. Each query is prepared once, on first use, and reused until Close. The db is typically the *sql.DB of the lib/pq driver, e.g. the DB of the sqlx.DB of Traffic Ops.
+package sqlsession
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/tocookie"
+
+	"github.com/lib/pq"
+)
+
+// DefaultTable is the name of the table of the sessions.
+const DefaultTable = "tocookie_session"
+
+// DefaultRevocationTable is the name of the table of the revocations.
+const DefaultRevocationTable = "tocookie_revocation"
+
+// DefaultJanitorInterval is a reasonable interval for StartJanitor, which keeps the tables small without querying them often.
+const DefaultJanitorInterval = 10 * time.Minute
+
+// Schema creates DefaultTable and DefaultRevocationTable, as the tocookie_sessions migration does, for databases not managed by the Traffic Ops migrations, e.g. in tests. The claims are stored as JSON text, and the expirations are indexed, so pruning is fast.
+const Schema = `CREATE TABLE IF NOT EXISTS tocookie_session (
+	id TEXT PRIMARY KEY,
+	claims TEXT NOT NULL,
+	expires TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS tocookie_session_expires_idx ON tocookie_session (expires);
+CREATE TABLE IF NOT EXISTS tocookie_revocation (
+	session_id TEXT PRIMARY KEY,
+	until TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS tocookie_revocation_until_idx ON tocookie_revocation (until);`
+
+// pgUndefinedTable is the PostgreSQL error code of a query of a table which doesn't exist.
+const pgUndefinedTable = "42P01"
+
+// statements are the prepared statements of a table, prepared on first use.
+type statements struct {
+	db    *sql.DB
+	mu    sync.Mutex
+	stmts map[string]*sql.Stmt
+}
+
+// prepare returns the prepared statement of the query, preparing it if it hasn't been.
+func (s *statements) prepare(query string) (*sql.Stmt, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if stmt, ok := s.stmts[query]; ok {
+		return stmt, nil
+	}
+	stmt, err := s.db.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	if s.stmts == nil {
+		s.stmts = map[string]*sql.Stmt{}
+	}
+	s.stmts[query] = stmt
+	return stmt, nil
+}
+
+func (s *statements) exec(query string, args ...interface{}) (sql.Result, error) {
+	stmt, err := s.prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	return stmt.Exec(args...)
+}
+
+func (s *statements) queryRow(query string, args ...interface{}) (*sql.Row, error) {
+	stmt, err := s.prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	return stmt.QueryRow(args...), nil
+}
+
+func (s *statements) query(query string, args ...interface{}) (*sql.Rows, error) {
+	stmt, err := s.prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	return stmt.Query(args...)
+}
+
+// Close closes the prepared statements. They are prepared again if the store is used after.
+func (s *statements) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	errs := []error{}
+	for query, stmt := range s.stmts {
+		if err := stmt.Close(); err != nil {
+			errs = append(errs, err)
+		}
+		delete(s.stmts, query)
+	}
+	return errors.Join(errs...)
+}
+
+// queryError returns the error of the operation on the table, with a hint if the table doesn't exist.
+func queryError(op, table string, err error) error {
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == pgUndefinedTable {
+		return fmt.Errorf("%s: table %s doesn't exist, has the tocookie_sessions migration been applied? %w", op, table, err)
+	}
+	return fmt.Errorf("%s: %w", op, err)
+}
+
+// Store is a tocookie.SessionStore backed by a table of the database. It is safe for concurrent use.
+type Store struct {
+	statements
+	// Table is the name of the table of the sessions. It is part of the queries as it is, so it must not come from untrusted input, and must not be changed once the store is used.
+	Table string
+}
+
+// New returns a Store of the sessions in DefaultTable of the database.
+func New(db *sql.DB) *Store {
+	return &Store{statements: statements{db: db}, Table: DefaultTable}
+}
+
+// Save stores the claims of the session, replacing any it had.
+func (s *Store) Save(id string, c *tocookie.Cookie) error {
+	claims, err := json.Marshal(c)
+	if err != nil {
+		return fmt.Errorf("encoding session claims: %w", err)
+	}
+	qry := `INSERT INTO ` + s.Table + ` (id, claims, expires) VALUES ($1, $2, $3) ON CONFLICT (id) DO UPDATE SET claims = EXCLUDED.claims, expires = EXCLUDED.expires`
+	if _, err := s.exec(qry, id, string(claims), c.Expires()); err != nil {
+		return queryError("saving session", s.Table, err)
+	}
+	return nil
+}
+
+// Load returns the claims of the session, or an error wrapping tocookie.ErrSessionNotFound if the table has none which haven't expired.
+func (s *Store) Load(id string) (*tocookie.Cookie, error) {
+	claims := ""
+	row, err := s.queryRow(`SELECT claims FROM `+s.Table+` WHERE id = $1 AND expires > $2`, id, time.Now())
+	if err == nil {
+		err = row.Scan(&claims)
+	}
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, tocookie.ErrSessionNotFound
+		}
+		return nil, queryError("loading session", s.Table, err)
+	}
+	c := &tocookie.Cookie{}
+	if err := json.Unmarshal([]byte(claims), c); err != nil {
+		return nil, fmt.Errorf("decoding session claims: %w", err)
+	}
+	return c, nil
+}
+
+// Delete removes the session.
+func (s *Store) Delete(id string) error {
+	if _, err := s.exec(`DELETE FROM `+s.Table+` WHERE id = $1`, id); err != nil {
+		return queryError("deleting session", s.Table, err)
+	}
+	return nil
+}
+
+// Sessions returns the claims of the sessions of the user which haven't expired, by ID, which makes Store a tocookie.SessionLister. The user is matched in the JSON of the claims, as the table has no column of it, so the query scans the sessions which haven't expired; the janitor keeps it short.
+func (s *Store) Sessions(user string) (map[string]*tocookie.Cookie, error) {
+	rows, err := s.query(`SELECT id, claims FROM `+s.Table+` WHERE claims::jsonb->>'auth_data' = $1 AND expires > $2`, user, time.Now())
+	if err != nil {
+		return nil, queryError("listing sessions", s.Table, err)
+	}
+	defer rows.Close()
+	sessions := map[string]*tocookie.Cookie{}
+	for rows.Next() {
+		id, claims := "", ""
+		if err := rows.Scan(&id, &claims); err != nil {
+			return nil, queryError("listing sessions", s.Table, err)
+		}
+		c := &tocookie.Cookie{}
+		if err := json.Unmarshal([]byte(claims), c); err != nil {
+			return nil, fmt.Errorf("decoding claims of session: %w", err)
+		}
+		sessions[id] = c
+	}
+	if err := rows.Err(); err != nil {
+		return nil, queryError("listing sessions", s.Table, err)
+	}
+	return sessions, nil
+}
+
+// Prune deletes the sessions which expired before now, and returns how many it deleted. Expired sessions are never loaded, so pruning only bounds the size of the table; see StartJanitor.
+func (s *Store) Prune(now time.Time) (int64, error) {
+	result, err := s.exec(`DELETE FROM `+s.Table+` WHERE expires <= $1`, now)
+	if err != nil {
+		return 0, queryError("pruning sessions", s.Table, err)
+	}
+	return result.RowsAffected()
+}
+
+// Revocations is a tocookie.RevocationStore backed by a table of the database. It is safe for concurrent use.
+type Revocations struct {
+	statements
+	// Table is the name of the table of the revocations, as Store's Table.
+	Table string
+}
+
+// NewRevocations returns a Revocations of the revocations in DefaultRevocationTable of the database.
+func NewRevocations(db *sql.DB) *Revocations {
+	return &Revocations{statements: statements{db: db}, Table: DefaultRevocationTable}
+}
+
+// Revoke revokes the session until the given time. Revoking a session which is already revoked until later doesn't shorten its revocation, and sessions revoked until a time which has passed are ignored.
+func (r *Revocations) Revoke(sessionID string, until time.Time) error {
+	if !until.After(time.Now()) {
+		return nil
+	}
+	qry := `INSERT INTO ` + r.Table + ` (session_id, until) VALUES ($1, $2) ON CONFLICT (session_id) DO UPDATE SET until = GREATEST(` + r.Table + `.until, EXCLUDED.until)`
+	if _, err := r.exec(qry, sessionID, until); err != nil {
+		return queryError("revoking session", r.Table, err)
+	}
+	return nil
+}
+
+// IsRevoked returns whether the session is revoked, and its revocation hasn't lapsed.
+func (r *Revocations) IsRevoked(sessionID string) (bool, error) {
+	revoked := false
+	row, err := r.queryRow(`SELECT EXISTS (SELECT 1 FROM `+r.Table+` WHERE session_id = $1 AND until > $2)`, sessionID, time.Now())
+	if err == nil {
+		err = row.Scan(&revoked)
+	}
+	if err != nil {
+		return false, queryError("checking session revocation", r.Table, err)
+	}
+	return revoked, nil
+}
+
+// Prune deletes the revocations which lapsed before now, and returns how many it deleted; see StartJanitor.
+func (r *Revocations) Prune(now time.Time) (int64, error) {
+	result, err := r.exec(`DELETE FROM `+r.Table+` WHERE until <= $1`, now)
+	if err != nil {
+		return 0, queryError("pruning revocations", r.Table, err)
+	}
+	return result.RowsAffected()
+}
+
+// Pruner deletes the entries of a table which can no longer be used. Store and Revocations are Pruners.
+type Pruner interface {
+	Prune(now time.Time) (int64, error)
+}
+
+// StartJanitor prunes the tables of the pruners every interval, in a goroutine, until the returned function is called, which waits for a prune in progress to finish, so the stores may be closed after it returns. Errors are reported to onError, if it isn't nil, and don't stop the janitor.
+func StartJanitor(interval time.Duration, onError func(error), pruners ...Pruner) func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-ticker.C:
+				for _, p := range pruners {
+					if _, err := p.Prune(now); err != nil && onError != nil {
+						onError(err)
+					}
+				}
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
@@ -0,0 +1,327 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlsession
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/tocookie"
+
+	"github.com/lib/pq"
+)
+
+// fakeRow is a row of the fake table.
+type fakeRow struct {
+	claims  string
+	expires time.Time
+}
+
+// fakeDB is a database/sql driver holding the session and revocation tables in memory, which understands only the queries of Store and Revocations.
+type fakeDB struct {
+	mu          sync.Mutex
+	rows        map[string]fakeRow
+	revocations map[string]time.Time
+	prepared    int
+}
+
+func (d *fakeDB) Open(string) (driver.Conn, error) { return fakeConn{d}, nil }
+
+type fakeConn struct{ d *fakeDB }
+
+func (c fakeConn) Prepare(query string) (driver.Stmt, error) {
+	c.d.mu.Lock()
+	defer c.d.mu.Unlock()
+	if !strings.Contains(query, DefaultTable+" ") && !strings.Contains(query, DefaultRevocationTable+" ") {
+		return nil, &pq.Error{Code: pgUndefinedTable, Message: "relation does not exist"}
+	}
+	c.d.prepared++
+	return fakeStmt{c.d, query}, nil
+}
+func (c fakeConn) Close() error              { return nil }
+func (c fakeConn) Begin() (driver.Tx, error) { return nil, errors.New("transactions not supported") }
+
+type fakeStmt struct {
+	d     *fakeDB
+	query string
+}
+
+func (s fakeStmt) Close() error  { return nil }
+func (s fakeStmt) NumInput() int { return -1 }
+
+func (s fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+	switch {
+	case strings.HasPrefix(s.query, "INSERT INTO "+DefaultRevocationTable+" "):
+		if until := args[1].(time.Time); until.After(s.d.revocations[args[0].(string)]) {
+			s.d.revocations[args[0].(string)] = until
+		}
+		return driver.RowsAffected(1), nil
+	case strings.HasPrefix(s.query, "DELETE FROM "+DefaultRevocationTable+" WHERE until <= "):
+		n := int64(0)
+		for id, until := range s.d.revocations {
+			if !until.After(args[0].(time.Time)) {
+				delete(s.d.revocations, id)
+				n++
+			}
+		}
+		return driver.RowsAffected(n), nil
+	case strings.HasPrefix(s.query, "INSERT INTO "+DefaultTable+" "):
+		s.d.rows[args[0].(string)] = fakeRow{args[1].(string), args[2].(time.Time)}
+		return driver.RowsAffected(1), nil
+	case strings.HasPrefix(s.query, "DELETE FROM "+DefaultTable+" WHERE id = "):
+		delete(s.d.rows, args[0].(string))
+		return driver.RowsAffected(1), nil
+	case strings.HasPrefix(s.query, "DELETE FROM "+DefaultTable+" WHERE expires <= "):
+		n := int64(0)
+		for id, row := range s.d.rows {
+			if !row.expires.After(args[0].(time.Time)) {
+				delete(s.d.rows, id)
+				n++
+			}
+		}
+		return driver.RowsAffected(n), nil
+	}
+	return nil, errors.New("unexpected query: " + s.query)
+}
+
+func (s fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+	rows := &fakeRows{}
+	switch {
+	case strings.HasPrefix(s.query, "SELECT claims FROM "+DefaultTable+" WHERE id = "):
+		if row, ok := s.d.rows[args[0].(string)]; ok && row.expires.After(args[1].(time.Time)) {
+			rows.values = [][]driver.Value{{row.claims}}
+		}
+	case strings.HasPrefix(s.query, "SELECT id, claims FROM "+DefaultTable+" WHERE claims::jsonb->>'auth_data' = "):
+		for id, row := range s.d.rows {
+			claims := struct {
+				AuthData string `json:"auth_data"`
+			}{}
+			if json.Unmarshal([]byte(row.claims), &claims) == nil && claims.AuthData == args[0].(string) && row.expires.After(args[1].(time.Time)) {
+				rows.values = append(rows.values, []driver.Value{id, row.claims})
+			}
+		}
+	case strings.HasPrefix(s.query, "SELECT EXISTS (SELECT 1 FROM "+DefaultRevocationTable+" WHERE session_id = "):
+		until, ok := s.d.revocations[args[0].(string)]
+		rows.values = [][]driver.Value{{ok && until.After(args[1].(time.Time))}}
+	default:
+		return nil, errors.New("unexpected query: " + s.query)
+	}
+	return rows, nil
+}
+
+// fakeRows are the rows of a query, of one or two columns.
+type fakeRows struct{ values [][]driver.Value }
+
+func (r *fakeRows) Columns() []string {
+	if len(r.values) > 0 && len(r.values[0]) == 2 {
+		return []string{"id", "claims"}
+	}
+	return []string{"value"}
+}
+func (r *fakeRows) Close() error { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+var fakeDriverOnce sync.Once
+var fakeDriver = &fakeDB{}
+
+func openFake(t *testing.T) (*sql.DB, *fakeDB) {
+	fakeDriverOnce.Do(func() { sql.Register("sqlsession-fake", fakeDriver) })
+	fakeDriver.mu.Lock()
+	fakeDriver.rows = map[string]fakeRow{}
+	fakeDriver.revocations = map[string]time.Time{}
+	fakeDriver.prepared = 0
+	fakeDriver.mu.Unlock()
+	db, err := sql.Open("sqlsession-fake", "")
+	if err != nil {
+		t.Fatalf("sql.Open expected nil error, actual: %v", err)
+	}
+	return db, fakeDriver
+}
+
+func TestStore(t *testing.T) {
+	db, fake := openFake(t)
+	defer db.Close()
+	store := New(db)
+	secret := "secret"
+
+	cookie, err := tocookie.NewServerSession(store, "alice", time.Now().Add(time.Hour), secret, tocookie.WithRoles("admin"), tocookie.WithClaims(map[string]interface{}{"tenant": "root"}))
+	if err != nil {
+		t.Fatalf("NewServerSession expected nil error, actual: %v", err)
+	}
+	c, err := tocookie.ParseServerSession(store, secret, cookie)
+	if err != nil {
+		t.Fatalf("ParseServerSession expected nil error, actual: %v", err)
+	}
+	if tenant, _ := c.ClaimString("tenant"); c.AuthData != "alice" || !c.HasRole("admin") || tenant != "root" {
+		t.Errorf("ParseServerSession expected alice with role admin and tenant root, actual: %+v", c)
+	}
+	if _, err := tocookie.RefreshServerSession(store, secret, cookie); err != nil {
+		t.Errorf("RefreshServerSession expected nil error, actual: %v", err)
+	}
+	if err := tocookie.DeleteServerSession(store, secret, cookie); err != nil {
+		t.Fatalf("DeleteServerSession expected nil error, actual: %v", err)
+	}
+	if _, err := tocookie.ParseServerSession(store, secret, cookie); !errors.Is(err, tocookie.ErrSessionNotFound) {
+		t.Errorf("ParseServerSession after delete expected ErrSessionNotFound, actual: %v", err)
+	}
+
+	expired, err := tocookie.NewServerSession(store, "bob", time.Now().Add(-time.Minute), secret)
+	if err != nil {
+		t.Fatalf("NewServerSession expected nil error, actual: %v", err)
+	}
+	if _, err := tocookie.ParseServerSession(store, secret, expired); !errors.Is(err, tocookie.ErrSessionNotFound) {
+		t.Errorf("ParseServerSession of expired session expected ErrSessionNotFound, actual: %v", err)
+	}
+	if _, err := tocookie.NewServerSession(store, "carol", time.Now().Add(time.Hour), secret); err != nil {
+		t.Fatalf("NewServerSession expected nil error, actual: %v", err)
+	}
+	if n, err := store.Prune(time.Now()); err != nil || n != 1 {
+		t.Errorf("Prune expected 1 session deleted, actual: %v, %v", n, err)
+	}
+	if len(fake.rows) != 1 {
+		t.Errorf("Prune expected 1 session left, actual: %v", len(fake.rows))
+	}
+	if fake.prepared != 4 {
+		t.Errorf("Store expected each of its 4 queries prepared once, actual: %v prepares", fake.prepared)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := tocookie.NewServerSession(store, "dave", time.Now().Add(time.Hour), secret); err != nil {
+			t.Fatalf("NewServerSession expected nil error, actual: %v", err)
+		}
+	}
+	sessions, err := store.Sessions("dave")
+	if err != nil || len(sessions) != 2 {
+		t.Fatalf("Sessions expected 2 sessions of dave, actual: %v %v", sessions, err)
+	}
+	for id, c := range sessions {
+		if len(id) == 0 || c.AuthData != "dave" {
+			t.Errorf("Sessions expected sessions of dave by ID, actual: '%v' %+v", id, c)
+		}
+	}
+	if n, err := tocookie.RevokeUserSessions(store, "dave", ""); err != nil || n != 2 {
+		t.Errorf("RevokeUserSessions expected 2 sessions revoked, actual: %v %v", n, err)
+	}
+	if sessions, err := store.Sessions("dave"); err != nil || len(sessions) != 0 {
+		t.Errorf("Sessions after RevokeUserSessions expected none, actual: %v %v", sessions, err)
+	}
+	if err := store.Close(); err != nil {
+		t.Errorf("Close expected nil error, actual: %v", err)
+	}
+}
+
+func TestRevocations(t *testing.T) {
+	db, fake := openFake(t)
+	defer db.Close()
+	revocations := NewRevocations(db)
+	defer revocations.Close()
+	secret := "secret"
+
+	cookie := tocookie.New("alice", time.Now().Add(time.Hour), secret)
+	c, err := tocookie.Parse(secret, cookie, tocookie.WithRevocationStore(revocations))
+	if err != nil {
+		t.Fatalf("Parse expected nil error, actual: %v", err)
+	}
+	if err := revocations.Revoke(c.SessionID, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("Revoke expected nil error, actual: %v", err)
+	}
+	if err := revocations.Revoke(c.SessionID, time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("Revoke expected nil error, actual: %v", err)
+	}
+	if _, err := tocookie.Parse(secret, cookie, tocookie.WithRevocationStore(revocations)); !errors.Is(err, tocookie.ErrRevoked) {
+		t.Errorf("Parse of revoked session expected ErrRevoked, actual: %v", err)
+	}
+	if n, err := revocations.Prune(time.Now().Add(30 * time.Minute)); err != nil || n != 0 {
+		t.Errorf("Prune before the latest revocation lapses expected nothing deleted, actual: %v, %v", n, err)
+	}
+	if err := revocations.Revoke("lapsed", time.Now().Add(-time.Minute)); err != nil || len(fake.revocations) != 1 {
+		t.Errorf("Revoke until the past expected to be ignored, actual: %v revocations, %v", len(fake.revocations), err)
+	}
+	if n, err := revocations.Prune(time.Now().Add(2 * time.Hour)); err != nil || n != 1 {
+		t.Errorf("Prune after the revocation lapsed expected 1 deleted, actual: %v, %v", n, err)
+	}
+	if revoked, err := revocations.IsRevoked(c.SessionID); err != nil || revoked {
+		t.Errorf("IsRevoked after prune expected false, actual: %v, %v", revoked, err)
+	}
+}
+
+func TestStartJanitor(t *testing.T) {
+	db, fake := openFake(t)
+	defer db.Close()
+	store, revocations := New(db), NewRevocations(db)
+	if err := store.Save("expired", &tocookie.Cookie{AuthData: "alice", ExpiresUnix: time.Now().Add(-time.Minute).Unix()}); err != nil {
+		t.Fatalf("Save expected nil error, actual: %v", err)
+	}
+	fake.mu.Lock()
+	fake.revocations["lapsed"] = time.Now().Add(-time.Minute)
+	fake.mu.Unlock()
+
+	missing := New(db)
+	missing.Table = "missing"
+	errs := make(chan error, 1)
+	stop := StartJanitor(time.Millisecond, func(err error) {
+		select {
+		case errs <- err:
+		default:
+		}
+	}, store, revocations, missing)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		fake.mu.Lock()
+		pruned := len(fake.rows) == 0 && len(fake.revocations) == 0
+		fake.mu.Unlock()
+		if pruned || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	stop()
+	if len(fake.rows) != 0 || len(fake.revocations) != 0 {
+		t.Errorf("StartJanitor expected expired entries pruned, actual: %v sessions, %v revocations", len(fake.rows), len(fake.revocations))
+	}
+	if err := <-errs; !strings.Contains(err.Error(), "migration") {
+		t.Errorf("StartJanitor expected error of missing table with migration hint, actual: %v", err)
+	}
+}
+
+func TestStoreErrors(t *testing.T) {
+	db, _ := openFake(t)
+	defer db.Close()
+	store := New(db)
+	store.Table = "other"
+	if err := store.Save("id", &tocookie.Cookie{AuthData: "alice"}); err == nil {
+		t.Errorf("Save to unknown table expected error, actual nil")
+	}
+	if _, err := store.Load("id"); err == nil || errors.Is(err, tocookie.ErrSessionNotFound) {
+		t.Errorf("Load from unknown table expected error other than ErrSessionNotFound, actual: %v", err)
+	}
+}
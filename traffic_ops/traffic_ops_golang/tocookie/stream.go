@@ -0,0 +1,99 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+)
+
+// streamChunkSize is the number of bytes read from the source per iteration when streaming. It must be a multiple of 4, so each chunk of base64 text decodes independently.
+const streamChunkSize = 32 * 1024
+
+// NewRawMsgStream is the streaming equivalent of NewRawMsg. The message is read from r, and the base64 encoded message, separator, and hex signature are written to w. The message is encoded and fed to the HMAC incrementally, so it is never held in memory in its entirety. The output is byte-for-byte identical to NewRawMsg.
+func NewRawMsgStream(w io.Writer, r io.Reader, key []byte) error {
+	mac := hmac.New(sha1.New, key)
+	enc := base64.NewEncoder(base64.RawURLEncoding, io.MultiWriter(w, mac))
+	if _, err := io.Copy(enc, r); err != nil {
+		return fmt.Errorf("encoding message: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return fmt.Errorf("encoding message: %w", err)
+	}
+	if _, err := io.WriteString(w, "--"+hex.EncodeToString(mac.Sum(nil))); err != nil {
+		return fmt.Errorf("writing signature: %w", err)
+	}
+	return nil
+}
+
+// VerifyRawMsgStream reads a message signed by NewRawMsg or NewRawMsgStream from r, writes the decoded message to w, and verifies the signature once the input is exhausted.
+//
+// Because the message is not buffered, the decoded bytes are written to w before the signature has been checked. Callers MUST discard everything written to w if an error is returned.
+func VerifyRawMsgStream(w io.Writer, r io.Reader, key []byte) error {
+	mac := hmac.New(sha1.New, key)
+	tailLen := len("--") + hex.EncodedLen(mac.Size())
+
+	// pending holds base64 text which hasn't yet been decoded, followed by the last tailLen bytes read, which may be the separator and signature.
+	pending := make([]byte, 0, 2*streamChunkSize+tailLen)
+	decoded := make([]byte, base64.RawURLEncoding.DecodedLen(cap(pending)))
+	buf := make([]byte, streamChunkSize)
+	for {
+		n, err := r.Read(buf)
+		pending = append(pending, buf[:n]...)
+		if msgLen := len(pending) - tailLen; msgLen >= streamChunkSize {
+			msgLen -= msgLen % 4
+			if err := decodeStreamChunk(w, mac, decoded, pending[:msgLen]); err != nil {
+				return err
+			}
+			pending = append(pending[:0], pending[msgLen:]...)
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("reading message: %w", err)
+		}
+	}
+
+	if len(pending) < tailLen || string(pending[len(pending)-tailLen:len(pending)-tailLen+2]) != "--" {
+		return fmt.Errorf("%w: message has no signature", ErrMalformed)
+	}
+	sig, err := hex.DecodeString(string(pending[len(pending)-tailLen+2:]))
+	if err != nil {
+		return fmt.Errorf("%w: error decoding signature: %w", ErrMalformed, err)
+	}
+	if err := decodeStreamChunk(w, mac, decoded, pending[:len(pending)-tailLen]); err != nil {
+		return err
+	}
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return ErrBadSignature
+	}
+	return nil
+}
+
+// decodeStreamChunk feeds the base64 text to the HMAC, and writes the decoded text to w. The scratch buffer must be large enough to hold the decoded chunk.
+func decodeStreamChunk(w io.Writer, mac io.Writer, scratch []byte, chunk []byte) error {
+	mac.Write(chunk)
+	n, err := base64.RawURLEncoding.Decode(scratch, chunk)
+	if err != nil {
+		return fmt.Errorf("%w: error decoding base64 data: %w", ErrMalformed, err)
+	}
+	if _, err := w.Write(scratch[:n]); err != nil {
+		return fmt.Errorf("writing message: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"bytes"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestRawMsgStreamMatchesNewRawMsg(t *testing.T) {
+	key := []byte("secret")
+	for _, size := range []int{0, 1, 2, 3, 4, 1000, streamChunkSize - 1, streamChunkSize, 3*streamChunkSize + 7} {
+		msg := make([]byte, size)
+		rand.New(rand.NewSource(int64(size))).Read(msg)
+
+		signed := bytes.Buffer{}
+		if err := NewRawMsgStream(&signed, bytes.NewReader(msg), key); err != nil {
+			t.Fatalf("size %v: NewRawMsgStream expected nil error, actual: %v", size, err)
+		}
+		if expected := NewRawMsg(msg, key); signed.String() != expected {
+			t.Errorf("size %v: NewRawMsgStream expected output identical to NewRawMsg", size)
+		}
+
+		decoded := bytes.Buffer{}
+		if err := VerifyRawMsgStream(&decoded, &signed, key); err != nil {
+			t.Fatalf("size %v: VerifyRawMsgStream expected nil error, actual: %v", size, err)
+		}
+		if !bytes.Equal(decoded.Bytes(), msg) {
+			t.Errorf("size %v: VerifyRawMsgStream expected decoded message to match original", size)
+		}
+	}
+}
+
+func TestVerifyRawMsgStreamRejects(t *testing.T) {
+	key := []byte("secret")
+	signed := NewRawMsg([]byte(strings.Repeat("large signed object ", 10000)), key)
+
+	tampered := []byte(signed)
+	tampered[len(tampered)/2] ^= 1
+
+	tests := map[string]struct {
+		signed string
+		key    []byte
+	}{
+		"wrong key":    {signed, []byte("wrong")},
+		"tampered":     {string(tampered), key},
+		"no signature": {signed[:len(signed)-42], key},
+		"empty":        {"", key},
+	}
+	for name, test := range tests {
+		if err := VerifyRawMsgStream(&bytes.Buffer{}, strings.NewReader(test.signed), test.key); err == nil {
+			t.Errorf("%v: VerifyRawMsgStream expected error, actual nil", name)
+		}
+	}
+}
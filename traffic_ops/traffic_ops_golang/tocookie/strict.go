@@ -0,0 +1,116 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// WithStrict makes Parse only accept cookies exactly as Perl Mojolicious mints them, as its sessions would read them: version 0, the payload in standard base64 with the exact '-' padding Mojolicious writes, "--", and the full lowercase hex tag of the hash, with no surrounding whitespace or quotes, and a payload of valid UTF-8. It is for checking that only cookies both Perl and Go Traffic Ops accept are in use, e.g. before switching between them, as the default compat mode also accepts cookies Perl would reject: unpadded and base64url payloads, truncated tags, and the versioned cookies of WithVersion.
+//
+// WithStrict takes precedence over WithTrim, WithURLEncoding, and WithPadding. It only affects parsing; New mints cookies Perl reads by default.
+func WithStrict() Option {
+	return func(o *options) { o.strict = true }
+}
+
+// DefaultMaxExpiry is how far in the future cookies may expire WithStrictPayload, if it isn't given a maximum: longer than any session Traffic Ops mints, and short enough that a hand-crafted cookie expiring in decades is rejected.
+const DefaultMaxExpiry = 7 * 24 * time.Hour
+
+// WithStrictPayload makes Parse reject cookies whose payloads no minter of this package would have written, with ErrMalformed, as hardening against tampered or hand-crafted cookies, e.g. those signed with a leaked secret: payloads with unknown keys, as WithDisallowUnknownFields rejects them; payloads without the by or auth_data claims; payloads expiring further in the future than maxExpiry, or DefaultMaxExpiry if it isn't positive, as WithMaxExpiry rejects them; and payloads in non-canonical base64, whose unused bits are set, in every version. Unlike WithStrict, it accepts every version and encoding which Parse does by default, so it suits services reading cookies of both Perl and Go Traffic Ops, though Perl's Mojolicious session data are unknown keys.
+func WithStrictPayload(maxExpiry time.Duration) Option {
+	if maxExpiry <= 0 {
+		maxExpiry = DefaultMaxExpiry
+	}
+	return func(o *options) {
+		o.disallowUnknownFields = true
+		o.canonicalBase64 = true
+		o.requireIdentity = true
+		o.maxExpiry = maxExpiry
+	}
+}
+
+// WithMaxExpiry makes Parse and Validate reject cookies expiring further in the future than the duration with ErrMalformed, as no cookie is minted to last that long, so such cookies have been tampered with or hand-crafted. By default, cookies may expire at any time.
+func WithMaxExpiry(duration time.Duration) Option {
+	return func(o *options) { o.maxExpiry = duration }
+}
+
+// errMissingIdentity is the error of cookies without the by or auth_data claims, WithStrictPayload.
+var errMissingIdentity = fmt.Errorf("%w: cookie has no by or auth_data", ErrMalformed)
+
+// checkStrictPayload returns an error if the claims of the cookie are rejected by WithStrictPayload or WithMaxExpiry at now.
+func (o *options) checkStrictPayload(c *Cookie, now time.Time) error {
+	if o.requireIdentity && (c.By == "" || c.AuthData == "") {
+		return errMissingIdentity
+	}
+	if o.maxExpiry > 0 && c.ExpiresUnix > now.Add(o.maxExpiry).Unix() {
+		return fmt.Errorf("%w: cookie expires at %v, after the maximum of %v from now", ErrMalformed, c.Expires().UTC().Format(time.RFC3339), o.maxExpiry)
+	}
+	return nil
+}
+
+// canonicalURLEncoding and canonicalV0FallbackEncodings are the encodings of payloads WithStrictPayload, rejecting non-canonical base64.
+var (
+	canonicalURLEncoding         = base64.RawURLEncoding.Strict()
+	canonicalV0FallbackEncodings = []*base64.Encoding{base64.RawURLEncoding.Strict(), base64.StdEncoding.Strict(), base64.RawStdEncoding.Strict()}
+)
+
+// strictEncoding is the encoding of Mojolicious payloads, rejecting non-canonical base64, whose unused bits Mojolicious never sets.
+var strictEncoding = mojoEncoding.Strict()
+
+// splitStrict splits a version 0 cookie as Mojolicious mints it, for Parse WithStrict. The entire text before the last "--" is the payload, as Mojolicious::Controller::signed_cookie splits it.
+func splitStrict(cookie string, o *options) (signedCookie, error) {
+	sepPos := strings.LastIndex(cookie, "--")
+	if sepPos == -1 {
+		return signedCookie{}, fmt.Errorf("%w: strict: no signature", ErrMalformed)
+	}
+	payload, tag := cookie[:sepPos], cookie[sepPos+2:]
+	if len(tag) != 2*o.hash.Size() || strings.IndexFunc(tag, func(c rune) bool { return (c < '0' || c > '9') && (c < 'a' || c > 'f') }) != -1 {
+		return signedCookie{}, fmt.Errorf("%w: strict: signature isn't %d lowercase hex digits", ErrMalformed, 2*o.hash.Size())
+	}
+	if len(payload) == 0 || len(payload)%4 != 0 || strings.IndexFunc(strings.TrimRight(payload, "-"), func(c rune) bool { return !isStdBase64Char(c) }) != -1 {
+		return signedCookie{}, fmt.Errorf("%w: strict: payload isn't '-' padded standard base64", ErrMalformed)
+	}
+	sig := make([]byte, len(tag)/2)
+	for i := range sig {
+		sig[i] = unhex(tag[2*i])<<4 | unhex(tag[2*i+1])
+	}
+	return signedCookie{version: Version0, signed: payload, payload: payload, sig: sig}, nil
+}
+
+// decodeStrict decodes the payload of a cookie split by splitStrict into dst, which is grown if need be.
+func decodeStrict(dst, text []byte) ([]byte, error) {
+	txtBytes, err := decodeBase64(dst, strictEncoding, text)
+	if err != nil {
+		return nil, fmt.Errorf("%w: strict: error decoding base64 data: %w", ErrMalformed, err)
+	}
+	if !utf8.Valid(txtBytes) {
+		return nil, fmt.Errorf("%w: strict: payload isn't valid UTF-8", ErrMalformed)
+	}
+	return txtBytes, nil
+}
+
+func isStdBase64Char(c rune) bool {
+	return c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '+' || c == '/'
+}
+
+// unhex returns the value of a lowercase hex digit.
+func unhex(c byte) byte {
+	if c >= 'a' {
+		return c - 'a' + 10
+	}
+	return c - '0'
+}
@@ -0,0 +1,146 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWithStrict(t *testing.T) {
+	secret := "secret"
+	// sign signs text Mojolicious wouldn't mint as it signs cookies; newMojoCookie mints those it would.
+	sign := func(text string) string { return signCookie(text, []byte(secret), newOptions(nil)) }
+	expires := strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)
+	session := `{"auth_data":"alice","expires":` + expires + `}`
+	padded := base64.StdEncoding.EncodeToString([]byte(`{"auth_data":"alice1","expires":` + expires + `}`))
+	if !strings.HasSuffix(padded, "=") {
+		t.Fatalf("test payload expected to be padded, actual: %v", padded)
+	}
+	unpadded := strings.TrimRight(padded, "=")
+	last := strings.IndexByte("ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/", unpadded[len(unpadded)-1])
+	nonCanonical := unpadded[:len(unpadded)-1] + string("ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/"[last+1]) + strings.Repeat("-", len(padded)-len(unpadded))
+	base64url := base64.RawURLEncoding.EncodeToString([]byte(`{"auth_data":"ab???","expires":` + expires + `}`))
+	if len(base64url)%4 != 0 || !strings.Contains(base64url, "_") {
+		t.Fatalf("test payload expected to be unpadded with base64url characters, actual: %v", base64url)
+	}
+	perl := newMojoCookie(session, secret)
+	sepPos := strings.LastIndex(perl, "--")
+	forged := perl[:len(perl)-1] + "0"
+	if strings.HasSuffix(perl, "0") {
+		forged = perl[:len(perl)-1] + "1"
+	}
+
+	tests := map[string]struct {
+		cookie string
+		opts   []Option
+		compat bool
+		strict bool
+	}{
+		"perl":           {perl, nil, true, true},
+		"new":            {New("alice", time.Now().Add(time.Hour), secret), nil, true, true},
+		"padding":        {newMojoCookie(`{"auth_data":"alice1","expires":`+expires+`}`, secret), nil, true, true},
+		"base64url":      {sign(base64url), nil, true, false},
+		"missing pad":    {sign(unpadded), nil, true, false},
+		"non-canonical":  {sign(nonCanonical), nil, true, false},
+		"version 1":      {New("alice", time.Now().Add(time.Hour), secret, WithVersion(Version1)), nil, true, false},
+		"uppercase hex":  {perl[:sepPos+2] + strings.ToUpper(perl[sepPos+2:]), nil, true, false},
+		"truncated tag":  {perl[:len(perl)-8], []Option{WithTagLength(16)}, true, false},
+		"whitespace":     {" " + perl + " ", []Option{WithTrim()}, true, false},
+		"invalid utf-8":  {newMojoCookie(`{"auth_data":"al`+"\xff"+`ice","expires":`+expires+`}`, secret), nil, true, false},
+		"url encoded":    {strings.Replace(newMojoCookie(`{"auth_data":"bob???","expires":`+expires+`}`, secret), "/", "%2F", -1), []Option{WithURLEncoding()}, true, false},
+		"no signature":   {perl[:sepPos], nil, false, false},
+		"bad signature":  {forged, nil, false, false},
+		"empty payload":  {sign(""), nil, false, false},
+		"payload dashes": {sign("eyJh-ZXh--"), nil, false, false},
+	}
+	for name, test := range tests {
+		if _, err := Parse(secret, test.cookie, test.opts...); (err == nil) != test.compat {
+			t.Errorf("%v: Parse expected success %v, actual: %v", name, test.compat, err)
+		}
+		_, err := Parse(secret, test.cookie, append(test.opts, WithStrict())...)
+		if test.strict && err != nil {
+			t.Errorf("%v: Parse WithStrict expected nil error, actual: %v", name, err)
+		} else if !test.strict && !IsAuthFailure(err) {
+			t.Errorf("%v: Parse WithStrict expected auth failure, actual: %v", name, err)
+		}
+	}
+
+	if _, err := Parse(secret, sign(nonCanonical), WithStrict()); !errors.Is(err, ErrMalformed) {
+		t.Errorf("Parse WithStrict of non-canonical base64 expected ErrMalformed, actual: %v", err)
+	}
+	p := NewParser(secret, WithStrict())
+	if _, err := p.Parse(perl); err != nil {
+		t.Errorf("Parser WithStrict expected nil error, actual: %v", err)
+	}
+	if _, err := p.Parse(New("alice", time.Now().Add(time.Hour), secret, WithVersion(Version1))); !errors.Is(err, ErrMalformed) {
+		t.Errorf("Parser WithStrict of version 1 expected ErrMalformed, actual: %v", err)
+	}
+}
+
+func TestWithStrictPayload(t *testing.T) {
+	secret := "secret"
+	sign := func(text string) string { return signCookie(text, []byte(secret), newOptions(nil)) }
+	expires := strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)
+	payload := func(session string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(session))
+	}
+	canonical := payload(`{"auth_data":"alice1","by":"trafficcontrol-go-tocookie","expires":` + expires + `}`)
+	if len(canonical)%4 == 0 {
+		t.Fatalf("test payload expected to end in a partial quantum, actual: %v", canonical)
+	}
+	const alphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-_"
+	last := strings.IndexByte(alphabet, canonical[len(canonical)-1])
+	nonCanonical := canonical[:len(canonical)-1] + string(alphabet[last+1])
+
+	tests := map[string]struct {
+		cookie string
+		strict bool
+	}{
+		"new":           {New("alice", time.Now().Add(time.Hour), secret), true},
+		"version 1":     {New("alice", time.Now().Add(time.Hour), secret, WithVersion(Version1)), true},
+		"perl":          {newMojoCookie(`{"auth_data":"alice","by":"trafficops","expires":`+expires+`}`, secret), true},
+		"canonical":     {sign(canonical), true},
+		"non-canonical": {sign(nonCanonical), false},
+		"unknown field": {sign(payload(`{"auth_data":"alice","by":"trafficops","expires":` + expires + `,"flash":{}}`)), false},
+		"no by":         {sign(payload(`{"auth_data":"alice","expires":` + expires + `}`)), false},
+		"no auth_data":  {sign(payload(`{"by":"trafficops","expires":` + expires + `}`)), false},
+		"far expiry":    {New("alice", time.Now().Add(DefaultMaxExpiry+time.Hour), secret), false},
+	}
+	for name, test := range tests {
+		if _, err := Parse(secret, test.cookie); err != nil {
+			t.Errorf("%v: Parse expected nil error, actual: %v", name, err)
+		}
+		if _, err := Parse(secret, test.cookie, WithStrictPayload(0)); test.strict && err != nil {
+			t.Errorf("%v: Parse WithStrictPayload expected nil error, actual: %v", name, err)
+		} else if !test.strict && !errors.Is(err, ErrMalformed) {
+			t.Errorf("%v: Parse WithStrictPayload expected ErrMalformed, actual: %v", name, err)
+		}
+	}
+
+	long := New("alice", time.Now().Add(30*24*time.Hour), secret)
+	if _, err := Parse(secret, long, WithStrictPayload(31*24*time.Hour)); err != nil {
+		t.Errorf("Parse WithStrictPayload within its maximum expected nil error, actual: %v", err)
+	}
+	if _, err := Parse(secret, long, WithMaxExpiry(time.Hour)); !errors.Is(err, ErrMalformed) {
+		t.Errorf("Parse WithMaxExpiry beyond the maximum expected ErrMalformed, actual: %v", err)
+	}
+	p := NewParser(secret, WithStrictPayload(0))
+	if _, err := p.Parse(sign(nonCanonical)); !errors.Is(err, ErrMalformed) {
+		t.Errorf("Parser WithStrictPayload of non-canonical base64 expected ErrMalformed, actual: %v", err)
+	}
+}
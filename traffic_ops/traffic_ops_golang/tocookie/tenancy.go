@@ -0,0 +1,94 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// ErrTenantNotAncestor is returned by RequireTenantAncestor for cookies whose tenant may not access the tenant.
+var ErrTenantNotAncestor = errors.New("cookie's tenant is not an ancestor of the tenant")
+
+// tenantHashLen is the number of bytes of the SHA-256 of a subtree kept by TenantSubtreeHash.
+const tenantHashLen = 16
+
+// Tenancy is the tenant of the user, as Traffic Ops tenancy scopes users to the resources of their tenant and its descendants.
+type Tenancy struct {
+	// ID is the ID of the user's tenant.
+	ID int `json:"id"`
+	// Subtree are the IDs of the tenants descending from ID, sorted, if the cookie was minted WithTenant of them.
+	Subtree []int `json:"sub,omitempty"`
+	// Hash is the TenantSubtreeHash of the tenant's subtree when the cookie was minted, so services holding the tenant tree can tell whether it has changed since; see TenantSubtreeCurrent.
+	Hash string `json:"hash,omitempty"`
+}
+
+// WithTenant sets the tenant of a cookie minted by New, and the IDs of the tenants descending from it, whose resources the user may access too, so handlers can scope requests with RequireTenantAncestor without querying the tenant tree. The subtree is embedded in the cookie, so tenants with too many descendants for WithMaxClaimsSize should be given WithTenantHash instead. The subtree is that of when the cookie is minted, and is preserved by Refresh.
+func WithTenant(id int, subtree ...int) Option {
+	t := &Tenancy{ID: id, Hash: TenantSubtreeHash(id, subtree)}
+	if len(subtree) > 0 {
+		t.Subtree = append([]int(nil), subtree...)
+		sort.Ints(t.Subtree)
+	}
+	return func(o *options) { o.tenancy = t }
+}
+
+// WithTenantHash sets the tenant of a cookie minted by New, with only the TenantSubtreeHash of its subtree, for tenants whose subtrees are too large to embed. Handlers must then scope requests by their own copy of the tenant tree, and should only trust it if TenantSubtreeCurrent.
+func WithTenantHash(id int, subtree ...int) Option {
+	t := &Tenancy{ID: id, Hash: TenantSubtreeHash(id, subtree)}
+	return func(o *options) { o.tenancy = t }
+}
+
+// TenantSubtreeHash returns a digest of the tenant and the IDs of the tenants descending from it, in any order, which changes when tenants are added to or moved out of the subtree.
+func TenantSubtreeHash(id int, subtree []int) string {
+	sorted := append([]int(nil), subtree...)
+	sort.Ints(sorted)
+	b := strconv.AppendInt(nil, int64(id), 10)
+	for _, descendant := range sorted {
+		b = append(b, ',')
+		b = strconv.AppendInt(b, int64(descendant), 10)
+	}
+	sum := sha256.Sum256(b)
+	return base64.RawURLEncoding.EncodeToString(sum[:tenantHashLen])
+}
+
+// TenantID returns the ID of the cookie's tenant, and whether it has one.
+func (c *Cookie) TenantID() (int, bool) {
+	if c.Tenancy == nil {
+		return 0, false
+	}
+	return c.Tenancy.ID, true
+}
+
+// RequireTenantAncestor returns nil if the cookie's tenant is the tenant of the ID, or an ancestor of it in the subtree of WithTenant, so the user may access the tenant's resources, and otherwise an error wrapping ErrTenantNotAncestor. Cookies without a tenant may access nothing, and those minted WithTenantHash only their own tenant.
+func (c *Cookie) RequireTenantAncestor(id int) error {
+	if c.Tenancy == nil {
+		return fmt.Errorf("%w: cookie has no tenant", ErrTenantNotAncestor)
+	}
+	if id == c.Tenancy.ID {
+		return nil
+	}
+	if i := sort.SearchInts(c.Tenancy.Subtree, id); i < len(c.Tenancy.Subtree) && c.Tenancy.Subtree[i] == id {
+		return nil
+	}
+	return fmt.Errorf("%w: tenant %d is not in the subtree of tenant %d", ErrTenantNotAncestor, id, c.Tenancy.ID)
+}
+
+// TenantSubtreeCurrent returns whether the subtree of the cookie's tenant, the IDs of the tenants currently descending from it, is the one it was minted with, so a handler may scope requests by its copy of the tenant tree, or trust the cookie's embedded subtree. Cookies whose subtree has changed should be re-minted. It is false for cookies without a tenant.
+func (c *Cookie) TenantSubtreeCurrent(subtree []int) bool {
+	return c.Tenancy != nil && constantTimeEqual(c.Tenancy.Hash, TenantSubtreeHash(c.Tenancy.ID, subtree))
+}
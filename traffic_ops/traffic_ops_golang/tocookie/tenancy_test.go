@@ -0,0 +1,104 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestWithTenant(t *testing.T) {
+	secret := "secret"
+	expiration := time.Now().Add(time.Hour)
+	c, err := Parse(secret, New("alice", expiration, secret, WithTenant(2, 7, 3, 5)))
+	if err != nil {
+		t.Fatalf("Parse expected nil error, actual: %v", err)
+	}
+	if id, ok := c.TenantID(); !ok || id != 2 {
+		t.Errorf("TenantID expected 2, actual: %v %v", id, ok)
+	}
+	if !reflect.DeepEqual(c.Tenancy.Subtree, []int{3, 5, 7}) {
+		t.Errorf("WithTenant expected sorted subtree [3 5 7], actual: %v", c.Tenancy.Subtree)
+	}
+	for id, ok := range map[int]bool{2: true, 3: true, 5: true, 7: true, 1: false, 4: false, 8: false} {
+		if err := c.RequireTenantAncestor(id); (err == nil) != ok || err != nil && !errors.Is(err, ErrTenantNotAncestor) {
+			t.Errorf("RequireTenantAncestor of %v expected success %v, actual: %v", id, ok, err)
+		}
+	}
+	if !c.TenantSubtreeCurrent([]int{5, 7, 3}) || c.TenantSubtreeCurrent([]int{3, 5}) || c.TenantSubtreeCurrent([]int{3, 5, 7, 9}) {
+		t.Errorf("TenantSubtreeCurrent expected only the minted subtree current, in any order")
+	}
+
+	refreshed, err := Parse(secret, Refresh(c, secret, WithTenant(9)))
+	if err != nil || !reflect.DeepEqual(refreshed.Tenancy, c.Tenancy) {
+		t.Errorf("Refresh expected tenancy preserved, actual: %+v %v", refreshed.Tenancy, err)
+	}
+	if clone := c.Clone(); !reflect.DeepEqual(clone.Tenancy, c.Tenancy) || &clone.Tenancy.Subtree[0] == &c.Tenancy.Subtree[0] {
+		t.Errorf("Clone expected deep copy of tenancy, actual: %+v", clone.Tenancy)
+	}
+}
+
+func TestWithTenantHash(t *testing.T) {
+	secret := "secret"
+	subtree := []int{3, 5, 7}
+	c, err := Parse(secret, New("alice", time.Now().Add(time.Hour), secret, WithTenantHash(2, subtree...)))
+	if err != nil {
+		t.Fatalf("Parse expected nil error, actual: %v", err)
+	}
+	if c.Tenancy.Subtree != nil || c.Tenancy.Hash != TenantSubtreeHash(2, subtree) {
+		t.Errorf("WithTenantHash expected only the subtree hash, actual: %+v", c.Tenancy)
+	}
+	if err := c.RequireTenantAncestor(2); err != nil {
+		t.Errorf("RequireTenantAncestor of own tenant expected nil error, actual: %v", err)
+	}
+	if err := c.RequireTenantAncestor(3); !errors.Is(err, ErrTenantNotAncestor) {
+		t.Errorf("RequireTenantAncestor of descendant without subtree expected ErrTenantNotAncestor, actual: %v", err)
+	}
+	if !c.TenantSubtreeCurrent(subtree) {
+		t.Errorf("TenantSubtreeCurrent of minted subtree expected true, actual false")
+	}
+}
+
+func TestNoTenant(t *testing.T) {
+	c := &Cookie{AuthData: "alice"}
+	if _, ok := c.TenantID(); ok {
+		t.Errorf("TenantID of cookie without tenant expected false, actual true")
+	}
+	if err := c.RequireTenantAncestor(0); !errors.Is(err, ErrTenantNotAncestor) {
+		t.Errorf("RequireTenantAncestor of cookie without tenant expected ErrTenantNotAncestor, actual: %v", err)
+	}
+	if c.TenantSubtreeCurrent(nil) {
+		t.Errorf("TenantSubtreeCurrent of cookie without tenant expected false, actual true")
+	}
+}
+
+func TestTenantSubtreeHash(t *testing.T) {
+	tests := map[string]struct {
+		a, b  []int
+		idA   int
+		idB   int
+		equal bool
+	}{
+		"order":        {[]int{1, 2}, []int{2, 1}, 0, 0, true},
+		"member":       {[]int{1, 2}, []int{1, 3}, 0, 0, false},
+		"tenant":       {[]int{1, 2}, []int{1, 2}, 0, 4, false},
+		"no ambiguity": {[]int{12}, []int{1, 2}, 0, 0, false},
+	}
+	for name, test := range tests {
+		if equal := TenantSubtreeHash(test.idA, test.a) == TenantSubtreeHash(test.idB, test.b); equal != test.equal {
+			t.Errorf("%v: TenantSubtreeHash expected equal %v, actual: %v", name, test.equal, equal)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookietest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/tocookie"
+)
+
+// Epoch is the time a NewClock given the zero time starts at, so minted cookies, and the times in tests' expectations, are the same on every run.
+var Epoch = time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// Clock is a clock which only moves when it is told to, for tocookie.WithClock, so tests can mint cookies, and then parse them as they would be minutes or days later, without waiting. It is safe for concurrent use.
+type Clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewClock returns a Clock stopped at the given time, or at Epoch if it is zero.
+func NewClock(now time.Time) *Clock {
+	if now.IsZero() {
+		now = Epoch
+	}
+	return &Clock{now: now}
+}
+
+// Now returns the time of the clock.
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Set stops the clock at the given time.
+func (c *Clock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = now
+}
+
+// Advance moves the clock forward by d, or back if it is negative, and returns its new time.
+func (c *Clock) Advance(d time.Duration) time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	return c.now
+}
+
+// Option returns tocookie.WithClock of the clock, for minting and parsing cookies at its time.
+func (c *Clock) Option() tocookie.Option {
+	return tocookie.WithClock(c.Now)
+}
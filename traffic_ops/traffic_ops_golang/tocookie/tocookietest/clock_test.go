@@ -0,0 +1,47 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookietest
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/tocookie"
+)
+
+func TestClock(t *testing.T) {
+	clock := NewClock(time.Time{})
+	if !clock.Now().Equal(Epoch) {
+		t.Errorf("NewClock of zero time expected Epoch, actual: %v", clock.Now())
+	}
+	if now := clock.Advance(time.Hour); !now.Equal(Epoch.Add(time.Hour)) || !clock.Now().Equal(now) {
+		t.Errorf("Advance expected %v, actual: %v %v", Epoch.Add(time.Hour), now, clock.Now())
+	}
+	clock.Set(Epoch)
+
+	cookie := clock.MintValid(t, "alice")
+	if c, err := tocookie.Parse(Secret, cookie, clock.Option()); err != nil || !c.Expires().Equal(Epoch.Add(tocookie.DefaultDuration)) {
+		t.Errorf("Parse of Clock.MintValid expected cookie expiring at %v, actual: %+v %v", Epoch.Add(tocookie.DefaultDuration), c, err)
+	}
+	if _, err := tocookie.Parse(Secret, cookie); !errors.Is(err, tocookie.ErrExpired) {
+		t.Errorf("Parse of Clock.MintValid at the real time expected ErrExpired, actual: %v", err)
+	}
+	clock.Advance(tocookie.DefaultDuration + time.Second)
+	if _, err := tocookie.Parse(Secret, cookie, clock.Option()); !errors.Is(err, tocookie.ErrExpired) {
+		t.Errorf("Parse after Advance past expiry expected ErrExpired, actual: %v", err)
+	}
+	if _, err := tocookie.Parse(Secret, clock.MintExpired(t, "alice"), clock.Option()); !errors.Is(err, tocookie.ErrExpired) {
+		t.Errorf("Parse of Clock.MintExpired expected ErrExpired, actual: %v", err)
+	}
+}
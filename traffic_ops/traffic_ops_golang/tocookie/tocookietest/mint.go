@@ -0,0 +1,128 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookietest
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/tocookie"
+)
+
+// Secret is the secret of the cookies minted by MintValid, MintExpired, and NewFixtures, for services' tests to configure their handlers with, rather than each inventing one.
+const Secret = "tocookietest-secret"
+
+// User is the user of the cookies of NewFixtures.
+const User = "tocookietest-user"
+
+// ExpiredAgo is how long before they are minted the cookies of MintExpired expire, well beyond any leeway or expiry grace a service would configure.
+const ExpiredAgo = 24 * time.Hour
+
+// MintValid mints a cookie of the user signed with Secret, expiring tocookie.DefaultDuration from now, failing the test if it can't be minted, e.g. because the options conflict.
+func MintValid(tb testing.TB, user string, opts ...tocookie.Option) string {
+	tb.Helper()
+	return mint(tb, user, time.Now().Add(tocookie.DefaultDuration), opts)
+}
+
+// MintExpired mints a cookie of the user signed with Secret, which expired ExpiredAgo, failing the test if it can't be minted.
+func MintExpired(tb testing.TB, user string, opts ...tocookie.Option) string {
+	tb.Helper()
+	return mint(tb, user, time.Now().Add(-ExpiredAgo), opts)
+}
+
+// MintValid mints a cookie of the user signed with Secret at the time of the clock, expiring tocookie.DefaultDuration later, failing the test if it can't be minted. Parse it with the clock's Option, or it is judged by the real time.
+func (c *Clock) MintValid(tb testing.TB, user string, opts ...tocookie.Option) string {
+	tb.Helper()
+	return mint(tb, user, c.Now().Add(tocookie.DefaultDuration), append(opts[:len(opts):len(opts)], c.Option()))
+}
+
+// MintExpired mints a cookie of the user signed with Secret at the time of the clock, which expired ExpiredAgo before it, failing the test if it can't be minted.
+func (c *Clock) MintExpired(tb testing.TB, user string, opts ...tocookie.Option) string {
+	tb.Helper()
+	return mint(tb, user, c.Now().Add(-ExpiredAgo), append(opts[:len(opts):len(opts)], c.Option()))
+}
+
+func mint(tb testing.TB, user string, expiration time.Time, opts []tocookie.Option) string {
+	tb.Helper()
+	cookie := tocookie.New(user, expiration, Secret, opts...)
+	if cookie == "" {
+		tb.Fatalf("minting cookie of user '%v' failed", user)
+	}
+	return cookie
+}
+
+// Fixtures are cookies of each outcome of tocookie.Parse with Secret, for table tests of the responses of authenticated handlers.
+type Fixtures struct {
+	// Valid is a cookie of User which is accepted.
+	Valid string
+	// Expired is an authentic cookie of User which expired ExpiredAgo.
+	Expired string
+	// Forged is a cookie of User signed with a secret other than Secret.
+	Forged string
+	// Malformed isn't a cookie at all.
+	Malformed string
+}
+
+// NewFixtures mints Fixtures with the options, failing the test if they can't be minted.
+func NewFixtures(tb testing.TB, opts ...tocookie.Option) Fixtures {
+	tb.Helper()
+	forged := tocookie.New(User, time.Now().Add(tocookie.DefaultDuration), Secret+"-forged", opts...)
+	if forged == "" {
+		tb.Fatalf("minting forged cookie failed")
+	}
+	return Fixtures{
+		Valid:     MintValid(tb, User, opts...),
+		Expired:   MintExpired(tb, User, opts...),
+		Forged:    forged,
+		Malformed: "not-a-cookie",
+	}
+}
+
+// GoldenVector is a cookie minted by a past version of tocookie, and the outcome of parsing it at a fixed time, from a corpus in this package's testdata, so services can check that the cookies their users hold still parse as they did after upgrading tocookie, or changing its options.
+type GoldenVector struct {
+	// Name describes what the vector covers.
+	Name string `json:"name"`
+	// Secret is the secret the cookie is parsed with.
+	Secret string `json:"secret"`
+	// Cookie is the cookie value.
+	Cookie string `json:"cookie"`
+	// Now is the time the cookie is parsed at, for tocookie.WithClock.
+	Now time.Time `json:"now"`
+	// User is the AuthData of the cookie, if it is valid.
+	User string `json:"user,omitempty"`
+	// Outcome is the tocookie.Outcome of parsing the cookie, as its String.
+	Outcome string `json:"outcome"`
+}
+
+// GoldenVectors reads the corpus of golden vectors in this package's testdata. It finds the corpus by the path of this package's source, so it only works where the source is.
+func GoldenVectors() ([]GoldenVector, error) {
+	_, file, _, ok := runtime.Caller(0)
+	if !ok {
+		return nil, fmt.Errorf("locating golden vectors: no caller information")
+	}
+	path := filepath.Join(filepath.Dir(file), "testdata", "golden_vectors.json")
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading golden vectors: %w", err)
+	}
+	vectors := []GoldenVector{}
+	if err := json.Unmarshal(b, &vectors); err != nil {
+		return nil, fmt.Errorf("decoding golden vectors '%s': %w", path, err)
+	}
+	return vectors, nil
+}
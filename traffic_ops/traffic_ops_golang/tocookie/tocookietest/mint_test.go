@@ -0,0 +1,69 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookietest
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/tocookie"
+)
+
+func TestMint(t *testing.T) {
+	if c, err := tocookie.Parse(Secret, MintValid(t, "alice", tocookie.WithRoles("admin"))); err != nil || c.AuthData != "alice" || !c.HasRole("admin") {
+		t.Errorf("Parse of MintValid expected alice with role admin, actual: %+v %v", c, err)
+	}
+	if _, err := tocookie.Parse(Secret, MintExpired(t, "alice"), tocookie.WithLeeway(time.Hour), tocookie.WithExpiryGrace(time.Hour)); !errors.Is(err, tocookie.ErrExpired) {
+		t.Errorf("Parse of MintExpired expected ErrExpired, actual: %v", err)
+	}
+}
+
+func TestFixtures(t *testing.T) {
+	fixtures := NewFixtures(t, tocookie.WithRoles("admin"))
+	tests := map[string]struct {
+		cookie  string
+		outcome tocookie.Outcome
+	}{
+		"valid":     {fixtures.Valid, tocookie.OutcomeValid},
+		"expired":   {fixtures.Expired, tocookie.OutcomeExpired},
+		"forged":    {fixtures.Forged, tocookie.OutcomeInvalid},
+		"malformed": {fixtures.Malformed, tocookie.OutcomeInvalid},
+	}
+	for name, test := range tests {
+		c, err := tocookie.Parse(Secret, test.cookie)
+		if outcome := tocookie.Classify(err); outcome != test.outcome {
+			t.Errorf("%v: Parse expected %v, actual: %v %v", name, test.outcome, outcome, err)
+		}
+		if err == nil && (c.AuthData != User || !c.HasRole("admin")) {
+			t.Errorf("%v: Parse expected %v with role admin, actual: %+v", name, User, c)
+		}
+	}
+}
+
+func TestGoldenVectors(t *testing.T) {
+	vectors, err := GoldenVectors()
+	if err != nil || len(vectors) == 0 {
+		t.Fatalf("GoldenVectors expected vectors, actual: %v %v", vectors, err)
+	}
+	for _, vector := range vectors {
+		clock := NewClock(vector.Now)
+		c, err := tocookie.Parse(vector.Secret, vector.Cookie, clock.Option())
+		if outcome := tocookie.Classify(err).String(); outcome != vector.Outcome {
+			t.Errorf("%v: Parse expected %v, actual: %v %v", vector.Name, vector.Outcome, outcome, err)
+		}
+		if err == nil && c.AuthData != vector.User {
+			t.Errorf("%v: Parse expected user %v, actual: %v", vector.Name, vector.User, c.AuthData)
+		}
+	}
+}
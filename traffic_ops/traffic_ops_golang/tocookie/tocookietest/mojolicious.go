@@ -0,0 +1,166 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookietest
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// MojoliciousFixture is a session cookie minted by Perl Mojolicious, from a corpus written by testdata/gen_mojolicious_cookies.pl.
+type MojoliciousFixture struct {
+	// Name describes what the fixture covers.
+	Name string `json:"name"`
+	// Secret is the secret the cookie is signed with.
+	Secret string `json:"secret"`
+	// Session is the session exactly as Mojolicious serialized it, which is the payload of the cookie.
+	Session string `json:"session"`
+	// Cookie is the cookie value.
+	Cookie string `json:"cookie"`
+	// Generator is the Mojolicious version which minted the cookie, or the replica of it the generator has when Mojolicious isn't installed.
+	Generator string `json:"generator"`
+}
+
+// LoadMojoliciousCorpus reads a corpus of Perl-minted cookies written by testdata/gen_mojolicious_cookies.pl.
+func LoadMojoliciousCorpus(path string) ([]MojoliciousFixture, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading mojolicious corpus: %w", err)
+	}
+	fixtures := []MojoliciousFixture{}
+	if err := json.Unmarshal(b, &fixtures); err != nil {
+		return nil, fmt.Errorf("decoding mojolicious corpus '%s': %w", path, err)
+	}
+	return fixtures, nil
+}
+
+// MojoliciousCorpus reads the corpus of Perl-minted cookies in this package's testdata, for tests of other packages. It finds the corpus by the path of this package's source, so it only works where the source is.
+func MojoliciousCorpus() ([]MojoliciousFixture, error) {
+	_, file, _, ok := runtime.Caller(0)
+	if !ok {
+		return nil, fmt.Errorf("locating mojolicious corpus: no caller information")
+	}
+	return LoadMojoliciousCorpus(filepath.Join(filepath.Dir(file), "testdata", "mojolicious_cookies.json"))
+}
+
+// NewMojoliciousCookie mints a session cookie byte for byte as Mojolicious::Sessions of Mojolicious 7.x does: the session is serialized as EncodeMojoliciousJSON does, base64 encoded with its '=' padding replaced by '-', and followed by "--" and the hex HMAC-SHA1 of the encoded session with the secret. Unlike tocookie.New, it writes the session as it is given, without adding claims, so it can mint any cookie Perl could.
+func NewMojoliciousCookie(session map[string]interface{}, secret string) (string, error) {
+	serialized, err := EncodeMojoliciousJSON(session)
+	if err != nil {
+		return "", err
+	}
+	value := strings.Replace(base64.StdEncoding.EncodeToString(serialized), "=", "-", -1)
+	mac := hmac.New(sha1.New, []byte(secret))
+	mac.Write([]byte(value))
+	return value + "--" + hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// EncodeMojoliciousJSON serializes the value as Mojo::JSON::encode_json does, which differs from encoding/json: object keys are sorted, there is no HTML escaping, '/' is escaped, other control characters are escaped with uppercase hex, and numbers are written as Perl prints them. The value must be made of maps with string keys, slices, strings, bools, nil, json.Number, and Go's numeric types, as json.Unmarshal into an interface{} returns them.
+func EncodeMojoliciousJSON(v interface{}) ([]byte, error) {
+	buf := bytes.Buffer{}
+	if err := encodeMojoValue(&buf, v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func encodeMojoValue(buf *bytes.Buffer, v interface{}) error {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		buf.WriteByte('{')
+		for i, key := range keys {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			encodeMojoString(buf, key)
+			buf.WriteByte(':')
+			if err := encodeMojoValue(buf, v[key]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	case []interface{}:
+		buf.WriteByte('[')
+		for i, elem := range v {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := encodeMojoValue(buf, elem); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	case string:
+		encodeMojoString(buf, v)
+	case bool:
+		buf.WriteString(strconv.FormatBool(v))
+	case nil:
+		buf.WriteString("null")
+	case json.Number:
+		buf.WriteString(v.String())
+	case int:
+		buf.WriteString(strconv.Itoa(v))
+	case int64:
+		buf.WriteString(strconv.FormatInt(v, 10))
+	case float64:
+		buf.WriteString(perlNumber(v))
+	default:
+		return fmt.Errorf("encoding mojolicious json: unsupported type %T", v)
+	}
+	return nil
+}
+
+// perlNumber formats a float as Perl stringifies numbers, with "%.15g", except that integers are written in full, as Perl stores them as integers.
+func perlNumber(f float64) string {
+	if f == math.Trunc(f) && math.Abs(f) < 1<<53 {
+		return strconv.FormatInt(int64(f), 10)
+	}
+	return strconv.FormatFloat(f, 'g', 15, 64)
+}
+
+// mojoEscapes are the escapes of Mojo::JSON for the characters it escapes by name.
+var mojoEscapes = map[rune]string{'"': `\"`, '\\': `\\`, '/': `\/`, '\b': `\b`, '\f': `\f`, '\n': `\n`, '\r': `\r`, '\t': `\t`, '\u2028': `\u2028`, '\u2029': `\u2029`}
+
+func encodeMojoString(buf *bytes.Buffer, s string) {
+	buf.WriteByte('"')
+	for len(s) > 0 {
+		r, size := utf8.DecodeRuneInString(s)
+		if escape, ok := mojoEscapes[r]; ok {
+			buf.WriteString(escape)
+		} else if r < 0x20 {
+			fmt.Fprintf(buf, `\u%04X`, r)
+		} else {
+			buf.WriteString(s[:size])
+		}
+		s = s[size:]
+	}
+	buf.WriteByte('"')
+}
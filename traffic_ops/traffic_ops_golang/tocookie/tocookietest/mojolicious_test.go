@@ -0,0 +1,89 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookietest
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/tocookie"
+)
+
+func TestMojoliciousCorpus(t *testing.T) {
+	fixtures, err := MojoliciousCorpus()
+	if err != nil || len(fixtures) == 0 {
+		t.Fatalf("MojoliciousCorpus expected fixtures, actual: %v %v", fixtures, err)
+	}
+	for _, fixture := range fixtures {
+		decoder := json.NewDecoder(bytes.NewReader([]byte(fixture.Session)))
+		decoder.UseNumber()
+		session := map[string]interface{}{}
+		if err := decoder.Decode(&session); err != nil {
+			t.Errorf("%v: decoding session expected nil error, actual: %v", fixture.Name, err)
+			continue
+		}
+		if cookie, err := NewMojoliciousCookie(session, fixture.Secret); err != nil || cookie != fixture.Cookie {
+			t.Errorf("%v: NewMojoliciousCookie expected Perl's cookie '%v', actual: '%v' %v", fixture.Name, fixture.Cookie, cookie, err)
+		}
+
+		for mode, opts := range map[string][]tocookie.Option{"compat": nil, "strict": {tocookie.WithStrict()}} {
+			c, err := tocookie.Parse(fixture.Secret, fixture.Cookie, opts...)
+			if err != nil {
+				t.Errorf("%v: Parse %v expected nil error, actual: %v", fixture.Name, mode, err)
+				continue
+			}
+			if string(c.RawPayload()) != fixture.Session {
+				t.Errorf("%v: Parse %v expected payload '%v', actual: '%v'", fixture.Name, mode, fixture.Session, string(c.RawPayload()))
+			}
+			if c.AuthData != session["auth_data"] {
+				t.Errorf("%v: Parse %v expected user %v, actual: %v", fixture.Name, mode, session["auth_data"], c.AuthData)
+			}
+		}
+		if _, err := tocookie.Parse(fixture.Secret+"x", fixture.Cookie, tocookie.WithStrict()); err == nil {
+			t.Errorf("%v: Parse with wrong secret expected error, actual nil", fixture.Name)
+		}
+
+		c, _ := tocookie.Parse(fixture.Secret, fixture.Cookie)
+		refreshed, err := tocookie.Parse(fixture.Secret, tocookie.Refresh(c, fixture.Secret), tocookie.WithStrict())
+		if err != nil {
+			t.Errorf("%v: Parse of refreshed cookie expected nil error, actual: %v", fixture.Name, err)
+		} else if refreshed.AuthData != c.AuthData || !reflect.DeepEqual(refreshed.Extra, c.Extra) {
+			t.Errorf("%v: Refresh expected session preserved, actual: %+v", fixture.Name, refreshed)
+		}
+	}
+}
+
+func TestEncodeMojoliciousJSON(t *testing.T) {
+	tests := map[string]struct {
+		value    interface{}
+		expected string
+	}{
+		"sorted":  {map[string]interface{}{"b": 1, "a": []interface{}{true, nil, "x"}}, `{"a":[true,null,"x"],"b":1}`},
+		"escapes": {"</a>\u2028\x7f\x1f\"", `"<\/a>\u2028` + "\x7f" + `\u001F\""`},
+		"numbers": {[]interface{}{1.0, 0.5, 1e21, int64(4102444800), json.Number("25")}, `[1,0.5,1e+21,4102444800,25]`},
+		"unicode": {"zoë", `"zoë"`},
+		"html":    {"&<>", `"&<>"`},
+		"empty":   {map[string]interface{}{}, `{}`},
+		"nested":  {map[string]interface{}{"a": map[string]interface{}{"c": 1, "b": 2}}, `{"a":{"b":2,"c":1}}`},
+	}
+	for name, test := range tests {
+		if actual, err := EncodeMojoliciousJSON(test.value); err != nil || string(actual) != test.expected {
+			t.Errorf("%v: EncodeMojoliciousJSON expected '%v', actual: '%v' %v", name, test.expected, string(actual), err)
+		}
+	}
+	if _, err := EncodeMojoliciousJSON(struct{}{}); err == nil {
+		t.Errorf("EncodeMojoliciousJSON of struct expected error, actual nil")
+	}
+}
@@ -0,0 +1,280 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookietest
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// RedisEntry is a key stored by a RedisServer.
+type RedisEntry struct {
+	Value  string
+	Expiry time.Time
+}
+
+// RedisServer is a fake Redis server, serving the subset of the Redis protocol the stores of tocookie/redissession and tocookie/redisrevocation use from memory, as a standalone server, a sentinel, or a Cluster node, so they can be tested without Redis. Its fields configure it; change them with Set once it is serving.
+type RedisServer struct {
+	// Password, if it isn't empty, must be given with AUTH before any other command.
+	Password string
+	// Role is the reply to ROLE. NewRedisServer sets it to "master".
+	Role string
+	// Master is the address the server, as a sentinel, reports for the master "mymaster".
+	Master string
+	// Slots is the reply to CLUSTER SLOTS, as RESP, and Owned the slots the server serves, if it is a Cluster node; see RedisClusterSlots.
+	Slots string
+	Owned func(slot int) bool
+	// Importing are the slots the server serves after ASKING.
+	Importing func(slot int) bool
+	// Redirect is the kind and address of the redirects of keys the server doesn't serve, e.g. "MOVED 127.0.0.1:7001".
+	Redirect string
+
+	l       net.Listener
+	mu      sync.Mutex
+	keys    map[string]RedisEntry
+	flushes int
+	conns   []net.Conn
+}
+
+// NewRedisServer returns a RedisServer listening on a local port, which is closed when the test ends.
+func NewRedisServer(tb testing.TB) *RedisServer {
+	tb.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		tb.Fatalf("listening: %v", err)
+	}
+	s := &RedisServer{Role: "master", l: l, keys: map[string]RedisEntry{}}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.conns = append(s.conns, conn)
+			s.mu.Unlock()
+			go s.serve(conn)
+		}
+	}()
+	tb.Cleanup(s.Close)
+	return s
+}
+
+// Addr returns the host:port address of the server.
+func (s *RedisServer) Addr() string {
+	return s.l.Addr().String()
+}
+
+// Close stops the server accepting connections, and closes those it has, so it is unavailable.
+func (s *RedisServer) Close() {
+	s.l.Close()
+	s.Drop()
+}
+
+// Drop closes the connections to the server, which keeps accepting new ones.
+func (s *RedisServer) Drop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+	s.conns = nil
+}
+
+// Set configures the server while it is serving.
+func (s *RedisServer) Set(configure func(s *RedisServer)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	configure(s)
+}
+
+// Key returns the key, whether or not it has expired, and whether the server has it.
+func (s *RedisServer) Key(key string) (RedisEntry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.keys[key]
+	return e, ok
+}
+
+// Keys returns a copy of the keys of the server, whether or not they have expired.
+func (s *RedisServer) Keys() map[string]RedisEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := make(map[string]RedisEntry, len(s.keys))
+	for key, e := range s.keys {
+		keys[key] = e
+	}
+	return keys
+}
+
+// Flushes returns the number of times the server has written replies, which is the number of round trips of clients which pipeline their commands.
+func (s *RedisServer) Flushes() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.flushes
+}
+
+// RedisBulkString returns the string as a RESP bulk string.
+func RedisBulkString(str string) string {
+	return "$" + strconv.Itoa(len(str)) + "\r\n" + str + "\r\n"
+}
+
+// RedisNumSlots is the number of hash slots of a Redis Cluster.
+const RedisNumSlots = 16384
+
+// RedisClusterSlots returns the reply to CLUSTER SLOTS of slots 0 to split-1 served by a, and the rest by b.
+func RedisClusterSlots(split int, a, b *RedisServer) string {
+	reply := "*2\r\n"
+	for _, r := range []struct {
+		start, end int
+		s          *RedisServer
+	}{{0, split - 1, a}, {split, RedisNumSlots - 1, b}} {
+		_, port, _ := net.SplitHostPort(r.s.Addr())
+		reply += fmt.Sprintf("*3\r\n:%d\r\n:%d\r\n*2\r\n%s:%s\r\n", r.start, r.end, RedisBulkString(""), port)
+	}
+	return reply
+}
+
+// RedisKeySlot returns the Cluster hash slot of the key, as Redis computes it: the CRC16 of the key, or of its hash tag, modulo RedisNumSlots.
+func RedisKeySlot(key string) int {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			key = key[start+1 : start+1+end]
+		}
+	}
+	crc := uint16(0)
+	for i := 0; i < len(key); i++ {
+		crc ^= uint16(key[i]) << 8
+		for bit := 0; bit < 8; bit++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return int(crc) % RedisNumSlots
+}
+
+func (s *RedisServer) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+	s.mu.Lock()
+	authed := s.Password == ""
+	s.mu.Unlock()
+	asking := false
+	for {
+		args, err := readRedisCommand(r)
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		reply := "-ERR unknown command\r\n"
+		slot := -1
+		if len(args) > 1 {
+			slot = RedisKeySlot(args[1])
+		}
+		switch cmd := strings.ToUpper(args[0]); {
+		case cmd == "AUTH":
+			if authed = len(args) == 2 && args[1] == s.Password; authed {
+				reply = "+OK\r\n"
+			} else {
+				reply = "-WRONGPASS invalid password\r\n"
+			}
+		case !authed:
+			reply = "-NOAUTH Authentication required.\r\n"
+		case cmd == "ASKING":
+			asking, reply = true, "+OK\r\n"
+		case cmd == "ROLE":
+			reply = "*1\r\n" + RedisBulkString(s.Role)
+		case cmd == "SENTINEL" && len(args) == 3 && args[2] == "mymaster":
+			host, port, _ := net.SplitHostPort(s.Master)
+			reply = "*2\r\n" + RedisBulkString(host) + RedisBulkString(port)
+		case cmd == "SENTINEL":
+			reply = "*-1\r\n"
+		case cmd == "CLUSTER" && s.Slots != "":
+			reply = s.Slots
+		case s.Owned != nil && !s.Owned(slot) && !(asking && s.Importing != nil && s.Importing(slot)):
+			reply = fmt.Sprintf("-%s %d %s\r\n", strings.Fields(s.Redirect)[0], slot, strings.Fields(s.Redirect)[1])
+		case cmd == "SET" && len(args) == 5 && strings.ToUpper(args[3]) == "PX":
+			ms, _ := strconv.Atoi(args[4])
+			s.keys[args[1]] = RedisEntry{Value: args[2], Expiry: time.Now().Add(time.Duration(ms) * time.Millisecond)}
+			reply = "+OK\r\n"
+		case cmd == "GET" && len(args) == 2:
+			reply = "$-1\r\n"
+			if e, ok := s.keys[args[1]]; ok && time.Now().Before(e.Expiry) {
+				reply = RedisBulkString(e.Value)
+			}
+		case cmd == "EXISTS" && len(args) == 2:
+			reply = ":0\r\n"
+			if e, ok := s.keys[args[1]]; ok && time.Now().Before(e.Expiry) {
+				reply = ":1\r\n"
+			}
+		case cmd == "DEL" && len(args) == 2:
+			_, ok := s.keys[args[1]]
+			delete(s.keys, args[1])
+			reply = ":0\r\n"
+			if ok {
+				reply = ":1\r\n"
+			}
+		}
+		if cmd := strings.ToUpper(args[0]); cmd != "ASKING" && cmd != "AUTH" {
+			asking = false
+		}
+		flush := r.Buffered() == 0
+		if flush {
+			s.flushes++
+		}
+		s.mu.Unlock()
+		if _, err := io.WriteString(w, reply); err != nil {
+			return
+		}
+		if flush {
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// readRedisCommand reads a command, an array of bulk strings.
+func readRedisCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+	if err != nil || n < 1 {
+		return nil, fmt.Errorf("bad command '%s'", line)
+	}
+	args := make([]string, n)
+	for i := range args {
+		if _, err := r.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		arg, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		args[i] = strings.TrimSuffix(arg, "\r\n")
+	}
+	return args, nil
+}
@@ -0,0 +1,83 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookietest
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRedisServer(t *testing.T) {
+	s := NewRedisServer(t)
+	s.Set(func(s *RedisServer) { s.Password = "hunter2" })
+	conn, err := net.Dial("tcp", s.Addr())
+	if err != nil {
+		t.Fatalf("dialing expected nil error, actual: %v", err)
+	}
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	tests := []struct {
+		cmd      []string
+		expected string
+	}{
+		{[]string{"EXISTS", "k"}, "-NOAUTH Authentication required.\r\n"},
+		{[]string{"AUTH", "hunter2"}, "+OK\r\n"},
+		{[]string{"SET", "k", "v", "PX", "60000"}, "+OK\r\n"},
+		{[]string{"GET", "k"}, "$1\r\nv\r\n"},
+		{[]string{"EXISTS", "k"}, ":1\r\n"},
+		{[]string{"DEL", "k"}, ":1\r\n"},
+		{[]string{"GET", "k"}, "$-1\r\n"},
+		{[]string{"ROLE"}, "*1\r\n$6\r\nmaster\r\n"},
+	}
+	for _, test := range tests {
+		cmd := "*" + string(rune('0'+len(test.cmd))) + "\r\n"
+		for _, arg := range test.cmd {
+			cmd += RedisBulkString(arg)
+		}
+		if _, err := conn.Write([]byte(cmd)); err != nil {
+			t.Fatalf("writing %v expected nil error, actual: %v", test.cmd, err)
+		}
+		actual := ""
+		for lines := strings.Count(test.expected, "\r\n"); lines > 0; lines-- {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				t.Fatalf("reading reply to %v expected nil error, actual: %v", test.cmd, err)
+			}
+			actual += line
+		}
+		if actual != test.expected {
+			t.Errorf("%v expected reply %q, actual: %q", test.cmd, test.expected, actual)
+		}
+	}
+	if e, ok := s.Key("k"); ok {
+		t.Errorf("Key of deleted key expected none, actual: %+v", e)
+	}
+
+	s.Close()
+	if conn, err := net.DialTimeout("tcp", s.Addr(), time.Second); err == nil {
+		conn.Close()
+		t.Errorf("dialing closed server expected error, actual nil")
+	}
+}
+
+func TestRedisKeySlot(t *testing.T) {
+	// the slots CLUSTER KEYSLOT returns.
+	for key, expected := range map[string]int{"foo": 12182, "somekey": 11058, "{foo}.bar": 12182} {
+		if actual := RedisKeySlot(key); actual != expected {
+			t.Errorf("RedisKeySlot of %v expected %v, actual: %v", key, expected, actual)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+#!/usr/bin/env perl
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+# Generates mojolicious_cookies.json, the corpus of Perl-minted session
+# cookies tocookietest.LoadMojoliciousCorpus reads:
+#
+#   perl gen_mojolicious_cookies.pl > mojolicious_cookies.json
+#
+# Cookies are minted as Mojolicious::Sessions::store and
+# Mojolicious::Controller::signed_cookie of Mojolicious 7.x do: the session
+# is serialized with Mojo::JSON, base64 encoded without line breaks, its '='
+# padding replaced by '-', and followed by "--" and the hex HMAC-SHA1 of the
+# encoded session with the first secret. If Mojolicious is installed, its
+# Mojo::JSON and Mojo::Util are used, and the corpus records its version;
+# otherwise a replica of them in core Perl is.
+
+use strict;
+use warnings;
+
+use Digest::SHA qw(hmac_sha1_hex);
+use Encode qw(decode_utf8 encode_utf8);
+use JSON::PP;
+use MIME::Base64 qw(encode_base64);
+
+my $generator = 'Mojolicious 7.x replica';
+my ($encode_json, $b64_encode, $hmac_sha1_sum) = (\&encode_mojo_json, \&encode_base64, \&hmac_sha1_hex);
+if (eval { require Mojolicious; require Mojo::JSON; require Mojo::Util; 1 }) {
+	$generator = "Mojolicious $Mojolicious::VERSION";
+	($encode_json, $b64_encode, $hmac_sha1_sum) = (\&Mojo::JSON::encode_json, \&Mojo::Util::b64_encode, \&Mojo::Util::hmac_sha1_sum);
+}
+
+# The sessions of the corpus. Numbers are numbers and strings are strings, as
+# Mojo::JSON tells them apart by how Perl last used them.
+my $far = 4102444800;
+my @fixtures = (
+	['traffic ops', 'mysecret', {auth_data => 'admin', expires => $far}],
+	['one padding', 'mysecret', {auth_data => 'admin12', expires => $far}],
+	['two padding', 'mysecret', {auth_data => 'operator1', expires => $far}],
+	['base64url characters', 'mysecret', {auth_data => 'bob???', expires => $far}],
+	['escaped slash', 'mysecret', {auth_data => 'cdn/admin', expires => $far}],
+	['control characters', 'mysecret', {auth_data => "tab\tnew\nline\x01", expires => $far}],
+	['unicode', 'mysecret', {auth_data => "zo\x{eb} \x{2603}", expires => $far}],
+	['line separators', 'mysecret', {auth_data => "a\x{2028}b\x{2029}c", expires => $far}],
+	['html', 'mysecret', {auth_data => '<script>&amp;</script>', expires => $far}],
+	['flash', 'mysecret', {auth_data => 'admin', expires => $far, new_flash => {message => 'Saved'}, flash => {}}],
+	['expiration', 'mysecret', {auth_data => 'admin', expires => $far, expiration => 86400}],
+	['nested', 'mysecret', {auth_data => 'admin', expires => $far, prefs => {cdns => ['cdn1', 'cdn2'], limit => 25, ratio => 0.5, on => JSON::PP::true, off => JSON::PP::false, none => undef}}],
+	['other secret', 'another secret', {auth_data => 'admin', expires => $far}],
+	['empty user', 'mysecret', {auth_data => '', expires => $far}],
+	['long', 'mysecret', {auth_data => 'admin', expires => $far, note => 'x' x 300}],
+);
+
+my @corpus;
+for my $fixture (@fixtures) {
+	my ($name, $secret, $session) = @$fixture;
+	my $serialized = $encode_json->($session);
+	my $value = $b64_encode->($serialized, '');
+	$value =~ y/=/-/;
+	push @corpus, {
+		name      => $name,
+		secret    => $secret,
+		session   => decode_utf8($serialized),
+		cookie    => "$value--" . $hmac_sha1_sum->($value, $secret),
+		generator => $generator,
+	};
+}
+print JSON::PP->new->utf8->canonical->pretty->encode(\@corpus);
+
+# encode_mojo_json is Mojo::JSON::encode_json: sorted keys, no whitespace,
+# '/' and U+2028 and U+2029 escaped, other characters as UTF-8.
+sub encode_mojo_json { encode_utf8(encode_mojo_value(shift)) }
+
+sub encode_mojo_value {
+	my $value = shift;
+	my $ref = ref $value;
+	if ($ref eq 'HASH') {
+		return '{' . join(',', map { encode_mojo_string($_) . ':' . encode_mojo_value($value->{$_}) } sort keys %$value) . '}';
+	}
+	if ($ref eq 'ARRAY') {
+		return '[' . join(',', map { encode_mojo_value($_) } @$value) . ']';
+	}
+	return $value ? 'true' : 'false' if JSON::PP::is_bool($value);
+	return 'null' unless defined $value;
+	my $flags = B::svref_2object(\$value)->FLAGS;
+	return 0 + $value if $flags & (B::SVp_IOK() | B::SVp_NOK()) && !($flags & B::SVp_POK());
+	return encode_mojo_string($value);
+}
+
+my %reverse;
+BEGIN {
+	require B;
+	%reverse = ('"' => '\"', '\\' => '\\\\', '/' => '\/', "\x08" => '\b', "\x0c" => '\f', "\x0a" => '\n', "\x0d" => '\r', "\x09" => '\t', "\x{2028}" => '\u2028', "\x{2029}" => '\u2029');
+	$reverse{pack 'C', $_} //= sprintf '\u%.4X', $_ for 0x00 .. 0x1f;
+}
+
+sub encode_mojo_string {
+	my $str = shift;
+	$str =~ s!([\x00-\x1f\x{2028}\x{2029}\\"/])!$reverse{$1}!gs;
+	return "\"$str\"";
+}
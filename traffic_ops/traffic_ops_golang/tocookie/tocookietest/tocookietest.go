@@ -0,0 +1,56 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package tocookietest provides utilities for tests of handlers authenticated by tocookie: a Clock which only moves when told to, cookies of a fixed Secret minted by MintValid, MintExpired, and NewFixtures, golden vectors of past versions, a Jar for end-to-end tests, and a fake RedisServer for tests of the Redis stores.
+package tocookietest
+
+import (
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"time"
+
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/tocookie"
+)
+
+// Jar is an http.CookieJar which can be seeded with cookies minted by tocookie. An http.Client using it carries the cookie on every request, and picks up refreshed cookies from Set-Cookie headers, just as a browser would.
+type Jar struct {
+	*cookiejar.Jar
+}
+
+// NewJar returns an empty Jar.
+func NewJar() *Jar {
+	jar, _ := cookiejar.New(nil) // only errors on invalid options
+	return &Jar{Jar: jar}
+}
+
+// Login mints a cookie for the user, as a successful login would, and stores it in the jar for the given URL. It returns the cookie value.
+func (j *Jar) Login(u *url.URL, user, secret string, expiration time.Time, opts ...tocookie.Option) string {
+	value := tocookie.New(user, expiration, secret, opts...)
+	j.SetCookies(u, []*http.Cookie{{Name: tocookie.Name, Value: value, Path: "/", HttpOnly: true}})
+	return value
+}
+
+// Value returns the value of the tocookie cookie the jar would send to the given URL, or the empty string if there is none.
+func (j *Jar) Value(u *url.URL) string {
+	for _, c := range j.Cookies(u) {
+		if c.Name == tocookie.Name {
+			return c.Value
+		}
+	}
+	return ""
+}
+
+// Client returns an http.Client using the jar.
+func (j *Jar) Client() *http.Client {
+	return &http.Client{Jar: j}
+}
@@ -0,0 +1,59 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookietest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/tocookie"
+)
+
+func TestJar(t *testing.T) {
+	secret := "secret"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cookie, err := r.Cookie(tocookie.Name)
+		if err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		c, err := tocookie.Parse(secret, cookie.Value)
+		if err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: tocookie.Name, Value: tocookie.Refresh(c, secret), Path: "/", HttpOnly: true})
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+
+	jar := NewJar()
+	if resp, err := jar.Client().Get(srv.URL); err != nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("request without login expected 401, actual: %v %v", resp, err)
+	}
+
+	login := jar.Login(u, "alice", secret, time.Now().Add(time.Minute))
+	if jar.Value(u) != login {
+		t.Errorf("Value expected login cookie '%v', actual: '%v'", login, jar.Value(u))
+	}
+	resp, err := jar.Client().Get(srv.URL)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("request after login expected 200, actual: %v %v", resp, err)
+	}
+	if jar.Value(u) == "" || jar.Value(u) == login {
+		t.Errorf("Value expected refreshed cookie, actual: '%v'", jar.Value(u))
+	}
+}
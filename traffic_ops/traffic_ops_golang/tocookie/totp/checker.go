@@ -0,0 +1,235 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package totp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/tocookie/login"
+)
+
+// DefaultMaxFailures is the failed codes after which Checker locks a user out for DefaultLockout. With DefaultSkew, each code has a 3 in a million chance of being right, so 5 failures give a guesser a 1 in 66,000 chance per lockout.
+const DefaultMaxFailures = 5
+
+// DefaultLockout is how long Checker locks users out for after DefaultMaxFailures failed codes.
+const DefaultLockout = 15 * time.Minute
+
+// ErrNotEnrolled is returned by Store for users who haven't enrolled, and by Checker for those who haven't confirmed their enrollment.
+var ErrNotEnrolled = errors.New("user not enrolled in two-factor authentication")
+
+// ErrLocked is returned by Checker for users who failed too many codes, until their lockout is over.
+var ErrLocked = errors.New("too many failed two-factor codes")
+
+// Enrollment is the two-factor enrollment of a user.
+type Enrollment struct {
+	// Secret is the base32 TOTP secret of the user's device.
+	Secret string
+	// Confirmed is whether the user has given a code of the secret, proving their device has it. Only confirmed enrollments are required, or accepted, at login.
+	Confirmed bool
+	// RecoveryHashes are the hashes of the user's unused recovery codes; see HashRecoveryCode.
+	RecoveryHashes []string
+	// LastStep is the step of the last code accepted, so codes can't be replayed.
+	LastStep int64
+	// Failures counts the failed codes since the last accepted one, and LastFailure is when the last failed, for the lockout of Checker.
+	Failures    int
+	LastFailure time.Time
+}
+
+// Store stores the enrollments of users. Implementations must be safe for concurrent use. MemoryStore is a Store for a single server.
+type Store interface {
+	// Load returns the enrollment of the user, or an error wrapping ErrNotEnrolled if there is none.
+	Load(ctx context.Context, user string) (*Enrollment, error)
+	// Save stores the enrollment of the user, replacing any they had.
+	Save(ctx context.Context, user string, e Enrollment) error
+	// Update calls update with the enrollment of the user, and stores it as update leaves it if update returns nil, atomically, so concurrent logins can't both accept a code. It returns the error of update, or one wrapping ErrNotEnrolled if the user has no enrollment.
+	Update(ctx context.Context, user string, update func(e *Enrollment) error) error
+	// Delete deletes the enrollment of the user, if they have one, e.g. when an administrator resets their second factor.
+	Delete(ctx context.Context, user string) error
+}
+
+// Checker enrolls users in two-factor authentication, and checks their codes. It implements login.TwoFactor.
+type Checker struct {
+	// Issuer is the issuer of the provisioning URIs of enrollments, which authenticator apps show to tell accounts apart, e.g. "Traffic Ops".
+	Issuer string
+	// Skew is the steps of drift Validate allows; see DefaultSkew.
+	Skew int
+	// MaxFailures and Lockout are the failed codes after which users are locked out, and for how long after the last failure.
+	MaxFailures int
+	Lockout     time.Duration
+	// Now returns the current time. It is time.Now if nil.
+	Now func() time.Time
+
+	store Store
+}
+
+// NewChecker returns a Checker of the enrollments of the store, with DefaultSkew, DefaultMaxFailures, and DefaultLockout.
+func NewChecker(store Store, issuer string) *Checker {
+	return &Checker{Issuer: issuer, Skew: DefaultSkew, MaxFailures: DefaultMaxFailures, Lockout: DefaultLockout, store: store}
+}
+
+// Pending is a new enrollment of Enroll, to be shown to the user once.
+type Pending struct {
+	// Secret is the TOTP secret, for users who type it into their authenticator app.
+	Secret string
+	// URI is the provisioning URI of the secret, usually shown as a QR code.
+	URI string
+	// RecoveryCodes are the user's recovery codes, each of which may be used once instead of a code.
+	RecoveryCodes []string
+}
+
+// Enroll starts the enrollment of the user, replacing any they had, with a new secret and RecoveryCodes recovery codes. The enrollment isn't required at login until the user confirms it with a code of the secret; see Confirm.
+func (c *Checker) Enroll(ctx context.Context, user string) (*Pending, error) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	codes, hashes, err := GenerateRecoveryCodes(RecoveryCodes)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.store.Save(ctx, user, Enrollment{Secret: secret, RecoveryHashes: hashes}); err != nil {
+		return nil, fmt.Errorf("saving enrollment of user '%v': %w", user, err)
+	}
+	return &Pending{Secret: secret, URI: ProvisioningURI(c.Issuer, user, secret), RecoveryCodes: codes}, nil
+}
+
+// Confirm confirms the enrollment of the user with a code of its secret, so it is required at login from then on. It returns an error wrapping ErrInvalidCode if the code is wrong, and ErrLocked if the user failed too many. Recovery codes don't confirm enrollments.
+func (c *Checker) Confirm(ctx context.Context, user, code string) error {
+	return c.check(ctx, user, code, false)
+}
+
+// Enrolled returns whether the user has a confirmed enrollment, and so must give a code to log in. It implements login.TwoFactor.
+func (c *Checker) Enrolled(ctx context.Context, user string) (bool, error) {
+	e, err := c.store.Load(ctx, user)
+	if errors.Is(err, ErrNotEnrolled) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("loading enrollment of user '%v': %w", user, err)
+	}
+	return e.Confirmed, nil
+}
+
+// Check returns nil if the code is a code of the user's confirmed enrollment, of a step after the last accepted, or one of their unused recovery codes, which is then used up. Wrong codes count towards the user's lockout, and wrong codes, and those of locked out or unenrolled users, return errors wrapping login.ErrInvalidCredentials, along with ErrInvalidCode, ErrLocked, or ErrNotEnrolled. It implements login.TwoFactor.
+func (c *Checker) Check(ctx context.Context, user, code string) error {
+	return c.check(ctx, user, code, true)
+}
+
+// check checks the code of the user, of a confirmed enrollment at login, or of an unconfirmed one otherwise, which it confirms.
+func (c *Checker) check(ctx context.Context, user, code string, atLogin bool) error {
+	now := c.now()
+	var result error
+	err := c.store.Update(ctx, user, func(e *Enrollment) error {
+		if atLogin && !e.Confirmed {
+			return ErrNotEnrolled
+		}
+		if c.MaxFailures > 0 && e.Failures >= c.MaxFailures {
+			if now.Before(e.LastFailure.Add(c.Lockout)) {
+				return ErrLocked
+			}
+			e.Failures = 0
+		}
+		if step, err := Validate(e.Secret, code, now, c.Skew); err == nil && step > e.LastStep {
+			e.LastStep = step
+			e.Failures = 0
+			e.Confirmed = true
+			return nil
+		}
+		if i := MatchRecoveryCode(e.RecoveryHashes, code); atLogin && i >= 0 {
+			e.RecoveryHashes = append(e.RecoveryHashes[:i:i], e.RecoveryHashes[i+1:]...)
+			e.Failures = 0
+			return nil
+		}
+		e.Failures++
+		e.LastFailure = now
+		result = ErrInvalidCode
+		return nil
+	})
+	if err == nil {
+		err = result
+	}
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, ErrInvalidCode) || errors.Is(err, ErrLocked) || errors.Is(err, ErrNotEnrolled):
+		return fmt.Errorf("%w: %w", login.ErrInvalidCredentials, err)
+	}
+	return fmt.Errorf("checking code of user '%v': %w", user, err)
+}
+
+func (c *Checker) now() time.Time {
+	if c.Now == nil {
+		return time.Now()
+	}
+	return c.Now()
+}
+
+// MemoryStore is a Store for a single server. Its enrollments are lost when the process exits, so services with several servers, or which restart, need a shared store, e.g. of the users' database.
+type MemoryStore struct {
+	mu          sync.Mutex
+	enrollments map[string]Enrollment
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{enrollments: map[string]Enrollment{}}
+}
+
+// Load implements Store.
+func (s *MemoryStore) Load(ctx context.Context, user string) (*Enrollment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.enrollments[user]
+	if !ok {
+		return nil, ErrNotEnrolled
+	}
+	e.RecoveryHashes = append([]string(nil), e.RecoveryHashes...)
+	return &e, nil
+}
+
+// Save implements Store.
+func (s *MemoryStore) Save(ctx context.Context, user string, e Enrollment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e.RecoveryHashes = append([]string(nil), e.RecoveryHashes...)
+	s.enrollments[user] = e
+	return nil
+}
+
+// Update implements Store.
+func (s *MemoryStore) Update(ctx context.Context, user string, update func(e *Enrollment) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.enrollments[user]
+	if !ok {
+		return ErrNotEnrolled
+	}
+	e.RecoveryHashes = append([]string(nil), e.RecoveryHashes...)
+	if err := update(&e); err != nil {
+		return err
+	}
+	s.enrollments[user] = e
+	return nil
+}
+
+// Delete implements Store.
+func (s *MemoryStore) Delete(ctx context.Context, user string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.enrollments, user)
+	return nil
+}
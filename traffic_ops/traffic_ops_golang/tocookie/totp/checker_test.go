@@ -0,0 +1,106 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package totp
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/tocookie/login"
+)
+
+func TestChecker(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1700000000, 0)
+	store := NewMemoryStore()
+	checker := NewChecker(store, "Traffic Ops")
+	checker.Now = func() time.Time { return now }
+
+	if enrolled, err := checker.Enrolled(ctx, "alice"); err != nil || enrolled {
+		t.Errorf("Enrolled of unknown user expected false, actual: %v %v", enrolled, err)
+	}
+	pending, err := checker.Enroll(ctx, "alice")
+	if err != nil || pending.Secret == "" || len(pending.RecoveryCodes) != RecoveryCodes || pending.URI != ProvisioningURI("Traffic Ops", "alice", pending.Secret) {
+		t.Fatalf("Enroll expected a secret, its URI, and recovery codes, actual: %+v %v", pending, err)
+	}
+	if enrolled, _ := checker.Enrolled(ctx, "alice"); enrolled {
+		t.Errorf("Enrolled before Confirm expected false, actual: true")
+	}
+	code, _ := Code(pending.Secret, now)
+	if err := checker.Check(ctx, "alice", code); !errors.Is(err, login.ErrInvalidCredentials) || !errors.Is(err, ErrNotEnrolled) {
+		t.Errorf("Check before Confirm expected ErrInvalidCredentials and ErrNotEnrolled, actual: %v", err)
+	}
+	if err := checker.Confirm(ctx, "alice", pending.RecoveryCodes[0]); !errors.Is(err, ErrInvalidCode) {
+		t.Errorf("Confirm with recovery code expected ErrInvalidCode, actual: %v", err)
+	}
+	if err := checker.Confirm(ctx, "alice", code); err != nil {
+		t.Fatalf("Confirm expected nil error, actual: %v", err)
+	}
+	if enrolled, _ := checker.Enrolled(ctx, "alice"); !enrolled {
+		t.Errorf("Enrolled after Confirm expected true, actual: false")
+	}
+
+	if err := checker.Check(ctx, "alice", code); !errors.Is(err, ErrInvalidCode) {
+		t.Errorf("Check of the code used to confirm expected ErrInvalidCode, actual: %v", err)
+	}
+	now = now.Add(Period)
+	code, _ = Code(pending.Secret, now)
+	if err := checker.Check(ctx, "alice", code); err != nil {
+		t.Errorf("Check of the next step's code expected nil error, actual: %v", err)
+	}
+	if err := checker.Check(ctx, "alice", code); !errors.Is(err, ErrInvalidCode) {
+		t.Errorf("Check of a replayed code expected ErrInvalidCode, actual: %v", err)
+	}
+
+	if err := checker.Check(ctx, "alice", pending.RecoveryCodes[1]); err != nil {
+		t.Errorf("Check of a recovery code expected nil error, actual: %v", err)
+	}
+	if err := checker.Check(ctx, "alice", pending.RecoveryCodes[1]); !errors.Is(err, ErrInvalidCode) {
+		t.Errorf("Check of a used recovery code expected ErrInvalidCode, actual: %v", err)
+	}
+	if e, _ := store.Load(ctx, "alice"); len(e.RecoveryHashes) != RecoveryCodes-1 {
+		t.Errorf("Check of a recovery code expected it removed, actual: %v left", len(e.RecoveryHashes))
+	}
+	if err := checker.Check(ctx, "bob", code); !errors.Is(err, login.ErrInvalidCredentials) || !errors.Is(err, ErrNotEnrolled) {
+		t.Errorf("Check of unknown user expected ErrInvalidCredentials and ErrNotEnrolled, actual: %v", err)
+	}
+}
+
+func TestCheckerLockout(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1700000000, 0)
+	store := NewMemoryStore()
+	store.Save(ctx, "alice", Enrollment{Secret: rfcSecret, Confirmed: true})
+	checker := NewChecker(store, "Traffic Ops")
+	checker.Now = func() time.Time { return now }
+
+	for i := 0; i < DefaultMaxFailures; i++ {
+		if err := checker.Check(ctx, "alice", "000000"); !errors.Is(err, ErrInvalidCode) {
+			t.Fatalf("Check of wrong code %v expected ErrInvalidCode, actual: %v", i, err)
+		}
+	}
+	code, _ := Code(rfcSecret, now)
+	if err := checker.Check(ctx, "alice", code); !errors.Is(err, ErrLocked) || !errors.Is(err, login.ErrInvalidCredentials) {
+		t.Errorf("Check after %v failures expected ErrLocked, actual: %v", DefaultMaxFailures, err)
+	}
+	now = now.Add(DefaultLockout + time.Second)
+	code, _ = Code(rfcSecret, now)
+	if err := checker.Check(ctx, "alice", code); err != nil {
+		t.Errorf("Check after the lockout expected nil error, actual: %v", err)
+	}
+	if e, _ := store.Load(ctx, "alice"); e.Failures != 0 {
+		t.Errorf("Check of a valid code expected failures reset, actual: %v", e.Failures)
+	}
+}
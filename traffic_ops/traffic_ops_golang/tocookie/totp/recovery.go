@@ -0,0 +1,66 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package totp
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// RecoveryCodes is the number of recovery codes of an enrollment.
+const RecoveryCodes = 10
+
+// recoveryCodeSize is the random bytes of a recovery code: 80 bits, which base32 encodes as 16 characters.
+const recoveryCodeSize = 10
+
+// recoveryEncoding is the encoding of recovery codes: lowercase unpadded base32, whose alphabet has no symbols, so codes are easily read and typed.
+var recoveryEncoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+// GenerateRecoveryCodes returns n new random recovery codes, for users to keep in case they lose their device, and their hashes, for the store. Each code is 16 characters, grouped by 4 with dashes, e.g. "abcd-efgh-ijkl-mnop", and can only be used once. Only the hashes should be stored; the codes are shown to the user once.
+func GenerateRecoveryCodes(n int) (codes []string, hashes []string, err error) {
+	for i := 0; i < n; i++ {
+		random := make([]byte, recoveryCodeSize)
+		if _, err := rand.Read(random); err != nil {
+			return nil, nil, fmt.Errorf("generating recovery code: %w", err)
+		}
+		encoded := recoveryEncoding.EncodeToString(random)
+		code := encoded[0:4] + "-" + encoded[4:8] + "-" + encoded[8:12] + "-" + encoded[12:16]
+		codes = append(codes, code)
+		hashes = append(hashes, HashRecoveryCode(code))
+	}
+	return codes, hashes, nil
+}
+
+// HashRecoveryCode returns the hash of a recovery code, as GenerateRecoveryCodes returns them: the hex SHA-256 of the code, lowercased, without dashes or spaces, so codes typed in either case, and ungrouped, match. The codes are random, so they needn't be salted.
+func HashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+// MatchRecoveryCode returns the index of the hash of the code in hashes, or -1 if it isn't there. Hashes are compared in constant time.
+func MatchRecoveryCode(hashes []string, code string) int {
+	hash := HashRecoveryCode(code)
+	match := -1
+	for i, h := range hashes {
+		if subtle.ConstantTimeCompare([]byte(h), []byte(hash)) == 1 && match == -1 {
+			match = i
+		}
+	}
+	return match
+}
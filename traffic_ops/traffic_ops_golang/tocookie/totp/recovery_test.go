@@ -0,0 +1,55 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package totp
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, hashes, err := GenerateRecoveryCodes(RecoveryCodes)
+	if err != nil || len(codes) != RecoveryCodes || len(hashes) != RecoveryCodes {
+		t.Fatalf("GenerateRecoveryCodes expected %v codes and hashes, actual: %v %v %v", RecoveryCodes, codes, hashes, err)
+	}
+	format := regexp.MustCompile(`^[a-z2-7]{4}-[a-z2-7]{4}-[a-z2-7]{4}-[a-z2-7]{4}$`)
+	seen := map[string]bool{}
+	for i, code := range codes {
+		if !format.MatchString(code) || seen[code] {
+			t.Errorf("GenerateRecoveryCodes expected distinct codes of 4 groups of 4, actual: %v", code)
+		}
+		seen[code] = true
+		if hashes[i] != HashRecoveryCode(code) || strings.Contains(hashes[i], code) {
+			t.Errorf("GenerateRecoveryCodes expected the hash of %v, actual: %v", code, hashes[i])
+		}
+	}
+
+	tests := map[string]struct {
+		code     string
+		expected int
+	}{
+		"code":        {codes[3], 3},
+		"uppercase":   {strings.ToUpper(codes[3]), 3},
+		"ungrouped":   {strings.ReplaceAll(codes[5], "-", ""), 5},
+		"spaced":      {strings.ReplaceAll(codes[5], "-", " "), 5},
+		"unknown":     {"aaaa-bbbb-cccc-dddd", -1},
+		"empty":       {"", -1},
+		"hash itself": {hashes[0], -1},
+	}
+	for name, test := range tests {
+		if i := MatchRecoveryCode(hashes, test.code); i != test.expected {
+			t.Errorf("%v: MatchRecoveryCode expected %v, actual: %v", name, test.expected, i)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package totp provides two-factor authentication by time-based one-time passwords, as RFC 6238 defines them and authenticator apps generate them, with recovery codes for users who lose their device. Checker enrolls users, and checks their codes for tocookie/login, whose Login issues a limited pre-auth cookie to users who have enrolled, which TwoFactorLogin exchanges for their session once they give a code:
+//
+//	checker := totp.NewChecker(totp.NewMemoryStore(), "Traffic Ops")
+//	handlers := login.New(authenticator, secret)
+//	handlers.TwoFactor = checker
+//
+// Codes are of Digits digits, of HMAC-SHA1 over steps of Period, which is what authenticator apps support by default.
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Digits is the number of digits of codes.
+const Digits = 6
+
+// Period is the time step of codes, each of which is valid for one step.
+const Period = 30 * time.Second
+
+// DefaultSkew is the steps before and after the current one whose codes Validate accepts by default, for the drift of clocks of devices, and the time users take to type them.
+const DefaultSkew = 1
+
+// SecretSize is the size of the secrets of GenerateSecret, in bytes: 160 bits, as RFC 4226 recommends for HMAC-SHA1.
+const SecretSize = 20
+
+// ErrInvalidCode is returned for codes which aren't valid for the secret at the time.
+var ErrInvalidCode = errors.New("invalid code")
+
+// encoding is the encoding of secrets: unpadded base32, as authenticator apps read them from provisioning URIs.
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateSecret returns a new random secret of SecretSize bytes, base32-encoded, for ProvisioningURI.
+func GenerateSecret() (string, error) {
+	secret := make([]byte, SecretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return "", fmt.Errorf("generating totp secret: %w", err)
+	}
+	return encoding.EncodeToString(secret), nil
+}
+
+// ProvisioningURI returns the otpauth:// URI of the secret of the account at the issuer, in the Key URI Format of Google Authenticator, which other authenticator apps read too, usually from a QR code of it.
+func ProvisioningURI(issuer, account, secret string) string {
+	label := account
+	if issuer != "" {
+		label = issuer + ":" + account
+	}
+	query := url.Values{"secret": {secret}, "algorithm": {"SHA1"}, "digits": {fmt.Sprint(Digits)}, "period": {fmt.Sprint(int(Period / time.Second))}}
+	if issuer != "" {
+		query.Set("issuer", issuer)
+	}
+	return "otpauth://totp/" + url.PathEscape(label) + "?" + query.Encode()
+}
+
+// Code returns the code of the secret at the time.
+func Code(secret string, t time.Time) (string, error) {
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return "", err
+	}
+	return hotp(key, counter(t)), nil
+}
+
+// Validate returns the step of the code, if it is the code of the secret at the time, or of a step up to skew steps before or after it, and an error wrapping ErrInvalidCode otherwise. Codes are one-time, so callers must record the step, and reject codes of the same step or earlier ones, as Checker does. Codes are compared in constant time.
+func Validate(secret, code string, t time.Time, skew int) (int64, error) {
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return 0, err
+	}
+	code = strings.TrimSpace(code)
+	if len(code) != Digits {
+		return 0, ErrInvalidCode
+	}
+	now := counter(t)
+	for i := -int64(skew); i <= int64(skew); i++ {
+		if subtle.ConstantTimeCompare([]byte(hotp(key, now+i)), []byte(code)) == 1 {
+			return now + i, nil
+		}
+	}
+	return 0, ErrInvalidCode
+}
+
+// decodeSecret decodes a base32 secret, ignoring case and spaces, as users type them.
+func decodeSecret(secret string) ([]byte, error) {
+	key, err := encoding.DecodeString(strings.ToUpper(strings.ReplaceAll(secret, " ", "")))
+	if err != nil || len(key) == 0 {
+		return nil, errors.New("malformed totp secret")
+	}
+	return key, nil
+}
+
+// counter returns the step of the time.
+func counter(t time.Time) int64 {
+	return t.Unix() / int64(Period/time.Second)
+}
+
+// hotp returns the HOTP code of the key at the counter, by RFC 4226.
+func hotp(key []byte, counter int64) string {
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, uint64(counter))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg)
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0xf
+	code := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	modulus := uint32(1)
+	for i := 0; i < Digits; i++ {
+		modulus *= 10
+	}
+	return fmt.Sprintf("%0*d", Digits, code%modulus)
+}
@@ -0,0 +1,104 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package totp
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 secret of the test vectors of RFC 6238, "12345678901234567890", in base32.
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestCode(t *testing.T) {
+	// the 8-digit codes of RFC 6238 appendix B, truncated to their last 6 digits, as RFC 4226 truncates them.
+	tests := map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1111111111: "050471",
+		1234567890: "005924",
+		2000000000: "279037",
+	}
+	for unix, expected := range tests {
+		if code, err := Code(rfcSecret, time.Unix(unix, 0)); err != nil || code != expected {
+			t.Errorf("Code at %v expected %v, actual: %v %v", unix, expected, code, err)
+		}
+	}
+	if code, err := Code("gezd gnbv gy3t qojq gezd gnbv gy3t qojq", time.Unix(59, 0)); err != nil || code != "287082" {
+		t.Errorf("Code of lowercase spaced secret expected 287082, actual: %v %v", code, err)
+	}
+	if _, err := Code("not base32!", time.Now()); err == nil {
+		t.Errorf("Code of malformed secret expected error, actual nil")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	step := now.Unix() / 30
+	tests := map[string]struct {
+		at       time.Time
+		skew     int
+		expected int64
+		err      error
+	}{
+		"current":         {now, 1, step, nil},
+		"previous step":   {now.Add(-Period), 1, step - 1, nil},
+		"next step":       {now.Add(Period), 1, step + 1, nil},
+		"two steps ago":   {now.Add(-2 * Period), 1, 0, ErrInvalidCode},
+		"two steps, skew": {now.Add(-2 * Period), 2, step - 2, nil},
+		"no skew":         {now.Add(-Period), 0, 0, ErrInvalidCode},
+	}
+	for name, test := range tests {
+		code, _ := Code(rfcSecret, test.at)
+		if s, err := Validate(rfcSecret, code, now, test.skew); s != test.expected || !errors.Is(err, test.err) {
+			t.Errorf("%v: Validate expected step %v %v, actual: %v %v", name, test.expected, test.err, s, err)
+		}
+	}
+	for _, code := range []string{"", "12345", "1234567", "000000"} {
+		if _, err := Validate(rfcSecret, code, now, DefaultSkew); !errors.Is(err, ErrInvalidCode) {
+			t.Errorf("Validate of '%v' expected ErrInvalidCode, actual: %v", code, err)
+		}
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatalf("GenerateSecret expected nil error, actual: %v", err)
+	}
+	if key, err := decodeSecret(secret); err != nil || len(key) != SecretSize {
+		t.Errorf("GenerateSecret expected %v bytes of base32, actual: '%v' %v", SecretSize, secret, err)
+	}
+	if other, _ := GenerateSecret(); other == secret {
+		t.Errorf("GenerateSecret expected different secrets, actual: %v twice", secret)
+	}
+}
+
+func TestProvisioningURI(t *testing.T) {
+	u, err := url.Parse(ProvisioningURI("Traffic Ops", "alice@example.net", rfcSecret))
+	if err != nil {
+		t.Fatalf("ProvisioningURI expected a URL, actual: %v", err)
+	}
+	query := u.Query()
+	if u.Scheme != "otpauth" || u.Host != "totp" || u.Path != "/Traffic Ops:alice@example.net" {
+		t.Errorf("ProvisioningURI expected otpauth://totp/Traffic Ops:alice@example.net, actual: %v", u)
+	}
+	if query.Get("secret") != rfcSecret || query.Get("issuer") != "Traffic Ops" || query.Get("digits") != "6" || query.Get("period") != "30" || query.Get("algorithm") != "SHA1" {
+		t.Errorf("ProvisioningURI expected secret, issuer, and parameters, actual: %v", query)
+	}
+	if u, _ := url.Parse(ProvisioningURI("", "alice", rfcSecret)); u.Path != "/alice" || u.Query().Has("issuer") {
+		t.Errorf("ProvisioningURI without issuer expected label alice, actual: %v", u)
+	}
+}
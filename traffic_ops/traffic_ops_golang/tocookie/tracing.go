@@ -0,0 +1,120 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Tracer starts the spans of WithTracer. It is a small subset of OpenTelemetry's trace.Tracer, so this package needn't depend on OpenTelemetry; an adapter is a few lines:
+//
+//	type otelTracer struct{ trace.Tracer }
+//
+//	func (t otelTracer) Start(ctx context.Context, name string) (context.Context, tocookie.Span) {
+//		ctx, span := t.Tracer.Start(ctx, name)
+//		return ctx, otelSpan{span}
+//	}
+//
+//	type otelSpan struct{ trace.Span }
+//
+//	func (s otelSpan) SetAttribute(key string, value interface{}) {
+//		switch v := value.(type) {
+//		case string:
+//			s.SetAttributes(attribute.String(key, v))
+//		case int64:
+//			s.SetAttributes(attribute.Int64(key, v))
+//		}
+//	}
+//
+//	func (s otelSpan) End(err error) {
+//		if err != nil {
+//			s.RecordError(err)
+//			s.SetStatus(codes.Error, tocookie.FailureReason(err))
+//		}
+//		s.Span.End()
+//	}
+type Tracer interface {
+	// Start starts a span of the given name, as a child of any span of the context, and returns the context of the new span.
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is a span started by a Tracer.
+type Span interface {
+	// SetAttribute sets an attribute of the span. Values are strings or int64s.
+	SetAttribute(key string, value interface{})
+	// End ends the span, with the error of the operation it traced, or nil if it succeeded.
+	End(err error)
+}
+
+// The names of the spans of WithTracer.
+const (
+	SpanParse   = "tocookie.Parse"
+	SpanNew     = "tocookie.New"
+	SpanRefresh = "tocookie.Refresh"
+)
+
+// The attributes of the spans of WithTracer. Spans never have the cookie, its signature, or the secret, nor the user, which is personal data; correlate spans with users by the attributes of the request.
+const (
+	// AttributeOutcome is the Outcome of parsing the cookie, e.g. "valid" or "expired".
+	AttributeOutcome = "tocookie.outcome"
+	// AttributeFailureReason is the FailureReason of cookies which weren't accepted.
+	AttributeFailureReason = "tocookie.failure_reason"
+	// AttributeTTL is the remaining lifetime of the cookie, in whole seconds, which is negative for expired cookies. It isn't set for cookies which couldn't be decoded, nor for cookies which weren't issued.
+	AttributeTTL = "tocookie.ttl_seconds"
+)
+
+// errNotIssued is the error spans of New and Refresh end with when no cookie was issued, as they only return the empty string.
+var errNotIssued = errors.New("cookie not issued")
+
+// WithTracer makes Parse, New, and Refresh trace themselves with spans of the tracer, e.g. one wrapping OpenTelemetry, so the time spent authenticating requests shows in their traces. Spans are started from the context of ParseContext, NewWithContext, and RefreshContext, which Middleware calls with the context of the request; the functions without a context start root spans. The hooks of WithHooks run with the context of the parse span, so their spans are its children.
+func WithTracer(tracer Tracer) Option {
+	return func(o *options) { o.tracer = tracer }
+}
+
+// startSpan starts a span of the tracer, if there is one. Otherwise, the context is returned, with a nil Span.
+func (o *options) startSpan(ctx context.Context, name string) (context.Context, Span) {
+	if o.tracer == nil {
+		return ctx, nil
+	}
+	return o.tracer.Start(ctx, name)
+}
+
+// endParseSpan ends the span, if there is one, of parsing a cookie, with the result.
+func (o *options) endParseSpan(span Span, c *Cookie, err error) {
+	if span == nil {
+		return
+	}
+	span.SetAttribute(AttributeOutcome, Classify(err).String())
+	if err != nil {
+		span.SetAttribute(AttributeFailureReason, FailureReason(err))
+	}
+	if c != nil {
+		span.SetAttribute(AttributeTTL, int64(c.Expires().Sub(o.now())/time.Second))
+	}
+	span.End(err)
+}
+
+// endIssueSpan ends the span, if there is one, of issuing a cookie which expires at the expiration, or of failing to, if it is empty.
+func (o *options) endIssueSpan(span Span, cookie string, expiration time.Time) {
+	if span == nil {
+		return
+	}
+	if cookie == "" {
+		span.End(errNotIssued)
+		return
+	}
+	span.SetAttribute(AttributeTTL, int64(expiration.Sub(o.now())/time.Second))
+	span.End(nil)
+}
@@ -0,0 +1,127 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type testTraceKey struct{}
+
+type testTracer struct {
+	spans []*testSpan
+}
+
+func (t *testTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	span := &testSpan{name: name, attrs: map[string]interface{}{}}
+	if parent, ok := ctx.Value(testTraceKey{}).(*testSpan); ok {
+		span.parent = parent.name
+	}
+	t.spans = append(t.spans, span)
+	return context.WithValue(ctx, testTraceKey{}, span), span
+}
+
+type testSpan struct {
+	name, parent string
+	attrs        map[string]interface{}
+	ended        bool
+	err          error
+}
+
+func (s *testSpan) SetAttribute(key string, value interface{}) { s.attrs[key] = value }
+func (s *testSpan) End(err error)                              { s.ended, s.err = true, err }
+
+func TestWithTracer(t *testing.T) {
+	secret := "secret"
+	tracer := &testTracer{}
+	opts := []Option{WithTracer(tracer), WithClock(func() time.Time { return time.Unix(1000, 0) })}
+
+	cookie := New("alice", time.Unix(1060, 0), secret, opts...)
+	c, err := Parse(secret, cookie, opts...)
+	if err != nil {
+		t.Fatalf("Parse expected nil error, actual: %v", err)
+	}
+	RefreshContext(context.Background(), c, secret, append(opts, WithIdleTimeout(time.Hour))...)
+	Parse("wrong", cookie, opts...)
+
+	tests := []struct {
+		name  string
+		ttl   interface{}
+		attrs map[string]interface{}
+		err   error
+	}{
+		{SpanNew, int64(60), nil, nil},
+		{SpanParse, int64(60), map[string]interface{}{AttributeOutcome: "valid"}, nil},
+		{SpanRefresh, int64(3600), nil, nil},
+		{SpanParse, nil, map[string]interface{}{AttributeOutcome: "invalid", AttributeFailureReason: "bad_signature"}, ErrBadSignature},
+	}
+	if len(tracer.spans) != len(tests) {
+		t.Fatalf("WithTracer expected %v spans, actual: %v", len(tests), len(tracer.spans))
+	}
+	for i, test := range tests {
+		span := tracer.spans[i]
+		if span.name != test.name || !span.ended || !errors.Is(span.err, test.err) {
+			t.Errorf("span %v expected %v ended with %v, actual: %v ended %v with %v", i, test.name, test.err, span.name, span.ended, span.err)
+		}
+		if span.attrs[AttributeTTL] != test.ttl {
+			t.Errorf("span %v expected TTL %v, actual: %v", i, test.ttl, span.attrs[AttributeTTL])
+		}
+		for k, v := range test.attrs {
+			if span.attrs[k] != v {
+				t.Errorf("span %v expected %v %v, actual: %v", i, k, v, span.attrs[k])
+			}
+		}
+		for k, v := range span.attrs {
+			if s, ok := v.(string); ok && (s == secret || s == cookie) {
+				t.Errorf("span %v expected no secrets, actual: %v %v", i, k, s)
+			}
+		}
+	}
+}
+
+func TestWithTracerMiddleware(t *testing.T) {
+	secret := "secret"
+	tracer := &testTracer{}
+	hookParent := ""
+	hook := func(ctx context.Context, c *Cookie) error {
+		if span, ok := ctx.Value(testTraceKey{}).(*testSpan); ok {
+			hookParent = span.name
+		}
+		return nil
+	}
+	opts := []Option{WithTracer(tracer), WithHooks(hook), WithRefreshWindow(time.Hour)}
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	handler := Middleware(secret, next, opts...)
+
+	ctx, _ := (&testTracer{}).Start(context.Background(), "request")
+	r := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+	r.AddCookie(&http.Cookie{Name: Name, Value: New("alice", time.Now().Add(time.Minute), secret)})
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	if len(tracer.spans) != 2 || tracer.spans[0].name != SpanParse || tracer.spans[1].name != SpanRefresh {
+		t.Fatalf("Middleware expected parse and refresh spans, actual: %v", len(tracer.spans))
+	}
+	for _, span := range tracer.spans {
+		if span.parent != "request" {
+			t.Errorf("Middleware expected %v span child of request, actual parent: '%v'", span.name, span.parent)
+		}
+	}
+	if hookParent != SpanParse {
+		t.Errorf("Middleware expected hooks run in the parse span, actual: '%v'", hookParent)
+	}
+}
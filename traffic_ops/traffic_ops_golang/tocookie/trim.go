@@ -0,0 +1,34 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"strings"
+)
+
+// WithTrim makes Parse, and the other functions which read cookies, remove whitespace surrounding the cookie, and then a single pair of double quotes wrapping it, as some proxies and hand-written curl commands deliver them. Trimming happens before the cookie is split or checked in any other way. By default, cookies are read strictly, and such characters make them malformed.
+func WithTrim() Option {
+	return func(o *options) { o.trim = true }
+}
+
+// trimCookie returns the cookie without surrounding whitespace and wrapping quotes, if WithTrim was given.
+func (o *options) trimCookie(cookie string) string {
+	if !o.trim {
+		return cookie
+	}
+	cookie = strings.TrimSpace(cookie)
+	if len(cookie) >= 2 && cookie[0] == '"' && cookie[len(cookie)-1] == '"' {
+		cookie = cookie[1 : len(cookie)-1]
+	}
+	return cookie
+}
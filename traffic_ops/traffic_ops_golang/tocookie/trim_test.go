@@ -0,0 +1,53 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWithTrim(t *testing.T) {
+	secret := "secret"
+	cookie := New("alice", time.Now().Add(time.Minute), secret)
+	v2 := New("alice", time.Now().Add(time.Minute), secret, WithCompression(CodecGzip))
+
+	tests := map[string]string{
+		"untouched":         cookie,
+		"whitespace":        " \t" + cookie + "\r\n",
+		"quotes":            `"` + cookie + `"`,
+		"quotes whitespace": ` "` + cookie + `" `,
+		"version 2":         `"` + v2 + `"`,
+	}
+	for name, input := range tests {
+		if c, err := Parse(secret, input, WithTrim()); err != nil || c.AuthData != "alice" {
+			t.Errorf("%v: Parse WithTrim expected alice and nil error, actual: %+v %v", name, c, err)
+		}
+		if name != "untouched" {
+			if _, err := Parse(secret, input); err == nil {
+				t.Errorf("%v: Parse without WithTrim expected error, actual nil", name)
+			}
+		}
+	}
+
+	rejected := map[string]string{
+		"two pairs of quotes":  `""` + cookie + `""`,
+		"unbalanced quote":     `"` + cookie,
+		"whitespace in quotes": `" ` + cookie + ` "`,
+	}
+	for name, input := range rejected {
+		if _, err := Parse(secret, input, WithTrim()); err == nil {
+			t.Errorf("%v: Parse WithTrim expected error, actual nil", name)
+		}
+	}
+}
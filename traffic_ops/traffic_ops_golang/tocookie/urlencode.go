@@ -0,0 +1,45 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"net/url"
+	"strings"
+)
+
+// WithURLEncoding makes New percent-encode the cookies it mints, and Parse, and the other functions which read cookies, percent-decode cookies once before reading them, for client stacks and gateways which encode cookie values an extra time, delivering e.g. "%2D%2D" for "--". Percent-encoding a cookie only changes it if it contains characters such as '+', '/', or '=', which depends on its padding; see WithPadding.
+//
+// No cookie in any registered version contains '%', so only cookies containing it are decoded, and decoding can't change a cookie which would otherwise have been valid. Cookies are decoded exactly once, so a cookie encoded twice is still rejected. Cookies which aren't valid percent-encoding are read as they are, and are rejected as malformed. Decoding happens after trimming; see WithTrim.
+func WithURLEncoding() Option {
+	return func(o *options) { o.urlEncoding = true }
+}
+
+// escapeCookie percent-encodes a minted cookie, if WithURLEncoding was given.
+func (o *options) escapeCookie(cookie string) string {
+	if !o.urlEncoding {
+		return cookie
+	}
+	return url.QueryEscape(cookie)
+}
+
+// unescapeCookie percent-decodes a cookie being read, if WithURLEncoding was given and it contains a percent-encoded character. Unlike url.QueryUnescape, a '+' is left alone, as it may be part of standard base64.
+func (o *options) unescapeCookie(cookie string) string {
+	if !o.urlEncoding || !strings.Contains(cookie, "%") {
+		return cookie
+	}
+	unescaped, err := url.PathUnescape(cookie)
+	if err != nil {
+		return cookie
+	}
+	return unescaped
+}
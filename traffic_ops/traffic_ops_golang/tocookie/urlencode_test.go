@@ -0,0 +1,89 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// percentEncodeAll percent-encodes every byte of s, as the most overzealous clients do.
+func percentEncodeAll(s string) string {
+	encoded := strings.Builder{}
+	for i := 0; i < len(s); i++ {
+		fmt.Fprintf(&encoded, "%%%02X", s[i])
+	}
+	return encoded.String()
+}
+
+func TestWithURLEncoding(t *testing.T) {
+	secret := "secret"
+	expiration := time.Now().Add(time.Minute)
+	cookie := New("alice", expiration, secret)
+
+	// The payloads of consecutive lengths can't all be a multiple of 3 bytes, so at least one is padded.
+	padded := map[string]string{}
+	for _, user := range []string{"alice", "alice1", "alice12"} {
+		plain := New(user, expiration, secret, WithPadding('='))
+		encoded := New(user, expiration, secret, WithPadding('='), WithURLEncoding())
+		if strings.Contains(encoded, "=") || strings.Count(encoded, "%3D") != strings.Count(plain, "=") {
+			t.Errorf("New WithURLEncoding expected '=' padding percent-encoded, actual: '%v'", encoded)
+		}
+		if encoded != plain {
+			padded[user] = encoded
+		}
+	}
+	if len(padded) == 0 {
+		t.Fatalf("New WithURLEncoding expected some padded cookies to be percent-encoded, actual none")
+	}
+	for user, encoded := range padded {
+		if c, err := Parse(secret, encoded, WithPadding('='), WithURLEncoding()); err != nil || c.AuthData != user {
+			t.Errorf("Parse WithURLEncoding encoded padding expected %v and nil error, actual: %+v %v", user, c, err)
+		}
+	}
+
+	tests := map[string]struct {
+		cookie string
+		opts   []Option
+	}{
+		"unencoded":  {cookie, nil},
+		"dashes":     {strings.Replace(cookie, "--", "%2D%2D", -1), nil},
+		"every byte": {percentEncodeAll(cookie), nil},
+		"trimmed":    {` "` + strings.Replace(cookie, "--", "%2d%2d", -1) + `" `, []Option{WithTrim()}},
+		"version 2":  {url.QueryEscape(New("alice", expiration, secret, WithCompression(CodecGzip))), nil},
+	}
+	for name, test := range tests {
+		opts := append(test.opts, WithURLEncoding())
+		if c, err := Parse(secret, test.cookie, opts...); err != nil || c.AuthData != "alice" {
+			t.Errorf("%v: Parse WithURLEncoding expected alice and nil error, actual: %+v %v", name, c, err)
+		}
+	}
+
+	rejected := map[string]string{
+		"without option": strings.Replace(cookie, "--", "%2D%2D", -1),
+		"encoded twice":  url.QueryEscape(strings.Replace(cookie, "--", "%2D%2D", -1)),
+		"bad escape":     strings.Replace(cookie, "--", "%2D%Z", -1),
+	}
+	for name, input := range rejected {
+		opts := []Option{WithURLEncoding()}
+		if name == "without option" {
+			opts = nil
+		}
+		if _, err := Parse(secret, input, opts...); err == nil {
+			t.Errorf("%v: Parse expected error, actual nil", name)
+		}
+	}
+}
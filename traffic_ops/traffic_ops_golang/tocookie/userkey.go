@@ -0,0 +1,62 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"crypto/sha256"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// userKeyInfo prefixes the user in the HKDF info, and PBKDF2 salt, of the keys of WithPerUserKeys.
+const userKeyInfo = "tocookie user key v1\x00"
+
+// userKeyLen is the length in bytes of per-user keys.
+const userKeyLen = 32
+
+// DefaultKDFIterations is the default number of iterations of the per-user key derivation. A single iteration is plain HKDF, without stretching.
+const DefaultKDFIterations = 1
+
+// WithPerUserKeys signs each cookie with a key derived from the secret and the cookie's user, so a leaked per-user key doesn't compromise other users' cookies. It must be given to both New and Parse.
+//
+// The key of user u is HKDF-SHA256 (RFC 5869) with the secret as the input keying material, no salt, and the info "tocookie user key v1", a 0 byte, and u; 32 bytes are derived. The key is always derived with SHA256, regardless of WithHash, which only sets the signature hash.
+//
+// With more than one iteration, set by WithKDFIterations, the input keying material is instead PBKDF2-HMAC-SHA256 (RFC 8018) of the secret, with that many iterations, the salt "tocookie user key v1", a 0 byte, and u, and a 32 byte output.
+//
+// Parse derives the key from the AuthData of the cookie before its signature is verified. This is safe because AuthData is signed: a cookie whose AuthData was altered fails verification, since its signature was not made with the altered user's key. Parse additionally rejects cookies whose verified AuthData isn't the user the key was derived for.
+func WithPerUserKeys() Option {
+	return func(o *options) { o.perUserKeys = true }
+}
+
+// WithKDFIterations sets the number of iterations of the per-user key derivation of WithPerUserKeys, to make brute-forcing the secret from a cookie more expensive. It must be given to both New and Parse, with the same count; cookies minted with a different count fail verification. Counts less than 1 are DefaultKDFIterations.
+//
+// Every cookie minted or parsed derives its key anew, so the cost is paid on every request: each iteration is one HMAC-SHA256, a fraction of a microsecond on current server hardware, so 10000 iterations add a few milliseconds to every New and Parse. Operators should measure the latency on their own hardware, e.g. with the package benchmarks, before increasing it.
+func WithKDFIterations(iterations int) Option {
+	return func(o *options) { o.kdfIterations = iterations }
+}
+
+// deriveUserKey returns the per-user key of the user, as documented by WithPerUserKeys.
+func deriveUserKey(secret []byte, user string, iterations int) []byte {
+	if iterations > 1 {
+		secret = pbkdf2.Key(secret, []byte(userKeyInfo+user), iterations, userKeyLen, sha256.New)
+	}
+	return deriveKey(secret, nil, userKeyInfo+user)
+}
+
+// signingKey returns the key the cookie of the user is signed with.
+func (o *options) signingKey(secret []byte, user string) []byte {
+	if !o.perUserKeys {
+		return secret
+	}
+	return deriveUserKey(secret, user, o.kdfIterations)
+}
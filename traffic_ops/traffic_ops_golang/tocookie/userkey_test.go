@@ -0,0 +1,80 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPerUserKeys(t *testing.T) {
+	expiration := time.Now().Add(time.Minute)
+	cookie := New("alice", expiration, "secret", WithPerUserKeys())
+
+	if c, err := Parse("secret", cookie, WithPerUserKeys()); err != nil || c.AuthData != "alice" {
+		t.Errorf("Parse with per-user keys expected alice and nil error, actual: %+v %v", c, err)
+	}
+	if _, err := Parse("secret", cookie); err == nil {
+		t.Errorf("Parse without per-user keys expected error for per-user cookie, actual nil")
+	}
+	if _, err := Parse("secret", New("alice", expiration, "secret"), WithPerUserKeys()); err == nil {
+		t.Errorf("Parse with per-user keys expected error for master-key cookie, actual nil")
+	}
+
+	// A cookie signed with alice's leaked key must not be accepted for another user.
+	msg := `{"auth_data":"bob","expires":` + strings.Repeat("9", 10) + `,"by":"` + GeneratedByStr + `"}`
+	payload := base64.RawURLEncoding.EncodeToString([]byte(msg))
+	forged := payload + "--" + hex.EncodeToString(newOptions(nil).sign([]byte(payload), deriveUserKey([]byte("secret"), "alice", 1)))
+	if _, err := Parse("secret", forged, WithPerUserKeys()); err == nil {
+		t.Errorf("Parse with per-user keys expected error for cookie signed with another user's key, actual nil")
+	}
+}
+
+func TestDeriveUserKey(t *testing.T) {
+	alice := deriveUserKey([]byte("secret"), "alice", 1)
+	if len(alice) != userKeyLen {
+		t.Errorf("deriveUserKey expected %v bytes, actual: %v", userKeyLen, len(alice))
+	}
+	if !bytes.Equal(alice, deriveUserKey([]byte("secret"), "alice", 1)) {
+		t.Errorf("deriveUserKey expected deterministic key")
+	}
+	if bytes.Equal(alice, deriveUserKey([]byte("secret"), "bob", 1)) || bytes.Equal(alice, deriveUserKey([]byte("other"), "alice", 1)) {
+		t.Errorf("deriveUserKey expected distinct keys for distinct users and secrets")
+	}
+}
+
+func TestWithKDFIterations(t *testing.T) {
+	expiration := time.Now().Add(time.Minute)
+	cookie := New("alice", expiration, "secret", WithPerUserKeys(), WithKDFIterations(1000))
+
+	if c, err := Parse("secret", cookie, WithPerUserKeys(), WithKDFIterations(1000)); err != nil || c.AuthData != "alice" {
+		t.Errorf("Parse with same iterations expected alice and nil error, actual: %+v %v", c, err)
+	}
+	if _, err := Parse("secret", cookie, WithPerUserKeys(), WithKDFIterations(999)); err == nil {
+		t.Errorf("Parse with different iterations expected error, actual nil")
+	}
+	if _, err := Parse("secret", cookie, WithPerUserKeys()); err == nil {
+		t.Errorf("Parse with default iterations expected error, actual nil")
+	}
+
+	unstretched := New("alice", expiration, "secret", WithPerUserKeys())
+	for _, iterations := range []int{0, -1, DefaultKDFIterations} {
+		if _, err := Parse("secret", unstretched, WithPerUserKeys(), WithKDFIterations(iterations)); err != nil {
+			t.Errorf("Parse with %v iterations expected default derivation, actual: %v", iterations, err)
+		}
+	}
+}
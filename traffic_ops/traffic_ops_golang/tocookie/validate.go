@@ -0,0 +1,119 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"errors"
+	"time"
+)
+
+// ValidateOption configures the policy checked by Validate. Validation options are ordinary Options, so the same options may be given to Parse, which validates every cookie it decodes.
+type ValidateOption = Option
+
+// WithNotBefore sets the time before which a cookie minted by New is not valid, which is stored in the nbf field. Parse and Validate reject cookies before then with ErrNotYetValid, tolerating the clock skew of WithLeeway.
+func WithNotBefore(t time.Time) Option {
+	return func(o *options) { o.notBefore = t.Unix() }
+}
+
+// WithAudience sets the audience of the cookie. Given to New, the audience is embedded in the cookie. Given to Parse or Validate, the cookie is rejected with ErrAudienceMismatch unless it was minted for the same audience.
+func WithAudience(audience string) Option {
+	return func(o *options) { o.audience = audience }
+}
+
+// WithIssuer sets the issuer of the cookie, which is stored in the By field. Given to New, it replaces GeneratedByStr. Given to Parse or Validate, the cookie is rejected with ErrIssuerMismatch unless it was minted by the same issuer.
+func WithIssuer(issuer string) Option {
+	return func(o *options) { o.issuer = issuer }
+}
+
+// WithMillisecondExpiry makes New store the expiration to millisecond precision, in the ExpiresMillis field, for short-lived cookies such as one-time links. Parse needs no option to read such cookies; it uses ExpiresMillis whenever it is present.
+func WithMillisecondExpiry() Option {
+	return func(o *options) { o.millis = true }
+}
+
+// WithRFC3339Expiry makes New additionally store the expiration as an RFC3339 string, in the ExpiresRFC3339 field, for consumers which prefer ISO timestamps. The string is derived from the integer expiration, so the two always agree.
+func WithRFC3339Expiry() Option {
+	return func(o *options) { o.rfc3339 = true }
+}
+
+// SkipExpiry makes Parse and Validate accept expired cookies. The signature is still verified, and all other claims are still validated.
+//
+// THIS IS FOR OFFLINE AND ADMINISTRATIVE TOOLING ONLY, such as forensic analysis of old cookies. It must never be used when authenticating requests, as it makes every cookie ever minted with the secret valid forever.
+func SkipExpiry() Option {
+	return func(o *options) { o.skipExpiry = true }
+}
+
+// WithAllErrors makes Parse and Validate check every claim rather than stopping at the first failure. If more than one check fails, the returned error joins them all, and implements Unwrap() []error, so errors.Is matches each failure. By default, only the first failure is returned.
+func WithAllErrors() Option {
+	return func(o *options) { o.allErrors = true }
+}
+
+// Validate checks whether the claims of an already-decoded cookie satisfy the policy given by the options, and returns the first failure, or all failures WithAllErrors. It checks the expiry and not-before times, with the leeway of WithLeeway and the grace period of WithExpiryGrace, setting Stale, and the audience, issuer, fingerprint, and claim validators if the corresponding options are given.
+//
+// Validate doesn't verify signatures, and doesn't consume nonces. It is intended for re-validating cookies previously returned by Parse, e.g. cached ones, against the current policy.
+func Validate(c *Cookie, opts ...ValidateOption) error {
+	return validate(c, newOptions(opts))
+}
+
+func validate(c *Cookie, o *options) error {
+	now := o.now()
+	var errs []error
+	fail := func(err error) bool {
+		errs = append(errs, err)
+		return !o.allErrors
+	}
+
+	if !o.skipExpiry {
+		c.Stale = false
+		if c.expired(now.Add(-o.leeway)) {
+			if o.expiryGrace > 0 && !c.expired(now.Add(-o.leeway-o.expiryGrace)) {
+				c.Stale = true
+			} else if expired := (&ExpiredError{Expired: c.Expires(), Now: now}); fail(expired) {
+				return expired
+			}
+		}
+	}
+	if o.sessionTooOld(c, now) && fail(ErrSessionTooOld) {
+		return ErrSessionTooOld
+	}
+	if o.reauthRequired(c, now) && fail(ErrReauthRequired) {
+		return ErrReauthRequired
+	}
+	if c.NotBefore != 0 && now.Add(o.leeway).Unix() < c.NotBefore && fail(ErrNotYetValid) {
+		return ErrNotYetValid
+	}
+	if o.audience != "" && c.Audience != o.audience && fail(ErrAudienceMismatch) {
+		return ErrAudienceMismatch
+	}
+	if o.issuer != "" && c.By != o.issuer && fail(ErrIssuerMismatch) {
+		return ErrIssuerMismatch
+	}
+	if o.fingerprint != "" && !constantTimeEqual(c.Fingerprint, o.fingerprint) && fail(ErrFingerprintMismatch) {
+		return ErrFingerprintMismatch
+	}
+	if err := o.checkStrictPayload(c, now); err != nil && fail(err) {
+		return err
+	}
+	if c.claimsSize() > o.maxClaimsSize && fail(ErrClaimsTooLarge) {
+		return ErrClaimsTooLarge
+	}
+	if claimErrs := o.validateClaims(c, o.allErrors); len(claimErrs) > 0 {
+		if !o.allErrors {
+			return claimErrs[0]
+		}
+		errs = append(errs, claimErrs...)
+	}
+	if len(errs) == 1 {
+		return errs[0]
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,180 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+	now := time.Now()
+	valid := Cookie{AuthData: "alice", By: "to.example.net", Audience: "api", ExpiresUnix: now.Add(time.Minute).Unix()}
+	policy := []ValidateOption{WithAudience("api"), WithIssuer("to.example.net")}
+
+	if err := Validate(&valid, policy...); err != nil {
+		t.Errorf("Validate expected nil error, actual: %v", err)
+	}
+
+	tests := map[string]struct {
+		modify   func(c *Cookie)
+		expected error
+	}{
+		"not yet valid":     {func(c *Cookie) { c.NotBefore = now.Add(time.Minute).Unix() }, ErrNotYetValid},
+		"wrong audience":    {func(c *Cookie) { c.Audience = "portal" }, ErrAudienceMismatch},
+		"missing audience":  {func(c *Cookie) { c.Audience = "" }, ErrAudienceMismatch},
+		"wrong issuer":      {func(c *Cookie) { c.By = GeneratedByStr }, ErrIssuerMismatch},
+		"wrong fingerprint": {func(c *Cookie) { c.Fingerprint = "other" }, nil},
+	}
+	for name, test := range tests {
+		c := valid
+		test.modify(&c)
+		if err := Validate(&c, policy...); err != test.expected {
+			t.Errorf("%v: Validate expected %v, actual: %v", name, test.expected, err)
+		}
+	}
+
+	expired := valid
+	expired.ExpiresUnix = now.Add(-time.Minute).Unix()
+	if err := Validate(&expired, policy...); err == nil {
+		t.Errorf("Validate expired expected error, actual nil")
+	}
+}
+
+func TestParseValidates(t *testing.T) {
+	secret := "secret"
+	cookie := New("alice", time.Now().Add(time.Minute), secret, WithAudience("api"), WithIssuer("to.example.net"))
+	c, err := Parse(secret, cookie, WithAudience("api"), WithIssuer("to.example.net"))
+	if err != nil {
+		t.Fatalf("Parse expected nil error, actual: %v", err)
+	}
+	if c.By != "to.example.net" || c.Audience != "api" {
+		t.Errorf("Parse expected issuer and audience claims, actual: %+v", c)
+	}
+	if _, err := Parse(secret, cookie, WithAudience("portal")); err != ErrAudienceMismatch {
+		t.Errorf("Parse wrong audience expected ErrAudienceMismatch, actual: %v", err)
+	}
+	if _, err := Parse(secret, New("alice", time.Now().Add(time.Minute), secret, WithNotBefore(time.Now().Add(time.Hour)))); err != ErrNotYetValid {
+		t.Errorf("Parse not yet valid expected ErrNotYetValid, actual: %v", err)
+	}
+}
+
+func TestWithMillisecondExpiry(t *testing.T) {
+	secret := "secret"
+	expiration := time.Now().Add(1500 * time.Millisecond)
+	c, err := Parse(secret, New("alice", expiration, secret, WithMillisecondExpiry()))
+	if err != nil {
+		t.Fatalf("Parse expected nil error, actual: %v", err)
+	}
+	if expected := expiration.UnixNano() / int64(time.Millisecond); c.ExpiresMillis != expected {
+		t.Errorf("Parse expected ExpiresMillis %v, actual: %v", expected, c.ExpiresMillis)
+	}
+	if c.ExpiresUnix != expiration.Unix() {
+		t.Errorf("Parse expected ExpiresUnix %v, actual: %v", expiration.Unix(), c.ExpiresUnix)
+	}
+
+	expired := Cookie{ExpiresUnix: time.Now().Unix(), ExpiresMillis: time.Now().Add(-time.Millisecond).UnixNano() / int64(time.Millisecond)}
+	if err := Validate(&expired); err == nil {
+		t.Errorf("Validate expired by milliseconds expected error, actual nil")
+	}
+}
+
+func TestWithRFC3339Expiry(t *testing.T) {
+	secret := "secret"
+	expiration := time.Unix(time.Now().Add(time.Hour).Unix(), 0)
+	c, err := Parse(secret, New("alice", expiration, secret, WithRFC3339Expiry()))
+	if err != nil {
+		t.Fatalf("Parse expected nil error, actual: %v", err)
+	}
+	if expected := expiration.UTC().Format(time.RFC3339); c.ExpiresRFC3339 != expected {
+		t.Errorf("Parse expected ExpiresRFC3339 '%v', actual: '%v'", expected, c.ExpiresRFC3339)
+	}
+
+	stringOnly := Cookie{ExpiresRFC3339: expiration.UTC().Format(time.RFC3339)}
+	if !stringOnly.Expires().Equal(expiration) {
+		t.Errorf("Expires from RFC3339 expected %v, actual: %v", expiration, stringOnly.Expires())
+	}
+	if err := Validate(&stringOnly); err != nil {
+		t.Errorf("Validate RFC3339 only expected nil error, actual: %v", err)
+	}
+	stringOnly.ExpiresRFC3339 = time.Now().Add(-time.Minute).UTC().Format(time.RFC3339)
+	if err := Validate(&stringOnly); err == nil {
+		t.Errorf("Validate expired RFC3339 only expected error, actual nil")
+	}
+}
+
+func TestExpiredError(t *testing.T) {
+	secret := "secret"
+	expiration := time.Unix(time.Now().Add(-time.Hour).Unix(), 0)
+	now := expiration.Add(time.Hour)
+	_, err := Parse(secret, New("alice", expiration, secret), WithClock(func() time.Time { return now }))
+	expired := &ExpiredError{}
+	if !errors.As(err, &expired) || !expired.Expired.Equal(expiration) || !expired.Now.Equal(now) {
+		t.Fatalf("Parse expired expected ExpiredError at %v, actual: %v", expiration, err)
+	}
+	if !strings.Contains(err.Error(), "1h0m0s ago") {
+		t.Errorf("ExpiredError expected message with age, actual: %v", err)
+	}
+}
+
+func TestSkipExpiry(t *testing.T) {
+	secret := "secret"
+	expired := New("alice", time.Now().Add(-time.Hour), secret, WithAudience("api"))
+
+	if _, err := Parse(secret, expired); !errors.Is(err, ErrExpired) {
+		t.Errorf("Parse expired expected ErrExpired, actual: %v", err)
+	}
+	if c, err := Parse(secret, expired, SkipExpiry()); err != nil || c.AuthData != "alice" {
+		t.Errorf("Parse expired with SkipExpiry expected claims and nil error, actual: %+v %v", c, err)
+	}
+	if _, err := Parse("wrong", expired, SkipExpiry()); err == nil {
+		t.Errorf("Parse wrong secret with SkipExpiry expected error, actual nil")
+	}
+	if _, err := Parse(secret, expired, SkipExpiry(), WithAudience("portal")); err != ErrAudienceMismatch {
+		t.Errorf("Parse wrong audience with SkipExpiry expected ErrAudienceMismatch, actual: %v", err)
+	}
+}
+
+func TestWithAllErrors(t *testing.T) {
+	now := time.Now()
+	c := Cookie{AuthData: "alice", By: GeneratedByStr, Audience: "portal", ExpiresUnix: now.Add(-time.Minute).Unix()}
+	policy := []ValidateOption{WithAudience("api"), WithIssuer("to.example.net")}
+
+	if err := Validate(&c, policy...); !errors.Is(err, ErrExpired) {
+		t.Errorf("Validate expected first failure ErrExpired, actual: %v", err)
+	}
+
+	err := Validate(&c, append(policy, WithAllErrors())...)
+	for _, expected := range []error{ErrExpired, ErrAudienceMismatch, ErrIssuerMismatch} {
+		if !errors.Is(err, expected) {
+			t.Errorf("Validate WithAllErrors expected error matching %v, actual: %v", expected, err)
+		}
+	}
+	if errors.Is(err, ErrNotYetValid) {
+		t.Errorf("Validate WithAllErrors expected no ErrNotYetValid, actual: %v", err)
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); !ok || len(joined.Unwrap()) != 3 {
+		t.Errorf("Validate WithAllErrors expected 3 joined errors, actual: %v", err)
+	}
+
+	c.Audience, c.By = "api", "to.example.net"
+	if err := Validate(&c, append(policy, WithAllErrors())...); !errors.Is(err, ErrExpired) {
+		t.Errorf("Validate WithAllErrors single failure expected ErrExpired, actual: %v", err)
+	}
+	c.ExpiresUnix = now.Add(time.Minute).Unix()
+	if err := Validate(&c, append(policy, WithAllErrors())...); err != nil {
+		t.Errorf("Validate WithAllErrors valid expected nil error, actual: %v", err)
+	}
+}
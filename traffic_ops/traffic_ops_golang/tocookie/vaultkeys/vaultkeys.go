@@ -0,0 +1,243 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package vaultkeys provides a tocookie.KeyProvider and tocookie.MACer backed by HashiCorp Vault, so the cookie secret needn't be kept in cdn.conf. KV reads the secret from the KV version 2 engine:
+//
+//	secret, err := tocookie.LoadSecret(ctx, &vaultkeys.KV{Addr: addr, Token: token, Path: "traffic_ops/cookie"})
+//
+// Transit goes further, and has the transit engine compute and verify cookie MACs, so the key never leaves Vault:
+//
+//	macer := &vaultkeys.Transit{Addr: addr, Token: token, Key: "traffic_ops_cookie"}
+//	cookie := tocookie.New(user, expiration, "", tocookie.WithMACer(macer))
+//
+// It speaks the Vault HTTP API itself, so it has no external dependencies.
+package vaultkeys
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultTimeout is how long a Transit request may take, if Timeout isn't set.
+const DefaultTimeout = 5 * time.Second
+
+// DefaultField is the field of the KV secret which holds the cookie secret, if Field isn't set.
+const DefaultField = "secret"
+
+// KV is a tocookie.KeyProvider which reads the secret from a Vault KV version 2 engine.
+type KV struct {
+	// Addr is the address of Vault, e.g. "https://vault.example.net:8200".
+	Addr string
+	// Token authenticates to Vault, and must be able to read the secret.
+	Token string
+	// Mount is the path the engine is mounted at. If empty, "secret" is used.
+	Mount string
+	// Path is the path of the secret within the engine.
+	Path string
+	// Field is the field of the secret which holds the cookie secret. If empty, DefaultField is used.
+	Field string
+	// HTTPClient makes the requests. If nil, http.DefaultClient is used.
+	HTTPClient *http.Client
+}
+
+// Secret returns the current version of the secret.
+func (kv *KV) Secret(ctx context.Context) (string, error) {
+	resp := struct {
+		Data struct {
+			Data map[string]interface{} `json:"data"`
+		} `json:"data"`
+	}{}
+	mount := kv.Mount
+	if mount == "" {
+		mount = "secret"
+	}
+	path := "/v1/" + strings.Trim(mount, "/") + "/data/" + strings.Trim(kv.Path, "/")
+	if err := do(ctx, kv.HTTPClient, http.MethodGet, kv.Addr, path, kv.Token, nil, &resp); err != nil {
+		return "", err
+	}
+	field := kv.Field
+	if field == "" {
+		field = DefaultField
+	}
+	secret, ok := resp.Data.Data[field].(string)
+	if !ok {
+		return "", fmt.Errorf("vault secret %s has no string field '%s'", kv.Path, field)
+	}
+	return secret, nil
+}
+
+// Transit is a tocookie.MACer which has the Vault transit engine compute and verify HMACs with a named key, so the key never leaves Vault. The MAC is the version of the key, as 4 big-endian bytes, followed by the HMAC-SHA256, so cookies keep verifying with the key version they were signed with after the key is rotated in Vault, until its min_decryption_version passes them.
+type Transit struct {
+	// Addr is the address of Vault, e.g. "https://vault.example.net:8200".
+	Addr string
+	// Token authenticates to Vault, and must be able to use the hmac and verify endpoints of the key.
+	Token string
+	// Mount is the path the engine is mounted at. If empty, "transit" is used.
+	Mount string
+	// Key is the name of the transit key.
+	Key string
+	// HTTPClient makes the requests. If nil, http.DefaultClient is used.
+	HTTPClient *http.Client
+	// Timeout is how long a request may take. If 0, DefaultTimeout is used.
+	Timeout time.Duration
+}
+
+// MAC returns the HMAC-SHA256 of the message with the transit key.
+func (t *Transit) MAC(message []byte) ([]byte, error) {
+	resp := struct {
+		Data struct {
+			HMAC string `json:"hmac"`
+		} `json:"data"`
+	}{}
+	req := map[string]string{"input": base64.StdEncoding.EncodeToString(message)}
+	if err := t.do("hmac", req, &resp); err != nil {
+		return nil, err
+	}
+	mac, err := decodeHMAC(resp.Data.HMAC)
+	if err != nil {
+		return nil, fmt.Errorf("vault returned malformed hmac: %w", err)
+	}
+	return mac, nil
+}
+
+// VerifyMAC returns whether mac is an HMAC-SHA256 of the message with a version of the transit key.
+func (t *Transit) VerifyMAC(message, mac []byte) (bool, error) {
+	hmac, ok := encodeHMAC(mac)
+	if !ok {
+		return false, nil
+	}
+	resp := struct {
+		Data struct {
+			Valid bool `json:"valid"`
+		} `json:"data"`
+	}{}
+	req := map[string]string{"input": base64.StdEncoding.EncodeToString(message), "hmac": hmac}
+	if err := t.do("verify", req, &resp); err != nil {
+		return false, err
+	}
+	return resp.Data.Valid, nil
+}
+
+func (t *Transit) do(op string, req interface{}, resp interface{}) error {
+	timeout := t.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	mount := t.Mount
+	if mount == "" {
+		mount = "transit"
+	}
+	path := "/v1/" + strings.Trim(mount, "/") + "/" + op + "/" + url.PathEscape(t.Key) + "/sha2-256"
+	return do(ctx, t.HTTPClient, http.MethodPost, t.Addr, path, t.Token, req, resp)
+}
+
+// hmacPrefix is the prefix of the HMACs of the transit engine, which is followed by the key version, a colon, and the base64 HMAC.
+const hmacPrefix = "vault:v"
+
+// decodeHMAC returns the binary form of an HMAC of the transit engine: its key version, as 4 big-endian bytes, followed by the raw HMAC.
+func decodeHMAC(s string) ([]byte, error) {
+	if !strings.HasPrefix(s, hmacPrefix) {
+		return nil, fmt.Errorf("missing prefix '%s'", hmacPrefix)
+	}
+	parts := strings.SplitN(s[len(hmacPrefix):], ":", 2)
+	if len(parts) != 2 {
+		return nil, errors.New("missing key version")
+	}
+	version, err := strconv.ParseUint(parts[0], 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("key version: %w", err)
+	}
+	raw, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("decoding base64: %w", err)
+	}
+	mac := make([]byte, 4, 4+len(raw))
+	binary.BigEndian.PutUint32(mac, uint32(version))
+	return append(mac, raw...), nil
+}
+
+// encodeHMAC returns the transit engine form of a binary HMAC of decodeHMAC, and whether it is one.
+func encodeHMAC(mac []byte) (string, bool) {
+	if len(mac) <= 4 {
+		return "", false
+	}
+	version := binary.BigEndian.Uint32(mac)
+	return hmacPrefix + strconv.FormatUint(uint64(version), 10) + ":" + base64.StdEncoding.EncodeToString(mac[4:]), true
+}
+
+// maxResponseSize is the largest Vault response read.
+const maxResponseSize = 1 << 20
+
+// do makes a Vault API request, with the JSON of req as its body if it isn't nil, and decodes the JSON response into resp.
+func do(ctx context.Context, client *http.Client, method, addr, path, token string, req interface{}, resp interface{}) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	var body io.Reader
+	if req != nil {
+		b, err := json.Marshal(req)
+		if err != nil {
+			return fmt.Errorf("encoding vault request: %w", err)
+		}
+		body = bytes.NewReader(b)
+	}
+	httpReq, err := http.NewRequest(method, strings.TrimRight(addr, "/")+path, body)
+	if err != nil {
+		return fmt.Errorf("creating vault request: %w", err)
+	}
+	httpReq = httpReq.WithContext(ctx)
+	httpReq.Header.Set("X-Vault-Token", token)
+	if req != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+	httpResp, err := client.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("requesting vault: %w", err)
+	}
+	defer httpResp.Body.Close()
+	b, err := ioutil.ReadAll(io.LimitReader(httpResp.Body, maxResponseSize))
+	if err != nil {
+		return fmt.Errorf("reading vault response: %w", err)
+	}
+	if httpResp.StatusCode != http.StatusOK {
+		return fmt.Errorf("vault %s %s returned %d: %s", method, path, httpResp.StatusCode, vaultErrors(b))
+	}
+	if err := json.Unmarshal(b, resp); err != nil {
+		return fmt.Errorf("decoding vault response: %w", err)
+	}
+	return nil
+}
+
+// vaultErrors returns the errors of a Vault error response, or the response itself if it has none.
+func vaultErrors(body []byte) string {
+	resp := struct {
+		Errors []string `json:"errors"`
+	}{}
+	if err := json.Unmarshal(body, &resp); err != nil || len(resp.Errors) == 0 {
+		return strings.TrimSpace(string(body))
+	}
+	return strings.Join(resp.Errors, "; ")
+}
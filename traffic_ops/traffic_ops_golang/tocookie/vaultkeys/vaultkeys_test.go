@@ -0,0 +1,158 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vaultkeys
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/tocookie"
+)
+
+const testToken = "s.testtoken"
+
+// fakeVault serves the endpoints of the KV version 2 and transit engines used here, with transit keys of the given versions, the latest last.
+func fakeVault(t *testing.T, kv map[string]interface{}, keys ...string) *httptest.Server {
+	sum := func(version int, input string) string {
+		mac := hmac.New(sha256.New, []byte(keys[version-1]))
+		msg, _ := base64.StdEncoding.DecodeString(input)
+		mac.Write(msg)
+		return "vault:v" + strconv.Itoa(version) + ":" + base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != testToken {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+		req := map[string]string{}
+		if r.Method == http.MethodPost {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				t.Errorf("vault request expected JSON body, actual: %v", err)
+			}
+		}
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/v1/secret/data/traffic_ops/cookie":
+			json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]interface{}{"data": kv}})
+		case r.Method == http.MethodPost && r.URL.Path == "/v1/transit/hmac/cookie/sha2-256":
+			json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]string{"hmac": sum(len(keys), req["input"])}})
+		case r.Method == http.MethodPost && r.URL.Path == "/v1/transit/verify/cookie/sha2-256":
+			valid := false
+			if parts := strings.SplitN(strings.TrimPrefix(req["hmac"], "vault:v"), ":", 2); len(parts) == 2 {
+				if version, err := strconv.Atoi(parts[0]); err == nil && version >= 1 && version <= len(keys) {
+					valid = hmac.Equal([]byte(sum(version, req["input"])), []byte(req["hmac"]))
+				}
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]bool{"valid": valid}})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors":[]}`))
+		}
+	}))
+}
+
+func TestKV(t *testing.T) {
+	srv := fakeVault(t, map[string]interface{}{"secret": "kv secret", "other": 1})
+	defer srv.Close()
+
+	kv := &KV{Addr: srv.URL, Token: testToken, Path: "traffic_ops/cookie"}
+	secret, err := tocookie.LoadSecret(context.Background(), kv)
+	if err != nil || secret != "kv secret" {
+		t.Errorf("KV expected 'kv secret', actual: '%v' %v", secret, err)
+	}
+	for name, kv := range map[string]*KV{
+		"non-string field": {Addr: srv.URL, Token: testToken, Path: "traffic_ops/cookie", Field: "other"},
+		"missing field":    {Addr: srv.URL, Token: testToken, Path: "traffic_ops/cookie", Field: "missing"},
+		"missing secret":   {Addr: srv.URL, Token: testToken, Path: "traffic_ops/missing"},
+		"bad token":        {Addr: srv.URL, Token: "wrong", Path: "traffic_ops/cookie"},
+	} {
+		if _, err := kv.Secret(context.Background()); err == nil {
+			t.Errorf("KV with %v expected error, actual: nil", name)
+		}
+	}
+	if _, err := (&KV{Addr: srv.URL, Token: "wrong", Path: "traffic_ops/cookie"}).Secret(context.Background()); err == nil || !strings.Contains(err.Error(), "permission denied") {
+		t.Errorf("KV with bad token expected vault error, actual: %v", err)
+	}
+}
+
+func TestTransit(t *testing.T) {
+	srv := fakeVault(t, nil, "key v1")
+	defer srv.Close()
+
+	macer := &Transit{Addr: srv.URL, Token: testToken, Key: "cookie"}
+	expiration := time.Now().Add(time.Minute)
+	cookie := tocookie.New("alice", expiration, "", tocookie.WithMACer(macer))
+	if cookie == "" {
+		t.Fatalf("New with Transit expected cookie, actual: empty")
+	}
+	if c, err := tocookie.Parse("", cookie, tocookie.WithMACer(macer)); err != nil || c.AuthData != "alice" {
+		t.Errorf("Parse with Transit expected alice, actual: %+v %v", c, err)
+	}
+	forged := cookie[:len(cookie)-1] + "0"
+	if strings.HasSuffix(cookie, "0") {
+		forged = cookie[:len(cookie)-1] + "1"
+	}
+	if _, err := tocookie.Parse("", forged, tocookie.WithMACer(macer)); !errors.Is(err, tocookie.ErrBadSignature) {
+		t.Errorf("Parse with Transit of tampered cookie expected ErrBadSignature, actual: %v", err)
+	}
+
+	rotated := fakeVault(t, nil, "key v1", "key v2")
+	defer rotated.Close()
+	macer.Addr = rotated.URL
+	if _, err := tocookie.Parse("", cookie, tocookie.WithMACer(macer)); err != nil {
+		t.Errorf("Parse with Transit after rotation expected nil error, actual: %v", err)
+	}
+	mac, err := macer.MAC([]byte("message"))
+	if err != nil || len(mac) != 4+sha256.Size || mac[3] != 2 {
+		t.Errorf("MAC after rotation expected key version 2 and HMAC-SHA256, actual: %x %v", mac, err)
+	}
+
+	down := &Transit{Addr: srv.URL, Token: "wrong", Key: "cookie"}
+	if _, err := tocookie.Parse("", cookie, tocookie.WithMACer(down)); err == nil || errors.Is(err, tocookie.ErrBadSignature) {
+		t.Errorf("Parse with failing Transit expected vault error, actual: %v", err)
+	}
+	if cookie := tocookie.New("alice", expiration, "", tocookie.WithMACer(down)); cookie != "" {
+		t.Errorf("New with failing Transit expected empty string, actual: '%v'", cookie)
+	}
+}
+
+func TestHMACEncoding(t *testing.T) {
+	for _, s := range []string{"vault:v1:AAEC", "vault:v4294967295:/w=="} {
+		mac, err := decodeHMAC(s)
+		if err != nil {
+			t.Errorf("decodeHMAC('%v') expected nil error, actual: %v", s, err)
+			continue
+		}
+		if actual, ok := encodeHMAC(mac); !ok || actual != s {
+			t.Errorf("encodeHMAC expected '%v', actual: '%v'", s, actual)
+		}
+	}
+	for _, s := range []string{"", "v1:AAEC", "vault:v1", "vault:vx:AAEC", "vault:v4294967296:AAEC", "vault:v1:!"} {
+		if _, err := decodeHMAC(s); err == nil {
+			t.Errorf("decodeHMAC('%v') expected error, actual: nil", s)
+		}
+	}
+	if _, ok := encodeHMAC([]byte{0, 0, 0, 1}); ok {
+		t.Errorf("encodeHMAC of version without HMAC expected false, actual: true")
+	}
+}
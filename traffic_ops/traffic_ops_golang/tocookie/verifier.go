@@ -0,0 +1,195 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"encoding"
+	"fmt"
+	"hash"
+	"strings"
+	"sync"
+)
+
+// maxVerifierTagSize is the largest HMAC tag a Verifier checks itself, which is the size of SHA-512. Verifiers of larger hashes verify like ValidateSignatureOnly.
+const maxVerifierTagSize = 64
+
+// Verifier verifies the signatures of cookies with a fixed secret and options, exactly as ValidateSignatureOnly does, for the hot paths of edge filters and services which check the signature of every request's cookie before anything else. See BenchmarkVerifier. It is safe for concurrent use.
+//
+// The HMAC key schedule, the states of the hash having absorbed the padded key, is computed once, with the associated data of WithAssociatedData, so verifying a cookie only hashes its signed text and the inner digest. The hash states are pooled, and the hex signature is decoded in place, so authentic cookies are verified without allocating. Cookies which don't verify are only split, to return the same errors as ValidateSignatureOnly, so forged cookies aren't hashed twice.
+//
+// Options the key schedule can't capture, WithPerUserKeys, WithMACer, WithLegacyHashes, KeySeparationCompat, WithTrim, WithURLEncoding, WithPadding, and WithStrict, and hashes whose state can't be marshalled, make the Verifier verify like ValidateSignatureOnly.
+type Verifier struct {
+	secret string
+	o      *options
+	// inner and outer are the marshalled states of the hash having absorbed the key xor ipad, and the associated data, and the key xor opad. They are nil if the Verifier verifies like ValidateSignatureOnly.
+	inner []byte
+	outer []byte
+	size  int
+	// states are *verifierStates.
+	states sync.Pool
+}
+
+// marshalableHash is a hash whose state can be saved and restored, as those of SHA-1, SHA-256, and SHA-512 can.
+type marshalableHash interface {
+	hash.Hash
+	encoding.BinaryMarshaler
+	encoding.BinaryUnmarshaler
+}
+
+// verifierState is the hash states and buffers of one Verifier.Verify.
+type verifierState struct {
+	inner marshalableHash
+	outer marshalableHash
+	// text holds the signed text, as hashes can't be written strings without copying them.
+	text []byte
+	sum  []byte
+	sig  [maxVerifierTagSize]byte
+}
+
+// NewVerifier returns a Verifier which verifies the signatures of cookies signed with the secret, with the given options, which are the same as those of ValidateSignatureOnly.
+func NewVerifier(secret string, opts ...Option) *Verifier {
+	v := &Verifier{secret: secret, o: newOptions(opts)}
+	if !v.o.keySchedulable() {
+		return v
+	}
+	v.inner, v.outer, v.size = v.o.keySchedule(v.o.macKey([]byte(secret)))
+	v.states.New = func() interface{} {
+		return &verifierState{inner: v.o.hash.New().(marshalableHash), outer: v.o.hash.New().(marshalableHash)}
+	}
+	return v
+}
+
+// Verify returns whether the cookie's signature is authentic, with the same errors as ValidateSignatureOnly with the Verifier's secret and options. A nil error doesn't mean the cookie may be accepted; it may have expired long ago.
+func (v *Verifier) Verify(cookie string) error {
+	if v.inner == nil {
+		return validateSignatureOnly(v.secret, cookie, v.o)
+	}
+	if err := v.o.precheck(cookie); err != nil {
+		return err
+	}
+	version, _ := splitVersion(cookie)
+	if version > Version2 || v.o.versionPolicy != nil && !v.o.versionPolicy.Accepts(version) {
+		// rejected by splitCookie, before any HMAC is computed.
+		return validateSignatureOnly(v.secret, cookie, v.o)
+	}
+	if v.matches(cookie) {
+		return nil
+	}
+	return v.mismatch(cookie)
+}
+
+// matches returns whether the HMAC tag of the cookie, which passed precheck, of the precomputed key schedule, matches its signature.
+func (v *Verifier) matches(cookie string) bool {
+	// precheck found a "--" followed by an even number of hex digits, which every version signs the cookie before.
+	sepPos := strings.LastIndex(cookie, "--")
+	sigHex := cookie[sepPos+2:]
+	if len(sigHex)/2 > v.size {
+		return false
+	}
+
+	st := v.states.Get().(*verifierState)
+	sig := decodeHexInto(st.sig[:], sigHex)
+	if err := st.inner.UnmarshalBinary(v.inner); err != nil {
+		panic("restoring hmac inner state: " + err.Error()) // only possible if the hash can't restore the state it marshalled
+	}
+	st.text = append(st.text[:0], cookie[:sepPos]...)
+	st.inner.Write(st.text)
+	st.sum = st.inner.Sum(st.sum[:0])
+	if err := st.outer.UnmarshalBinary(v.outer); err != nil {
+		panic("restoring hmac outer state: " + err.Error())
+	}
+	st.outer.Write(st.sum)
+	st.sum = st.outer.Sum(st.sum[:0])
+	ok := v.o.tagMatches(sig, st.sum)
+	v.states.Put(st)
+	return ok
+}
+
+// mismatch returns the error of a cookie whose tag didn't match, as validateSignatureOnly returns it, without computing its HMAC again: the error of splitting it, if it can't be, and ErrBadSignature, or a SignatureError given WithSignatureDiagnostics, if it can.
+func (v *Verifier) mismatch(cookie string) error {
+	s, err := splitCookie(cookie, v.o)
+	if err != nil {
+		return err
+	}
+	if s.header.Alg != "" {
+		return fmt.Errorf("%w: cookie signed with %s, not an HMAC", ErrBadSignature, s.header.Alg)
+	}
+	return v.o.badSignature(s, []byte(v.secret))
+}
+
+// keySchedulable returns whether a Verifier with the options can precompute its key schedule, rather than verifying like ValidateSignatureOnly.
+func (o *options) keySchedulable() bool {
+	if o.perUserKeys || o.macer != nil || o.publicKey != nil || len(o.legacyHashes) > 0 || o.keySeparation == KeySeparationCompat {
+		return false
+	}
+	if o.trim || o.urlEncoding || o.paddingSet || o.strict {
+		return false
+	}
+	if !o.hash.Available() || o.hash.Size() > maxVerifierTagSize {
+		return false
+	}
+	_, ok := o.hash.New().(marshalableHash)
+	return ok
+}
+
+// keySchedule returns the marshalled states of the configured hash having absorbed the HMAC key xor ipad followed by the associated data, and the key xor opad, as RFC 2104 computes them, along with the size of the hash.
+func (o *options) keySchedule(key []byte) ([]byte, []byte, int) {
+	inner := o.hash.New().(marshalableHash)
+	outer := o.hash.New().(marshalableHash)
+	if len(key) > inner.BlockSize() {
+		inner.Write(key)
+		key = inner.Sum(nil)
+		inner.Reset()
+	}
+	ipad := make([]byte, inner.BlockSize())
+	opad := make([]byte, outer.BlockSize())
+	copy(ipad, key)
+	copy(opad, key)
+	for i := range ipad {
+		ipad[i] ^= 0x36
+	}
+	for i := range opad {
+		opad[i] ^= 0x5c
+	}
+	inner.Write(ipad)
+	o.writeAAD(inner)
+	outer.Write(opad)
+	innerState, err := inner.MarshalBinary()
+	if err != nil {
+		panic("marshalling hmac inner state: " + err.Error()) // only possible if keySchedulable was wrong
+	}
+	outerState, err := outer.MarshalBinary()
+	if err != nil {
+		panic("marshalling hmac outer state: " + err.Error())
+	}
+	return innerState, outerState, inner.Size()
+}
+
+// decodeHexInto decodes the hex string, which must be of an even number of hex digits, as precheck checks, into dst, which must be long enough, without converting it to bytes first, and returns the decoded bytes.
+func decodeHexInto(dst []byte, s string) []byte {
+	n := len(s) / 2
+	for i := 0; i < n; i++ {
+		dst[i] = fromHexChar(s[2*i])<<4 | fromHexChar(s[2*i+1])
+	}
+	return dst[:n]
+}
+
+func fromHexChar(c byte) byte {
+	switch {
+	case c <= '9':
+		return c - '0'
+	case c <= 'F':
+		return c - 'A' + 10
+	}
+	return c - 'a' + 10
+}
@@ -0,0 +1,36 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !race
+// +build !race
+
+package tocookie
+
+import (
+	"crypto"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestVerifierDoesNotAllocate is skipped by the race detector, which drops items put in a sync.Pool at random, so the hash states are allocated again.
+func TestVerifierDoesNotAllocate(t *testing.T) {
+	for _, hash := range []crypto.Hash{crypto.SHA1, crypto.SHA256, crypto.SHA512} {
+		opts := []Option{WithHash(hash), WithAssociatedData([]byte("host"))}
+		cookie := New(strings.Repeat("u", 512), time.Now().Add(time.Hour), "secret", opts...)
+		v := NewVerifier("secret", opts...)
+		v.Verify(cookie)
+		if allocs := testing.AllocsPerRun(100, func() { v.Verify(cookie) }); allocs != 0 {
+			t.Errorf("Verifier.Verify with %v expected no allocations, actual: %v", hash, allocs)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"crypto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestVerifierMatchesValidateSignatureOnly(t *testing.T) {
+	secret := "secret"
+	longSecret := strings.Repeat("s", 200)
+	expiration := time.Now().Add(time.Minute)
+	cookie := New("alice", expiration, secret)
+	sepPos := strings.LastIndex(cookie, "--")
+	tests := map[string]struct {
+		secret    string
+		cookie    string
+		opts      []Option
+		scheduled bool
+	}{
+		"version 0":        {secret, cookie, nil, true},
+		"mojolicious":      {"mysecret", perlCookies[`{"auth_data":"operator1","expires":4102444800}`], nil, true},
+		"version 1":        {secret, New("alice", expiration, secret, WithVersion(Version1), WithHash(crypto.SHA256)), []Option{WithHash(crypto.SHA256)}, true},
+		"version 2":        {secret, New("alice", expiration, secret, WithVersion(Version2), WithHash(crypto.SHA512)), []Option{WithHash(crypto.SHA512)}, true},
+		"compressed":       {secret, New("alice", expiration, secret, WithCompression(CodecGzip)), nil, true},
+		"encrypted":        {secret, New("alice", expiration, secret, WithEncryption()), nil, true},
+		"upper case hex":   {secret, cookie[:sepPos] + strings.ToUpper(cookie[sepPos:]), nil, true},
+		"long secret":      {longSecret, New("alice", expiration, longSecret), nil, true},
+		"aad":              {secret, New("alice", expiration, secret, WithAssociatedData([]byte("host"))), []Option{WithAssociatedData([]byte("host"))}, true},
+		"wrong aad":        {secret, New("alice", expiration, secret, WithAssociatedData([]byte("host"))), []Option{WithAssociatedData([]byte("other"))}, true},
+		"truncated tag":    {secret, New("alice", expiration, secret, WithTagLength(MinTagLength)), []Option{WithTagLength(MinTagLength)}, true},
+		"key separation":   {secret, New("alice", expiration, secret, WithKeySeparation(KeySeparationStrict)), []Option{WithKeySeparation(KeySeparationStrict)}, true},
+		"not accepted":     {secret, cookie, []Option{WithVersionPolicy(VersionPolicy{Mint: Version1, Accept: []int{Version1}})}, true},
+		"expired":          {secret, New("alice", time.Now().Add(-time.Minute), secret), nil, true},
+		"wrong secret":     {secret, New("alice", expiration, "wrong"), nil, true},
+		"forged version 2": {secret, New("alice", expiration, "wrong", WithVersion(Version2)), nil, true},
+		"diagnostics":      {secret, New("alice", expiration, "wrong"), []Option{WithSignatureDiagnostics()}, true},
+		"malformed":        {secret, "not a cookie", nil, true},
+		"odd hex":          {secret, cookie + "0", nil, true},
+		"compat":           {secret, cookie, []Option{WithKeySeparation(KeySeparationCompat)}, false},
+		"legacy hash":      {secret, cookie, []Option{WithHash(crypto.SHA256), WithLegacyHashes(crypto.SHA1)}, false},
+		"per user keys":    {secret, New("alice", expiration, secret, WithPerUserKeys()), []Option{WithPerUserKeys()}, false},
+		"trimmed":          {secret, ` "` + cookie + `" `, []Option{WithTrim()}, false},
+		"padded":           {secret, New("alice", expiration, secret, WithPadding('=')), []Option{WithPadding('=')}, false},
+		"unavailable hash": {secret, cookie, []Option{WithHash(crypto.MD4)}, false},
+	}
+	for name, test := range tests {
+		expected := ValidateSignatureOnly(test.secret, test.cookie, test.opts...)
+		v := NewVerifier(test.secret, test.opts...)
+		if scheduled := v.inner != nil; scheduled != test.scheduled {
+			t.Errorf("%v: NewVerifier expected key schedule %v, actual: %v", name, test.scheduled, scheduled)
+		}
+		for i := 0; i < 2; i++ {
+			if err := v.Verify(test.cookie); (err == nil) != (expected == nil) || err != nil && err.Error() != expected.Error() {
+				t.Errorf("%v: Verifier.Verify expected error %v, actual: %v", name, expected, err)
+			}
+		}
+	}
+}
+
+func TestVerifierConcurrent(t *testing.T) {
+	v := NewVerifier("secret", WithHash(crypto.SHA256))
+	wg := sync.WaitGroup{}
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			valid := New("user"+string(rune('a'+i)), time.Now().Add(time.Hour), "secret", WithHash(crypto.SHA256))
+			forged := New("user"+string(rune('a'+i)), time.Now().Add(time.Hour), "wrong", WithHash(crypto.SHA256))
+			for j := 0; j < 100; j++ {
+				if err := v.Verify(valid); err != nil {
+					t.Errorf("Verifier.Verify expected nil error, actual: %v", err)
+				}
+				if err := v.Verify(forged); err == nil {
+					t.Errorf("Verifier.Verify of forged cookie expected error, actual: nil")
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,361 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Cookie format versions. A cookie starting with "v<N>." is version N, and a cookie without a version prefix is version 0. A '.' never appears in a version 0 cookie, because it is in neither base64 alphabet nor the hex signature, so the versions can't be confused.
+//
+// Version registry:
+//
+//	0  <base64 payload>--<hex HMAC of the base64 payload>
+//	   The Mojolicious signed cookie format, read and written by Perl Traffic Ops: the payload is standard base64 with '=' padding replaced by '-', and the padding is part of the signed bytes. Parse also accepts unpadded base64url payloads, as once minted by Go; see WithPadding.
+//	1  v1.<unpadded base64url payload>--<hex HMAC of everything before the last "--">
+//	   The version prefix is signed, so a cookie can't be downgraded by stripping it, and the payload ends at the last "--", so a '-' in the payload is unambiguous.
+//	2  v2.<unpadded base64url JSON header>.<unpadded base64url payload>--<hex HMAC of everything before the last "--">
+//	   As version 1, with a header describing how the payload is encoded, e.g. {"zip":"gzip"} for a compressed payload, or {"enc":"A256GCM"} for an encrypted one. The header is signed along with the payload, and headers with unknown parameters are rejected. New mints version 2 whenever an option needs a header.
+//
+// New versions must be added to this registry and to splitVersioned and encodeSigned. All versions sign with the hash configured by WithHash. Parse must continue to read every registered version.
+const (
+	Version0 = 0
+	Version1 = 1
+	Version2 = 2
+)
+
+// DefaultVersion is the version New mints. It is version 0, so cookies are readable by Perl Traffic Ops.
+const DefaultVersion = Version0
+
+// maxVersion bounds the version number parsed from a cookie, so absurd prefixes can't overflow.
+const maxVersion = 1 << 16
+
+const versionPrefix = "v"
+const versionSep = "."
+
+// WithVersion sets the format version of cookies minted by New. New returns an empty string if the version isn't in the registry. Parse reads all versions regardless of this option; see WithVersionPolicy to also restrict the versions Parse accepts.
+func WithVersion(version int) Option {
+	return func(o *options) { o.version = version }
+}
+
+// splitVersion returns the version of the cookie, and the cookie with the version prefix removed. Anything without a well-formed "v<N>." prefix is version 0, so a version 0 cookie is never rejected for looking like a versioned one.
+func splitVersion(cookie string) (int, string) {
+	if !strings.HasPrefix(cookie, versionPrefix) {
+		return Version0, cookie
+	}
+	sepPos := strings.Index(cookie, versionSep)
+	if sepPos <= len(versionPrefix) {
+		return Version0, cookie
+	}
+	version := 0
+	for _, c := range cookie[len(versionPrefix):sepPos] {
+		if c < '0' || c > '9' || version > maxVersion {
+			return Version0, cookie
+		}
+		version = version*10 + int(c-'0')
+	}
+	return version, cookie[sepPos+len(versionSep):]
+}
+
+// signedCookie is a cookie split into its parts, without its signature having been verified.
+type signedCookie struct {
+	version int
+	// signed is the part of the cookie covered by the signature.
+	signed string
+	// payload is the base64 payload.
+	payload string
+	sig     []byte
+	// header is the header of the cookie, which is empty for versions without headers.
+	header header
+	// key is the key the signature was verified with, which decrypts encrypted payloads. It is nil if the signature hasn't been verified.
+	key []byte
+}
+
+// splitCookie splits a cookie of any registered version into its parts, after trimming and decoding it if WithTrim and WithURLEncoding were given, or a Mojolicious cookie WithStrict. Cookies of versions the VersionPolicy doesn't accept are rejected with ErrVersionNotAccepted.
+func splitCookie(cookie string, o *options) (signedCookie, error) {
+	s, err := splitVersioned(cookie, o)
+	if err != nil {
+		return signedCookie{}, err
+	}
+	if o.versionPolicy != nil && !o.versionPolicy.Accepts(s.version) {
+		return signedCookie{}, fmt.Errorf("%w: version %d", ErrVersionNotAccepted, s.version)
+	}
+	return s, nil
+}
+
+func splitVersioned(cookie string, o *options) (signedCookie, error) {
+	if err := o.checkCookieSize(cookie); err != nil {
+		return signedCookie{}, err
+	}
+	if o.strict {
+		return splitStrict(cookie, o)
+	}
+	cookie = o.unescapeCookie(o.trimCookie(cookie))
+	version, body := splitVersion(cookie)
+	switch version {
+	case Version0:
+		if o.paddingSet {
+			return splitV0Padded(body)
+		}
+		return splitV0(body)
+	case Version1:
+		return splitV1(cookie, body)
+	case Version2:
+		return splitV2(cookie, body)
+	}
+	return signedCookie{}, fmt.Errorf("%w: unsupported cookie version %d", ErrMalformed, version)
+}
+
+// verify checks the signature of the cookie against the key.
+func (s signedCookie) verify(key []byte, o *options) error {
+	if o.publicKey != nil {
+		return s.verifyAsymmetric(o.publicKey)
+	}
+	if s.header.Alg != "" {
+		return fmt.Errorf("%w: cookie signed with %s, not an HMAC", ErrBadSignature, s.header.Alg)
+	}
+	if o.macer != nil {
+		valid, err := o.macer.VerifyMAC(o.withAAD([]byte(s.signed)), s.sig)
+		if err != nil {
+			return fmt.Errorf("verifying signature: %w", err)
+		}
+		if !valid {
+			return ErrBadSignature
+		}
+		return nil
+	}
+	if err := o.checkHash(); err != nil {
+		return err
+	}
+	if !checkHmac([]byte(s.signed), s.sig, key, o) && !o.verifiesLegacy([]byte(s.signed), s.sig, key) {
+		return o.badSignature(s, key)
+	}
+	return nil
+}
+
+// checkHash returns an error if the configured hash isn't linked into the binary.
+func (o *options) checkHash() error {
+	if !o.hash.Available() {
+		return fmt.Errorf("unsupported hash %v", o.hash)
+	}
+	return nil
+}
+
+// decodePayload returns the decoded payload, decrypted and decompressed if the header says it's encrypted and compressed. The payload must not be trusted unless verify succeeded.
+func (s signedCookie) decodePayload(o *options) ([]byte, error) {
+	return s.decodePayloadInto(nil, []byte(s.payload), o)
+}
+
+// decodePayloadInto is decodePayload, decoding the payload, given as text, into dst, which is grown if need be. Compressed payloads are decompressed into new memory.
+func (s signedCookie) decodePayloadInto(dst, text []byte, o *options) ([]byte, error) {
+	encoding := base64.RawURLEncoding
+	if o.canonicalBase64 {
+		encoding = canonicalURLEncoding
+	}
+	if s.version == Version0 {
+		if o.strict {
+			return decodeStrict(dst, text)
+		}
+		if !o.paddingSet {
+			if o.canonicalBase64 {
+				return decodeV0Payload(dst, text, canonicalV0FallbackEncodings)
+			}
+			return decodeV0Payload(dst, text, v0FallbackEncodings)
+		}
+		var err error
+		if encoding, err = o.v0Encoding(); err != nil {
+			return nil, err
+		}
+		if o.canonicalBase64 {
+			encoding = encoding.Strict()
+		}
+	}
+	txtBytes, err := decodeBase64(dst, encoding, text)
+	if err != nil {
+		return nil, fmt.Errorf("%w: error decoding base64 data: %w", ErrMalformed, err)
+	}
+	if s.header.Enc != "" {
+		if txtBytes, err = open(txtBytes, s.key, s.header.Enc); err != nil {
+			return nil, err
+		}
+	}
+	if s.header.Zip != "" {
+		return decompress(s.header.Zip, txtBytes, o.maxDecompressedSize)
+	}
+	return txtBytes, nil
+}
+
+// decodeSigned verifies the signature of a cookie of any registered version, and returns its decoded payload.
+func decodeSigned(cookie string, key []byte, o *options) ([]byte, error) {
+	s, err := verifySigned(cookie, key, o)
+	if err != nil {
+		return nil, err
+	}
+	return s.decodePayload(o)
+}
+
+// verifySigned splits a cookie of any registered version, and verifies its signature against the key.
+func verifySigned(cookie string, key []byte, o *options) (signedCookie, error) {
+	s, err := splitCookie(cookie, o)
+	if err != nil {
+		return signedCookie{}, err
+	}
+	if err := s.verify(key, o); err != nil {
+		return signedCookie{}, err
+	}
+	s.key = key
+	return s, nil
+}
+
+// encodeSigned serializes and signs the payload in the configured version.
+func encodeSigned(msg, key []byte, o *options) string {
+	if !o.hash.Available() || o.macer != nil && (o.encrypt || o.perUserKeys) {
+		return ""
+	}
+	version := o.version
+	if o.needsHeader() && version < Version2 {
+		version = Version2
+	}
+	switch version {
+	case Version0:
+		return o.escapeCookie(encodeV0(msg, key, o))
+	case Version1:
+		return o.escapeCookie(encodeV1(msg, key, o))
+	case Version2:
+		return o.escapeCookie(encodeV2(msg, key, o))
+	}
+	return ""
+}
+
+func encodeV0(msg, key []byte, o *options) string {
+	encoding, err := o.v0Encoding()
+	if err != nil {
+		return ""
+	}
+	return signCookie(encoding.EncodeToString(msg), key, o)
+}
+
+func encodeV1(msg, key []byte, o *options) string {
+	return signCookie(versionPrefix+strconv.Itoa(Version1)+versionSep+base64.RawURLEncoding.EncodeToString(msg), key, o)
+}
+
+// signCookie returns the signed part of a cookie followed by "--" and its hex signature, made with the private key of WithSigner or the MACer of WithMACer if there is one, and with an HMAC of the key otherwise. It returns an empty string if the signature can't be made.
+func signCookie(signed string, key []byte, o *options) string {
+	var sig []byte
+	var err error
+	switch {
+	case o.signer != nil:
+		sig, err = o.signAsymmetric([]byte(signed))
+	case o.macer != nil:
+		sig, err = o.macer.MAC(o.withAAD([]byte(signed)))
+	default:
+		sig = o.sign([]byte(signed), key)
+	}
+	if err != nil {
+		return ""
+	}
+	return signed + "--" + hex.EncodeToString(sig)
+}
+
+// splitV1 splits a version 1 cookie into its parts. The body is the cookie without its version prefix.
+func splitV1(cookie, body string) (signedCookie, error) {
+	sigPos := strings.LastIndex(body, "--")
+	if sigPos == -1 {
+		return signedCookie{}, fmt.Errorf("%w '%s' -- no signature", ErrMalformed, cookie)
+	}
+	sigBytes, err := hex.DecodeString(body[sigPos+2:])
+	if err != nil {
+		return signedCookie{}, fmt.Errorf("%w: error decoding signature: %w", ErrMalformed, err)
+	}
+	return signedCookie{version: Version1, signed: cookie[:len(cookie)-len(body)+sigPos], payload: body[:sigPos], sig: sigBytes}, nil
+}
+
+// header is the parameters of a version 2 cookie.
+type header struct {
+	// Zip is the name of the Codec the payload is compressed with.
+	Zip string `json:"zip,omitempty"`
+	// Kid is the ID of the key the cookie is signed with; see WithKeyID.
+	Kid string `json:"kid,omitempty"`
+	// Enc is the encryption the payload is sealed with, after compression; see WithEncryption.
+	Enc string `json:"enc,omitempty"`
+	// Alg is the algorithm of the signature, if it isn't an HMAC; see WithSigner.
+	Alg string `json:"alg,omitempty"`
+}
+
+// needsHeader returns whether cookies minted with the options need a version 2 header.
+func (o *options) needsHeader() bool {
+	return o.compression != "" || o.keyID != "" || o.encrypt || o.signer != nil
+}
+
+// encodeV2 serializes and signs the payload as a version 2 cookie, returning an empty string if the payload can't be encoded as configured.
+func encodeV2(msg, key []byte, o *options) string {
+	hdr := header{Kid: o.keyID}
+	if o.signer != nil {
+		if hdr.Alg = signerAlg(o.signer); hdr.Alg == "" || o.encrypt || o.perUserKeys {
+			return ""
+		}
+	}
+	if o.compression != "" {
+		compressed, codec, err := o.compressPayload(msg)
+		if err != nil {
+			return ""
+		}
+		msg, hdr.Zip = compressed, codec
+	}
+	if o.encrypt {
+		if o.perUserKeys {
+			return ""
+		}
+		hdr.Enc = EncryptionAES256GCM
+		sealed, err := seal(msg, key)
+		if err != nil {
+			return ""
+		}
+		msg = sealed
+	}
+	hdrBytes, err := json.Marshal(hdr)
+	if err != nil {
+		return ""
+	}
+	signed := versionPrefix + strconv.Itoa(Version2) + versionSep + base64.RawURLEncoding.EncodeToString(hdrBytes) + versionSep + base64.RawURLEncoding.EncodeToString(msg)
+	return signCookie(signed, key, o)
+}
+
+// splitV2 splits a version 2 cookie into its parts. The body is the cookie without its version prefix.
+func splitV2(cookie, body string) (signedCookie, error) {
+	hdrEnd := strings.Index(body, versionSep)
+	if hdrEnd == -1 {
+		return signedCookie{}, fmt.Errorf("%w '%s' -- no header", ErrMalformed, cookie)
+	}
+	s, err := splitV1(cookie, body[hdrEnd+len(versionSep):])
+	if err != nil {
+		return signedCookie{}, err
+	}
+	s.version = Version2
+	hdrBytes, err := base64.RawURLEncoding.DecodeString(body[:hdrEnd])
+	if err != nil {
+		return signedCookie{}, fmt.Errorf("%w: error decoding header: %w", ErrMalformed, err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(hdrBytes))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&s.header); err != nil {
+		return signedCookie{}, fmt.Errorf("%w: error decoding header '%s': %w", ErrMalformed, string(hdrBytes), err)
+	}
+	return s, nil
+}
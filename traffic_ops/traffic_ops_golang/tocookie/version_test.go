@@ -0,0 +1,116 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWithVersion(t *testing.T) {
+	secret := "secret"
+	for _, version := range []int{Version0, Version1} {
+		cookie := New("alice", time.Now().Add(time.Minute), secret, WithVersion(version))
+		if version == Version1 && !strings.HasPrefix(cookie, "v1.") {
+			t.Errorf("New version 1 expected prefix 'v1.', actual: '%v'", cookie)
+		}
+		c, err := Parse(secret, cookie)
+		if err != nil {
+			t.Errorf("Parse version %v expected nil error, actual: %v", version, err)
+			continue
+		}
+		if c.AuthData != "alice" {
+			t.Errorf("Parse version %v expected AuthData 'alice', actual: '%v'", version, c.AuthData)
+		}
+	}
+
+	if cookie := New("alice", time.Now().Add(time.Minute), secret, WithVersion(99)); cookie != "" {
+		t.Errorf("New unsupported version expected empty cookie, actual: '%v'", cookie)
+	}
+}
+
+func TestParseVersionRejects(t *testing.T) {
+	secret := "secret"
+	v1 := New("alice", time.Now().Add(time.Minute), secret, WithVersion(Version1))
+	tests := map[string]string{
+		"downgraded":  strings.TrimPrefix(v1, "v1."),
+		"upgraded":    "v2." + strings.TrimPrefix(v1, "v1."),
+		"bad version": "vx." + strings.TrimPrefix(v1, "v1."),
+	}
+	for name, cookie := range tests {
+		if _, err := Parse(secret, cookie); err == nil {
+			t.Errorf("%v: Parse expected error, actual nil", name)
+		}
+	}
+}
+
+// newMojoCookie mints a cookie the way Perl Mojolicious does: standard base64 with '=' padding replaced by '-', signed including the padding.
+func newMojoCookie(payload string, secret string) string {
+	value := strings.Replace(base64.StdEncoding.EncodeToString([]byte(payload)), "=", "-", -1)
+	mac := hmac.New(sha1.New, []byte(secret))
+	mac.Write([]byte(value))
+	return value + "--" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestParseDualVersion(t *testing.T) {
+	secret := "secret"
+	expires := strconv.FormatInt(time.Now().Add(time.Minute).Unix(), 10)
+	cookies := map[string]string{
+		"version 0":         New("alice", time.Now().Add(time.Minute), secret),
+		"version 0 perl":    newMojoCookie(`{"auth_data":"alice","expires":`+expires+`}`, secret),
+		"version 0 padding": newMojoCookie(`{"auth_data":"alice","expires":`+expires+`,"x":1}`, secret),
+		"version 1":         New("alice", time.Now().Add(time.Minute), secret, WithVersion(Version1)),
+	}
+	for name, cookie := range cookies {
+		c, err := Parse(secret, cookie)
+		if err != nil {
+			t.Errorf("%v: Parse expected nil error, actual: %v", name, err)
+			continue
+		}
+		if c.AuthData != "alice" {
+			t.Errorf("%v: Parse expected AuthData 'alice', actual: '%v'", name, c.AuthData)
+		}
+	}
+}
+
+func TestSplitVersion(t *testing.T) {
+	tests := map[string]int{
+		"eyJhdXRoX2RhdGEiOiJhIn0--00": Version0,
+		"v1.eyJhIjoxfQ--00":           Version1,
+		"v12.abc--00":                 12,
+		"vGhpcyBpcyBub3QgdjE--00":     Version0,
+		"v.abc--00":                   Version0,
+		"vx.abc--00":                  Version0,
+		"v99999999999999999999.a--00": Version0,
+	}
+	for cookie, expected := range tests {
+		if actual, _ := splitVersion(cookie); actual != expected {
+			t.Errorf("splitVersion('%v') expected %v, actual: %v", cookie, expected, actual)
+		}
+	}
+
+	msg := []byte{0xbe, 0xef}
+	raw := NewRawMsg(msg, []byte("secret"))
+	if !strings.HasPrefix(raw, "v") {
+		t.Fatalf("NewRawMsg expected message starting with 'v', actual: '%v'", raw)
+	}
+	if actual, err := decodeSigned(raw, []byte("secret"), newOptions(nil)); err != nil || string(actual) != string(msg) {
+		t.Errorf("decodeSigned version 0 starting with 'v' expected original message and nil error, actual: %v %v", actual, err)
+	}
+}
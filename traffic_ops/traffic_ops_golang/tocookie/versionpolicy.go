@@ -0,0 +1,85 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"fmt"
+)
+
+// VersionPolicy controls which format versions cookies are accepted and minted in, so a change of format can be rolled out across Perl and Go components in stages, rather than on a flag day. For example, to move from version 0 to version 1:
+//
+//  1. Deploy every component with Accept {Version0, Version1} and Mint Version0, so all of them read the new version before any mints it.
+//  2. Change Mint to Version1. Middleware upgrades the cookies of returning clients as they are refreshed; see NeedsUpgrade.
+//  3. Once every version 0 cookie has expired, change Accept to {Version1}, so version 0 cookies can no longer be used.
+//
+// Perl Traffic Ops only reads version 0, so it must not be sent cookies of other versions until it is replaced.
+type VersionPolicy struct {
+	// Mint is the version New and Refresh mint, as given by WithVersion. New still mints version 2 if an option needs a header.
+	Mint int
+	// Accept are the versions Parse accepts. If it is empty, every registered version is accepted.
+	Accept []int
+}
+
+// WithVersionPolicy makes New and Refresh mint cookies in the Mint version of the policy, and Parse reject cookies of versions it doesn't Accept with ErrVersionNotAccepted. Cookies are rejected for their version before their signature is verified. Given to Middleware, cookies of older versions than the policy mints are refreshed in that version, whether or not they are about to expire.
+func WithVersionPolicy(policy VersionPolicy) Option {
+	policy.Accept = append([]int(nil), policy.Accept...)
+	return func(o *options) {
+		o.version = policy.Mint
+		o.versionPolicy = &policy
+	}
+}
+
+// Accepts returns whether the policy accepts cookies of the version.
+func (p VersionPolicy) Accepts(version int) bool {
+	if len(p.Accept) == 0 {
+		return isRegisteredVersion(version)
+	}
+	for _, accepted := range p.Accept {
+		if accepted == version {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate returns an error if the policy names versions which aren't in the registry, or doesn't accept the version it mints, so a misconfigured policy can be reported at startup rather than by every New and Parse.
+func (p VersionPolicy) Validate() error {
+	if !isRegisteredVersion(p.Mint) {
+		return fmt.Errorf("mint version %d isn't a registered cookie version", p.Mint)
+	}
+	for _, version := range p.Accept {
+		if !isRegisteredVersion(version) {
+			return fmt.Errorf("accepted version %d isn't a registered cookie version", version)
+		}
+	}
+	if !p.Accepts(p.Mint) {
+		return fmt.Errorf("mint version %d isn't accepted", p.Mint)
+	}
+	return nil
+}
+
+// NeedsUpgrade returns whether the cookie is of an older version than the policy mints, so it should be refreshed to upgrade it. The cookie isn't verified.
+func (p VersionPolicy) NeedsUpgrade(cookie string) bool {
+	return CookieVersion(cookie) < p.Mint
+}
+
+// CookieVersion returns the format version of the cookie, as given by its version prefix, without verifying or decoding it. Anything without a version prefix is version 0.
+func CookieVersion(cookie string) int {
+	version, _ := splitVersion(cookie)
+	return version
+}
+
+// isRegisteredVersion returns whether the version is in the registry.
+func isRegisteredVersion(version int) bool {
+	return version >= Version0 && version <= Version2
+}
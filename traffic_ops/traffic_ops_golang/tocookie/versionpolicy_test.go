@@ -0,0 +1,100 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tocookie
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWithVersionPolicy(t *testing.T) {
+	secret := "secret"
+	expires := time.Now().Add(time.Hour)
+	v0 := New("alice", expires, secret)
+	v1 := New("alice", expires, secret, WithVersion(Version1))
+	v2 := New("alice", expires, secret, WithVersion(Version2))
+
+	tests := map[string]struct {
+		policy   VersionPolicy
+		cookie   string
+		accepted bool
+	}{
+		"all v0":          {VersionPolicy{}, v0, true},
+		"all v2":          {VersionPolicy{}, v2, true},
+		"transition v0":   {VersionPolicy{Mint: Version1, Accept: []int{Version0, Version1}}, v0, true},
+		"transition v1":   {VersionPolicy{Mint: Version1, Accept: []int{Version0, Version1}}, v1, true},
+		"transition v2":   {VersionPolicy{Mint: Version1, Accept: []int{Version0, Version1}}, v2, false},
+		"retired v0":      {VersionPolicy{Mint: Version1, Accept: []int{Version1}}, v0, false},
+		"retired v1":      {VersionPolicy{Mint: Version1, Accept: []int{Version1}}, v1, true},
+		"retired forged":  {VersionPolicy{Mint: Version1, Accept: []int{Version1}}, New("alice", expires, "wrong"), false},
+		"unregistered v3": {VersionPolicy{}, "v3." + v1[len("v1."):], false},
+	}
+	for name, test := range tests {
+		_, err := Parse(secret, test.cookie, WithVersionPolicy(test.policy))
+		if test.accepted && err != nil {
+			t.Errorf("%v: Parse expected nil error, actual: %v", name, err)
+		} else if !test.accepted && !errors.Is(err, ErrVersionNotAccepted) && !errors.Is(err, ErrMalformed) {
+			t.Errorf("%v: Parse expected ErrVersionNotAccepted, actual: %v", name, err)
+		}
+	}
+	if _, err := Parse(secret, v0, WithVersionPolicy(VersionPolicy{Mint: Version1, Accept: []int{Version1}})); !errors.Is(err, ErrVersionNotAccepted) || Classify(err) != OutcomeRejected {
+		t.Errorf("Parse of retired version expected rejected ErrVersionNotAccepted, actual: %v", err)
+	}
+	if _, err := NewParser(secret, WithVersionPolicy(VersionPolicy{Accept: []int{Version1}})).Parse(v0); !errors.Is(err, ErrVersionNotAccepted) {
+		t.Errorf("Parser.Parse of retired version expected ErrVersionNotAccepted, actual: %v", err)
+	}
+
+	policy := WithVersionPolicy(VersionPolicy{Mint: Version1, Accept: []int{Version0, Version1}})
+	if cookie := New("alice", expires, secret, policy); CookieVersion(cookie) != Version1 {
+		t.Errorf("New with policy expected version 1, actual: %v", cookie)
+	}
+	c, err := Parse(secret, v0, policy)
+	if err != nil {
+		t.Fatalf("Parse expected nil error, actual: %v", err)
+	}
+	if cookie := Refresh(c, secret, policy); CookieVersion(cookie) != Version1 {
+		t.Errorf("Refresh with policy expected version 1, actual: %v", cookie)
+	}
+}
+
+func TestVersionPolicy(t *testing.T) {
+	valid := map[string]struct {
+		policy VersionPolicy
+		valid  bool
+	}{
+		"default":         {VersionPolicy{}, true},
+		"transition":      {VersionPolicy{Mint: Version1, Accept: []int{Version0, Version1}}, true},
+		"unregistered":    {VersionPolicy{Mint: 7}, false},
+		"unregistered ok": {VersionPolicy{Accept: []int{Version0, 7}}, false},
+		"not accepted":    {VersionPolicy{Mint: Version0, Accept: []int{Version1}}, false},
+	}
+	for name, test := range valid {
+		if err := test.policy.Validate(); (err == nil) != test.valid {
+			t.Errorf("%v: Validate expected valid %v, actual: %v", name, test.valid, err)
+		}
+	}
+
+	policy := VersionPolicy{Mint: Version1}
+	for cookie, expected := range map[string]bool{
+		"eyJhIjoxfQ----00":                              true,
+		"v1.eyJhIjoxfQ--00":                             false,
+		"v2.e30.eyJhIjoxfQ--00":                         false,
+		"vX.eyJhIjoxfQ--00":                             true,
+		New("a", time.Now(), "", WithVersion(Version1)): false,
+	} {
+		if actual := policy.NeedsUpgrade(cookie); actual != expected {
+			t.Errorf("NeedsUpgrade of '%v' expected %v, actual: %v", cookie, expected, actual)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package zstdcodec registers a zstd compression codec for tocookie payloads. It is a separate package so the core tocookie package has no external dependencies. Import it for its side effect:
+//
+//	import _ "github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/tocookie/zstdcodec"
+//
+// and mint cookies with tocookie.WithCompression(zstdcodec.Name).
+package zstdcodec
+
+import (
+	"io"
+
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/tocookie"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Name is the name of the codec, which identifies it in cookies.
+const Name = "zstd"
+
+func init() {
+	tocookie.RegisterCodec(Codec{})
+}
+
+// Codec is the zstd tocookie.Codec.
+type Codec struct{}
+
+func (Codec) Name() string { return Name }
+
+func (Codec) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return zstd.NewWriter(w, zstd.WithEncoderLevel(zstd.SpeedBetterCompression))
+}
+
+// NewReader returns a decoder for the stream. The decoder doesn't limit its output; tocookie.Parse bounds how much it reads.
+func (Codec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	d, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+	if err != nil {
+		return nil, err
+	}
+	return d.IOReadCloser(), nil
+}
@@ -46,9 +46,32 @@ var ServerName = "traffic_ops_golang" + "/" + about.About.Version
 
 // AuthBase ...
 type AuthBase struct {
-	secret                 string
+	// secret signs refreshed cookies.
+	secret string
+	// keys verify cookies. If empty, only secret is used. Like Mojolicious, cookies signed with any of them are accepted, so secrets can be rotated without logging everyone out.
+	keys                   tocookie.KeySet
 	getCurrentUserInfoStmt *sqlx.Stmt
 	override               Middleware
+	// refreshWindow is how long before its expiration a cookie is refreshed. If 0, tocookie.DefaultRefreshWindow is used.
+	refreshWindow time.Duration
+	// maxLifetime is the maximum age of a session, however often it is refreshed. If 0, sessions may be refreshed indefinitely.
+	maxLifetime time.Duration
+	// idleTimeout is how long a refreshed cookie is valid. If 0, tocookie.DefaultDuration is used.
+	idleTimeout time.Duration
+	// leeway is the clock skew tolerated between Traffic Ops servers when checking cookie expiry. If 0, there is none.
+	leeway time.Duration
+}
+
+// sessionConfig returns the lifetime policy of sessions. The refresh window defaults to half the idle timeout, which is tocookie.DefaultRefreshWindow with the default idle timeout.
+func (a AuthBase) sessionConfig() tocookie.Config {
+	return tocookie.Config{MaxLifetime: a.maxLifetime, IdleTimeout: a.idleTimeout, RefreshThreshold: a.refreshWindow, Leeway: a.leeway}
+}
+
+func (a AuthBase) keyRing() tocookie.KeyRing {
+	if id, _ := a.keys.Current(); id == "" {
+		return tocookie.NewKeySetFromSecrets(a.secret)
+	}
+	return a.keys
 }
 
 // GetWrapper ...
@@ -86,9 +109,16 @@ func (a AuthBase) GetWrapper(privLevelRequired int) Middleware {
 				return
 			}
 
-			oldCookie, err := tocookie.Parse(a.secret, cookie.Value)
+			oldCookie, err := tocookie.ParseWithKeyRing(a.keyRing(), cookie.Value, a.sessionConfig().Options()...)
 			if err != nil {
-				log.Errorf("error parsing cookie: %s", err)
+				switch tocookie.Classify(err) {
+				case tocookie.OutcomeInvalid:
+					log.Warnf("rejecting invalid cookie: %s", err)
+				case tocookie.OutcomeExpired:
+					log.Debugf("rejecting expired cookie: %s", err)
+				default:
+					log.Infof("rejecting cookie: %s", err)
+				}
 				handleErr(http.StatusUnauthorized, errors.New("Unauthorized, please log in."))
 				return
 			}
@@ -100,8 +130,13 @@ func (a AuthBase) GetWrapper(privLevelRequired int) Middleware {
 				return
 			}
 
-			newCookieVal := tocookie.Refresh(oldCookie, a.secret)
-			http.SetCookie(w, &http.Cookie{Name: tocookie.Name, Value: newCookieVal, Path: "/", HttpOnly: true})
+			// only refresh cookies about to expire, so every response doesn't carry a Set-Cookie
+			newCookieVal, err := a.sessionConfig().RefreshIfNeeded(oldCookie, a.secret)
+			if err != nil {
+				log.Infof("not refreshing cookie for user '%s': %s", username, err)
+			} else if newCookieVal != "" {
+				http.SetCookie(w, tocookie.HTTPCookie(newCookieVal, time.Time{}))
+			}
 
 			ctx := r.Context()
 			ctx = context.WithValue(ctx, auth.CurrentUserKey, currentUserInfo)
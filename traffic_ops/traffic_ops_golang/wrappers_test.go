@@ -144,7 +144,7 @@ func TestWrapAuth(t *testing.T) {
 		t.Fatalf("could not create priv statement: %v\n", err)
 	}
 
-	authBase := AuthBase{secret, sqlStatement, nil}
+	authBase := AuthBase{secret: secret, getCurrentUserInfoStmt: sqlStatement}
 
 	cookie := tocookie.New(userName, time.Now().Add(time.Minute), secret)
 
@@ -210,4 +210,107 @@ func TestWrapAuth(t *testing.T) {
 	}
 }
 
+func TestWrapAuthRefreshWindow(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close()
+
+	db := sqlx.NewDb(mockDB, "sqlmock")
+	defer db.Close()
+
+	userName := "user1"
+	secret := "secret"
+
+	prepare := mock.ExpectPrepare("SELECT")
+	for i := 0; i < 2; i++ {
+		rows := sqlmock.NewRows([]string{"priv_level", "username", "id", "tenant_id"})
+		rows.AddRow(30, "user1", 1, 1)
+		prepare.ExpectQuery().WithArgs(userName).WillReturnRows(rows)
+	}
+
+	sqlStatement, err := prepareUserInfoStmt(db)
+	if err != nil {
+		t.Fatalf("could not create priv statement: %v\n", err)
+	}
+
+	authBase := AuthBase{secret: secret, getCurrentUserInfoStmt: sqlStatement, refreshWindow: 10 * time.Minute}
+	f := authBase.GetWrapper(15)(func(w http.ResponseWriter, r *http.Request) {})
+
+	tests := map[string]struct {
+		expiry    time.Duration
+		refreshed bool
+	}{
+		"outside window": {time.Hour, false},
+		"inside window":  {time.Minute, true},
+	}
+	for name, test := range tests {
+		w := httptest.NewRecorder()
+		r, err := http.NewRequest("", "/", nil)
+		if err != nil {
+			t.Error("Error creating new request")
+		}
+		r.Header.Add("Cookie", tocookie.Name+"="+tocookie.New(userName, time.Now().Add(test.expiry), secret))
+
+		f(w, r)
+
+		if refreshed := w.Header().Get("Set-Cookie") != ""; refreshed != test.refreshed {
+			t.Errorf("%v: expected refreshed %v, actual: %v", name, test.refreshed, refreshed)
+		}
+	}
+}
+
+func TestWrapAuthPreviousSecrets(t *testing.T) {
+	mockDB, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer mockDB.Close()
+
+	db := sqlx.NewDb(mockDB, "sqlmock")
+	defer db.Close()
+
+	userName := "user1"
+	secrets := []string{"new secret", "old secret"}
+
+	prepare := mock.ExpectPrepare("SELECT")
+	for i := 0; i < 2; i++ {
+		rows := sqlmock.NewRows([]string{"priv_level", "username", "id", "tenant_id"})
+		rows.AddRow(30, "user1", 1, 1)
+		prepare.ExpectQuery().WithArgs(userName).WillReturnRows(rows)
+	}
+
+	sqlStatement, err := prepareUserInfoStmt(db)
+	if err != nil {
+		t.Fatalf("could not create priv statement: %v\n", err)
+	}
+
+	authBase := AuthBase{secret: secrets[0], keys: tocookie.NewKeySetFromSecrets(secrets...), getCurrentUserInfoStmt: sqlStatement}
+	f := authBase.GetWrapper(15)(func(w http.ResponseWriter, r *http.Request) {})
+
+	tests := map[string]struct {
+		secret string
+		status int
+	}{
+		"current secret":  {secrets[0], http.StatusOK},
+		"previous secret": {secrets[1], http.StatusOK},
+		"unknown secret":  {"other secret", http.StatusUnauthorized},
+	}
+	for name, test := range tests {
+		w := httptest.NewRecorder()
+		r, err := http.NewRequest("", "/", nil)
+		if err != nil {
+			t.Error("Error creating new request")
+		}
+		r.Header.Add("Cookie", tocookie.Name+"="+tocookie.New(userName, time.Now().Add(time.Hour), test.secret))
+
+		f(w, r)
+
+		if w.Code != test.status {
+			t.Errorf("%v: expected status %v, actual: %v", name, test.status, w.Code)
+		}
+	}
+}
+
 // TODO: TestWrapAccessLog